@@ -3,15 +3,18 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"log"
 	"net"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/codes"
 
 
+	"GalleryService/config"
 	"GalleryService/internal/db"
 	"GalleryService/internal/jwt"
 	"GalleryService/internal/middleware"
@@ -220,7 +223,7 @@ func (s *galleryServer) DownloadMedia(ctx context.Context, req *proto.DownloadMe
 	var buf bytes.Buffer
 	if err := s.mediaService.DownloadMedia(uint(req.MediaId), userID, &buf); err != nil {
 		log.Printf("Erreur lors du téléchargement du média : %v", err)
-		return nil, status.Errorf(codes.Internal, "échec du téléchargement du média : %v", err)
+		return nil, status.Errorf(mediaErrorCode(err), "échec du téléchargement du média : %v", err)
 	}
 
 	return &proto.DownloadMediaResponse{
@@ -228,6 +231,59 @@ func (s *galleryServer) DownloadMedia(ctx context.Context, req *proto.DownloadMe
 	}, nil
 }
 
+// mediaErrorCode traduit les erreurs d'accès à un média en code gRPC
+func mediaErrorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, services.ErrMediaNotFound):
+		return codes.NotFound
+	case errors.Is(err, services.ErrMediaForbidden):
+		return codes.PermissionDenied
+	default:
+		return codes.Internal
+	}
+}
+
+func (s *galleryServer) GetMediaURL(ctx context.Context, req *proto.GetMediaURLRequest) (*proto.GetMediaURLResponse, error) {
+	userID, err := jwt.ExtractUserIDFromContext(ctx)
+	if err != nil {
+		log.Printf("Erreur d'extraction du userID : %v", err)
+		return nil, status.Errorf(codes.Unauthenticated, "token invalide : %v", err)
+	}
+
+	expires := time.Duration(req.ExpiresIn) * time.Second
+	url, expiresAt, err := s.mediaService.GetMediaURL(uint(req.MediaId), userID, expires)
+	if err != nil {
+		log.Printf("Erreur lors de la génération du lien du média : %v", err)
+		return nil, status.Errorf(mediaErrorCode(err), "échec de la génération du lien du média : %v", err)
+	}
+
+	return &proto.GetMediaURLResponse{
+		Url:       url,
+		ExpiresAt: expiresAt.Unix(),
+	}, nil
+}
+
+func (s *galleryServer) GetMediaUploadForm(ctx context.Context, req *proto.GetMediaUploadFormRequest) (*proto.GetMediaUploadFormResponse, error) {
+	userID, err := jwt.ExtractUserIDFromContext(ctx)
+	if err != nil {
+		log.Printf("Erreur d'extraction du userID : %v", err)
+		return nil, status.Errorf(codes.Unauthenticated, "token invalide : %v", err)
+	}
+
+	expires := time.Duration(req.ExpiresIn) * time.Second
+	url, fields, expiresAt, err := s.mediaService.GetMediaUploadForm(uint(req.AlbumId), userID, expires)
+	if err != nil {
+		log.Printf("Erreur lors de la génération du formulaire d'upload : %v", err)
+		return nil, status.Errorf(mediaErrorCode(err), "échec de la génération du formulaire d'upload : %v", err)
+	}
+
+	return &proto.GetMediaUploadFormResponse{
+		Url:       url,
+		Fields:    fields,
+		ExpiresAt: expiresAt.Unix(),
+	}, nil
+}
+
 func (s *galleryServer) DeleteMedia(ctx context.Context, req *proto.DeleteMediaRequest) (*proto.DeleteMediaResponse, error) {
 
 	userID, err := jwt.ExtractUserIDFromContext(ctx)
@@ -344,7 +400,16 @@ func main() {
 	}
 
 	// Initialiser le S3Service
-	s3Service := services.NewS3Service("http://my-s3-clone:9090")
+	cfg := config.LoadConfig()
+	s3APIURL := cfg.S3APIURL
+	if s3APIURL == "" {
+		s3APIURL = "http://my-s3-clone:9090"
+	}
+	s3Service := services.NewS3Service(s3APIURL)
+	s3Service.PublicURL = cfg.S3PublicURL
+	s3Service.AccessKey = cfg.S3AccessKey
+	s3Service.SecretKey = cfg.S3SecretKey
+	s3Service.Region = cfg.S3Region
 
 	// Initialize services
 	albumService := services.NewAlbumService(dbManager, s3Service)
@@ -359,16 +424,18 @@ func main() {
 
 	// Définir les méthodes protégées (authentification requise)
 	methodsToIntercept := map[string]bool{
-		"/proto.AlbumService/CreateAlbum":     true,
-		"/proto.AlbumService/UpdateAlbum":     true,
-		"/proto.AlbumService/DeleteAlbum":     true,
-		"/proto.AlbumService/GetPrivateAlbum": true,
-		"/proto.MediaService/AddMedia":        true,
-		"/proto.MediaService/MarkAsPrivate":   true,
-		"/proto.MediaService/GetPrivateMedia": true,
-		"/proto.MediaService/DownloadMedia":   true,
-		"/proto.MediaService/DeleteMedia":     true,
-		"/proto.MediaService/GetMediaByAlbum": true,
+		"/proto.AlbumService/CreateAlbum":        true,
+		"/proto.AlbumService/UpdateAlbum":        true,
+		"/proto.AlbumService/DeleteAlbum":        true,
+		"/proto.AlbumService/GetPrivateAlbum":    true,
+		"/proto.MediaService/AddMedia":           true,
+		"/proto.MediaService/MarkAsPrivate":      true,
+		"/proto.MediaService/GetPrivateMedia":    true,
+		"/proto.MediaService/DownloadMedia":      true,
+		"/proto.MediaService/GetMediaURL":        true,
+		"/proto.MediaService/GetMediaUploadForm": true,
+		"/proto.MediaService/DeleteMedia":        true,
+		"/proto.MediaService/GetMediaByAlbum":    true,
 	}
 
 	// Créer le serveur gRPC avec intercepteur JWT
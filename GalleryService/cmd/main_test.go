@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"GalleryService/internal/services"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Test that media access errors keep their meaning once translated to gRPC codes
+func TestMediaErrorCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		want codes.Code
+	}{
+		{fmt.Errorf("%w : ID 4", services.ErrMediaNotFound), codes.NotFound},
+		{fmt.Errorf("%w : l'utilisateur 2 n'est pas le propriétaire du média 4", services.ErrMediaForbidden), codes.PermissionDenied},
+		{errors.New("connexion à la base perdue"), codes.Internal},
+	}
+	for _, c := range cases {
+		if got := mediaErrorCode(c.err); got != c.want {
+			t.Errorf("mediaErrorCode(%q) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
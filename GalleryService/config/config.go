@@ -11,15 +11,24 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	S3APIURL   string
+	// URL de l'API S3 accessible par les clients, utilisée pour les liens présignés
+	S3PublicURL string
+	S3AccessKey string
+	S3SecretKey string
+	S3Region    string
 }
 
 func LoadConfig() Config {
 	return Config{
-		DBHost:     os.Getenv("DB_HOST"),
-		DBPort:     os.Getenv("DB_PORT"),
-		DBUser:     os.Getenv("DB_USER"),
-		DBPassword: os.Getenv("DB_PASSWORD"),
-		DBName:     os.Getenv("DB_NAME"),
-		S3APIURL:   os.Getenv("S3_API_URL"),
+		DBHost:      os.Getenv("DB_HOST"),
+		DBPort:      os.Getenv("DB_PORT"),
+		DBUser:      os.Getenv("DB_USER"),
+		DBPassword:  os.Getenv("DB_PASSWORD"),
+		DBName:      os.Getenv("DB_NAME"),
+		S3APIURL:    os.Getenv("S3_API_URL"),
+		S3PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+		S3Region:    os.Getenv("S3_REGION"),
 	}
 }
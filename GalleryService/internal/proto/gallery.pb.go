@@ -1051,6 +1051,224 @@ func (x *DownloadMediaResponse) GetFileData() []byte {
 	return nil
 }
 
+// Lien présigné permettant au navigateur de télécharger l'original directement depuis le stockage
+type GetMediaURLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MediaId       uint32                 `protobuf:"varint,1,opt,name=media_id,json=mediaId,proto3" json:"media_id,omitempty"`
+	ExpiresIn     uint32                 `protobuf:"varint,2,opt,name=expires_in,json=expiresIn,proto3" json:"expires_in,omitempty"` // durée de validité en secondes, 0 pour la valeur par défaut
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMediaURLRequest) Reset() {
+	*x = GetMediaURLRequest{}
+	mi := &file_proto_gallery_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMediaURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMediaURLRequest) ProtoMessage() {}
+
+func (x *GetMediaURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gallery_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMediaURLRequest.ProtoReflect.Descriptor instead.
+func (*GetMediaURLRequest) Descriptor() ([]byte, []int) {
+	return file_proto_gallery_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *GetMediaURLRequest) GetMediaId() uint32 {
+	if x != nil {
+		return x.MediaId
+	}
+	return 0
+}
+
+func (x *GetMediaURLRequest) GetExpiresIn() uint32 {
+	if x != nil {
+		return x.ExpiresIn
+	}
+	return 0
+}
+
+type GetMediaURLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	ExpiresAt     int64                  `protobuf:"varint,2,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"` // timestamp Unix d'expiration du lien
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMediaURLResponse) Reset() {
+	*x = GetMediaURLResponse{}
+	mi := &file_proto_gallery_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMediaURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMediaURLResponse) ProtoMessage() {}
+
+func (x *GetMediaURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gallery_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMediaURLResponse.ProtoReflect.Descriptor instead.
+func (*GetMediaURLResponse) Descriptor() ([]byte, []int) {
+	return file_proto_gallery_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *GetMediaURLResponse) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *GetMediaURLResponse) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+// Formulaire POST signé permettant au navigateur d'envoyer un média directement dans le bucket de l'album
+type GetMediaUploadFormRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AlbumId       uint32                 `protobuf:"varint,1,opt,name=album_id,json=albumId,proto3" json:"album_id,omitempty"`
+	ExpiresIn     uint32                 `protobuf:"varint,2,opt,name=expires_in,json=expiresIn,proto3" json:"expires_in,omitempty"` // durée de validité en secondes, 0 pour la valeur par défaut
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMediaUploadFormRequest) Reset() {
+	*x = GetMediaUploadFormRequest{}
+	mi := &file_proto_gallery_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMediaUploadFormRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMediaUploadFormRequest) ProtoMessage() {}
+
+func (x *GetMediaUploadFormRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gallery_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMediaUploadFormRequest.ProtoReflect.Descriptor instead.
+func (*GetMediaUploadFormRequest) Descriptor() ([]byte, []int) {
+	return file_proto_gallery_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *GetMediaUploadFormRequest) GetAlbumId() uint32 {
+	if x != nil {
+		return x.AlbumId
+	}
+	return 0
+}
+
+func (x *GetMediaUploadFormRequest) GetExpiresIn() uint32 {
+	if x != nil {
+		return x.ExpiresIn
+	}
+	return 0
+}
+
+type GetMediaUploadFormResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`                                                                                 // action du formulaire
+	Fields        map[string]string      `protobuf:"bytes,2,rep,name=fields,proto3" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // champs à envoyer avant le champ file
+	ExpiresAt     int64                  `protobuf:"varint,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`                                                   // timestamp Unix d'expiration de la politique
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMediaUploadFormResponse) Reset() {
+	*x = GetMediaUploadFormResponse{}
+	mi := &file_proto_gallery_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMediaUploadFormResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMediaUploadFormResponse) ProtoMessage() {}
+
+func (x *GetMediaUploadFormResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gallery_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMediaUploadFormResponse.ProtoReflect.Descriptor instead.
+func (*GetMediaUploadFormResponse) Descriptor() ([]byte, []int) {
+	return file_proto_gallery_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *GetMediaUploadFormResponse) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *GetMediaUploadFormResponse) GetFields() map[string]string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *GetMediaUploadFormResponse) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
 type DeleteMediaRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	MediaId       uint32                 `protobuf:"varint,1,opt,name=media_id,json=mediaId,proto3" json:"media_id,omitempty"`
@@ -1060,7 +1278,7 @@ type DeleteMediaRequest struct {
 
 func (x *DeleteMediaRequest) Reset() {
 	*x = DeleteMediaRequest{}
-	mi := &file_proto_gallery_proto_msgTypes[21]
+	mi := &file_proto_gallery_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1072,7 +1290,7 @@ func (x *DeleteMediaRequest) String() string {
 func (*DeleteMediaRequest) ProtoMessage() {}
 
 func (x *DeleteMediaRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gallery_proto_msgTypes[21]
+	mi := &file_proto_gallery_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1085,7 +1303,7 @@ func (x *DeleteMediaRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteMediaRequest.ProtoReflect.Descriptor instead.
 func (*DeleteMediaRequest) Descriptor() ([]byte, []int) {
-	return file_proto_gallery_proto_rawDescGZIP(), []int{21}
+	return file_proto_gallery_proto_rawDescGZIP(), []int{25}
 }
 
 func (x *DeleteMediaRequest) GetMediaId() uint32 {
@@ -1104,7 +1322,7 @@ type DeleteMediaResponse struct {
 
 func (x *DeleteMediaResponse) Reset() {
 	*x = DeleteMediaResponse{}
-	mi := &file_proto_gallery_proto_msgTypes[22]
+	mi := &file_proto_gallery_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1116,7 +1334,7 @@ func (x *DeleteMediaResponse) String() string {
 func (*DeleteMediaResponse) ProtoMessage() {}
 
 func (x *DeleteMediaResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gallery_proto_msgTypes[22]
+	mi := &file_proto_gallery_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1129,7 +1347,7 @@ func (x *DeleteMediaResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteMediaResponse.ProtoReflect.Descriptor instead.
 func (*DeleteMediaResponse) Descriptor() ([]byte, []int) {
-	return file_proto_gallery_proto_rawDescGZIP(), []int{22}
+	return file_proto_gallery_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *DeleteMediaResponse) GetMessage() string {
@@ -1150,7 +1368,7 @@ type CreateUserRequest struct {
 
 func (x *CreateUserRequest) Reset() {
 	*x = CreateUserRequest{}
-	mi := &file_proto_gallery_proto_msgTypes[23]
+	mi := &file_proto_gallery_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1162,7 +1380,7 @@ func (x *CreateUserRequest) String() string {
 func (*CreateUserRequest) ProtoMessage() {}
 
 func (x *CreateUserRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gallery_proto_msgTypes[23]
+	mi := &file_proto_gallery_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1175,7 +1393,7 @@ func (x *CreateUserRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateUserRequest.ProtoReflect.Descriptor instead.
 func (*CreateUserRequest) Descriptor() ([]byte, []int) {
-	return file_proto_gallery_proto_rawDescGZIP(), []int{23}
+	return file_proto_gallery_proto_rawDescGZIP(), []int{27}
 }
 
 func (x *CreateUserRequest) GetUsername() string {
@@ -1201,7 +1419,7 @@ type CreateUserResponse struct {
 
 func (x *CreateUserResponse) Reset() {
 	*x = CreateUserResponse{}
-	mi := &file_proto_gallery_proto_msgTypes[24]
+	mi := &file_proto_gallery_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1213,7 +1431,7 @@ func (x *CreateUserResponse) String() string {
 func (*CreateUserResponse) ProtoMessage() {}
 
 func (x *CreateUserResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gallery_proto_msgTypes[24]
+	mi := &file_proto_gallery_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1226,7 +1444,7 @@ func (x *CreateUserResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateUserResponse.ProtoReflect.Descriptor instead.
 func (*CreateUserResponse) Descriptor() ([]byte, []int) {
-	return file_proto_gallery_proto_rawDescGZIP(), []int{24}
+	return file_proto_gallery_proto_rawDescGZIP(), []int{28}
 }
 
 func (x *CreateUserResponse) GetMessage() string {
@@ -1246,7 +1464,7 @@ type GetMediaByAlbumRequest struct {
 
 func (x *GetMediaByAlbumRequest) Reset() {
 	*x = GetMediaByAlbumRequest{}
-	mi := &file_proto_gallery_proto_msgTypes[25]
+	mi := &file_proto_gallery_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1258,7 +1476,7 @@ func (x *GetMediaByAlbumRequest) String() string {
 func (*GetMediaByAlbumRequest) ProtoMessage() {}
 
 func (x *GetMediaByAlbumRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gallery_proto_msgTypes[25]
+	mi := &file_proto_gallery_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1271,7 +1489,7 @@ func (x *GetMediaByAlbumRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetMediaByAlbumRequest.ProtoReflect.Descriptor instead.
 func (*GetMediaByAlbumRequest) Descriptor() ([]byte, []int) {
-	return file_proto_gallery_proto_rawDescGZIP(), []int{25}
+	return file_proto_gallery_proto_rawDescGZIP(), []int{29}
 }
 
 func (x *GetMediaByAlbumRequest) GetAlbumId() uint32 {
@@ -1291,7 +1509,7 @@ type GetMediaByAlbumResponse struct {
 
 func (x *GetMediaByAlbumResponse) Reset() {
 	*x = GetMediaByAlbumResponse{}
-	mi := &file_proto_gallery_proto_msgTypes[26]
+	mi := &file_proto_gallery_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1303,7 +1521,7 @@ func (x *GetMediaByAlbumResponse) String() string {
 func (*GetMediaByAlbumResponse) ProtoMessage() {}
 
 func (x *GetMediaByAlbumResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gallery_proto_msgTypes[26]
+	mi := &file_proto_gallery_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1316,7 +1534,7 @@ func (x *GetMediaByAlbumResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetMediaByAlbumResponse.ProtoReflect.Descriptor instead.
 func (*GetMediaByAlbumResponse) Descriptor() ([]byte, []int) {
-	return file_proto_gallery_proto_rawDescGZIP(), []int{26}
+	return file_proto_gallery_proto_rawDescGZIP(), []int{30}
 }
 
 func (x *GetMediaByAlbumResponse) GetMedia() []*Media {
@@ -1340,7 +1558,7 @@ type Album struct {
 
 func (x *Album) Reset() {
 	*x = Album{}
-	mi := &file_proto_gallery_proto_msgTypes[27]
+	mi := &file_proto_gallery_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1352,7 +1570,7 @@ func (x *Album) String() string {
 func (*Album) ProtoMessage() {}
 
 func (x *Album) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gallery_proto_msgTypes[27]
+	mi := &file_proto_gallery_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1365,7 +1583,7 @@ func (x *Album) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Album.ProtoReflect.Descriptor instead.
 func (*Album) Descriptor() ([]byte, []int) {
-	return file_proto_gallery_proto_rawDescGZIP(), []int{27}
+	return file_proto_gallery_proto_rawDescGZIP(), []int{31}
 }
 
 func (x *Album) GetId() uint32 {
@@ -1418,7 +1636,7 @@ type Media struct {
 
 func (x *Media) Reset() {
 	*x = Media{}
-	mi := &file_proto_gallery_proto_msgTypes[28]
+	mi := &file_proto_gallery_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1430,7 +1648,7 @@ func (x *Media) String() string {
 func (*Media) ProtoMessage() {}
 
 func (x *Media) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gallery_proto_msgTypes[28]
+	mi := &file_proto_gallery_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1443,7 +1661,7 @@ func (x *Media) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Media.ProtoReflect.Descriptor instead.
 func (*Media) Descriptor() ([]byte, []int) {
-	return file_proto_gallery_proto_rawDescGZIP(), []int{28}
+	return file_proto_gallery_proto_rawDescGZIP(), []int{32}
 }
 
 func (x *Media) GetId() uint32 {
@@ -1504,7 +1722,7 @@ type MediaGroup struct {
 
 func (x *MediaGroup) Reset() {
 	*x = MediaGroup{}
-	mi := &file_proto_gallery_proto_msgTypes[29]
+	mi := &file_proto_gallery_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1516,7 +1734,7 @@ func (x *MediaGroup) String() string {
 func (*MediaGroup) ProtoMessage() {}
 
 func (x *MediaGroup) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gallery_proto_msgTypes[29]
+	mi := &file_proto_gallery_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1529,7 +1747,7 @@ func (x *MediaGroup) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MediaGroup.ProtoReflect.Descriptor instead.
 func (*MediaGroup) Descriptor() ([]byte, []int) {
-	return file_proto_gallery_proto_rawDescGZIP(), []int{29}
+	return file_proto_gallery_proto_rawDescGZIP(), []int{33}
 }
 
 func (x *MediaGroup) GetMedia() []*Media {
@@ -1548,7 +1766,7 @@ type DetectSimilarMediaRequest struct {
 
 func (x *DetectSimilarMediaRequest) Reset() {
 	*x = DetectSimilarMediaRequest{}
-	mi := &file_proto_gallery_proto_msgTypes[30]
+	mi := &file_proto_gallery_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1560,7 +1778,7 @@ func (x *DetectSimilarMediaRequest) String() string {
 func (*DetectSimilarMediaRequest) ProtoMessage() {}
 
 func (x *DetectSimilarMediaRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gallery_proto_msgTypes[30]
+	mi := &file_proto_gallery_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1573,7 +1791,7 @@ func (x *DetectSimilarMediaRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DetectSimilarMediaRequest.ProtoReflect.Descriptor instead.
 func (*DetectSimilarMediaRequest) Descriptor() ([]byte, []int) {
-	return file_proto_gallery_proto_rawDescGZIP(), []int{30}
+	return file_proto_gallery_proto_rawDescGZIP(), []int{34}
 }
 
 func (x *DetectSimilarMediaRequest) GetAlbumId() uint32 {
@@ -1592,7 +1810,7 @@ type DetectSimilarMediaResponse struct {
 
 func (x *DetectSimilarMediaResponse) Reset() {
 	*x = DetectSimilarMediaResponse{}
-	mi := &file_proto_gallery_proto_msgTypes[31]
+	mi := &file_proto_gallery_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1604,7 +1822,7 @@ func (x *DetectSimilarMediaResponse) String() string {
 func (*DetectSimilarMediaResponse) ProtoMessage() {}
 
 func (x *DetectSimilarMediaResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gallery_proto_msgTypes[31]
+	mi := &file_proto_gallery_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1617,7 +1835,7 @@ func (x *DetectSimilarMediaResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DetectSimilarMediaResponse.ProtoReflect.Descriptor instead.
 func (*DetectSimilarMediaResponse) Descriptor() ([]byte, []int) {
-	return file_proto_gallery_proto_rawDescGZIP(), []int{31}
+	return file_proto_gallery_proto_rawDescGZIP(), []int{35}
 }
 
 func (x *DetectSimilarMediaResponse) GetGroups() []*MediaGroup {
@@ -1636,7 +1854,7 @@ type AddMediaToFavoriteRequest struct {
 
 func (x *AddMediaToFavoriteRequest) Reset() {
 	*x = AddMediaToFavoriteRequest{}
-	mi := &file_proto_gallery_proto_msgTypes[32]
+	mi := &file_proto_gallery_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1648,7 +1866,7 @@ func (x *AddMediaToFavoriteRequest) String() string {
 func (*AddMediaToFavoriteRequest) ProtoMessage() {}
 
 func (x *AddMediaToFavoriteRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gallery_proto_msgTypes[32]
+	mi := &file_proto_gallery_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1661,7 +1879,7 @@ func (x *AddMediaToFavoriteRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AddMediaToFavoriteRequest.ProtoReflect.Descriptor instead.
 func (*AddMediaToFavoriteRequest) Descriptor() ([]byte, []int) {
-	return file_proto_gallery_proto_rawDescGZIP(), []int{32}
+	return file_proto_gallery_proto_rawDescGZIP(), []int{36}
 }
 
 func (x *AddMediaToFavoriteRequest) GetMediaId() uint32 {
@@ -1680,7 +1898,7 @@ type AddMediaToFavoriteResponse struct {
 
 func (x *AddMediaToFavoriteResponse) Reset() {
 	*x = AddMediaToFavoriteResponse{}
-	mi := &file_proto_gallery_proto_msgTypes[33]
+	mi := &file_proto_gallery_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1692,7 +1910,7 @@ func (x *AddMediaToFavoriteResponse) String() string {
 func (*AddMediaToFavoriteResponse) ProtoMessage() {}
 
 func (x *AddMediaToFavoriteResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gallery_proto_msgTypes[33]
+	mi := &file_proto_gallery_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1705,7 +1923,7 @@ func (x *AddMediaToFavoriteResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AddMediaToFavoriteResponse.ProtoReflect.Descriptor instead.
 func (*AddMediaToFavoriteResponse) Descriptor() ([]byte, []int) {
-	return file_proto_gallery_proto_rawDescGZIP(), []int{33}
+	return file_proto_gallery_proto_rawDescGZIP(), []int{37}
 }
 
 func (x *AddMediaToFavoriteResponse) GetMessage() string {
@@ -1775,7 +1993,27 @@ const file_proto_gallery_proto_rawDesc = "" +
 	"\x14DownloadMediaRequest\x12\x19\n" +
 	"\bmedia_id\x18\x01 \x01(\rR\amediaId\"4\n" +
 	"\x15DownloadMediaResponse\x12\x1b\n" +
-	"\tfile_data\x18\x01 \x01(\fR\bfileData\"/\n" +
+	"\tfile_data\x18\x01 \x01(\fR\bfileData\"N\n" +
+	"\x12GetMediaURLRequest\x12\x19\n" +
+	"\bmedia_id\x18\x01 \x01(\rR\amediaId\x12\x1d\n" +
+	"\n" +
+	"expires_in\x18\x02 \x01(\rR\texpiresIn\"F\n" +
+	"\x13GetMediaURLResponse\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x02 \x01(\x03R\texpiresAt\"U\n" +
+	"\x19GetMediaUploadFormRequest\x12\x19\n" +
+	"\balbum_id\x18\x01 \x01(\rR\aalbumId\x12\x1d\n" +
+	"\n" +
+	"expires_in\x18\x02 \x01(\rR\texpiresIn\"\xcf\x01\n" +
+	"\x1aGetMediaUploadFormResponse\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12E\n" +
+	"\x06fields\x18\x02 \x03(\v2-.proto.GetMediaUploadFormResponse.FieldsEntryR\x06fields\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x03 \x01(\x03R\texpiresAt\x1a9\n" +
+	"\vFieldsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"/\n" +
 	"\x12DeleteMediaRequest\x12\x19\n" +
 	"\bmedia_id\x18\x01 \x01(\rR\amediaId\"/\n" +
 	"\x13DeleteMediaResponse\x12\x18\n" +
@@ -1821,13 +2059,15 @@ const file_proto_gallery_proto_rawDesc = "" +
 	"\x0fGetAlbumsByUser\x12\x1d.proto.GetAlbumsByUserRequest\x1a\x1e.proto.GetAlbumsByUserResponse\x12D\n" +
 	"\vUpdateAlbum\x12\x19.proto.UpdateAlbumRequest\x1a\x1a.proto.UpdateAlbumResponse\x12D\n" +
 	"\vDeleteAlbum\x12\x19.proto.DeleteAlbumRequest\x1a\x1a.proto.DeleteAlbumResponse\x12P\n" +
-	"\x0fGetPrivateAlbum\x12\x1d.proto.GetPrivateAlbumRequest\x1a\x1e.proto.GetPrivateAlbumResponse2\xd2\x05\n" +
+	"\x0fGetPrivateAlbum\x12\x1d.proto.GetPrivateAlbumRequest\x1a\x1e.proto.GetPrivateAlbumResponse2\xf3\x06\n" +
 	"\fMediaService\x12;\n" +
 	"\bAddMedia\x12\x16.proto.AddMediaRequest\x1a\x17.proto.AddMediaResponse\x12M\n" +
 	"\x0eGetMediaByUser\x12\x1c.proto.GetMediaByUserRequest\x1a\x1d.proto.GetMediaByUserResponse\x12J\n" +
 	"\rMarkAsPrivate\x12\x1b.proto.MarkAsPrivateRequest\x1a\x1c.proto.MarkAsPrivateResponse\x12P\n" +
 	"\x0fGetPrivateMedia\x12\x1d.proto.GetPrivateMediaRequest\x1a\x1e.proto.GetPrivateMediaResponse\x12J\n" +
 	"\rDownloadMedia\x12\x1b.proto.DownloadMediaRequest\x1a\x1c.proto.DownloadMediaResponse\x12D\n" +
+	"\vGetMediaURL\x12\x19.proto.GetMediaURLRequest\x1a\x1a.proto.GetMediaURLResponse\x12Y\n" +
+	"\x12GetMediaUploadForm\x12 .proto.GetMediaUploadFormRequest\x1a!.proto.GetMediaUploadFormResponse\x12D\n" +
 	"\vDeleteMedia\x12\x19.proto.DeleteMediaRequest\x1a\x1a.proto.DeleteMediaResponse\x12Y\n" +
 	"\x12DetectSimilarMedia\x12 .proto.DetectSimilarMediaRequest\x1a!.proto.DetectSimilarMediaResponse\x12Y\n" +
 	"\x12AddMediaToFavorite\x12 .proto.AddMediaToFavoriteRequest\x1a!.proto.AddMediaToFavoriteResponse\x12P\n" +
@@ -1848,7 +2088,7 @@ func file_proto_gallery_proto_rawDescGZIP() []byte {
 	return file_proto_gallery_proto_rawDescData
 }
 
-var file_proto_gallery_proto_msgTypes = make([]protoimpl.MessageInfo, 34)
+var file_proto_gallery_proto_msgTypes = make([]protoimpl.MessageInfo, 39)
 var file_proto_gallery_proto_goTypes = []any{
 	(*CreateAlbumRequest)(nil),         // 0: proto.CreateAlbumRequest
 	(*CreateAlbumResponse)(nil),        // 1: proto.CreateAlbumResponse
@@ -1871,65 +2111,75 @@ var file_proto_gallery_proto_goTypes = []any{
 	(*GetPrivateMediaResponse)(nil),    // 18: proto.GetPrivateMediaResponse
 	(*DownloadMediaRequest)(nil),       // 19: proto.DownloadMediaRequest
 	(*DownloadMediaResponse)(nil),      // 20: proto.DownloadMediaResponse
-	(*DeleteMediaRequest)(nil),         // 21: proto.DeleteMediaRequest
-	(*DeleteMediaResponse)(nil),        // 22: proto.DeleteMediaResponse
-	(*CreateUserRequest)(nil),          // 23: proto.CreateUserRequest
-	(*CreateUserResponse)(nil),         // 24: proto.CreateUserResponse
-	(*GetMediaByAlbumRequest)(nil),     // 25: proto.GetMediaByAlbumRequest
-	(*GetMediaByAlbumResponse)(nil),    // 26: proto.GetMediaByAlbumResponse
-	(*Album)(nil),                      // 27: proto.Album
-	(*Media)(nil),                      // 28: proto.Media
-	(*MediaGroup)(nil),                 // 29: proto.MediaGroup
-	(*DetectSimilarMediaRequest)(nil),  // 30: proto.DetectSimilarMediaRequest
-	(*DetectSimilarMediaResponse)(nil), // 31: proto.DetectSimilarMediaResponse
-	(*AddMediaToFavoriteRequest)(nil),  // 32: proto.AddMediaToFavoriteRequest
-	(*AddMediaToFavoriteResponse)(nil), // 33: proto.AddMediaToFavoriteResponse
+	(*GetMediaURLRequest)(nil),         // 21: proto.GetMediaURLRequest
+	(*GetMediaURLResponse)(nil),        // 22: proto.GetMediaURLResponse
+	(*GetMediaUploadFormRequest)(nil),  // 23: proto.GetMediaUploadFormRequest
+	(*GetMediaUploadFormResponse)(nil), // 24: proto.GetMediaUploadFormResponse
+	(*DeleteMediaRequest)(nil),         // 25: proto.DeleteMediaRequest
+	(*DeleteMediaResponse)(nil),        // 26: proto.DeleteMediaResponse
+	(*CreateUserRequest)(nil),          // 27: proto.CreateUserRequest
+	(*CreateUserResponse)(nil),         // 28: proto.CreateUserResponse
+	(*GetMediaByAlbumRequest)(nil),     // 29: proto.GetMediaByAlbumRequest
+	(*GetMediaByAlbumResponse)(nil),    // 30: proto.GetMediaByAlbumResponse
+	(*Album)(nil),                      // 31: proto.Album
+	(*Media)(nil),                      // 32: proto.Media
+	(*MediaGroup)(nil),                 // 33: proto.MediaGroup
+	(*DetectSimilarMediaRequest)(nil),  // 34: proto.DetectSimilarMediaRequest
+	(*DetectSimilarMediaResponse)(nil), // 35: proto.DetectSimilarMediaResponse
+	(*AddMediaToFavoriteRequest)(nil),  // 36: proto.AddMediaToFavoriteRequest
+	(*AddMediaToFavoriteResponse)(nil), // 37: proto.AddMediaToFavoriteResponse
+	nil,                                // 38: proto.GetMediaUploadFormResponse.FieldsEntry
 }
 var file_proto_gallery_proto_depIdxs = []int32{
-	28, // 0: proto.AlbumWithMedia.media:type_name -> proto.Media
+	32, // 0: proto.AlbumWithMedia.media:type_name -> proto.Media
 	3,  // 1: proto.GetAlbumsByUserResponse.albums:type_name -> proto.AlbumWithMedia
-	27, // 2: proto.GetPrivateAlbumResponse.album:type_name -> proto.Album
-	28, // 3: proto.GetMediaByUserResponse.media_list:type_name -> proto.Media
-	28, // 4: proto.GetPrivateMediaResponse.media:type_name -> proto.Media
-	28, // 5: proto.GetMediaByAlbumResponse.media:type_name -> proto.Media
-	28, // 6: proto.Album.media:type_name -> proto.Media
-	28, // 7: proto.MediaGroup.media:type_name -> proto.Media
-	29, // 8: proto.DetectSimilarMediaResponse.groups:type_name -> proto.MediaGroup
-	0,  // 9: proto.AlbumService.CreateAlbum:input_type -> proto.CreateAlbumRequest
-	2,  // 10: proto.AlbumService.GetAlbumsByUser:input_type -> proto.GetAlbumsByUserRequest
-	5,  // 11: proto.AlbumService.UpdateAlbum:input_type -> proto.UpdateAlbumRequest
-	7,  // 12: proto.AlbumService.DeleteAlbum:input_type -> proto.DeleteAlbumRequest
-	9,  // 13: proto.AlbumService.GetPrivateAlbum:input_type -> proto.GetPrivateAlbumRequest
-	11, // 14: proto.MediaService.AddMedia:input_type -> proto.AddMediaRequest
-	13, // 15: proto.MediaService.GetMediaByUser:input_type -> proto.GetMediaByUserRequest
-	15, // 16: proto.MediaService.MarkAsPrivate:input_type -> proto.MarkAsPrivateRequest
-	17, // 17: proto.MediaService.GetPrivateMedia:input_type -> proto.GetPrivateMediaRequest
-	19, // 18: proto.MediaService.DownloadMedia:input_type -> proto.DownloadMediaRequest
-	21, // 19: proto.MediaService.DeleteMedia:input_type -> proto.DeleteMediaRequest
-	30, // 20: proto.MediaService.DetectSimilarMedia:input_type -> proto.DetectSimilarMediaRequest
-	32, // 21: proto.MediaService.AddMediaToFavorite:input_type -> proto.AddMediaToFavoriteRequest
-	25, // 22: proto.MediaService.GetMediaByAlbum:input_type -> proto.GetMediaByAlbumRequest
-	23, // 23: proto.UserService.CreateUser:input_type -> proto.CreateUserRequest
-	1,  // 24: proto.AlbumService.CreateAlbum:output_type -> proto.CreateAlbumResponse
-	4,  // 25: proto.AlbumService.GetAlbumsByUser:output_type -> proto.GetAlbumsByUserResponse
-	6,  // 26: proto.AlbumService.UpdateAlbum:output_type -> proto.UpdateAlbumResponse
-	8,  // 27: proto.AlbumService.DeleteAlbum:output_type -> proto.DeleteAlbumResponse
-	10, // 28: proto.AlbumService.GetPrivateAlbum:output_type -> proto.GetPrivateAlbumResponse
-	12, // 29: proto.MediaService.AddMedia:output_type -> proto.AddMediaResponse
-	14, // 30: proto.MediaService.GetMediaByUser:output_type -> proto.GetMediaByUserResponse
-	16, // 31: proto.MediaService.MarkAsPrivate:output_type -> proto.MarkAsPrivateResponse
-	18, // 32: proto.MediaService.GetPrivateMedia:output_type -> proto.GetPrivateMediaResponse
-	20, // 33: proto.MediaService.DownloadMedia:output_type -> proto.DownloadMediaResponse
-	22, // 34: proto.MediaService.DeleteMedia:output_type -> proto.DeleteMediaResponse
-	31, // 35: proto.MediaService.DetectSimilarMedia:output_type -> proto.DetectSimilarMediaResponse
-	33, // 36: proto.MediaService.AddMediaToFavorite:output_type -> proto.AddMediaToFavoriteResponse
-	26, // 37: proto.MediaService.GetMediaByAlbum:output_type -> proto.GetMediaByAlbumResponse
-	24, // 38: proto.UserService.CreateUser:output_type -> proto.CreateUserResponse
-	24, // [24:39] is the sub-list for method output_type
-	9,  // [9:24] is the sub-list for method input_type
-	9,  // [9:9] is the sub-list for extension type_name
-	9,  // [9:9] is the sub-list for extension extendee
-	0,  // [0:9] is the sub-list for field type_name
+	31, // 2: proto.GetPrivateAlbumResponse.album:type_name -> proto.Album
+	32, // 3: proto.GetMediaByUserResponse.media_list:type_name -> proto.Media
+	32, // 4: proto.GetPrivateMediaResponse.media:type_name -> proto.Media
+	38, // 5: proto.GetMediaUploadFormResponse.fields:type_name -> proto.GetMediaUploadFormResponse.FieldsEntry
+	32, // 6: proto.GetMediaByAlbumResponse.media:type_name -> proto.Media
+	32, // 7: proto.Album.media:type_name -> proto.Media
+	32, // 8: proto.MediaGroup.media:type_name -> proto.Media
+	33, // 9: proto.DetectSimilarMediaResponse.groups:type_name -> proto.MediaGroup
+	0,  // 10: proto.AlbumService.CreateAlbum:input_type -> proto.CreateAlbumRequest
+	2,  // 11: proto.AlbumService.GetAlbumsByUser:input_type -> proto.GetAlbumsByUserRequest
+	5,  // 12: proto.AlbumService.UpdateAlbum:input_type -> proto.UpdateAlbumRequest
+	7,  // 13: proto.AlbumService.DeleteAlbum:input_type -> proto.DeleteAlbumRequest
+	9,  // 14: proto.AlbumService.GetPrivateAlbum:input_type -> proto.GetPrivateAlbumRequest
+	11, // 15: proto.MediaService.AddMedia:input_type -> proto.AddMediaRequest
+	13, // 16: proto.MediaService.GetMediaByUser:input_type -> proto.GetMediaByUserRequest
+	15, // 17: proto.MediaService.MarkAsPrivate:input_type -> proto.MarkAsPrivateRequest
+	17, // 18: proto.MediaService.GetPrivateMedia:input_type -> proto.GetPrivateMediaRequest
+	19, // 19: proto.MediaService.DownloadMedia:input_type -> proto.DownloadMediaRequest
+	21, // 20: proto.MediaService.GetMediaURL:input_type -> proto.GetMediaURLRequest
+	23, // 21: proto.MediaService.GetMediaUploadForm:input_type -> proto.GetMediaUploadFormRequest
+	25, // 22: proto.MediaService.DeleteMedia:input_type -> proto.DeleteMediaRequest
+	34, // 23: proto.MediaService.DetectSimilarMedia:input_type -> proto.DetectSimilarMediaRequest
+	36, // 24: proto.MediaService.AddMediaToFavorite:input_type -> proto.AddMediaToFavoriteRequest
+	29, // 25: proto.MediaService.GetMediaByAlbum:input_type -> proto.GetMediaByAlbumRequest
+	27, // 26: proto.UserService.CreateUser:input_type -> proto.CreateUserRequest
+	1,  // 27: proto.AlbumService.CreateAlbum:output_type -> proto.CreateAlbumResponse
+	4,  // 28: proto.AlbumService.GetAlbumsByUser:output_type -> proto.GetAlbumsByUserResponse
+	6,  // 29: proto.AlbumService.UpdateAlbum:output_type -> proto.UpdateAlbumResponse
+	8,  // 30: proto.AlbumService.DeleteAlbum:output_type -> proto.DeleteAlbumResponse
+	10, // 31: proto.AlbumService.GetPrivateAlbum:output_type -> proto.GetPrivateAlbumResponse
+	12, // 32: proto.MediaService.AddMedia:output_type -> proto.AddMediaResponse
+	14, // 33: proto.MediaService.GetMediaByUser:output_type -> proto.GetMediaByUserResponse
+	16, // 34: proto.MediaService.MarkAsPrivate:output_type -> proto.MarkAsPrivateResponse
+	18, // 35: proto.MediaService.GetPrivateMedia:output_type -> proto.GetPrivateMediaResponse
+	20, // 36: proto.MediaService.DownloadMedia:output_type -> proto.DownloadMediaResponse
+	22, // 37: proto.MediaService.GetMediaURL:output_type -> proto.GetMediaURLResponse
+	24, // 38: proto.MediaService.GetMediaUploadForm:output_type -> proto.GetMediaUploadFormResponse
+	26, // 39: proto.MediaService.DeleteMedia:output_type -> proto.DeleteMediaResponse
+	35, // 40: proto.MediaService.DetectSimilarMedia:output_type -> proto.DetectSimilarMediaResponse
+	37, // 41: proto.MediaService.AddMediaToFavorite:output_type -> proto.AddMediaToFavoriteResponse
+	30, // 42: proto.MediaService.GetMediaByAlbum:output_type -> proto.GetMediaByAlbumResponse
+	28, // 43: proto.UserService.CreateUser:output_type -> proto.CreateUserResponse
+	27, // [27:44] is the sub-list for method output_type
+	10, // [10:27] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_proto_gallery_proto_init() }
@@ -1943,7 +2193,7 @@ func file_proto_gallery_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_gallery_proto_rawDesc), len(file_proto_gallery_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   34,
+			NumMessages:   39,
 			NumExtensions: 0,
 			NumServices:   3,
 		},
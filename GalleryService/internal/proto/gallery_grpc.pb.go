@@ -278,6 +278,8 @@ const (
 	MediaService_MarkAsPrivate_FullMethodName      = "/proto.MediaService/MarkAsPrivate"
 	MediaService_GetPrivateMedia_FullMethodName    = "/proto.MediaService/GetPrivateMedia"
 	MediaService_DownloadMedia_FullMethodName      = "/proto.MediaService/DownloadMedia"
+	MediaService_GetMediaURL_FullMethodName        = "/proto.MediaService/GetMediaURL"
+	MediaService_GetMediaUploadForm_FullMethodName = "/proto.MediaService/GetMediaUploadForm"
 	MediaService_DeleteMedia_FullMethodName        = "/proto.MediaService/DeleteMedia"
 	MediaService_DetectSimilarMedia_FullMethodName = "/proto.MediaService/DetectSimilarMedia"
 	MediaService_AddMediaToFavorite_FullMethodName = "/proto.MediaService/AddMediaToFavorite"
@@ -293,6 +295,8 @@ type MediaServiceClient interface {
 	MarkAsPrivate(ctx context.Context, in *MarkAsPrivateRequest, opts ...grpc.CallOption) (*MarkAsPrivateResponse, error)
 	GetPrivateMedia(ctx context.Context, in *GetPrivateMediaRequest, opts ...grpc.CallOption) (*GetPrivateMediaResponse, error)
 	DownloadMedia(ctx context.Context, in *DownloadMediaRequest, opts ...grpc.CallOption) (*DownloadMediaResponse, error)
+	GetMediaURL(ctx context.Context, in *GetMediaURLRequest, opts ...grpc.CallOption) (*GetMediaURLResponse, error)
+	GetMediaUploadForm(ctx context.Context, in *GetMediaUploadFormRequest, opts ...grpc.CallOption) (*GetMediaUploadFormResponse, error)
 	DeleteMedia(ctx context.Context, in *DeleteMediaRequest, opts ...grpc.CallOption) (*DeleteMediaResponse, error)
 	DetectSimilarMedia(ctx context.Context, in *DetectSimilarMediaRequest, opts ...grpc.CallOption) (*DetectSimilarMediaResponse, error)
 	AddMediaToFavorite(ctx context.Context, in *AddMediaToFavoriteRequest, opts ...grpc.CallOption) (*AddMediaToFavoriteResponse, error)
@@ -357,6 +361,26 @@ func (c *mediaServiceClient) DownloadMedia(ctx context.Context, in *DownloadMedi
 	return out, nil
 }
 
+func (c *mediaServiceClient) GetMediaURL(ctx context.Context, in *GetMediaURLRequest, opts ...grpc.CallOption) (*GetMediaURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetMediaURLResponse)
+	err := c.cc.Invoke(ctx, MediaService_GetMediaURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mediaServiceClient) GetMediaUploadForm(ctx context.Context, in *GetMediaUploadFormRequest, opts ...grpc.CallOption) (*GetMediaUploadFormResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetMediaUploadFormResponse)
+	err := c.cc.Invoke(ctx, MediaService_GetMediaUploadForm_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *mediaServiceClient) DeleteMedia(ctx context.Context, in *DeleteMediaRequest, opts ...grpc.CallOption) (*DeleteMediaResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(DeleteMediaResponse)
@@ -406,6 +430,8 @@ type MediaServiceServer interface {
 	MarkAsPrivate(context.Context, *MarkAsPrivateRequest) (*MarkAsPrivateResponse, error)
 	GetPrivateMedia(context.Context, *GetPrivateMediaRequest) (*GetPrivateMediaResponse, error)
 	DownloadMedia(context.Context, *DownloadMediaRequest) (*DownloadMediaResponse, error)
+	GetMediaURL(context.Context, *GetMediaURLRequest) (*GetMediaURLResponse, error)
+	GetMediaUploadForm(context.Context, *GetMediaUploadFormRequest) (*GetMediaUploadFormResponse, error)
 	DeleteMedia(context.Context, *DeleteMediaRequest) (*DeleteMediaResponse, error)
 	DetectSimilarMedia(context.Context, *DetectSimilarMediaRequest) (*DetectSimilarMediaResponse, error)
 	AddMediaToFavorite(context.Context, *AddMediaToFavoriteRequest) (*AddMediaToFavoriteResponse, error)
@@ -435,6 +461,12 @@ func (UnimplementedMediaServiceServer) GetPrivateMedia(context.Context, *GetPriv
 func (UnimplementedMediaServiceServer) DownloadMedia(context.Context, *DownloadMediaRequest) (*DownloadMediaResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DownloadMedia not implemented")
 }
+func (UnimplementedMediaServiceServer) GetMediaURL(context.Context, *GetMediaURLRequest) (*GetMediaURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMediaURL not implemented")
+}
+func (UnimplementedMediaServiceServer) GetMediaUploadForm(context.Context, *GetMediaUploadFormRequest) (*GetMediaUploadFormResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMediaUploadForm not implemented")
+}
 func (UnimplementedMediaServiceServer) DeleteMedia(context.Context, *DeleteMediaRequest) (*DeleteMediaResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteMedia not implemented")
 }
@@ -558,6 +590,42 @@ func _MediaService_DownloadMedia_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MediaService_GetMediaURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMediaURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MediaServiceServer).GetMediaURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MediaService_GetMediaURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MediaServiceServer).GetMediaURL(ctx, req.(*GetMediaURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MediaService_GetMediaUploadForm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMediaUploadFormRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MediaServiceServer).GetMediaUploadForm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MediaService_GetMediaUploadForm_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MediaServiceServer).GetMediaUploadForm(ctx, req.(*GetMediaUploadFormRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _MediaService_DeleteMedia_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteMediaRequest)
 	if err := dec(in); err != nil {
@@ -657,6 +725,14 @@ var MediaService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DownloadMedia",
 			Handler:    _MediaService_DownloadMedia_Handler,
 		},
+		{
+			MethodName: "GetMediaURL",
+			Handler:    _MediaService_GetMediaURL_Handler,
+		},
+		{
+			MethodName: "GetMediaUploadForm",
+			Handler:    _MediaService_GetMediaUploadForm_Handler,
+		},
 		{
 			MethodName: "DeleteMedia",
 			Handler:    _MediaService_DeleteMedia_Handler,
@@ -3,13 +3,18 @@ package services
 import (
 	"GalleryService/internal/db"
 	"GalleryService/internal/models"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"strings"
     "GalleryService/internal/utils"
     "os"
+	"path/filepath"
 	"strconv"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 type MediaService struct {
@@ -40,12 +45,13 @@ func (s *MediaService) AddMedia(media *models.Media, file io.Reader, fileSize in
 	log.Printf("Chemin du fichier : %s", media.Path)
 
 	// 3. Sauvegarder le fichier temporairement
-	tempFilePath := fmt.Sprintf("/tmp/%s", media.Name)
-	tempFile, err := os.Create(tempFilePath)
+	// Le nom peut contenir des "/" (clé imbriquée) : seul son dernier segment est repris
+	tempFile, err := os.CreateTemp("", "*-"+filepath.Base(media.Name))
 	if err != nil {
-		log.Printf("Erreur création fichier temporaire %s : %v", tempFilePath, err)
+		log.Printf("Erreur création fichier temporaire pour %s : %v", media.Name, err)
 		return fmt.Errorf("échec de la création du fichier temporaire : %v", err)
 	}
+	tempFilePath := tempFile.Name()
 	defer tempFile.Close()
 	defer os.Remove(tempFilePath)
 	log.Printf("Fichier temporaire créé : %s", tempFilePath)
@@ -206,22 +212,44 @@ func (s *MediaService) MarkAsPrivate(mediaID uint, userID uint) error {
     return nil
 }
 
-func (s *MediaService) DownloadMedia(mediaID uint, userID uint, w io.Writer) error {
+// Erreurs renvoyées par getOwnedMedia, que les appelants distinguent avec errors.Is
+var (
+	ErrMediaNotFound  = errors.New("média introuvable")
+	ErrMediaForbidden = errors.New("accès au média refusé")
+)
+
+// getOwnedMedia récupère un média et son album en vérifiant que l'utilisateur en est le propriétaire
+func (s *MediaService) getOwnedMedia(mediaID uint, userID uint) (models.Media, models.Album, error) {
 	// Récupérer le média à partir de la base de données
 	var media models.Media
 	if err := s.DBManager.DB.First(&media, mediaID).Error; err != nil {
-		return fmt.Errorf("média non trouvé pour l'ID %d : %v", mediaID, err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return media, models.Album{}, fmt.Errorf("%w : ID %d", ErrMediaNotFound, mediaID)
+		}
+		return media, models.Album{}, fmt.Errorf("média non trouvé pour l'ID %d : %v", mediaID, err)
 	}
 
 	// Récupérer l'album auquel appartient le média
 	var album models.Album
 	if err := s.DBManager.DB.First(&album, media.AlbumID).Error; err != nil {
-		return fmt.Errorf("album non trouvé pour l'ID %d : %v", media.AlbumID, err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return media, album, fmt.Errorf("%w : album %d du média %d", ErrMediaNotFound, media.AlbumID, mediaID)
+		}
+		return media, album, fmt.Errorf("album non trouvé pour l'ID %d : %v", media.AlbumID, err)
 	}
 
 	// Vérifier que l'utilisateur est bien le propriétaire
 	if album.UserID != userID {
-		return fmt.Errorf("l'utilisateur %d n'est pas autorisé à accéder à ce média", userID)
+		return media, album, fmt.Errorf("%w : l'utilisateur %d n'est pas le propriétaire du média %d", ErrMediaForbidden, userID, mediaID)
+	}
+
+	return media, album, nil
+}
+
+func (s *MediaService) DownloadMedia(mediaID uint, userID uint, w io.Writer) error {
+	media, album, err := s.getOwnedMedia(mediaID, userID)
+	if err != nil {
+		return err
 	}
 
 	// Télécharger le fichier depuis S3
@@ -234,6 +262,75 @@ func (s *MediaService) DownloadMedia(mediaID uint, userID uint, w io.Writer) err
 	return nil
 }
 
+// Durées de validité des liens présignés
+const (
+	DefaultMediaURLExpiry = 15 * time.Minute
+	MaxMediaURLExpiry     = time.Hour
+)
+
+// GetMediaURL génère un lien présigné permettant au client de télécharger le média
+// directement depuis l'API S3, sans transiter par le service. Retourne le lien et sa date d'expiration.
+func (s *MediaService) GetMediaURL(mediaID uint, userID uint, expires time.Duration) (string, time.Time, error) {
+	media, album, err := s.getOwnedMedia(mediaID, userID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if expires <= 0 {
+		expires = DefaultMediaURLExpiry
+	}
+	if expires > MaxMediaURLExpiry {
+		expires = MaxMediaURLExpiry
+	}
+
+	mediaPath := fmt.Sprintf("%s/%s", album.BucketName, media.Name)
+	url, err := s.S3Service.PresignGetURL(mediaPath, expires)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("échec de la génération du lien : %v", err)
+	}
+
+	log.Printf("Lien présigné généré pour %s (valide %s)", mediaPath, expires)
+	return url, time.Now().Add(expires), nil
+}
+
+// Limites des formulaires d'upload direct : les fichiers envoyés sont rangés sous MediaUploadPrefix,
+// à l'écart des médias enregistrés à la racine du bucket, et ne dépassent pas la taille acceptée par AddMedia
+const (
+	MediaUploadPrefix  = "uploads/"
+	MaxMediaUploadSize = 10 << 20
+)
+
+// GetMediaUploadForm prépare un formulaire POST signé permettant au navigateur d'envoyer un média
+// directement dans le bucket d'un album de l'utilisateur. Retourne l'action du formulaire, ses champs
+// et la date d'expiration de la politique.
+func (s *MediaService) GetMediaUploadForm(albumID uint, userID uint, expires time.Duration) (string, map[string]string, time.Time, error) {
+	var album models.Album
+	if err := s.DBManager.DB.First(&album, albumID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, time.Time{}, fmt.Errorf("%w : album %d", ErrMediaNotFound, albumID)
+		}
+		return "", nil, time.Time{}, fmt.Errorf("album non trouvé pour l'ID %d : %v", albumID, err)
+	}
+	if album.UserID != userID {
+		return "", nil, time.Time{}, fmt.Errorf("%w : l'utilisateur %d n'est pas le propriétaire de l'album %d", ErrMediaForbidden, userID, albumID)
+	}
+
+	if expires <= 0 {
+		expires = DefaultMediaURLExpiry
+	}
+	if expires > MaxMediaURLExpiry {
+		expires = MaxMediaURLExpiry
+	}
+
+	url, fields, err := s.S3Service.PresignPostPolicy(album.BucketName, MediaUploadPrefix, MaxMediaUploadSize, expires)
+	if err != nil {
+		return "", nil, time.Time{}, fmt.Errorf("échec de la génération du formulaire : %v", err)
+	}
+
+	log.Printf("Formulaire d'upload généré pour le bucket %s (valide %s)", album.BucketName, expires)
+	return url, fields, time.Now().Add(expires), nil
+}
+
 func (s *MediaService) DeleteMedia(mediaID uint, userID uint) error {
     // Récupérer le média à partir de son ID
     var media models.Media
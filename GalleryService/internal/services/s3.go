@@ -2,18 +2,30 @@ package services
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
 // S3Service gère la communication avec l'API S3-like
 type S3Service struct {
 	APIURL string
+	// URL de l'API S3-like vue par les navigateurs, utilisée pour les liens présignés (APIURL si vide)
+	PublicURL string
+	// Clés utilisées pour signer les requêtes (SigV4). Les requêtes ne sont pas signées si elles sont vides.
+	AccessKey string
+	SecretKey string
+	Region    string
 }
 
 type ListAllMyBucketsResult struct {
@@ -34,6 +46,109 @@ func NewS3Service(apiURL string) *S3Service {
 	return &S3Service{APIURL: apiURL}
 }
 
+// do envoie une requête à l'API S3-like, signée si des clés sont configurées
+func (s *S3Service) do(req *http.Request) (*http.Response, error) {
+	if s.AccessKey != "" {
+		signRequest(req, s.AccessKey, s.SecretKey, s.region(), time.Now())
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// get envoie une requête GET à l'API S3-like
+func (s *S3Service) get(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.do(req)
+}
+
+// objectURL construit l'URL d'un objet. Chaque segment de la clé est encodé et les "/" sont
+// conservés : une clé comme "album/2024/été 1.jpg" désigne un objet imbriqué.
+func (s *S3Service) objectURL(objectPath string) string {
+	segments := strings.Split(objectPath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return s.APIURL + "/" + strings.Join(segments, "/")
+}
+
+// xmlText échappe une valeur insérée dans un corps XML (clé contenant "&" ou "<" par exemple)
+func xmlText(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+func (s *S3Service) region() string {
+	if s.Region == "" {
+		return "us-east-1"
+	}
+	return s.Region
+}
+
+// PresignGetURL génère un lien de téléchargement direct vers un objet, valable pendant expires
+func (s *S3Service) PresignGetURL(objectPath string, expires time.Duration) (string, error) {
+	if s.AccessKey == "" || s.SecretKey == "" {
+		return "", fmt.Errorf("les URL présignées nécessitent S3_ACCESS_KEY et S3_SECRET_KEY")
+	}
+
+	baseURL := s.PublicURL
+	if baseURL == "" {
+		baseURL = s.APIURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("URL publique S3 invalide : %v", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + objectPath
+
+	return presignURL("GET", u, s.AccessKey, s.SecretKey, s.region(), time.Now(), expires), nil
+}
+
+// PresignPostPolicy prépare un upload direct depuis le navigateur (formulaire POST multipart/form-data) :
+// elle renvoie l'URL du formulaire et ses champs, à envoyer avant le champ file. La politique signée
+// limite les clés au préfixe keyPrefix et la taille du fichier à maxSize octets, pendant expires.
+func (s *S3Service) PresignPostPolicy(bucketName, keyPrefix string, maxSize int64, expires time.Duration) (string, map[string]string, error) {
+	if s.AccessKey == "" || s.SecretKey == "" {
+		return "", nil, fmt.Errorf("les formulaires d'upload signés nécessitent S3_ACCESS_KEY et S3_SECRET_KEY")
+	}
+	baseURL := s.PublicURL
+	if baseURL == "" {
+		baseURL = s.APIURL
+	}
+
+	now := time.Now()
+	credential := s.AccessKey + "/" + now.UTC().Format("20060102") + "/" + s.region() + "/s3/aws4_request"
+	date := now.UTC().Format(amzDateFormat)
+	document, err := json.Marshal(map[string]interface{}{
+		"expiration": now.Add(expires).UTC().Format(time.RFC3339),
+		"conditions": []interface{}{
+			map[string]string{"bucket": bucketName},
+			[]interface{}{"starts-with", "$key", keyPrefix},
+			[]interface{}{"starts-with", "$Content-Type", ""},
+			[]interface{}{"content-length-range", 1, maxSize},
+			map[string]string{"x-amz-algorithm": sigV4Algorithm},
+			map[string]string{"x-amz-credential": credential},
+			map[string]string{"x-amz-date": date},
+		},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	policy := base64.StdEncoding.EncodeToString(document)
+
+	fields := map[string]string{
+		"key":              keyPrefix + "${filename}",
+		"policy":           policy,
+		"x-amz-algorithm":  sigV4Algorithm,
+		"x-amz-credential": credential,
+		"x-amz-date":       date,
+		"x-amz-signature":  signPostPolicy(policy, s.SecretKey, s.region(), now),
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + bucketName, fields, nil
+}
+
 // CreateFolder crée un dossier dans l'API S3-like
 func (s *S3Service) CreateBucket(folderPath string) error {
 	// Ajouter un "/" pour simuler un dossier
@@ -46,7 +161,7 @@ func (s *S3Service) CreateBucket(folderPath string) error {
 
 	req.Header.Set("Content-Type", "application/octet-stream") // Type MIME pour un objet vide
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %v", err)
 	}
@@ -59,13 +174,38 @@ func (s *S3Service) CreateBucket(folderPath string) error {
 	return nil
 }
 
+// PutBucketEncryption active le chiffrement par défaut (SSE-S3) d'un bucket : les objets
+// téléversés ensuite sont chiffrés sur le disque du serveur S3-like
+func (s *S3Service) PutBucketEncryption(bucketName string) error {
+	url := fmt.Sprintf("%s/%s/?encryption", s.APIURL, bucketName)
+	payload := `<ServerSideEncryptionConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Rule><ApplyServerSideEncryptionByDefault><SSEAlgorithm>AES256</SSEAlgorithm></ApplyServerSideEncryptionByDefault></Rule></ServerSideEncryptionConfiguration>`
+
+	req, err := http.NewRequest("PUT", url, strings.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/xml")
+
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to enable bucket encryption, status: %s", resp.Status)
+	}
+
+	return nil
+}
+
 // ListBuckets récupère la liste des buckets depuis l'API S3-like
 func (s *S3Service) ListBuckets() ([]Bucket, error) {
 	// Construire l'URL pour lister les buckets
 	url := fmt.Sprintf("%s/", s.APIURL)
 
 	// Envoyer une requête HTTP GET
-	resp, err := http.Get(url)
+	resp, err := s.get(url)
 	if err != nil {
 		return nil, fmt.Errorf("erreur lors de l'appel à l'API S3-like : %v", err)
 	}
@@ -96,7 +236,7 @@ func (s *S3Service) DeleteBucket(bucketName string) error {
 		return fmt.Errorf("échec de la création de la requête de suppression : %v", err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return fmt.Errorf("échec de la suppression du bucket : %v", err)
 	}
@@ -112,7 +252,7 @@ func (s *S3Service) DeleteBucket(bucketName string) error {
 
 func (s *S3Service) UploadFile(objectPath string, file io.Reader, fileSize int64) error {
 	// Construire l'URL pour téléverser l'objet
-	url := fmt.Sprintf("%s/%s", s.APIURL, objectPath)
+	url := s.objectURL(objectPath)
 
 	// Créer une requête PUT pour téléverser le fichier
 	req, err := http.NewRequest("PUT", url, file)
@@ -120,12 +260,16 @@ func (s *S3Service) UploadFile(objectPath string, file io.Reader, fileSize int64
 		return fmt.Errorf("échec de la création de la requête : %v", err)
 	}
 
-	// Ajouter les en-têtes requis
-	req.Header.Set("Content-Type", "application/octet-stream")
+	// Ajouter les en-têtes requis. Le Content-Type est conservé par l'API S3 et renvoyé au téléchargement.
+	contentType := mime.TypeByExtension(filepath.Ext(objectPath))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprintf("%d", fileSize))
 
 	// Envoyer la requête
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return fmt.Errorf("échec de l'upload : %v", err)
 	}
@@ -152,7 +296,7 @@ func (s *S3Service) MoveObject(sourceBucket, sourceKey, targetBucket string) err
 		<TargetBucket>%s</TargetBucket>
 	</Move>
 
-	`, sourceKey, targetBucket)
+	`, xmlText(sourceKey), xmlText(targetBucket))
 
 	// Créer la requête HTTP POST
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(payload)))
@@ -162,7 +306,7 @@ func (s *S3Service) MoveObject(sourceBucket, sourceKey, targetBucket string) err
 	req.Header.Set("Content-Type", "application/xml")
 
 	// Envoyer la requête
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return fmt.Errorf("échec de la requête de déplacement : %v", err)
 	}
@@ -179,10 +323,10 @@ func (s *S3Service) MoveObject(sourceBucket, sourceKey, targetBucket string) err
 
 func (s *S3Service) DownloadFile(path string, w io.Writer) error {
 	// Construire l'URL pour télécharger le fichier
-	url := fmt.Sprintf("%s/%s", s.APIURL, path)
+	url := s.objectURL(path)
 
 	// Envoyer une requête HTTP GET
-	resp, err := http.Get(url)
+	resp, err := s.get(url)
 	if err != nil {
 		return fmt.Errorf("échec de la requête de téléchargement : %v", err)
 	}
@@ -217,7 +361,7 @@ func (s *S3Service) DeleteObject(bucketName, objectName string) error {
             <Key>%s</Key>
         </Object>
     </Delete>
-    `, objectName)
+    `, xmlText(objectName))
 	log.Printf("Corps XML généré : %s", payload)
 
 	// Créer une requête HTTP POST
@@ -231,7 +375,7 @@ func (s *S3Service) DeleteObject(bucketName, objectName string) error {
 	log.Printf("Headers envoyés : %v", req.Header)
 
 	// Envoyer la requête HTTP
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return fmt.Errorf("échec de la requête de suppression : %v", err)
 	}
@@ -246,6 +390,17 @@ func (s *S3Service) DeleteObject(bucketName, objectName string) error {
 		return fmt.Errorf("échec de la suppression de l'objet, statut : %s", resp.Status)
 	}
 
+	// Une suppression par lot répond 200 même si une clé a échoué : l'échec est décrit dans un élément Error
+	var result struct {
+		Errors []struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		} `xml:"Error"`
+	}
+	if err := xml.Unmarshal(responseBody, &result); err == nil && len(result.Errors) > 0 {
+		return fmt.Errorf("échec de la suppression de l'objet : %s (%s)", result.Errors[0].Code, result.Errors[0].Message)
+	}
+
 	// Journaliser la réussite
 	log.Printf("Objet supprimé avec succès : %s/%s", bucketName, objectName)
 	return nil
@@ -254,7 +409,7 @@ func (s *S3Service) DeleteObject(bucketName, objectName string) error {
 func (s *S3Service) GetFilesInAlbum(bucketName string) ([]string, error) {
 	url := fmt.Sprintf("%s/%s/", s.APIURL, bucketName)
 
-	resp, err := http.Get(url)
+	resp, err := s.get(url)
 	if err != nil {
 		return nil, fmt.Errorf("échec de la récupération des fichiers pour l'album %s : %v", bucketName, err)
 	}
@@ -285,13 +440,13 @@ func (s *S3Service) GetFilesInAlbum(bucketName string) ([]string, error) {
 }
 
 func (s *S3Service) DownloadTempFile(bucketName, objectName string) (string, error) {
-	localPath := fmt.Sprintf("/tmp/%s", objectName)
-
-	file, err := os.Create(localPath)
+	// La clé peut contenir des "/" : seul son dernier segment est repris dans le nom du fichier
+	file, err := os.CreateTemp("", "*-"+filepath.Base(objectName))
 	if err != nil {
 		return "", fmt.Errorf("échec de la création du fichier temporaire: %v", err)
 	}
 	defer file.Close()
+	localPath := file.Name()
 
 	filePath := fmt.Sprintf("%s/%s", bucketName, objectName)
 	err = s.DownloadFile(filePath, file)
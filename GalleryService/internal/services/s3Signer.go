@@ -0,0 +1,150 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature AWS V4 des requêtes envoyées à l'API S3-like
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	amzDateFormat   = "20060102T150405Z"
+)
+
+// sigV4Encode encode une chaîne selon les règles SigV4 (seuls A-Z a-z 0-9 - _ . ~ sont conservés)
+func sigV4Encode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && keepSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func sigV4Query(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, sigV4Encode(key, false)+"="+sigV4Encode(value, false))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// sigV4Signature calcule la signature d'une requête canonique
+func sigV4Signature(secretKey, region string, t time.Time, canonicalRequest string) (string, string) {
+	date := t.UTC().Format("20060102")
+	scope := date + "/" + region + "/s3/aws4_request"
+
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{sigV4Algorithm, t.UTC().Format(amzDateFormat), scope, hex.EncodeToString(hash[:])}, "\n")
+
+	return hex.EncodeToString(hmacSHA256(sigV4Key(secretKey, region, t), stringToSign)), scope
+}
+
+// sigV4Key dérive la clé de signature du jour à partir de la secret key
+func sigV4Key(secretKey, region string, t time.Time) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), t.UTC().Format("20060102"))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+// signPostPolicy signe la politique encodée en base64 d'un formulaire d'upload POST
+func signPostPolicy(policy, secretKey, region string, t time.Time) string {
+	return hex.EncodeToString(hmacSHA256(sigV4Key(secretKey, region, t), policy))
+}
+
+// signRequest ajoute l'en-tête Authorization à une requête. Le payload n'est pas signé,
+// ce qui permet d'envoyer les fichiers en streaming.
+func signRequest(req *http.Request, accessKey, secretKey, region string, t time.Time) {
+	req.Header.Set("X-Amz-Date", t.UTC().Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	signedHeaders := []string{"host"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" {
+			signedHeaders = append(signedHeaders, lower)
+		}
+	}
+	sort.Strings(signedHeaders)
+
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Host
+		if name != "host" {
+			value = strings.Join(strings.Fields(req.Header.Get(name)), " ")
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigV4Encode(req.URL.Path, true),
+		sigV4Query(req.URL.Query()),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		unsignedPayload,
+	}, "\n")
+
+	signature, scope := sigV4Signature(secretKey, region, t, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, accessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+// presignURL génère une URL présignée, valable pendant expires, pour la méthode donnée
+func presignURL(method string, u *url.URL, accessKey, secretKey, region string, t time.Time, expires time.Duration) string {
+	date := t.UTC().Format("20060102")
+
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", accessKey+"/"+date+"/"+region+"/s3/aws4_request")
+	query.Set("X-Amz-Date", t.UTC().Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		sigV4Encode(u.Path, true),
+		sigV4Query(query),
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+
+	signature, _ := sigV4Signature(secretKey, region, t, canonicalRequest)
+
+	signed := *u
+	signed.RawQuery = sigV4Query(query) + "&X-Amz-Signature=" + signature
+	return signed.String()
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPutBucketEncryption(t *testing.T) {
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.RequestURI(), string(data)
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	s3 := NewS3Service(server.URL)
+	s3.AccessKey, s3.SecretKey = testAccessKey, testSecretKey
+	if err := s3.PutBucketEncryption("private-album-1"); err != nil {
+		t.Fatalf("PutBucketEncryption() = %v", err)
+	}
+	if method != "PUT" || path != "/private-album-1/?encryption" || !strings.Contains(body, "<SSEAlgorithm>AES256</SSEAlgorithm>") {
+		t.Errorf("unexpected request %s %s %s", method, path, body)
+	}
+
+	// Le serveur sans clé maître refuse le chiffrement par défaut
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+	})
+	if err := s3.PutBucketEncryption("private-album-1"); err == nil {
+		t.Errorf("expected an error when the server does not support encryption")
+	}
+}
+
+func TestPresignPostPolicy(t *testing.T) {
+	s3 := NewS3Service("http://my-s3-clone:9090")
+	s3.AccessKey, s3.SecretKey = testAccessKey, testSecretKey
+	url, fields, err := s3.PresignPostPolicy("main-album-1", MediaUploadPrefix, MaxMediaUploadSize, DefaultMediaURLExpiry)
+	if err != nil {
+		t.Fatalf("PresignPostPolicy() = %v", err)
+	}
+	if url != "http://my-s3-clone:9090/main-album-1" {
+		t.Errorf("url = %s", url)
+	}
+	if fields["key"] != "uploads/${filename}" {
+		t.Errorf("key = %s", fields["key"])
+	}
+
+	// La politique limite le bucket, le préfixe des clés et la taille du fichier
+	document, err := base64.StdEncoding.DecodeString(fields["policy"])
+	if err != nil {
+		t.Fatalf("policy is not base64: %v", err)
+	}
+	var policy struct {
+		Expiration string            `json:"expiration"`
+		Conditions []json.RawMessage `json:"conditions"`
+	}
+	if err := json.Unmarshal(document, &policy); err != nil {
+		t.Fatalf("policy is not JSON: %v", err)
+	}
+	conditions := make([]string, len(policy.Conditions))
+	for i, c := range policy.Conditions {
+		conditions[i] = string(c)
+	}
+	joined := strings.Join(conditions, ",")
+	for _, want := range []string{
+		`{"bucket":"main-album-1"}`,
+		`["starts-with","$key","uploads/"]`,
+		`["content-length-range",1,10485760]`,
+		`{"x-amz-credential":"` + fields["x-amz-credential"] + `"}`,
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("conditions %s lack %s", joined, want)
+		}
+	}
+
+	signedAt, err := time.Parse(amzDateFormat, fields["x-amz-date"])
+	if err != nil {
+		t.Fatalf("x-amz-date = %s: %v", fields["x-amz-date"], err)
+	}
+	if want := signPostPolicy(fields["policy"], testSecretKey, testRegion, signedAt); fields["x-amz-signature"] != want {
+		t.Errorf("x-amz-signature = %s, want %s", fields["x-amz-signature"], want)
+	}
+
+	// Sans identifiants, le formulaire ne peut pas être signé
+	if _, _, err := NewS3Service("http://my-s3-clone:9090").PresignPostPolicy("main-album-1", MediaUploadPrefix, MaxMediaUploadSize, DefaultMediaURLExpiry); err == nil {
+		t.Errorf("expected an error without credentials")
+	}
+}
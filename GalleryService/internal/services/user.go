@@ -4,6 +4,7 @@ import (
 	"GalleryService/internal/db"
 	"GalleryService/internal/models"
 	"fmt"
+	"log"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -59,6 +60,10 @@ func (s *UserService) CreateUser(email string, username string) error {
 		tx.Rollback()
 		return fmt.Errorf("échec de la création du bucket privé : %v", err)
 	}
+	// Sans clé maître côté serveur, le chiffrement n'est pas disponible : l'album reste utilisable
+	if err := s.S3Service.PutBucketEncryption(privateBucket); err != nil {
+		log.Printf("Chiffrement par défaut non activé pour le bucket %s : %v", privateBucket, err)
+	}
 	privateAlbum.ExistsInS3 = true
 	if err := tx.Save(&privateAlbum).Error; err != nil {
 		tx.Rollback()
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Taille maximale acceptée pour un chunk, pour ne pas charger un flux malveillant en mémoire
+const maxChunkSize = 16 << 20
+
+// ChunkedReader décode un payload aws-chunked (STREAMING-AWS4-HMAC-SHA256-PAYLOAD)
+// et vérifie la signature de chaque chunk lorsque la requête a été authentifiée
+type ChunkedReader struct {
+	reader            *bufio.Reader
+	signing           *SigningContext
+	previousSignature string
+	chunk             []byte
+	err               error
+	totalBytes        int64
+}
+
+// NewChunkedReader décode body. Si signing est nil (authentification désactivée),
+// les signatures de chunk sont ignorées.
+func NewChunkedReader(body io.Reader, signing *SigningContext) *ChunkedReader {
+	cr := &ChunkedReader{
+		reader:  bufio.NewReader(body),
+		signing: signing,
+	}
+	if signing != nil {
+		cr.previousSignature = signing.Signature
+	}
+	return cr
+}
+
+func (cr *ChunkedReader) Read(p []byte) (int, error) {
+	for len(cr.chunk) == 0 {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+		cr.err = cr.readChunk()
+	}
+
+	n := copy(p, cr.chunk)
+	cr.chunk = cr.chunk[n:]
+	return n, nil
+}
+
+// readChunk lit un chunk "taille-hex;chunk-signature=sig\r\n<données>\r\n"
+func (cr *ChunkedReader) readChunk() error {
+	line, err := cr.reader.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: unexpected end of stream", ErrMalformedChunk)
+		}
+		return fmt.Errorf("error reading chunk size: %w", err)
+	}
+
+	sizeHex, extension, _ := strings.Cut(strings.TrimSpace(line), ";")
+	size, err := strconv.ParseInt(sizeHex, 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return fmt.Errorf("%w: invalid chunk size %q", ErrMalformedChunk, sizeHex)
+	}
+	signature := strings.TrimPrefix(extension, "chunk-signature=")
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(cr.reader, data); err != nil {
+		return fmt.Errorf("%w: error reading chunk data: %v", ErrMalformedChunk, err)
+	}
+	if crlf, err := cr.reader.Peek(2); err == nil && string(crlf) == "\r\n" {
+		cr.reader.Discard(2)
+	} else if size > 0 {
+		return fmt.Errorf("%w: missing CRLF after chunk data", ErrMalformedChunk)
+	}
+
+	if cr.signing != nil {
+		if err := cr.verify(signature, data); err != nil {
+			return err
+		}
+	}
+
+	if size == 0 {
+		log.Printf("Completed processing chunked stream, total bytes processed: %d", cr.totalBytes)
+		return io.EOF
+	}
+
+	cr.totalBytes += size
+	cr.chunk = data
+	return nil
+}
+
+func (cr *ChunkedReader) verify(signature string, data []byte) error {
+	provided, err := hex.DecodeString(signature)
+	if err != nil || signature == "" {
+		log.Printf("Missing or invalid chunk signature after %d bytes", cr.totalBytes)
+		return ErrSignatureDoesNotMatch
+	}
+
+	expected := hmacSHA256(cr.signing.signingKey, chunkStringToSign(cr.signing.Date, cr.signing.scope, cr.previousSignature, data))
+	if !hmac.Equal(expected, provided) {
+		log.Printf("Chunk signature mismatch after %d bytes", cr.totalBytes)
+		return ErrSignatureDoesNotMatch
+	}
+
+	cr.previousSignature = signature
+	return nil
+}
@@ -0,0 +1,14 @@
+package auth
+
+// CredentialStore fournit la secret key associée à une access key
+type CredentialStore interface {
+	SecretKey(accessKey string) (string, bool)
+}
+
+// StaticCredentials est un CredentialStore en mémoire, construit depuis la configuration
+type StaticCredentials map[string]string
+
+func (c StaticCredentials) SecretKey(accessKey string) (string, bool) {
+	secretKey, ok := c[accessKey]
+	return secretKey, ok
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"errors"
+
+	"my-s3-clone/s3errors"
+)
+
+// Erreurs de vérification des signatures
+var (
+	ErrMissingAuth               = errors.New("request is not signed")
+	ErrUnsupportedSignature      = errors.New("unsupported signature version")
+	ErrMalformedAuthorization    = errors.New("malformed authorization header")
+	ErrMalformedPresignedRequest = errors.New("malformed presigned request")
+	ErrInvalidAccessKeyID        = errors.New("unknown access key")
+	ErrSignatureDoesNotMatch     = errors.New("signature does not match")
+	ErrRequestTimeTooSkewed      = errors.New("request time too skewed")
+	ErrExpiredPresignedRequest   = errors.New("presigned request has expired")
+	ErrMissingContentSha256      = errors.New("missing x-amz-content-sha256 header")
+	ErrInvalidContentSha256      = errors.New("invalid x-amz-content-sha256 header")
+	ErrMalformedChunk            = errors.New("malformed aws-chunked payload")
+	ErrMalformedPostRequest      = errors.New("malformed POST form")
+	ErrInvalidPostPolicy         = errors.New("invalid POST policy")
+	ErrPostPolicyConditionFailed = errors.New("Invalid according to Policy")
+)
+
+// ToAPIError traduit une erreur d'authentification en erreur S3
+func ToAPIError(err error) (s3errors.APIError, bool) {
+	switch {
+	case errors.Is(err, ErrMissingAuth):
+		return s3errors.ErrAccessDenied, true
+	case errors.Is(err, ErrUnsupportedSignature):
+		return s3errors.ErrInvalidRequest.WithMessage("The authorization mechanism you have provided is not supported. Please use AWS4-HMAC-SHA256."), true
+	case errors.Is(err, ErrMalformedAuthorization):
+		return s3errors.ErrAuthorizationHeaderMalformed, true
+	case errors.Is(err, ErrMalformedPresignedRequest):
+		return s3errors.ErrAuthorizationQueryParametersError, true
+	case errors.Is(err, ErrInvalidAccessKeyID):
+		return s3errors.ErrInvalidAccessKeyId, true
+	case errors.Is(err, ErrSignatureDoesNotMatch):
+		return s3errors.ErrSignatureDoesNotMatch, true
+	case errors.Is(err, ErrRequestTimeTooSkewed):
+		return s3errors.ErrRequestTimeTooSkewed, true
+	case errors.Is(err, ErrExpiredPresignedRequest):
+		return s3errors.ErrAccessDenied.WithMessage("Request has expired"), true
+	case errors.Is(err, ErrMissingContentSha256):
+		return s3errors.ErrInvalidRequest.WithMessage("Missing required header for this request: x-amz-content-sha256"), true
+	case errors.Is(err, ErrInvalidContentSha256):
+		return s3errors.ErrInvalidArgument.WithMessage("x-amz-content-sha256 must be UNSIGNED-PAYLOAD, STREAMING-AWS4-HMAC-SHA256-PAYLOAD or a valid sha256 value."), true
+	case errors.Is(err, ErrMalformedChunk):
+		return s3errors.ErrInvalidRequest.WithMessage("The aws-chunked payload is malformed."), true
+	case errors.Is(err, ErrMalformedPostRequest):
+		return s3errors.ErrInvalidArgument.WithMessage(err.Error()), true
+	case errors.Is(err, ErrInvalidPostPolicy):
+		return s3errors.ErrInvalidPolicyDocument.WithMessage(err.Error()), true
+	case errors.Is(err, ErrPostPolicyConditionFailed):
+		return s3errors.ErrAccessDenied.WithMessage(err.Error()), true
+	}
+	return s3errors.APIError{}, false
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Champs d'un formulaire POST qui n'ont pas à être couverts par une condition de la politique.
+// Le champ bucket, qui porte le bucket de l'URL, n'en fait pas partie : une politique doit fixer
+// son bucket pour ne pas servir à écrire dans un autre.
+var postPolicyExemptFields = map[string]bool{
+	"policy":          true,
+	"x-amz-signature": true,
+	"file":            true,
+}
+
+// PostPolicy est la politique d'un upload par formulaire POST : un document JSON encodé en base64
+// qui fixe sa date d'expiration et les conditions que doivent respecter les champs du formulaire
+type PostPolicy struct {
+	Expiration time.Time
+	Conditions []PostPolicyCondition
+	// Taille minimale et maximale du fichier (condition content-length-range), si elle est fixée
+	LengthRange *[2]int64
+}
+
+// PostPolicyCondition impose qu'un champ du formulaire soit égal à Value (Operator "eq") ou commence
+// par Value ("starts-with"). Field est le nom du champ en minuscules, sans le "$" initial.
+type PostPolicyCondition struct {
+	Operator string
+	Field    string
+	Value    string
+}
+
+// ParsePostPolicy décode une politique encodée en base64
+func ParsePostPolicy(encoded string) (*PostPolicy, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the policy is not valid base64", ErrInvalidPostPolicy)
+	}
+	var document struct {
+		Expiration string            `json:"expiration"`
+		Conditions []json.RawMessage `json:"conditions"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPostPolicy, err)
+	}
+
+	expiration, err := time.Parse(time.RFC3339, document.Expiration)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid expiration %q", ErrInvalidPostPolicy, document.Expiration)
+	}
+	policy := &PostPolicy{Expiration: expiration}
+	for _, raw := range document.Conditions {
+		if err := policy.addCondition(raw); err != nil {
+			return nil, fmt.Errorf("%w: %v in condition %s", ErrInvalidPostPolicy, err, raw)
+		}
+	}
+	return policy, nil
+}
+
+// addCondition décode une condition : {"champ": "valeur"}, ["eq" | "starts-with", "$champ", "valeur"]
+// ou ["content-length-range", min, max]
+func (p *PostPolicy) addCondition(raw json.RawMessage) error {
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		var fields map[string]string
+		if err := json.Unmarshal(raw, &fields); err != nil || len(fields) != 1 {
+			return fmt.Errorf("expected a single field")
+		}
+		for field, value := range fields {
+			p.Conditions = append(p.Conditions, PostPolicyCondition{Operator: "eq", Field: strings.ToLower(strings.TrimPrefix(field, "$")), Value: value})
+		}
+		return nil
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(raw, &elements); err != nil || len(elements) != 3 {
+		return fmt.Errorf("expected an object or an array of 3 elements")
+	}
+	var operator string
+	if err := json.Unmarshal(elements[0], &operator); err != nil {
+		return fmt.Errorf("invalid operator")
+	}
+
+	switch operator = strings.ToLower(operator); operator {
+	case "content-length-range":
+		var min, max int64
+		if json.Unmarshal(elements[1], &min) != nil || json.Unmarshal(elements[2], &max) != nil || min < 0 || max < min {
+			return fmt.Errorf("invalid content-length-range")
+		}
+		p.LengthRange = &[2]int64{min, max}
+	case "eq", "starts-with":
+		var field, value string
+		if json.Unmarshal(elements[1], &field) != nil || json.Unmarshal(elements[2], &value) != nil || !strings.HasPrefix(field, "$") {
+			return fmt.Errorf("invalid field")
+		}
+		p.Conditions = append(p.Conditions, PostPolicyCondition{Operator: operator, Field: strings.ToLower(field[1:]), Value: value})
+	default:
+		return fmt.Errorf("unknown operator %q", operator)
+	}
+	return nil
+}
+
+// Check vérifie que la politique n'a pas expiré à l'heure now, que les champs du formulaire (indexés
+// par leur nom en minuscules) respectent ses conditions, et qu'aucun champ n'échappe aux conditions
+func (p *PostPolicy) Check(form map[string]string, now time.Time) error {
+	if now.After(p.Expiration) {
+		return fmt.Errorf("%w: Policy expired.", ErrPostPolicyConditionFailed)
+	}
+
+	covered := make(map[string]bool)
+	for _, condition := range p.Conditions {
+		covered[condition.Field] = true
+		value := form[condition.Field]
+		matches := value == condition.Value
+		if condition.Operator == "starts-with" {
+			// Content-Type peut lister plusieurs types, qui doivent tous respecter la condition
+			matches = true
+			values := []string{value}
+			if condition.Field == "content-type" {
+				values = strings.Split(value, ",")
+			}
+			for _, v := range values {
+				matches = matches && strings.HasPrefix(strings.TrimSpace(v), condition.Value)
+			}
+		}
+		if !matches {
+			return fmt.Errorf("%w: Policy Condition failed: [\"%s\", \"$%s\", \"%s\"]", ErrPostPolicyConditionFailed, condition.Operator, condition.Field, condition.Value)
+		}
+	}
+
+	for field := range form {
+		if !covered[field] && !postPolicyExemptFields[field] && !strings.HasPrefix(field, "x-ignore-") {
+			return fmt.Errorf("%w: Extra input fields: %s", ErrPostPolicyConditionFailed, field)
+		}
+	}
+	return nil
+}
+
+// VerifyPostPolicy contrôle la signature SigV4 d'un formulaire POST : elle porte sur la politique
+// encodée en base64 (champ policy), signée avec la clé du jour de x-amz-date
+func (v *Verifier) VerifyPostPolicy(form map[string]string) (*SigningContext, error) {
+	switch form["x-amz-algorithm"] {
+	case SignV4Algorithm:
+	case "":
+		return nil, fmt.Errorf("%w: a signed POST must contain the field x-amz-algorithm", ErrMalformedPostRequest)
+	default:
+		return nil, ErrUnsupportedSignature
+	}
+	cred, ok := parseCredential(form["x-amz-credential"])
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid x-amz-credential", ErrMalformedPostRequest)
+	}
+	date, err := time.Parse(iso8601Format, form["x-amz-date"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid x-amz-date", ErrMalformedPostRequest)
+	}
+	signature := form["x-amz-signature"]
+	if signature == "" || form["policy"] == "" {
+		return nil, fmt.Errorf("%w: a signed POST must contain the fields policy and x-amz-signature", ErrMalformedPostRequest)
+	}
+
+	key, err := v.credentialKey(cred, date)
+	if err != nil {
+		return nil, err
+	}
+	expected := hmacSHA256(key, form["policy"])
+	provided, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, provided) {
+		return nil, ErrSignatureDoesNotMatch
+	}
+
+	return &SigningContext{
+		AccessKey:  cred.accessKey,
+		Region:     cred.region,
+		Date:       date,
+		Signature:  signature,
+		scope:      credentialScope(date, cred.region),
+		signingKey: key,
+	}, nil
+}
+
+// SignPostPolicy calcule les champs de signature d'un formulaire POST pour la politique encodée,
+// comme le ferait un SDK AWS : x-amz-algorithm, x-amz-credential, x-amz-date et x-amz-signature.
+// Les trois premiers doivent aussi figurer dans les conditions de la politique.
+func SignPostPolicy(policy, accessKey, secretKey, region string, t time.Time) map[string]string {
+	return map[string]string{
+		"x-amz-algorithm":  SignV4Algorithm,
+		"x-amz-credential": accessKey + "/" + credentialScope(t, region),
+		"x-amz-date":       t.UTC().Format(iso8601Format),
+		"x-amz-signature":  hex.EncodeToString(hmacSHA256(signingKey(secretKey, t, region), policy)),
+	}
+}
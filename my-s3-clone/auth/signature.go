@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignV4Algorithm  = "AWS4-HMAC-SHA256"
+	StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	UnsignedPayload  = "UNSIGNED-PAYLOAD"
+	EmptySHA256      = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	iso8601Format = "20060102T150405Z"
+	yyyymmdd      = "20060102"
+	serviceName   = "s3"
+	scopeSuffix   = "aws4_request"
+)
+
+// uriEncode encode une chaîne selon les règles SigV4 : seuls les caractères non réservés
+// (A-Z, a-z, 0-9, '-', '_', '.', '~') sont conservés, ainsi que '/' si demandé
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery trie les paramètres par nom puis par valeur, en excluant X-Amz-Signature
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if key == "X-Amz-Signature" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, false)+"="+uriEncode(value, false))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// headerValue renvoie la valeur d'un en-tête signé, y compris ceux que net/http sort de r.Header
+func headerValue(r *http.Request, name string) string {
+	switch name {
+	case "host":
+		return r.Host
+	case "content-length":
+		if value := r.Header.Get("Content-Length"); value != "" {
+			return value
+		}
+		if r.ContentLength >= 0 {
+			return strconv.FormatInt(r.ContentLength, 10)
+		}
+		return ""
+	case "transfer-encoding":
+		if len(r.TransferEncoding) > 0 {
+			return strings.Join(r.TransferEncoding, ",")
+		}
+	}
+
+	values := r.Header.Values(name)
+	trimmed := make([]string, len(values))
+	for i, value := range values {
+		// Les espaces consécutifs sont ramenés à un seul espace
+		trimmed[i] = strings.Join(strings.Fields(value), " ")
+	}
+	return strings.Join(trimmed, ",")
+}
+
+func canonicalHeaders(r *http.Request, signedHeaders []string) string {
+	var b strings.Builder
+	for _, name := range signedHeaders {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(headerValue(r, name))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// canonicalRequest construit la requête canonique définie par SigV4
+func canonicalRequest(r *http.Request, query url.Values, signedHeaders []string, payloadHash string) string {
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(path, true),
+		canonicalQuery(query),
+		canonicalHeaders(r, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+func credentialScope(t time.Time, region string) string {
+	return strings.Join([]string{t.UTC().Format(yyyymmdd), region, serviceName, scopeSuffix}, "/")
+}
+
+func stringToSign(t time.Time, scope, canonical string) string {
+	hash := sha256.Sum256([]byte(canonical))
+	return strings.Join([]string{
+		SignV4Algorithm,
+		t.UTC().Format(iso8601Format),
+		scope,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// signingKey dérive la clé de signature du jour à partir de la secret key
+func signingKey(secretKey string, t time.Time, region string) []byte {
+	kDate := hmacSHA256([]byte("AWS4"+secretKey), t.UTC().Format(yyyymmdd))
+	kRegion := hmacSHA256(kDate, region)
+	kService := hmacSHA256(kRegion, serviceName)
+	return hmacSHA256(kService, scopeSuffix)
+}
+
+// defaultSignedHeaders choisit les en-têtes à signer côté client : host, content-type, content-md5 et x-amz-*
+func defaultSignedHeaders(r *http.Request) []string {
+	headers := []string{"host"}
+	for name := range r.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "content-md5" {
+			headers = append(headers, lower)
+		}
+	}
+	sort.Strings(headers)
+	return headers
+}
+
+// SignRequest signe une requête avec l'en-tête Authorization, comme le ferait un SDK AWS.
+// Le hash du payload est lu dans X-Amz-Content-Sha256 (UNSIGNED-PAYLOAD par défaut).
+func SignRequest(r *http.Request, accessKey, secretKey, region string, t time.Time) {
+	if r.Header.Get("X-Amz-Content-Sha256") == "" {
+		r.Header.Set("X-Amz-Content-Sha256", UnsignedPayload)
+	}
+	r.Header.Set("X-Amz-Date", t.UTC().Format(iso8601Format))
+
+	signedHeaders := defaultSignedHeaders(r)
+	scope := credentialScope(t, region)
+	canonical := canonicalRequest(r, r.URL.Query(), signedHeaders, r.Header.Get("X-Amz-Content-Sha256"))
+	signature := hex.EncodeToString(hmacSHA256(signingKey(secretKey, t, region), stringToSign(t, scope, canonical)))
+
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		SignV4Algorithm, accessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+// PresignURL génère une URL présignée valable pendant expires pour la méthode donnée
+func PresignURL(method, rawURL, accessKey, secretKey, region string, t time.Time, expires time.Duration) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	scope := credentialScope(t, region)
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", SignV4Algorithm)
+	query.Set("X-Amz-Credential", accessKey+"/"+scope)
+	query.Set("X-Amz-Date", t.UTC().Format(iso8601Format))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	r := &http.Request{Method: method, URL: u, Host: u.Host, Header: http.Header{}}
+	canonical := canonicalRequest(r, query, []string{"host"}, UnsignedPayload)
+	signature := hex.EncodeToString(hmacSHA256(signingKey(secretKey, t, region), stringToSign(t, scope, canonical)))
+
+	query.Set("X-Amz-Signature", signature)
+	u.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + signature
+	return u.String(), nil
+}
+
+// chunkStringToSign construit la chaîne à signer d'un chunk d'un payload aws-chunked
+func chunkStringToSign(t time.Time, scope, previousSignature string, chunk []byte) string {
+	hash := sha256.Sum256(chunk)
+	return strings.Join([]string{
+		SignV4Algorithm + "-PAYLOAD",
+		t.UTC().Format(iso8601Format),
+		scope,
+		previousSignature,
+		EmptySHA256,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+}
+
+// ChunkSignature calcule la signature d'un chunk, chaînée à la signature précédente
+// (la signature de l'en-tête Authorization pour le premier chunk)
+func ChunkSignature(secretKey, region string, t time.Time, previousSignature string, chunk []byte) string {
+	key := signingKey(secretKey, t, region)
+	return hex.EncodeToString(hmacSHA256(key, chunkStringToSign(t, credentialScope(t, region), previousSignature, chunk)))
+}
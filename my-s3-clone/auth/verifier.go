@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Décalage d'horloge maximal toléré entre le client et le serveur
+	maxClockSkew = 15 * time.Minute
+	// Durée de validité maximale d'une URL présignée (7 jours, comme S3)
+	maxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// SigningContext décrit une requête dont la signature a été vérifiée
+type SigningContext struct {
+	AccessKey string
+	Region    string
+	Date      time.Time
+	Signature string
+
+	scope      string
+	signingKey []byte
+}
+
+// Verifier vérifie les signatures AWS Signature V4 des requêtes entrantes
+type Verifier struct {
+	Credentials CredentialStore
+	// Région attendue dans le scope des signatures, ignorée si vide
+	Region string
+	// Horloge utilisée pour les contrôles de date, remplaçable dans les tests
+	Now func() time.Time
+}
+
+func NewVerifier(credentials CredentialStore, region string) *Verifier {
+	return &Verifier{
+		Credentials: credentials,
+		Region:      region,
+		Now:         time.Now,
+	}
+}
+
+// Verify contrôle la signature d'une requête, qu'elle soit portée par l'en-tête Authorization
+// ou par les paramètres d'une URL présignée
+func (v *Verifier) Verify(r *http.Request) (*SigningContext, error) {
+	authorization := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(authorization, SignV4Algorithm+" "):
+		return v.verifyHeader(r, authorization)
+	case authorization != "":
+		return nil, ErrUnsupportedSignature
+	case r.URL.Query().Get("X-Amz-Algorithm") != "":
+		return v.verifyPresigned(r)
+	}
+	return nil, ErrMissingAuth
+}
+
+// credential représente la valeur Credential=AKID/20060102/region/s3/aws4_request
+type credential struct {
+	accessKey string
+	date      string
+	region    string
+}
+
+func parseCredential(value string) (credential, bool) {
+	parts := strings.Split(value, "/")
+	if len(parts) < 5 || parts[len(parts)-2] != serviceName || parts[len(parts)-1] != scopeSuffix {
+		return credential{}, false
+	}
+	n := len(parts)
+	return credential{
+		accessKey: strings.Join(parts[:n-4], "/"),
+		date:      parts[n-4],
+		region:    parts[n-3],
+	}, true
+}
+
+// parseSignedHeaders valide la liste des en-têtes signés, qui doit contenir host
+func parseSignedHeaders(value string) ([]string, bool) {
+	headers := strings.Split(value, ";")
+	hasHost := false
+	for _, header := range headers {
+		if header == "" || header != strings.ToLower(header) {
+			return nil, false
+		}
+		if header == "host" {
+			hasHost = true
+		}
+	}
+	return headers, hasHost
+}
+
+func (v *Verifier) verifyHeader(r *http.Request, authorization string) (*SigningContext, error) {
+	fields := make(map[string]string)
+	for _, field := range strings.Split(strings.TrimPrefix(authorization, SignV4Algorithm+" "), ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found {
+			return nil, ErrMalformedAuthorization
+		}
+		fields[key] = value
+	}
+
+	cred, ok := parseCredential(fields["Credential"])
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid Credential", ErrMalformedAuthorization)
+	}
+	signedHeaders, ok := parseSignedHeaders(fields["SignedHeaders"])
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid SignedHeaders", ErrMalformedAuthorization)
+	}
+	signature := fields["Signature"]
+	if signature == "" {
+		return nil, fmt.Errorf("%w: missing Signature", ErrMalformedAuthorization)
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return nil, ErrMissingContentSha256
+	}
+	if !validPayloadHash(payloadHash) {
+		return nil, ErrInvalidContentSha256
+	}
+
+	dateValue := r.Header.Get("X-Amz-Date")
+	if dateValue == "" {
+		dateValue = r.Header.Get("Date")
+	}
+	date, err := parseRequestDate(dateValue)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid request date", ErrMalformedAuthorization)
+	}
+	if skew := v.Now().Sub(date); skew > maxClockSkew || skew < -maxClockSkew {
+		return nil, ErrRequestTimeTooSkewed
+	}
+
+	return v.checkSignature(r, r.URL.Query(), cred, date, signedHeaders, payloadHash, signature)
+}
+
+func (v *Verifier) verifyPresigned(r *http.Request) (*SigningContext, error) {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != SignV4Algorithm {
+		return nil, ErrUnsupportedSignature
+	}
+
+	cred, ok := parseCredential(query.Get("X-Amz-Credential"))
+	if !ok {
+		return nil, ErrMalformedPresignedRequest
+	}
+	signedHeaders, ok := parseSignedHeaders(query.Get("X-Amz-SignedHeaders"))
+	if !ok {
+		return nil, ErrMalformedPresignedRequest
+	}
+	signature := query.Get("X-Amz-Signature")
+	if signature == "" {
+		return nil, ErrMalformedPresignedRequest
+	}
+	date, err := time.Parse(iso8601Format, query.Get("X-Amz-Date"))
+	if err != nil {
+		return nil, ErrMalformedPresignedRequest
+	}
+	expiresSeconds, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expiresSeconds < 1 || time.Duration(expiresSeconds)*time.Second > maxPresignExpiry {
+		return nil, fmt.Errorf("%w: X-Amz-Expires must be between 1 and 604800 seconds", ErrMalformedPresignedRequest)
+	}
+
+	now := v.Now()
+	if date.After(now.Add(maxClockSkew)) {
+		return nil, ErrRequestTimeTooSkewed
+	}
+	if now.After(date.Add(time.Duration(expiresSeconds) * time.Second)) {
+		return nil, ErrExpiredPresignedRequest
+	}
+
+	// Une URL présignée ne signe pas le payload, sauf si le client a fourni son hash
+	payloadHash := query.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = UnsignedPayload
+	}
+
+	return v.checkSignature(r, query, cred, date, signedHeaders, payloadHash, signature)
+}
+
+// checkSignature recalcule la signature attendue et la compare à celle fournie
+func (v *Verifier) checkSignature(r *http.Request, query url.Values, cred credential, date time.Time, signedHeaders []string, payloadHash, signature string) (*SigningContext, error) {
+	key, err := v.credentialKey(cred, date)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := credentialScope(date, cred.region)
+	canonical := canonicalRequest(r, query, signedHeaders, payloadHash)
+	expected := hmacSHA256(key, stringToSign(date, scope, canonical))
+
+	provided, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, provided) {
+		return nil, ErrSignatureDoesNotMatch
+	}
+
+	return &SigningContext{
+		AccessKey:  cred.accessKey,
+		Region:     cred.region,
+		Date:       date,
+		Signature:  signature,
+		scope:      scope,
+		signingKey: key,
+	}, nil
+}
+
+// credentialKey contrôle la date et la région du scope de la signature, et dérive la clé de
+// signature de l'access key
+func (v *Verifier) credentialKey(cred credential, date time.Time) ([]byte, error) {
+	if cred.date != date.UTC().Format(yyyymmdd) {
+		return nil, fmt.Errorf("%w: credential date does not match request date", ErrMalformedAuthorization)
+	}
+	if v.Region != "" && cred.region != v.Region {
+		return nil, fmt.Errorf("%w: the region '%s' is wrong; expecting '%s'", ErrMalformedAuthorization, cred.region, v.Region)
+	}
+
+	secretKey, ok := v.Credentials.SecretKey(cred.accessKey)
+	if !ok {
+		return nil, ErrInvalidAccessKeyID
+	}
+	return signingKey(secretKey, date, cred.region), nil
+}
+
+// parseRequestDate accepte le format ISO 8601 de X-Amz-Date ou le format HTTP de Date
+func parseRequestDate(value string) (time.Time, error) {
+	if t, err := time.Parse(iso8601Format, value); err == nil {
+		return t, nil
+	}
+	return http.ParseTime(value)
+}
+
+// validPayloadHash indique si x-amz-content-sha256 est une valeur reconnue : UNSIGNED-PAYLOAD,
+// un payload STREAMING-* ou une empreinte SHA-256 en hexadécimal
+func validPayloadHash(value string) bool {
+	if value == UnsignedPayload || strings.HasPrefix(value, "STREAMING-") {
+		return true
+	}
+	sum, err := hex.DecodeString(value)
+	return err == nil && len(sum) == sha256.Size
+}
+
+type contextKey struct{}
+
+// WithSigningContext attache le résultat de la vérification au contexte de la requête. Une requête
+// acceptée comme anonyme cesse de l'être si sa signature est vérifiée plus tard (formulaire POST).
+func WithSigningContext(ctx context.Context, sc *SigningContext) context.Context {
+	ctx = context.WithValue(ctx, anonymousKey{}, false)
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// SigningContextFromRequest renvoie le contexte de signature, ou nil si l'authentification est désactivée
+func SigningContextFromRequest(r *http.Request) *SigningContext {
+	sc, _ := r.Context().Value(contextKey{}).(*SigningContext)
+	return sc
+}
+
+type anonymousKey struct{}
+
+// WithAnonymous marque une requête non signée acceptée en accès anonyme : elle n'est autorisée
+// que si la politique ou l'ACL du bucket (ou de l'objet) l'ouvre au public
+func WithAnonymous(ctx context.Context) context.Context {
+	return context.WithValue(ctx, anonymousKey{}, true)
+}
+
+// IsAnonymous indique si la requête a été acceptée sans signature alors que l'authentification est active
+func IsAnonymous(r *http.Request) bool {
+	anonymous, _ := r.Context().Value(anonymousKey{}).(bool)
+	return anonymous
+}
@@ -0,0 +1,190 @@
+package checksum
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Erreurs de vérification du contenu, traduites en erreurs S3 par les handlers
+var (
+	ErrInvalidDigest         = errors.New("Content-MD5 is not a valid base64-encoded MD5 digest")
+	ErrInvalidChecksum       = errors.New("invalid checksum header")
+	ErrInvalidDecodedLength  = errors.New("invalid x-amz-decoded-content-length")
+	ErrBadDigest             = errors.New("the Content-MD5 you specified did not match what was received")
+	ErrContentSHA256Mismatch = errors.New("the provided x-amz-content-sha256 header does not match what was computed")
+	ErrChecksumMismatch      = errors.New("the checksum you specified did not match the calculated checksum")
+	ErrIncompleteBody        = errors.New("the request body does not have the declared length")
+)
+
+// MismatchError signale un contenu qui ne correspond pas à son en-tête x-amz-checksum-* ; elle
+// satisfait errors.Is(err, ErrChecksumMismatch)
+type MismatchError struct {
+	Algorithm string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("The %s you specified did not match the calculated checksum.", e.Algorithm)
+}
+
+func (e *MismatchError) Is(target error) bool {
+	return target == ErrChecksumMismatch
+}
+
+// Valeurs de x-amz-content-sha256 qui ne sont pas l'empreinte du corps
+const (
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	streamingPrefix = "STREAMING-"
+)
+
+// Algorithmes des en-têtes x-amz-checksum-<algorithme>
+var algorithms = []struct {
+	name string
+	new  func() hash.Hash
+}{
+	{"crc32", func() hash.Hash { return crc32.NewIEEE() }},
+	{"crc32c", func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) }},
+	{"sha1", sha1.New},
+	{"sha256", sha256.New},
+}
+
+// expectedSum est une empreinte annoncée par le client, comparée à celle du contenu reçu
+type expectedSum struct {
+	header   string
+	hash     hash.Hash
+	expected []byte
+	err      error
+}
+
+// Reader vérifie le contenu d'un upload pendant sa lecture : à la fin du flux, il renvoie une
+// erreur au lieu d'io.EOF si le contenu ne correspond pas aux en-têtes de la requête. Le stockage
+// n'écrit l'objet à sa place définitive qu'après avoir tout lu : un contenu refusé n'est jamais exposé.
+type Reader struct {
+	src  io.Reader
+	sums []*expectedSum
+	// Longueur annoncée du contenu décodé, -1 si elle n'est pas connue
+	length int64
+	read   int64
+	err    error
+}
+
+// NewReader enveloppe le contenu de la requête (déjà décodé si le payload est aws-chunked) et
+// prépare les vérifications demandées par ses en-têtes : Content-MD5, x-amz-content-sha256,
+// x-amz-decoded-content-length et x-amz-checksum-*
+func NewReader(src io.Reader, header http.Header) (*Reader, error) {
+	cr := &Reader{src: src, length: -1}
+
+	if value := header.Get("Content-MD5"); value != "" {
+		sum, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(sum) != md5.Size {
+			return nil, ErrInvalidDigest
+		}
+		cr.sums = append(cr.sums, &expectedSum{header: "Content-MD5", hash: md5.New(), expected: sum, err: ErrBadDigest})
+	}
+
+	switch value := header.Get("X-Amz-Content-Sha256"); {
+	case value == "" || value == unsignedPayload:
+	case strings.HasPrefix(value, streamingPrefix):
+		// Le contenu est signé chunk par chunk ; sa longueur décodée est annoncée à part
+		if decoded := header.Get("X-Amz-Decoded-Content-Length"); decoded != "" {
+			length, err := strconv.ParseInt(decoded, 10, 64)
+			if err != nil || length < 0 {
+				return nil, ErrInvalidDecodedLength
+			}
+			cr.length = length
+		}
+	default:
+		// Le format de l'en-tête est contrôlé avec la signature : sans authentification, une valeur
+		// qui n'est pas une empreinte SHA-256 est ignorée
+		if sum, err := hex.DecodeString(value); err == nil && len(sum) == sha256.Size {
+			cr.sums = append(cr.sums, &expectedSum{header: "X-Amz-Content-Sha256", hash: sha256.New(), expected: sum, err: ErrContentSHA256Mismatch})
+		}
+	}
+
+	for _, algorithm := range algorithms {
+		name := "X-Amz-Checksum-" + algorithm.name
+		value := header.Get(name)
+		if value == "" {
+			continue
+		}
+		h := algorithm.new()
+		sum, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(sum) != h.Size() {
+			return nil, fmt.Errorf("%w: %s is not a valid base64-encoded %s", ErrInvalidChecksum, name, strings.ToUpper(algorithm.name))
+		}
+		cr.sums = append(cr.sums, &expectedSum{
+			header:   name,
+			hash:     h,
+			expected: sum,
+			err:      &MismatchError{Algorithm: strings.ToUpper(algorithm.name)},
+		})
+	}
+	return cr, nil
+}
+
+func (cr *Reader) Read(p []byte) (int, error) {
+	if cr.err != nil {
+		return 0, cr.err
+	}
+
+	n, err := cr.src.Read(p)
+	for _, sum := range cr.sums {
+		sum.hash.Write(p[:n])
+	}
+	cr.read += int64(n)
+	if cr.length >= 0 && cr.read > cr.length {
+		cr.err = ErrIncompleteBody
+		return 0, cr.err
+	}
+
+	switch {
+	case err == io.EOF:
+		cr.err = cr.verify()
+		if cr.err == nil {
+			cr.err = io.EOF
+		}
+		return n, cr.err
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		// Corps interrompu avant la longueur annoncée par Content-Length
+		cr.err = ErrIncompleteBody
+		return n, cr.err
+	case err != nil:
+		cr.err = err
+	}
+	return n, err
+}
+
+// verify compare le contenu entièrement lu aux en-têtes de la requête
+func (cr *Reader) verify() error {
+	if cr.length >= 0 && cr.read != cr.length {
+		return ErrIncompleteBody
+	}
+	for _, sum := range cr.sums {
+		if !bytes.Equal(sum.hash.Sum(nil), sum.expected) {
+			return sum.err
+		}
+	}
+	return nil
+}
+
+// Checksums renvoie les en-têtes x-amz-checksum-* vérifiés, à renvoyer au client une fois l'objet enregistré
+func (cr *Reader) Checksums() map[string]string {
+	checksums := make(map[string]string)
+	for _, sum := range cr.sums {
+		if strings.HasPrefix(sum.header, "X-Amz-Checksum-") {
+			checksums[sum.header] = base64.StdEncoding.EncodeToString(sum.expected)
+		}
+	}
+	return checksums
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// Config regroupe la configuration du serveur, lue depuis les variables d'environnement
+type Config struct {
+	// Région annoncée par le serveur et attendue dans les signatures SigV4
+	Region string
+	// Couples access key / secret key acceptés pour la signature des requêtes.
+	// Si aucun n'est configuré, l'authentification est désactivée.
+	Credentials map[string]string
+	// Access key à laquelle sont attribués au démarrage les buckets sans propriétaire, créés alors
+	// que l'authentification était désactivée ; vide, ils n'ont pas de propriétaire
+	BucketOwner string
+	// Access key de l'administrateur, seul autorisé à appeler les routes d'administration
+	// (/?integrity, /?usage) ; vide, elles sont refusées à tous
+	AdminAccessKey string
+	// Backend de stockage (voir storage.Backends) et sa racine sur disque ;
+	// les valeurs vides désignent les valeurs par défaut du package storage
+	StorageBackend string
+	StorageRoot    string
+	// Intervalle entre deux passages du sweeper de cycle de vie ; 0 le désactive
+	LifecycleInterval time.Duration
+	// Intervalle entre deux vérifications de l'intégrité des contenus stockés ; 0 les désactive
+	// (une vérification peut toujours être lancée par POST /?integrity)
+	ScrubInterval time.Duration
+	// Débit de lecture maximal d'une vérification, en octets par seconde ; 0 : sans limite
+	ScrubRate int64
+	// Quotas d'espace des buckets et de leurs propriétaires, et intervalle entre deux recomptages
+	// complets de l'usage ; 0 désactive les recomptages périodiques
+	Quotas               dto.QuotaConfiguration
+	QuotaRecountInterval time.Duration
+	// Clé maître AES-256 du chiffrement SSE-S3 ; si elle est absente, seul SSE-C est disponible
+	MasterKey []byte
+	// Répertoire de la file des notifications d'événements ; vide, il est placé dans le
+	// répertoire système de la racine du stockage
+	NotificationQueueDir string
+}
+
+func LoadConfig() (Config, error) {
+	cfg := Config{
+		Region:         os.Getenv("S3_REGION"),
+		Credentials:    make(map[string]string),
+		StorageBackend: os.Getenv("S3_STORAGE_BACKEND"),
+		StorageRoot:    os.Getenv("S3_STORAGE_ROOT"),
+
+		NotificationQueueDir: os.Getenv("S3_NOTIFICATION_QUEUE_DIR"),
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	cfg.LifecycleInterval = time.Hour
+	if value := os.Getenv("S3_LIFECYCLE_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < 0 {
+			return cfg, fmt.Errorf("invalid S3_LIFECYCLE_INTERVAL %q: expected a duration such as 1h or 10m", value)
+		}
+		cfg.LifecycleInterval = interval
+	}
+
+	cfg.ScrubInterval = 24 * time.Hour
+	if value := os.Getenv("S3_SCRUB_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < 0 {
+			return cfg, fmt.Errorf("invalid S3_SCRUB_INTERVAL %q: expected a duration such as 24h or 168h", value)
+		}
+		cfg.ScrubInterval = interval
+	}
+
+	// Par défaut, une vérification lit au plus 10 Mio/s
+	cfg.ScrubRate = 10 << 20
+	if value := os.Getenv("S3_SCRUB_RATE"); value != "" {
+		rate, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || rate < 0 {
+			return cfg, fmt.Errorf("invalid S3_SCRUB_RATE %q: expected a number of bytes per second", value)
+		}
+		cfg.ScrubRate = rate
+	}
+
+	cfg.QuotaRecountInterval = time.Hour
+	if value := os.Getenv("S3_QUOTA_RECOUNT_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < 0 {
+			return cfg, fmt.Errorf("invalid S3_QUOTA_RECOUNT_INTERVAL %q: expected a duration such as 1h or 10m", value)
+		}
+		cfg.QuotaRecountInterval = interval
+	}
+
+	// Fichier JSON optionnel de la forme {"bucket": {"hardBytes": ...}, "owner": {...},
+	// "buckets": {"nom": {...}}, "owners": {"accessKey": {...}}} (voir dto.QuotaConfiguration)
+	if path := os.Getenv("S3_QUOTAS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read quotas file: %v", err)
+		}
+		if err := json.Unmarshal(data, &cfg.Quotas); err != nil {
+			return cfg, fmt.Errorf("failed to parse quotas file %s: %v", path, err)
+		}
+		if err := validateQuotas(cfg.Quotas); err != nil {
+			return cfg, fmt.Errorf("invalid quotas file %s: %v", path, err)
+		}
+	}
+
+	// Clé de 32 octets encodée en base64 (par exemple : openssl rand -base64 32)
+	if value := os.Getenv("S3_SSE_MASTER_KEY"); value != "" {
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(key) != 32 {
+			return cfg, fmt.Errorf("invalid S3_SSE_MASTER_KEY: expected 32 bytes encoded in base64")
+		}
+		cfg.MasterKey = key
+	}
+
+	accessKey := os.Getenv("S3_ACCESS_KEY")
+	secretKey := os.Getenv("S3_SECRET_KEY")
+	if accessKey != "" || secretKey != "" {
+		if accessKey == "" || secretKey == "" {
+			return cfg, fmt.Errorf("S3_ACCESS_KEY and S3_SECRET_KEY must be set together")
+		}
+		cfg.Credentials[accessKey] = secretKey
+	}
+
+	// Fichier JSON optionnel de la forme {"accessKey": "secretKey", ...}
+	if path := os.Getenv("S3_CREDENTIALS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read credentials file: %v", err)
+		}
+		var fileCredentials map[string]string
+		if err := json.Unmarshal(data, &fileCredentials); err != nil {
+			return cfg, fmt.Errorf("failed to parse credentials file %s: %v", path, err)
+		}
+		for accessKey, secretKey := range fileCredentials {
+			cfg.Credentials[accessKey] = secretKey
+		}
+	}
+
+	// Par défaut, les buckets sans propriétaire reviennent à S3_ACCESS_KEY
+	cfg.BucketOwner = os.Getenv("S3_BUCKET_OWNER")
+	if cfg.BucketOwner == "" {
+		cfg.BucketOwner = accessKey
+	}
+	if _, ok := cfg.Credentials[cfg.BucketOwner]; cfg.BucketOwner != "" && !ok {
+		return cfg, fmt.Errorf("invalid S3_BUCKET_OWNER %q: expected one of the configured access keys", cfg.BucketOwner)
+	}
+
+	// Par défaut, l'administrateur est le titulaire de S3_ACCESS_KEY
+	cfg.AdminAccessKey = os.Getenv("S3_ADMIN_ACCESS_KEY")
+	if cfg.AdminAccessKey == "" {
+		cfg.AdminAccessKey = accessKey
+	}
+	if _, ok := cfg.Credentials[cfg.AdminAccessKey]; cfg.AdminAccessKey != "" && !ok {
+		return cfg, fmt.Errorf("invalid S3_ADMIN_ACCESS_KEY %q: expected one of the configured access keys", cfg.AdminAccessKey)
+	}
+
+	return cfg, nil
+}
+
+// validateQuotas refuse les limites négatives
+func validateQuotas(quotas dto.QuotaConfiguration) error {
+	limits := map[string]dto.QuotaLimits{"bucket": quotas.Bucket, "owner": quotas.Owner}
+	for name, l := range quotas.Buckets {
+		limits["bucket "+name] = l
+	}
+	for owner, l := range quotas.Owners {
+		limits["owner "+owner] = l
+	}
+	for name, l := range limits {
+		if l.SoftBytes < 0 || l.HardBytes < 0 || l.SoftObjects < 0 || l.HardObjects < 0 {
+			return fmt.Errorf("limits of %s must not be negative", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"my-s3-clone/dto"
+)
+
+// Limites imposées par S3 sur une configuration CORS
+const (
+	maxRules    = 100
+	maxIDLength = 255
+)
+
+// Méthodes qu'une règle peut autoriser
+var allowedMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPut:    true,
+	http.MethodPost:   true,
+	http.MethodDelete: true,
+	http.MethodHead:   true,
+}
+
+// ConfigError décrit une configuration refusée. Malformed distingue un document qui ne respecte
+// pas le schéma (MalformedXML) d'une règle dont les valeurs sont invalides (InvalidRequest).
+type ConfigError struct {
+	Malformed bool
+	Message   string
+}
+
+func (e *ConfigError) Error() string {
+	return e.Message
+}
+
+func malformed(format string, args ...interface{}) error {
+	return &ConfigError{Malformed: true, Message: fmt.Sprintf(format, args...)}
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &ConfigError{Message: fmt.Sprintf(format, args...)}
+}
+
+// Validate vérifie une configuration CORS avant son enregistrement
+func Validate(config dto.CORSConfiguration) error {
+	if len(config.Rules) == 0 {
+		return malformed("The CORS configuration must contain at least one rule")
+	}
+	if len(config.Rules) > maxRules {
+		return invalid("The CORS configuration cannot have more than %d rules", maxRules)
+	}
+
+	for _, rule := range config.Rules {
+		if len(rule.ID) > maxIDLength {
+			return invalid("ID length should not exceed allowed limit of %d", maxIDLength)
+		}
+		if len(rule.AllowedOrigins) == 0 || len(rule.AllowedMethods) == 0 {
+			return malformed("A CORS rule must contain at least one AllowedOrigin and one AllowedMethod")
+		}
+		for _, method := range rule.AllowedMethods {
+			if !allowedMethods[method] {
+				return invalid("Found unsupported HTTP method in CORS config. Unsupported method is %s", method)
+			}
+		}
+		for _, origin := range rule.AllowedOrigins {
+			if strings.Count(origin, "*") > 1 {
+				return invalid("AllowedOrigin %q can not have more than one wildcard.", origin)
+			}
+		}
+		for _, header := range rule.AllowedHeaders {
+			if strings.Count(header, "*") > 1 {
+				return invalid("AllowedHeader %q can not have more than one wildcard.", header)
+			}
+		}
+		if rule.MaxAgeSeconds != nil && *rule.MaxAgeSeconds < 0 {
+			return invalid("MaxAgeSeconds must be a positive integer")
+		}
+	}
+	return nil
+}
+
+// Match renvoie la première règle qui autorise la requête, ou nil. headers est la liste des
+// en-têtes demandés par un preflight (Access-Control-Request-Headers).
+func Match(config dto.CORSConfiguration, origin, method string, headers []string) *dto.CORSRule {
+	for i, rule := range config.Rules {
+		if matchesAny(rule.AllowedOrigins, origin, false) && contains(rule.AllowedMethods, method) && allowsHeaders(rule, headers) {
+			return &config.Rules[i]
+		}
+	}
+	return nil
+}
+
+// ParseRequestHeaders découpe la valeur d'Access-Control-Request-Headers
+func ParseRequestHeaders(value string) []string {
+	var headers []string
+	for _, header := range strings.Split(value, ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
+
+// SetHeaders ajoute à la réponse les en-têtes CORS d'une requête autorisée par rule. Une règle
+// ouverte à toutes les origines renvoie "*" ; sinon l'origine est reprise, avec les credentials.
+func SetHeaders(h http.Header, rule *dto.CORSRule, origin string) {
+	if contains(rule.AllowedOrigins, "*") {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	h.Set("Access-Control-Allow-Methods", strings.Join(rule.AllowedMethods, ", "))
+	if len(rule.ExposeHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+	}
+	h.Add("Vary", "Origin")
+}
+
+// SetPreflightHeaders ajoute à la réponse d'un preflight autorisé les en-têtes demandés et la
+// durée de mise en cache
+func SetPreflightHeaders(h http.Header, rule *dto.CORSRule, origin string, headers []string) {
+	SetHeaders(h, rule, origin)
+	if len(headers) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	if rule.MaxAgeSeconds != nil {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(*rule.MaxAgeSeconds))
+	}
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+}
+
+func allowsHeaders(rule dto.CORSRule, headers []string) bool {
+	for _, header := range headers {
+		if !matchesAny(rule.AllowedHeaders, header, true) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny indique si value correspond à l'un des motifs, qui peuvent contenir un joker "*"
+func matchesAny(patterns []string, value string, ignoreCase bool) bool {
+	for _, pattern := range patterns {
+		if ignoreCase {
+			pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+		}
+		prefix, suffix, wildcard := strings.Cut(pattern, "*")
+		if !wildcard && pattern == value {
+			return true
+		}
+		if wildcard && len(value) >= len(prefix)+len(suffix) && strings.HasPrefix(value, prefix) && strings.HasSuffix(value, suffix) {
+			return true
+		}
+	}
+	return false
+}
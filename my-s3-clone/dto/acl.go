@@ -0,0 +1,26 @@
+package dto
+
+import "encoding/xml"
+
+// AccessControlPolicy est la réponse de GET ?acl : les droits accordés par l'ACL prédéfinie
+// du bucket ou de l'objet, en plus du contrôle total de son propriétaire
+type AccessControlPolicy struct {
+	XMLName           xml.Name `xml:"AccessControlPolicy"`
+	Xmlns             string   `xml:"xmlns,attr,omitempty"`
+	Owner             Owner    `xml:"Owner"`
+	AccessControlList []Grant  `xml:"AccessControlList>Grant"`
+}
+
+type Grant struct {
+	Grantee    Grantee `xml:"Grantee"`
+	Permission string  `xml:"Permission"`
+}
+
+// Grantee est un utilisateur (CanonicalUser, identifié par son access key) ou un groupe (Group, identifié par son URI)
+type Grantee struct {
+	XmlnsXsi    string `xml:"xmlns:xsi,attr"`
+	Type        string `xml:"xsi:type,attr"`
+	ID          string `xml:"ID,omitempty"`
+	DisplayName string `xml:"DisplayName,omitempty"`
+	URI         string `xml:"URI,omitempty"`
+}
@@ -8,12 +8,35 @@ import (
 type ListAllMyBucketsResult struct {
     XMLName xml.Name `xml:"ListAllMyBucketsResult"`
     Buckets []Bucket `xml:"Buckets>Bucket"`
+    Owner   *Owner   `xml:"Owner,omitempty"`
 }
 
 type Bucket struct {
     Name         string    `xml:"Name"`
     CreationDate time.Time `xml:"CreationDate"`
-    LocationConstraint   string   `xml:"LocationConstraint,omitempty"`
-    ObjectLockConfig   string   `xml:"ObjectLockConfiguration,omitempty"`
-    ObjectDelimiter   string   `xml:"ObjectDelimiter,omitempty"`
+    BucketRegion string    `xml:"BucketRegion,omitempty"`
+}
+
+// BucketInfo regroupe les métadonnées d'un bucket conservées par le stockage
+type BucketInfo struct {
+    Name         string
+    CreationDate time.Time
+    // Access key du créateur du bucket ; vide si l'authentification était désactivée
+    Owner        string
+    // Région du bucket ; vide pour les buckets créés avant son enregistrement
+    Region       string
+    // ACL prédéfinie du bucket (voir le package policy) ; vide équivaut à "private"
+    ACL          string
+}
+
+// CreateBucketConfiguration est le corps facultatif de CreateBucket
+type CreateBucketConfiguration struct {
+    XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+    LocationConstraint string   `xml:"LocationConstraint"`
+}
+
+// LocationConstraint est la réponse de GetBucketLocation
+type LocationConstraint struct {
+    XMLName xml.Name `xml:"LocationConstraint"`
+    Region  string   `xml:",chardata"`
 }
@@ -0,0 +1,22 @@
+package dto
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// CopyObjectResult est la réponse d'une copie d'objet (PUT avec x-amz-copy-source)
+type CopyObjectResult struct {
+	XMLName      xml.Name  `xml:"CopyObjectResult"`
+	Xmlns        string    `xml:"xmlns,attr"`
+	ETag         string    `xml:"ETag"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+// CopyPartResult est la réponse d'UploadPartCopy (upload d'une partie avec x-amz-copy-source)
+type CopyPartResult struct {
+	XMLName      xml.Name  `xml:"CopyPartResult"`
+	Xmlns        string    `xml:"xmlns,attr"`
+	ETag         string    `xml:"ETag"`
+	LastModified time.Time `xml:"LastModified"`
+}
@@ -0,0 +1,23 @@
+package dto
+
+import "encoding/xml"
+
+// CORSConfiguration est le corps de PUT/GET ?cors
+type CORSConfiguration struct {
+	XMLName xml.Name   `xml:"CORSConfiguration" json:"-"`
+	Xmlns   string     `xml:"xmlns,attr,omitempty" json:"-"`
+	Rules   []CORSRule `xml:"CORSRule" json:"rules"`
+}
+
+// CORSRule autorise des requêtes cross-origin : une requête doit correspondre à l'une des
+// origines, à l'une des méthodes et, pour un preflight, chacun des en-têtes demandés doit
+// correspondre à l'un des AllowedHeader. Les origines et en-têtes acceptent un joker "*".
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty" json:"id,omitempty"`
+	AllowedOrigins []string `xml:"AllowedOrigin" json:"allowedOrigins"`
+	AllowedMethods []string `xml:"AllowedMethod" json:"allowedMethods"`
+	AllowedHeaders []string `xml:"AllowedHeader" json:"allowedHeaders,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader" json:"exposeHeaders,omitempty"`
+	// Durée pendant laquelle le navigateur peut garder la réponse au preflight en cache
+	MaxAgeSeconds *int `xml:"MaxAgeSeconds" json:"maxAgeSeconds,omitempty"`
+}
@@ -3,28 +3,40 @@ package dto
 import (
     "encoding/xml"
 )
-// type DeleteObjectRequest struct {
-// 	Quiet  bool `xml:"Quiet"`
-// 	Object struct {
-// 		Key string `xml:"Key"`
-// 	} `xml:"Object"`
-// }
 
+// DeleteResult est la réponse d'une suppression par lot : une entrée par clé
 type DeleteResult struct {
-	DeletedResult []Deleted `xml:"Deleted"`
+	XMLName       xml.Name      `xml:"DeleteResult"`
+	Xmlns         string        `xml:"xmlns,attr,omitempty"`
+	DeletedResult []Deleted     `xml:"Deleted"`
+	Errors        []DeleteError `xml:"Error"`
+}
+
+// DeleteError signale une clé qu'une opération par lot n'a pas pu traiter ; les autres clés le sont quand même
+type DeleteError struct {
+	Key       string `xml:"Key"`
+	VersionId string `xml:"VersionId,omitempty"`
+	Code      string `xml:"Code"`
+	Message   string `xml:"Message"`
 }
 
 type Deleted struct {
-	Key string `xml:"Key"`
+	Key                   string `xml:"Key"`
+	VersionId             string `xml:"VersionId,omitempty"`
+	DeleteMarker          bool   `xml:"DeleteMarker,omitempty"`
+	DeleteMarkerVersionId string `xml:"DeleteMarkerVersionId,omitempty"`
 }
 
 // DeleteObjectRequest représente la requête de suppression d'objets en batch
 type DeleteObjectRequest struct {
     XMLName xml.Name         `xml:"Delete"`
+    // En mode Quiet, la réponse ne contient que les erreurs
+    Quiet   bool              `xml:"Quiet"`
     Objects []ObjectToDelete  `xml:"Object"`
 }
 
 // ObjectToDelete représente un objet à supprimer
 type ObjectToDelete struct {
-    Key string `xml:"Key"`
+    Key       string `xml:"Key"`
+    VersionId string `xml:"VersionId,omitempty"`
 }
@@ -0,0 +1,45 @@
+package dto
+
+import "encoding/xml"
+
+// Algorithme de chiffrement côté serveur accepté, pour SSE-S3 comme pour SSE-C
+const EncryptionAES256 = "AES256"
+
+// Encryption décrit le chiffrement au repos d'un objet : SSE-S3 (clé gérée par le serveur) ou
+// SSE-C (clé fournie par le client à chaque requête, dont seul le MD5 est enregistré)
+type Encryption struct {
+	Algorithm string `json:"algorithm"`
+	// MD5 (base64) de la clé du client ; vide pour SSE-S3
+	CustomerKeyMD5 string `json:"customerKeyMD5,omitempty"`
+	// Clé du client, transmise avec la requête d'écriture et jamais enregistrée
+	CustomerKey []byte `json:"-"`
+	// Taille et ETag du contenu en clair, enregistrés à l'écriture pour que les listings n'aient
+	// pas à ouvrir l'objet ; l'ETag n'est enregistré que pour SSE-S3, où il est le MD5 du contenu
+	PlainSize *int64 `json:"plainSize,omitempty"`
+	PlainETag string `json:"plainETag,omitempty"`
+}
+
+// CustomerProvided indique un chiffrement SSE-C
+func (e *Encryption) CustomerProvided() bool {
+	return e.CustomerKeyMD5 != ""
+}
+
+// ServerSideEncryptionConfiguration est le corps de PUT/GET ?encryption : le chiffrement appliqué
+// par défaut aux objets écrits dans le bucket sans en-tête de chiffrement
+type ServerSideEncryptionConfiguration struct {
+	XMLName xml.Name                   `xml:"ServerSideEncryptionConfiguration" json:"-"`
+	Xmlns   string                     `xml:"xmlns,attr,omitempty" json:"-"`
+	Rules   []ServerSideEncryptionRule `xml:"Rule" json:"rules"`
+}
+
+// ServerSideEncryptionRule désigne le chiffrement appliqué par défaut
+type ServerSideEncryptionRule struct {
+	ApplyServerSideEncryptionByDefault ServerSideEncryptionByDefault `xml:"ApplyServerSideEncryptionByDefault" json:"applyServerSideEncryptionByDefault"`
+}
+
+// ServerSideEncryptionByDefault donne l'algorithme du chiffrement par défaut ; seul AES256 (SSE-S3) est
+// accepté, les clés KMS ne sont pas prises en charge
+type ServerSideEncryptionByDefault struct {
+	SSEAlgorithm   string `xml:"SSEAlgorithm" json:"sseAlgorithm"`
+	KMSMasterKeyID string `xml:"KMSMasterKeyID,omitempty" json:"kmsMasterKeyId,omitempty"`
+}
@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// IntegrityReport est le résultat d'un passage du scrubber, renvoyé par GET /?integrity.
+// Les contenus y sont relus et comparés à l'empreinte SHA-256 enregistrée à leur écriture.
+type IntegrityReport struct {
+	XMLName xml.Name `xml:"IntegrityReport" json:"-"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty" json:"-"`
+	// Vrai si un passage est en cours ; le rapport est alors celui du passage précédent
+	InProgress bool       `xml:"InProgress" json:"-"`
+	Started    time.Time  `xml:"Started,omitempty" json:"started"`
+	Finished   *time.Time `xml:"Finished,omitempty" json:"finished,omitempty"`
+	// Versions relues et volume relu
+	ObjectsChecked int64 `xml:"ObjectsChecked" json:"objectsChecked"`
+	BytesChecked   int64 `xml:"BytesChecked" json:"bytesChecked"`
+	// Contenus dont l'empreinte ne correspond plus, contenus disparus, et contenus sans empreinte
+	// (écrits avant son enregistrement), qui ne peuvent pas être vérifiés
+	Corrupt    []IntegrityIssue `xml:"Corrupt" json:"corrupt,omitempty"`
+	Missing    []IntegrityIssue `xml:"Missing" json:"missing,omitempty"`
+	Unverified []IntegrityIssue `xml:"Unverified" json:"unverified,omitempty"`
+}
+
+// IntegrityIssue désigne une version d'objet signalée par le scrubber. Expected et Actual ne sont
+// renseignés que pour un contenu corrompu.
+type IntegrityIssue struct {
+	Bucket    string `xml:"Bucket" json:"bucket"`
+	Key       string `xml:"Key" json:"key"`
+	VersionID string `xml:"VersionId,omitempty" json:"versionId,omitempty"`
+	Expected  string `xml:"ExpectedSHA256,omitempty" json:"expectedSha256,omitempty"`
+	Actual    string `xml:"ActualSHA256,omitempty" json:"actualSha256,omitempty"`
+}
@@ -0,0 +1,63 @@
+package dto
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// LifecycleConfiguration est le corps de PUT/GET ?lifecycle
+type LifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration" json:"-"`
+	Xmlns   string          `xml:"xmlns,attr,omitempty" json:"-"`
+	Rules   []LifecycleRule `xml:"Rule" json:"rules"`
+}
+
+// LifecycleRule est une règle de cycle de vie : les actions s'appliquent aux objets
+// sélectionnés par le filtre (ou par l'ancien élément Prefix, placé directement dans la règle)
+type LifecycleRule struct {
+	ID     string           `xml:"ID,omitempty" json:"id,omitempty"`
+	Status string           `xml:"Status" json:"status"`
+	Prefix *string          `xml:"Prefix" json:"prefix,omitempty"`
+	Filter *LifecycleFilter `xml:"Filter" json:"filter,omitempty"`
+
+	Expiration                     *LifecycleExpiration            `xml:"Expiration" json:"expiration,omitempty"`
+	NoncurrentVersionExpiration    *NoncurrentVersionExpiration    `xml:"NoncurrentVersionExpiration" json:"noncurrentVersionExpiration,omitempty"`
+	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload" json:"abortIncompleteMultipartUpload,omitempty"`
+}
+
+// LifecycleFilter sélectionne les objets par préfixe, par tag, ou par les deux (And)
+type LifecycleFilter struct {
+	Prefix *string             `xml:"Prefix" json:"prefix,omitempty"`
+	Tag    *Tag                `xml:"Tag" json:"tag,omitempty"`
+	And    *LifecycleFilterAnd `xml:"And" json:"and,omitempty"`
+}
+
+// LifecycleFilterAnd combine un préfixe et plusieurs tags, qui doivent tous correspondre
+type LifecycleFilterAnd struct {
+	Prefix string `xml:"Prefix,omitempty" json:"prefix,omitempty"`
+	Tags   []Tag  `xml:"Tag" json:"tags,omitempty"`
+}
+
+// Tag est un couple clé / valeur associé à un objet
+type Tag struct {
+	Key   string `xml:"Key" json:"key"`
+	Value string `xml:"Value" json:"value"`
+}
+
+// LifecycleExpiration fait expirer la version courante des objets, après un nombre
+// de jours ou à une date donnée
+type LifecycleExpiration struct {
+	Days int        `xml:"Days,omitempty" json:"days,omitempty"`
+	Date *time.Time `xml:"Date" json:"date,omitempty"`
+}
+
+// NoncurrentVersionExpiration supprime les versions précédentes un nombre de jours
+// après qu'elles ont été remplacées
+type NoncurrentVersionExpiration struct {
+	NoncurrentDays int `xml:"NoncurrentDays" json:"noncurrentDays"`
+}
+
+// AbortIncompleteMultipartUpload abandonne les uploads multipart restés inachevés
+type AbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation" json:"daysAfterInitiation"`
+}
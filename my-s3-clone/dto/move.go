@@ -0,0 +1,17 @@
+package dto
+
+import "encoding/xml"
+
+// MoveResult est la réponse d'un déplacement par lot (POST ?move) : une entrée par clé
+type MoveResult struct {
+	XMLName xml.Name      `xml:"MoveResult"`
+	Xmlns   string        `xml:"xmlns,attr,omitempty"`
+	Moved   []Moved       `xml:"Moved"`
+	Errors  []DeleteError `xml:"Error"`
+}
+
+// Moved décrit un objet déplacé ; VersionId est celle créée dans le bucket cible s'il est versionné
+type Moved struct {
+	Key       string `xml:"Key"`
+	VersionId string `xml:"VersionId,omitempty"`
+}
@@ -0,0 +1,36 @@
+package dto
+
+import "encoding/xml"
+
+// NotificationConfiguration est le corps de PUT/GET ?notification. Les cibles SNS, SQS et Lambda
+// de S3 n'existent pas ici : les événements sont envoyés à des webhooks HTTP (WebhookConfiguration).
+type NotificationConfiguration struct {
+	XMLName  xml.Name               `xml:"NotificationConfiguration" json:"-"`
+	Xmlns    string                 `xml:"xmlns,attr,omitempty" json:"-"`
+	Webhooks []WebhookConfiguration `xml:"WebhookConfiguration" json:"webhooks,omitempty"`
+}
+
+// WebhookConfiguration envoie en POST à Endpoint les événements de l'un des types Events
+// ("s3:ObjectCreated:*", "s3:ObjectRemoved:Delete"...) dont la clé respecte le filtre
+type WebhookConfiguration struct {
+	ID       string              `xml:"Id,omitempty" json:"id,omitempty"`
+	Endpoint string              `xml:"Endpoint" json:"endpoint"`
+	Events   []string            `xml:"Event" json:"events"`
+	Filter   *NotificationFilter `xml:"Filter,omitempty" json:"filter,omitempty"`
+}
+
+// NotificationFilter restreint les clés notifiées par un préfixe et/ou un suffixe
+type NotificationFilter struct {
+	Key KeyFilter `xml:"S3Key" json:"key"`
+}
+
+// KeyFilter regroupe les règles "prefix" et "suffix" d'un filtre (au plus une de chaque)
+type KeyFilter struct {
+	Rules []FilterRule `xml:"FilterRule" json:"rules"`
+}
+
+// FilterRule est une règle de filtre : Name vaut "prefix" ou "suffix"
+type FilterRule struct {
+	Name  string `xml:"Name" json:"name"`
+	Value string `xml:"Value" json:"value"`
+}
@@ -5,19 +5,56 @@ import (
     "time"
 )
 
+// ListObjectsResponse est la réponse de ListObjects (v1)
 type ListObjectsResponse struct {
-    XMLName     xml.Name `xml:"ListBucketResult"`
-    Xmlns       string   `xml:"xmlns,attr"`
-    Name        string   `xml:"Name"`
-    Prefix      string   `xml:"Prefix"`
-    Marker      string   `xml:"Marker"`
-    MaxKeys     int      `xml:"MaxKeys"`
-    IsTruncated bool     `xml:"IsTruncated"`
-    Contents    []Object `xml:"Contents"`
+    XMLName        xml.Name       `xml:"ListBucketResult"`
+    Xmlns          string         `xml:"xmlns,attr"`
+    Name           string         `xml:"Name"`
+    Prefix         string         `xml:"Prefix"`
+    Marker         string         `xml:"Marker"`
+    NextMarker     string         `xml:"NextMarker,omitempty"`
+    MaxKeys        int            `xml:"MaxKeys"`
+    Delimiter      string         `xml:"Delimiter,omitempty"`
+    EncodingType   string         `xml:"EncodingType,omitempty"`
+    IsTruncated    bool           `xml:"IsTruncated"`
+    Contents       []Object       `xml:"Contents"`
+    CommonPrefixes []CommonPrefix `xml:"CommonPrefixes"`
+}
+
+// ListBucketV2Result est la réponse de ListObjectsV2 (list-type=2)
+type ListBucketV2Result struct {
+    XMLName               xml.Name       `xml:"ListBucketResult"`
+    Xmlns                 string         `xml:"xmlns,attr"`
+    Name                  string         `xml:"Name"`
+    Prefix                string         `xml:"Prefix"`
+    Delimiter             string         `xml:"Delimiter,omitempty"`
+    StartAfter            string         `xml:"StartAfter,omitempty"`
+    ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+    NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+    MaxKeys               int            `xml:"MaxKeys"`
+    KeyCount              int            `xml:"KeyCount"`
+    EncodingType          string         `xml:"EncodingType,omitempty"`
+    IsTruncated           bool           `xml:"IsTruncated"`
+    Contents              []Object       `xml:"Contents"`
+    CommonPrefixes        []CommonPrefix `xml:"CommonPrefixes"`
 }
 
 type Object struct {
     Key          string    `xml:"Key"`
     LastModified time.Time `xml:"LastModified"`
+    ETag         string    `xml:"ETag,omitempty"`
     Size         int       `xml:"Size"`
+    StorageClass string    `xml:"StorageClass,omitempty"`
+    Owner        *Owner    `xml:"Owner,omitempty"`
+}
+
+// CommonPrefix regroupe les clés partageant un même préfixe jusqu'au délimiteur
+type CommonPrefix struct {
+    Prefix string `xml:"Prefix"`
+}
+
+// Owner identifie le propriétaire d'un objet ou d'un bucket
+type Owner struct {
+    ID          string `xml:"ID"`
+    DisplayName string `xml:"DisplayName"`
 }
@@ -0,0 +1,40 @@
+package dto
+
+import "time"
+
+// ObjectMetadata regroupe les en-têtes HTTP enregistrés avec un objet et renvoyés sur GET/HEAD
+type ObjectMetadata struct {
+	ContentType        string `json:"contentType,omitempty"`
+	ContentDisposition string `json:"contentDisposition,omitempty"`
+	CacheControl       string `json:"cacheControl,omitempty"`
+	// En-têtes x-amz-meta-*, indexés par leur nom en minuscules sans le préfixe
+	UserMetadata map[string]string `json:"userMetadata,omitempty"`
+	// Chiffrement au repos, nil si l'objet est stocké en clair (voir storage.EncryptedStorage)
+	Encryption *Encryption `json:"encryption,omitempty"`
+	// Rétention et mise en suspens légale, nil si la version n'est pas verrouillée (voir storage.ObjectLockStorage)
+	Lock *ObjectLock `json:"lock,omitempty"`
+	// ACL prédéfinie de l'objet (en-tête x-amz-acl) ; vide équivaut à "private"
+	ACL string `json:"acl,omitempty"`
+	// Tags de l'objet (en-tête x-amz-tagging ou PUT ?tagging), indexés par leur clé
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// ObjectInfo représente les métadonnées d'un objet stocké
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	// ETag sans guillemets : MD5 du contenu, ou "<md5>-<nombre de parties>" pour un objet multipart
+	ETag string
+	// Identifiant de version, vide pour un objet écrit sans versioning
+	VersionID string
+	ObjectMetadata
+}
+
+// DeleteObjectResult décrit l'effet d'une suppression d'objet
+type DeleteObjectResult struct {
+	// Version supprimée, ou version du marqueur de suppression créé
+	VersionID string
+	// Vrai si un marqueur de suppression a été créé ou supprimé
+	DeleteMarker bool
+}
@@ -0,0 +1,64 @@
+package dto
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Valeurs de la configuration Object Lock d'un bucket et du verrouillage des objets
+const (
+	ObjectLockEnabled   = "Enabled"
+	RetentionGovernance = "GOVERNANCE"
+	RetentionCompliance = "COMPLIANCE"
+	LegalHoldOn         = "ON"
+	LegalHoldOff        = "OFF"
+)
+
+// ObjectLockConfiguration est le corps de PUT/GET ?object-lock. Une fois activé,
+// le verrouillage ne peut plus être désactivé ; seule la rétention par défaut peut changer.
+type ObjectLockConfiguration struct {
+	XMLName           xml.Name        `xml:"ObjectLockConfiguration" json:"-"`
+	Xmlns             string          `xml:"xmlns,attr,omitempty" json:"-"`
+	ObjectLockEnabled string          `xml:"ObjectLockEnabled,omitempty" json:"objectLockEnabled"`
+	Rule              *ObjectLockRule `xml:"Rule" json:"rule,omitempty"`
+}
+
+// ObjectLockRule porte la rétention appliquée aux nouveaux objets qui n'en précisent pas
+type ObjectLockRule struct {
+	DefaultRetention DefaultRetention `xml:"DefaultRetention" json:"defaultRetention"`
+}
+
+// DefaultRetention est une durée de rétention, en jours ou en années
+type DefaultRetention struct {
+	Mode  string `xml:"Mode" json:"mode"`
+	Days  int    `xml:"Days,omitempty" json:"days,omitempty"`
+	Years int    `xml:"Years,omitempty" json:"years,omitempty"`
+}
+
+// ObjectRetention est le corps de PUT/GET ?retention
+type ObjectRetention struct {
+	XMLName         xml.Name   `xml:"Retention"`
+	Xmlns           string     `xml:"xmlns,attr,omitempty"`
+	Mode            string     `xml:"Mode,omitempty"`
+	RetainUntilDate *time.Time `xml:"RetainUntilDate"`
+}
+
+// LegalHold est le corps de PUT/GET ?legal-hold
+type LegalHold struct {
+	XMLName xml.Name `xml:"LegalHold"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	Status  string   `xml:"Status"`
+}
+
+// ObjectLock est le verrouillage d'une version d'objet, enregistré avec ses métadonnées
+type ObjectLock struct {
+	// Mode de rétention (GOVERNANCE ou COMPLIANCE), vide si la version n'a pas de rétention
+	Mode            string    `json:"mode,omitempty"`
+	RetainUntilDate time.Time `json:"retainUntilDate,omitempty"`
+	LegalHold       bool      `json:"legalHold,omitempty"`
+}
+
+// Retained indique si la rétention protège encore la version à l'instant now
+func (l *ObjectLock) Retained(now time.Time) bool {
+	return l != nil && l.Mode != "" && now.Before(l.RetainUntilDate)
+}
@@ -0,0 +1,83 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// BucketPolicy est le corps JSON de PUT/GET ?policy
+type BucketPolicy struct {
+	Version   string            `json:"Version,omitempty"`
+	ID        string            `json:"Id,omitempty"`
+	Statement []PolicyStatement `json:"Statement"`
+}
+
+// PolicyStatement autorise (Allow) ou refuse (Deny) des actions "s3:..." sur des ressources
+// "arn:aws:s3:::bucket[/clé]" aux principaux désignés. Actions et ressources acceptent les jokers "*" et "?".
+type PolicyStatement struct {
+	Sid       string     `json:"Sid,omitempty"`
+	Effect    string     `json:"Effect"`
+	Principal *Principal `json:"Principal,omitempty"`
+	Action    StringList `json:"Action"`
+	Resource  StringList `json:"Resource"`
+}
+
+// Principal désigne les access keys visées par une instruction : "*" (tout le monde, y compris
+// les requêtes anonymes) ou {"AWS": ["<access key>", ...]}
+type Principal struct {
+	AWS StringList
+}
+
+func (p *Principal) UnmarshalJSON(data []byte) error {
+	var wildcard string
+	if err := json.Unmarshal(data, &wildcard); err == nil {
+		if wildcard != "*" {
+			return errors.New(`principal must be "*" or {"AWS": ...}`)
+		}
+		p.AWS = StringList{"*"}
+		return nil
+	}
+
+	var principals map[string]StringList
+	if err := json.Unmarshal(data, &principals); err != nil {
+		return err
+	}
+	for kind := range principals {
+		if kind != "AWS" {
+			return errors.New("unsupported principal type " + kind)
+		}
+	}
+	p.AWS = principals["AWS"]
+	return nil
+}
+
+func (p Principal) MarshalJSON() ([]byte, error) {
+	if len(p.AWS) == 1 && p.AWS[0] == "*" {
+		return json.Marshal("*")
+	}
+	return json.Marshal(map[string]StringList{"AWS": p.AWS})
+}
+
+// StringList accepte une chaîne seule ou un tableau de chaînes, comme les politiques AWS
+type StringList []string
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = StringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+func (l StringList) MarshalJSON() ([]byte, error) {
+	if len(l) == 1 {
+		return json.Marshal(l[0])
+	}
+	return json.Marshal([]string(l))
+}
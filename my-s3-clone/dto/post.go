@@ -0,0 +1,13 @@
+package dto
+
+import "encoding/xml"
+
+// PostResponse est la réponse d'un upload par formulaire POST avec success_action_status 201
+type PostResponse struct {
+	XMLName  xml.Name `xml:"PostResponse"`
+	Xmlns    string   `xml:"xmlns,attr,omitempty"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
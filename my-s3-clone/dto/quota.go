@@ -0,0 +1,85 @@
+package dto
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// QuotaLimits borne l'espace occupé par un bucket ou par l'ensemble des buckets d'un propriétaire,
+// en octets et en nombre de versions stockées ; 0 signifie pas de limite. Une écriture qui
+// dépasserait une limite stricte (Hard) est refusée, une limite souple (Soft) est seulement signalée.
+type QuotaLimits struct {
+	SoftBytes   int64 `xml:"SoftBytes,omitempty" json:"softBytes,omitempty"`
+	HardBytes   int64 `xml:"HardBytes,omitempty" json:"hardBytes,omitempty"`
+	SoftObjects int64 `xml:"SoftObjects,omitempty" json:"softObjects,omitempty"`
+	HardObjects int64 `xml:"HardObjects,omitempty" json:"hardObjects,omitempty"`
+}
+
+// IsZero indique si aucune limite n'est fixée
+func (l QuotaLimits) IsZero() bool {
+	return l == QuotaLimits{}
+}
+
+// QuotaConfiguration regroupe les limites par défaut de chaque bucket et de chaque propriétaire
+// (access key), et celles propres à certains buckets ou propriétaires, qui les remplacent
+type QuotaConfiguration struct {
+	Bucket  QuotaLimits            `json:"bucket"`
+	Owner   QuotaLimits            `json:"owner"`
+	Buckets map[string]QuotaLimits `json:"buckets,omitempty"`
+	Owners  map[string]QuotaLimits `json:"owners,omitempty"`
+}
+
+// BucketLimits renvoie les limites du bucket donné
+func (c QuotaConfiguration) BucketLimits(bucketName string) QuotaLimits {
+	if limits, ok := c.Buckets[bucketName]; ok {
+		return limits
+	}
+	return c.Bucket
+}
+
+// OwnerLimits renvoie les limites du propriétaire donné. Les buckets créés sans authentification
+// n'ont pas de propriétaire : seules leurs limites de bucket s'appliquent.
+func (c QuotaConfiguration) OwnerLimits(owner string) QuotaLimits {
+	if owner == "" {
+		return QuotaLimits{}
+	}
+	if limits, ok := c.Owners[owner]; ok {
+		return limits
+	}
+	return c.Owner
+}
+
+// Usage est l'espace occupé par les versions stockées (hors marqueurs de suppression)
+type Usage struct {
+	Bytes   int64 `xml:"Bytes"`
+	Objects int64 `xml:"Objects"`
+}
+
+// UsageReport est la réponse à GET /?usage
+type UsageReport struct {
+	XMLName xml.Name `xml:"UsageReport"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	// Date du dernier recomptage complet ; les compteurs sont tenus à jour entre deux recomptages
+	LastRecount *time.Time    `xml:"LastRecount,omitempty"`
+	Buckets     []BucketUsage `xml:"Bucket"`
+	Owners      []OwnerUsage  `xml:"Owner"`
+}
+
+// BucketUsage décrit l'usage d'un bucket et ses limites
+type BucketUsage struct {
+	Name  string `xml:"Name"`
+	Owner string `xml:"Owner,omitempty"`
+	Usage
+	Quota             *QuotaLimits `xml:"Quota,omitempty"`
+	SoftQuotaExceeded bool         `xml:"SoftQuotaExceeded"`
+	HardQuotaExceeded bool         `xml:"HardQuotaExceeded"`
+}
+
+// OwnerUsage décrit l'usage cumulé des buckets d'un propriétaire et ses limites
+type OwnerUsage struct {
+	ID string `xml:"ID"`
+	Usage
+	Quota             *QuotaLimits `xml:"Quota,omitempty"`
+	SoftQuotaExceeded bool         `xml:"SoftQuotaExceeded"`
+	HardQuotaExceeded bool         `xml:"HardQuotaExceeded"`
+}
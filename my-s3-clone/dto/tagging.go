@@ -0,0 +1,15 @@
+package dto
+
+import "encoding/xml"
+
+// Tagging est le corps de PUT/GET ?tagging
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	TagSet  TagSet   `xml:"TagSet"`
+}
+
+// TagSet regroupe les tags d'un objet ; il est présent, vide, pour un objet sans tags
+type TagSet struct {
+	Tags []Tag `xml:"Tag"`
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/policy"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+const (
+	cannedACLHeader = "X-Amz-Acl"
+
+	xsiNamespace           = "http://www.w3.org/2001/XMLSchema-instance"
+	allUsersGroup          = "http://acs.amazonaws.com/groups/global/AllUsers"
+	authenticatedUserGroup = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+)
+
+// cannedACLFromRequest lit l'ACL prédéfinie demandée dans l'en-tête x-amz-acl ; renvoie "" si
+// la requête n'en demande pas
+func cannedACLFromRequest(r *http.Request) (string, error) {
+	acl := r.Header.Get(cannedACLHeader)
+	if acl != "" && !policy.ValidCannedACL(acl) {
+		return "", fmt.Errorf("Unsupported canned ACL: %s", acl)
+	}
+	return acl, nil
+}
+
+// aclForPut lit l'ACL d'une requête PUT ?acl : seules les ACL prédéfinies sont prises en charge,
+// pas les listes de droits envoyées dans le corps
+func aclForPut(w http.ResponseWriter, r *http.Request) (string, bool) {
+	acl, err := cannedACLFromRequest(r)
+	if err != nil {
+		s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+		return "", false
+	}
+	if acl == "" {
+		if r.Body != nil {
+			io.Copy(io.Discard, r.Body)
+		}
+		s3errors.WriteError(w, r, s3errors.ErrNotImplemented.WithMessage("Only canned ACLs set with the x-amz-acl header are supported."))
+		return "", false
+	}
+	return acl, true
+}
+
+// accessControlPolicy décrit une ACL prédéfinie sous la forme de la liste de droits renvoyée par GET ?acl
+func accessControlPolicy(owner, acl string) dto.AccessControlPolicy {
+	result := dto.AccessControlPolicy{
+		Xmlns: dto.S3Namespace,
+		Owner: dto.Owner{ID: owner, DisplayName: owner},
+		AccessControlList: []dto.Grant{{
+			Grantee:    dto.Grantee{XmlnsXsi: xsiNamespace, Type: "CanonicalUser", ID: owner, DisplayName: owner},
+			Permission: "FULL_CONTROL",
+		}},
+	}
+	group := func(uri, permission string) dto.Grant {
+		return dto.Grant{Grantee: dto.Grantee{XmlnsXsi: xsiNamespace, Type: "Group", URI: uri}, Permission: permission}
+	}
+
+	switch acl {
+	case policy.ACLPublicRead:
+		result.AccessControlList = append(result.AccessControlList, group(allUsersGroup, "READ"))
+	case policy.ACLPublicReadWrite:
+		result.AccessControlList = append(result.AccessControlList, group(allUsersGroup, "READ"), group(allUsersGroup, "WRITE"))
+	case policy.ACLAuthenticatedRead:
+		result.AccessControlList = append(result.AccessControlList, group(authenticatedUserGroup, "READ"))
+	}
+	return result
+}
+
+// HandlePutBucketAcl sets the canned ACL of a bucket (PUT /{bucket}/?acl)
+func HandlePutBucketAcl(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketName := mux.Vars(r)["bucketName"]
+		acl, ok := aclForPut(w, r)
+		if !ok {
+			return
+		}
+
+		info, err := s.GetBucketInfo(bucketName)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		info.ACL = acl
+		if err := s.PutBucketInfo(bucketName, info); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleGetBucketAcl returns the grants of the canned ACL of a bucket (GET /{bucket}/?acl)
+func HandleGetBucketAcl(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := s.GetBucketInfo(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		writeXML(w, accessControlPolicy(info.Owner, info.ACL))
+	}
+}
+
+// HandlePutObjectAcl sets the canned ACL of an object version (PUT /{bucket}/{key}?acl)
+func HandlePutObjectAcl(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		acl, ok := aclForPut(w, r)
+		if !ok {
+			return
+		}
+
+		versionID := r.URL.Query().Get("versionId")
+		err := s.UpdateObjectMetadata(vars["bucketName"], vars["objectName"], versionID, func(metadata *dto.ObjectMetadata) error {
+			metadata.ACL = acl
+			return nil
+		})
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		setVersionIDHeader(w, versionID)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleGetObjectAcl returns the grants of the canned ACL of an object version (GET /{bucket}/{key}?acl)
+func HandleGetObjectAcl(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucketName := vars["bucketName"]
+
+		// Les objets appartiennent au propriétaire de leur bucket
+		info, err := s.GetBucketInfo(bucketName)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		acl, err := storage.ObjectACL(s, bucketName, vars["objectName"], r.URL.Query().Get("versionId"))
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		writeXML(w, accessControlPolicy(info.Owner, acl))
+	}
+}
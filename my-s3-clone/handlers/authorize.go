@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/auth"
+	"my-s3-clone/dto"
+	"my-s3-clone/policy"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+var errAccessDenied = errors.New("access denied")
+
+// Actions que le propriétaire d'un bucket peut toujours effectuer, même si sa politique les lui
+// refuse : sans elles, une politique trop restrictive ne pourrait plus être corrigée
+var ownerPolicyActions = map[string]bool{
+	policy.GetBucketPolicy:    true,
+	policy.PutBucketPolicy:    true,
+	policy.DeleteBucketPolicy: true,
+}
+
+// requestPrincipal renvoie l'access key de l'auteur de la requête ("" pour une requête anonyme),
+// et false si l'authentification est désactivée (toutes les requêtes sont alors autorisées)
+func requestPrincipal(r *http.Request) (string, bool) {
+	if auth.IsAnonymous(r) {
+		return "", true
+	}
+	if sc := auth.SigningContextFromRequest(r); sc != nil {
+		return sc.AccessKey, true
+	}
+	return "", false
+}
+
+// Authorize only lets the request reach next if its author may perform action on the bucket and
+// object of the route, according to the bucket policy and the canned ACLs
+func Authorize(s storage.Storage, action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		versionID := r.URL.Query().Get("versionId")
+
+		// Une version précise se lit et se supprime avec les actions dédiées
+		switch {
+		case action == policy.GetObject && versionID != "":
+			action = policy.GetObjectVersion
+		case action == policy.DeleteObject && versionID != "":
+			action = policy.DeleteObjectVersion
+		case action == policy.GetObjectTagging && versionID != "":
+			action = policy.GetObjectVersionTagging
+		case action == policy.PutObjectTagging && versionID != "":
+			action = policy.PutObjectVersionTagging
+		case action == policy.DeleteObjectTagging && versionID != "":
+			action = policy.DeleteObjectVersionTagging
+		}
+		if !authorized(w, r, s, action, vars["bucketName"], vars["objectName"], versionID) {
+			return
+		}
+
+		// Écrire un objet avec des tags exige aussi le droit de les poser
+		if action == policy.PutObject && r.Header.Get(taggingHeader) != "" {
+			if !authorized(w, r, s, policy.PutObjectTagging, vars["bucketName"], vars["objectName"], versionID) {
+				return
+			}
+		}
+
+		// Le contournement de la rétention GOVERNANCE est une permission à part entière
+		if bypassGovernance(r) {
+			switch action {
+			case policy.DeleteObject, policy.DeleteObjectVersion, policy.PutObjectRetention:
+				if !authorized(w, r, s, policy.BypassGovernanceRetention, vars["bucketName"], vars["objectName"], versionID) {
+					return
+				}
+			}
+		}
+		next(w, r)
+	}
+}
+
+// AuthorizeAdmin only lets the request reach next if its author is the administrator of the server:
+// administration actions cover every bucket, so no bucket policy or ACL can grant them
+func AuthorizeAdmin(admin, action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, enabled := requestPrincipal(r)
+		if enabled && (principal == "" || principal != admin) {
+			if principal == "" {
+				principal = "anonymous"
+			}
+			log.Printf("Access denied to %s for %s", principal, action)
+			s3errors.WriteError(w, r, s3errors.ErrAccessDenied)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authorized vérifie que l'auteur de la requête peut effectuer action sur le bucket (et l'objet
+// key s'il est renseigné), et répond AccessDenied sinon
+func authorized(w http.ResponseWriter, r *http.Request, s storage.Storage, action, bucketName, key, versionID string) bool {
+	err := checkAccess(r, s, action, bucketName, key, versionID)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, errAccessDenied) {
+		principal, _ := requestPrincipal(r)
+		if principal == "" {
+			principal = "anonymous"
+		}
+		log.Printf("Access denied to %s for %s on %s", principal, action, policy.Resource(bucketName, key))
+		s3errors.WriteError(w, r, s3errors.ErrAccessDenied)
+	} else {
+		writeStorageError(w, r, err)
+	}
+	return false
+}
+
+// checkAccess évalue, dans l'ordre : un refus explicite de la politique, les droits du propriétaire
+// du bucket, une autorisation de la politique, puis l'ACL du bucket et celle de l'objet
+func checkAccess(r *http.Request, s storage.Storage, action, bucketName, key, versionID string) error {
+	principal, enabled := requestPrincipal(r)
+	if !enabled {
+		return nil
+	}
+
+	// Lister ses buckets et en créer sont ouverts à tout utilisateur authentifié
+	if bucketName == "" || action == policy.CreateBucket {
+		if principal == "" {
+			return errAccessDenied
+		}
+		return nil
+	}
+
+	info, err := s.GetBucketInfo(bucketName)
+	if err != nil {
+		if principal == "" {
+			// Un anonyme n'apprend pas si le bucket existe
+			return errAccessDenied
+		}
+		// Le handler signale le bucket inexistant ou invalide
+		return nil
+	}
+	// Un bucket créé sans authentification n'a pas de propriétaire tant qu'il n'a pas été attribué
+	// (voir storage.ClaimOwnerlessBuckets) : seules sa politique et son ACL y donnent accès
+	owner := principal != "" && info.Owner == principal
+
+	var bucketPolicy *dto.BucketPolicy
+	if p, err := s.GetBucketPolicy(bucketName); err == nil {
+		bucketPolicy = &p
+	} else if !errors.Is(err, storage.ErrNoSuchBucketPolicy) {
+		return err
+	}
+
+	switch policy.Evaluate(bucketPolicy, principal, action, policy.Resource(bucketName, key)) {
+	case policy.Denied:
+		if owner && ownerPolicyActions[action] {
+			return nil
+		}
+		return errAccessDenied
+	case policy.Allowed:
+		return nil
+	}
+	if owner || policy.BucketACLAllows(info.ACL, principal, action) {
+		return nil
+	}
+
+	if key != "" && policy.IsObjectRead(action) {
+		acl, err := storage.ObjectACL(s, bucketName, key, versionID)
+		if err == nil && policy.ObjectACLAllows(acl, principal, action) {
+			return nil
+		}
+	}
+	return errAccessDenied
+}
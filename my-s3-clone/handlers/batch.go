@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+)
+
+// batchError décrit l'échec d'une clé d'une opération par lot (suppression ou déplacement) :
+// il est rapporté dans la réponse sans interrompre le traitement des autres clés
+func batchError(key, versionID string, err error) dto.DeleteError {
+	apiErr := s3errors.ErrAccessDenied
+	if !errors.Is(err, errAccessDenied) {
+		apiErr = storageAPIError(err)
+	}
+	log.Printf("Batch operation failed on %s: %v", key, err)
+	return dto.DeleteError{Key: key, VersionId: versionID, Code: apiErr.Code, Message: apiErr.Description}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"log"
+	"net/http"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// Région annoncée pour les buckets créés avant l'enregistrement de leur région
+const defaultRegion = "us-east-1"
+
+// requestAccessKey renvoie l'access key qui a signé la requête, ou "" si l'authentification est désactivée
+func requestAccessKey(r *http.Request) string {
+	if sc := auth.SigningContextFromRequest(r); sc != nil {
+		return sc.AccessKey
+	}
+	return ""
+}
+
+// visibleTo indique si le bucket est listé pour l'access key owner : un bucket sans propriétaire
+// enregistré (créé sans authentification ou avant l'enregistrement des propriétaires) est visible de tous
+func visibleTo(info dto.BucketInfo, owner string) bool {
+	return owner == "" || info.Owner == "" || info.Owner == owner
+}
+
+// bucketRegionFromRequest lit la région demandée dans le corps facultatif de CreateBucket. Elle
+// doit correspondre à la région de la signature ; par défaut, c'est cette région qui est retenue.
+func bucketRegionFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	region := defaultRegion
+	if sc := auth.SigningContextFromRequest(r); sc != nil && sc.Region != "" {
+		region = sc.Region
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			writeStorageError(w, r, err)
+			return "", false
+		}
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return region, true
+	}
+
+	var config dto.CreateBucketConfiguration
+	if err := xml.Unmarshal(body, &config); err != nil {
+		s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+		return "", false
+	}
+	if config.LocationConstraint == "" {
+		return region, true
+	}
+	if auth.SigningContextFromRequest(r) != nil && config.LocationConstraint != region {
+		s3errors.WriteError(w, r, s3errors.ErrIllegalLocationConstraint)
+		return "", false
+	}
+	return config.LocationConstraint, true
+}
+
+// writeBucketAlreadyExists signale la création d'un bucket existant, selon qu'il appartient ou non
+// à l'auteur de la requête
+func writeBucketAlreadyExists(w http.ResponseWriter, r *http.Request, s storage.Storage, bucketName string) {
+	info, err := s.GetBucketInfo(bucketName)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	if info.Owner == requestAccessKey(r) {
+		s3errors.WriteError(w, r, s3errors.ErrBucketAlreadyOwnedByYou)
+		return
+	}
+	s3errors.WriteError(w, r, s3errors.ErrBucketAlreadyExists)
+}
+
+// writeBucketLocation renvoie la région enregistrée pour le bucket
+func writeBucketLocation(w http.ResponseWriter, r *http.Request, s storage.Storage, bucketName string) {
+	info, err := s.GetBucketInfo(bucketName)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	region := info.Region
+	if region == "" {
+		region = defaultRegion
+	}
+
+	response, err := xml.Marshal(dto.LocationConstraint{Region: region})
+	if err != nil {
+		log.Printf("Error generating XML response: %v", err)
+		s3errors.WriteError(w, r, s3errors.ErrInternalError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"my-s3-clone/s3errors"
+)
+
+// quoteETag ajoute les guillemets attendus dans les en-têtes ETag
+func quoteETag(etag string) string {
+	return `"` + etag + `"`
+}
+
+// etagListMatches indique si un en-tête If-Match / If-None-Match contient etag.
+// "*" correspond à tout objet existant. Les ETags faibles (W/"...") sont comparés
+// sur leur valeur lorsque weak est vrai, et ne correspondent jamais sinon.
+func etagListMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if strings.Trim(candidate, `"`) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// modifiedSince compare la date de modification (à la seconde près, comme les dates HTTP)
+// à la date d'un en-tête. ok est faux si l'en-tête est absent ou invalide.
+func modifiedSince(header string, lastModified time.Time) (modified, ok bool) {
+	if header == "" {
+		return false, false
+	}
+	t, err := http.ParseTime(header)
+	if err != nil {
+		return false, false
+	}
+	return lastModified.Truncate(time.Second).After(t), true
+}
+
+// checkPreconditions évalue If-Match, If-Unmodified-Since, If-None-Match et If-Modified-Since
+// (RFC 7232, section 6) pour un GET ou un HEAD. Si une condition échoue, la réponse 412 ou 304
+// est écrite et false est renvoyé.
+func checkPreconditions(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !etagListMatches(ifMatch, etag, false) {
+			s3errors.WriteError(w, r, s3errors.ErrPreconditionFailed)
+			return false
+		}
+	} else if modified, ok := modifiedSince(r.Header.Get("If-Unmodified-Since"), lastModified); ok && modified {
+		s3errors.WriteError(w, r, s3errors.ErrPreconditionFailed)
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if etagListMatches(ifNoneMatch, etag, true) {
+			writeNotModified(w)
+			return false
+		}
+	} else if modified, ok := modifiedSince(r.Header.Get("If-Modified-Since"), lastModified); ok && !modified {
+		writeNotModified(w)
+		return false
+	}
+
+	return true
+}
+
+// copySourcePreconditionsMet évalue les en-têtes x-amz-copy-source-if-* d'une copie. Comme sur S3,
+// If-Match l'emporte sur If-Unmodified-Since et If-None-Match sur If-Modified-Since ; la copie
+// échoue avec 412 si l'une des conditions n'est pas remplie.
+func copySourcePreconditionsMet(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifMatch := r.Header.Get("X-Amz-Copy-Source-If-Match"); ifMatch != "" {
+		if !etagListMatches(ifMatch, etag, false) {
+			return false
+		}
+	} else if modified, ok := modifiedSince(r.Header.Get("X-Amz-Copy-Source-If-Unmodified-Since"), lastModified); ok && modified {
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get("X-Amz-Copy-Source-If-None-Match"); ifNoneMatch != "" {
+		if etagListMatches(ifNoneMatch, etag, true) {
+			return false
+		}
+	} else if modified, ok := modifiedSince(r.Header.Get("X-Amz-Copy-Source-If-Modified-Since"), lastModified); ok && !modified {
+		return false
+	}
+	return true
+}
+
+// writeNotModified répond 304 en ne conservant que les en-têtes de validation
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	for _, name := range []string{"Content-Type", "Content-Length", "Content-Disposition", "Accept-Ranges"} {
+		h.Del(name)
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
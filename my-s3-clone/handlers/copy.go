@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/policy"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// parseCopySource décode l'en-tête x-amz-copy-source ("/bucket/key" ou "bucket/key", encodé en URL,
+// éventuellement suivi de "?versionId=ID")
+func parseCopySource(header string) (bucket, key, versionID string, ok bool) {
+	path, query, _ := strings.Cut(header, "?")
+	if query != "" {
+		values, err := url.ParseQuery(query)
+		if err != nil || values.Get("versionId") == "" {
+			return "", "", "", false
+		}
+		versionID = values.Get("versionId")
+	}
+	source, err := url.PathUnescape(path)
+	if err != nil {
+		return "", "", "", false
+	}
+	bucket, key, found := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if !found || bucket == "" || key == "" {
+		return "", "", "", false
+	}
+	return bucket, key, versionID, true
+}
+
+// copySource est l'objet (ou la version) lu par une copie
+type copySource struct {
+	bucket    string
+	key       string
+	versionID string
+	info      dto.ObjectInfo
+	// objects lit la source avec la clé SSE-C des en-têtes x-amz-copy-source-*
+	objects storage.Storage
+}
+
+// openCopySource vérifie que l'auteur de la requête peut lire la source désignée par x-amz-copy-source.
+// Sinon, l'erreur est écrite et ok est faux.
+func openCopySource(w http.ResponseWriter, r *http.Request, s storage.Storage) (source copySource, ok bool) {
+	source.bucket, source.key, source.versionID, ok = parseCopySource(r.Header.Get("X-Amz-Copy-Source"))
+	if !ok {
+		s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("Copy Source must mention the source bucket and key: sourcebucket/sourcekey"))
+		return source, false
+	}
+
+	// La source chiffrée en SSE-C se lit avec les en-têtes x-amz-copy-source-*
+	sourceKeyMaterial, err := customerKeyFromRequest(r, copySourceSSEPrefix)
+	if err != nil {
+		s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+		return source, false
+	}
+
+	// La copie de la source exige le droit de la lire
+	action := policy.GetObject
+	if source.versionID != "" {
+		action = policy.GetObjectVersion
+	}
+	if !authorized(w, r, s, action, source.bucket, source.key, source.versionID) {
+		return source, false
+	}
+	source.objects = storage.WithCustomerKey(s, sourceKeyMaterial)
+	return source, true
+}
+
+// hasCopySourceConditions indique si la requête porte un en-tête x-amz-copy-source-if-*
+func hasCopySourceConditions(r *http.Request) bool {
+	for _, name := range []string{"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+		if r.Header.Get("X-Amz-Copy-Source-"+name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCopySource traduit l'erreur de lecture de la source d'une copie et évalue les conditions
+// x-amz-copy-source-if-* sur ses informations. Sinon, l'erreur est écrite et false est renvoyé.
+func checkCopySource(w http.ResponseWriter, r *http.Request, source *copySource, info dto.ObjectInfo, err error) bool {
+	if err != nil {
+		if errors.Is(err, storage.ErrDeleteMarker) {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidRequest.WithMessage("The source of a copy request may not specifically refer to a delete marker by version id."))
+		} else {
+			writeStorageError(w, r, err)
+		}
+		return false
+	}
+	source.info = info
+	if !copySourcePreconditionsMet(r, info.ETag, info.LastModified) {
+		s3errors.WriteError(w, r, s3errors.ErrPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// setCopySourceVersionIDHeader renseigne x-amz-copy-source-version-id si la version copiée est connue
+func setCopySourceVersionIDHeader(w http.ResponseWriter, source copySource) {
+	versionID := source.versionID
+	if versionID == "" {
+		versionID = source.info.VersionID
+	}
+	if versionID != "" {
+		w.Header().Set("x-amz-copy-source-version-id", versionID)
+	}
+}
+
+// Copy an object or one of its versions (PUT /{bucket}/{key} with x-amz-copy-source)
+func HandleCopyObject(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucketName := vars["bucketName"]
+		objectName := vars["objectName"]
+
+		// La copie est chiffrée selon les en-têtes x-amz-server-side-encryption*
+		encryption, err := encryptionFromRequest(r)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+		lock, err := objectLockFromRequest(r)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+		acl, err := cannedACLFromRequest(r)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+
+		// Comme les métadonnées, les tags sont copiés depuis la source (COPY, par défaut) ou remplacés
+		// par ceux de l'en-tête x-amz-tagging (REPLACE)
+		var tags map[string]string
+		replaceMetadata := r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE"
+		replaceTags := false
+		switch r.Header.Get(taggingDirectiveHeader) {
+		case "", "COPY":
+		case "REPLACE":
+			replaceTags = true
+			if tags, err = tagsFromRequest(r); err != nil {
+				writeTaggingError(w, r, err)
+				return
+			}
+		default:
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("Unknown tagging directive."))
+			return
+		}
+
+		source, ok := openCopySource(w, r, s)
+		if !ok {
+			return
+		}
+		// La source n'est lue à part que si les conditions ou les métadonnées de la copie l'exigent,
+		// y compris quand seuls les métadonnées ou seuls les tags sont remplacés
+		if hasCopySourceConditions(r) || encryption != nil || lock != nil || acl != "" || replaceMetadata != replaceTags {
+			info, err := source.objects.StatObject(source.bucket, source.key, source.versionID)
+			if !checkCopySource(w, r, &source, info, err) {
+				return
+			}
+		}
+
+		// COPY (par défaut) conserve les métadonnées de la source, REPLACE utilise celles de la requête.
+		// Sans en-tête de chiffrement, COPY conserve aussi le chiffrement de la source. Le verrouillage
+		// et l'ACL de la source ne sont jamais copiés : la copie a ceux de la requête.
+		var metadata *dto.ObjectMetadata
+		switch directive := r.Header.Get("X-Amz-Metadata-Directive"); directive {
+		case "", "COPY":
+			if source.bucket == bucketName && source.key == objectName && source.versionID == "" && encryption == nil && lock == nil {
+				s3errors.WriteError(w, r, s3errors.ErrInvalidRequest.WithMessage("This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes."))
+				return
+			}
+			if encryption != nil || lock != nil || acl != "" || replaceTags {
+				replacement := source.info.ObjectMetadata
+				replacement.Encryption = encryption
+				replacement.Lock = lock
+				replacement.ACL = acl
+				if replaceTags {
+					replacement.Tags = tags
+				}
+				metadata = &replacement
+			}
+		case "REPLACE":
+			replacement, err := objectMetadataFromRequest(r)
+			if err != nil {
+				s3errors.WriteError(w, r, s3errors.ErrMetadataTooLarge)
+				return
+			}
+			replacement.Encryption = encryption
+			replacement.Lock = lock
+			replacement.ACL = acl
+			replacement.Tags = source.info.Tags
+			if replaceTags {
+				replacement.Tags = tags
+			}
+			metadata = &replacement
+		default:
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("Unknown metadata directive."))
+			return
+		}
+
+		log.Printf("Copying object %s/%s to %s/%s", source.bucket, source.key, bucketName, objectName)
+
+		info, err := source.objects.CopyObject(source.bucket, source.key, source.versionID, bucketName, objectName, metadata)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		setVersionIDHeader(w, info.VersionID)
+		setCopySourceVersionIDHeader(w, source)
+		setEncryptionHeaders(w, info.Encryption)
+		setObjectLockHeaders(w, info.Lock)
+		writeXML(w, dto.CopyObjectResult{
+			Xmlns:        dto.S3Namespace,
+			ETag:         quoteETag(info.ETag),
+			LastModified: info.LastModified.UTC(),
+		})
+	}
+}
+
+// Copy an object, or a range of it, into a part of a multipart upload
+// (PUT /{bucket}/{key}?partNumber=N&uploadId=ID with x-amz-copy-source)
+func HandleUploadPartCopy(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucketName := vars["bucketName"]
+		objectName := vars["objectName"]
+		uploadID := vars["uploadId"]
+
+		partNumber, err := strconv.Atoi(vars["partNumber"])
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("Part number must be an integer between 1 and 10000, inclusive"))
+			return
+		}
+
+		// The parts of an SSE-C upload must be sent with the key given when the upload was created
+		customerKey, err := customerKeyFromRequest(r, objectSSEPrefix)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+		objects := storage.WithCustomerKey(s, customerKey)
+
+		source, ok := openCopySource(w, r, s)
+		if !ok {
+			return
+		}
+
+		reader, info, err := source.objects.GetObject(source.bucket, source.key, source.versionID)
+		if err == nil {
+			defer reader.Close()
+		}
+		if !checkCopySource(w, r, &source, info, err) {
+			return
+		}
+
+		var data io.Reader = reader
+		if header := r.Header.Get("X-Amz-Copy-Source-Range"); header != "" {
+			br, err := parseCopySourceRange(header, source.info.Size)
+			if errors.Is(err, errRangeNotSatisfiable) {
+				s3errors.WriteError(w, r, s3errors.ErrInvalidRange)
+				return
+			} else if err != nil {
+				s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy"))
+				return
+			}
+			if _, err := reader.Seek(br.start, io.SeekStart); err != nil {
+				writeStorageError(w, r, err)
+				return
+			}
+			data = io.LimitReader(reader, br.length)
+		}
+
+		log.Printf("Copying %s/%s to part %d of upload %s for %s/%s", source.bucket, source.key, partNumber, uploadID, bucketName, objectName)
+
+		etag, err := objects.UploadPart(bucketName, objectName, uploadID, partNumber, data)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		if metadata, err := objects.MultipartUploadMetadata(bucketName, objectName, uploadID); err == nil {
+			setEncryptionHeaders(w, metadata.Encryption)
+		}
+		setCopySourceVersionIDHeader(w, source)
+		writeXML(w, dto.CopyPartResult{
+			Xmlns:        dto.S3Namespace,
+			ETag:         quoteETag(etag),
+			LastModified: time.Now().UTC(),
+		})
+	}
+}
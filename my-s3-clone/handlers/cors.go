@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/cors"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// HandlePutBucketCors replaces the CORS rules of a bucket (PUT /{bucket}/?cors)
+func HandlePutBucketCors(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketName := mux.Vars(r)["bucketName"]
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInternalError)
+			return
+		}
+
+		var config dto.CORSConfiguration
+		if err := xml.Unmarshal(body, &config); err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+		if err := cors.Validate(config); err != nil {
+			var configErr *cors.ConfigError
+			if errors.As(err, &configErr) && configErr.Malformed {
+				s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+			} else {
+				s3errors.WriteError(w, r, s3errors.ErrInvalidRequest.WithMessage(err.Error()))
+			}
+			return
+		}
+
+		if err := s.PutBucketCors(bucketName, config); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleGetBucketCors returns the CORS rules of a bucket (GET /{bucket}/?cors)
+func HandleGetBucketCors(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config, err := s.GetBucketCors(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		config.Xmlns = dto.S3Namespace
+		writeXML(w, config)
+	}
+}
+
+// HandleDeleteBucketCors removes the CORS rules of a bucket (DELETE /{bucket}/?cors)
+func HandleDeleteBucketCors(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.DeleteBucketCors(mux.Vars(r)["bucketName"]); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleCORSPreflight answers a CORS preflight request with the rules of the target bucket
+// (OPTIONS /{bucket}/{key})
+func HandleCORSPreflight(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		method := r.Header.Get("Access-Control-Request-Method")
+		if origin == "" || method == "" {
+			s3errors.WriteError(w, r, s3errors.ErrBadRequest)
+			return
+		}
+
+		config, err := s.GetBucketCors(mux.Vars(r)["bucketName"])
+		if errors.Is(err, storage.ErrNoSuchCORS) {
+			s3errors.WriteError(w, r, s3errors.ErrCORSForbidden.WithMessage("CORSResponse: CORS is not enabled for this bucket."))
+			return
+		} else if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		headers := cors.ParseRequestHeaders(r.Header.Get("Access-Control-Request-Headers"))
+		rule := cors.Match(config, origin, method, headers)
+		if rule == nil {
+			s3errors.WriteError(w, r, s3errors.ErrCORSForbidden)
+			return
+		}
+		cors.SetPreflightHeaders(w.Header(), rule, origin, headers)
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// En-têtes du chiffrement côté serveur. Les en-têtes SSE-C existent en deux variantes : préfixés
+// par "x-amz-" pour l'objet de la requête, par "x-amz-copy-source-" pour la source d'une copie.
+const (
+	sseHeader                  = "X-Amz-Server-Side-Encryption"
+	sseCustomerAlgorithmHeader = "Server-Side-Encryption-Customer-Algorithm"
+	sseCustomerKeyHeader       = "Server-Side-Encryption-Customer-Key"
+	sseCustomerKeyMD5Header    = "Server-Side-Encryption-Customer-Key-Md5"
+
+	objectSSEPrefix     = "X-Amz-"
+	copySourceSSEPrefix = "X-Amz-Copy-Source-"
+)
+
+// encryptionFromRequest lit le chiffrement demandé pour l'objet écrit : SSE-S3
+// (x-amz-server-side-encryption: AES256) ou SSE-C. Renvoie nil si aucun n'est demandé.
+func encryptionFromRequest(r *http.Request) (*dto.Encryption, error) {
+	algorithm := r.Header.Get(sseHeader)
+	key, err := customerKeyFromRequest(r, objectSSEPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case key != nil && algorithm != "":
+		return nil, errors.New("Server Side Encryption with Customer provided key is incompatible with the encryption method specified")
+	case key != nil:
+		return &dto.Encryption{Algorithm: dto.EncryptionAES256, CustomerKey: key, CustomerKeyMD5: storage.CustomerKeyMD5(key)}, nil
+	case algorithm == "":
+		return nil, nil
+	case algorithm != dto.EncryptionAES256:
+		return nil, errors.New("The encryption method specified is not supported")
+	}
+	return &dto.Encryption{Algorithm: dto.EncryptionAES256}, nil
+}
+
+// customerKeyFromRequest lit la clé SSE-C des en-têtes commençant par prefix. Renvoie nil si la
+// requête n'en fournit pas.
+func customerKeyFromRequest(r *http.Request, prefix string) ([]byte, error) {
+	algorithm := r.Header.Get(prefix + sseCustomerAlgorithmHeader)
+	encodedKey := r.Header.Get(prefix + sseCustomerKeyHeader)
+	keyMD5 := r.Header.Get(prefix + sseCustomerKeyMD5Header)
+	if algorithm == "" && encodedKey == "" && keyMD5 == "" {
+		return nil, nil
+	}
+
+	if algorithm != dto.EncryptionAES256 {
+		return nil, errors.New("Requests specifying Server Side Encryption with Customer provided keys must provide a valid encryption algorithm.")
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != storage.EncryptionKeySize {
+		return nil, errors.New("The secret key was invalid for the specified algorithm.")
+	}
+	if keyMD5 != storage.CustomerKeyMD5(key) {
+		return nil, errors.New("The calculated MD5 hash of the key did not match the hash that was provided.")
+	}
+	return key, nil
+}
+
+// setEncryptionHeaders indique dans la réponse comment l'objet est chiffré
+func setEncryptionHeaders(w http.ResponseWriter, encryption *dto.Encryption) {
+	switch {
+	case encryption == nil:
+	case encryption.CustomerProvided():
+		w.Header().Set(objectSSEPrefix+sseCustomerAlgorithmHeader, encryption.Algorithm)
+		w.Header().Set(objectSSEPrefix+sseCustomerKeyMD5Header, encryption.CustomerKeyMD5)
+	default:
+		w.Header().Set(sseHeader, encryption.Algorithm)
+	}
+}
+
+// HandlePutBucketEncryption sets the default encryption of a bucket, applied to the objects written
+// without encryption headers (PUT /{bucket}/?encryption). Only AES256 (SSE-S3) is supported.
+func HandlePutBucketEncryption(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInternalError)
+			return
+		}
+
+		var config dto.ServerSideEncryptionConfiguration
+		if err := xml.Unmarshal(body, &config); err != nil || len(config.Rules) != 1 {
+			s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+		if rule := config.Rules[0].ApplyServerSideEncryptionByDefault; rule.SSEAlgorithm != dto.EncryptionAES256 || rule.KMSMasterKeyID != "" {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("The only supported default encryption is AES256, without KMS key."))
+			return
+		}
+
+		if err := s.PutBucketEncryption(mux.Vars(r)["bucketName"], config); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleGetBucketEncryption returns the default encryption of a bucket (GET /{bucket}/?encryption)
+func HandleGetBucketEncryption(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config, err := s.GetBucketEncryption(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		config.Xmlns = dto.S3Namespace
+		writeXML(w, config)
+	}
+}
+
+// HandleDeleteBucketEncryption removes the default encryption of a bucket; objects already
+// encrypted stay encrypted (DELETE /{bucket}/?encryption)
+func HandleDeleteBucketEncryption(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.DeleteBucketEncryption(mux.Vars(r)["bucketName"]); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/checksum"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// writeStorageError traduit une erreur de la couche de stockage en erreur S3
+func writeStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	s3errors.WriteError(w, r, storageAPIError(err))
+}
+
+// storageAPIError renvoie l'erreur S3 correspondant à une erreur de la couche de stockage, pour
+// une réponse d'erreur ou l'élément <Error> d'une opération par lot
+func storageAPIError(err error) s3errors.APIError {
+	// Erreurs levées pendant la lecture du corps (signature de chunk invalide, flux mal formé)
+	if apiErr, ok := auth.ToAPIError(err); ok {
+		return apiErr
+	}
+
+	switch {
+	case errors.Is(err, checksum.ErrBadDigest):
+		return s3errors.ErrBadDigest
+	case errors.Is(err, checksum.ErrChecksumMismatch):
+		var mismatch *checksum.MismatchError
+		errors.As(err, &mismatch)
+		return s3errors.ErrBadDigest.WithMessage(mismatch.Error())
+	case errors.Is(err, checksum.ErrContentSHA256Mismatch):
+		return s3errors.ErrContentSHA256Mismatch
+	case errors.Is(err, checksum.ErrIncompleteBody):
+		return s3errors.ErrIncompleteBody
+	case errors.Is(err, storage.ErrNoSuchBucket):
+		return s3errors.ErrNoSuchBucket
+	case errors.Is(err, storage.ErrNoSuchUpload):
+		return s3errors.ErrNoSuchUpload
+	case errors.Is(err, storage.ErrInvalidPart):
+		return s3errors.ErrInvalidPart
+	case errors.Is(err, storage.ErrInvalidPartOrder):
+		return s3errors.ErrInvalidPartOrder
+	case errors.Is(err, storage.ErrInvalidPartNumber):
+		return s3errors.ErrInvalidArgument.WithMessage("Part number must be an integer between 1 and 10000, inclusive")
+	case errors.Is(err, storage.ErrEntityTooSmall):
+		return s3errors.ErrEntityTooSmall
+	case errors.Is(err, storage.ErrNoSuchVersion):
+		return s3errors.ErrNoSuchVersion
+	case errors.Is(err, storage.ErrInvalidVersioning):
+		return s3errors.ErrMalformedXML
+	case errors.Is(err, storage.ErrInvalidBucketName):
+		return s3errors.ErrInvalidBucketName
+	case errors.Is(err, storage.ErrInvalidObjectName):
+		return s3errors.ErrInvalidArgument.WithMessage("Object key is not valid: it must not be empty, longer than 1024 bytes, or contain empty, \".\" or \"..\" segments.")
+	case errors.Is(err, storage.ErrKeyConflict):
+		return s3errors.ErrInvalidRequest.WithMessage("The object key conflicts with an existing key used as a folder, or with a folder used as a key.")
+	case errors.Is(err, storage.ErrMoveOntoItself):
+		return s3errors.ErrInvalidRequest.WithMessage("The source and target of a move must be different objects.")
+	case errors.Is(err, storage.ErrNoSuchLifecycle):
+		return s3errors.ErrNoSuchLifecycleConfiguration
+	case errors.Is(err, storage.ErrNoSuchCORS):
+		return s3errors.ErrNoSuchCORSConfiguration
+	case errors.Is(err, storage.ErrNoSuchBucketPolicy):
+		return s3errors.ErrNoSuchBucketPolicy
+	case errors.Is(err, storage.ErrNoIntegrityReport):
+		return s3errors.ErrNoSuchIntegrityReport
+	case errors.Is(err, storage.ErrQuotaExceeded):
+		return s3errors.ErrQuotaExceeded
+	case errors.Is(err, storage.ErrNoSuchObjectLockConfiguration):
+		return s3errors.ErrObjectLockConfigurationNotFound
+	case errors.Is(err, storage.ErrObjectLockNotEnabled):
+		return s3errors.ErrInvalidRequest.WithMessage("Bucket is missing Object Lock Configuration.")
+	case errors.Is(err, storage.ErrObjectLocked):
+		return s3errors.ErrAccessDenied.WithMessage("Access Denied because object protected by object lock.")
+	case errors.Is(err, storage.ErrNoSuchEncryptionConfiguration):
+		return s3errors.ErrNoSuchEncryptionConfiguration
+	case errors.Is(err, storage.ErrInvalidEncryption):
+		return s3errors.ErrInvalidArgument.WithMessage("The server-side encryption parameters are not valid.")
+	case errors.Is(err, storage.ErrEncryptionNotConfigured):
+		return s3errors.ErrNotImplemented.WithMessage("Server-side encryption with server-managed keys is not configured on this server.")
+	case errors.Is(err, storage.ErrEncryptionNotApplicable):
+		return s3errors.ErrInvalidRequest.WithMessage("The encryption parameters are not applicable to this object.")
+	case errors.Is(err, storage.ErrCustomerKeyRequired):
+		return s3errors.ErrInvalidRequest.WithMessage("The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.")
+	case errors.Is(err, storage.ErrCustomerKeyMismatch):
+		return s3errors.ErrAccessDenied.WithMessage("The provided customer key does not match the key used to encrypt the object.")
+	case errors.Is(err, os.ErrNotExist):
+		return s3errors.ErrNoSuchKey
+	default:
+		log.Printf("Storage error: %v", err)
+		return s3errors.ErrInternalError
+	}
+}
+
+// requestPayload renvoie le corps de la requête. Les payloads aws-chunked sont décodés ici,
+// et la signature de chaque chunk est vérifiée si la requête a été authentifiée. Le contenu est
+// vérifié pendant sa lecture selon les en-têtes Content-MD5, x-amz-content-sha256 et x-amz-checksum-* ;
+// si un en-tête est invalide, l'erreur S3 est écrite et ok vaut false.
+func requestPayload(w http.ResponseWriter, r *http.Request) (payload *checksum.Reader, ok bool) {
+	var body io.Reader = r.Body
+	if r.Header.Get("X-Amz-Content-Sha256") == auth.StreamingPayload {
+		body = auth.NewChunkedReader(r.Body, auth.SigningContextFromRequest(r))
+	}
+
+	payload, err := checksum.NewReader(body, r.Header)
+	switch {
+	case err == nil:
+		return payload, true
+	case errors.Is(err, checksum.ErrInvalidDigest):
+		s3errors.WriteError(w, r, s3errors.ErrInvalidDigest)
+	case errors.Is(err, checksum.ErrInvalidChecksum):
+		s3errors.WriteError(w, r, s3errors.ErrInvalidRequest.WithMessage(err.Error()))
+	default:
+		s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+	}
+	return nil, false
+}
+
+// setChecksumHeaders renvoie au client les sommes de contrôle x-amz-checksum-* vérifiées
+func setChecksumHeaders(w http.ResponseWriter, payload *checksum.Reader) {
+	for name, value := range payload.Checksums() {
+		w.Header().Set(name, value)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/scrub"
+)
+
+// errNoScrubber répond aux requêtes d'intégrité d'un backend qui ne conserve pas d'empreintes
+var errNoScrubber = s3errors.ErrNotImplemented.WithMessage("The storage backend does not support integrity checks.")
+
+// HandleGetIntegrityReport returns the report of the last integrity scrub: corrupt and missing
+// objects, and objects stored without a checksum (GET /?integrity)
+func HandleGetIntegrityReport(sc *scrub.Scrubber) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if sc == nil {
+			s3errors.WriteError(w, r, errNoScrubber)
+			return
+		}
+		report, err := sc.Report()
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		report.Xmlns = dto.S3Namespace
+		writeXML(w, report)
+	}
+}
+
+// HandleStartIntegrityScrub starts an integrity scrub in the background (POST /?integrity).
+// Its report is available from GET /?integrity once it has finished.
+func HandleStartIntegrityScrub(sc *scrub.Scrubber) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if sc == nil {
+			s3errors.WriteError(w, r, errNoScrubber)
+			return
+		}
+		// Le passage survit à la requête qui l'a lancé
+		if err := sc.Start(context.Background()); err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrOperationAborted.WithMessage("An integrity scrub is already running."))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
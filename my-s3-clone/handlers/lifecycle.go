@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/lifecycle"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// HandlePutBucketLifecycle replaces the lifecycle rules of a bucket (PUT /{bucket}/?lifecycle)
+func HandlePutBucketLifecycle(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketName := mux.Vars(r)["bucketName"]
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInternalError)
+			return
+		}
+
+		var config dto.LifecycleConfiguration
+		if err := xml.Unmarshal(body, &config); err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+		if err := lifecycle.Validate(config); err != nil {
+			var configErr *lifecycle.ConfigError
+			if errors.As(err, &configErr) && configErr.Malformed {
+				s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+			} else {
+				s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			}
+			return
+		}
+
+		if err := s.PutBucketLifecycle(bucketName, config); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleGetBucketLifecycle returns the lifecycle rules of a bucket (GET /{bucket}/?lifecycle)
+func HandleGetBucketLifecycle(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config, err := s.GetBucketLifecycle(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		config.Xmlns = dto.S3Namespace
+		writeXML(w, config)
+	}
+}
+
+// HandleDeleteBucketLifecycle removes the lifecycle rules of a bucket (DELETE /{bucket}/?lifecycle)
+func HandleDeleteBucketLifecycle(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.DeleteBucketLifecycle(mux.Vars(r)["bucketName"]); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/auth"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// Propriétaire renvoyé quand l'authentification est désactivée
+const anonymousOwner = "anonymous"
+
+var errInvalidEncodingType = errors.New("invalid encoding type")
+
+// listParams lit les paramètres communs à ListObjects v1 et v2
+func listParams(r *http.Request) (maxKeys int, encodingType string, err error) {
+	maxKeys, err = parseIntParam(r, "max-keys", storage.MaxListKeys)
+	if err != nil || maxKeys < 0 {
+		return 0, "", errors.New("max-keys must be a non-negative integer")
+	}
+	// Comme S3, une page ne contient jamais plus de 1000 clés
+	if maxKeys > storage.MaxListKeys {
+		maxKeys = storage.MaxListKeys
+	}
+
+	encodingType = r.URL.Query().Get("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		return 0, "", errInvalidEncodingType
+	}
+	return maxKeys, encodingType, nil
+}
+
+func writeListParamsError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errInvalidEncodingType) {
+		s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("Invalid Encoding Method specified in Request"))
+		return
+	}
+	s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+}
+
+// s3URLEncode encode une clé pour encoding-type=url, en conservant les "/" comme S3
+func s3URLEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "%2F", "/")
+}
+
+// encodeListing applique encoding-type=url aux clés et préfixes d'un listing
+func encodeListing(contents []dto.Object, prefixes []dto.CommonPrefix) {
+	for i := range contents {
+		contents[i].Key = s3URLEncode(contents[i].Key)
+	}
+	for i := range prefixes {
+		prefixes[i].Prefix = s3URLEncode(prefixes[i].Prefix)
+	}
+}
+
+// Le jeton de continuation est opaque pour le client : c'est la dernière clé (ou le dernier
+// préfixe commun) renvoyé, encodé en base64
+func encodeContinuationToken(marker string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(marker))
+}
+
+func decodeContinuationToken(token string) (string, bool) {
+	marker, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(marker) == 0 {
+		return "", false
+	}
+	return string(marker), true
+}
+
+// requestOwner renvoie le propriétaire associé à la clé d'accès qui a signé la requête
+func requestOwner(r *http.Request) dto.Owner {
+	if sc := auth.SigningContextFromRequest(r); sc != nil {
+		return dto.Owner{ID: sc.AccessKey, DisplayName: sc.AccessKey}
+	}
+	return dto.Owner{ID: anonymousOwner, DisplayName: anonymousOwner}
+}
+
+// List objects in a bucket (GET /{bucket}?list-type=2)
+func HandleListObjectsV2(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketName := mux.Vars(r)["bucketName"]
+		query := r.URL.Query()
+
+		maxKeys, encodingType, err := listParams(r)
+		if err != nil {
+			writeListParamsError(w, r, err)
+			return
+		}
+
+		// Le jeton de continuation prime sur start-after
+		token := query.Get("continuation-token")
+		marker := query.Get("start-after")
+		if _, present := query["continuation-token"]; present {
+			var ok bool
+			if marker, ok = decodeContinuationToken(token); !ok {
+				s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("The continuation token provided is incorrect"))
+				return
+			}
+		}
+
+		listing, err := s.ListObjects(bucketName, query.Get("prefix"), query.Get("delimiter"), marker, maxKeys)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		result := dto.ListBucketV2Result{
+			Xmlns:             dto.S3Namespace,
+			Name:              bucketName,
+			Prefix:            listing.Prefix,
+			Delimiter:         listing.Delimiter,
+			StartAfter:        query.Get("start-after"),
+			ContinuationToken: token,
+			MaxKeys:           maxKeys,
+			KeyCount:          len(listing.Contents) + len(listing.CommonPrefixes),
+			EncodingType:      encodingType,
+			IsTruncated:       listing.IsTruncated,
+			Contents:          listing.Contents,
+			CommonPrefixes:    listing.CommonPrefixes,
+		}
+		if listing.IsTruncated {
+			result.NextContinuationToken = encodeContinuationToken(listing.NextMarker)
+		}
+
+		if query.Get("fetch-owner") == "true" {
+			owner := requestOwner(r)
+			for i := range result.Contents {
+				result.Contents[i].Owner = &owner
+			}
+		}
+
+		if encodingType == "url" {
+			result.Prefix = s3URLEncode(result.Prefix)
+			result.Delimiter = s3URLEncode(result.Delimiter)
+			result.StartAfter = s3URLEncode(result.StartAfter)
+			encodeListing(result.Contents, result.CommonPrefixes)
+		}
+
+		writeXML(w, result)
+	}
+}
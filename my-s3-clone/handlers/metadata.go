@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"my-s3-clone/dto"
+)
+
+// Préfixe des en-têtes de métadonnées utilisateur
+const userMetadataPrefix = "x-amz-meta-"
+
+// Taille maximale des métadonnées utilisateur (noms et valeurs), comme S3
+const maxUserMetadataSize = 2 << 10
+
+var errMetadataTooLarge = errors.New("user metadata too large")
+
+// objectMetadataFromRequest extrait les métadonnées à enregistrer avec l'objet
+func objectMetadataFromRequest(r *http.Request) (dto.ObjectMetadata, error) {
+	metadata := dto.ObjectMetadata{
+		ContentType:        r.Header.Get("Content-Type"),
+		ContentDisposition: r.Header.Get("Content-Disposition"),
+		CacheControl:       r.Header.Get("Cache-Control"),
+	}
+
+	size := 0
+	for name, values := range r.Header {
+		lower := strings.ToLower(name)
+		if !strings.HasPrefix(lower, userMetadataPrefix) || len(values) == 0 {
+			continue
+		}
+		key := strings.TrimPrefix(lower, userMetadataPrefix)
+		value := strings.Join(values, ",")
+		size += len(key) + len(value)
+
+		if metadata.UserMetadata == nil {
+			metadata.UserMetadata = make(map[string]string)
+		}
+		metadata.UserMetadata[key] = value
+	}
+	if size > maxUserMetadataSize {
+		return metadata, errMetadataTooLarge
+	}
+	return metadata, nil
+}
+
+// setObjectMetadataHeaders renvoie les métadonnées enregistrées dans les en-têtes de la réponse
+func setObjectMetadataHeaders(w http.ResponseWriter, objectName string, metadata dto.ObjectMetadata) {
+	h := w.Header()
+
+	contentType := metadata.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h.Set("Content-Type", contentType)
+
+	if metadata.ContentDisposition != "" {
+		h.Set("Content-Disposition", metadata.ContentDisposition)
+	} else {
+		// Le nom proposé est le dernier segment de la clé ; les caractères spéciaux sont encodés (RFC 2231)
+		disposition := mime.FormatMediaType("inline", map[string]string{"filename": path.Base(objectName)})
+		if disposition == "" {
+			disposition = "inline"
+		}
+		h.Set("Content-Disposition", disposition)
+	}
+	if metadata.CacheControl != "" {
+		h.Set("Cache-Control", metadata.CacheControl)
+	}
+	for key, value := range metadata.UserMetadata {
+		h.Set(userMetadataPrefix+key, value)
+	}
+	setTaggingCountHeader(w, metadata.Tags)
+}
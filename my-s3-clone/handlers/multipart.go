@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// writeXML encode une réponse XML avec le statut 200
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding XML response: %v", err)
+	}
+}
+
+// parseIntParam lit un paramètre entier optionnel de la query string
+func parseIntParam(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s value: %q", name, value)
+	}
+	return n, nil
+}
+
+// Start a multipart upload (POST /{bucket}/{key}?uploads)
+func HandleCreateMultipartUpload(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucketName := vars["bucketName"]
+		objectName := vars["objectName"]
+
+		log.Printf("Creating multipart upload for %s/%s", bucketName, objectName)
+
+		metadata, err := objectMetadataFromRequest(r)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrMetadataTooLarge)
+			return
+		}
+		metadata.Encryption, err = encryptionFromRequest(r)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+		metadata.Lock, err = objectLockFromRequest(r)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+		metadata.ACL, err = cannedACLFromRequest(r)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+		metadata.Tags, err = tagsFromRequest(r)
+		if err != nil {
+			writeTaggingError(w, r, err)
+			return
+		}
+
+		uploadID, err := s.CreateMultipartUpload(bucketName, objectName, metadata)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		setEncryptionHeaders(w, metadata.Encryption)
+
+		writeXML(w, dto.InitiateMultipartUploadResult{
+			Xmlns:    dto.S3Namespace,
+			Bucket:   bucketName,
+			Key:      objectName,
+			UploadId: uploadID,
+		})
+	}
+}
+
+// Upload one part (PUT /{bucket}/{key}?partNumber=N&uploadId=ID)
+func HandleUploadPart(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucketName := vars["bucketName"]
+		objectName := vars["objectName"]
+		uploadID := vars["uploadId"]
+
+		partNumber, err := strconv.Atoi(vars["partNumber"])
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("Part number must be an integer between 1 and 10000, inclusive"))
+			return
+		}
+
+		log.Printf("Uploading part %d of upload %s for %s/%s", partNumber, uploadID, bucketName, objectName)
+
+		// The parts of an SSE-C upload must be sent with the key given when the upload was created
+		customerKey, err := customerKeyFromRequest(r, objectSSEPrefix)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+		objects := storage.WithCustomerKey(s, customerKey)
+
+		payload, ok := requestPayload(w, r)
+		if !ok {
+			return
+		}
+		etag, err := objects.UploadPart(bucketName, objectName, uploadID, partNumber, payload)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		if metadata, err := objects.MultipartUploadMetadata(bucketName, objectName, uploadID); err == nil {
+			setEncryptionHeaders(w, metadata.Encryption)
+		}
+		setChecksumHeaders(w, payload)
+		w.Header().Set("ETag", `"`+etag+`"`)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Assemble the uploaded parts (POST /{bucket}/{key}?uploadId=ID)
+func HandleCompleteMultipartUpload(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucketName := vars["bucketName"]
+		objectName := vars["objectName"]
+		uploadID := vars["uploadId"]
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInternalError)
+			return
+		}
+
+		var completeReq dto.CompleteMultipartUpload
+		if err := xml.Unmarshal(body, &completeReq); err != nil || len(completeReq.Parts) == 0 {
+			s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+
+		log.Printf("Completing multipart upload %s for %s/%s with %d parts", uploadID, bucketName, objectName, len(completeReq.Parts))
+
+		info, err := s.CompleteMultipartUpload(bucketName, objectName, uploadID, completeReq.Parts)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+
+		setVersionIDHeader(w, info.VersionID)
+		setEncryptionHeaders(w, info.Encryption)
+		setObjectLockHeaders(w, info.Lock)
+		w.Header().Set("ETag", quoteETag(info.ETag))
+		writeXML(w, dto.CompleteMultipartUploadResult{
+			Xmlns:    dto.S3Namespace,
+			Location: fmt.Sprintf("%s://%s/%s/%s", scheme, r.Host, bucketName, objectName),
+			Bucket:   bucketName,
+			Key:      objectName,
+			ETag:     quoteETag(info.ETag),
+		})
+	}
+}
+
+// Abort a multipart upload (DELETE /{bucket}/{key}?uploadId=ID)
+func HandleAbortMultipartUpload(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		if err := s.AbortMultipartUpload(vars["bucketName"], vars["objectName"], vars["uploadId"]); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// List the parts already uploaded (GET /{bucket}/{key}?uploadId=ID)
+func HandleListParts(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		partNumberMarker, err := parseIntParam(r, "part-number-marker", 0)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+		maxParts, err := parseIntParam(r, "max-parts", 1000)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+
+		result, err := s.ListParts(vars["bucketName"], vars["objectName"], vars["uploadId"], partNumberMarker, maxParts)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		writeXML(w, result)
+	}
+}
+
+// List the multipart uploads in progress (GET /{bucket}/?uploads)
+func HandleListMultipartUploads(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		query := r.URL.Query()
+
+		maxUploads, err := parseIntParam(r, "max-uploads", 1000)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+
+		result, err := s.ListMultipartUploads(vars["bucketName"], query.Get("prefix"), query.Get("key-marker"), query.Get("upload-id-marker"), maxUploads)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		writeXML(w, result)
+	}
+}
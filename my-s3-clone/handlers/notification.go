@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/notify"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// validateNotification vérifie les webhooks d'une configuration de notification : une URL
+// http(s), au moins un type d'événement connu, et au plus une règle prefix et une règle suffix
+func validateNotification(config dto.NotificationConfiguration) error {
+	for _, webhook := range config.Webhooks {
+		endpoint, err := url.Parse(webhook.Endpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return fmt.Errorf("The webhook endpoint %q is not a valid http or https URL", webhook.Endpoint)
+		}
+		if len(webhook.Events) == 0 {
+			return errors.New("A webhook configuration must specify at least one event")
+		}
+		for _, event := range webhook.Events {
+			if !notify.ValidEvent(event) {
+				return fmt.Errorf("The event %q is not supported for notifications", event)
+			}
+		}
+		if webhook.Filter == nil {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, rule := range webhook.Filter.Key.Rules {
+			name := strings.ToLower(rule.Name)
+			if name != "prefix" && name != "suffix" {
+				return fmt.Errorf("The filter rule name must be either prefix or suffix, not %q", rule.Name)
+			}
+			if seen[name] {
+				return fmt.Errorf("Cannot specify more than one %s rule in a filter", name)
+			}
+			seen[name] = true
+		}
+	}
+	return nil
+}
+
+// HandlePutBucketNotification replaces the webhooks notified of the events of a bucket
+// (PUT /{bucket}/?notification); an empty configuration disables the notifications
+func HandlePutBucketNotification(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var config dto.NotificationConfiguration
+		if !readXMLBody(w, r, &config) {
+			return
+		}
+		if err := validateNotification(config); err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+
+		if err := s.PutBucketNotification(mux.Vars(r)["bucketName"], config); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleGetBucketNotification returns the webhooks notified of the events of a bucket
+// (GET /{bucket}/?notification)
+func HandleGetBucketNotification(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config, err := s.GetBucketNotification(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		config.Xmlns = dto.S3Namespace
+		writeXML(w, config)
+	}
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// En-têtes de l'Object Lock
+const (
+	objectLockModeHeader            = "X-Amz-Object-Lock-Mode"
+	objectLockRetainUntilDateHeader = "X-Amz-Object-Lock-Retain-Until-Date"
+	objectLockLegalHoldHeader       = "X-Amz-Object-Lock-Legal-Hold"
+	bucketObjectLockEnabledHeader   = "X-Amz-Bucket-Object-Lock-Enabled"
+	bypassGovernanceHeader          = "X-Amz-Bypass-Governance-Retention"
+)
+
+// validRetentionMode indique si mode est un mode de rétention reconnu
+func validRetentionMode(mode string) bool {
+	return mode == dto.RetentionGovernance || mode == dto.RetentionCompliance
+}
+
+// objectLockFromRequest lit le verrouillage demandé pour l'objet écrit. Renvoie nil si la requête
+// n'en demande pas.
+func objectLockFromRequest(r *http.Request) (*dto.ObjectLock, error) {
+	mode := r.Header.Get(objectLockModeHeader)
+	until := r.Header.Get(objectLockRetainUntilDateHeader)
+	legalHold := r.Header.Get(objectLockLegalHoldHeader)
+	if mode == "" && until == "" && legalHold == "" {
+		return nil, nil
+	}
+
+	lock := &dto.ObjectLock{}
+	if (mode == "") != (until == "") {
+		return nil, errors.New("x-amz-object-lock-retain-until-date and x-amz-object-lock-mode must both be supplied")
+	}
+	if mode != "" {
+		if !validRetentionMode(mode) {
+			return nil, errors.New("Unknown wormMode directive.")
+		}
+		date, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return nil, errors.New("The retain until date must be provided in ISO 8601 format")
+		}
+		if !date.After(time.Now()) {
+			return nil, errors.New("The retain until date must be in the future!")
+		}
+		lock.Mode, lock.RetainUntilDate = mode, date.UTC()
+	}
+
+	switch legalHold {
+	case "", dto.LegalHoldOff:
+	case dto.LegalHoldOn:
+		lock.LegalHold = true
+	default:
+		return nil, errors.New("Legal Hold must be either of 'ON' or 'OFF'")
+	}
+	return lock, nil
+}
+
+// setObjectLockHeaders indique dans la réponse le verrouillage de l'objet
+func setObjectLockHeaders(w http.ResponseWriter, lock *dto.ObjectLock) {
+	if lock == nil {
+		return
+	}
+	if lock.Mode != "" {
+		w.Header().Set(objectLockModeHeader, lock.Mode)
+		w.Header().Set(objectLockRetainUntilDateHeader, lock.RetainUntilDate.UTC().Format(time.RFC3339))
+	}
+	if lock.LegalHold {
+		w.Header().Set(objectLockLegalHoldHeader, dto.LegalHoldOn)
+	} else {
+		w.Header().Set(objectLockLegalHoldHeader, dto.LegalHoldOff)
+	}
+}
+
+// bypassGovernance indique si la requête demande à contourner la rétention GOVERNANCE
+func bypassGovernance(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get(bypassGovernanceHeader), "true")
+}
+
+// objectLockStorage renvoie la vue de s à utiliser pour la requête : elle peut contourner la
+// rétention GOVERNANCE si la requête le demande
+func objectLockStorage(s storage.Storage, r *http.Request) storage.Storage {
+	if bypassGovernance(r) {
+		return storage.WithGovernanceBypass(s)
+	}
+	return s
+}
+
+// readXMLBody décode le corps XML de la requête dans v et écrit l'erreur S3 en cas d'échec
+func readXMLBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s3errors.WriteError(w, r, s3errors.ErrInternalError)
+		return false
+	}
+	if err := xml.Unmarshal(body, v); err != nil {
+		s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+		return false
+	}
+	return true
+}
+
+// HandlePutObjectLockConfiguration enables object lock on a bucket and sets its default retention
+// (PUT /{bucket}/?object-lock)
+func HandlePutObjectLockConfiguration(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var config dto.ObjectLockConfiguration
+		if !readXMLBody(w, r, &config) {
+			return
+		}
+		if config.ObjectLockEnabled != dto.ObjectLockEnabled {
+			s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+		if config.Rule != nil {
+			retention := config.Rule.DefaultRetention
+			if !validRetentionMode(retention.Mode) {
+				s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+				return
+			}
+			if (retention.Days > 0) == (retention.Years > 0) || retention.Days < 0 || retention.Years < 0 {
+				s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("Default retention period must be a positive integer value, in either days or years."))
+				return
+			}
+		}
+
+		if err := s.PutObjectLockConfiguration(mux.Vars(r)["bucketName"], config); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleGetObjectLockConfiguration returns the object lock configuration of a bucket (GET /{bucket}/?object-lock)
+func HandleGetObjectLockConfiguration(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config, err := s.GetObjectLockConfiguration(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		config.Xmlns = dto.S3Namespace
+		writeXML(w, config)
+	}
+}
+
+// HandlePutObjectRetention sets the retention of an object version (PUT /{bucket}/{key}?retention)
+func HandlePutObjectRetention(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		var retention dto.ObjectRetention
+		if !readXMLBody(w, r, &retention) {
+			return
+		}
+		// Une rétention vide supprime la rétention, ce qui n'est possible qu'en contournant GOVERNANCE
+		if retention.Mode != "" || retention.RetainUntilDate != nil {
+			if !validRetentionMode(retention.Mode) || retention.RetainUntilDate == nil {
+				s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+				return
+			}
+			if !retention.RetainUntilDate.After(time.Now()) {
+				s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("The retain until date must be in the future!"))
+				return
+			}
+		}
+
+		err := objectLockStorage(s, r).UpdateObjectMetadata(vars["bucketName"], vars["objectName"], r.URL.Query().Get("versionId"), func(metadata *dto.ObjectMetadata) error {
+			lock := dto.ObjectLock{Mode: retention.Mode}
+			if metadata.Lock != nil {
+				lock.LegalHold = metadata.Lock.LegalHold
+			}
+			if retention.RetainUntilDate != nil {
+				lock.RetainUntilDate = retention.RetainUntilDate.UTC()
+			}
+			metadata.Lock = normalizeObjectLock(lock)
+			return nil
+		})
+		if err != nil {
+			writeObjectError(w, r, dto.ObjectInfo{}, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleGetObjectRetention returns the retention of an object version (GET /{bucket}/{key}?retention)
+func HandleGetObjectRetention(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		info, err := s.StatObject(vars["bucketName"], vars["objectName"], r.URL.Query().Get("versionId"))
+		if err != nil {
+			writeObjectError(w, r, info, err)
+			return
+		}
+		if info.Lock == nil || info.Lock.Mode == "" {
+			s3errors.WriteError(w, r, s3errors.ErrNoSuchObjectLockConfiguration)
+			return
+		}
+
+		until := info.Lock.RetainUntilDate.UTC()
+		writeXML(w, dto.ObjectRetention{Xmlns: dto.S3Namespace, Mode: info.Lock.Mode, RetainUntilDate: &until})
+	}
+}
+
+// HandlePutObjectLegalHold places or removes a legal hold on an object version (PUT /{bucket}/{key}?legal-hold)
+func HandlePutObjectLegalHold(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		var legalHold dto.LegalHold
+		if !readXMLBody(w, r, &legalHold) {
+			return
+		}
+		if legalHold.Status != dto.LegalHoldOn && legalHold.Status != dto.LegalHoldOff {
+			s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+
+		err := s.UpdateObjectMetadata(vars["bucketName"], vars["objectName"], r.URL.Query().Get("versionId"), func(metadata *dto.ObjectMetadata) error {
+			var lock dto.ObjectLock
+			if metadata.Lock != nil {
+				lock = *metadata.Lock
+			}
+			lock.LegalHold = legalHold.Status == dto.LegalHoldOn
+			metadata.Lock = normalizeObjectLock(lock)
+			return nil
+		})
+		if err != nil {
+			writeObjectError(w, r, dto.ObjectInfo{}, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleGetObjectLegalHold returns the legal hold status of an object version (GET /{bucket}/{key}?legal-hold)
+func HandleGetObjectLegalHold(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		info, err := s.StatObject(vars["bucketName"], vars["objectName"], r.URL.Query().Get("versionId"))
+		if err != nil {
+			writeObjectError(w, r, info, err)
+			return
+		}
+		if info.Lock == nil {
+			s3errors.WriteError(w, r, s3errors.ErrNoSuchObjectLockConfiguration)
+			return
+		}
+
+		status := dto.LegalHoldOff
+		if info.Lock.LegalHold {
+			status = dto.LegalHoldOn
+		}
+		writeXML(w, dto.LegalHold{Xmlns: dto.S3Namespace, Status: status})
+	}
+}
+
+// normalizeObjectLock renvoie nil pour un verrouillage vide, qui n'est pas enregistré
+func normalizeObjectLock(lock dto.ObjectLock) *dto.ObjectLock {
+	if lock.Mode == "" && !lock.LegalHold {
+		return nil
+	}
+	return &lock
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/policy"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// HandlePutBucketPolicy replaces the policy of a bucket (PUT /{bucket}/?policy)
+func HandlePutBucketPolicy(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketName := mux.Vars(r)["bucketName"]
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, policy.MaxSize+1))
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInternalError)
+			return
+		}
+
+		bucketPolicy, err := policy.Parse(bucketName, body)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrMalformedPolicy.WithMessage(err.Error()))
+			return
+		}
+		if err := s.PutBucketPolicy(bucketName, bucketPolicy); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleGetBucketPolicy returns the policy of a bucket as JSON (GET /{bucket}/?policy)
+func HandleGetBucketPolicy(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketPolicy, err := s.GetBucketPolicy(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		response, err := json.Marshal(bucketPolicy)
+		if err != nil {
+			log.Printf("Error encoding bucket policy: %v", err)
+			s3errors.WriteError(w, r, s3errors.ErrInternalError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+// HandleDeleteBucketPolicy removes the policy of a bucket (DELETE /{bucket}/?policy)
+func HandleDeleteBucketPolicy(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.DeleteBucketPolicy(mux.Vars(r)["bucketName"]); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
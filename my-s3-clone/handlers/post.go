@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/auth"
+	"my-s3-clone/dto"
+	"my-s3-clone/policy"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// Taille maximale des champs qui précèdent le fichier dans un formulaire POST, comme S3
+const maxPostFormSize = 20 * 1024
+
+var (
+	errEntityTooSmall = errors.New("the uploaded file is smaller than the policy allows")
+	errEntityTooLarge = errors.New("the uploaded file is larger than the policy allows")
+)
+
+// lengthRangeReader fait échouer la lecture du fichier dès qu'il dépasse max octets, ou à la fin
+// s'il en compte moins de min : l'objet n'est alors pas enregistré
+type lengthRangeReader struct {
+	r        io.Reader
+	n        int64
+	min, max int64
+}
+
+func (lr *lengthRangeReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	lr.n += int64(n)
+	if lr.n > lr.max {
+		return n, errEntityTooLarge
+	}
+	if err == io.EOF && lr.n < lr.min {
+		return n, errEntityTooSmall
+	}
+	return n, err
+}
+
+// readPostForm lit les champs du formulaire jusqu'au fichier, qui doit être le dernier champ utile :
+// les champs suivants sont ignorés, comme dans S3. Les noms des champs sont mis en minuscules.
+// Elle renvoie false après avoir écrit l'erreur si le formulaire est invalide.
+func readPostForm(w http.ResponseWriter, r *http.Request) (map[string]string, *multipartFile, bool) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		s3errors.WriteError(w, r, s3errors.ErrMalformedPOSTRequest)
+		return nil, nil, false
+	}
+
+	form := make(map[string]string)
+	size := 0
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("POST requires exactly one file upload per request."))
+			return nil, nil, false
+		} else if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrMalformedPOSTRequest)
+			return nil, nil, false
+		}
+
+		name := strings.ToLower(part.FormName())
+		if name == "file" {
+			return form, &multipartFile{Reader: part, name: part.FileName()}, true
+		}
+		value, err := io.ReadAll(io.LimitReader(part, int64(maxPostFormSize-size+1)))
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrMalformedPOSTRequest)
+			return nil, nil, false
+		}
+		if size += len(value); size > maxPostFormSize {
+			s3errors.WriteError(w, r, s3errors.ErrMalformedPOSTRequest.WithMessage("Your POST request fields preceding the upload file were too large."))
+			return nil, nil, false
+		}
+		form[name] = string(value)
+	}
+}
+
+// multipartFile est le fichier d'un formulaire POST, lu au fil de l'eau
+type multipartFile struct {
+	io.Reader
+	name string
+}
+
+// postFormHeaders traduit les champs du formulaire en en-têtes, pour lire les métadonnées de
+// l'objet comme pour un PUT : Content-Type, x-amz-meta-*, x-amz-server-side-encryption...
+func postFormHeaders(form map[string]string) http.Header {
+	header := make(http.Header)
+	for name, value := range form {
+		if name == "acl" {
+			name = cannedACLHeader
+		}
+		header.Set(name, value)
+	}
+	// Le fichier n'est ni signé ni envoyé en aws-chunked : seuls Content-MD5 et x-amz-checksum-* s'appliquent
+	header.Del("X-Amz-Content-Sha256")
+	return header
+}
+
+// HandlePostObject uploads an object from a browser HTML form (POST /{bucket} as multipart/form-data).
+// The form carries the key, the metadata of the object and, when the request is signed, a base64 policy
+// whose conditions the other fields must meet, signed with SigV4 in x-amz-signature.
+func HandlePostObject(s storage.Storage, verifier *auth.Verifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketName := mux.Vars(r)["bucketName"]
+
+		form, file, ok := readPostForm(w, r)
+		if !ok {
+			return
+		}
+		key := form["key"]
+		if key == "" {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("Bucket POST must contain a field named 'key'.  If it is specified, please check the order of the fields."))
+			return
+		}
+		key = strings.ReplaceAll(key, "${filename}", file.name)
+		form["key"] = key
+		form["bucket"] = bucketName
+
+		// Avec une politique, le formulaire doit être signé (si l'authentification est active) et
+		// respecter ses conditions ; sans politique, l'upload est anonyme
+		var postPolicy *auth.PostPolicy
+		var err error
+		if form["policy"] != "" {
+			if postPolicy, err = auth.ParsePostPolicy(form["policy"]); err != nil {
+				writeStorageError(w, r, err)
+				return
+			}
+		}
+		if verifier != nil && (postPolicy != nil || form["x-amz-signature"] != "") {
+			signing, err := verifier.VerifyPostPolicy(form)
+			if err != nil {
+				writeStorageError(w, r, err)
+				return
+			}
+			r = r.WithContext(auth.WithSigningContext(r.Context(), signing))
+		}
+		if postPolicy != nil {
+			if err := postPolicy.Check(form, time.Now()); err != nil {
+				writeStorageError(w, r, err)
+				return
+			}
+		}
+
+		if !authorized(w, r, s, policy.PutObject, bucketName, key, "") {
+			return
+		}
+		if form["tagging"] != "" && !authorized(w, r, s, policy.PutObjectTagging, bucketName, key, "") {
+			return
+		}
+
+		// Les métadonnées sont lues dans les champs du formulaire, comme dans les en-têtes d'un PUT
+		formRequest := r.Clone(r.Context())
+		formRequest.Header = postFormHeaders(form)
+		formRequest.Body = io.NopCloser(file)
+		if postPolicy != nil && postPolicy.LengthRange != nil {
+			formRequest.Body = io.NopCloser(&lengthRangeReader{r: file, min: postPolicy.LengthRange[0], max: postPolicy.LengthRange[1]})
+		}
+
+		metadata, err := objectMetadataFromRequest(formRequest)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrMetadataTooLarge)
+			return
+		}
+		metadata.Encryption, err = encryptionFromRequest(formRequest)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+		metadata.Lock, err = objectLockFromRequest(formRequest)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+		metadata.ACL, err = cannedACLFromRequest(formRequest)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+			return
+		}
+		// Dans un formulaire, les tags sont un document Tagging et non une query string
+		if form["tagging"] != "" {
+			var tagging dto.Tagging
+			if err := xml.Unmarshal([]byte(form["tagging"]), &tagging); err != nil {
+				s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+				return
+			}
+			if metadata.Tags, err = tagsFromSet(tagging.TagSet.Tags); err != nil {
+				writeTaggingError(w, r, err)
+				return
+			}
+		}
+
+		payload, ok := requestPayload(w, formRequest)
+		if !ok {
+			return
+		}
+		info, err := s.AddObject(bucketName, key, payload, metadata)
+		switch {
+		case errors.Is(err, errEntityTooSmall):
+			s3errors.WriteError(w, r, s3errors.ErrEntityTooSmall)
+			return
+		case errors.Is(err, errEntityTooLarge):
+			s3errors.WriteError(w, r, s3errors.ErrEntityTooLarge)
+			return
+		case err != nil:
+			writeStorageError(w, r, err)
+			return
+		}
+
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		location := (&url.URL{Scheme: scheme, Host: r.Host, Path: "/" + bucketName + "/" + key}).String()
+		w.Header().Set("ETag", quoteETag(info.ETag))
+		w.Header().Set("Location", location)
+		setVersionIDHeader(w, info.VersionID)
+		setEncryptionHeaders(w, info.Encryption)
+		setChecksumHeaders(w, payload)
+
+		// Le navigateur est renvoyé vers success_action_redirect, avec l'objet créé en paramètres
+		if redirect, err := url.Parse(form["success_action_redirect"]); err == nil && redirect.Scheme != "" {
+			query := redirect.Query()
+			query.Set("bucket", bucketName)
+			query.Set("key", key)
+			query.Set("etag", quoteETag(info.ETag))
+			redirect.RawQuery = query.Encode()
+			http.Redirect(w, r, redirect.String(), http.StatusSeeOther)
+			return
+		}
+
+		switch form["success_action_status"] {
+		case "200":
+			w.WriteHeader(http.StatusOK)
+		case "201":
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(xml.Header))
+			xml.NewEncoder(w).Encode(dto.PostResponse{Xmlns: dto.S3Namespace, Location: location, Bucket: bucketName, Key: key, ETag: quoteETag(info.ETag)})
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
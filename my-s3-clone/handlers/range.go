@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errRangeNotSatisfiable signale qu'aucune des plages demandées ne recouvre l'objet
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// byteRange est une plage d'octets [start, start+length) de l'objet
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+// contentRange formate l'en-tête Content-Range de la plage
+func (br byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.start, br.start+br.length-1, size)
+}
+
+// errInvalidCopySourceRange signale un en-tête x-amz-copy-source-range mal formé
+var errInvalidCopySourceRange = errors.New("invalid copy source range")
+
+// parseCopySourceRange interprète l'en-tête x-amz-copy-source-range ("bytes=first-last", bornes
+// incluses) pour une source de taille size. Contrairement à Range, les deux bornes sont obligatoires
+// et la plage doit être entièrement contenue dans l'objet.
+func parseCopySourceRange(header string, size int64) (byteRange, error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok {
+		return byteRange{}, errInvalidCopySourceRange
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return byteRange{}, errInvalidCopySourceRange
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return byteRange{}, errInvalidCopySourceRange
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return byteRange{}, errInvalidCopySourceRange
+	}
+	if end >= size {
+		return byteRange{}, errRangeNotSatisfiable
+	}
+	return byteRange{start: start, length: end - start + 1}, nil
+}
+
+// parseRange interprète un en-tête Range (RFC 7233) pour un objet de taille size.
+// Comme S3, un en-tête mal formé est ignoré (l'objet entier est renvoyé) ; les plages
+// qui commencent après la fin de l'objet sont écartées, et errRangeNotSatisfiable est
+// renvoyée s'il n'en reste aucune.
+func parseRange(header string, size int64) ([]byteRange, error) {
+	specs, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok {
+		return nil, nil
+	}
+
+	var ranges []byteRange
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, nil
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+		var br byteRange
+		if first == "" {
+			// Suffixe : les N derniers octets
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, nil
+			}
+			if n == 0 {
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			br = byteRange{start: size - n, length: n}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, nil
+			}
+			end := size - 1
+			if last != "" {
+				end, err = strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					return nil, nil
+				}
+				if end >= size {
+					end = size - 1
+				}
+			}
+			if start >= size {
+				continue
+			}
+			br = byteRange{start: start, length: end - start + 1}
+		}
+		ranges = append(ranges, br)
+	}
+
+	if len(ranges) == 0 {
+		return nil, errRangeNotSatisfiable
+	}
+	return ranges, nil
+}
+
+// ifRangeMatches indique si la condition If-Range est remplie, c'est-à-dire si la plage
+// demandée peut être renvoyée. La valeur est soit un ETag, soit une date HTTP.
+func ifRangeMatches(r *http.Request, etag string, modTime time.Time) bool {
+	value := strings.TrimSpace(r.Header.Get("If-Range"))
+	if value == "" {
+		return true
+	}
+	if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "W/") {
+		// Seule une comparaison forte est permise pour If-Range
+		return etag != "" && !strings.HasPrefix(value, "W/") && value == etag
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return false
+	}
+	return modTime.Truncate(time.Second).Equal(t)
+}
+
+// requestedRanges renvoie les plages à servir pour la requête, ou nil pour l'objet entier
+func requestedRanges(r *http.Request, size int64, etag string, modTime time.Time) ([]byteRange, error) {
+	header := r.Header.Get("Range")
+	if header == "" || !ifRangeMatches(r, etag, modTime) {
+		return nil, nil
+	}
+	return parseRange(header, size)
+}
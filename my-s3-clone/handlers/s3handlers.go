@@ -2,8 +2,11 @@ package handlers
 
 import (
     "io"
+    "my-s3-clone/auth"
+    "my-s3-clone/s3errors"
     "my-s3-clone/storage"
     "my-s3-clone/dto"
+    "my-s3-clone/policy"
     "net/http"
     "github.com/gorilla/mux"
     "log"
@@ -13,6 +16,9 @@ import (
     "os"
     "strconv"
     "errors"
+    "strings"
+    "mime/multipart"
+    "net/textproto"
 )
 
 // List all buckets
@@ -29,17 +35,30 @@ func HandleListBuckets(s storage.Storage) http.HandlerFunc {
             log.Printf("Found %d buckets.", len(buckets))
         }
 
+        // Only the buckets owned by the caller are listed
+        owner := requestAccessKey(r)
         var bucketList []dto.Bucket
         for _, bucketName := range buckets {
+            info, err := s.GetBucketInfo(bucketName)
+            if err != nil {
+                log.Printf("Skipping bucket %s: %v", bucketName, err)
+                continue
+            }
+            if !visibleTo(info, owner) {
+                continue
+            }
             log.Printf("Adding bucket: %s", bucketName)
             bucketList = append(bucketList, dto.Bucket{
                 Name:         bucketName,
-                CreationDate: time.Now(),
+                CreationDate: info.CreationDate,
+                BucketRegion: info.Region,
             })
         }
 
+        bucketOwner := requestOwner(r)
         response := dto.ListAllMyBucketsResult{
             Buckets: bucketList,
+            Owner:   &bucketOwner,
         }
 
         w.Header().Set("Content-Type", "application/xml")
@@ -69,22 +88,51 @@ func HandleCreateBucket(s storage.Storage) http.HandlerFunc {
         // Vérification si le bucket existe déjà
         exists, err := s.CheckBucketExists(bucketName) 
         if err != nil {
-            http.Error(w, "Erreur lors de la vérification du bucket", http.StatusInternalServerError)
+            writeStorageError(w, r, err)
             return
         }
 
         if exists {
-            http.Error(w, fmt.Sprintf("Bucket '%s' already exists", bucketName), http.StatusConflict)
+            writeBucketAlreadyExists(w, r, s, bucketName)
+            return
+        }
+
+        region, ok := bucketRegionFromRequest(w, r)
+        if !ok {
+            return
+        }
+        acl, err := cannedACLFromRequest(r)
+        if err != nil {
+            s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
             return
         }
 
         // Création du bucket si il n'existe pas
         err = s.CreateBucket(bucketName)
+        if errors.Is(err, storage.ErrInvalidBucketName) {
+            writeStorageError(w, r, err)
+            return
+        }
         if err != nil {
             http.Error(w, err.Error(), http.StatusInternalServerError)
             return
         }
 
+        // The creator owns the bucket
+        if err := s.PutBucketInfo(bucketName, dto.BucketInfo{Owner: requestAccessKey(r), Region: region, ACL: acl}); err != nil {
+            writeStorageError(w, r, err)
+            return
+        }
+
+        // The object lock can be enabled when the bucket is created
+        if strings.EqualFold(r.Header.Get(bucketObjectLockEnabledHeader), "true") {
+            config := dto.ObjectLockConfiguration{ObjectLockEnabled: dto.ObjectLockEnabled}
+            if err := s.PutObjectLockConfiguration(bucketName, config); err != nil {
+                writeStorageError(w, r, err)
+                return
+            }
+        }
+
         // Réponse pour indiquer que le bucket a été créé avec succès
         bucketResponse := dto.ListAllMyBucketsResult{
             Buckets: []dto.Bucket{
@@ -117,9 +165,7 @@ func HandleGetBucket(s storage.Storage) http.HandlerFunc {
 
         if locationParam != "" {
             log.Printf("Demande de localisation pour le bucket: %s", bucketName)
-            w.Header().Set("Content-Type", "application/xml")
-            w.WriteHeader(http.StatusOK)
-            w.Write([]byte(`<LocationConstraint>us-east-1</LocationConstraint>`))
+            writeBucketLocation(w, r, s, bucketName)
             return
         }
 
@@ -127,7 +173,7 @@ func HandleGetBucket(s storage.Storage) http.HandlerFunc {
         exists, err := s.CheckBucketExists(bucketName)
         if err != nil {
             log.Printf("Erreur lors de la vérification du bucket: %v", err)
-            http.Error(w, "Internal server error", http.StatusInternalServerError)
+            writeStorageError(w, r, err)
             return
         }
 
@@ -161,29 +207,64 @@ func HandleAddObject(s storage.Storage) http.HandlerFunc {
 
         log.Printf("Uploading object: %s to bucket: %s", objectName, bucketName)
 
-        // Get the total content length from the X-Amz-Decoded-Content-Length header
+        // aws-chunked uploads carry the real object size in X-Amz-Decoded-Content-Length,
+        // plain uploads (e.g. presigned PUT from a browser) use Content-Length
         contentLength := r.Header.Get("X-Amz-Decoded-Content-Length")
         if contentLength == "" {
-            log.Printf("Missing X-Amz-Decoded-Content-Length header")
-            http.Error(w, "Missing X-Amz-Decoded-Content-Length header", http.StatusBadRequest)
-            return
+            if r.Header.Get("X-Amz-Content-Sha256") == auth.StreamingPayload {
+                log.Printf("Missing X-Amz-Decoded-Content-Length header")
+                http.Error(w, "Missing X-Amz-Decoded-Content-Length header", http.StatusBadRequest)
+                return
+            }
+            contentLength = strconv.FormatInt(r.ContentLength, 10)
         }
 
         log.Printf("Total upload size: %s bytes", contentLength)
 
-        // Process the uploaded object
-        err := s.AddObject(bucketName, objectName, r.Body, r.Header.Get("X-Amz-Content-Sha256"))
+        metadata, err := objectMetadataFromRequest(r)
         if err != nil {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
-            log.Printf("Error uploading object: %v", err)
+            s3errors.WriteError(w, r, s3errors.ErrMetadataTooLarge)
+            return
+        }
+        metadata.Encryption, err = encryptionFromRequest(r)
+        if err != nil {
+            s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+            return
+        }
+        metadata.Lock, err = objectLockFromRequest(r)
+        if err != nil {
+            s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+            return
+        }
+        metadata.ACL, err = cannedACLFromRequest(r)
+        if err != nil {
+            s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+            return
+        }
+        metadata.Tags, err = tagsFromRequest(r)
+        if err != nil {
+            writeTaggingError(w, r, err)
             return
         }
 
-        // Generate an ETag for the object
-        eTag := `"1b2cf535f27731c974343645a3985328"`
+        // Process the uploaded object, verified against the digests sent by the client
+        payload, ok := requestPayload(w, r)
+        if !ok {
+            return
+        }
+        info, err := s.AddObject(bucketName, objectName, payload, metadata)
+        if err != nil {
+            log.Printf("Error uploading object: %v", err)
+            writeStorageError(w, r, err)
+            return
+        }
 
         // Set the appropriate headers
-        w.Header().Set("ETag", eTag)
+        w.Header().Set("ETag", quoteETag(info.ETag))
+        setVersionIDHeader(w, info.VersionID)
+        setEncryptionHeaders(w, info.Encryption)
+        setObjectLockHeaders(w, info.Lock)
+        setChecksumHeaders(w, payload)
         w.Header().Set("x-amz-id-2", "LriYPLdmOdAiIfgSm/F1YsViT1LW94/xUQxMsF7xiEb1a0wiIOIxl+zbwZ163pt7")
         w.Header().Set("x-amz-request-id", "0A49CE4060975EAC")
         w.Header().Set("Date", time.Now().Format(http.TimeFormat))
@@ -198,7 +279,7 @@ func HandleAddObject(s storage.Storage) http.HandlerFunc {
     }
 }
 
-// Check if an object exists
+// Check if an object exists, honouring conditional headers
 func HandleCheckObjectExist(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         log.Printf("Received request: %s %s", r.Method, r.URL.Path)
@@ -212,23 +293,36 @@ func HandleCheckObjectExist(s storage.Storage) http.HandlerFunc {
             return
         }
 
-        exists, lastModified, size, err := s.CheckObjectExist(bucketName, objectName)
-        if err != nil || !exists {
-            if !exists {
-                http.Error(w, "Object not found", http.StatusNotFound)
-                return
-            }
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+        // Objects encrypted with a customer key (SSE-C) can only be read with that key
+        customerKey, err := customerKeyFromRequest(r, objectSSEPrefix)
+        if err != nil {
+            s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
+            return
+        }
+
+        info, err := storage.WithCustomerKey(s, customerKey).StatObject(bucketName, objectName, r.URL.Query().Get("versionId"))
+        if err != nil {
+            writeObjectError(w, r, info, err)
+            return
+        }
+
+        w.Header().Set("ETag", quoteETag(info.ETag))
+        w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+        setVersionIDHeader(w, info.VersionID)
+        if !checkPreconditions(w, r, info.ETag, info.LastModified) {
             return
         }
 
-        w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
-        w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+        setObjectMetadataHeaders(w, objectName, info.ObjectMetadata)
+        setEncryptionHeaders(w, info.Encryption)
+        setObjectLockHeaders(w, info.Lock)
+        w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size))
+        w.Header().Set("Accept-Ranges", "bytes")
         w.WriteHeader(http.StatusOK)
     }
 }
 
-// Download an object
+// Download an object, honouring conditional headers, Range and If-Range
 func HandleDownloadObject(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         log.Printf("Received request: %s %s", r.Method, r.URL.Path)
@@ -237,33 +331,103 @@ func HandleDownloadObject(s storage.Storage) http.HandlerFunc {
         bucketName := vars["bucketName"]
         objectName := vars["objectName"]
 
-        // Récupérer les données du fichier et ses métadonnées
-        data, fileInfo, err := s.GetObject(bucketName, objectName)
+        customerKey, err := customerKeyFromRequest(r, objectSSEPrefix)
         if err != nil {
-            if os.IsNotExist(err) {
-                http.Error(w, "File not found", http.StatusNotFound)
-                return
-            }
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+            s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage(err.Error()))
             return
         }
 
+        // Ouvrir l'objet en streaming et récupérer ses métadonnées
+        reader, info, err := storage.WithCustomerKey(s, customerKey).GetObject(bucketName, objectName, r.URL.Query().Get("versionId"))
+        if err != nil {
+            writeObjectError(w, r, info, err)
+            return
+        }
+        defer reader.Close()
+
+        size := info.Size
+
         // Envoyer les métadonnées dans les en-têtes HTTP
-        w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", objectName))
-        w.Header().Set("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
-        w.Header().Set("Last-Modified", fileInfo.ModTime().Format(http.TimeFormat))
+        w.Header().Set("ETag", quoteETag(info.ETag))
+        w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+        setVersionIDHeader(w, info.VersionID)
+        if !checkPreconditions(w, r, info.ETag, info.LastModified) {
+            return
+        }
+        setObjectMetadataHeaders(w, objectName, info.ObjectMetadata)
+        setEncryptionHeaders(w, info.Encryption)
+        setObjectLockHeaders(w, info.Lock)
+        w.Header().Set("Accept-Ranges", "bytes")
 
-        // Envoyer le contenu du fichier
-        w.Header().Set("Content-Type", "application/octet-stream")
-        w.WriteHeader(http.StatusOK)
+        ranges, err := requestedRanges(r, size, w.Header().Get("ETag"), info.LastModified)
+        if err != nil {
+            w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+            s3errors.WriteError(w, r, s3errors.ErrInvalidRange)
+            return
+        }
 
-        if _, err := w.Write(data); err != nil {
-            http.Error(w, "Failed to write file content", http.StatusInternalServerError)
+        switch len(ranges) {
+        case 0:
+            w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+            w.WriteHeader(http.StatusOK)
+            if _, err := io.Copy(w, reader); err != nil {
+                log.Printf("Error streaming object %s/%s: %v", bucketName, objectName, err)
+            }
+        case 1:
+            writeSingleRange(w, reader, ranges[0], size)
+        default:
+            writeMultipleRanges(w, reader, ranges, size)
         }
     }
 }
 
-// List objects in a bucket
+// writeSingleRange sends one byte range as a 206 Partial Content response
+func writeSingleRange(w http.ResponseWriter, reader io.ReadSeeker, br byteRange, size int64) {
+    if _, err := reader.Seek(br.start, io.SeekStart); err != nil {
+        log.Printf("Error seeking object: %v", err)
+        http.Error(w, "Failed to read object", http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Range", br.contentRange(size))
+    w.Header().Set("Content-Length", fmt.Sprintf("%d", br.length))
+    w.WriteHeader(http.StatusPartialContent)
+    if _, err := io.CopyN(w, reader, br.length); err != nil {
+        log.Printf("Error streaming range %s: %v", br.contentRange(size), err)
+    }
+}
+
+// writeMultipleRanges sends several byte ranges as a multipart/byteranges body
+func writeMultipleRanges(w http.ResponseWriter, reader io.ReadSeeker, ranges []byteRange, size int64) {
+    // Chaque partie porte le Content-Type de l'objet
+    contentType := w.Header().Get("Content-Type")
+
+    mw := multipart.NewWriter(w)
+    w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+    w.WriteHeader(http.StatusPartialContent)
+
+    for _, br := range ranges {
+        part, err := mw.CreatePart(textproto.MIMEHeader{
+            "Content-Type":  {contentType},
+            "Content-Range": {br.contentRange(size)},
+        })
+        if err != nil {
+            log.Printf("Error writing multipart range: %v", err)
+            return
+        }
+        if _, err := reader.Seek(br.start, io.SeekStart); err != nil {
+            log.Printf("Error seeking object: %v", err)
+            return
+        }
+        if _, err := io.CopyN(part, reader, br.length); err != nil {
+            log.Printf("Error streaming range %s: %v", br.contentRange(size), err)
+            return
+        }
+    }
+    mw.Close()
+}
+
+// List objects in a bucket (ListObjects v1, paged with marker)
 func HandleListObjects(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         vars := mux.Vars(r)
@@ -271,30 +435,31 @@ func HandleListObjects(s storage.Storage) http.HandlerFunc {
 
         queryParams := r.URL.Query()
         prefix := queryParams.Get("prefix")
+        delimiter := queryParams.Get("delimiter")
         marker := queryParams.Get("marker")
-        maxKeys := queryParams.Get("max-keys")
-
-        if maxKeys == "" {
-            maxKeys = "1000"
-        }
 
-        maxKeysInt, err := strconv.Atoi(maxKeys)
+        maxKeys, encodingType, err := listParams(r)
         if err != nil {
-            http.Error(w, "Invalid max-keys value", http.StatusBadRequest)
+            writeListParamsError(w, r, err)
             return
         }
 
-        objects, err := s.ListObjects(bucketName, prefix, marker, maxKeysInt)
+        objects, err := s.ListObjects(bucketName, prefix, delimiter, marker, maxKeys)
         if err != nil {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+            writeStorageError(w, r, err)
             return
         }
 
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        if err := xml.NewEncoder(w).Encode(objects); err != nil {
-            http.Error(w, "Erreur lors de l'encodage XML", http.StatusInternalServerError)
+        if encodingType == "url" {
+            objects.EncodingType = encodingType
+            objects.Prefix = s3URLEncode(objects.Prefix)
+            objects.Marker = s3URLEncode(objects.Marker)
+            objects.NextMarker = s3URLEncode(objects.NextMarker)
+            objects.Delimiter = s3URLEncode(objects.Delimiter)
+            encodeListing(objects.Contents, objects.CommonPrefixes)
         }
+
+        writeXML(w, objects)
     }
 }
 
@@ -319,6 +484,14 @@ func HandleDeleteBucket(s storage.Storage) http.HandlerFunc {
                 http.Error(w, fmt.Sprintf("Bucket %s does not exist", bucketName), http.StatusNotFound)
                 return
             }
+            if errors.Is(err, storage.ErrInvalidBucketName) {
+                s3errors.WriteError(w, r, s3errors.ErrInvalidBucketName)
+                return
+            }
+            if errors.Is(err, storage.ErrObjectLocked) {
+                writeStorageError(w, r, err)
+                return
+            }
             // Pour toute autre erreur, renvoyer un code 500
             log.Printf("Error deleting bucket %s: %v", bucketName, err)
             http.Error(w, "Failed to delete bucket", http.StatusInternalServerError)
@@ -332,7 +505,11 @@ func HandleDeleteBucket(s storage.Storage) http.HandlerFunc {
 }
 
 
-// Batch delete objects
+// maxDeleteObjects est le nombre maximal de clés d'une requête DeleteObjects, comme sur S3
+const maxDeleteObjects = 1000
+
+// Batch delete objects (POST /{bucket}/?delete). Each key is authorized, deleted and reported
+// separately in a Deleted or Error element; in Quiet mode only the errors are reported.
 func HandleDeleteObject(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         if r.Method != http.MethodPost {
@@ -344,168 +521,84 @@ func HandleDeleteObject(s storage.Storage) http.HandlerFunc {
         vars := mux.Vars(r)
         bucketName := vars["bucketName"]
 
-        body, err := io.ReadAll(r.Body)
+        if _, err := s.GetBucketInfo(bucketName); err != nil {
+            // Un anonyme reçoit AccessDenied : il n'apprend pas si le bucket existe
+            if authorized(w, r, s, policy.DeleteObject, bucketName, "", "") {
+                writeStorageError(w, r, err)
+            }
+            return
+        }
+
+        // Le corps est vérifié pendant sa lecture contre Content-MD5 et x-amz-checksum-*
+        payload, ok := requestPayload(w, r)
+        if !ok {
+            return
+        }
+        body, err := io.ReadAll(payload)
         if err != nil {
-            http.Error(w, "Error reading request body", http.StatusInternalServerError)
             log.Printf("Error reading request body: %v", err)
+            writeStorageError(w, r, err)
             return
         }
         log.Printf("Request body: %s", string(body))
 
         var deleteReq dto.DeleteObjectRequest
-        err = xml.Unmarshal(body, &deleteReq)
-        if err != nil {
-            http.Error(w, "Error parsing XML", http.StatusBadRequest)
+        if err := xml.Unmarshal(body, &deleteReq); err != nil || len(deleteReq.Objects) == 0 {
             log.Printf("Error parsing XML: %v", err)
+            s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+            return
+        }
+        if len(deleteReq.Objects) > maxDeleteObjects {
+            s3errors.WriteError(w, r, s3errors.ErrMalformedXML.WithMessage(fmt.Sprintf("A delete request can contain at most %d keys.", maxDeleteObjects)))
             return
         }
 
-        var deletedObjects []dto.Deleted
+        objects := objectLockStorage(s, r)
+        deleteResult := dto.DeleteResult{Xmlns: dto.S3Namespace}
         for _, objectToDelete := range deleteReq.Objects {
             log.Printf("Attempting to delete object: %s", objectToDelete.Key)
-            err := s.DeleteObject(bucketName, objectToDelete.Key)
+
+            action := policy.DeleteObject
+            if objectToDelete.VersionId != "" {
+                action = policy.DeleteObjectVersion
+            }
+            err := checkAccess(r, s, action, bucketName, objectToDelete.Key, objectToDelete.VersionId)
+            if err == nil && bypassGovernance(r) {
+                err = checkAccess(r, s, policy.BypassGovernanceRetention, bucketName, objectToDelete.Key, objectToDelete.VersionId)
+            }
+            var result dto.DeleteObjectResult
+            if err == nil {
+                result, err = objects.DeleteObject(bucketName, objectToDelete.Key, objectToDelete.VersionId)
+            }
+            // Supprimer un objet absent n'est pas une erreur pour S3 : la clé est rapportée comme supprimée
+            if errors.Is(err, os.ErrNotExist) {
+                err = nil
+            }
             if err != nil {
-                if errors.Is(err, os.ErrNotExist) { // Vérifie si l'erreur correspond à l'objet non trouvé
-                    http.Error(w, "Object not found", http.StatusNotFound)
-                    log.Printf("Object not found: %s", objectToDelete.Key)
-                    continue 
-                }
-                http.Error(w, "Error deleting object", http.StatusInternalServerError)
-                log.Printf("Error deleting object %s: %v", objectToDelete.Key, err)
-                return
+                deleteResult.Errors = append(deleteResult.Errors, batchError(objectToDelete.Key, objectToDelete.VersionId, err))
+                continue
             }
             log.Printf("Successfully deleted object: %s", objectToDelete.Key)
 
-            deletedObjects = append(deletedObjects, dto.Deleted{Key: objectToDelete.Key})
-        }
-
-        deleteResult := dto.DeleteResult{
-            DeletedResult: deletedObjects,
-        }
-
-        response, err := xml.Marshal(deleteResult)
-        if err != nil {
-            http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-            log.Printf("Error generating XML response: %v", err)
-            return
+            if deleteReq.Quiet {
+                continue
+            }
+            deleted := dto.Deleted{Key: objectToDelete.Key, VersionId: objectToDelete.VersionId}
+            if result.DeleteMarker {
+                deleted.DeleteMarker = true
+                deleted.DeleteMarkerVersionId = result.VersionID
+            }
+            deleteResult.DeletedResult = append(deleteResult.DeletedResult, deleted)
         }
 
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        w.Write(response)
-
-                // Log response status and body
-        log.Printf("Response status: %d", http.StatusOK)
-        log.Printf("Response body: %s", string(response))
+        writeXML(w, deleteResult)
     }
 }
 
+// HandleBucketLocation returns the region of a bucket (GET /{bucket}/?location)
 func HandleBucketLocation(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        vars := mux.Vars(r)
-        bucketName := vars["bucketName"]
-            // La chaîne représentant la date
-    dateString := "2024-09-16T10:12:24Z"
-
-    // Convertir la chaîne en time.Time
-    creationDate, err := time.Parse(time.RFC3339, dateString)
-    if err != nil {
-        fmt.Printf("Error parsing date: %v\n", err)
-        return
-    }
-        bucket := dto.Bucket{
-            Name:         bucketName,
-            CreationDate: creationDate,
-            LocationConstraint : "us-east-1",
-        }
-
-        response, err := xml.Marshal(bucket)
-        if err != nil {
-            http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-            log.Printf("Error generating XML response: %v", err)
-            return
-        }
-
-        
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        w.Write(response)
-            
-    }
-}
-
-func HandleBucketLockConfig(s storage.Storage) http.HandlerFunc {
-    return func(w http.ResponseWriter, r *http.Request) {
-        vars := mux.Vars(r)
-        bucketName := vars["bucketName"]
-
-        dateString := "2024-09-16T10:12:24Z"
-
-        // Convertir la chaîne en time.Time
-        creationDate, err := time.Parse(time.RFC3339, dateString)
-        if err != nil {
-            fmt.Printf("Error parsing date: %v\n", err)
-            return
-        }
-
-        bucket := dto.Bucket{
-            Name:         bucketName,
-            CreationDate: creationDate,
-            ObjectLockConfig : "true",
-        }
-    
-
-        response, err := xml.Marshal(bucket)
-        if err != nil {
-            http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-            log.Printf("Error generating XML response: %v", err)
-            return
-        }
-
-        
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        w.Write(response)
-            
-            
-    }
-}
-
-
-func HandleBucketDelimiter(s storage.Storage) http.HandlerFunc {
-    return func(w http.ResponseWriter, r *http.Request) {
-        vars := mux.Vars(r)
-        bucketName := vars["bucketName"]
-
-        dateString := "2024-09-16T10:12:24Z"
-
-        // Convertir la chaîne en time.Time
-        creationDate, err := time.Parse(time.RFC3339, dateString)
-        if err != nil {
-            fmt.Printf("Error parsing date: %v\n", err)
-            return
-        }
-
-        bucket := dto.Bucket{
-            Name:         bucketName,
-            CreationDate: creationDate,
-            ObjectDelimiter : "true",
-        }
-    
-
-        response, err := xml.Marshal(bucket)
-        if err != nil {
-            http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-            log.Printf("Error generating XML response: %v", err)
-            return
-        }
-
-        
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        w.Write(response)
-            
-            
+        writeBucketLocation(w, r, s, mux.Vars(r)["bucketName"])
     }
 }
 
@@ -520,11 +613,15 @@ type ObjectToMove struct {
 	Key string `xml:"Key"`
 }
 
-// HandleMoveObject gère les requêtes pour déplacer des objets entre des buckets
+// maxMoveObjects est le nombre maximal de clés d'une requête de déplacement, comme pour DeleteObjects
+const maxMoveObjects = maxDeleteObjects
+
+// HandleMoveObject moves a batch of objects to another bucket (POST /{bucket}/?move). Each key is
+// authorized, moved and reported separately: a failure does not stop the batch
 func HandleMoveObject(s storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+			s3errors.WriteError(w, r, s3errors.ErrMethodNotAllowed)
 			return
 		}
 		log.Printf("Received POST ?move request for moving objects: %s %s", r.Method, r.URL.Path)
@@ -532,69 +629,59 @@ func HandleMoveObject(s storage.Storage) http.HandlerFunc {
 		vars := mux.Vars(r)
 		sourceBucket := vars["bucketName"]
 
-		body, err := io.ReadAll(r.Body)
+		// Le corps est vérifié pendant sa lecture contre Content-MD5 et x-amz-checksum-*
+		payload, ok := requestPayload(w, r)
+		if !ok {
+			return
+		}
+		body, err := io.ReadAll(payload)
 		if err != nil {
-			http.Error(w, "Error reading request body", http.StatusInternalServerError)
 			log.Printf("Error reading request body: %v", err)
+			writeStorageError(w, r, err)
 			return
 		}
 		log.Printf("Request body: %s", string(body))
 
 		var moveReq MoveObjectRequest
-		err = xml.Unmarshal(body, &moveReq)
-		if err != nil {
-			http.Error(w, "Error parsing XML", http.StatusBadRequest)
+		if err := xml.Unmarshal(body, &moveReq); err != nil || len(moveReq.Objects) == 0 {
 			log.Printf("Error parsing XML: %v", err)
+			s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
 			return
 		}
-
 		if moveReq.TargetBucket == "" {
-			http.Error(w, "TargetBucket is missing in the request", http.StatusBadRequest)
-			log.Println("Error: TargetBucket is missing")
+			s3errors.WriteError(w, r, s3errors.ErrMalformedXML.WithMessage("The move request must name a TargetBucket."))
+			return
+		}
+		if len(moveReq.Objects) > maxMoveObjects {
+			s3errors.WriteError(w, r, s3errors.ErrMalformedXML.WithMessage(fmt.Sprintf("A move request can contain at most %d keys.", maxMoveObjects)))
 			return
 		}
 
-		var movedObjects []dto.Deleted
+		moveResult := dto.MoveResult{Xmlns: dto.S3Namespace}
 		for _, objectToMove := range moveReq.Objects {
 			log.Printf("Attempting to move object: %s", objectToMove.Key)
 
-			// Copier l'objet
-			err := s.CopyObject(sourceBucket, objectToMove.Key, moveReq.TargetBucket, objectToMove.Key)
-			if err != nil {
-				http.Error(w, "Error moving object", http.StatusInternalServerError)
-				log.Printf("Error moving object %s: %v", objectToMove.Key, err)
-				return
+			// Déplacer un objet revient à le lire et le supprimer de la source, puis à l'écrire dans la cible
+			err := checkAccess(r, s, policy.GetObject, sourceBucket, objectToMove.Key, "")
+			if err == nil {
+				err = checkAccess(r, s, policy.DeleteObject, sourceBucket, objectToMove.Key, "")
+			}
+			if err == nil {
+				err = checkAccess(r, s, policy.PutObject, moveReq.TargetBucket, objectToMove.Key, "")
+			}
+			var info dto.ObjectInfo
+			if err == nil {
+				info, err = s.MoveObject(sourceBucket, objectToMove.Key, moveReq.TargetBucket, objectToMove.Key, nil)
 			}
-
-			// Supprimer l'objet source
-			err = s.DeleteObject(sourceBucket, objectToMove.Key)
 			if err != nil {
-				http.Error(w, "Error deleting source object after move", http.StatusInternalServerError)
-				log.Printf("Error deleting object %s: %v", objectToMove.Key, err)
-				return
+				moveResult.Errors = append(moveResult.Errors, batchError(objectToMove.Key, "", err))
+				continue
 			}
 
 			log.Printf("Successfully moved object: %s", objectToMove.Key)
-			movedObjects = append(movedObjects, dto.Deleted{Key: objectToMove.Key})
-		}
-
-		moveResult := dto.DeleteResult{
-			DeletedResult: movedObjects,
+			moveResult.Moved = append(moveResult.Moved, dto.Moved{Key: objectToMove.Key, VersionId: info.VersionID})
 		}
 
-		response, err := xml.Marshal(moveResult)
-		if err != nil {
-			http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-			log.Printf("Error generating XML response: %v", err)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/xml")
-		w.WriteHeader(http.StatusOK)
-		w.Write(response)
-
-		// Log response status and body
-		log.Printf("Response status: %d", http.StatusOK)
-		log.Printf("Response body: %s", string(response))
+		writeXML(w, moveResult)
 	}
-}
\ No newline at end of file
+}
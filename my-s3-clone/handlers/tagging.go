@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// En-têtes du tagging d'objet
+const (
+	taggingHeader          = "X-Amz-Tagging"
+	taggingCountHeader     = "X-Amz-Tagging-Count"
+	taggingDirectiveHeader = "X-Amz-Tagging-Directive"
+)
+
+// Limites du tagging d'objet, comme S3
+const (
+	maxObjectTags     = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+var errTooManyTags = errors.New("Object tags cannot be greater than 10")
+
+// tagsFromSet valide les tags d'un objet et les renvoie indexés par clé ; nil s'il n'y en a aucun
+func tagsFromSet(tagSet []dto.Tag) (map[string]string, error) {
+	if len(tagSet) > maxObjectTags {
+		return nil, errTooManyTags
+	}
+	if len(tagSet) == 0 {
+		return nil, nil
+	}
+
+	tags := make(map[string]string, len(tagSet))
+	for _, tag := range tagSet {
+		if tag.Key == "" || utf8.RuneCountInString(tag.Key) > maxTagKeyLength {
+			return nil, errors.New("The TagKey you have provided is invalid")
+		}
+		if utf8.RuneCountInString(tag.Value) > maxTagValueLength {
+			return nil, errors.New("The TagValue you have provided is invalid")
+		}
+		if _, ok := tags[tag.Key]; ok {
+			return nil, errors.New("Cannot provide multiple Tags with the same key")
+		}
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
+// tagsFromRequest lit les tags de l'en-tête x-amz-tagging, encodés comme une query string
+// (cle1=valeur1&cle2=valeur2). Renvoie nil si la requête n'en porte pas.
+func tagsFromRequest(r *http.Request) (map[string]string, error) {
+	header := r.Header.Get(taggingHeader)
+	if header == "" {
+		return nil, nil
+	}
+	values, err := url.ParseQuery(header)
+	if err != nil {
+		return nil, errors.New("The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+	}
+
+	var tagSet []dto.Tag
+	for key, keyValues := range values {
+		if len(keyValues) > 1 {
+			return nil, errors.New("Cannot provide multiple Tags with the same key")
+		}
+		tagSet = append(tagSet, dto.Tag{Key: key, Value: keyValues[0]})
+	}
+	return tagsFromSet(tagSet)
+}
+
+// writeTaggingError écrit l'erreur S3 correspondant à des tags refusés
+func writeTaggingError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errTooManyTags) {
+		s3errors.WriteError(w, r, s3errors.ErrBadRequest.WithMessage(err.Error()))
+		return
+	}
+	s3errors.WriteError(w, r, s3errors.ErrInvalidTag.WithMessage(err.Error()))
+}
+
+// setTaggingCountHeader indique dans la réponse le nombre de tags de l'objet
+func setTaggingCountHeader(w http.ResponseWriter, tags map[string]string) {
+	if len(tags) > 0 {
+		w.Header().Set(taggingCountHeader, strconv.Itoa(len(tags)))
+	}
+}
+
+// HandlePutObjectTagging replaces the tags of an object version (PUT /{bucket}/{key}?tagging)
+func HandlePutObjectTagging(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		var tagging dto.Tagging
+		if !readXMLBody(w, r, &tagging) {
+			return
+		}
+		tags, err := tagsFromSet(tagging.TagSet.Tags)
+		if err != nil {
+			writeTaggingError(w, r, err)
+			return
+		}
+
+		versionID := r.URL.Query().Get("versionId")
+		err = s.UpdateObjectMetadata(vars["bucketName"], vars["objectName"], versionID, func(metadata *dto.ObjectMetadata) error {
+			metadata.Tags = tags
+			return nil
+		})
+		if err != nil {
+			writeObjectError(w, r, dto.ObjectInfo{}, err)
+			return
+		}
+		setVersionIDHeader(w, versionID)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleGetObjectTagging returns the tags of an object version, sorted by key (GET /{bucket}/{key}?tagging)
+func HandleGetObjectTagging(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		versionID := r.URL.Query().Get("versionId")
+
+		tags, err := storage.ObjectTags(s, vars["bucketName"], vars["objectName"], versionID)
+		if err != nil {
+			writeObjectError(w, r, dto.ObjectInfo{}, err)
+			return
+		}
+
+		tagging := dto.Tagging{Xmlns: dto.S3Namespace}
+		for key, value := range tags {
+			tagging.TagSet.Tags = append(tagging.TagSet.Tags, dto.Tag{Key: key, Value: value})
+		}
+		sort.Slice(tagging.TagSet.Tags, func(i, j int) bool { return tagging.TagSet.Tags[i].Key < tagging.TagSet.Tags[j].Key })
+
+		setVersionIDHeader(w, versionID)
+		writeXML(w, tagging)
+	}
+}
+
+// HandleDeleteObjectTagging removes all the tags of an object version (DELETE /{bucket}/{key}?tagging)
+func HandleDeleteObjectTagging(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		versionID := r.URL.Query().Get("versionId")
+
+		err := s.UpdateObjectMetadata(vars["bucketName"], vars["objectName"], versionID, func(metadata *dto.ObjectMetadata) error {
+			metadata.Tags = nil
+			return nil
+		})
+		if err != nil {
+			writeObjectError(w, r, dto.ObjectInfo{}, err)
+			return
+		}
+		setVersionIDHeader(w, versionID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
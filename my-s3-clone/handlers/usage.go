@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/quota"
+	"my-s3-clone/s3errors"
+)
+
+// HandleGetUsage returns the space used by each bucket and each owner, with their quotas (GET /?usage)
+func HandleGetUsage(qs *quota.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if qs == nil {
+			s3errors.WriteError(w, r, s3errors.ErrNotImplemented.WithMessage("Usage tracking is not enabled on this server."))
+			return
+		}
+		report := qs.Usage()
+		report.Xmlns = dto.S3Namespace
+		writeXML(w, report)
+	}
+}
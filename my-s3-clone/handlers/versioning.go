@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// setVersionIDHeader renseigne x-amz-version-id si l'objet est versionné
+func setVersionIDHeader(w http.ResponseWriter, versionID string) {
+	if versionID != "" {
+		w.Header().Set("x-amz-version-id", versionID)
+	}
+}
+
+// writeObjectError traduit une erreur de lecture d'objet ; une version qui est un marqueur
+// de suppression renvoie 405 avec x-amz-delete-marker, comme S3
+func writeObjectError(w http.ResponseWriter, r *http.Request, info dto.ObjectInfo, err error) {
+	if errors.Is(err, storage.ErrDeleteMarker) {
+		w.Header().Set("x-amz-delete-marker", "true")
+		setVersionIDHeader(w, info.VersionID)
+		w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+		s3errors.WriteError(w, r, s3errors.ErrMethodNotAllowed)
+		return
+	}
+	writeStorageError(w, r, err)
+}
+
+// Delete a single object or one of its versions (DELETE /{bucket}/{key}[?versionId=ID])
+func HandleDeleteSingleObject(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucketName := vars["bucketName"]
+		objectName := vars["objectName"]
+		versionID := r.URL.Query().Get("versionId")
+
+		log.Printf("Deleting object %s/%s (version %q)", bucketName, objectName, versionID)
+
+		result, err := objectLockStorage(s, r).DeleteObject(bucketName, objectName, versionID)
+		if err != nil {
+			// Supprimer un objet absent n'est pas une erreur pour S3
+			if errors.Is(err, os.ErrNotExist) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			writeStorageError(w, r, err)
+			return
+		}
+
+		setVersionIDHeader(w, result.VersionID)
+		if result.DeleteMarker {
+			w.Header().Set("x-amz-delete-marker", "true")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Set the versioning state of a bucket (PUT /{bucket}?versioning)
+func HandlePutBucketVersioning(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketName := mux.Vars(r)["bucketName"]
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrInternalError)
+			return
+		}
+
+		var config dto.VersioningConfiguration
+		if err := xml.Unmarshal(body, &config); err != nil {
+			s3errors.WriteError(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+
+		if err := s.PutBucketVersioning(bucketName, config.Status); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Get the versioning state of a bucket (GET /{bucket}?versioning)
+func HandleGetBucketVersioning(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := s.GetBucketVersioning(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		// Un bucket jamais versionné renvoie une configuration vide
+		writeXML(w, dto.VersioningConfiguration{
+			Xmlns:  dto.S3Namespace,
+			Status: status,
+		})
+	}
+}
+
+// List all versions and delete markers of a bucket (GET /{bucket}?versions)
+func HandleListObjectVersions(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		maxKeys, err := parseIntParam(r, "max-keys", 1000)
+		if err != nil || maxKeys < 0 {
+			s3errors.WriteError(w, r, s3errors.ErrInvalidArgument.WithMessage("max-keys must be a non-negative integer: "+strconv.Quote(query.Get("max-keys"))))
+			return
+		}
+
+		result, err := s.ListObjectVersions(mux.Vars(r)["bucketName"], query.Get("prefix"), query.Get("key-marker"), query.Get("version-id-marker"), maxKeys)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		writeXML(w, result)
+	}
+}
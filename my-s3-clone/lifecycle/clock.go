@@ -0,0 +1,52 @@
+package lifecycle
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock fournit l'heure au sweeper. En production c'est l'horloge système ; les tests utilisent
+// une ManualClock pour décider exactement quand les règles arrivent à échéance.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock est l'horloge réelle
+var SystemClock Clock = systemClock{}
+
+// ManualClock est une horloge qui n'avance que lorsqu'on le lui demande
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManualClock crée une horloge arrêtée à l'instant now
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance avance l'horloge de d
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set règle l'horloge à l'instant now
+func (c *ManualClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
@@ -0,0 +1,161 @@
+package lifecycle
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// Limites imposées par S3 sur une configuration de cycle de vie
+const (
+	maxRules    = 1000
+	maxIDLength = 255
+)
+
+// États d'une règle
+const (
+	StatusEnabled  = "Enabled"
+	StatusDisabled = "Disabled"
+)
+
+// ConfigError décrit une configuration refusée. Malformed distingue un document qui ne respecte
+// pas le schéma (MalformedXML) d'une règle dont les valeurs sont invalides (InvalidArgument).
+type ConfigError struct {
+	Malformed bool
+	Message   string
+}
+
+func (e *ConfigError) Error() string {
+	return e.Message
+}
+
+func malformed(format string, args ...interface{}) error {
+	return &ConfigError{Malformed: true, Message: fmt.Sprintf(format, args...)}
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &ConfigError{Message: fmt.Sprintf(format, args...)}
+}
+
+// Validate vérifie une configuration de cycle de vie avant son enregistrement
+func Validate(config dto.LifecycleConfiguration) error {
+	if len(config.Rules) == 0 {
+		return malformed("The lifecycle configuration must contain at least one rule")
+	}
+	if len(config.Rules) > maxRules {
+		return invalid("The lifecycle configuration cannot have more than %d rules", maxRules)
+	}
+
+	ids := make(map[string]bool)
+	for _, rule := range config.Rules {
+		if len(rule.ID) > maxIDLength {
+			return invalid("ID length should not exceed allowed limit of %d", maxIDLength)
+		}
+		if rule.ID != "" {
+			if ids[rule.ID] {
+				return invalid("Rule ID must be unique. Found same ID for more than one rule")
+			}
+			ids[rule.ID] = true
+		}
+		if err := validateRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateRule(rule dto.LifecycleRule) error {
+	if rule.Status != StatusEnabled && rule.Status != StatusDisabled {
+		return malformed("Rule status must be Enabled or Disabled")
+	}
+	if rule.Prefix != nil && rule.Filter != nil {
+		return malformed("A rule cannot have both a Prefix and a Filter")
+	}
+	if filter := rule.Filter; filter != nil {
+		set := 0
+		for _, present := range []bool{filter.Prefix != nil, filter.Tag != nil, filter.And != nil} {
+			if present {
+				set++
+			}
+		}
+		if set > 1 {
+			return malformed("A Filter must contain exactly one of Prefix, Tag or And")
+		}
+	}
+
+	if rule.Expiration == nil && rule.NoncurrentVersionExpiration == nil && rule.AbortIncompleteMultipartUpload == nil {
+		return invalid("At least one action needs to be specified in a rule")
+	}
+	if expiration := rule.Expiration; expiration != nil {
+		if (expiration.Days != 0) == (expiration.Date != nil) {
+			return malformed("Expiration must specify exactly one of Days or Date")
+		}
+		if expiration.Days < 0 {
+			return invalid("'Days' for Expiration action must be a positive integer")
+		}
+		if date := expiration.Date; date != nil && !date.Equal(date.UTC().Truncate(24*time.Hour)) {
+			return invalid("'Date' must be at midnight GMT")
+		}
+	}
+	if noncurrent := rule.NoncurrentVersionExpiration; noncurrent != nil && noncurrent.NoncurrentDays <= 0 {
+		return invalid("'NoncurrentDays' for NoncurrentVersionExpiration action must be a positive integer")
+	}
+	if abort := rule.AbortIncompleteMultipartUpload; abort != nil {
+		if abort.DaysAfterInitiation <= 0 {
+			return invalid("'DaysAfterInitiation' for AbortIncompleteMultipartUpload action must be a positive integer")
+		}
+		if len(ruleTags(rule)) > 0 {
+			return invalid("AbortIncompleteMultipartUpload cannot be specified with Tags")
+		}
+	}
+	return nil
+}
+
+// rulePrefix renvoie le préfixe des clés concernées par la règle
+func rulePrefix(rule dto.LifecycleRule) string {
+	switch {
+	case rule.Prefix != nil:
+		return *rule.Prefix
+	case rule.Filter == nil:
+		return ""
+	case rule.Filter.Prefix != nil:
+		return *rule.Filter.Prefix
+	case rule.Filter.And != nil:
+		return rule.Filter.And.Prefix
+	}
+	return ""
+}
+
+// ruleTags renvoie les tags que doit porter un objet pour être concerné par la règle
+func ruleTags(rule dto.LifecycleRule) []dto.Tag {
+	switch {
+	case rule.Filter == nil:
+		return nil
+	case rule.Filter.Tag != nil:
+		return []dto.Tag{*rule.Filter.Tag}
+	case rule.Filter.And != nil:
+		return rule.Filter.And.Tags
+	}
+	return nil
+}
+
+// Matches indique si la règle s'applique à l'objet de clé key portant les tags donnés
+func Matches(rule dto.LifecycleRule, key string, tags map[string]string) bool {
+	if !strings.HasPrefix(key, rulePrefix(rule)) {
+		return false
+	}
+	for _, tag := range ruleTags(rule) {
+		if value, ok := tags[tag.Key]; !ok || value != tag.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// expiresAt renvoie l'échéance d'une action qui s'applique days jours après t. Comme S3,
+// l'échéance est arrondie au minuit UTC suivant.
+func expiresAt(t time.Time, days int) time.Time {
+	return t.UTC().Add(time.Duration(days) * 24 * time.Hour).Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
@@ -0,0 +1,255 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/storage"
+)
+
+// Sweeper applique périodiquement les règles de cycle de vie de tous les buckets.
+// Il ne passe que par l'interface Storage : il fonctionne donc avec tous les backends.
+type Sweeper struct {
+	storage storage.Storage
+	clock   Clock
+}
+
+// Stats compte les actions effectuées lors d'un passage du sweeper
+type Stats struct {
+	Expired           int
+	NoncurrentExpired int
+	AbortedUploads    int
+}
+
+// NewSweeper crée un sweeper pour le stockage donné
+func NewSweeper(s storage.Storage, clock Clock) *Sweeper {
+	return &Sweeper{storage: s, clock: clock}
+}
+
+// Run effectue un passage immédiatement, puis toutes les interval, jusqu'à l'annulation de ctx
+func (sw *Sweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		stats, err := sw.Sweep()
+		if err != nil {
+			log.Printf("Lifecycle sweep failed: %v", err)
+		}
+		if stats != (Stats{}) {
+			log.Printf("Lifecycle sweep: %d object(s) expired, %d noncurrent version(s) deleted, %d upload(s) aborted",
+				stats.Expired, stats.NoncurrentExpired, stats.AbortedUploads)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep effectue un passage sur tous les buckets, à l'heure donnée par l'horloge. Une erreur sur
+// un bucket n'empêche pas de traiter les suivants ; les erreurs sont renvoyées ensemble.
+func (sw *Sweeper) Sweep() (Stats, error) {
+	var stats Stats
+	var errs []error
+	now := sw.clock.Now()
+
+	for _, bucketName := range sw.storage.ListBuckets() {
+		config, err := sw.storage.GetBucketLifecycle(bucketName)
+		if errors.Is(err, storage.ErrNoSuchLifecycle) || errors.Is(err, storage.ErrNoSuchBucket) {
+			continue
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("bucket %s: %w", bucketName, err))
+			continue
+		}
+
+		for _, rule := range config.Rules {
+			if rule.Status != StatusEnabled {
+				continue
+			}
+			if err := sw.applyRule(bucketName, rule, now, &stats); err != nil {
+				errs = append(errs, fmt.Errorf("bucket %s, rule %q: %w", bucketName, rule.ID, err))
+			}
+		}
+	}
+	return stats, errors.Join(errs...)
+}
+
+func (sw *Sweeper) applyRule(bucketName string, rule dto.LifecycleRule, now time.Time, stats *Stats) error {
+	if rule.Expiration != nil {
+		if err := sw.expireCurrent(bucketName, rule, now, stats); err != nil {
+			return err
+		}
+	}
+	if rule.NoncurrentVersionExpiration != nil {
+		if err := sw.expireNoncurrent(bucketName, rule, now, stats); err != nil {
+			return err
+		}
+	}
+	if rule.AbortIncompleteMultipartUpload != nil {
+		if err := sw.abortIncomplete(bucketName, rule, now, stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expired indique si l'action Expiration de la règle est échue pour un objet modifié à lastModified
+func expired(expiration *dto.LifecycleExpiration, lastModified, now time.Time) bool {
+	if expiration.Date != nil {
+		return !now.Before(*expiration.Date)
+	}
+	return !now.Before(expiresAt(lastModified, expiration.Days))
+}
+
+// expireCurrent supprime les objets dont la version courante a expiré. Dans un bucket versionné,
+// la suppression ajoute un marqueur : les données restent accessibles par leur version.
+func (sw *Sweeper) expireCurrent(bucketName string, rule dto.LifecycleRule, now time.Time, stats *Stats) error {
+	var keys []string
+	marker := ""
+	for {
+		response, err := sw.storage.ListObjects(bucketName, rulePrefix(rule), "", marker, storage.MaxListKeys)
+		if err != nil {
+			return err
+		}
+		for _, object := range response.Contents {
+			if expired(rule.Expiration, object.LastModified, now) && sw.matches(bucketName, rule, object.Key, "") {
+				keys = append(keys, object.Key)
+			}
+		}
+		if !response.IsTruncated {
+			break
+		}
+		marker = response.NextMarker
+	}
+
+	for _, key := range keys {
+		if _, err := sw.storage.DeleteObject(bucketName, key, ""); errors.Is(err, storage.ErrObjectLocked) {
+			// Un objet verrouillé expirera au passage qui suit la fin de son verrouillage
+			continue
+		} else if err != nil {
+			return err
+		}
+		log.Printf("Lifecycle rule %q expired %s/%s", rule.ID, bucketName, key)
+		stats.Expired++
+	}
+	return nil
+}
+
+// matches indique si la règle s'applique à une version d'objet (la version courante si versionID
+// est vide). Les tags de la version ne sont lus que si la règle filtre par tag.
+func (sw *Sweeper) matches(bucketName string, rule dto.LifecycleRule, key, versionID string) bool {
+	if len(ruleTags(rule)) == 0 || !strings.HasPrefix(key, rulePrefix(rule)) {
+		return Matches(rule, key, nil)
+	}
+	tags, err := storage.ObjectTags(sw.storage, bucketName, key, versionID)
+	if err != nil {
+		return false
+	}
+	return Matches(rule, key, tags)
+}
+
+// versionEntry est une version (ou un marqueur de suppression) d'un objet
+type versionEntry struct {
+	versionID    string
+	lastModified time.Time
+	latest       bool
+}
+
+// expireNoncurrent supprime définitivement les versions remplacées depuis plus de NoncurrentDays
+// jours. Une version devient non courante au moment où la version suivante est écrite.
+func (sw *Sweeper) expireNoncurrent(bucketName string, rule dto.LifecycleRule, now time.Time, stats *Stats) error {
+	versions := make(map[string][]versionEntry)
+	keyMarker, versionIDMarker := "", ""
+	for {
+		result, err := sw.storage.ListObjectVersions(bucketName, rulePrefix(rule), keyMarker, versionIDMarker, storage.MaxListKeys)
+		if err != nil {
+			return err
+		}
+		for _, version := range result.Versions {
+			versions[version.Key] = append(versions[version.Key], versionEntry{version.VersionId, version.LastModified, version.IsLatest})
+		}
+		for _, marker := range result.DeleteMarkers {
+			versions[marker.Key] = append(versions[marker.Key], versionEntry{marker.VersionId, marker.LastModified, marker.IsLatest})
+		}
+		if !result.IsTruncated {
+			break
+		}
+		keyMarker, versionIDMarker = result.NextKeyMarker, result.NextVersionIdMarker
+	}
+
+	keys := make([]string, 0, len(versions))
+	for key := range versions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	days := rule.NoncurrentVersionExpiration.NoncurrentDays
+	for _, key := range keys {
+		entries := versions[key]
+		// Versions et marqueurs sont listés séparément : on reconstitue l'ordre, de la plus récente à la plus ancienne
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].latest != entries[j].latest {
+				return entries[i].latest
+			}
+			return entries[i].lastModified.After(entries[j].lastModified)
+		})
+
+		for i := 1; i < len(entries); i++ {
+			if now.Before(expiresAt(entries[i-1].lastModified, days)) {
+				continue
+			}
+			// Chaque version a ses propres tags ; un marqueur de suppression n'en a pas
+			if !sw.matches(bucketName, rule, key, entries[i].versionID) {
+				continue
+			}
+			_, err := sw.storage.DeleteObject(bucketName, key, entries[i].versionID)
+			if errors.Is(err, storage.ErrNoSuchVersion) || errors.Is(err, storage.ErrObjectLocked) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			log.Printf("Lifecycle rule %q deleted noncurrent version %s of %s/%s", rule.ID, entries[i].versionID, bucketName, key)
+			stats.NoncurrentExpired++
+		}
+	}
+	return nil
+}
+
+// abortIncomplete abandonne les uploads multipart démarrés depuis plus de DaysAfterInitiation jours
+func (sw *Sweeper) abortIncomplete(bucketName string, rule dto.LifecycleRule, now time.Time, stats *Stats) error {
+	var uploads []dto.Upload
+	keyMarker, uploadIDMarker := "", ""
+	for {
+		result, err := sw.storage.ListMultipartUploads(bucketName, rulePrefix(rule), keyMarker, uploadIDMarker, storage.MaxListKeys)
+		if err != nil {
+			return err
+		}
+		for _, upload := range result.Uploads {
+			if !now.Before(expiresAt(upload.Initiated, rule.AbortIncompleteMultipartUpload.DaysAfterInitiation)) {
+				uploads = append(uploads, upload)
+			}
+		}
+		if !result.IsTruncated {
+			break
+		}
+		keyMarker, uploadIDMarker = result.NextKeyMarker, result.NextUploadIdMarker
+	}
+
+	for _, upload := range uploads {
+		if err := sw.storage.AbortMultipartUpload(bucketName, upload.Key, upload.UploadId); err != nil && !errors.Is(err, storage.ErrNoSuchUpload) {
+			return err
+		}
+		log.Printf("Lifecycle rule %q aborted upload %s of %s/%s", rule.ID, upload.UploadId, bucketName, upload.Key)
+		stats.AbortedUploads++
+	}
+	return nil
+}
@@ -4,52 +4,63 @@ import (
     "net/http"
     "strings"
     "log"
-    "bytes"
+    "errors"
+    "github.com/gorilla/mux"
+    "my-s3-clone/auth"
+    "my-s3-clone/cors"
+    "my-s3-clone/s3errors"
+    "my-s3-clone/storage"
 )
-// CorsMiddleware permet de configurer les en-têtes CORS
-func CORSMiddleware(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        // Autoriser les origines spécifiques
-        w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-        
-        // Autoriser les méthodes HTTP
-        w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-        
-        // Autoriser les en-têtes spécifiques
-        w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Amz-Content-Sha256, X-Amz-Decoded-Content-Length")
-        
-        // Gérer les requêtes preflight (OPTIONS)
-        if r.Method == "OPTIONS" {
-            w.WriteHeader(http.StatusOK)
-            return
-        }
-
-        next.ServeHTTP(w, r)
-    })
-}
+// CORSMiddleware ajoute les en-têtes CORS aux requêtes cross-origin (en-tête Origin) autorisées
+// par les règles du bucket visé. Les preflights (OPTIONS) sont traités par HandleCORSPreflight.
+func CORSMiddleware(s storage.Storage) mux.MiddlewareFunc {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            origin := r.Header.Get("Origin")
+            bucketName := mux.Vars(r)["bucketName"]
+            if origin != "" && bucketName != "" && r.Method != http.MethodOptions {
+                if config, err := s.GetBucketCors(bucketName); err == nil {
+                    if rule := cors.Match(config, origin, r.Method, nil); rule != nil {
+                        cors.SetHeaders(w.Header(), rule, origin)
+                    }
+                }
+            }
 
-func BasicAuthMiddleware(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        if strings.HasPrefix(r.URL.Path, "/probe-bsign") {
             next.ServeHTTP(w, r)
-            return
-        }
+        })
+    }
+}
 
-        // Accepter les requêtes avec AWS4-HMAC-SHA256 dans l'en-tête Authorization
-        if strings.Contains(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
-            next.ServeHTTP(w, r)
-            return
-        }
+// AuthMiddleware vérifie la signature AWS SigV4 (en-tête Authorization ou URL présignée) de chaque requête.
+// Si verifier est nil, aucune access key n'est configurée et l'authentification est désactivée.
+// Une requête sans signature est transmise comme anonyme, à charge pour les handlers de l'autoriser.
+func AuthMiddleware(verifier *auth.Verifier) mux.MiddlewareFunc {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if verifier == nil || strings.HasPrefix(r.URL.Path, "/probe-bsign") || r.Method == http.MethodOptions {
+                next.ServeHTTP(w, r)
+                return
+            }
 
-        // Appliquer l'authentification basique pour les autres routes
-        user, pass, ok := r.BasicAuth()
-        if !ok || user != "accessuser" || pass != "accesspassword" {
-            http.Error(w, "Unauthorized", http.StatusUnauthorized)
-            return
-        }
+            signing, err := verifier.Verify(r)
+            if errors.Is(err, auth.ErrMissingAuth) {
+                // Requête anonyme : c'est la politique ou l'ACL de la ressource qui décidera
+                next.ServeHTTP(w, r.WithContext(auth.WithAnonymous(r.Context())))
+                return
+            }
+            if err != nil {
+                log.Printf("Authentification refusée pour %s %s : %v", r.Method, r.URL.Path, err)
+                apiErr, ok := auth.ToAPIError(err)
+                if !ok {
+                    apiErr = s3errors.ErrAccessDenied
+                }
+                s3errors.WriteError(w, r, apiErr)
+                return
+            }
 
-        next.ServeHTTP(w, r)
-    })
+            next.ServeHTTP(w, r.WithContext(auth.WithSigningContext(r.Context(), signing)))
+        })
+    }
 }
 
 func LogRequestMiddleware(next http.Handler) http.Handler {
@@ -64,10 +75,12 @@ func LogRequestMiddleware(next http.Handler) http.Handler {
     })
 }
 
+// loggingResponseWriter retient le statut et la taille de la réponse, sans conserver son contenu :
+// les objets sont transmis en streaming et peuvent peser plusieurs gigaoctets
 type loggingResponseWriter struct {
     http.ResponseWriter
     statusCode int
-    responseBody bytes.Buffer
+    bytesWritten int64
 }
 
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
@@ -76,8 +89,9 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 }
 
 func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
-    lrw.responseBody.Write(b) 
-    return lrw.ResponseWriter.Write(b) 
+    n, err := lrw.ResponseWriter.Write(b)
+    lrw.bytesWritten += int64(n)
+    return n, err
 }
 
 func LogResponseMiddleware(next http.Handler) http.Handler {
@@ -86,7 +100,6 @@ func LogResponseMiddleware(next http.Handler) http.Handler {
         next.ServeHTTP(lrw, r)
         
         // Log la réponse
-        log.Printf("Response status: %d", lrw.statusCode)
-        log.Printf("Response body: %s", lrw.responseBody.String())
+        log.Printf("Response status: %d (%d bytes)", lrw.statusCode, lrw.bytesWritten)
     })
 }
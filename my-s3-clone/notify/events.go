@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// Types d'événements publiés, tels qu'ils s'écrivent dans une configuration de notification.
+// Dans les enregistrements envoyés, le préfixe "s3:" est omis, comme dans S3.
+const (
+	ObjectCreatedPut                     = "s3:ObjectCreated:Put"
+	ObjectCreatedCopy                    = "s3:ObjectCreated:Copy"
+	ObjectCreatedCompleteMultipartUpload = "s3:ObjectCreated:CompleteMultipartUpload"
+	ObjectRemovedDelete                  = "s3:ObjectRemoved:Delete"
+	ObjectRemovedDeleteMarkerCreated     = "s3:ObjectRemoved:DeleteMarkerCreated"
+)
+
+// Familles d'événements : "s3:ObjectCreated:*" désigne tous les types de la famille
+var eventFamilies = map[string][]string{
+	"s3:ObjectCreated:*": {ObjectCreatedPut, ObjectCreatedCopy, ObjectCreatedCompleteMultipartUpload},
+	"s3:ObjectRemoved:*": {ObjectRemovedDelete, ObjectRemovedDeleteMarkerCreated},
+}
+
+// ValidEvent indique si event est un type ou une famille d'événements pris en charge
+func ValidEvent(event string) bool {
+	if _, ok := eventFamilies[event]; ok {
+		return true
+	}
+	for _, events := range eventFamilies {
+		for _, known := range events {
+			if event == known {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Matches indique si un événement de type eventName sur la clé key doit être envoyé au webhook
+func Matches(webhook dto.WebhookConfiguration, eventName, key string) bool {
+	if webhook.Filter != nil {
+		for _, rule := range webhook.Filter.Key.Rules {
+			switch strings.ToLower(rule.Name) {
+			case "prefix":
+				if !strings.HasPrefix(key, rule.Value) {
+					return false
+				}
+			case "suffix":
+				if !strings.HasSuffix(key, rule.Value) {
+					return false
+				}
+			}
+		}
+	}
+
+	for _, event := range webhook.Events {
+		if event == eventName {
+			return true
+		}
+		for _, member := range eventFamilies[event] {
+			if member == eventName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Record est un enregistrement d'événement au format des notifications S3 (version 2.1)
+type Record struct {
+	EventVersion string   `json:"eventVersion"`
+	EventSource  string   `json:"eventSource"`
+	AwsRegion    string   `json:"awsRegion"`
+	EventTime    string   `json:"eventTime"`
+	EventName    string   `json:"eventName"`
+	S3           RecordS3 `json:"s3"`
+}
+
+// RecordS3 décrit le bucket et l'objet concernés par l'événement
+type RecordS3 struct {
+	SchemaVersion   string       `json:"s3SchemaVersion"`
+	ConfigurationID string       `json:"configurationId"`
+	Bucket          RecordBucket `json:"bucket"`
+	Object          RecordObject `json:"object"`
+}
+
+// RecordBucket identifie le bucket de l'événement
+type RecordBucket struct {
+	Name string `json:"name"`
+	Arn  string `json:"arn"`
+}
+
+// RecordObject identifie la version d'objet de l'événement
+type RecordObject struct {
+	// Clé encodée comme un paramètre de formulaire, sans encoder les "/", comme dans S3
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	// Valeur hexadécimale croissante qui permet d'ordonner les événements d'une même clé
+	Sequencer string `json:"sequencer"`
+}
+
+// Payload est le corps JSON envoyé au webhook
+type Payload struct {
+	Records []Record `json:"Records"`
+}
+
+var lastSequence int64
+
+// nextSequencer renvoie un séquenceur strictement croissant, dérivé de l'heure
+func nextSequencer(now time.Time) string {
+	for {
+		last := atomic.LoadInt64(&lastSequence)
+		next := now.UnixNano()
+		if next <= last {
+			next = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&lastSequence, last, next) {
+			return fmt.Sprintf("%016X", next)
+		}
+	}
+}
+
+// newRecord crée l'enregistrement d'un événement sur une version d'objet
+func newRecord(region, configurationID, eventName, bucketName string, info dto.ObjectInfo, now time.Time) Record {
+	return Record{
+		EventVersion: "2.1",
+		EventSource:  "aws:s3",
+		AwsRegion:    region,
+		EventTime:    now.UTC().Format("2006-01-02T15:04:05.000Z"),
+		EventName:    strings.TrimPrefix(eventName, "s3:"),
+		S3: RecordS3{
+			SchemaVersion:   "1.0",
+			ConfigurationID: configurationID,
+			Bucket:          RecordBucket{Name: bucketName, Arn: "arn:aws:s3:::" + bucketName},
+			Object: RecordObject{
+				Key:       strings.ReplaceAll(url.QueryEscape(info.Key), "%2F", "/"),
+				Size:      info.Size,
+				ETag:      info.ETag,
+				VersionID: info.VersionID,
+				Sequencer: nextSequencer(now),
+			},
+		},
+	}
+}
@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// Sous-répertoire de la file où sont enregistrées les intentions
+const intentDir = "intents"
+
+// intent annonce les événements d'une opération avant qu'elle ne modifie le backend. Elle est
+// supprimée une fois l'opération terminée et ses événements mis en file ; celle qu'un arrêt du
+// serveur laisse sur disque est rejouée au démarrage suivant d'après l'état du backend.
+type intent struct {
+	Events  []intendedEvent `json:"events"`
+	Created time.Time       `json:"created"`
+}
+
+// intendedEvent est un événement annoncé, avec l'état de l'objet concerné avant l'opération
+// (nil s'il n'existait pas)
+type intendedEvent struct {
+	Bucket    string       `json:"bucket"`
+	Key       string       `json:"key"`
+	VersionID string       `json:"versionId,omitempty"`
+	EventName string       `json:"eventName"`
+	Before    *objectState `json:"before,omitempty"`
+}
+
+// objectState identifie le contenu d'un objet, pour savoir si une opération l'a remplacé
+type objectState struct {
+	ETag         string    `json:"etag"`
+	VersionID    string    `json:"versionId,omitempty"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+func stateOf(info dto.ObjectInfo) *objectState {
+	return &objectState{ETag: info.ETag, VersionID: info.VersionID, LastModified: info.LastModified}
+}
+
+func (s *objectState) same(other *objectState) bool {
+	return s.ETag == other.ETag && s.VersionID == other.VersionID && s.LastModified.Equal(other.LastModified)
+}
+
+// writeIntent enregistre une intention sur disque et renvoie son nom
+func (q *Queue) writeIntent(i intent) (string, error) {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return "", err
+	}
+	name, err := fileName(i.Created)
+	if err != nil {
+		return "", err
+	}
+	if err := writeFile(filepath.Join(q.dir, intentDir), name, data); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// dropIntent supprime une intention ; un nom vide ne désigne aucune intention
+func (q *Queue) dropIntent(name string) {
+	if name == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(q.dir, intentDir, name)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove event intent %s: %v", name, err)
+	}
+}
+
+// announce enregistre l'intention des événements qui concernent un webhook, avant l'opération qui
+// les produit, et renvoie son nom ("" si aucun ne concerne de webhook). L'état de chaque objet est
+// noté pour savoir au démarrage si l'opération a eu lieu.
+func (ns *Storage) announce(events ...intendedEvent) (string, error) {
+	var announced []intendedEvent
+	for _, event := range events {
+		if !ns.notified(event.Bucket, event.EventName, event.Key) {
+			continue
+		}
+		if info, err := ns.Storage.StatObject(event.Bucket, event.Key, event.VersionID); err == nil {
+			event.Before = stateOf(info)
+		}
+		announced = append(announced, event)
+	}
+	if len(announced) == 0 {
+		return "", nil
+	}
+	return ns.queue.writeIntent(intent{Events: announced, Created: time.Now()})
+}
+
+// notified indique si un webhook du bucket attend l'événement. Une configuration illisible ne
+// bloque pas l'opération : publish journalisera l'erreur.
+func (ns *Storage) notified(bucketName, eventName, key string) bool {
+	webhooks, err := ns.webhooks(bucketName, eventName, key)
+	return err == nil && len(webhooks) > 0
+}
+
+// replayIntents publie les événements des opérations interrompues par un arrêt du serveur : une
+// création si l'objet a changé depuis l'intention, une suppression si l'objet qui existait a
+// disparu. Une réécriture à l'identique d'un objet non versionné ne se distingue pas d'une
+// opération qui n'a pas eu lieu et n'est pas publiée.
+func (ns *Storage) replayIntents() {
+	dir := filepath.Join(ns.queue.dir, intentDir)
+	names, err := fileNames(dir)
+	if err != nil {
+		log.Printf("Failed to read event intents %s: %v", dir, err)
+		return
+	}
+	for _, name := range names {
+		var i intent
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			err = json.Unmarshal(data, &i)
+		}
+		if err != nil {
+			log.Printf("Dropping unreadable event intent %s: %v", name, err)
+		}
+		for _, event := range i.Events {
+			ns.replay(event)
+		}
+		ns.queue.dropIntent(name)
+	}
+	if len(names) > 0 {
+		log.Printf("Replayed %d interrupted event intent(s)", len(names))
+	}
+}
+
+func (ns *Storage) replay(event intendedEvent) {
+	info, err := ns.Storage.StatObject(event.Bucket, event.Key, event.VersionID)
+	if strings.HasPrefix(event.EventName, "s3:ObjectRemoved:") {
+		if err != nil && event.Before != nil {
+			ns.publish(event.Bucket, event.EventName, dto.ObjectInfo{Key: event.Key, VersionID: event.VersionID})
+		}
+		return
+	}
+	if err == nil && (event.Before == nil || !event.Before.same(stateOf(info))) {
+		ns.publish(event.Bucket, event.EventName, info)
+	}
+}
@@ -0,0 +1,273 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Délai entre deux tentatives : il double à chaque échec, jusqu'à maxBackoff
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Hour
+)
+
+// Une livraison qui échoue encore après maxDeliveryAge est abandonnée
+const maxDeliveryAge = 7 * 24 * time.Hour
+
+// Queue est une file de livraisons persistée sur disque : chaque livraison est un fichier JSON
+// du répertoire de la file, supprimé seulement quand le webhook a répondu par un statut 2xx.
+// Une livraison survit donc à un redémarrage et peut être envoyée plus d'une fois (at-least-once).
+// Le sous-répertoire intents garde les événements annoncés par les opérations en cours (voir intent).
+type Queue struct {
+	dir    string
+	client *http.Client
+	// Sérialise les passages de livraison
+	mu sync.Mutex
+	// Réveille Run quand une livraison est ajoutée
+	wake chan struct{}
+}
+
+// delivery est une livraison en attente, telle qu'elle est enregistrée sur disque
+type delivery struct {
+	Endpoint    string          `json:"endpoint"`
+	Body        json.RawMessage `json:"body"`
+	Attempts    int             `json:"attempts"`
+	Created     time.Time       `json:"created"`
+	NextAttempt time.Time       `json:"nextAttempt"`
+}
+
+// Stats compte les livraisons traitées lors d'un passage
+type Stats struct {
+	Delivered int
+	Failed    int
+	Dropped   int
+}
+
+// NewQueue ouvre la file du répertoire dir, en le créant si besoin ; les livraisons qui y restent
+// d'une exécution précédente seront envoyées au prochain passage
+func NewQueue(dir string) (*Queue, error) {
+	if err := os.MkdirAll(filepath.Join(dir, intentDir), os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &Queue{
+		dir:    dir,
+		client: &http.Client{Timeout: 10 * time.Second},
+		wake:   make(chan struct{}, 1),
+	}, nil
+}
+
+// Enqueue enregistre sur disque l'envoi de payload à endpoint ; une livraison acceptée n'est
+// jamais perdue
+func (q *Queue) Enqueue(endpoint string, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	data, err := json.Marshal(delivery{Endpoint: endpoint, Body: body, Created: now, NextAttempt: now})
+	if err != nil {
+		return err
+	}
+
+	name, err := fileName(now)
+	if err != nil {
+		return err
+	}
+	if err := q.writeDelivery(name, data); err != nil {
+		return err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// fileName renvoie un nom de fichier unique qui commence par un séquenceur : l'ordre des noms
+// est celui des ajouts
+func fileName(now time.Time) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s.json", nextSequencer(now), hex.EncodeToString(suffix)), nil
+}
+
+// Pending renvoie le nombre de livraisons en attente
+func (q *Queue) Pending() int {
+	names, _ := q.deliveryNames()
+	return len(names)
+}
+
+// Run effectue un passage immédiatement, puis toutes les interval ou dès qu'une livraison est
+// ajoutée, jusqu'à l'annulation de ctx
+func (q *Queue) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if stats := q.Deliver(time.Now()); stats.Failed > 0 || stats.Dropped > 0 {
+			log.Printf("Event notifications: %d delivered, %d failed, %d dropped", stats.Delivered, stats.Failed, stats.Dropped)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// Deliver envoie, dans l'ordre de la file, les livraisons dont la tentative est due à l'heure now.
+// Après un échec, les livraisons suivantes vers le même webhook attendent le prochain passage,
+// pour ne pas inverser l'ordre des événements.
+func (q *Queue) Deliver(now time.Time) Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var stats Stats
+	names, err := q.deliveryNames()
+	if err != nil {
+		log.Printf("Failed to read notification queue %s: %v", q.dir, err)
+		return stats
+	}
+
+	blocked := make(map[string]bool)
+	for _, name := range names {
+		path := filepath.Join(q.dir, name)
+		var d delivery
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &d)
+		}
+		if err != nil {
+			log.Printf("Dropping unreadable notification %s: %v", name, err)
+			os.Remove(path)
+			stats.Dropped++
+			continue
+		}
+		if blocked[d.Endpoint] || now.Before(d.NextAttempt) {
+			blocked[d.Endpoint] = true
+			continue
+		}
+
+		err = q.post(d)
+		if err == nil {
+			os.Remove(path)
+			stats.Delivered++
+			continue
+		}
+
+		blocked[d.Endpoint] = true
+		if now.Sub(d.Created) > maxDeliveryAge {
+			log.Printf("Dropping notification to %s after %d attempts: %v", d.Endpoint, d.Attempts+1, err)
+			os.Remove(path)
+			stats.Dropped++
+			continue
+		}
+		backoff := maxBackoff
+		if d.Attempts < 12 {
+			backoff = minBackoff << d.Attempts
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+		d.Attempts++
+		d.NextAttempt = now.Add(backoff)
+		log.Printf("Notification to %s failed (attempt %d), retrying in %s: %v", d.Endpoint, d.Attempts, backoff, err)
+		if data, err := json.Marshal(d); err != nil || q.writeDelivery(name, data) != nil {
+			log.Printf("Failed to update notification %s", name)
+		}
+		stats.Failed++
+	}
+	return stats
+}
+
+// writeDelivery écrit une livraison dans la file
+func (q *Queue) writeDelivery(name string, data []byte) error {
+	return writeFile(q.dir, name, data)
+}
+
+// writeFile écrit un fichier temporaire synchronisé sur disque puis le renomme, pour ne jamais
+// laisser dans dir un fichier à moitié écrit
+func writeFile(dir, name string, data []byte) error {
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// post envoie une livraison ; seul un statut 2xx la confirme
+func (q *Queue) post(d delivery) error {
+	resp, err := q.client.Post(d.Endpoint, "application/json", bytes.NewReader(d.Body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// deliveryNames liste les livraisons de la file, dans l'ordre d'ajout
+func (q *Queue) deliveryNames() ([]string, error) {
+	return fileNames(q.dir)
+}
+
+// fileNames liste les fichiers JSON de dir par ordre de nom, sans les fichiers temporaires
+func fileNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// syncDir synchronise un répertoire sur disque, pour qu'un fichier qui vient d'y être renommé
+// survive à un arrêt brutal
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"io"
+	"log"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/storage"
+)
+
+// Storage publie les événements des buckets dont les notifications sont configurées : après
+// chaque écriture ou suppression réussie du backend, un enregistrement est ajouté à la file pour
+// chaque webhook concerné. Les suppressions du cycle de vie sont notifiées comme les autres.
+//
+// Avant l'opération, ses événements sont annoncés par une intention enregistrée dans la file
+// (voir intent) : un arrêt du serveur entre l'opération et la mise en file ne les perd pas.
+type Storage struct {
+	storage.Storage
+	queue  *Queue
+	region string
+}
+
+// NewStorage ajoute la publication des événements au backend donné, après avoir publié ceux des
+// opérations qu'un arrêt du serveur a interrompues
+func NewStorage(backend storage.Storage, queue *Queue, region string) *Storage {
+	ns := &Storage{Storage: backend, queue: queue, region: region}
+	ns.replayIntents()
+	return ns
+}
+
+// Unwrap renvoie le backend, pour les fonctionnalités propres à celui-ci (voir storage.AsScrubber)
+func (ns *Storage) Unwrap() storage.Storage {
+	return ns.Storage
+}
+
+func (ns *Storage) AddObject(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	intent, err := ns.announce(intendedEvent{Bucket: bucketName, Key: objectName, EventName: ObjectCreatedPut})
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	info, err := ns.Storage.AddObject(bucketName, objectName, data, metadata)
+	if err == nil {
+		ns.publish(bucketName, ObjectCreatedPut, info)
+	}
+	ns.queue.dropIntent(intent)
+	return info, err
+}
+
+func (ns *Storage) CopyObject(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	intent, err := ns.announce(intendedEvent{Bucket: targetBucket, Key: targetKey, EventName: ObjectCreatedCopy})
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	info, err := ns.Storage.CopyObject(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey, metadata)
+	if err == nil {
+		ns.publish(targetBucket, ObjectCreatedCopy, info)
+	}
+	ns.queue.dropIntent(intent)
+	return info, err
+}
+
+// MoveObject publie la création de la cible puis la suppression de la source, qui laisse un
+// marqueur de suppression dans un bucket versionné
+func (ns *Storage) MoveObject(sourceBucket, sourceKey, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	removed := ObjectRemovedDelete
+	if ns.versioned(sourceBucket) {
+		removed = ObjectRemovedDeleteMarkerCreated
+	}
+	intent, err := ns.announce(
+		intendedEvent{Bucket: targetBucket, Key: targetKey, EventName: ObjectCreatedCopy},
+		intendedEvent{Bucket: sourceBucket, Key: sourceKey, EventName: removed},
+	)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	info, err := ns.Storage.MoveObject(sourceBucket, sourceKey, targetBucket, targetKey, metadata)
+	if err == nil {
+		ns.publish(targetBucket, ObjectCreatedCopy, info)
+		ns.publish(sourceBucket, removed, dto.ObjectInfo{Key: sourceKey})
+	}
+	ns.queue.dropIntent(intent)
+	return info, err
+}
+
+func (ns *Storage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+	intent, err := ns.announce(intendedEvent{Bucket: bucketName, Key: objectName, EventName: ObjectCreatedCompleteMultipartUpload})
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	info, err := ns.Storage.CompleteMultipartUpload(bucketName, objectName, uploadID, parts)
+	if err == nil {
+		ns.publish(bucketName, ObjectCreatedCompleteMultipartUpload, info)
+	}
+	ns.queue.dropIntent(intent)
+	return info, err
+}
+
+// DeleteObject publie DeleteMarkerCreated si la suppression a créé un marqueur, Delete sinon
+// (y compris pour la suppression d'un marqueur par sa version)
+func (ns *Storage) DeleteObject(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error) {
+	// L'intention prévoit le marqueur d'après le versioning du bucket
+	intended := ObjectRemovedDelete
+	if versionID == "" && ns.versioned(bucketName) {
+		intended = ObjectRemovedDeleteMarkerCreated
+	}
+	intent, err := ns.announce(intendedEvent{Bucket: bucketName, Key: objectName, VersionID: versionID, EventName: intended})
+	if err != nil {
+		return dto.DeleteObjectResult{}, err
+	}
+	result, err := ns.Storage.DeleteObject(bucketName, objectName, versionID)
+	if err == nil {
+		eventName := ObjectRemovedDelete
+		if result.DeleteMarker && versionID == "" {
+			eventName = ObjectRemovedDeleteMarkerCreated
+		}
+		ns.publish(bucketName, eventName, dto.ObjectInfo{Key: objectName, VersionID: result.VersionID})
+	}
+	ns.queue.dropIntent(intent)
+	return result, err
+}
+
+// versioned indique si le versioning du bucket a été activé, même s'il est suspendu depuis
+func (ns *Storage) versioned(bucketName string) bool {
+	versioning, err := ns.Storage.GetBucketVersioning(bucketName)
+	return err == nil && versioning != ""
+}
+
+// publish met en file l'événement pour chaque webhook du bucket qu'il concerne. Un échec est
+// journalisé sans faire échouer l'opération, qui a déjà eu lieu.
+func (ns *Storage) publish(bucketName, eventName string, info dto.ObjectInfo) {
+	webhooks, err := ns.webhooks(bucketName, eventName, info.Key)
+	if err != nil {
+		log.Printf("Failed to read notifications of bucket %s: %v", bucketName, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+	// Sous le chiffrement, le backend décrit le contenu chiffré
+	if plain, err := storage.PlaintextInfo(ns.Storage, bucketName, info); err != nil {
+		log.Printf("Failed to read plaintext size of %s/%s: %v", bucketName, info.Key, err)
+	} else {
+		info = plain
+	}
+
+	now := time.Now()
+	for _, webhook := range webhooks {
+		record := newRecord(ns.region, webhook.ID, eventName, bucketName, info, now)
+		if err := ns.queue.Enqueue(webhook.Endpoint, Payload{Records: []Record{record}}); err != nil {
+			log.Printf("Failed to queue %s event for %s/%s: %v", eventName, bucketName, info.Key, err)
+		}
+	}
+}
+
+// webhooks renvoie les webhooks du bucket qui attendent un événement de type eventName sur key
+func (ns *Storage) webhooks(bucketName, eventName, key string) ([]dto.WebhookConfiguration, error) {
+	config, err := ns.Storage.GetBucketNotification(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	var webhooks []dto.WebhookConfiguration
+	for _, webhook := range config.Webhooks {
+		if Matches(webhook, eventName, key) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
@@ -0,0 +1,130 @@
+package policy
+
+// Actions S3 évaluées par les handlers, telles qu'elles s'écrivent dans une politique de bucket
+const (
+	ListAllMyBuckets = "s3:ListAllMyBuckets"
+	CreateBucket     = "s3:CreateBucket"
+	DeleteBucket     = "s3:DeleteBucket"
+
+	ListBucket                 = "s3:ListBucket"
+	ListBucketVersions         = "s3:ListBucketVersions"
+	ListBucketMultipartUploads = "s3:ListBucketMultipartUploads"
+	GetBucketLocation          = "s3:GetBucketLocation"
+
+	GetBucketVersioning              = "s3:GetBucketVersioning"
+	PutBucketVersioning              = "s3:PutBucketVersioning"
+	GetLifecycleConfiguration        = "s3:GetLifecycleConfiguration"
+	PutLifecycleConfiguration        = "s3:PutLifecycleConfiguration"
+	GetBucketCORS                    = "s3:GetBucketCORS"
+	PutBucketCORS                    = "s3:PutBucketCORS"
+	GetBucketObjectLockConfiguration = "s3:GetBucketObjectLockConfiguration"
+	PutBucketObjectLockConfiguration = "s3:PutBucketObjectLockConfiguration"
+	GetBucketPolicy                  = "s3:GetBucketPolicy"
+	PutBucketPolicy                  = "s3:PutBucketPolicy"
+	DeleteBucketPolicy               = "s3:DeleteBucketPolicy"
+	GetBucketAcl                     = "s3:GetBucketAcl"
+	PutBucketAcl                     = "s3:PutBucketAcl"
+	GetBucketNotification            = "s3:GetBucketNotification"
+	PutBucketNotification            = "s3:PutBucketNotification"
+	GetEncryptionConfiguration       = "s3:GetEncryptionConfiguration"
+	PutEncryptionConfiguration       = "s3:PutEncryptionConfiguration"
+
+	GetObject                  = "s3:GetObject"
+	GetObjectVersion           = "s3:GetObjectVersion"
+	PutObject                  = "s3:PutObject"
+	DeleteObject               = "s3:DeleteObject"
+	DeleteObjectVersion        = "s3:DeleteObjectVersion"
+	GetObjectAcl               = "s3:GetObjectAcl"
+	PutObjectAcl               = "s3:PutObjectAcl"
+	GetObjectRetention         = "s3:GetObjectRetention"
+	PutObjectRetention         = "s3:PutObjectRetention"
+	GetObjectLegalHold         = "s3:GetObjectLegalHold"
+	PutObjectLegalHold         = "s3:PutObjectLegalHold"
+	GetObjectTagging           = "s3:GetObjectTagging"
+	GetObjectVersionTagging    = "s3:GetObjectVersionTagging"
+	PutObjectTagging           = "s3:PutObjectTagging"
+	PutObjectVersionTagging    = "s3:PutObjectVersionTagging"
+	DeleteObjectTagging        = "s3:DeleteObjectTagging"
+	DeleteObjectVersionTagging = "s3:DeleteObjectVersionTagging"
+	BypassGovernanceRetention  = "s3:BypassGovernanceRetention"
+	AbortMultipartUpload       = "s3:AbortMultipartUpload"
+	ListMultipartUploadParts   = "s3:ListMultipartUploadParts"
+)
+
+// Actions d'administration du serveur, propres à ce service et réservées à l'administrateur
+// (config.Config.AdminAccessKey) : elles portent sur tous les buckets, aucune politique ni ACL
+// ne peut donc les accorder
+const (
+	GetIntegrityReport  = "admin:GetIntegrityReport"
+	StartIntegrityScrub = "admin:StartIntegrityScrub"
+	GetUsage            = "admin:GetUsage"
+)
+
+// ACL prédéfinies acceptées dans l'en-tête x-amz-acl
+const (
+	ACLPrivate           = "private"
+	ACLPublicRead        = "public-read"
+	ACLPublicReadWrite   = "public-read-write"
+	ACLAuthenticatedRead = "authenticated-read"
+)
+
+// ValidCannedACL indique si acl est une ACL prédéfinie prise en charge
+func ValidCannedACL(acl string) bool {
+	switch acl {
+	case ACLPrivate, ACLPublicRead, ACLPublicReadWrite, ACLAuthenticatedRead:
+		return true
+	}
+	return false
+}
+
+// Droits READ et WRITE d'une ACL de bucket, exprimés en actions
+var (
+	bucketReadActions = map[string]bool{
+		ListBucket:                 true,
+		ListBucketVersions:         true,
+		ListBucketMultipartUploads: true,
+	}
+	bucketWriteActions = map[string]bool{
+		PutObject:            true,
+		DeleteObject:         true,
+		DeleteObjectVersion:  true,
+		AbortMultipartUpload: true,
+	}
+)
+
+// Droit READ d'une ACL d'objet
+var objectReadActions = map[string]bool{
+	GetObject:        true,
+	GetObjectVersion: true,
+}
+
+// readableBy indique si l'ACL accorde READ au principal ("" pour une requête anonyme)
+func readableBy(acl, principal string) bool {
+	switch acl {
+	case ACLPublicRead, ACLPublicReadWrite:
+		return true
+	case ACLAuthenticatedRead:
+		return principal != ""
+	}
+	return false
+}
+
+// BucketACLAllows indique si l'ACL du bucket permet au principal d'effectuer action : READ
+// permet de lister le bucket, WRITE (public-read-write) d'y écrire et d'y supprimer des objets
+func BucketACLAllows(acl, principal, action string) bool {
+	if bucketWriteActions[action] {
+		return acl == ACLPublicReadWrite
+	}
+	return bucketReadActions[action] && readableBy(acl, principal)
+}
+
+// ObjectACLAllows indique si l'ACL de l'objet permet au principal d'effectuer action : READ
+// permet de lire l'objet
+func ObjectACLAllows(acl, principal, action string) bool {
+	return objectReadActions[action] && readableBy(acl, principal)
+}
+
+// IsObjectRead indique si action est une lecture d'objet, que l'ACL de l'objet peut autoriser
+func IsObjectRead(action string) bool {
+	return objectReadActions[action]
+}
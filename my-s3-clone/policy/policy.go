@@ -0,0 +1,180 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"my-s3-clone/dto"
+)
+
+// Taille maximale d'une politique de bucket
+const MaxSize = 20 * 1024
+
+const (
+	effectAllow = "Allow"
+	effectDeny  = "Deny"
+
+	resourcePrefix = "arn:aws:s3:::"
+)
+
+// Decision est le résultat de l'évaluation d'une politique pour une requête
+type Decision int
+
+const (
+	// NotApplicable : aucune instruction ne s'applique, la décision revient au propriétaire et aux ACL
+	NotApplicable Decision = iota
+	Allowed
+	Denied
+)
+
+// Resource renvoie l'ARN du bucket, ou de l'objet key s'il est renseigné
+func Resource(bucketName, key string) string {
+	if key == "" {
+		return resourcePrefix + bucketName
+	}
+	return resourcePrefix + bucketName + "/" + key
+}
+
+// Parse décode et valide la politique envoyée pour le bucket. Les éléments non pris en charge
+// (Condition, NotAction, NotPrincipal...) sont refusés plutôt qu'ignorés, pour qu'une restriction
+// ne soit jamais élargie silencieusement.
+func Parse(bucketName string, data []byte) (dto.BucketPolicy, error) {
+	var policy dto.BucketPolicy
+	if len(data) > MaxSize {
+		return policy, fmt.Errorf("Policies must be no more than %d bytes", MaxSize)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&policy); err != nil {
+		return policy, fmt.Errorf("This policy contains invalid Json: %v", err)
+	}
+	return policy, Validate(bucketName, policy)
+}
+
+// Validate vérifie une politique avant son enregistrement
+func Validate(bucketName string, policy dto.BucketPolicy) error {
+	switch policy.Version {
+	case "", "2012-10-17", "2008-10-17":
+	default:
+		return fmt.Errorf("The policy must contain a valid version string")
+	}
+	if len(policy.Statement) == 0 {
+		return errors.New("Missing required field Statement")
+	}
+
+	for _, statement := range policy.Statement {
+		if statement.Effect != effectAllow && statement.Effect != effectDeny {
+			return fmt.Errorf("Invalid effect: %s", statement.Effect)
+		}
+		if statement.Principal == nil || len(statement.Principal.AWS) == 0 {
+			return errors.New("Missing required field Principal")
+		}
+		if len(statement.Action) == 0 {
+			return errors.New("Missing required field Action")
+		}
+		for _, action := range statement.Action {
+			if action != "*" && !strings.HasPrefix(strings.ToLower(action), "s3:") {
+				return fmt.Errorf("Policy has invalid action: %s", action)
+			}
+		}
+		if len(statement.Resource) == 0 {
+			return errors.New("Missing required field Resource")
+		}
+		for _, resource := range statement.Resource {
+			name := strings.TrimPrefix(resource, resourcePrefix)
+			if name == resource {
+				return fmt.Errorf("Policy has invalid resource: %s", resource)
+			}
+			if bucket, _, _ := strings.Cut(name, "/"); bucket != bucketName {
+				return fmt.Errorf("Action does not apply to any resource(s) in statement: %s", resource)
+			}
+		}
+	}
+	return nil
+}
+
+// Evaluate applique la politique à la requête du principal (son access key, "" pour une requête
+// anonyme) : un Deny l'emporte sur tout Allow
+func Evaluate(policy *dto.BucketPolicy, principal, action, resource string) Decision {
+	if policy == nil {
+		return NotApplicable
+	}
+
+	decision := NotApplicable
+	for _, statement := range policy.Statement {
+		if !applies(statement, principal, action, resource) {
+			continue
+		}
+		if statement.Effect == effectDeny {
+			return Denied
+		}
+		decision = Allowed
+	}
+	return decision
+}
+
+func applies(statement dto.PolicyStatement, principal, action, resource string) bool {
+	if !matchesPrincipal(statement.Principal, principal) {
+		return false
+	}
+	actionMatches := false
+	for _, pattern := range statement.Action {
+		// Les noms d'actions ne sont pas sensibles à la casse
+		if match(strings.ToLower(pattern), strings.ToLower(action)) {
+			actionMatches = true
+			break
+		}
+	}
+	if !actionMatches {
+		return false
+	}
+	for _, pattern := range statement.Resource {
+		if match(pattern, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPrincipal indique si l'instruction vise le principal : "*" vise aussi les requêtes anonymes
+func matchesPrincipal(p *dto.Principal, principal string) bool {
+	if p == nil {
+		return false
+	}
+	for _, accessKey := range p.AWS {
+		if accessKey == "*" || (principal != "" && accessKey == principal) {
+			return true
+		}
+	}
+	return false
+}
+
+// match compare value au motif, où "*" remplace une suite quelconque de caractères et "?" un caractère
+func match(pattern, value string) bool {
+	// Position à laquelle reprendre après le dernier "*" rencontré
+	star, resume := -1, 0
+	p, v := 0, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case p < len(pattern) && pattern[p] == '*':
+			star, resume = p, v
+			p++
+		case star >= 0:
+			resume++
+			p, v = star+1, resume
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
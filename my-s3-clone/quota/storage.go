@@ -0,0 +1,530 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/storage"
+)
+
+// Storage tient à jour l'espace occupé par chaque bucket (octets et nombre de versions stockées) et
+// refuse avec storage.ErrQuotaExceeded les écritures qui dépasseraient une limite stricte du bucket
+// ou de son propriétaire. L'usage d'un propriétaire est la somme de celui de ses buckets.
+//
+// Un objet chiffré compte pour sa taille en clair, celle que montrent HEAD et les listings, et non
+// pour sa taille sur disque.
+//
+// Les compteurs sont incrémentaux : chaque écriture réserve son contenu au fil de sa lecture, pour
+// que des écritures simultanées ne dépassent pas ensemble une limite, et chaque suppression libère
+// la version supprimée. Ils ne voient que les opérations qui passent par cette couche : un
+// recomptage complet périodique (Run) corrige les écarts.
+type Storage struct {
+	storage.Storage
+	limits dto.QuotaConfiguration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucketUsage
+	recounted time.Time
+	// Le premier recomptage a lieu avant la première écriture ou lecture des compteurs
+	counted sync.Once
+}
+
+// bucketUsage est l'usage d'un bucket : ses versions stockées, et les écritures en cours
+type bucketUsage struct {
+	owner   string
+	stored  dto.Usage
+	pending dto.Usage
+}
+
+// NewStorage ajoute le suivi de l'usage et les quotas limits au backend donné
+func NewStorage(backend storage.Storage, limits dto.QuotaConfiguration) *Storage {
+	return &Storage{Storage: backend, limits: limits, buckets: make(map[string]*bucketUsage)}
+}
+
+// From renvoie la couche de quotas de s, en traversant les couches qui exposent leur backend par
+// une méthode Unwrap
+func From(s storage.Storage) (*Storage, bool) {
+	for {
+		switch layer := s.(type) {
+		case *Storage:
+			return layer, true
+		case interface{ Unwrap() storage.Storage }:
+			s = layer.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
+
+// Unwrap renvoie le backend, pour les fonctionnalités propres à celui-ci (voir storage.AsScrubber)
+func (qs *Storage) Unwrap() storage.Storage {
+	return qs.Storage
+}
+
+// Run recompte l'usage de tous les buckets toutes les interval, jusqu'à l'annulation de ctx
+func (qs *Storage) Run(ctx context.Context, interval time.Duration) {
+	qs.init()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := qs.Recount(); err != nil {
+			log.Printf("Usage recount failed: %v", err)
+		}
+	}
+}
+
+func (qs *Storage) init() {
+	qs.counted.Do(func() {
+		if err := qs.Recount(); err != nil {
+			log.Printf("Usage recount failed: %v", err)
+		}
+	})
+}
+
+// Recount recalcule l'usage de chaque bucket à partir de la liste de ses versions et remplace les
+// compteurs. Une écriture qui se termine pendant le recomptage peut être comptée deux fois ou pas
+// du tout : l'écart est corrigé au recomptage suivant. Un bucket dont le recomptage échoue garde
+// ses compteurs.
+func (qs *Storage) Recount() error {
+	var errs []error
+	counted := make(map[string]*bucketUsage)
+	failed := make(map[string]bool)
+	for _, bucketName := range qs.Storage.ListBuckets() {
+		usage, err := qs.countBucket(bucketName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bucket %s: %w", bucketName, err))
+			failed[bucketName] = true
+			continue
+		}
+		counted[bucketName] = &bucketUsage{owner: qs.owner(bucketName), stored: usage}
+	}
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	for bucketName, entry := range qs.buckets {
+		switch recount, ok := counted[bucketName]; {
+		case ok:
+			recount.pending = entry.pending
+		case failed[bucketName] || entry.pending != (dto.Usage{}):
+			// Les écritures en cours d'un bucket créé pendant le recomptage restent comptées
+			counted[bucketName] = entry
+		}
+	}
+	qs.buckets = counted
+	qs.recounted = time.Now().UTC()
+	return errors.Join(errs...)
+}
+
+func (qs *Storage) countBucket(bucketName string) (dto.Usage, error) {
+	var usage dto.Usage
+	keyMarker, versionIDMarker := "", ""
+	for {
+		result, err := qs.Storage.ListObjectVersions(bucketName, "", keyMarker, versionIDMarker, 1000)
+		if err != nil {
+			return usage, err
+		}
+		for _, version := range result.Versions {
+			size := version.Size
+			if info, err := qs.Storage.StatObject(bucketName, version.Key, version.VersionId); err == nil {
+				size = qs.plainSize(bucketName, info)
+			}
+			usage.Bytes += size
+			usage.Objects++
+		}
+		if !result.IsTruncated {
+			return usage, nil
+		}
+		keyMarker, versionIDMarker = result.NextKeyMarker, result.NextVersionIdMarker
+	}
+}
+
+// plainSize renvoie la taille en clair d'un objet décrit par le backend, placé sous le chiffrement
+func (qs *Storage) plainSize(bucketName string, info dto.ObjectInfo) int64 {
+	plain, err := storage.PlaintextInfo(qs.Storage, bucketName, info)
+	if err != nil {
+		log.Printf("Failed to read plaintext size of %s/%s: %v", bucketName, info.Key, err)
+		return info.Size
+	}
+	return plain.Size
+}
+
+// owner renvoie le propriétaire d'un bucket, ou "" s'il n'en a pas
+func (qs *Storage) owner(bucketName string) string {
+	info, err := qs.Storage.GetBucketInfo(bucketName)
+	if err != nil {
+		return ""
+	}
+	return info.Owner
+}
+
+// Usage renvoie l'usage et les limites de tous les buckets et de leurs propriétaires
+func (qs *Storage) Usage() dto.UsageReport {
+	qs.init()
+	bucketNames := qs.Storage.ListBuckets()
+	sort.Strings(bucketNames)
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	var report dto.UsageReport
+	if !qs.recounted.IsZero() {
+		recounted := qs.recounted
+		report.LastRecount = &recounted
+	}
+	owners := make(map[string]*dto.OwnerUsage)
+	var ownerIDs []string
+	for _, bucketName := range bucketNames {
+		bucket := dto.BucketUsage{Name: bucketName}
+		if entry, ok := qs.buckets[bucketName]; ok {
+			bucket.Owner, bucket.Usage = entry.owner, entry.stored
+		}
+		bucket.Quota, bucket.SoftQuotaExceeded, bucket.HardQuotaExceeded = describeLimits(qs.limits.BucketLimits(bucketName), bucket.Usage)
+		report.Buckets = append(report.Buckets, bucket)
+
+		if bucket.Owner == "" {
+			continue
+		}
+		owner, ok := owners[bucket.Owner]
+		if !ok {
+			owner = &dto.OwnerUsage{ID: bucket.Owner}
+			owners[bucket.Owner] = owner
+			ownerIDs = append(ownerIDs, bucket.Owner)
+		}
+		owner.Bytes += bucket.Bytes
+		owner.Objects += bucket.Objects
+	}
+
+	sort.Strings(ownerIDs)
+	for _, id := range ownerIDs {
+		owner := owners[id]
+		owner.Quota, owner.SoftQuotaExceeded, owner.HardQuotaExceeded = describeLimits(qs.limits.OwnerLimits(id), owner.Usage)
+		report.Owners = append(report.Owners, *owner)
+	}
+	return report
+}
+
+func describeLimits(limits dto.QuotaLimits, usage dto.Usage) (*dto.QuotaLimits, bool, bool) {
+	if limits.IsZero() {
+		return nil, false, false
+	}
+	return &limits, exceeds(usage, limits.SoftBytes, limits.SoftObjects), exceeds(usage, limits.HardBytes, limits.HardObjects)
+}
+
+// exceeds indique si usage dépasse l'une des limites données (0 : pas de limite)
+func exceeds(usage dto.Usage, bytes, objects int64) bool {
+	return (bytes > 0 && usage.Bytes > bytes) || (objects > 0 && usage.Objects > objects)
+}
+
+func add(a, b dto.Usage) dto.Usage {
+	return dto.Usage{Bytes: a.Bytes + b.Bytes, Objects: a.Objects + b.Objects}
+}
+
+func sub(a, b dto.Usage) dto.Usage {
+	return dto.Usage{Bytes: a.Bytes - b.Bytes, Objects: a.Objects - b.Objects}
+}
+
+// entry renvoie les compteurs d'un bucket, créés au besoin. Doit être appelée sous qs.mu.
+func (qs *Storage) entry(bucketName string) *bucketUsage {
+	entry, ok := qs.buckets[bucketName]
+	if !ok {
+		entry = &bucketUsage{}
+		qs.buckets[bucketName] = entry
+	}
+	return entry
+}
+
+// ownerTotal renvoie l'usage des buckets d'un propriétaire, écritures en cours comprises. Doit
+// être appelée sous qs.mu.
+func (qs *Storage) ownerTotal(owner string) dto.Usage {
+	var total dto.Usage
+	for _, entry := range qs.buckets {
+		if entry.owner == owner {
+			total = add(total, add(entry.stored, entry.pending))
+		}
+	}
+	return total
+}
+
+// reservation est une écriture en cours, comptée dans l'usage de son bucket jusqu'à sa fin
+type reservation struct {
+	qs         *Storage
+	bucketName string
+	// Ajouté à l'usage en cours du bucket : le contenu lu jusqu'ici, moins la version remplacée
+	delta dto.Usage
+	size  int64
+}
+
+// reserve commence une écriture de size octets dans un bucket, qui remplace la version replaced.
+// Elle renvoie storage.ErrQuotaExceeded si l'écriture dépasserait une limite stricte.
+func (qs *Storage) reserve(bucketName string, replaced dto.Usage, size int64) (*reservation, error) {
+	qs.init()
+	owner := qs.owner(bucketName)
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	qs.entry(bucketName).owner = owner
+
+	res := &reservation{qs: qs, bucketName: bucketName}
+	if err := res.grow(dto.Usage{Bytes: size - replaced.Bytes, Objects: 1 - replaced.Objects}); err != nil {
+		return nil, err
+	}
+	res.size = size
+	return res, nil
+}
+
+// grow ajoute delta à la réservation, sauf s'il fait dépasser une limite stricte du bucket ou de
+// son propriétaire. Seules les limites que delta augmente sont vérifiées : remplacer un objet
+// par un plus petit reste possible au-delà d'une limite. Doit être appelée sous qs.mu.
+func (res *reservation) grow(delta dto.Usage) error {
+	qs := res.qs
+	entry := qs.entry(res.bucketName)
+
+	growth := dto.Usage{Bytes: max(delta.Bytes, 0), Objects: max(delta.Objects, 0)}
+	bucketLimits := qs.limits.BucketLimits(res.bucketName)
+	if exceeds(add(add(entry.stored, entry.pending), growth), bucketLimits.HardBytes, bucketLimits.HardObjects) {
+		return fmt.Errorf("%w: bucket %s", storage.ErrQuotaExceeded, res.bucketName)
+	}
+	if ownerLimits := qs.limits.OwnerLimits(entry.owner); !ownerLimits.IsZero() {
+		if exceeds(add(qs.ownerTotal(entry.owner), growth), ownerLimits.HardBytes, ownerLimits.HardObjects) {
+			return fmt.Errorf("%w: owner %s", storage.ErrQuotaExceeded, entry.owner)
+		}
+	}
+	entry.pending = add(entry.pending, delta)
+	res.delta = add(res.delta, delta)
+	return nil
+}
+
+// read ajoute n octets de contenu à la réservation (n est négatif quand une estimation est corrigée)
+func (res *reservation) read(n int64) error {
+	res.qs.mu.Lock()
+	defer res.qs.mu.Unlock()
+	if err := res.grow(dto.Usage{Bytes: n}); err != nil {
+		return err
+	}
+	res.size += n
+	return nil
+}
+
+// end termine l'écriture : en cas d'échec la réservation est annulée, sinon elle est comptée dans
+// les versions stockées, avec la taille stored effectivement écrite
+func (res *reservation) end(stored int64, err error) {
+	qs := res.qs
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	entry := qs.entry(res.bucketName)
+	entry.pending = sub(entry.pending, res.delta)
+	if err != nil {
+		return
+	}
+	limits, ownerLimits := qs.limits.BucketLimits(res.bucketName), qs.limits.OwnerLimits(entry.owner)
+	bucketBefore, ownerBefore := entry.stored, qs.ownerTotal(entry.owner)
+	entry.stored = add(entry.stored, add(res.delta, dto.Usage{Bytes: stored - res.size}))
+
+	// Le dépassement d'une limite souple n'est signalé qu'au moment où il se produit
+	if !exceeds(bucketBefore, limits.SoftBytes, limits.SoftObjects) && exceeds(entry.stored, limits.SoftBytes, limits.SoftObjects) {
+		log.Printf("Bucket %s is over its soft quota: %d bytes in %d object(s)", res.bucketName, entry.stored.Bytes, entry.stored.Objects)
+	}
+	if ownerLimits.IsZero() {
+		return
+	}
+	if total := qs.ownerTotal(entry.owner); !exceeds(ownerBefore, ownerLimits.SoftBytes, ownerLimits.SoftObjects) && exceeds(total, ownerLimits.SoftBytes, ownerLimits.SoftObjects) {
+		log.Printf("Owner %s is over its soft quota: %d bytes in %d object(s)", entry.owner, total.Bytes, total.Objects)
+	}
+}
+
+// release retire une version supprimée de l'usage d'un bucket
+func (qs *Storage) release(bucketName string, freed dto.Usage) {
+	if freed == (dto.Usage{}) {
+		return
+	}
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	entry := qs.entry(bucketName)
+	entry.stored = sub(entry.stored, freed)
+	entry.stored.Bytes, entry.stored.Objects = max(entry.stored.Bytes, 0), max(entry.stored.Objects, 0)
+}
+
+// replaced renvoie l'usage de la version courante d'un objet si une écriture ou une suppression
+// sans versionId la fait disparaître, c'est-à-dire si elle n'est pas conservée par le versioning
+func (qs *Storage) replaced(bucketName, objectName string) dto.Usage {
+	status, err := qs.Storage.GetBucketVersioning(bucketName)
+	if err != nil || status == storage.VersioningEnabled {
+		return dto.Usage{}
+	}
+	info, err := qs.Storage.StatObject(bucketName, objectName, "")
+	if err != nil {
+		return dto.Usage{}
+	}
+	// Versioning suspendu : seule la version "null" est remplacée, une autre version est archivée
+	if status == storage.VersioningSuspended && info.VersionID != "" && info.VersionID != "null" {
+		return dto.Usage{}
+	}
+	return dto.Usage{Bytes: qs.plainSize(bucketName, info), Objects: 1}
+}
+
+// quotaReader réserve le contenu d'une écriture au fil de sa lecture. Un contenu chiffré est
+// réservé pour sa taille en clair : une estimation qui ne la dépasse pas pendant la lecture, puis
+// la taille exacte à la fin du flux, vérifiée avant que le backend n'enregistre l'objet.
+type quotaReader struct {
+	r      io.Reader
+	res    *reservation
+	sealed bool
+	// Octets lus, et octets réservés
+	read     int64
+	reserved int64
+}
+
+func (qr *quotaReader) Read(p []byte) (int, error) {
+	n, err := qr.r.Read(p)
+	qr.read += int64(n)
+	size := qr.read
+	if qr.sealed {
+		size = storage.SealedPlainSize(qr.read, err == io.EOF)
+	}
+	if size != qr.reserved {
+		if rerr := qr.res.read(size - qr.reserved); rerr != nil {
+			return n, rerr
+		}
+		qr.reserved = size
+	}
+	return n, err
+}
+
+func (qs *Storage) AddObject(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	res, err := qs.reserve(bucketName, qs.replaced(bucketName, objectName), 0)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	reader := &quotaReader{r: data, res: res, sealed: metadata.Encryption != nil}
+	info, err := qs.Storage.AddObject(bucketName, objectName, reader, metadata)
+	res.end(qs.plainSize(bucketName, info), err)
+	return info, err
+}
+
+func (qs *Storage) CopyObject(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	source, err := qs.Storage.StatObject(sourceBucket, sourceKey, sourceVersionID)
+	if err != nil {
+		// Le backend signale la source introuvable
+		return qs.Storage.CopyObject(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey, metadata)
+	}
+	res, err := qs.reserve(targetBucket, qs.replaced(targetBucket, targetKey), qs.plainSize(sourceBucket, source))
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	info, err := qs.Storage.CopyObject(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey, metadata)
+	res.end(qs.plainSize(targetBucket, info), err)
+	return info, err
+}
+
+// MoveObject compte la cible comme une copie, et libère la source si elle n'est pas conservée
+// par le versioning de son bucket
+func (qs *Storage) MoveObject(sourceBucket, sourceKey, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	source, err := qs.Storage.StatObject(sourceBucket, sourceKey, "")
+	if err != nil {
+		return qs.Storage.MoveObject(sourceBucket, sourceKey, targetBucket, targetKey, metadata)
+	}
+	freed := qs.replaced(sourceBucket, sourceKey)
+	res, err := qs.reserve(targetBucket, qs.replaced(targetBucket, targetKey), qs.plainSize(sourceBucket, source))
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	info, err := qs.Storage.MoveObject(sourceBucket, sourceKey, targetBucket, targetKey, metadata)
+	res.end(qs.plainSize(targetBucket, info), err)
+	if err == nil {
+		qs.release(sourceBucket, freed)
+	}
+	return info, err
+}
+
+// CompleteMultipartUpload vérifie les quotas avec la taille des parties assemblées ; un upload
+// refusé est conservé, il peut être abandonné ou terminé après avoir libéré de la place
+func (qs *Storage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+	size, err := qs.uploadSize(bucketName, objectName, uploadID, parts)
+	if err != nil {
+		// Le backend signale l'upload ou les parties introuvables
+		return qs.Storage.CompleteMultipartUpload(bucketName, objectName, uploadID, parts)
+	}
+	res, err := qs.reserve(bucketName, qs.replaced(bucketName, objectName), size)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	info, err := qs.Storage.CompleteMultipartUpload(bucketName, objectName, uploadID, parts)
+	res.end(qs.plainSize(bucketName, info), err)
+	return info, err
+}
+
+// uploadSize renvoie la taille de l'objet que formeraient les parties données d'un upload, en clair
+// pour un upload chiffré
+func (qs *Storage) uploadSize(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (int64, error) {
+	metadata, err := qs.Storage.MultipartUploadMetadata(bucketName, objectName, uploadID)
+	if err != nil {
+		return 0, err
+	}
+	sizes := make(map[int]int64)
+	marker := 0
+	for {
+		result, err := qs.Storage.ListParts(bucketName, objectName, uploadID, marker, 1000)
+		if err != nil {
+			return 0, err
+		}
+		for _, part := range result.Parts {
+			sizes[part.PartNumber] = part.Size
+			// Chaque partie chiffrée est un segment
+			if metadata.Encryption != nil {
+				sizes[part.PartNumber] = storage.SealedPlainSize(part.Size, true)
+			}
+		}
+		if !result.IsTruncated {
+			break
+		}
+		marker = result.NextPartNumberMarker
+	}
+
+	var size int64
+	for _, part := range parts {
+		size += sizes[part.PartNumber]
+	}
+	return size, nil
+}
+
+func (qs *Storage) DeleteObject(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error) {
+	qs.init()
+	var freed dto.Usage
+	if versionID != "" {
+		// Un marqueur de suppression n'occupe pas de place : StatObject le refuse
+		if info, err := qs.Storage.StatObject(bucketName, objectName, versionID); err == nil {
+			freed = dto.Usage{Bytes: qs.plainSize(bucketName, info), Objects: 1}
+		}
+	} else {
+		freed = qs.replaced(bucketName, objectName)
+	}
+	result, err := qs.Storage.DeleteObject(bucketName, objectName, versionID)
+	if err == nil {
+		qs.release(bucketName, freed)
+	}
+	return result, err
+}
+
+func (qs *Storage) DeleteBucket(bucketName string) error {
+	if err := qs.Storage.DeleteBucket(bucketName); err != nil {
+		return err
+	}
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	delete(qs.buckets, bucketName)
+	return nil
+}
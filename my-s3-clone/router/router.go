@@ -1,26 +1,113 @@
 package router
 
 import (
+    "context"
+    "log"
     "github.com/gorilla/mux"
+    "my-s3-clone/auth"
+    "my-s3-clone/config"
     "my-s3-clone/handlers"
+    "my-s3-clone/lifecycle"
     "my-s3-clone/middleware"
+    "my-s3-clone/notify"
+    "my-s3-clone/policy"
+    "my-s3-clone/quota"
+    "my-s3-clone/scrub"
     "my-s3-clone/storage"
     "net/http"
+    "path/filepath"
+    "time"
 )
 
-// SetupRouter sets up the router with default storage
+// SetupRouter sets up the router with the storage backend and the configuration from the environment
 func SetupRouter() *mux.Router {
-    // Use default storage (e.g., FileStorage)
-    return SetupRouterWithStorage(&storage.FileStorage{})
+    cfg, err := config.LoadConfig()
+    if err != nil {
+        log.Fatalf("Invalid configuration: %v", err)
+    }
+
+    // The storage backend is chosen by configuration (filesystem by default)
+    s, err := storage.NewBackend(cfg.StorageBackend, storage.BackendConfig{Root: cfg.StorageRoot})
+    if err != nil {
+        log.Fatalf("Invalid storage configuration: %v", err)
+    }
+
+    // Usage is tracked right above the backend, so that every write is counted; encrypted objects count
+    // for their plaintext size, as shown by HEAD and listings
+    quotas := quota.NewStorage(s, cfg.Quotas)
+    if cfg.QuotaRecountInterval > 0 {
+        go quotas.Run(context.Background(), cfg.QuotaRecountInterval)
+    }
+    s = quotas
+
+    // Bucket events are queued on disk and delivered to the webhooks of the bucket in the background
+    queueDir := cfg.NotificationQueueDir
+    if queueDir == "" {
+        root := cfg.StorageRoot
+        if root == "" {
+            root = storage.DefaultRoot
+        }
+        queueDir = filepath.Join(root, ".s3clone", "notifications")
+    }
+    if queue, err := notify.NewQueue(queueDir); err != nil {
+        log.Printf("Event notifications disabled: %v", err)
+    } else {
+        s = notify.NewStorage(s, queue, cfg.Region)
+        go queue.Run(context.Background(), time.Second)
+    }
+
+    // Lifecycle rules are enforced by a background sweeper, which must not remove locked objects
+    if cfg.LifecycleInterval > 0 {
+        go lifecycle.NewSweeper(storage.NewObjectLockStorage(s), lifecycle.SystemClock).Run(context.Background(), cfg.LifecycleInterval)
+    }
+    return SetupRouterWithConfig(s, cfg)
 }
 
-// SetupRouterWithStorage allows injecting custom storage (e.g., mock storage for tests)
+// SetupRouterWithStorage allows injecting custom storage (e.g., mock storage for tests).
+// No credentials are configured, so request signatures are not checked.
 func SetupRouterWithStorage(s storage.Storage) *mux.Router {
+    return SetupRouterWithConfig(s, config.Config{})
+}
+
+// SetupRouterWithConfig builds the router for the given storage and configuration
+func SetupRouterWithConfig(s storage.Storage, cfg config.Config) *mux.Router {
+    // The usage report needs the quota layer, if SetupRouter added one
+    quotas, _ := quota.From(s)
+
+    // Object lock and server-side encryption are applied on top of any backend
+    s = storage.NewEncryptedStorage(storage.NewObjectLockStorage(s), cfg.MasterKey)
+
+    // Backends that record checksums are scrubbed periodically and on demand; encrypted objects are verified as stored
+    var scrubber *scrub.Scrubber
+    if backend, ok := storage.AsScrubber(s); ok {
+        scrubber = scrub.NewScrubber(backend, cfg.ScrubRate)
+        if cfg.ScrubInterval > 0 {
+            go scrubber.Run(context.Background(), cfg.ScrubInterval)
+        }
+    }
+
     r := mux.NewRouter()
+    // Object keys may contain slashes, so paths are not cleaned (no redirect for "a//b" or "a/../b"):
+    // invalid keys and bucket names are rejected by the storage layer instead
+    r.SkipClean(true)
 
-    r.Use(middleware.CORSMiddleware)
+    var verifier *auth.Verifier
+    if len(cfg.Credentials) > 0 {
+        verifier = auth.NewVerifier(auth.StaticCredentials(cfg.Credentials), cfg.Region)
+        // Buckets created while authentication was disabled have no owner, and nobody gets owner rights on them
+        if cfg.BucketOwner != "" {
+            if err := storage.ClaimOwnerlessBuckets(s, cfg.BucketOwner); err != nil {
+                log.Printf("Failed to assign buckets without owner to %s: %v", cfg.BucketOwner, err)
+            }
+        }
+    } else {
+        log.Println("No credentials configured, request signatures will not be checked")
+    }
+
+    r.Use(middleware.CORSMiddleware(s))
     r.Use(middleware.LogRequestMiddleware)
     r.Use(middleware.LogResponseMiddleware)
+    r.Use(middleware.AuthMiddleware(verifier))
 
     // Health check route
     r.HandleFunc("/probe-bsign{suffix:.*}", func(w http.ResponseWriter, r *http.Request) {
@@ -29,27 +116,104 @@ func SetupRouterWithStorage(s storage.Storage) *mux.Router {
         w.Write([]byte("<Response></Response>"))
     }).Methods("GET", "HEAD")
 
+    // Every S3 request is evaluated against the bucket policy and the canned ACLs before reaching its handler.
+    // Batch delete, copy and move also check the keys they read or remove in their handler.
+    authorize := func(action string, next http.HandlerFunc) http.HandlerFunc {
+        return handlers.Authorize(s, action, next)
+    }
+
+    // CORS preflight requests are answered with the rules of the target bucket
+    r.HandleFunc("/{bucketName}/{objectName:.*}", handlers.HandleCORSPreflight(s)).Methods("OPTIONS")
+
+    // CORS routes
+    r.HandleFunc("/{bucketName}/", authorize(policy.PutBucketCORS, handlers.HandlePutBucketCors(s))).Queries("cors", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/", authorize(policy.GetBucketCORS, handlers.HandleGetBucketCors(s))).Queries("cors", "").Methods("GET")
+    r.HandleFunc("/{bucketName}/", authorize(policy.PutBucketCORS, handlers.HandleDeleteBucketCors(s))).Queries("cors", "").Methods("DELETE")
+
+    // Notification routes
+    r.HandleFunc("/{bucketName}/", authorize(policy.PutBucketNotification, handlers.HandlePutBucketNotification(s))).Queries("notification", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/", authorize(policy.GetBucketNotification, handlers.HandleGetBucketNotification(s))).Queries("notification", "").Methods("GET")
+
+    // Default encryption routes
+    r.HandleFunc("/{bucketName}/", authorize(policy.PutEncryptionConfiguration, handlers.HandlePutBucketEncryption(s))).Queries("encryption", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/", authorize(policy.GetEncryptionConfiguration, handlers.HandleGetBucketEncryption(s))).Queries("encryption", "").Methods("GET")
+    r.HandleFunc("/{bucketName}/", authorize(policy.PutEncryptionConfiguration, handlers.HandleDeleteBucketEncryption(s))).Queries("encryption", "").Methods("DELETE")
+
+    // Policy and ACL routes
+    r.HandleFunc("/{bucketName}/", authorize(policy.PutBucketPolicy, handlers.HandlePutBucketPolicy(s))).Queries("policy", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/", authorize(policy.GetBucketPolicy, handlers.HandleGetBucketPolicy(s))).Queries("policy", "").Methods("GET")
+    r.HandleFunc("/{bucketName}/", authorize(policy.DeleteBucketPolicy, handlers.HandleDeleteBucketPolicy(s))).Queries("policy", "").Methods("DELETE")
+    r.HandleFunc("/{bucketName}/", authorize(policy.PutBucketAcl, handlers.HandlePutBucketAcl(s))).Queries("acl", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/", authorize(policy.GetBucketAcl, handlers.HandleGetBucketAcl(s))).Queries("acl", "").Methods("GET")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.PutObjectAcl, handlers.HandlePutObjectAcl(s))).Queries("acl", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.GetObjectAcl, handlers.HandleGetObjectAcl(s))).Queries("acl", "").Methods("GET")
+
     // Batch delete route
     r.HandleFunc("/{bucketName}/", handlers.HandleDeleteObject(s)).Queries("delete", "").Methods("POST", "OPTIONS")
 
+    // Multipart upload routes (must be registered before the generic object routes)
+    r.HandleFunc("/{bucketName}/", authorize(policy.ListBucketMultipartUploads, handlers.HandleListMultipartUploads(s))).Queries("uploads", "").Methods("GET")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.PutObject, handlers.HandleCreateMultipartUpload(s))).Queries("uploads", "").Methods("POST")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.PutObject, handlers.HandleUploadPartCopy(s))).Queries("partNumber", "{partNumber}", "uploadId", "{uploadId}").Headers("X-Amz-Copy-Source", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.PutObject, handlers.HandleUploadPart(s))).Queries("partNumber", "{partNumber}", "uploadId", "{uploadId}").Methods("PUT")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.PutObject, handlers.HandleCompleteMultipartUpload(s))).Queries("uploadId", "{uploadId}").Methods("POST")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.AbortMultipartUpload, handlers.HandleAbortMultipartUpload(s))).Queries("uploadId", "{uploadId}").Methods("DELETE")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.ListMultipartUploadParts, handlers.HandleListParts(s))).Queries("uploadId", "{uploadId}").Methods("GET")
+
+    // Versioning routes
+    r.HandleFunc("/{bucketName}/", authorize(policy.PutBucketVersioning, handlers.HandlePutBucketVersioning(s))).Queries("versioning", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/", authorize(policy.GetBucketVersioning, handlers.HandleGetBucketVersioning(s))).Queries("versioning", "").Methods("GET")
+    r.HandleFunc("/{bucketName}/", authorize(policy.ListBucketVersions, handlers.HandleListObjectVersions(s))).Queries("versions", "").Methods("GET")
+
+    // Lifecycle routes
+    r.HandleFunc("/{bucketName}/", authorize(policy.PutLifecycleConfiguration, handlers.HandlePutBucketLifecycle(s))).Queries("lifecycle", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/", authorize(policy.GetLifecycleConfiguration, handlers.HandleGetBucketLifecycle(s))).Queries("lifecycle", "").Methods("GET")
+    r.HandleFunc("/{bucketName}/", authorize(policy.PutLifecycleConfiguration, handlers.HandleDeleteBucketLifecycle(s))).Queries("lifecycle", "").Methods("DELETE")
+
+    // Object lock routes
+    r.HandleFunc("/{bucketName}/", authorize(policy.PutBucketObjectLockConfiguration, handlers.HandlePutObjectLockConfiguration(s))).Queries("object-lock", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/", authorize(policy.GetBucketObjectLockConfiguration, handlers.HandleGetObjectLockConfiguration(s))).Queries("object-lock", "").Methods("GET")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.PutObjectRetention, handlers.HandlePutObjectRetention(s))).Queries("retention", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.GetObjectRetention, handlers.HandleGetObjectRetention(s))).Queries("retention", "").Methods("GET")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.PutObjectLegalHold, handlers.HandlePutObjectLegalHold(s))).Queries("legal-hold", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.GetObjectLegalHold, handlers.HandleGetObjectLegalHold(s))).Queries("legal-hold", "").Methods("GET")
+
+    // Tagging routes
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.PutObjectTagging, handlers.HandlePutObjectTagging(s))).Queries("tagging", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.GetObjectTagging, handlers.HandleGetObjectTagging(s))).Queries("tagging", "").Methods("GET")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.DeleteObjectTagging, handlers.HandleDeleteObjectTagging(s))).Queries("tagging", "").Methods("DELETE")
+
     // Object-specific routes
-    r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleAddObject(s)).Methods("PUT", "OPTIONS")
-    r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleCheckObjectExist(s)).Methods("HEAD", "OPTIONS")
-    r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleDownloadObject(s)).Methods("GET","OPTIONS")
-    r.HandleFunc("/{bucketName}/", handlers.HandleListObjects(s)).Methods("GET", "HEAD", "OPTIONS")
-    r.HandleFunc("/{bucketName}/", handlers.HandleBucketLocation(s)).Queries("location", "").Methods("GET", "OPTIONS")
-    r.HandleFunc("/{bucketName}/", handlers.HandleBucketLockConfig(s)).Queries("object-lock", "").Methods("GET", "OPTIONS")
-    r.HandleFunc("/{bucketName}/", handlers.HandleBucketDelimiter(s)).Queries("delimiter", "").Methods("GET","OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.PutObject, handlers.HandleCopyObject(s))).Headers("X-Amz-Copy-Source", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.PutObject, handlers.HandleAddObject(s))).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.GetObject, handlers.HandleCheckObjectExist(s))).Methods("HEAD", "OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.GetObject, handlers.HandleDownloadObject(s))).Methods("GET","OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", authorize(policy.DeleteObject, handlers.HandleDeleteSingleObject(s))).Methods("DELETE")
+    r.HandleFunc("/{bucketName}/", authorize(policy.GetBucketLocation, handlers.HandleBucketLocation(s))).Queries("location", "").Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", authorize(policy.ListBucket, handlers.HandleListObjectsV2(s))).Queries("list-type", "2").Methods("GET", "HEAD")
+    r.HandleFunc("/{bucketName}/", authorize(policy.ListBucket, handlers.HandleListObjects(s))).Methods("GET", "HEAD", "OPTIONS")
     r.HandleFunc("/{bucketName}/", handlers.HandleMoveObject(s)).Queries("move", "").Methods("POST", "OPTIONS")
+
+    // Browser form uploads, authorized by the signed policy of the form rather than by request signatures
+    r.HandleFunc("/{bucketName}/", handlers.HandlePostObject(s, verifier)).HeadersRegexp("Content-Type", "^multipart/form-data").Methods("POST")
+    r.HandleFunc("/{bucketName}", handlers.HandlePostObject(s, verifier)).HeadersRegexp("Content-Type", "^multipart/form-data").Methods("POST")
     
 
     // Bucket-specific routes
-    r.HandleFunc("/{bucketName}/", handlers.HandleGetBucket(s)).Methods("GET", "OPTIONS")
-    r.HandleFunc("/{bucketName}/", handlers.HandleCreateBucket(s)).Methods("PUT", "OPTIONS")
-    r.HandleFunc("/{bucketName}/", handlers.HandleDeleteBucket(s)).Methods("DELETE", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", authorize(policy.ListBucket, handlers.HandleGetBucket(s))).Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", authorize(policy.CreateBucket, handlers.HandleCreateBucket(s))).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", authorize(policy.DeleteBucket, handlers.HandleDeleteBucket(s))).Methods("DELETE", "OPTIONS")
+
+    // Administration routes, which cover every bucket and are reserved to the administrator
+    authorizeAdmin := func(action string, next http.HandlerFunc) http.HandlerFunc {
+        return handlers.AuthorizeAdmin(cfg.AdminAccessKey, action, next)
+    }
+    r.HandleFunc("/", authorizeAdmin(policy.GetIntegrityReport, handlers.HandleGetIntegrityReport(scrubber))).Queries("integrity", "").Methods("GET")
+    r.HandleFunc("/", authorizeAdmin(policy.StartIntegrityScrub, handlers.HandleStartIntegrityScrub(scrubber))).Queries("integrity", "").Methods("POST")
+    r.HandleFunc("/", authorizeAdmin(policy.GetUsage, handlers.HandleGetUsage(quotas))).Queries("usage", "").Methods("GET")
 
     // Route for listing all buckets
-    r.HandleFunc("/", handlers.HandleListBuckets(s)).Methods("GET", "HEAD", "OPTIONS")
+    r.HandleFunc("/", authorize(policy.ListAllMyBuckets, handlers.HandleListBuckets(s))).Methods("GET", "HEAD", "OPTIONS")
 
     return r
 }
@@ -0,0 +1,298 @@
+package s3errors
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+)
+
+// APIError décrit une erreur S3 telle qu'elle est renvoyée au client
+type APIError struct {
+	Code           string
+	Description    string
+	HTTPStatusCode int
+}
+
+// Catalogue des erreurs S3 utilisées par le serveur
+var (
+	ErrInternalError = APIError{
+		Code:           "InternalError",
+		Description:    "We encountered an internal error. Please try again.",
+		HTTPStatusCode: http.StatusInternalServerError,
+	}
+	ErrInvalidArgument = APIError{
+		Code:           "InvalidArgument",
+		Description:    "Invalid Argument",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrMalformedXML = APIError{
+		Code:           "MalformedXML",
+		Description:    "The XML you provided was not well-formed or did not validate against our published schema.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrNoSuchBucket = APIError{
+		Code:           "NoSuchBucket",
+		Description:    "The specified bucket does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrNoSuchKey = APIError{
+		Code:           "NoSuchKey",
+		Description:    "The specified key does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrNoSuchUpload = APIError{
+		Code:           "NoSuchUpload",
+		Description:    "The specified multipart upload does not exist. The upload ID might be invalid, or the multipart upload might have been aborted or completed.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrInvalidPart = APIError{
+		Code:           "InvalidPart",
+		Description:    "One or more of the specified parts could not be found. The part might not have been uploaded, or the specified entity tag might not have matched the part's entity tag.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidPartOrder = APIError{
+		Code:           "InvalidPartOrder",
+		Description:    "The list of parts was not in ascending order. The parts list must be specified in order by part number.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrAccessDenied = APIError{
+		Code:           "AccessDenied",
+		Description:    "Access Denied.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrInvalidRequest = APIError{
+		Code:           "InvalidRequest",
+		Description:    "Invalid Request",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrSignatureDoesNotMatch = APIError{
+		Code:           "SignatureDoesNotMatch",
+		Description:    "The request signature we calculated does not match the signature you provided. Check your key and signing method.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrInvalidAccessKeyId = APIError{
+		Code:           "InvalidAccessKeyId",
+		Description:    "The AWS Access Key Id you provided does not exist in our records.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrAuthorizationHeaderMalformed = APIError{
+		Code:           "AuthorizationHeaderMalformed",
+		Description:    "The authorization header is malformed.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrAuthorizationQueryParametersError = APIError{
+		Code:           "AuthorizationQueryParametersError",
+		Description:    "Query-string authentication version 4 requires the X-Amz-Algorithm, X-Amz-Credential, X-Amz-Signature, X-Amz-Date, X-Amz-SignedHeaders, and X-Amz-Expires parameters.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrRequestTimeTooSkewed = APIError{
+		Code:           "RequestTimeTooSkewed",
+		Description:    "The difference between the request time and the server's time is too large.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrEntityTooSmall = APIError{
+		Code:           "EntityTooSmall",
+		Description:    "Your proposed upload is smaller than the minimum allowed object size.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrEntityTooLarge = APIError{
+		Code:           "EntityTooLarge",
+		Description:    "Your proposed upload exceeds the maximum allowed object size.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrMetadataTooLarge = APIError{
+		Code:           "MetadataTooLarge",
+		Description:    "Your metadata headers exceed the maximum allowed metadata size.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrPreconditionFailed = APIError{
+		Code:           "PreconditionFailed",
+		Description:    "At least one of the pre-conditions you specified did not hold",
+		HTTPStatusCode: http.StatusPreconditionFailed,
+	}
+	ErrInvalidRange = APIError{
+		Code:           "InvalidRange",
+		Description:    "The requested range is not satisfiable",
+		HTTPStatusCode: http.StatusRequestedRangeNotSatisfiable,
+	}
+	ErrNoSuchVersion = APIError{
+		Code:           "NoSuchVersion",
+		Description:    "The specified version does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrInvalidBucketName = APIError{
+		Code:           "InvalidBucketName",
+		Description:    "The specified bucket is not valid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrBucketAlreadyExists = APIError{
+		Code:           "BucketAlreadyExists",
+		Description:    "The requested bucket name is not available. The bucket namespace is shared by all users of the system. Please select a different name and try again.",
+		HTTPStatusCode: http.StatusConflict,
+	}
+	ErrBucketAlreadyOwnedByYou = APIError{
+		Code:           "BucketAlreadyOwnedByYou",
+		Description:    "Your previous request to create the named bucket succeeded and you already own it.",
+		HTTPStatusCode: http.StatusConflict,
+	}
+	ErrIllegalLocationConstraint = APIError{
+		Code:           "IllegalLocationConstraintException",
+		Description:    "The specified location constraint is not valid for this endpoint.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrMethodNotAllowed = APIError{
+		Code:           "MethodNotAllowed",
+		Description:    "The specified method is not allowed against this resource.",
+		HTTPStatusCode: http.StatusMethodNotAllowed,
+	}
+	ErrNoSuchLifecycleConfiguration = APIError{
+		Code:           "NoSuchLifecycleConfiguration",
+		Description:    "The lifecycle configuration does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrNoSuchCORSConfiguration = APIError{
+		Code:           "NoSuchCORSConfiguration",
+		Description:    "The CORS configuration does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrNoSuchEncryptionConfiguration = APIError{
+		Code:           "ServerSideEncryptionConfigurationNotFoundError",
+		Description:    "The server side encryption configuration was not found.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrCORSForbidden = APIError{
+		Code:           "AccessForbidden",
+		Description:    "CORSResponse: This CORS request is not allowed. This is usually because the evalution of Origin, request method / Access-Control-Request-Method or Access-Control-Request-Headers are not whitelisted by the resource's CORS spec.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrBadRequest = APIError{
+		Code:           "BadRequest",
+		Description:    "Insufficient information. Origin request header needed.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrBadDigest = APIError{
+		Code:           "BadDigest",
+		Description:    "The Content-MD5 you specified did not match what was received.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidDigest = APIError{
+		Code:           "InvalidDigest",
+		Description:    "The Content-MD5 you specified is not valid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrContentSHA256Mismatch = APIError{
+		Code:           "XAmzContentSHA256Mismatch",
+		Description:    "The provided 'x-amz-content-sha256' header does not match what was computed.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrIncompleteBody = APIError{
+		Code:           "IncompleteBody",
+		Description:    "You did not provide the number of bytes specified by the Content-Length HTTP header.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrNoSuchBucketPolicy = APIError{
+		Code:           "NoSuchBucketPolicy",
+		Description:    "The bucket policy does not exist",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrInvalidPolicyDocument = APIError{
+		Code:           "InvalidPolicyDocument",
+		Description:    "The content of the form does not meet the conditions specified in the policy document.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrMalformedPOSTRequest = APIError{
+		Code:           "MalformedPOSTRequest",
+		Description:    "The body of your POST request is not well-formed multipart/form-data.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrMalformedPolicy = APIError{
+		Code:           "MalformedPolicy",
+		Description:    "Policy has invalid resource",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrObjectLockConfigurationNotFound = APIError{
+		Code:           "ObjectLockConfigurationNotFoundError",
+		Description:    "Object Lock configuration does not exist for this bucket.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrNoSuchObjectLockConfiguration = APIError{
+		Code:           "NoSuchObjectLockConfiguration",
+		Description:    "The specified object does not have a ObjectLock configuration.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrInvalidTag = APIError{
+		Code:           "InvalidTag",
+		Description:    "The tag provided was not a valid tag.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrNotImplemented = APIError{
+		Code:           "NotImplemented",
+		Description:    "A header you provided implies functionality that is not implemented.",
+		HTTPStatusCode: http.StatusNotImplemented,
+	}
+	ErrOperationAborted = APIError{
+		Code:           "OperationAborted",
+		Description:    "A conflicting conditional operation is currently in progress against this resource. Please try again.",
+		HTTPStatusCode: http.StatusConflict,
+	}
+	ErrQuotaExceeded = APIError{
+		Code:           "QuotaExceeded",
+		Description:    "The upload would exceed the storage quota of the bucket or of its owner.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrNoSuchIntegrityReport = APIError{
+		Code:           "NoSuchIntegrityReport",
+		Description:    "No integrity scrub has completed yet.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+)
+
+// WithMessage renvoie une copie de l'erreur avec un message personnalisé
+func (e APIError) WithMessage(message string) APIError {
+	e.Description = message
+	return e
+}
+
+// WriteError encode l'erreur au format XML S3 et l'envoie au client
+func WriteError(w http.ResponseWriter, r *http.Request, apiErr APIError) {
+	vars := mux.Vars(r)
+	requestID := NewRequestID()
+
+	response := dto.ErrorResponse{
+		Code:       apiErr.Code,
+		Message:    apiErr.Description,
+		BucketName: vars["bucketName"],
+		Key:        vars["objectName"],
+		Resource:   r.URL.Path,
+		RequestId:  requestID,
+		HostId:     requestID,
+	}
+
+	log.Printf("S3 error %s (%d) for %s %s: %s", apiErr.Code, apiErr.HTTPStatusCode, r.Method, r.URL.Path, apiErr.Description)
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("x-amz-request-id", requestID)
+	w.WriteHeader(apiErr.HTTPStatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding S3 error response: %v", err)
+	}
+}
+
+// NewRequestID génère un identifiant de requête au format utilisé par S3
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "0000000000000000"
+	}
+	return strings.ToUpper(hex.EncodeToString(b))
+}
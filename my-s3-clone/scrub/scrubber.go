@@ -0,0 +1,124 @@
+package scrub
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/storage"
+)
+
+// ErrRunning est renvoyée lorsqu'un passage est demandé alors qu'un autre est en cours
+var ErrRunning = errors.New("an integrity scrub is already running")
+
+// Scrubber planifie les passages de vérification d'intégrité d'un backend (voir storage.Scrubber)
+// et permet d'en lancer à la demande. Un seul passage s'exécute à la fois.
+type Scrubber struct {
+	backend storage.Scrubber
+	// Débit de lecture maximal d'un passage, en octets par seconde ; 0 : sans limite
+	bytesPerSecond int64
+
+	mu      sync.Mutex
+	running bool
+}
+
+// NewScrubber crée un scrubber pour le backend donné
+func NewScrubber(backend storage.Scrubber, bytesPerSecond int64) *Scrubber {
+	return &Scrubber{backend: backend, bytesPerSecond: bytesPerSecond}
+}
+
+// Run effectue un passage toutes les interval, jusqu'à l'annulation de ctx. Contrairement au
+// sweeper du cycle de vie, il n'en effectue pas au démarrage : relire tout le stockage à chaque
+// redémarrage serait trop coûteux.
+func (sc *Scrubber) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := sc.Scrub(ctx); errors.Is(err, ErrRunning) {
+			log.Printf("Scheduled integrity scrub skipped: %v", err)
+		}
+	}
+}
+
+// Scrub effectue un passage et renvoie son rapport, ou ErrRunning si un passage est déjà en cours
+func (sc *Scrubber) Scrub(ctx context.Context) (dto.IntegrityReport, error) {
+	if !sc.begin() {
+		return dto.IntegrityReport{}, ErrRunning
+	}
+	defer sc.end()
+	return sc.scrub(ctx)
+}
+
+// Start lance un passage en arrière-plan, ou renvoie ErrRunning si un passage est déjà en cours
+func (sc *Scrubber) Start(ctx context.Context) error {
+	if !sc.begin() {
+		return ErrRunning
+	}
+	go func() {
+		defer sc.end()
+		sc.scrub(ctx)
+	}()
+	return nil
+}
+
+// Running indique si un passage est en cours
+func (sc *Scrubber) Running() bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.running
+}
+
+// Report renvoie le rapport du dernier passage terminé, marqué InProgress si un passage est en
+// cours. Avant la fin du premier passage, il renvoie storage.ErrNoIntegrityReport, sauf si ce
+// passage est en cours.
+func (sc *Scrubber) Report() (dto.IntegrityReport, error) {
+	running := sc.Running()
+	report, err := sc.backend.LastIntegrityReport()
+	if errors.Is(err, storage.ErrNoIntegrityReport) && running {
+		return dto.IntegrityReport{InProgress: true}, nil
+	}
+	report.InProgress = running
+	return report, err
+}
+
+func (sc *Scrubber) begin() bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.running {
+		return false
+	}
+	sc.running = true
+	return true
+}
+
+func (sc *Scrubber) end() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.running = false
+}
+
+func (sc *Scrubber) scrub(ctx context.Context) (dto.IntegrityReport, error) {
+	report, err := sc.backend.Scrub(ctx, sc.bytesPerSecond)
+	if err != nil {
+		log.Printf("Integrity scrub failed: %v", err)
+		return report, err
+	}
+	log.Printf("Integrity scrub: %d object(s) checked (%d bytes), %d corrupt, %d missing, %d without checksum",
+		report.ObjectsChecked, report.BytesChecked, len(report.Corrupt), len(report.Missing), len(report.Unverified))
+	for _, issue := range report.Corrupt {
+		log.Printf("Corrupt object %s/%s (version %q): expected SHA-256 %s, got %s", issue.Bucket, issue.Key, issue.VersionID, issue.Expected, issue.Actual)
+	}
+	for _, issue := range report.Missing {
+		log.Printf("Missing object %s/%s (version %q)", issue.Bucket, issue.Key, issue.VersionID)
+	}
+	return report, nil
+}
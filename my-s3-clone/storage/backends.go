@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BackendConfig regroupe les paramètres transmis aux backends à leur création
+type BackendConfig struct {
+	// Répertoire racine des backends sur disque ; DefaultRoot si vide
+	Root string
+}
+
+// BackendFactory crée une instance de backend à partir de la configuration
+type BackendFactory func(cfg BackendConfig) (Storage, error)
+
+// Backend utilisé quand aucun n'est configuré
+const DefaultBackend = "fs"
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]BackendFactory)
+)
+
+// RegisterBackend rend un backend disponible sous le nom donné. Enregistrer deux fois
+// le même nom est une erreur de programmation.
+func RegisterBackend(name string, factory BackendFactory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if _, exists := backends[name]; exists {
+		panic(fmt.Sprintf("storage backend %q registered twice", name))
+	}
+	backends[name] = factory
+}
+
+// Backends renvoie, triés, les noms des backends enregistrés
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewBackend crée le backend enregistré sous le nom donné (DefaultBackend si name est vide)
+func NewBackend(name string, cfg BackendConfig) (Storage, error) {
+	if name == "" {
+		name = DefaultBackend
+	}
+
+	backendsMu.RLock()
+	factory, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q (available: %s)", name, strings.Join(Backends(), ", "))
+	}
+	return factory(cfg)
+}
+
+func init() {
+	// Système de fichiers : un fichier par objet, sous la racine configurée
+	RegisterBackend("fs", func(cfg BackendConfig) (Storage, error) {
+		fs := NewFileStorage(cfg.Root)
+		fs.recoverJournal()
+		return fs, nil
+	})
+	// Mémoire : pour les tests et le développement, rien n'est conservé à l'arrêt
+	RegisterBackend("memory", func(cfg BackendConfig) (Storage, error) {
+		return NewMemoryStorage(), nil
+	})
+	// Système de fichiers adressé par contenu : les contenus identiques ne sont stockés qu'une fois
+	RegisterBackend("cas", func(cfg BackendConfig) (Storage, error) {
+		fs := NewContentAddressedStorage(cfg.Root)
+		fs.recoverJournal()
+		return fs, nil
+	})
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Stockage adressé par contenu (backend "cas"). L'arborescence est celle de FileStorage, mais
+// chaque contenu n'est stocké qu'une fois, sous .s3clone/blobs/<ab>/<sha256> : le fichier d'un
+// objet, courant ou archivé, est un lien physique vers son blob. Les photos identiques ne
+// prennent donc de la place qu'une fois, et les lectures restent de simples lectures de fichier.
+// Un blob dont le seul lien restant est celui du répertoire blobs n'est plus utilisé : il est
+// supprimé dès que le dernier objet qui le référençait disparaît.
+
+// NewContentAddressedStorage crée un stockage sur disque qui déduplique les contenus par SHA-256
+func NewContentAddressedStorage(root string) *FileStorage {
+	return &FileStorage{Root: root, contentAddressed: true}
+}
+
+func (fs *FileStorage) blobsDir() string {
+	return filepath.Join(fs.root(), systemDirName, "blobs")
+}
+
+func (fs *FileStorage) blobPath(sum string) string {
+	return filepath.Join(fs.blobsDir(), sum[:2], sum)
+}
+
+// storeBlob range le contenu du fichier tmpPath dans les blobs et fait de tmpPath un lien vers
+// ce blob : si un contenu identique existe déjà, le fichier temporaire est remplacé par un lien
+// vers l'existant. Renvoie l'empreinte SHA-256 du contenu. Doit être appelée sous fs.mu.
+func (fs *FileStorage) storeBlob(tmpPath string) (string, error) {
+	sum, err := fileSHA256(tmpPath)
+	if err != nil {
+		return "", err
+	}
+
+	blob := fs.blobPath(sum)
+	if err := os.MkdirAll(filepath.Dir(blob), os.ModePerm); err != nil {
+		return "", err
+	}
+	if err := os.Link(tmpPath, blob); err == nil {
+		return sum, nil
+	} else if !errors.Is(err, os.ErrExist) {
+		return "", fmt.Errorf("failed to store blob %s: %v", sum, err)
+	}
+
+	// Contenu déjà connu : le fichier temporaire devient un lien vers le blob existant
+	if err := os.Remove(tmpPath); err != nil {
+		return "", err
+	}
+	if err := os.Link(blob, tmpPath); err != nil {
+		return "", fmt.Errorf("failed to link blob %s: %v", sum, err)
+	}
+	log.Printf("Content %s already stored, deduplicated", sum)
+	return sum, nil
+}
+
+// currentBlob renvoie le blob de la version courante d'un objet, ou "" si l'objet n'existe pas
+// ou si le stockage n'est pas adressé par contenu
+func (fs *FileStorage) currentBlob(bucketName, objectName string) string {
+	if !fs.contentAddressed {
+		return ""
+	}
+	var meta objectMeta
+	if err := readJSONFile(fs.metaPath(bucketName, objectName), &meta); err != nil {
+		return ""
+	}
+	return meta.SHA256
+}
+
+// releaseBlob supprime un blob qui n'est plus référencé par aucun objet. Doit être appelée
+// sous fs.mu, après la suppression du fichier de l'objet.
+func (fs *FileStorage) releaseBlob(sum string) {
+	if !fs.contentAddressed || len(sum) != sha256.Size*2 {
+		return
+	}
+	path := fs.blobPath(sum)
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if stat, ok := fileInfo.Sys().(*syscall.Stat_t); ok && stat.Nlink <= 1 {
+		if err := os.Remove(path); err != nil {
+			log.Printf("Failed to remove unused blob %s: %v", sum, err)
+			return
+		}
+		removeEmptyParents(fs.blobsDir(), path)
+	}
+}
+
+// collectBlobs supprime tous les blobs qui ne sont plus référencés, par exemple après la
+// suppression d'un bucket entier. Doit être appelée sous fs.mu.
+func (fs *FileStorage) collectBlobs() {
+	if !fs.contentAddressed {
+		return
+	}
+	root := fs.blobsDir()
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		fs.releaseBlob(d.Name())
+		return nil
+	})
+}
+
+// fileSHA256 calcule le SHA-256 hexadécimal du contenu d'un fichier
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
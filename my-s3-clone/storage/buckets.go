@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// Les métadonnées d'un bucket (date de création, propriétaire, région, ACL) sont conservées avec sa
+// configuration (versioning, cycle de vie, Object Lock...) dans .s3clone/buckets/<bucket>.json.
+
+// initBucketConfig enregistre la date de création d'un bucket qui vient d'être créé
+func (fs *FileStorage) initBucketConfig(bucketName string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return err
+	}
+	if !config.CreationDate.IsZero() {
+		return nil
+	}
+	config.CreationDate = time.Now().UTC()
+	return writeJSONFile(fs.bucketConfigPath(bucketName), config)
+}
+
+// Lecture des métadonnées d'un bucket
+func (fs *FileStorage) GetBucketInfo(bucketName string) (dto.BucketInfo, error) {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return dto.BucketInfo{}, err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return dto.BucketInfo{}, err
+	}
+	if config.CreationDate.IsZero() {
+		// Bucket créé avant l'enregistrement des métadonnées : la date de modification du
+		// répertoire en tient lieu, et est enregistrée pour ne plus varier
+		fileInfo, err := os.Stat(fs.bucketDir(bucketName))
+		if err != nil {
+			return dto.BucketInfo{}, err
+		}
+		config.CreationDate = fileInfo.ModTime().UTC()
+		if err := writeJSONFile(fs.bucketConfigPath(bucketName), config); err != nil {
+			log.Printf("Failed to save creation date of bucket %s: %v", bucketName, err)
+		}
+	}
+
+	return dto.BucketInfo{
+		Name:         bucketName,
+		CreationDate: config.CreationDate,
+		Owner:        config.Owner,
+		Region:       config.Region,
+		ACL:          config.ACL,
+	}, nil
+}
+
+// Enregistrement du propriétaire, de la région et de l'ACL d'un bucket
+func (fs *FileStorage) PutBucketInfo(bucketName string, info dto.BucketInfo) error {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return err
+	}
+	config.Owner, config.Region, config.ACL = info.Owner, info.Region, info.ACL
+	return writeJSONFile(fs.bucketConfigPath(bucketName), config)
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"log"
+
+	"my-s3-clone/dto"
+)
+
+// Les règles CORS sont conservées dans la configuration du bucket ; elles sont évaluées par le
+// middleware CORS pour chaque requête cross-origin (voir le package cors).
+
+// Lecture des règles CORS d'un bucket
+func (fs *FileStorage) GetBucketCors(bucketName string) (dto.CORSConfiguration, error) {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return dto.CORSConfiguration{}, err
+	}
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return dto.CORSConfiguration{}, err
+	}
+	if config.CORS == nil {
+		return dto.CORSConfiguration{}, ErrNoSuchCORS
+	}
+	return *config.CORS, nil
+}
+
+// Remplacement des règles CORS d'un bucket (elles sont validées par la couche HTTP)
+func (fs *FileStorage) PutBucketCors(bucketName string, cors dto.CORSConfiguration) error {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return err
+	}
+	config.CORS = &cors
+	log.Printf("CORS of bucket %s set to %d rule(s)", bucketName, len(cors.Rules))
+	return writeJSONFile(fs.bucketConfigPath(bucketName), config)
+}
+
+// Suppression des règles CORS d'un bucket
+func (fs *FileStorage) DeleteBucketCors(bucketName string) error {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil || config.CORS == nil {
+		return err
+	}
+	config.CORS = nil
+	log.Printf("CORS of bucket %s removed", bucketName)
+	return writeJSONFile(fs.bucketConfigPath(bucketName), config)
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"log"
+
+	"my-s3-clone/dto"
+)
+
+// Le chiffrement par défaut est conservé dans la configuration du bucket ; il est appliqué par
+// EncryptedStorage aux écritures qui ne demandent pas de chiffrement.
+
+// Lecture du chiffrement par défaut d'un bucket
+func (fs *FileStorage) GetBucketEncryption(bucketName string) (dto.ServerSideEncryptionConfiguration, error) {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return dto.ServerSideEncryptionConfiguration{}, err
+	}
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return dto.ServerSideEncryptionConfiguration{}, err
+	}
+	if config.Encryption == nil {
+		return dto.ServerSideEncryptionConfiguration{}, ErrNoSuchEncryptionConfiguration
+	}
+	return *config.Encryption, nil
+}
+
+// Remplacement du chiffrement par défaut d'un bucket (il est validé par la couche HTTP)
+func (fs *FileStorage) PutBucketEncryption(bucketName string, encryption dto.ServerSideEncryptionConfiguration) error {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return err
+	}
+	config.Encryption = &encryption
+	log.Printf("Default encryption of bucket %s set", bucketName)
+	return writeJSONFile(fs.bucketConfigPath(bucketName), config)
+}
+
+// Suppression du chiffrement par défaut d'un bucket ; les objets déjà chiffrés le restent
+func (fs *FileStorage) DeleteBucketEncryption(bucketName string) error {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil || config.Encryption == nil {
+		return err
+	}
+	config.Encryption = nil
+	log.Printf("Default encryption of bucket %s removed", bucketName)
+	return writeJSONFile(fs.bucketConfigPath(bucketName), config)
+}
@@ -0,0 +1,517 @@
+package storage
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+
+	"my-s3-clone/dto"
+)
+
+// EncryptedStorage chiffre au repos, quel que soit le backend, les objets pour lesquels un
+// chiffrement côté serveur est demandé dans les métadonnées : SSE-S3 avec la clé maître de la
+// configuration, SSE-C avec la clé fournie par le client. Une écriture qui n'en demande pas reçoit
+// le chiffrement par défaut du bucket, s'il en a un. Le backend ne voit que le contenu chiffré
+// (voir sealed.go) ; les autres objets sont lus et écrits tels quels.
+//
+// La clé du client accompagne les métadonnées pour les écritures (AddObject,
+// CreateMultipartUpload, cible de CopyObject) ; pour les lectures, les parties d'un upload et la
+// source d'une copie, elle est fournie par la vue renvoyée par WithCustomerKey.
+type EncryptedStorage struct {
+	Storage
+	// Clé maître AES-256 de SSE-S3 ; nil si SSE-S3 n'est pas configuré
+	masterKey []byte
+	// Clé du client (SSE-C) pour la requête en cours
+	customerKey []byte
+}
+
+// Taille des clés de chiffrement (AES-256)
+const EncryptionKeySize = sealKeySize
+
+// NewEncryptedStorage ajoute le chiffrement côté serveur au backend donné
+func NewEncryptedStorage(backend Storage, masterKey []byte) *EncryptedStorage {
+	return &EncryptedStorage{Storage: backend, masterKey: masterKey}
+}
+
+// WithCustomerKey renvoie une vue de s qui utilise la clé du client key pour lire les objets SSE-C.
+// Si s ne chiffre pas les objets, il est renvoyé tel quel.
+func WithCustomerKey(s Storage, key []byte) Storage {
+	es, ok := s.(*EncryptedStorage)
+	if !ok || key == nil {
+		return s
+	}
+	view := *es
+	view.customerKey = key
+	return &view
+}
+
+// CustomerKeyMD5 renvoie l'empreinte d'une clé de client telle qu'elle est transmise par S3 (MD5 en base64)
+func CustomerKeyMD5(key []byte) string {
+	sum := md5.Sum(key)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// writeKey renvoie la clé qui enveloppe les clés de données d'un objet à chiffrer
+func (es *EncryptedStorage) writeKey(encryption *dto.Encryption) ([]byte, error) {
+	if encryption.Algorithm != dto.EncryptionAES256 {
+		return nil, ErrInvalidEncryption
+	}
+	if !encryption.CustomerProvided() {
+		if es.masterKey == nil {
+			return nil, ErrEncryptionNotConfigured
+		}
+		return es.masterKey, nil
+	}
+	if len(encryption.CustomerKey) != EncryptionKeySize || CustomerKeyMD5(encryption.CustomerKey) != encryption.CustomerKeyMD5 {
+		return nil, ErrInvalidEncryption
+	}
+	return encryption.CustomerKey, nil
+}
+
+// readKey renvoie la clé qui déchiffre un objet (nil s'il est en clair) et vérifie que la clé
+// du client n'est fournie que pour un objet SSE-C, et que c'est la bonne
+func (es *EncryptedStorage) readKey(encryption *dto.Encryption) ([]byte, error) {
+	if encryption == nil || !encryption.CustomerProvided() {
+		if es.customerKey != nil {
+			return nil, ErrEncryptionNotApplicable
+		}
+		if encryption == nil {
+			return nil, nil
+		}
+		if es.masterKey == nil {
+			return nil, ErrEncryptionNotConfigured
+		}
+		return es.masterKey, nil
+	}
+	if es.customerKey == nil {
+		return nil, ErrCustomerKeyRequired
+	}
+	if CustomerKeyMD5(es.customerKey) != encryption.CustomerKeyMD5 {
+		return nil, ErrCustomerKeyMismatch
+	}
+	return es.customerKey, nil
+}
+
+// defaultEncryption renvoie le chiffrement par défaut du bucket, nil s'il n'en a pas
+func (es *EncryptedStorage) defaultEncryption(bucketName string) (*dto.Encryption, error) {
+	config, err := es.Storage.GetBucketEncryption(bucketName)
+	if errors.Is(err, ErrNoSuchEncryptionConfiguration) || errors.Is(err, ErrNoSuchBucket) {
+		return nil, nil
+	}
+	if err != nil || len(config.Rules) == 0 {
+		return nil, err
+	}
+	return &dto.Encryption{Algorithm: config.Rules[0].ApplyServerSideEncryptionByDefault.SSEAlgorithm}, nil
+}
+
+// withDefaultEncryption complète des métadonnées qui ne demandent pas de chiffrement avec celui du bucket
+func (es *EncryptedStorage) withDefaultEncryption(bucketName string, metadata dto.ObjectMetadata) (dto.ObjectMetadata, error) {
+	if metadata.Encryption != nil {
+		return metadata, nil
+	}
+	encryption, err := es.defaultEncryption(bucketName)
+	metadata.Encryption = encryption
+	return metadata, err
+}
+
+// PutBucketEncryption refuse un chiffrement par défaut SSE-S3 sans clé maître : toutes les
+// écritures dans le bucket échoueraient
+func (es *EncryptedStorage) PutBucketEncryption(bucketName string, config dto.ServerSideEncryptionConfiguration) error {
+	if es.masterKey == nil {
+		return ErrEncryptionNotConfigured
+	}
+	return es.Storage.PutBucketEncryption(bucketName, config)
+}
+
+// storedEncryption renvoie la description du chiffrement à enregistrer, sans la clé du client
+func storedEncryption(metadata dto.ObjectMetadata) dto.ObjectMetadata {
+	stored := *metadata.Encryption
+	stored.CustomerKey = nil
+	stored.PlainSize, stored.PlainETag = nil, ""
+	metadata.Encryption = &stored
+	return metadata
+}
+
+// recordPlain complète la description du chiffrement avec la taille et l'ETag du contenu en clair
+func recordPlain(encryption *dto.Encryption, size int64, etag string) {
+	encryption.PlainSize = &size
+	if !encryption.CustomerProvided() {
+		encryption.PlainETag = etag
+	}
+}
+
+func (es *EncryptedStorage) AddObject(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	metadata, err := es.withDefaultEncryption(bucketName, metadata)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if metadata.Encryption == nil {
+		return es.Storage.AddObject(bucketName, objectName, data, metadata)
+	}
+	kek, err := es.writeKey(metadata.Encryption)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	sealed, err := newSealReader(data, kek)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	// Le backend enregistre les métadonnées après avoir lu tout le contenu : la taille et l'ETag en
+	// clair y sont donc complétés avant d'être écrits
+	stored := storedEncryption(metadata)
+	sealed.finished = func() {
+		recordPlain(stored.Encryption, sealed.size, hex.EncodeToString(sealed.hash.Sum(nil)))
+	}
+	info, err := es.Storage.AddObject(bucketName, objectName, sealed, stored)
+	if err != nil {
+		return info, err
+	}
+	// Comme S3, l'ETag d'un objet SSE-S3 est le MD5 du contenu en clair ; celui d'un objet SSE-C ne l'est pas
+	info.Size = sealed.size
+	if !metadata.Encryption.CustomerProvided() {
+		info.ETag = hex.EncodeToString(sealed.hash.Sum(nil))
+	}
+	return info, nil
+}
+
+// describe corrige la taille et l'ETag d'un objet chiffré d'après ses segments
+func (es *EncryptedStorage) describe(info dto.ObjectInfo, segments []sealedSegment) dto.ObjectInfo {
+	info.Size = layoutSize(segments)
+	if len(segments) == 1 && !info.Encryption.CustomerProvided() && es.masterKey != nil {
+		etag, err := segmentMD5(es.masterKey, segments[0])
+		if err != nil {
+			log.Printf("Failed to read ETag of encrypted object %s: %v", info.Key, err)
+		} else {
+			info.ETag = etag
+		}
+	}
+	return info
+}
+
+func (es *EncryptedStorage) GetObject(bucketName, objectName, versionID string) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+	reader, info, err := es.Storage.GetObject(bucketName, objectName, versionID)
+	if err != nil {
+		return reader, info, err
+	}
+	kek, err := es.readKey(info.Encryption)
+	if err != nil {
+		reader.Close()
+		return nil, info, err
+	}
+	if kek == nil {
+		return reader, info, nil
+	}
+
+	segments, err := readSealedLayout(reader, info.Size)
+	if err != nil {
+		reader.Close()
+		return nil, info, err
+	}
+	return newSealedObject(reader, kek, segments), es.describe(info, segments), nil
+}
+
+// StatObject vérifie la clé d'un objet SSE-C comme GetObject : S3 l'exige aussi pour HEAD
+func (es *EncryptedStorage) StatObject(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+	info, err := es.Storage.StatObject(bucketName, objectName, versionID)
+	if err != nil {
+		return info, err
+	}
+	if _, err := es.readKey(info.Encryption); err != nil || info.Encryption == nil {
+		return info, err
+	}
+
+	reader, info, err := es.GetObject(bucketName, objectName, versionID)
+	if err != nil {
+		return info, err
+	}
+	reader.Close()
+	return info, nil
+}
+
+// plainInfo renvoie la taille et l'ETag d'un objet tels que le client les voit, sans exiger la clé
+// du client. Ils sont lus dans les métadonnées, ou à défaut dans les trailers, qui ne sont pas chiffrés.
+func (es *EncryptedStorage) plainInfo(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+	info, err := es.Storage.StatObject(bucketName, objectName, versionID)
+	if err != nil || info.Encryption == nil {
+		return info, err
+	}
+	if recorded, ok := recordedPlainInfo(info); ok {
+		return recorded, nil
+	}
+	reader, info, err := es.Storage.GetObject(bucketName, objectName, versionID)
+	if err != nil {
+		return info, err
+	}
+	defer reader.Close()
+
+	segments, err := readSealedLayout(reader, info.Size)
+	if err != nil {
+		return info, err
+	}
+	return es.describe(info, segments), nil
+}
+
+// recordedPlainInfo applique à info la taille et l'ETag en clair enregistrés à l'écriture de l'objet
+func recordedPlainInfo(info dto.ObjectInfo) (dto.ObjectInfo, bool) {
+	if info.Encryption.PlainSize == nil {
+		return info, false
+	}
+	info.Size = *info.Encryption.PlainSize
+	if info.Encryption.PlainETag != "" {
+		info.ETag = info.Encryption.PlainETag
+	}
+	return info, true
+}
+
+// PlaintextInfo corrige la taille et l'ETag d'un objet chiffré renvoyé par une couche placée sous
+// EncryptedStorage, comme les notifications. Sans valeurs enregistrées (upload multipart en cours
+// de finalisation), la taille est lue dans les trailers et l'ETag reste celui du backend.
+func PlaintextInfo(s Storage, bucketName string, info dto.ObjectInfo) (dto.ObjectInfo, error) {
+	if info.Encryption == nil {
+		return info, nil
+	}
+	if recorded, ok := recordedPlainInfo(info); ok {
+		return recorded, nil
+	}
+	reader, stored, err := s.GetObject(bucketName, info.Key, info.VersionID)
+	if err != nil {
+		return info, err
+	}
+	defer reader.Close()
+
+	segments, err := readSealedLayout(reader, stored.Size)
+	if err != nil {
+		return info, err
+	}
+	info.Size = layoutSize(segments)
+	return info, nil
+}
+
+// ListObjects corrige la taille (et l'ETag) des objets chiffrés listés
+func (es *EncryptedStorage) ListObjects(bucketName, prefix, delimiter, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
+	response, err := es.Storage.ListObjects(bucketName, prefix, delimiter, marker, maxKeys)
+	if err != nil {
+		return response, err
+	}
+	for i, object := range response.Contents {
+		info, err := es.plainInfo(bucketName, object.Key, "")
+		if err != nil || info.Encryption == nil {
+			continue
+		}
+		response.Contents[i].Size = int(info.Size)
+		response.Contents[i].ETag = `"` + info.ETag + `"`
+	}
+	return response, nil
+}
+
+// ListObjectVersions corrige la taille (et l'ETag) des versions chiffrées listées
+func (es *EncryptedStorage) ListObjectVersions(bucketName, prefix, keyMarker, versionIDMarker string, maxKeys int) (dto.ListVersionsResult, error) {
+	result, err := es.Storage.ListObjectVersions(bucketName, prefix, keyMarker, versionIDMarker, maxKeys)
+	if err != nil {
+		return result, err
+	}
+	for i, version := range result.Versions {
+		info, err := es.plainInfo(bucketName, version.Key, version.VersionId)
+		if err != nil || info.Encryption == nil {
+			continue
+		}
+		result.Versions[i].Size = info.Size
+		result.Versions[i].ETag = `"` + info.ETag + `"`
+	}
+	return result, nil
+}
+
+// CopyObject copie le contenu tel quel, chiffré ou non : la copie garde le chiffrement de la
+// source (avec la même clé du client pour SSE-C). Si metadata demande un chiffrement, ou si la
+// source est en clair et que le bucket cible a un chiffrement par défaut, la source est déchiffrée
+// puis la copie chiffrée.
+func (es *EncryptedStorage) CopyObject(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	if metadata == nil || metadata.Encryption == nil {
+		encryption, err := es.plainSourceDefault(sourceBucket, sourceKey, sourceVersionID, targetBucket)
+		if err != nil {
+			return dto.ObjectInfo{}, err
+		}
+		if encryption != nil {
+			return es.sealCopy(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey, metadata, encryption)
+		}
+
+		// La clé du client, si elle est fournie, doit être celle de la source
+		if es.customerKey != nil {
+			if _, err := es.StatObject(sourceBucket, sourceKey, sourceVersionID); err != nil {
+				return dto.ObjectInfo{}, err
+			}
+		}
+		info, err := es.Storage.CopyObject(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey, metadata)
+		if err != nil || info.Encryption == nil {
+			return info, err
+		}
+		plain, err := es.plainInfo(targetBucket, targetKey, info.VersionID)
+		if err != nil {
+			return info, err
+		}
+		return plain, nil
+	}
+
+	reader, _, err := es.GetObject(sourceBucket, sourceKey, sourceVersionID)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	defer reader.Close()
+
+	// Le backend écrit la copie dans un fichier temporaire avant de la mettre en place :
+	// une copie sur elle-même peut relire la source jusqu'au bout
+	return es.AddObject(targetBucket, targetKey, reader, *metadata)
+}
+
+// plainSourceDefault renvoie le chiffrement par défaut du bucket cible si la source d'une copie
+// ou d'un déplacement est en clair, nil sinon
+func (es *EncryptedStorage) plainSourceDefault(sourceBucket, sourceKey, sourceVersionID, targetBucket string) (*dto.Encryption, error) {
+	encryption, err := es.defaultEncryption(targetBucket)
+	if err != nil || encryption == nil {
+		return nil, err
+	}
+	source, err := es.Storage.StatObject(sourceBucket, sourceKey, sourceVersionID)
+	if err != nil || source.Encryption != nil {
+		// L'erreur éventuelle est renvoyée par la copie elle-même
+		return nil, nil
+	}
+	return encryption, nil
+}
+
+// sealCopy copie une source en clair en la chiffrant. Si metadata est nil, la copie reprend les
+// métadonnées et les tags de la source, sans son verrouillage ni son ACL.
+func (es *EncryptedStorage) sealCopy(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey string, metadata *dto.ObjectMetadata, encryption *dto.Encryption) (dto.ObjectInfo, error) {
+	reader, source, err := es.GetObject(sourceBucket, sourceKey, sourceVersionID)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	defer reader.Close()
+
+	copied := source.ObjectMetadata
+	copied.Lock, copied.ACL = nil, ""
+	if metadata != nil {
+		copied = *metadata
+	}
+	copied.Encryption = encryption
+	return es.AddObject(targetBucket, targetKey, reader, copied)
+}
+
+// MoveObject déplace le contenu tel quel : la cible garde le chiffrement de la source. Un objet en
+// clair déplacé vers un bucket qui a un chiffrement par défaut ne peut pas l'être par un simple
+// renommage : il est copié en étant chiffré, puis la source est supprimée.
+func (es *EncryptedStorage) MoveObject(sourceBucket, sourceKey, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	encryption, err := es.plainSourceDefault(sourceBucket, sourceKey, "", targetBucket)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if encryption != nil {
+		return es.sealMove(sourceBucket, sourceKey, targetBucket, targetKey, metadata, encryption)
+	}
+
+	info, err := es.Storage.MoveObject(sourceBucket, sourceKey, targetBucket, targetKey, metadata)
+	if err != nil || info.Encryption == nil {
+		return info, err
+	}
+	return es.plainInfo(targetBucket, targetKey, info.VersionID)
+}
+
+// sealMove déplace une source en clair en la chiffrant. Si la source ne peut pas être supprimée
+// (verrouillage, erreur du backend), la copie chiffrée l'est à sa place : le déplacement n'a pas lieu.
+func (es *EncryptedStorage) sealMove(sourceBucket, sourceKey, targetBucket, targetKey string, metadata *dto.ObjectMetadata, encryption *dto.Encryption) (dto.ObjectInfo, error) {
+	if sourceBucket == targetBucket && sourceKey == targetKey {
+		return dto.ObjectInfo{}, ErrMoveOntoItself
+	}
+	info, err := es.sealCopy(sourceBucket, sourceKey, "", targetBucket, targetKey, metadata, encryption)
+	if err != nil {
+		return info, err
+	}
+	if _, err := es.Storage.DeleteObject(sourceBucket, sourceKey, ""); err != nil {
+		if _, rollbackErr := es.Storage.DeleteObject(targetBucket, targetKey, info.VersionID); rollbackErr != nil {
+			log.Printf("Failed to remove encrypted copy %s/%s of unmovable object %s/%s: %v", targetBucket, targetKey, sourceBucket, sourceKey, rollbackErr)
+		}
+		return dto.ObjectInfo{}, err
+	}
+	return info, nil
+}
+
+func (es *EncryptedStorage) CreateMultipartUpload(bucketName, objectName string, metadata dto.ObjectMetadata) (string, error) {
+	metadata, err := es.withDefaultEncryption(bucketName, metadata)
+	if err != nil {
+		return "", err
+	}
+	if metadata.Encryption == nil {
+		return es.Storage.CreateMultipartUpload(bucketName, objectName, metadata)
+	}
+	if _, err := es.writeKey(metadata.Encryption); err != nil {
+		return "", err
+	}
+	return es.Storage.CreateMultipartUpload(bucketName, objectName, storedEncryption(metadata))
+}
+
+// UploadPart chiffre chaque partie en un segment ; pour SSE-C, la clé de chaque partie doit être
+// celle fournie au démarrage de l'upload
+func (es *EncryptedStorage) UploadPart(bucketName, objectName, uploadID string, partNumber int, data io.Reader) (string, error) {
+	metadata, err := es.Storage.MultipartUploadMetadata(bucketName, objectName, uploadID)
+	if err != nil {
+		return "", err
+	}
+	kek, err := es.readKey(metadata.Encryption)
+	if err != nil {
+		return "", err
+	}
+	if kek == nil {
+		return es.Storage.UploadPart(bucketName, objectName, uploadID, partNumber, data)
+	}
+
+	sealed, err := newSealReader(data, kek)
+	if err != nil {
+		return "", err
+	}
+	return es.Storage.UploadPart(bucketName, objectName, uploadID, partNumber, sealed)
+}
+
+func (es *EncryptedStorage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+	info, err := es.Storage.CompleteMultipartUpload(bucketName, objectName, uploadID, parts)
+	if err != nil || info.Encryption == nil {
+		return info, err
+	}
+	plain, err := es.plainInfo(bucketName, objectName, info.VersionID)
+	if err != nil {
+		log.Printf("Failed to read size of encrypted object %s/%s: %v", bucketName, objectName, err)
+		return info, nil
+	}
+	info.Size = plain.Size
+
+	// La taille en clair n'est connue qu'une fois les parties assemblées ; sans elle, les listings
+	// la relisent dans les trailers
+	err = es.Storage.UpdateObjectMetadata(bucketName, objectName, info.VersionID, func(metadata *dto.ObjectMetadata) error {
+		if metadata.Encryption != nil && metadata.Encryption.PlainSize == nil {
+			recordPlain(metadata.Encryption, plain.Size, plain.ETag)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to record size of encrypted object %s/%s: %v", bucketName, objectName, err)
+	}
+	return info, nil
+}
+
+// ListParts renvoie la taille en clair des parties d'un upload chiffré
+func (es *EncryptedStorage) ListParts(bucketName, objectName, uploadID string, partNumberMarker, maxParts int) (dto.ListPartsResult, error) {
+	result, err := es.Storage.ListParts(bucketName, objectName, uploadID, partNumberMarker, maxParts)
+	if err != nil {
+		return result, err
+	}
+	metadata, err := es.Storage.MultipartUploadMetadata(bucketName, objectName, uploadID)
+	if err != nil || metadata.Encryption == nil {
+		return result, err
+	}
+	for i, part := range result.Parts {
+		result.Parts[i].Size = plainSize(part.Size)
+	}
+	return result, nil
+}
@@ -0,0 +1,40 @@
+package storage
+
+import "errors"
+
+// Erreurs renvoyées par les implémentations de Storage, traduites en erreurs S3 par les handlers
+var (
+	ErrNoSuchBucket       = errors.New("bucket does not exist")
+	ErrNoSuchUpload       = errors.New("multipart upload does not exist")
+	ErrInvalidPart        = errors.New("one or more parts could not be found or have a different ETag")
+	ErrInvalidPartOrder   = errors.New("parts are not in ascending order")
+	ErrInvalidPartNumber  = errors.New("part number must be an integer between 1 and 10000")
+	ErrEntityTooSmall     = errors.New("part is smaller than the minimum allowed size")
+	ErrNoSuchVersion      = errors.New("version does not exist")
+	ErrDeleteMarker       = errors.New("version is a delete marker")
+	ErrInvalidVersioning  = errors.New("invalid versioning status")
+	ErrInvalidBucketName  = errors.New("invalid bucket name")
+	ErrInvalidObjectName  = errors.New("invalid object key")
+	ErrKeyConflict        = errors.New("object key conflicts with an existing key prefix")
+	ErrMoveOntoItself     = errors.New("source and target of a move are the same object")
+	ErrNoSuchLifecycle    = errors.New("bucket has no lifecycle configuration")
+	ErrNoSuchCORS         = errors.New("bucket has no CORS configuration")
+	ErrNoSuchBucketPolicy = errors.New("bucket has no policy")
+	ErrNoIntegrityReport  = errors.New("no integrity scrub has completed yet")
+
+	// Quotas (voir le package quota)
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+	// Object Lock (voir ObjectLockStorage)
+	ErrNoSuchObjectLockConfiguration = errors.New("bucket has no object lock configuration")
+	ErrObjectLockNotEnabled          = errors.New("object lock is not enabled on the bucket")
+	ErrObjectLocked                  = errors.New("object is protected by object lock")
+
+	// Chiffrement côté serveur (voir EncryptedStorage)
+	ErrNoSuchEncryptionConfiguration = errors.New("bucket has no default encryption")
+	ErrInvalidEncryption             = errors.New("invalid server-side encryption parameters")
+	ErrEncryptionNotConfigured       = errors.New("server-side encryption with a master key is not configured")
+	ErrEncryptionNotApplicable       = errors.New("encryption parameters are not applicable to this object")
+	ErrCustomerKeyRequired           = errors.New("object is encrypted with a customer-provided key")
+	ErrCustomerKeyMismatch           = errors.New("customer-provided key does not match the object key")
+)
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Répertoire système, à la racine du stockage, qui contient les données internes du serveur.
+// Son nom commence par un point : ce ne peut donc pas être un nom de bucket valide.
+const systemDirName = ".s3clone"
+
+// createTempFile crée un fichier temporaire dans le répertoire système. Il est sur le même
+// système de fichiers que les buckets : il peut donc être mis en place par un simple renommage.
+func (fs *FileStorage) createTempFile() (*os.File, error) {
+	dir := filepath.Join(fs.root(), systemDirName, "tmp")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(dir, "object-*")
+}
+
+// writeJSONFile sérialise v dans un fichier temporaire synchronisé sur disque puis le renomme, pour ne
+// jamais exposer un fichier à moitié écrit
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir synchronise un répertoire sur disque, pour qu'un fichier qui vient d'y être renommé
+// survive à un arrêt brutal
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// fileIdentity identifie un fichier par son périphérique et son inode : l'identité est conservée par
+// les renommages et partagée par les liens physiques, mais pas par une copie, même identique
+func fileIdentity(path string) (string, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", fmt.Errorf("no inode for %s", path)
+	}
+	return fmt.Sprintf("%d:%d", stat.Dev, stat.Ino), nil
+}
+
+// readJSONFile lit et désérialise un fichier écrit par writeJSONFile
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Journal des opérations sur disque qui ne peuvent pas être atomiques, sous .s3clone/journal.
+// Chaque entrée est écrite avant l'opération et supprimée une fois celle-ci terminée ; au démarrage
+// du backend, les entrées restantes sont reprises par recoverJournal.
+
+func (fs *FileStorage) journalDir() string {
+	return filepath.Join(fs.root(), systemDirName, "journal")
+}
+
+// writeJournal enregistre une entrée du journal, dont le nom commence par prefix, et renvoie son chemin
+func (fs *FileStorage) writeJournal(prefix string, entry interface{}) (string, error) {
+	if err := os.MkdirAll(fs.journalDir(), os.ModePerm); err != nil {
+		return "", err
+	}
+	id, err := newVersionID()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(fs.journalDir(), prefix+id+".json")
+	if err := writeJSONFile(path, entry); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// recoverJournal reprend les opérations interrompues par un arrêt du serveur ; elle est appelée à
+// la création du backend. Les déplacements sont repris avant les écritures : un déplacement annulé
+// supprime l'entrée qui mettait sa cible en place (voir dropCommitJournal).
+func (fs *FileStorage) recoverJournal() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	recoverers := []struct {
+		prefix  string
+		recover func(path string) error
+	}{
+		{"move-", fs.recoverMove},
+		{"commit-", fs.recoverCommit},
+	}
+	for _, recoverer := range recoverers {
+		entries, err := os.ReadDir(fs.journalDir())
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), recoverer.prefix) {
+				continue
+			}
+			path := filepath.Join(fs.journalDir(), entry.Name())
+			if err := recoverer.recover(path); err != nil {
+				log.Printf("Failed to recover journal %s: %v", path, err)
+			}
+		}
+	}
+}
+
+// commitJournal décrit la mise en place d'une nouvelle version : le contenu est renommé à sa place
+// puis son sidecar est écrit, et un arrêt entre les deux laisserait le nouveau contenu avec les
+// métadonnées de l'ancien (ETag, type, empreinte)
+type commitJournal struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	// Fichier temporaire renommé en objet, et son identité (voir fileIdentity), que le renommage conserve
+	Staged     string `json:"staged"`
+	StagedFile string `json:"stagedFile"`
+	// Sidecar de la nouvelle version
+	Meta objectMeta `json:"meta"`
+}
+
+// writeCommitJournal enregistre la mise en place de staged avant le renommage
+func (fs *FileStorage) writeCommitJournal(bucketName, objectName, staged string, meta objectMeta) (string, error) {
+	identity, err := fileIdentity(staged)
+	if err != nil {
+		return "", err
+	}
+	path, err := fs.writeJournal("commit-", commitJournal{
+		Bucket:     bucketName,
+		Key:        objectName,
+		Staged:     staged,
+		StagedFile: identity,
+		Meta:       meta,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to write commit journal: %v", err)
+	}
+	return path, nil
+}
+
+// recoverCommit reprend une mise en place interrompue. Si le contenu n'a pas été renommé, l'écriture
+// n'a jamais abouti et le fichier temporaire est supprimé ; s'il est en place, son sidecar est écrit.
+// Un objet remplacé depuis n'est pas le fichier journalisé et garde son sidecar.
+func (fs *FileStorage) recoverCommit(path string) error {
+	var journal commitJournal
+	if err := readJSONFile(path, &journal); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(journal.Staged); err == nil {
+		if err := os.Remove(journal.Staged); err != nil {
+			return err
+		}
+		fs.releaseBlob(journal.Meta.SHA256)
+		log.Printf("Rolled back interrupted write of %s/%s", journal.Bucket, journal.Key)
+	} else if identity, err := fileIdentity(fs.objectFile(journal.Bucket, journal.Key)); err == nil && identity == journal.StagedFile {
+		if err := fs.writeObjectMeta(journal.Bucket, journal.Key, journal.Meta); err != nil {
+			return err
+		}
+		log.Printf("Completed interrupted write of %s/%s", journal.Bucket, journal.Key)
+	}
+	return os.Remove(path)
+}
+
+// dropCommitJournal supprime l'entrée du journal qui met en place staged, quand le fichier
+// temporaire a été supprimé sans être renommé. Doit être appelée sous fs.mu.
+func (fs *FileStorage) dropCommitJournal(staged string) {
+	entries, err := os.ReadDir(fs.journalDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "commit-") {
+			continue
+		}
+		path := filepath.Join(fs.journalDir(), entry.Name())
+		var journal commitJournal
+		if err := readJSONFile(path, &journal); err == nil && journal.Staged == staged {
+			if err := os.Remove(path); err != nil {
+				log.Printf("Failed to remove commit journal %s: %v", path, err)
+			}
+		}
+	}
+}
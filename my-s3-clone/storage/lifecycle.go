@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"log"
+
+	"my-s3-clone/dto"
+)
+
+// Les règles de cycle de vie sont conservées dans la configuration du bucket ; elles sont
+// appliquées par le sweeper du package lifecycle, qui passe par l'interface Storage.
+
+// Lecture des règles de cycle de vie d'un bucket
+func (fs *FileStorage) GetBucketLifecycle(bucketName string) (dto.LifecycleConfiguration, error) {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return dto.LifecycleConfiguration{}, err
+	}
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return dto.LifecycleConfiguration{}, err
+	}
+	if config.Lifecycle == nil {
+		return dto.LifecycleConfiguration{}, ErrNoSuchLifecycle
+	}
+	return *config.Lifecycle, nil
+}
+
+// Remplacement des règles de cycle de vie d'un bucket (elles sont validées par la couche HTTP)
+func (fs *FileStorage) PutBucketLifecycle(bucketName string, lifecycle dto.LifecycleConfiguration) error {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return err
+	}
+	config.Lifecycle = &lifecycle
+	log.Printf("Lifecycle of bucket %s set to %d rule(s)", bucketName, len(lifecycle.Rules))
+	return writeJSONFile(fs.bucketConfigPath(bucketName), config)
+}
+
+// Suppression des règles de cycle de vie d'un bucket
+func (fs *FileStorage) DeleteBucketLifecycle(bucketName string) error {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil || config.Lifecycle == nil {
+		return err
+	}
+	config.Lifecycle = nil
+	log.Printf("Lifecycle of bucket %s removed", bucketName)
+	return writeJSONFile(fs.bucketConfigPath(bucketName), config)
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"my-s3-clone/dto"
+)
+
+// Nombre maximal de clés renvoyées par une page de listing, comme S3
+const MaxListKeys = 1000
+
+// Liste des objets d'un bucket, triés par clé. Les clés situées après marker sont renvoyées ;
+// si delimiter est renseigné, les clés qui le contiennent après le préfixe sont regroupées
+// dans CommonPrefixes (chaque préfixe compte pour une clé dans maxKeys).
+func (fs *FileStorage) ListObjects(bucketName, prefix, delimiter, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
+	response := dto.ListObjectsResponse{
+		Xmlns:     dto.S3Namespace,
+		Name:      bucketName,
+		Prefix:    prefix,
+		Marker:    marker,
+		MaxKeys:   maxKeys,
+		Delimiter: delimiter,
+		Contents:  make([]dto.Object, 0),
+	}
+	if err := fs.requireBucket(bucketName); err != nil {
+		return response, err
+	}
+
+	keys, err := fs.bucketKeys(bucketName, prefix)
+	if err != nil {
+		return response, err
+	}
+
+	err = paginateObjects(&response, keys, func(key string) (dto.ObjectInfo, error) {
+		fileInfo, err := os.Stat(fs.objectFile(bucketName, key))
+		if err != nil {
+			return dto.ObjectInfo{}, err
+		}
+		return fs.statObject(bucketName, key, fileInfo)
+	})
+	return response, err
+}
+
+// paginateObjects remplit une page de listing à partir des clés triées d'un bucket ; elle est
+// commune à tous les backends. stat renvoie os.ErrNotExist pour un objet supprimé entre-temps.
+func paginateObjects(response *dto.ListObjectsResponse, keys []string, stat func(key string) (dto.ObjectInfo, error)) error {
+	prefix, delimiter, marker, maxKeys := response.Prefix, response.Delimiter, response.Marker, response.MaxKeys
+
+	count := 0
+	lastPrefix := ""
+	for _, key := range keys {
+		if key <= marker || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		commonPrefix := ""
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				commonPrefix = key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		// Un préfixe déjà renvoyé (sur cette page ou comme marqueur de la précédente) est ignoré
+		if commonPrefix != "" && (commonPrefix == lastPrefix || commonPrefix == marker) {
+			continue
+		}
+
+		if count == maxKeys {
+			response.IsTruncated = true
+			break
+		}
+		count++
+
+		if commonPrefix != "" {
+			lastPrefix = commonPrefix
+			response.NextMarker = commonPrefix
+			response.CommonPrefixes = append(response.CommonPrefixes, dto.CommonPrefix{Prefix: commonPrefix})
+			continue
+		}
+
+		info, err := stat(key)
+		if errors.Is(err, os.ErrNotExist) {
+			// Objet supprimé pendant le listing
+			count--
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		response.NextMarker = key
+		response.Contents = append(response.Contents, dto.Object{
+			Key:          key,
+			LastModified: info.LastModified,
+			ETag:         `"` + info.ETag + `"`,
+			Size:         int(info.Size),
+			StorageClass: "STANDARD",
+		})
+	}
+
+	// NextMarker n'est renvoyé que si la liste est tronquée
+	if !response.IsTruncated {
+		response.NextMarker = ""
+	}
+	return nil
+}
+
+// bucketKeys liste, triées, les clés des objets d'un bucket qui commencent par prefix
+func (fs *FileStorage) bucketKeys(bucketName, prefix string) ([]string, error) {
+	bucketPath := fs.bucketDir(bucketName)
+
+	// Seul le sous-répertoire correspondant à la partie "dossier" du préfixe est parcouru
+	root := bucketPath
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		root = filepath.Join(bucketPath, filepath.FromSlash(prefix[:i]))
+		if root != bucketPath && !strings.HasPrefix(root, bucketPath+string(filepath.Separator)) {
+			return nil, nil
+		}
+	}
+
+	var keys []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) && path == root {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return err
+		}
+		key, err := filepath.Rel(bucketPath, path)
+		if err != nil {
+			return err
+		}
+		if key = filepath.ToSlash(key); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// L'ordre du parcours ("a/b" avant "a-c") n'est pas l'ordre des clés S3
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -1,194 +1,100 @@
 package storage
 
 import (
-    "strings"
+    "crypto/md5"
+    "crypto/sha256"
+    "encoding/hex"
     "os"
-    "path/filepath"
     "log"
     "fmt"
     "io"
-    "bufio"  
-    "strconv"
-    "time"
+    "sync"
     "my-s3-clone/dto"
 )
 
 // FileStorage implémente l'interface Storage avec un stockage basé sur le système de fichiers
-type FileStorage struct{}
-
-const storageRoot = "/mydata/data"
-
-func ProcessChunkedStream(reader io.Reader, writer io.Writer) error {
-    bufReader := bufio.NewReader(reader)
-    log.Println("Started processing chunked stream")
-
-    totalBytesProcessed := int64(0)
-
-    for {
-        // Read chunk size line
-        line, err := bufReader.ReadString('\n')
-        if err != nil {
-            log.Printf("Error reading chunk size: %v", err)
-            return fmt.Errorf("error reading chunk size: %v", err)
-        }
-        log.Printf("Received chunk size line: %s", line)
-
-        // Parse chunk size (ignore chunk extensions)
-        line = strings.TrimSpace(line)
-        parts := strings.SplitN(line, ";", 2)
-        chunkSizeHex := parts[0]
-
-        chunkSize, err := strconv.ParseInt(chunkSizeHex, 16, 64)
-        if err != nil {
-            log.Printf("Error parsing chunk size: %v", err)
-            return fmt.Errorf("error parsing chunk size: %v", err)
-        }
-
-        log.Printf("Parsed chunk size: %d", chunkSize)
-
-        // End of stream (zero-size chunk)
-        if chunkSize == 0 {
-            log.Println("Received final chunk (size 0), finishing")
-            break
-        }
-
-        // Copy chunk data to writer
-        if _, err := io.CopyN(writer, bufReader, chunkSize); err != nil {
-            log.Printf("Error reading chunk data: %v", err)
-            return fmt.Errorf("error reading chunk data: %v", err)
-        }
+type FileStorage struct {
+    // Répertoire racine des buckets ; DefaultRoot si vide
+    Root string
+    // Si vrai, les contenus identiques ne sont stockés qu'une fois (voir blobs.go)
+    contentAddressed bool
+    // Sérialise les opérations qui manipulent les versions d'un objet
+    mu sync.Mutex
+}
 
-        totalBytesProcessed += chunkSize
-        log.Printf("Successfully processed a chunk, total bytes processed: %d", totalBytesProcessed)
+// Répertoire racine utilisé par défaut par les backends sur disque
+const DefaultRoot = "/mydata/data"
 
-        // Discard the CRLF after the chunk
-        if _, err := bufReader.Discard(2); err != nil {
-            log.Printf("Error discarding CRLF: %v", err)
-            return fmt.Errorf("error discarding CRLF: %v", err)
-        }
+// NewFileStorage crée un stockage sur disque sous root (DefaultRoot si root est vide)
+func NewFileStorage(root string) *FileStorage {
+    return &FileStorage{Root: root}
+}
 
-        // Log chunk signature 
-        if len(parts) > 1 {
-            chunkSignature := parts[1]
-            log.Printf("Chunk signature: %s", chunkSignature)
-        }
+func (fs *FileStorage) root() string {
+    if fs.Root == "" {
+        return DefaultRoot
     }
-
-    log.Printf("Completed processing chunked stream, total bytes processed: %d", totalBytesProcessed)
-    return nil
+    return fs.Root
 }
 
-
-// Ajout d'un objet dans un bucket
-func (fs *FileStorage) AddObject(bucketName, objectName string, data io.Reader, contentSha256 string) error {
+// Ajout d'un objet dans un bucket, avec ses métadonnées. Le contenu est écrit dans un fichier
+// temporaire, synchronisé sur disque, puis mis en place par renommage : une version existante n'est
+// remplacée (ou archivée, si le bucket est versionné) qu'une fois l'upload terminé et vérifié. Une
+// erreur de lecture de data (upload interrompu, empreinte incorrecte) abandonne le fichier temporaire.
+// L'ETag (MD5 du contenu) est calculé pendant l'écriture.
+func (fs *FileStorage) AddObject(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error) {
     log.Printf("Starting object upload: %s in bucket: %s", objectName, bucketName)
 
-    objectPath, err := getUniqueObjectPath(bucketName, objectName)
-    if err != nil {
-        log.Printf("Failed to create object path for %s in bucket %s: %v", objectName, bucketName, err)
-        return fmt.Errorf("Failed to create object path: %v", err)
+    if err := checkObjectName(bucketName, objectName); err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    if err := fs.requireBucket(bucketName); err != nil {
+        return dto.ObjectInfo{}, err
     }
 
-    log.Printf("Object path created: %s", objectPath)
-
-    file, err := os.Create(objectPath)
+    file, err := fs.createTempFile()
     if err != nil {
-        log.Printf("Failed to create file: %s, error: %v", objectPath, err)
-        return fmt.Errorf("Failed to create file: %v", err)
+        log.Printf("Failed to create temporary file for %s: %v", objectName, err)
+        return dto.ObjectInfo{}, fmt.Errorf("Failed to create file: %v", err)
     }
+    defer os.Remove(file.Name())
     defer file.Close()
 
-    log.Printf("Writing data to object: %s", objectPath)
+    log.Printf("Writing data to temporary file: %s", file.Name())
 
-    if err := writeObjectToFile(data, file, contentSha256); err != nil {
+    hash := md5.New()
+    sum := sha256.New()
+    if err := writeObjectToFile(data, io.MultiWriter(file, hash, sum)); err != nil {
         log.Printf("Error writing object to file: %v", err)
-        return err
+        return dto.ObjectInfo{}, err
     }
-
-    log.Printf("Successfully uploaded file: %s", objectPath)
-    return nil
-}
-
-// Fonction pour obtenir un chemin unique si l'objet existe déjà
-func getUniqueObjectPath(bucketName, objectName string) (string, error) {
-    objectPath := filepath.Join(storageRoot, bucketName, objectName)
-    if _, err := os.Stat(objectPath); os.IsNotExist(err) {
-        return objectPath, nil
-    }
-
-    log.Printf("Object already exists, generating new name for: %s", objectPath)
-
-    objectNameWithoutExt := strings.TrimSuffix(objectName, filepath.Ext(objectName))
-    extension := filepath.Ext(objectName)
-    newObjectName := objectNameWithoutExt
-    suffix := 1
-
-    for {
-        newObjectName = fmt.Sprintf("%s-%d%s", objectNameWithoutExt, suffix, extension)
-        newObjectPath := filepath.Join(storageRoot, bucketName, newObjectName)
-        if _, err := os.Stat(newObjectPath); os.IsNotExist(err) {
-            return newObjectPath, nil
-        }
-        suffix++
+    // Le contenu doit être sur disque avant que le renommage ne le rende visible
+    if err := file.Sync(); err != nil {
+        return dto.ObjectInfo{}, fmt.Errorf("Failed to sync file: %v", err)
     }
-}
-
-// Fonction qui gère l'écriture du flux dans le fichier
-func writeObjectToFile(data io.Reader, file *os.File, contentSha256 string) error {
-    if contentSha256 == "STREAMING-AWS4-HMAC-SHA256-PAYLOAD" {
-        log.Println("Processing as chunked stream")
-        if err := ProcessChunkedStream(data, file); err != nil {
-            log.Printf("Failed to write chunked data: %v", err)
-            return fmt.Errorf("Failed to write chunked data: %v", err)
-        }
-    } else {
-        log.Println("Processing as regular stream")
-        if _, err := io.Copy(file, data); err != nil {
-            log.Printf("Failed to write data: %v", err)
-            return fmt.Errorf("Failed to write data: %v", err)
-        }
+    if err := file.Close(); err != nil {
+        return dto.ObjectInfo{}, fmt.Errorf("Failed to write file: %v", err)
     }
-    return nil
-}
 
-// Lister les objets dans un bucket
-func (fs *FileStorage) ListObjects(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
-    bucketPath := filepath.Join(storageRoot, bucketName)
-
-    objects, err := filepath.Glob(filepath.Join(bucketPath, prefix+"*"))
+    meta := objectMeta{ETag: hex.EncodeToString(hash.Sum(nil)), ObjectMetadata: metadata, SHA256: hex.EncodeToString(sum.Sum(nil))}
+    info, err := fs.commitObject(bucketName, objectName, file.Name(), meta)
     if err != nil {
-        return dto.ListObjectsResponse{}, fmt.Errorf("error while listing objects: %v", err)
-    }
-
-    response := dto.ListObjectsResponse{
-        Xmlns:       "http://s3.amazonaws.com/doc/2006-03-01/",
-        Name:        bucketName,
-        Prefix:      prefix,
-        Marker:      marker,
-        MaxKeys:     maxKeys,
-        IsTruncated: false,
-        Contents:    make([]dto.Object, 0),
+        log.Printf("Failed to store object %s in bucket %s: %v", objectName, bucketName, err)
+        return dto.ObjectInfo{}, err
     }
 
-    for i, object := range objects {
-        if i >= maxKeys {
-            response.IsTruncated = true
-            break
-        }
-
-        fileInfo, err := os.Stat(object)
-        if err != nil {
-            return dto.ListObjectsResponse{}, fmt.Errorf("error retrieving file info: %v", err)
-        }
+    log.Printf("Successfully uploaded object: %s/%s (version %q)", bucketName, objectName, info.VersionID)
+    return info, nil
+}
 
-        response.Contents = append(response.Contents, dto.Object{
-            Key:          filepath.Base(object),
-            LastModified: fileInfo.ModTime(),
-            Size:         int(fileInfo.Size()),
-        })
+// Fonction qui gère l'écriture du flux dans le fichier. Les payloads aws-chunked
+// sont décodés (et leurs signatures vérifiées) en amont, par la couche HTTP.
+func writeObjectToFile(data io.Reader, file io.Writer) error {
+    if _, err := io.Copy(file, data); err != nil {
+        log.Printf("Failed to write data: %v", err)
+        return fmt.Errorf("Failed to write data: %w", err)
     }
-
-    return response, nil
+    return nil
 }
 
 // Lister les buckets
@@ -196,19 +102,22 @@ func (fs *FileStorage) ListBuckets() []string {
     var buckets []string
 
     // Ajout de log pour vérifier si le répertoire existe
-    log.Printf("Vérification de l'existence du répertoire de stockage des buckets : %s", storageRoot)
+    log.Printf("Vérification de l'existence du répertoire de stockage des buckets : %s", fs.root())
 
-    files, err := os.ReadDir(storageRoot)
+    files, err := os.ReadDir(fs.root())
     if err != nil {
-        log.Printf("Erreur lors de la lecture du répertoire %s : %v", storageRoot, err)
+        log.Printf("Erreur lors de la lecture du répertoire %s : %v", fs.root(), err)
         return buckets
     }
 
     // Ajout de log pour voir combien de fichiers/répertoires sont trouvés
-    log.Printf("Nombre d'éléments trouvés dans le répertoire %s : %d", storageRoot, len(files))
+    log.Printf("Nombre d'éléments trouvés dans le répertoire %s : %d", fs.root(), len(files))
 
     // Parcourir chaque élément trouvé
     for _, file := range files {
+        if file.Name() == systemDirName {
+            continue
+        }
         if file.IsDir() {
             // Ajout de log pour chaque répertoire trouvé
             log.Printf("Bucket trouvé : %s", file.Name())
@@ -225,56 +134,95 @@ func (fs *FileStorage) ListBuckets() []string {
     return buckets
 }
 
-// Créer un bucket
+// Créer un bucket, en enregistrant sa date de création
 func (fs *FileStorage) CreateBucket(bucketName string) error {
-    bucketPath := filepath.Join(storageRoot, bucketName)
+    if !validNewBucketName(bucketName) {
+        return ErrInvalidBucketName
+    }
+    bucketPath := fs.bucketDir(bucketName)
     if err := os.MkdirAll(bucketPath, os.ModePerm); err != nil {
         return err
     }
-    return nil
+    return fs.initBucketConfig(bucketName)
 }
 
-// Récupération d'un objet dans un bucket
-func (fs *FileStorage) GetObject(bucketName, objectName string) ([]byte, dto.FileInfo, error) {
-	objectPath := filepath.Join(storageRoot, bucketName, objectName)
-	log.Printf("Tentative de récupération de l'objet : %s", objectPath)
+// Récupération d'un objet dans un bucket. Le fichier est ouvert et non chargé en mémoire,
+// ce qui permet de le lire en streaming et de se positionner pour les requêtes Range.
+// Si versionID est vide, la version courante est renvoyée.
+func (fs *FileStorage) GetObject(bucketName, objectName, versionID string) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+	if err := checkObjectName(bucketName, objectName); err != nil {
+		return nil, dto.ObjectInfo{}, err
+	}
+	objectPath := fs.objectFile(bucketName, objectName)
+	log.Printf("Tentative de récupération de l'objet : %s (version %q)", objectPath, versionID)
 
-	// Lire le fichier
-	data, err := os.ReadFile(objectPath)
+	if versionID != "" {
+		return fs.openVersion(bucketName, objectName, versionID)
+	}
+
+	// Ouvrir le fichier
+	file, err := os.Open(objectPath)
 	if err != nil {
-		log.Printf("Erreur lors de la lecture de l'objet: %v", err)
-		return nil, nil, err
+		log.Printf("Erreur lors de l'ouverture de l'objet: %v", err)
+		return nil, dto.ObjectInfo{}, err
 	}
 
 	// Récupérer les métadonnées du fichier
-	FileInfo, err := os.Stat(objectPath)
+	fileInfo, err := file.Stat()
 	if err != nil {
+		file.Close()
 		log.Printf("Erreur lors de la récupération des métadonnées du fichier: %v", err)
-		return nil, nil, err
+		return nil, dto.ObjectInfo{}, err
+	}
+	if fileInfo.IsDir() {
+		file.Close()
+		return nil, dto.ObjectInfo{}, os.ErrNotExist
 	}
 
-	// Retourner le contenu du fichier et les métadonnées encapsulées dans fileInfoWrapper
-	return data, &dto.FileInfoWrapper{FileInfo: FileInfo}, nil
+	info, err := fs.statObject(bucketName, objectName, fileInfo)
+	if err != nil {
+		file.Close()
+		return nil, dto.ObjectInfo{}, err
+	}
+
+	return file, info, nil
 }
 
-// Vérification de l'existence d'un objet dans un bucket
-func (fs *FileStorage) CheckObjectExist(bucketName, objectName string) (bool, time.Time, int64, error) {
-    objectPath := filepath.Join(storageRoot, bucketName, objectName)
+// Récupération des métadonnées d'un objet (ou d'une de ses versions), sans l'ouvrir
+func (fs *FileStorage) StatObject(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+    if err := checkObjectName(bucketName, objectName); err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    if versionID != "" {
+        file, info, err := fs.openVersion(bucketName, objectName, versionID)
+        if err == nil {
+            file.Close()
+        }
+        return info, err
+    }
+
+    objectPath := fs.objectFile(bucketName, objectName)
 
     fileInfo, err := os.Stat(objectPath)
-    if os.IsNotExist(err) {
-        return false, time.Time{}, 0, nil
-    } else if err != nil {
-        log.Printf("Error checking object: %v", err)
-        return false, time.Time{}, 0, fmt.Errorf("error checking object existence: %v", err)
+    if err != nil {
+        if !os.IsNotExist(err) {
+            log.Printf("Error checking object: %v", err)
+        }
+        return dto.ObjectInfo{}, err
+    }
+    if fileInfo.IsDir() {
+        return dto.ObjectInfo{}, os.ErrNotExist
     }
 
-    return true, fileInfo.ModTime(), fileInfo.Size(), nil
+    return fs.statObject(bucketName, objectName, fileInfo)
 }
 
 // Vérification de l'existence d'un bucket
 func (fs *FileStorage) CheckBucketExists(bucketName string) (bool, error) {
-    bucketPath := filepath.Join(storageRoot, bucketName)
+    if !validBucketName(bucketName) {
+        return false, ErrInvalidBucketName
+    }
+    bucketPath := fs.bucketDir(bucketName)
     if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
         return false, nil
     } else if err != nil {
@@ -285,7 +233,10 @@ func (fs *FileStorage) CheckBucketExists(bucketName string) (bool, error) {
 
 // Suppression d'un bucket
 func (fs *FileStorage) DeleteBucket(bucketName string) error {
-    bucketPath := filepath.Join(storageRoot, bucketName)
+    if !validBucketName(bucketName) {
+        return ErrInvalidBucketName
+    }
+    bucketPath := fs.bucketDir(bucketName)
 
     if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
         log.Printf("Bucket %s does not exist", bucketName)
@@ -297,55 +248,113 @@ func (fs *FileStorage) DeleteBucket(bucketName string) error {
         log.Printf("Failed to delete bucket %s: %v", bucketName, err)
         return err
     }
+    for _, path := range []string{fs.bucketMetaDir(bucketName), fs.bucketVersionsDir(bucketName), fs.bucketConfigPath(bucketName)} {
+        if err := os.RemoveAll(path); err != nil {
+            log.Printf("Failed to delete metadata of bucket %s: %v", bucketName, err)
+        }
+    }
+    fs.mu.Lock()
+    fs.collectBlobs()
+    fs.mu.Unlock()
 
     log.Printf("Bucket %s successfully deleted", bucketName)
     return nil
 }
 
-// Suppression d'un objet dans un bucket
-func (fs *FileStorage) DeleteObject(bucketName, objectName string) error {
-    objectPath := filepath.Join(storageRoot, bucketName, objectName)
+// Suppression d'un objet dans un bucket. Avec versionID, la version indiquée est supprimée
+// définitivement ; sinon, dans un bucket versionné, un marqueur de suppression est ajouté.
+func (fs *FileStorage) DeleteObject(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error) {
+    if err := checkObjectName(bucketName, objectName); err != nil {
+        return dto.DeleteObjectResult{}, err
+    }
+
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+
+    return fs.deleteObject(bucketName, objectName, versionID)
+}
+
+// deleteObject est DeleteObject pour un appelant qui détient déjà fs.mu
+func (fs *FileStorage) deleteObject(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error) {
+    if versionID != "" {
+        return fs.deleteVersion(bucketName, objectName, versionID)
+    }
+
+    config, err := fs.loadBucketConfig(bucketName)
+    if err != nil {
+        return dto.DeleteObjectResult{}, err
+    }
+    if config.Versioning != "" {
+        return fs.deleteVersioned(bucketName, objectName, config.Versioning)
+    }
+
+    objectPath := fs.objectFile(bucketName, objectName)
 
     if _, err := os.Stat(objectPath); os.IsNotExist(err) {
         log.Printf("Object %s does not exist in bucket %s", objectName, bucketName)
-        return fmt.Errorf("object not found: %w", err) // Retourne une erreur "object not found" encapsulant l'erreur 404
+        return dto.DeleteObjectResult{}, fmt.Errorf("object not found: %w", err) // Retourne une erreur "object not found" encapsulant l'erreur 404
     }
 
-    err := os.Remove(objectPath)
+    blob := fs.currentBlob(bucketName, objectName)
+    err = os.Remove(objectPath)
     if err != nil {
         log.Printf("Failed to delete object %s in bucket %s: %v", objectName, bucketName, err)
-        return err
+        return dto.DeleteObjectResult{}, err
     }
+    fs.releaseBlob(blob)
+    fs.removeObjectMeta(bucketName, objectName)
+    removeEmptyParents(fs.bucketDir(bucketName), objectPath)
 
     log.Printf("Object %s in bucket %s successfully deleted", objectName, bucketName)
-    return nil
+    return dto.DeleteObjectResult{}, nil
 }
 
-func (fs *FileStorage) CopyObject(sourceBucket, sourceKey, targetBucket, targetKey string) error {
-	sourcePath := filepath.Join(storageRoot, sourceBucket, sourceKey)
-	targetPath := filepath.Join(storageRoot, targetBucket, targetKey)
-
-	// Assurez-vous que le répertoire cible existe
-	if err := os.MkdirAll(filepath.Dir(targetPath), os.ModePerm); err != nil {
-		return fmt.Errorf("impossible de créer le répertoire cible : %v", err)
+// Copie d'un objet (de la version sourceVersionID, ou de la version courante si elle est vide).
+// Si metadata est nil, les métadonnées de la source sont conservées (x-amz-metadata-directive: COPY),
+// sinon elles sont remplacées (REPLACE).
+func (fs *FileStorage) CopyObject(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	if err := checkObjectName(targetBucket, targetKey); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := fs.requireBucket(targetBucket); err != nil {
+		return dto.ObjectInfo{}, err
 	}
 
-	// Copier le fichier
-	input, err := os.Open(sourcePath)
+	// Copier le fichier dans un fichier temporaire : la copie d'un objet sur lui-même
+	// (pour remplacer ses métadonnées) ne doit pas le tronquer
+	input, info, err := fs.GetObject(sourceBucket, sourceKey, sourceVersionID)
 	if err != nil {
-		return fmt.Errorf("impossible d'ouvrir le fichier source : %v", err)
+		return dto.ObjectInfo{}, fmt.Errorf("impossible d'ouvrir l'objet source : %w", err)
 	}
 	defer input.Close()
 
-	output, err := os.Create(targetPath)
+	output, err := fs.createTempFile()
 	if err != nil {
-		return fmt.Errorf("impossible de créer le fichier cible : %v", err)
+		return dto.ObjectInfo{}, fmt.Errorf("impossible de créer le fichier cible : %v", err)
 	}
-	defer output.Close()
+	defer os.Remove(output.Name())
 
-	if _, err := io.Copy(output, input); err != nil {
-		return fmt.Errorf("erreur lors de la copie : %v", err)
+	sum := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(output, sum), input); err != nil {
+		output.Close()
+		return dto.ObjectInfo{}, fmt.Errorf("erreur lors de la copie : %v", err)
+	}
+	if err := output.Sync(); err != nil {
+		output.Close()
+		return dto.ObjectInfo{}, fmt.Errorf("erreur lors de la copie : %v", err)
+	}
+	if err := output.Close(); err != nil {
+		return dto.ObjectInfo{}, fmt.Errorf("erreur lors de la copie : %v", err)
 	}
 
-	return nil
+	// La copie a le même contenu, donc le même ETag que la source
+	meta := objectMeta{ETag: info.ETag, ObjectMetadata: info.ObjectMetadata, SHA256: hex.EncodeToString(sum.Sum(nil))}
+	// Le verrouillage et l'ACL protègent la version source : la copie n'a que ceux demandés avec les métadonnées
+	meta.Lock, meta.ACL = nil, ""
+	if metadata != nil {
+		// Le chiffrement décrit le contenu copié tel quel : il n'est pas remplacé avec les métadonnées
+		meta.ObjectMetadata = *metadata
+		meta.Encryption = info.Encryption
+	}
+	return fs.commitObject(targetBucket, targetKey, output.Name(), meta)
 }
@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// ObjectLockStorage applique l'Object Lock, quel que soit le backend : une version retenue
+// (jusqu'à sa date de fin de rétention) ou en suspens légal ne peut être ni supprimée, ni
+// remplacée, ni voir son verrouillage affaibli. Seules les opérations qui détruiraient une
+// version sont refusées : dans un bucket versionné, écrire ou supprimer sans versionId archive la
+// version courante, qui reste protégée. Le mode GOVERNANCE peut être contourné avec la vue
+// renvoyée par WithGovernanceBypass ; le mode COMPLIANCE ne le peut pas.
+//
+// Les vérifications ne portent que sur les buckets dont l'Object Lock est activé.
+type ObjectLockStorage struct {
+	Storage
+	// Vrai si la requête en cours peut contourner la rétention GOVERNANCE
+	bypassGovernance bool
+}
+
+// NewObjectLockStorage ajoute l'application de l'Object Lock au backend donné
+func NewObjectLockStorage(backend Storage) *ObjectLockStorage {
+	return &ObjectLockStorage{Storage: backend}
+}
+
+// WithGovernanceBypass renvoie une vue de s qui peut supprimer les versions retenues en mode
+// GOVERNANCE, ou raccourcir leur rétention (x-amz-bypass-governance-retention)
+func WithGovernanceBypass(s Storage) Storage {
+	switch s := s.(type) {
+	case *ObjectLockStorage:
+		view := *s
+		view.bypassGovernance = true
+		return &view
+	case *EncryptedStorage:
+		view := *s
+		view.Storage = WithGovernanceBypass(s.Storage)
+		return &view
+	}
+	return s
+}
+
+// CheckRemovable renvoie ErrObjectLocked si supprimer ou remplacer l'objet détruirait une version
+// verrouillée. Elle permet de refuser une opération composée (un déplacement) avant de la commencer.
+func CheckRemovable(s Storage, bucketName, objectName string) error {
+	switch layer := s.(type) {
+	case *ObjectLockStorage:
+		config, err := layer.lockConfig(bucketName)
+		if err != nil {
+			return err
+		}
+		return layer.checkRemovable(config, bucketName, objectName, "")
+	case *EncryptedStorage:
+		return CheckRemovable(layer.Storage, bucketName, objectName)
+	}
+	return nil
+}
+
+// lockConfig renvoie la configuration Object Lock du bucket, ou nil si elle n'est pas activée.
+// Un bucket inexistant est laissé au backend, qui renvoie l'erreur attendue pour l'opération.
+func (ls *ObjectLockStorage) lockConfig(bucketName string) (*dto.ObjectLockConfiguration, error) {
+	config, err := ls.Storage.GetObjectLockConfiguration(bucketName)
+	if errors.Is(err, ErrNoSuchObjectLockConfiguration) || errors.Is(err, ErrNoSuchBucket) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// checkLock renvoie ErrObjectLocked si le verrouillage protège la version
+func (ls *ObjectLockStorage) checkLock(lock *dto.ObjectLock) error {
+	switch {
+	case lock == nil:
+		return nil
+	case lock.LegalHold:
+		return ErrObjectLocked
+	case !lock.Retained(time.Now()):
+		return nil
+	case lock.Mode == dto.RetentionGovernance && ls.bypassGovernance:
+		return nil
+	}
+	return ErrObjectLocked
+}
+
+// checkRemovable vérifie que l'opération ne détruit pas de version protégée : la version versionID,
+// ou, si versionID est vide, celle qu'une écriture ou une suppression ferait disparaître
+func (ls *ObjectLockStorage) checkRemovable(config *dto.ObjectLockConfiguration, bucketName, objectName, versionID string) error {
+	if config == nil {
+		return nil
+	}
+	if versionID == "" {
+		status, err := ls.Storage.GetBucketVersioning(bucketName)
+		if err != nil {
+			return err
+		}
+		switch status {
+		case VersioningEnabled:
+			// La version courante est archivée, pas détruite
+			return nil
+		case VersioningSuspended:
+			// Seule la version "null", courante ou archivée, est remplacée
+			versionID = nullVersionID
+		}
+	}
+
+	info, err := ls.Storage.StatObject(bucketName, objectName, versionID)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrNoSuchVersion) || errors.Is(err, ErrDeleteMarker) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := ls.checkLock(info.Lock); err != nil {
+		log.Printf("Refusing to remove locked object %s/%s (version %q)", bucketName, objectName, info.VersionID)
+		return err
+	}
+	return nil
+}
+
+// newObjectLock renvoie le verrouillage d'une nouvelle version : celui demandé, ou la rétention
+// par défaut du bucket si aucune rétention n'est demandée
+func newObjectLock(config *dto.ObjectLockConfiguration, lock *dto.ObjectLock) (*dto.ObjectLock, error) {
+	if config == nil {
+		if lock != nil {
+			return nil, ErrObjectLockNotEnabled
+		}
+		return nil, nil
+	}
+	if config.Rule == nil || (lock != nil && lock.Mode != "") {
+		return lock, nil
+	}
+
+	retention := config.Rule.DefaultRetention
+	applied := dto.ObjectLock{
+		Mode:            retention.Mode,
+		RetainUntilDate: time.Now().UTC().AddDate(retention.Years, 0, retention.Days),
+	}
+	if lock != nil {
+		applied.LegalHold = lock.LegalHold
+	}
+	return &applied, nil
+}
+
+// sameLock compare deux verrouillages, nil équivalant à l'absence de verrouillage
+func sameLock(a, b *dto.ObjectLock) bool {
+	var x, y dto.ObjectLock
+	if a != nil {
+		x = *a
+	}
+	if b != nil {
+		y = *b
+	}
+	return x.Mode == y.Mode && x.RetainUntilDate.Equal(y.RetainUntilDate) && x.LegalHold == y.LegalHold
+}
+
+// checkLockChange vérifie qu'une modification du verrouillage d'une version n'affaiblit pas une
+// rétention en cours : elle ne peut être ni supprimée, ni raccourcie, ni passer de COMPLIANCE à
+// GOVERNANCE, sauf contournement d'une rétention GOVERNANCE. La suspension légale est libre.
+func (ls *ObjectLockStorage) checkLockChange(config *dto.ObjectLockConfiguration, before, after *dto.ObjectLock) error {
+	if sameLock(before, after) {
+		return nil
+	}
+	if config == nil {
+		return ErrObjectLockNotEnabled
+	}
+	if !before.Retained(time.Now()) {
+		return nil
+	}
+
+	weakened := after == nil || after.Mode == "" ||
+		after.RetainUntilDate.Before(before.RetainUntilDate) ||
+		(before.Mode == dto.RetentionCompliance && after.Mode != dto.RetentionCompliance)
+	if weakened && !(before.Mode == dto.RetentionGovernance && ls.bypassGovernance) {
+		return ErrObjectLocked
+	}
+	return nil
+}
+
+func (ls *ObjectLockStorage) AddObject(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	config, err := ls.lockConfig(bucketName)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if metadata.Lock, err = newObjectLock(config, metadata.Lock); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := ls.checkRemovable(config, bucketName, objectName, ""); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return ls.Storage.AddObject(bucketName, objectName, data, metadata)
+}
+
+func (ls *ObjectLockStorage) DeleteObject(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error) {
+	config, err := ls.lockConfig(bucketName)
+	if err != nil {
+		return dto.DeleteObjectResult{}, err
+	}
+	if err := ls.checkRemovable(config, bucketName, objectName, versionID); err != nil {
+		return dto.DeleteObjectResult{VersionID: versionID}, err
+	}
+	return ls.Storage.DeleteObject(bucketName, objectName, versionID)
+}
+
+// DeleteBucket supprime le bucket et tout son contenu : il est refusé si une version est verrouillée
+func (ls *ObjectLockStorage) DeleteBucket(bucketName string) error {
+	config, err := ls.lockConfig(bucketName)
+	if err != nil {
+		return err
+	}
+
+	keyMarker, versionIDMarker := "", ""
+	for config != nil {
+		result, err := ls.Storage.ListObjectVersions(bucketName, "", keyMarker, versionIDMarker, MaxListKeys)
+		if err != nil {
+			return err
+		}
+		for _, version := range result.Versions {
+			if err := ls.checkRemovable(config, bucketName, version.Key, version.VersionId); err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated {
+			break
+		}
+		keyMarker, versionIDMarker = result.NextKeyMarker, result.NextVersionIdMarker
+	}
+	return ls.Storage.DeleteBucket(bucketName)
+}
+
+// CopyObject vérifie que la cible peut être remplacée. La copie ne reprend pas le verrouillage
+// de la source : elle a celui demandé avec les métadonnées, ou la rétention par défaut du bucket cible.
+func (ls *ObjectLockStorage) CopyObject(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	config, err := ls.lockConfig(targetBucket)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := ls.checkRemovable(config, targetBucket, targetKey, ""); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if metadata, err = ls.targetMetadata(config, sourceBucket, sourceKey, sourceVersionID, metadata); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return ls.Storage.CopyObject(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey, metadata)
+}
+
+// MoveObject refuse de déplacer un objet verrouillé ou de remplacer une cible verrouillée. Comme pour
+// CopyObject, la cible a le verrouillage demandé avec les métadonnées, ou la rétention par défaut de son bucket.
+func (ls *ObjectLockStorage) MoveObject(sourceBucket, sourceKey, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	if err := CheckRemovable(ls, sourceBucket, sourceKey); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	config, err := ls.lockConfig(targetBucket)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := ls.checkRemovable(config, targetBucket, targetKey, ""); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if metadata, err = ls.targetMetadata(config, sourceBucket, sourceKey, "", metadata); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return ls.Storage.MoveObject(sourceBucket, sourceKey, targetBucket, targetKey, metadata)
+}
+
+// targetMetadata renvoie les métadonnées de la cible d'une copie ou d'un déplacement, avec le
+// verrouillage demandé ou la rétention par défaut du bucket cible (config). nil conserve les
+// métadonnées de la source, sans verrouillage.
+func (ls *ObjectLockStorage) targetMetadata(config *dto.ObjectLockConfiguration, sourceBucket, sourceKey, sourceVersionID string, metadata *dto.ObjectMetadata) (*dto.ObjectMetadata, error) {
+	if metadata == nil && config != nil && config.Rule != nil {
+		// La rétention par défaut s'ajoute aux métadonnées de la source
+		source, err := ls.Storage.StatObject(sourceBucket, sourceKey, sourceVersionID)
+		if err != nil {
+			return nil, err
+		}
+		replacement := source.ObjectMetadata
+		replacement.Lock, replacement.ACL = nil, ""
+		metadata = &replacement
+	}
+	if metadata != nil {
+		replacement := *metadata
+		var err error
+		if replacement.Lock, err = newObjectLock(config, metadata.Lock); err != nil {
+			return nil, err
+		}
+		metadata = &replacement
+	}
+	return metadata, nil
+}
+
+// UpdateObjectMetadata refuse les modifications qui affaibliraient le verrouillage de la version
+func (ls *ObjectLockStorage) UpdateObjectMetadata(bucketName, objectName, versionID string, update func(*dto.ObjectMetadata) error) error {
+	config, err := ls.lockConfig(bucketName)
+	if err != nil {
+		return err
+	}
+	return ls.Storage.UpdateObjectMetadata(bucketName, objectName, versionID, func(metadata *dto.ObjectMetadata) error {
+		var before *dto.ObjectLock
+		if metadata.Lock != nil {
+			previous := *metadata.Lock
+			before = &previous
+		}
+		if err := update(metadata); err != nil {
+			return err
+		}
+		return ls.checkLockChange(config, before, metadata.Lock)
+	})
+}
+
+func (ls *ObjectLockStorage) CreateMultipartUpload(bucketName, objectName string, metadata dto.ObjectMetadata) (string, error) {
+	config, err := ls.lockConfig(bucketName)
+	if err != nil {
+		return "", err
+	}
+	if metadata.Lock, err = newObjectLock(config, metadata.Lock); err != nil {
+		return "", err
+	}
+	return ls.Storage.CreateMultipartUpload(bucketName, objectName, metadata)
+}
+
+func (ls *ObjectLockStorage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+	config, err := ls.lockConfig(bucketName)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := ls.checkRemovable(config, bucketName, objectName, ""); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return ls.Storage.CompleteMultipartUpload(bucketName, objectName, uploadID, parts)
+}
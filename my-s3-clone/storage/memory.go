@@ -0,0 +1,928 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// MemoryStorage implémente l'interface Storage entièrement en mémoire, pour les tests et les
+// environnements de développement éphémères. Son comportement (versioning, multipart, listing,
+// erreurs) est celui de FileStorage ; les données sont perdues à l'arrêt du serveur.
+type MemoryStorage struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	uploads map[string]*memoryUpload
+}
+
+type memoryBucket struct {
+	info       dto.BucketInfo
+	versioning string
+	lifecycle  *dto.LifecycleConfiguration
+	objectLock *dto.ObjectLockConfiguration
+	cors       *dto.CORSConfiguration
+	policy     *dto.BucketPolicy
+	encryption *dto.ServerSideEncryptionConfiguration
+	// Configuration des notifications, nil si aucune n'est configurée
+	notification *dto.NotificationConfiguration
+	// Versions de chaque clé, la plus récente en premier. La première est la version courante,
+	// sauf si c'est un marqueur de suppression.
+	objects map[string][]*memoryVersion
+}
+
+type memoryVersion struct {
+	meta objectMeta
+	data []byte
+}
+
+type memoryUpload struct {
+	upload multipartUpload
+	parts  map[int]memoryPart
+}
+
+type memoryPart struct {
+	info partInfo
+	data []byte
+}
+
+// memoryObject permet de lire un contenu en mémoire comme un fichier
+type memoryObject struct {
+	*bytes.Reader
+}
+
+func (memoryObject) Close() error {
+	return nil
+}
+
+// NewMemoryStorage crée un stockage en mémoire vide
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		buckets: make(map[string]*memoryBucket),
+		uploads: make(map[string]*memoryUpload),
+	}
+}
+
+// notFound construit une erreur reconnue par os.IsNotExist, comme celles de FileStorage
+func notFound(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+// bucket renvoie le bucket demandé, ou ErrNoSuchBucket. Doit être appelée sous ms.mu.
+func (ms *MemoryStorage) bucket(bucketName string) (*memoryBucket, error) {
+	if !validBucketName(bucketName) {
+		return nil, ErrInvalidBucketName
+	}
+	bucket, ok := ms.buckets[bucketName]
+	if !ok {
+		return nil, ErrNoSuchBucket
+	}
+	return bucket, nil
+}
+
+// findVersion renvoie la version versionID d'un objet, ou ErrNoSuchVersion
+func findVersion(versions []*memoryVersion, versionID string) (int, *memoryVersion, error) {
+	for i, version := range versions {
+		if currentVersionID(version.meta) == versionID {
+			return i, version, nil
+		}
+	}
+	return -1, nil, ErrNoSuchVersion
+}
+
+// withoutNullVersion retire la version "null" d'un objet, qu'elle soit courante ou non
+func withoutNullVersion(versions []*memoryVersion) []*memoryVersion {
+	kept := versions[:0:0]
+	for _, version := range versions {
+		if currentVersionID(version.meta) != nullVersionID {
+			kept = append(kept, version)
+		}
+	}
+	return kept
+}
+
+func (ms *MemoryStorage) AddObject(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	if err := checkObjectName(bucketName, objectName); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return dto.ObjectInfo{}, fmt.Errorf("Failed to write data: %w", err)
+	}
+	sum := md5.Sum(content)
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return ms.commit(bucket, objectName, content, objectMeta{ETag: hex.EncodeToString(sum[:]), ObjectMetadata: metadata})
+}
+
+// commit ajoute une nouvelle version courante à un objet, selon l'état du versioning du bucket.
+// Doit être appelée sous ms.mu.
+func (ms *MemoryStorage) commit(bucket *memoryBucket, objectName string, data []byte, meta objectMeta) (dto.ObjectInfo, error) {
+	versions := bucket.objects[objectName]
+
+	switch bucket.versioning {
+	case VersioningEnabled:
+		versionID, err := newVersionID()
+		if err != nil {
+			return dto.ObjectInfo{}, err
+		}
+		meta.VersionID = versionID
+	case VersioningSuspended:
+		// La nouvelle version remplace la version "null" existante, courante ou archivée
+		meta.VersionID = nullVersionID
+		versions = withoutNullVersion(versions)
+	default:
+		meta.VersionID = ""
+		versions = nil
+	}
+
+	meta.Size = int64(len(data))
+	meta.LastModified = time.Now()
+	bucket.objects[objectName] = append([]*memoryVersion{{meta: meta, data: data}}, versions...)
+	return metaObjectInfo(objectName, meta), nil
+}
+
+// open renvoie une version d'un objet (la version courante si versionID est vide)
+func (ms *MemoryStorage) open(bucketName, objectName, versionID string) (*memoryVersion, dto.ObjectInfo, error) {
+	if err := checkObjectName(bucketName, objectName); err != nil {
+		return nil, dto.ObjectInfo{}, err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var versions []*memoryVersion
+	if bucket, ok := ms.buckets[bucketName]; ok {
+		versions = bucket.objects[objectName]
+	}
+
+	if versionID == "" {
+		if len(versions) == 0 || versions[0].meta.DeleteMarker {
+			return nil, dto.ObjectInfo{}, notFound("open", bucketName+"/"+objectName)
+		}
+		return versions[0], metaObjectInfo(objectName, versions[0].meta), nil
+	}
+
+	_, version, err := findVersion(versions, versionID)
+	if err != nil {
+		return nil, dto.ObjectInfo{}, err
+	}
+	info := metaObjectInfo(objectName, version.meta)
+	info.VersionID = versionID
+	if version.meta.DeleteMarker {
+		return nil, info, ErrDeleteMarker
+	}
+	return version, info, nil
+}
+
+func (ms *MemoryStorage) GetObject(bucketName, objectName, versionID string) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+	version, info, err := ms.open(bucketName, objectName, versionID)
+	if err != nil {
+		return nil, info, err
+	}
+	return memoryObject{bytes.NewReader(version.data)}, info, nil
+}
+
+func (ms *MemoryStorage) StatObject(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+	_, info, err := ms.open(bucketName, objectName, versionID)
+	return info, err
+}
+
+func (ms *MemoryStorage) DeleteObject(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error) {
+	if err := checkObjectName(bucketName, objectName); err != nil {
+		return dto.DeleteObjectResult{}, err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.deleteObject(bucketName, objectName, versionID)
+}
+
+// deleteObject est DeleteObject pour un appelant qui détient déjà ms.mu
+func (ms *MemoryStorage) deleteObject(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error) {
+	bucket, ok := ms.buckets[bucketName]
+	if !ok {
+		if versionID != "" {
+			return dto.DeleteObjectResult{VersionID: versionID}, ErrNoSuchVersion
+		}
+		return dto.DeleteObjectResult{}, notFound("remove", bucketName+"/"+objectName)
+	}
+	versions := bucket.objects[objectName]
+
+	// Suppression définitive d'une version ou d'un marqueur de suppression
+	if versionID != "" {
+		result := dto.DeleteObjectResult{VersionID: versionID}
+		i, version, err := findVersion(versions, versionID)
+		if err != nil {
+			return result, err
+		}
+		result.DeleteMarker = version.meta.DeleteMarker
+		ms.setVersions(bucket, objectName, append(versions[:i:i], versions[i+1:]...))
+		return result, nil
+	}
+
+	result := dto.DeleteObjectResult{DeleteMarker: true, VersionID: nullVersionID}
+	switch bucket.versioning {
+	case VersioningEnabled:
+		newID, err := newVersionID()
+		if err != nil {
+			return result, err
+		}
+		result.VersionID = newID
+	case VersioningSuspended:
+		versions = withoutNullVersion(versions)
+	default:
+		if len(versions) == 0 {
+			return dto.DeleteObjectResult{}, fmt.Errorf("object not found: %w", notFound("remove", bucketName+"/"+objectName))
+		}
+		delete(bucket.objects, objectName)
+		return dto.DeleteObjectResult{}, nil
+	}
+
+	marker := &memoryVersion{meta: objectMeta{VersionID: result.VersionID, LastModified: time.Now(), DeleteMarker: true}}
+	bucket.objects[objectName] = append([]*memoryVersion{marker}, versions...)
+	return result, nil
+}
+
+func (ms *MemoryStorage) UpdateObjectMetadata(bucketName, objectName, versionID string, update func(*dto.ObjectMetadata) error) error {
+	if err := checkObjectName(bucketName, objectName); err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	versions := bucket.objects[objectName]
+
+	var version *memoryVersion
+	if versionID == "" {
+		if len(versions) == 0 || versions[0].meta.DeleteMarker {
+			return notFound("update", bucketName+"/"+objectName)
+		}
+		version = versions[0]
+	} else if _, version, err = findVersion(versions, versionID); err != nil {
+		return err
+	} else if version.meta.DeleteMarker {
+		return ErrDeleteMarker
+	}
+
+	// Les métadonnées sont remplacées d'un bloc : une erreur de update les laisse intactes
+	meta := version.meta
+	if err := update(&meta.ObjectMetadata); err != nil {
+		return err
+	}
+	version.meta = meta
+	return nil
+}
+
+// setVersions remplace les versions d'un objet, et oublie la clé s'il n'en reste aucune
+func (ms *MemoryStorage) setVersions(bucket *memoryBucket, objectName string, versions []*memoryVersion) {
+	if len(versions) == 0 {
+		delete(bucket.objects, objectName)
+		return
+	}
+	bucket.objects[objectName] = versions
+}
+
+func (ms *MemoryStorage) CopyObject(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	if err := checkObjectName(targetBucket, targetKey); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if exists, err := ms.CheckBucketExists(targetBucket); err != nil {
+		return dto.ObjectInfo{}, err
+	} else if !exists {
+		return dto.ObjectInfo{}, ErrNoSuchBucket
+	}
+
+	source, info, err := ms.open(sourceBucket, sourceKey, sourceVersionID)
+	if err != nil {
+		return dto.ObjectInfo{}, fmt.Errorf("impossible d'ouvrir l'objet source : %w", err)
+	}
+
+	meta := objectMeta{ETag: info.ETag, ObjectMetadata: info.ObjectMetadata}
+	// Le verrouillage et l'ACL protègent la version source : la copie n'a que ceux demandés avec les métadonnées
+	meta.Lock, meta.ACL = nil, ""
+	if metadata != nil {
+		// Le chiffrement décrit le contenu copié tel quel : il n'est pas remplacé avec les métadonnées
+		meta.ObjectMetadata = *metadata
+		meta.Encryption = info.Encryption
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(targetBucket)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	// Les contenus ne sont jamais modifiés : la copie peut partager celui de la source
+	return ms.commit(bucket, targetKey, source.data, meta)
+}
+
+// MoveObject déplace la version courante d'un objet en une seule opération sous ms.mu : la cible
+// partage le contenu de la source, qui est ensuite supprimée
+func (ms *MemoryStorage) MoveObject(sourceBucket, sourceKey, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	if err := checkObjectName(sourceBucket, sourceKey); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := checkObjectName(targetBucket, targetKey); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if sourceBucket == targetBucket && sourceKey == targetKey {
+		return dto.ObjectInfo{}, ErrMoveOntoItself
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	target, err := ms.bucket(targetBucket)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	var versions []*memoryVersion
+	if bucket, ok := ms.buckets[sourceBucket]; ok {
+		versions = bucket.objects[sourceKey]
+	}
+	if len(versions) == 0 || versions[0].meta.DeleteMarker {
+		return dto.ObjectInfo{}, fmt.Errorf("impossible d'ouvrir l'objet source : %w", notFound("open", sourceBucket+"/"+sourceKey))
+	}
+	source := versions[0]
+
+	meta := objectMeta{ETag: source.meta.ETag, ObjectMetadata: source.meta.ObjectMetadata}
+	// Le verrouillage et l'ACL protègent la version source : la cible n'a que ceux demandés avec les métadonnées
+	meta.Lock, meta.ACL = nil, ""
+	if metadata != nil {
+		// Le chiffrement décrit le contenu déplacé tel quel : il n'est pas remplacé avec les métadonnées
+		meta.ObjectMetadata = *metadata
+		meta.Encryption = source.meta.Encryption
+	}
+
+	info, err := ms.commit(target, targetKey, source.data, meta)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if _, err := ms.deleteObject(sourceBucket, sourceKey, ""); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return info, nil
+}
+
+func (ms *MemoryStorage) CheckBucketExists(bucketName string) (bool, error) {
+	if !validBucketName(bucketName) {
+		return false, ErrInvalidBucketName
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	_, ok := ms.buckets[bucketName]
+	return ok, nil
+}
+
+func (ms *MemoryStorage) ListBuckets() []string {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var buckets []string
+	for name := range ms.buckets {
+		buckets = append(buckets, name)
+	}
+	sort.Strings(buckets)
+	return buckets
+}
+
+func (ms *MemoryStorage) CreateBucket(bucketName string) error {
+	if !validNewBucketName(bucketName) {
+		return ErrInvalidBucketName
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.buckets[bucketName]; !ok {
+		ms.buckets[bucketName] = &memoryBucket{
+			info:    dto.BucketInfo{Name: bucketName, CreationDate: time.Now().UTC()},
+			objects: make(map[string][]*memoryVersion),
+		}
+	}
+	return nil
+}
+
+func (ms *MemoryStorage) GetBucketInfo(bucketName string) (dto.BucketInfo, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return dto.BucketInfo{}, err
+	}
+	return bucket.info, nil
+}
+
+func (ms *MemoryStorage) PutBucketInfo(bucketName string, info dto.BucketInfo) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	bucket.info.Owner, bucket.info.Region, bucket.info.ACL = info.Owner, info.Region, info.ACL
+	return nil
+}
+
+func (ms *MemoryStorage) DeleteBucket(bucketName string) error {
+	if !validBucketName(bucketName) {
+		return ErrInvalidBucketName
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.buckets[bucketName]; !ok {
+		return notFound("remove", bucketName)
+	}
+	delete(ms.buckets, bucketName)
+	for uploadID, upload := range ms.uploads {
+		if upload.upload.Bucket == bucketName {
+			delete(ms.uploads, uploadID)
+		}
+	}
+	log.Printf("Bucket %s successfully deleted", bucketName)
+	return nil
+}
+
+func (ms *MemoryStorage) ListObjects(bucketName, prefix, delimiter, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
+	response := dto.ListObjectsResponse{
+		Xmlns:     dto.S3Namespace,
+		Name:      bucketName,
+		Prefix:    prefix,
+		Marker:    marker,
+		MaxKeys:   maxKeys,
+		Delimiter: delimiter,
+		Contents:  make([]dto.Object, 0),
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return response, err
+	}
+
+	var keys []string
+	for key, versions := range bucket.objects {
+		if strings.HasPrefix(key, prefix) && !versions[0].meta.DeleteMarker {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	err = paginateObjects(&response, keys, func(key string) (dto.ObjectInfo, error) {
+		return metaObjectInfo(key, bucket.objects[key][0].meta), nil
+	})
+	return response, err
+}
+
+func (ms *MemoryStorage) GetBucketVersioning(bucketName string) (string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return "", err
+	}
+	return bucket.versioning, nil
+}
+
+func (ms *MemoryStorage) PutBucketVersioning(bucketName, status string) error {
+	if status != VersioningEnabled && status != VersioningSuspended {
+		return ErrInvalidVersioning
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	bucket.versioning = status
+	return nil
+}
+
+func (ms *MemoryStorage) ListObjectVersions(bucketName, prefix, keyMarker, versionIDMarker string, maxKeys int) (dto.ListVersionsResult, error) {
+	result := dto.ListVersionsResult{
+		Xmlns:           dto.S3Namespace,
+		Name:            bucketName,
+		Prefix:          prefix,
+		KeyMarker:       keyMarker,
+		VersionIdMarker: versionIDMarker,
+		MaxKeys:         maxKeys,
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return result, err
+	}
+
+	keys := make([]string, 0, len(bucket.objects))
+	for key := range bucket.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	err = paginateVersions(&result, keys, func(key string) ([]objectMeta, error) {
+		var versions []objectMeta
+		for _, version := range bucket.objects[key] {
+			meta := version.meta
+			meta.VersionID = currentVersionID(meta)
+			versions = append(versions, meta)
+		}
+		return versions, nil
+	})
+	return result, err
+}
+
+func (ms *MemoryStorage) GetBucketLifecycle(bucketName string) (dto.LifecycleConfiguration, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return dto.LifecycleConfiguration{}, err
+	}
+	if bucket.lifecycle == nil {
+		return dto.LifecycleConfiguration{}, ErrNoSuchLifecycle
+	}
+	return *bucket.lifecycle, nil
+}
+
+func (ms *MemoryStorage) PutBucketLifecycle(bucketName string, lifecycle dto.LifecycleConfiguration) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	bucket.lifecycle = &lifecycle
+	return nil
+}
+
+func (ms *MemoryStorage) DeleteBucketLifecycle(bucketName string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	bucket.lifecycle = nil
+	return nil
+}
+
+func (ms *MemoryStorage) GetBucketCors(bucketName string) (dto.CORSConfiguration, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return dto.CORSConfiguration{}, err
+	}
+	if bucket.cors == nil {
+		return dto.CORSConfiguration{}, ErrNoSuchCORS
+	}
+	return *bucket.cors, nil
+}
+
+func (ms *MemoryStorage) PutBucketCors(bucketName string, config dto.CORSConfiguration) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	bucket.cors = &config
+	return nil
+}
+
+func (ms *MemoryStorage) DeleteBucketCors(bucketName string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	bucket.cors = nil
+	return nil
+}
+
+func (ms *MemoryStorage) GetBucketEncryption(bucketName string) (dto.ServerSideEncryptionConfiguration, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return dto.ServerSideEncryptionConfiguration{}, err
+	}
+	if bucket.encryption == nil {
+		return dto.ServerSideEncryptionConfiguration{}, ErrNoSuchEncryptionConfiguration
+	}
+	return *bucket.encryption, nil
+}
+
+func (ms *MemoryStorage) PutBucketEncryption(bucketName string, config dto.ServerSideEncryptionConfiguration) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	bucket.encryption = &config
+	return nil
+}
+
+func (ms *MemoryStorage) DeleteBucketEncryption(bucketName string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	bucket.encryption = nil
+	return nil
+}
+
+func (ms *MemoryStorage) GetBucketPolicy(bucketName string) (dto.BucketPolicy, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return dto.BucketPolicy{}, err
+	}
+	if bucket.policy == nil {
+		return dto.BucketPolicy{}, ErrNoSuchBucketPolicy
+	}
+	return *bucket.policy, nil
+}
+
+func (ms *MemoryStorage) PutBucketPolicy(bucketName string, policy dto.BucketPolicy) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	bucket.policy = &policy
+	return nil
+}
+
+func (ms *MemoryStorage) DeleteBucketPolicy(bucketName string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	bucket.policy = nil
+	return nil
+}
+
+func (ms *MemoryStorage) GetBucketNotification(bucketName string) (dto.NotificationConfiguration, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil || bucket.notification == nil {
+		return dto.NotificationConfiguration{}, err
+	}
+	return *bucket.notification, nil
+}
+
+func (ms *MemoryStorage) PutBucketNotification(bucketName string, config dto.NotificationConfiguration) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	bucket.notification = nil
+	if len(config.Webhooks) > 0 {
+		bucket.notification = &config
+	}
+	return nil
+}
+
+func (ms *MemoryStorage) GetObjectLockConfiguration(bucketName string) (dto.ObjectLockConfiguration, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return dto.ObjectLockConfiguration{}, err
+	}
+	if bucket.objectLock == nil {
+		return dto.ObjectLockConfiguration{}, ErrNoSuchObjectLockConfiguration
+	}
+	return *bucket.objectLock, nil
+}
+
+func (ms *MemoryStorage) PutObjectLockConfiguration(bucketName string, config dto.ObjectLockConfiguration) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	bucket.objectLock = &config
+	return nil
+}
+
+// upload renvoie un upload en cours, s'il concerne bien l'objet demandé. Doit être appelée sous ms.mu.
+func (ms *MemoryStorage) upload(bucketName, objectName, uploadID string) (*memoryUpload, error) {
+	upload, ok := ms.uploads[uploadID]
+	if !ok || upload.upload.Bucket != bucketName || upload.upload.Key != objectName {
+		return nil, ErrNoSuchUpload
+	}
+	return upload, nil
+}
+
+func (ms *MemoryStorage) CreateMultipartUpload(bucketName, objectName string, metadata dto.ObjectMetadata) (string, error) {
+	if err := checkObjectName(bucketName, objectName); err != nil {
+		return "", err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, err := ms.bucket(bucketName); err != nil {
+		return "", err
+	}
+	uploadID, err := newUploadID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate upload id: %v", err)
+	}
+
+	ms.uploads[uploadID] = &memoryUpload{
+		upload: multipartUpload{
+			Bucket:    bucketName,
+			Key:       objectName,
+			UploadID:  uploadID,
+			Metadata:  metadata,
+			Initiated: time.Now().UTC(),
+		},
+		parts: make(map[int]memoryPart),
+	}
+	return uploadID, nil
+}
+
+func (ms *MemoryStorage) UploadPart(bucketName, objectName, uploadID string, partNumber int, data io.Reader) (string, error) {
+	if partNumber < 1 || partNumber > maxPartNumber {
+		return "", ErrInvalidPartNumber
+	}
+
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return "", fmt.Errorf("Failed to write data: %w", err)
+	}
+	sum := md5.Sum(content)
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	upload, err := ms.upload(bucketName, objectName, uploadID)
+	if err != nil {
+		return "", err
+	}
+	part := partInfo{
+		PartNumber:   partNumber,
+		ETag:         hex.EncodeToString(sum[:]),
+		Size:         int64(len(content)),
+		LastModified: time.Now().UTC(),
+	}
+	upload.parts[partNumber] = memoryPart{info: part, data: content}
+	return part.ETag, nil
+}
+
+func (ms *MemoryStorage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	upload, err := ms.upload(bucketName, objectName, uploadID)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	infos, err := checkCompletedParts(parts, func(partNumber int) (partInfo, error) {
+		part, ok := upload.parts[partNumber]
+		if !ok {
+			return partInfo{}, ErrInvalidPart
+		}
+		return part.info, nil
+	})
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	bucket, err := ms.bucket(bucketName)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	var data bytes.Buffer
+	for _, info := range infos {
+		data.Write(upload.parts[info.PartNumber].data)
+	}
+	info, err := ms.commit(bucket, objectName, data.Bytes(), objectMeta{ETag: multipartETag(infos), ObjectMetadata: upload.upload.Metadata})
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	delete(ms.uploads, uploadID)
+	return info, nil
+}
+
+func (ms *MemoryStorage) AbortMultipartUpload(bucketName, objectName, uploadID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, err := ms.upload(bucketName, objectName, uploadID); err != nil {
+		return err
+	}
+	delete(ms.uploads, uploadID)
+	return nil
+}
+
+func (ms *MemoryStorage) MultipartUploadMetadata(bucketName, objectName, uploadID string) (dto.ObjectMetadata, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	upload, err := ms.upload(bucketName, objectName, uploadID)
+	if err != nil {
+		return dto.ObjectMetadata{}, err
+	}
+	return upload.upload.Metadata, nil
+}
+
+func (ms *MemoryStorage) ListParts(bucketName, objectName, uploadID string, partNumberMarker, maxParts int) (dto.ListPartsResult, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	upload, err := ms.upload(bucketName, objectName, uploadID)
+	if err != nil {
+		return dto.ListPartsResult{}, err
+	}
+
+	var infos []partInfo
+	for _, part := range upload.parts {
+		if part.info.PartNumber > partNumberMarker {
+			infos = append(infos, part.info)
+		}
+	}
+	return paginateParts(bucketName, objectName, uploadID, infos, partNumberMarker, maxParts), nil
+}
+
+func (ms *MemoryStorage) ListMultipartUploads(bucketName, prefix, keyMarker, uploadIDMarker string, maxUploads int) (dto.ListMultipartUploadsResult, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, err := ms.bucket(bucketName); err != nil {
+		return dto.ListMultipartUploadsResult{}, err
+	}
+
+	var uploads []multipartUpload
+	for _, upload := range ms.uploads {
+		if upload.upload.Bucket == bucketName && strings.HasPrefix(upload.upload.Key, prefix) {
+			uploads = append(uploads, upload.upload)
+		}
+	}
+	return paginateUploads(bucketName, uploads, prefix, keyMarker, uploadIDMarker, maxUploads), nil
+}
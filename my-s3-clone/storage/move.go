@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"my-s3-clone/dto"
+)
+
+// Déplacement d'objets sur disque. Le contenu n'est jamais recopié : le fichier source est lié
+// (lien physique) dans le répertoire temporaire, le lien est mis en place comme nouvelle version de
+// la cible, puis la source est supprimée. Avant de commencer, le déplacement est décrit dans un
+// journal, sous .s3clone/journal ; le journal n'est supprimé qu'une fois la source supprimée. Au
+// démarrage du backend, les déplacements interrompus sont terminés si le lien a été renommé en
+// cible, et annulés sinon : un arrêt brutal ne laisse jamais l'objet aux deux endroits, ni nulle part.
+
+// moveJournal décrit un déplacement en cours
+type moveJournal struct {
+	SourceBucket string `json:"sourceBucket"`
+	SourceKey    string `json:"sourceKey"`
+	TargetBucket string `json:"targetBucket"`
+	TargetKey    string `json:"targetKey"`
+	// Fichier temporaire qui porte le contenu de la source, renommé en cible : tant qu'il existe,
+	// la cible n'a pas été mise en place
+	Staged string `json:"staged"`
+	// Identité du fichier source (voir fileIdentity) : la source n'est supprimée que si elle n'a
+	// pas été remplacée depuis le début du déplacement
+	SourceFile string `json:"sourceFile"`
+	// Métadonnées de la cible ; leur ETag est celui de la source
+	Meta objectMeta `json:"meta"`
+}
+
+// MoveObject déplace la version courante d'un objet. Si metadata est nil, la cible garde les
+// métadonnées de la source, sans son verrouillage ni son ACL.
+func (fs *FileStorage) MoveObject(sourceBucket, sourceKey, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	if err := checkObjectName(sourceBucket, sourceKey); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := checkObjectName(targetBucket, targetKey); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if sourceBucket == targetBucket && sourceKey == targetKey {
+		return dto.ObjectInfo{}, ErrMoveOntoItself
+	}
+	if err := fs.requireBucket(targetBucket); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	sourcePath := fs.objectFile(sourceBucket, sourceKey)
+	sourceFile, err := fileIdentity(sourcePath)
+	if err != nil {
+		return dto.ObjectInfo{}, fmt.Errorf("impossible d'ouvrir l'objet source : %w", err)
+	}
+	sourceMeta, err := fs.loadObjectMeta(sourceBucket, sourceKey)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	meta := objectMeta{ETag: sourceMeta.ETag, ObjectMetadata: sourceMeta.ObjectMetadata, SHA256: sourceMeta.SHA256}
+	// Le verrouillage et l'ACL protègent la version source : la cible n'a que ceux demandés avec les métadonnées
+	meta.Lock, meta.ACL = nil, ""
+	if metadata != nil {
+		// Le chiffrement décrit le contenu déplacé tel quel : il n'est pas remplacé avec les métadonnées
+		meta.ObjectMetadata = *metadata
+		meta.Encryption = sourceMeta.Encryption
+	}
+
+	staged, err := fs.stageFile(sourcePath)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	journal := moveJournal{
+		SourceBucket: sourceBucket,
+		SourceKey:    sourceKey,
+		TargetBucket: targetBucket,
+		TargetKey:    targetKey,
+		Staged:       staged,
+		SourceFile:   sourceFile,
+		Meta:         meta,
+	}
+	journalPath, err := fs.writeMoveJournal(journal)
+	if err != nil {
+		os.Remove(staged)
+		return dto.ObjectInfo{}, err
+	}
+
+	info, err := fs.commit(targetBucket, targetKey, staged, meta)
+	if err != nil {
+		// La cible a pu être mise en place avant l'erreur : le journal décide de la suite
+		fs.completeMove(journalPath, journal)
+		return dto.ObjectInfo{}, err
+	}
+	if _, err := fs.deleteObject(sourceBucket, sourceKey, ""); err != nil {
+		// Le journal est conservé : la suppression sera reprise au prochain démarrage
+		return info, fmt.Errorf("object moved but its source could not be removed: %w", err)
+	}
+	if err := os.Remove(journalPath); err != nil {
+		log.Printf("Failed to remove move journal %s: %v", journalPath, err)
+	}
+	return info, nil
+}
+
+// stageFile crée dans le répertoire temporaire un lien physique vers path, ou une copie si le
+// système de fichiers ne permet pas les liens. Doit être appelée sous fs.mu.
+func (fs *FileStorage) stageFile(path string) (string, error) {
+	tmp, err := fs.createTempFile()
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	if err := os.Remove(tmp.Name()); err != nil {
+		return "", err
+	}
+	if err := os.Link(path, tmp.Name()); err == nil {
+		return tmp.Name(), nil
+	}
+
+	if err := copyFile(path, tmp.Name()); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// copyFile copie le contenu de src dans un nouveau fichier dst, synchronisé sur disque
+func copyFile(src, dst string) error {
+	input, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(output, input); err != nil {
+		output.Close()
+		return err
+	}
+	if err := output.Sync(); err != nil {
+		output.Close()
+		return err
+	}
+	return output.Close()
+}
+
+// writeMoveJournal enregistre un déplacement avant qu'il ne commence et renvoie le chemin du journal
+func (fs *FileStorage) writeMoveJournal(journal moveJournal) (string, error) {
+	path, err := fs.writeJournal("move-", journal)
+	if err != nil {
+		return "", fmt.Errorf("failed to write move journal: %v", err)
+	}
+	return path, nil
+}
+
+// completeMove termine ou annule un déplacement interrompu, puis supprime son journal. Le fichier
+// temporaire disparaît quand il est renommé en cible : s'il existe encore, le déplacement est
+// annulé ; sinon il est terminé, et la source est supprimée si elle est toujours le fichier
+// déplacé. Une cible identique écrite par ailleurs ne termine donc jamais un déplacement.
+// Doit être appelée sous fs.mu.
+func (fs *FileStorage) completeMove(path string, journal moveJournal) error {
+	if _, err := os.Stat(journal.Staged); err == nil {
+		if err := os.Remove(journal.Staged); err != nil {
+			return err
+		}
+		// La mise en place de la cible, si elle a été journalisée, est annulée avec le déplacement
+		fs.dropCommitJournal(journal.Staged)
+		log.Printf("Rolled back interrupted move of %s/%s to %s/%s", journal.SourceBucket, journal.SourceKey, journal.TargetBucket, journal.TargetKey)
+		return os.Remove(path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if identity, err := fileIdentity(fs.objectFile(journal.SourceBucket, journal.SourceKey)); err == nil && identity == journal.SourceFile {
+		if _, err := fs.deleteObject(journal.SourceBucket, journal.SourceKey, ""); err != nil {
+			return err
+		}
+	}
+	log.Printf("Completed interrupted move of %s/%s to %s/%s", journal.SourceBucket, journal.SourceKey, journal.TargetBucket, journal.TargetKey)
+	return os.Remove(path)
+}
+
+// recoverMove reprend un déplacement interrompu par un arrêt du serveur (voir recoverJournal)
+func (fs *FileStorage) recoverMove(path string) error {
+	var journal moveJournal
+	if err := readJSONFile(path, &journal); err != nil {
+		return err
+	}
+	return fs.completeMove(path, journal)
+}
@@ -0,0 +1,483 @@
+package storage
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+const (
+	// Taille minimale d'une partie, sauf pour la dernière (identique à S3)
+	minPartSize = 5 << 20
+	// Numéro de partie maximal accepté par S3
+	maxPartNumber = 10000
+)
+
+// multipartUpload est le descripteur d'un upload multipart, stocké dans upload.json
+type multipartUpload struct {
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	UploadID  string    `json:"uploadId"`
+	Initiated time.Time `json:"initiated"`
+	// Métadonnées fournies au démarrage de l'upload, appliquées à l'objet final
+	Metadata dto.ObjectMetadata `json:"metadata"`
+}
+
+// partInfo décrit une partie téléversée, stockée à côté de ses données
+type partInfo struct {
+	PartNumber   int       `json:"partNumber"`
+	ETag         string    `json:"etag"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// Répertoire contenant tous les uploads multipart en cours
+func (fs *FileStorage) multipartRoot() string {
+	return filepath.Join(fs.root(), systemDirName, "multipart")
+}
+
+func (fs *FileStorage) uploadDir(uploadID string) string {
+	return filepath.Join(fs.multipartRoot(), uploadID)
+}
+
+func (fs *FileStorage) partDataPath(uploadID string, partNumber int) string {
+	return filepath.Join(fs.uploadDir(uploadID), fmt.Sprintf("part.%05d", partNumber))
+}
+
+func (fs *FileStorage) partInfoPath(uploadID string, partNumber int) string {
+	return fs.partDataPath(uploadID, partNumber) + ".json"
+}
+
+// newUploadID génère un identifiant d'upload aléatoire
+func newUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validUploadID vérifie le format de l'identifiant, qui est utilisé comme nom de répertoire
+func validUploadID(uploadID string) bool {
+	if len(uploadID) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(uploadID)
+	return err == nil
+}
+
+// loadUpload charge le descripteur d'un upload et vérifie qu'il concerne bien l'objet demandé
+func (fs *FileStorage) loadUpload(bucketName, objectName, uploadID string) (multipartUpload, error) {
+	var upload multipartUpload
+	if !validUploadID(uploadID) {
+		return upload, ErrNoSuchUpload
+	}
+
+	if err := readJSONFile(filepath.Join(fs.uploadDir(uploadID), "upload.json"), &upload); err != nil {
+		if os.IsNotExist(err) {
+			return upload, ErrNoSuchUpload
+		}
+		return upload, err
+	}
+
+	if upload.Bucket != bucketName || upload.Key != objectName {
+		return upload, ErrNoSuchUpload
+	}
+	return upload, nil
+}
+
+// normalizeETag retire les guillemets d'un ETag pour pouvoir le comparer
+func normalizeETag(etag string) string {
+	return strings.Trim(strings.TrimSpace(etag), `"`)
+}
+
+// Démarrage d'un upload multipart
+func (fs *FileStorage) CreateMultipartUpload(bucketName, objectName string, metadata dto.ObjectMetadata) (string, error) {
+	if err := checkObjectName(bucketName, objectName); err != nil {
+		return "", err
+	}
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", ErrNoSuchBucket
+	}
+
+	uploadID, err := newUploadID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate upload id: %v", err)
+	}
+
+	upload := multipartUpload{
+		Bucket:    bucketName,
+		Key:       objectName,
+		UploadID:  uploadID,
+		Metadata:  metadata,
+		Initiated: time.Now().UTC(),
+	}
+	if err := writeJSONFile(filepath.Join(fs.uploadDir(uploadID), "upload.json"), upload); err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %v", err)
+	}
+
+	log.Printf("Multipart upload %s created for %s/%s", uploadID, bucketName, objectName)
+	return uploadID, nil
+}
+
+// Téléversement d'une partie. Une partie déjà envoyée avec le même numéro est remplacée,
+// ce qui permet aux clients de reprendre un upload interrompu.
+func (fs *FileStorage) UploadPart(bucketName, objectName, uploadID string, partNumber int, data io.Reader) (string, error) {
+	if partNumber < 1 || partNumber > maxPartNumber {
+		return "", ErrInvalidPartNumber
+	}
+
+	if _, err := fs.loadUpload(bucketName, objectName, uploadID); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(fs.uploadDir(uploadID), ".part-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create part file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	counter := &countingWriter{}
+	if err := writeObjectToFile(data, io.MultiWriter(tmp, hash, counter)); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to sync part: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write part: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), fs.partDataPath(uploadID, partNumber)); err != nil {
+		return "", fmt.Errorf("failed to store part: %v", err)
+	}
+
+	part := partInfo{
+		PartNumber:   partNumber,
+		ETag:         hex.EncodeToString(hash.Sum(nil)),
+		Size:         counter.n,
+		LastModified: time.Now().UTC(),
+	}
+	if err := writeJSONFile(fs.partInfoPath(uploadID, partNumber), part); err != nil {
+		return "", fmt.Errorf("failed to store part info: %v", err)
+	}
+
+	log.Printf("Part %d of upload %s stored (%d bytes)", partNumber, uploadID, part.Size)
+	return part.ETag, nil
+}
+
+// Assemblage des parties en un objet final. L'objet est construit dans un fichier temporaire
+// puis renommé, il n'est donc jamais visible à moitié écrit.
+func (fs *FileStorage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+	upload, err := fs.loadUpload(bucketName, objectName, uploadID)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	infos, err := checkCompletedParts(parts, func(partNumber int) (partInfo, error) {
+		var info partInfo
+		err := readJSONFile(fs.partInfoPath(uploadID, partNumber), &info)
+		if os.IsNotExist(err) {
+			return info, ErrInvalidPart
+		}
+		return info, err
+	})
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	if err := fs.requireBucket(bucketName); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	tmp, err := os.CreateTemp(fs.uploadDir(uploadID), ".complete-*")
+	if err != nil {
+		return dto.ObjectInfo{}, fmt.Errorf("failed to create object file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	for _, info := range infos {
+		if err := appendPart(tmp, fs.partDataPath(uploadID, info.PartNumber)); err != nil {
+			tmp.Close()
+			return dto.ObjectInfo{}, err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return dto.ObjectInfo{}, fmt.Errorf("failed to sync object file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return dto.ObjectInfo{}, fmt.Errorf("failed to write object file: %v", err)
+	}
+
+	info, err := fs.commitObject(bucketName, objectName, tmp.Name(), objectMeta{ETag: multipartETag(infos), ObjectMetadata: upload.Metadata})
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	if err := os.RemoveAll(fs.uploadDir(uploadID)); err != nil {
+		log.Printf("Failed to clean up multipart upload %s: %v", uploadID, err)
+	}
+
+	log.Printf("Multipart upload %s completed into %s/%s", uploadID, bucketName, objectName)
+	return info, nil
+}
+
+// checkCompletedParts vérifie la liste des parties envoyée par le client : numéros croissants,
+// parties existantes dont l'ETag correspond, taille minimale sauf pour la dernière.
+// lookup renvoie ErrInvalidPart pour une partie inconnue.
+func checkCompletedParts(parts []dto.CompletedPart, lookup func(partNumber int) (partInfo, error)) ([]partInfo, error) {
+	if len(parts) == 0 {
+		return nil, ErrInvalidPart
+	}
+
+	infos := make([]partInfo, 0, len(parts))
+	for i, part := range parts {
+		if i > 0 && part.PartNumber <= parts[i-1].PartNumber {
+			return nil, ErrInvalidPartOrder
+		}
+		if part.PartNumber < 1 || part.PartNumber > maxPartNumber {
+			return nil, ErrInvalidPart
+		}
+
+		info, err := lookup(part.PartNumber)
+		if err != nil {
+			return nil, err
+		}
+		if normalizeETag(part.ETag) != info.ETag {
+			return nil, ErrInvalidPart
+		}
+		if info.Size < minPartSize && i < len(parts)-1 {
+			return nil, ErrEntityTooSmall
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// multipartETag calcule l'ETag d'un objet multipart : le MD5 de la concaténation des MD5
+// des parties, suivi du nombre de parties
+func multipartETag(infos []partInfo) string {
+	hash := md5.New()
+	for _, info := range infos {
+		sum, _ := hex.DecodeString(info.ETag)
+		hash.Write(sum)
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(hash.Sum(nil)), len(infos))
+}
+
+// appendPart recopie le contenu d'une partie à la fin du fichier final
+func appendPart(dst io.Writer, partPath string) error {
+	src, err := os.Open(partPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrInvalidPart
+		}
+		return err
+	}
+	defer src.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("failed to assemble part %s: %v", partPath, err)
+	}
+	return nil
+}
+
+// Abandon d'un upload multipart et suppression des parties déjà reçues
+func (fs *FileStorage) AbortMultipartUpload(bucketName, objectName, uploadID string) error {
+	if _, err := fs.loadUpload(bucketName, objectName, uploadID); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(fs.uploadDir(uploadID)); err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %v", err)
+	}
+
+	log.Printf("Multipart upload %s aborted", uploadID)
+	return nil
+}
+
+// Métadonnées d'un upload en cours, appliquées à l'objet final
+func (fs *FileStorage) MultipartUploadMetadata(bucketName, objectName, uploadID string) (dto.ObjectMetadata, error) {
+	upload, err := fs.loadUpload(bucketName, objectName, uploadID)
+	if err != nil {
+		return dto.ObjectMetadata{}, err
+	}
+	return upload.Metadata, nil
+}
+
+// Liste des parties déjà reçues pour un upload, triées par numéro
+func (fs *FileStorage) ListParts(bucketName, objectName, uploadID string, partNumberMarker, maxParts int) (dto.ListPartsResult, error) {
+	if _, err := fs.loadUpload(bucketName, objectName, uploadID); err != nil {
+		return dto.ListPartsResult{}, err
+	}
+
+	entries, err := os.ReadDir(fs.uploadDir(uploadID))
+	if err != nil {
+		return dto.ListPartsResult{}, fmt.Errorf("failed to list parts: %v", err)
+	}
+
+	var infos []partInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "part.") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		var info partInfo
+		if err := readJSONFile(filepath.Join(fs.uploadDir(uploadID), name), &info); err != nil {
+			return dto.ListPartsResult{}, err
+		}
+		if info.PartNumber > partNumberMarker {
+			infos = append(infos, info)
+		}
+	}
+	return paginateParts(bucketName, objectName, uploadID, infos, partNumberMarker, maxParts), nil
+}
+
+// paginateParts construit une page de la liste des parties d'un upload ; infos ne contient
+// que les parties situées après partNumberMarker
+func paginateParts(bucketName, objectName, uploadID string, infos []partInfo, partNumberMarker, maxParts int) dto.ListPartsResult {
+	sort.Slice(infos, func(i, j int) bool { return infos[i].PartNumber < infos[j].PartNumber })
+
+	result := dto.ListPartsResult{
+		Xmlns:            dto.S3Namespace,
+		Bucket:           bucketName,
+		Key:              objectName,
+		UploadId:         uploadID,
+		StorageClass:     "STANDARD",
+		PartNumberMarker: partNumberMarker,
+		MaxParts:         maxParts,
+		Parts:            make([]dto.Part, 0),
+	}
+
+	for _, info := range infos {
+		if len(result.Parts) >= maxParts {
+			result.IsTruncated = true
+			break
+		}
+		result.Parts = append(result.Parts, dto.Part{
+			PartNumber:   info.PartNumber,
+			LastModified: info.LastModified,
+			ETag:         `"` + info.ETag + `"`,
+			Size:         info.Size,
+		})
+		result.NextPartNumberMarker = info.PartNumber
+	}
+
+	return result
+}
+
+// Liste des uploads multipart en cours dans un bucket, triés par clé puis par date de création
+func (fs *FileStorage) ListMultipartUploads(bucketName, prefix, keyMarker, uploadIDMarker string, maxUploads int) (dto.ListMultipartUploadsResult, error) {
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return dto.ListMultipartUploadsResult{}, err
+	}
+	if !exists {
+		return dto.ListMultipartUploadsResult{}, ErrNoSuchBucket
+	}
+
+	entries, err := os.ReadDir(fs.multipartRoot())
+	if err != nil && !os.IsNotExist(err) {
+		return dto.ListMultipartUploadsResult{}, fmt.Errorf("failed to list multipart uploads: %v", err)
+	}
+
+	var uploads []multipartUpload
+	for _, entry := range entries {
+		if !entry.IsDir() || !validUploadID(entry.Name()) {
+			continue
+		}
+		var upload multipartUpload
+		if err := readJSONFile(filepath.Join(fs.uploadDir(entry.Name()), "upload.json"), &upload); err != nil {
+			// Upload en cours de suppression ou descripteur illisible : on l'ignore
+			continue
+		}
+		if upload.Bucket != bucketName || !strings.HasPrefix(upload.Key, prefix) {
+			continue
+		}
+		uploads = append(uploads, upload)
+	}
+	return paginateUploads(bucketName, uploads, prefix, keyMarker, uploadIDMarker, maxUploads), nil
+}
+
+// paginateUploads construit une page de la liste des uploads en cours, triés par clé puis par date
+// de création ; uploads ne contient que les uploads du bucket dont la clé commence par prefix
+func paginateUploads(bucketName string, uploads []multipartUpload, prefix, keyMarker, uploadIDMarker string, maxUploads int) dto.ListMultipartUploadsResult {
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].Key != uploads[j].Key {
+			return uploads[i].Key < uploads[j].Key
+		}
+		if !uploads[i].Initiated.Equal(uploads[j].Initiated) {
+			return uploads[i].Initiated.Before(uploads[j].Initiated)
+		}
+		return uploads[i].UploadID < uploads[j].UploadID
+	})
+
+	result := dto.ListMultipartUploadsResult{
+		Xmlns:          dto.S3Namespace,
+		Bucket:         bucketName,
+		KeyMarker:      keyMarker,
+		UploadIdMarker: uploadIDMarker,
+		Prefix:         prefix,
+		MaxUploads:     maxUploads,
+		Uploads:        make([]dto.Upload, 0),
+	}
+
+	// Sans upload-id-marker, le key-marker exclut toutes les clés inférieures ou égales.
+	// Avec, il exclut seulement les uploads de la même clé jusqu'à celui indiqué inclus.
+	skipping := uploadIDMarker != ""
+	for _, upload := range uploads {
+		if keyMarker != "" {
+			if upload.Key < keyMarker || (upload.Key == keyMarker && uploadIDMarker == "") {
+				continue
+			}
+			if upload.Key == keyMarker && skipping {
+				if upload.UploadID == uploadIDMarker {
+					skipping = false
+				}
+				continue
+			}
+		}
+
+		if len(result.Uploads) >= maxUploads {
+			result.IsTruncated = true
+			break
+		}
+		result.Uploads = append(result.Uploads, dto.Upload{
+			Key:          upload.Key,
+			UploadId:     upload.UploadID,
+			Initiated:    upload.Initiated,
+			StorageClass: "STANDARD",
+		})
+		result.NextKeyMarker = upload.Key
+		result.NextUploadIdMarker = upload.UploadID
+	}
+
+	return result
+}
+
+// countingWriter compte les octets écrits, pour connaître la taille réelle d'un flux chunked
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"log"
+
+	"my-s3-clone/dto"
+)
+
+// La configuration des notifications est conservée dans la configuration du bucket ; les
+// événements sont publiés par le package notify, au-dessus des backends.
+
+// Lecture de la configuration des notifications d'un bucket ; elle est vide si aucune n'est configurée
+func (fs *FileStorage) GetBucketNotification(bucketName string) (dto.NotificationConfiguration, error) {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return dto.NotificationConfiguration{}, err
+	}
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil || config.Notification == nil {
+		return dto.NotificationConfiguration{}, err
+	}
+	return *config.Notification, nil
+}
+
+// Remplacement de la configuration des notifications d'un bucket (elle est validée par la couche
+// HTTP) ; une configuration sans webhook désactive les notifications
+func (fs *FileStorage) PutBucketNotification(bucketName string, notification dto.NotificationConfiguration) error {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return err
+	}
+	config.Notification = nil
+	if len(notification.Webhooks) > 0 {
+		config.Notification = &notification
+	}
+	log.Printf("Notifications of bucket %s set to %d webhook(s)", bucketName, len(notification.Webhooks))
+	return writeJSONFile(fs.bucketConfigPath(bucketName), config)
+}
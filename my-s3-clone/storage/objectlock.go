@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"log"
+
+	"my-s3-clone/dto"
+)
+
+// La configuration Object Lock est conservée dans la configuration du bucket ; le verrouillage
+// des objets est enregistré dans leurs métadonnées et appliqué par ObjectLockStorage.
+
+// Lecture de la configuration Object Lock d'un bucket
+func (fs *FileStorage) GetObjectLockConfiguration(bucketName string) (dto.ObjectLockConfiguration, error) {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return dto.ObjectLockConfiguration{}, err
+	}
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return dto.ObjectLockConfiguration{}, err
+	}
+	if config.ObjectLock == nil {
+		return dto.ObjectLockConfiguration{}, ErrNoSuchObjectLockConfiguration
+	}
+	return *config.ObjectLock, nil
+}
+
+// Remplacement de la configuration Object Lock d'un bucket (elle est validée par la couche HTTP)
+func (fs *FileStorage) PutObjectLockConfiguration(bucketName string, lock dto.ObjectLockConfiguration) error {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return err
+	}
+	config.ObjectLock = &lock
+	log.Printf("Object lock enabled on bucket %s", bucketName)
+	return writeJSONFile(fs.bucketConfigPath(bucketName), config)
+}
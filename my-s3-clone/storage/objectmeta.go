@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// objectMeta est le fichier annexe (sidecar) conservé pour chaque objet, sous
+// <racine>/.s3clone/meta/<bucket>/<clé>.json
+type objectMeta struct {
+	ETag string `json:"etag"`
+	// Identifiant de version ; vide si l'objet a été écrit alors que le versioning n'était pas activé
+	VersionID string `json:"versionId,omitempty"`
+	dto.ObjectMetadata
+	// Empreinte SHA-256 du contenu stocké, enregistrée à l'écriture : elle nomme le blob du backend
+	// adressé par contenu (voir blobs.go) et permet au scrubber de détecter la corruption (voir scrub.go)
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Renseignés uniquement pour les versions archivées (voir versioning.go)
+	Size         int64     `json:"size,omitempty"`
+	LastModified time.Time `json:"lastModified,omitempty"`
+	DeleteMarker bool      `json:"deleteMarker,omitempty"`
+}
+
+func (fs *FileStorage) metaPath(bucketName, objectName string) string {
+	return filepath.Join(fs.root(), systemDirName, "meta", bucketName, filepath.FromSlash(objectName)+".json")
+}
+
+func (fs *FileStorage) bucketMetaDir(bucketName string) string {
+	return filepath.Join(fs.root(), systemDirName, "meta", bucketName)
+}
+
+func (fs *FileStorage) writeObjectMeta(bucketName, objectName string, meta objectMeta) error {
+	return writeJSONFile(fs.metaPath(bucketName, objectName), meta)
+}
+
+// loadObjectMeta lit le sidecar d'un objet. Les objets écrits avant l'introduction des
+// sidecars n'en ont pas : leur ETag est alors calculé une fois puis enregistré.
+func (fs *FileStorage) loadObjectMeta(bucketName, objectName string) (objectMeta, error) {
+	var meta objectMeta
+	err := readJSONFile(fs.metaPath(bucketName, objectName), &meta)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return meta, err
+	}
+
+	etag, err := fileMD5(fs.objectFile(bucketName, objectName))
+	if err != nil {
+		return meta, err
+	}
+	meta.ETag = etag
+	if err := fs.writeObjectMeta(bucketName, objectName, meta); err != nil {
+		log.Printf("Failed to save metadata of %s/%s: %v", bucketName, objectName, err)
+	}
+	return meta, nil
+}
+
+func (fs *FileStorage) removeObjectMeta(bucketName, objectName string) {
+	path := fs.metaPath(bucketName, objectName)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove metadata of %s/%s: %v", bucketName, objectName, err)
+	}
+	removeEmptyParents(fs.bucketMetaDir(bucketName), path)
+}
+
+// Modification des métadonnées d'une version, courante ou archivée, sans toucher à son contenu
+func (fs *FileStorage) UpdateObjectMetadata(bucketName, objectName, versionID string, update func(*dto.ObjectMetadata) error) error {
+	if err := checkObjectName(bucketName, objectName); err != nil {
+		return err
+	}
+	if err := fs.requireBucket(bucketName); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fileInfo, err := os.Stat(fs.objectFile(bucketName, objectName)); err == nil && !fileInfo.IsDir() {
+		meta, err := fs.loadObjectMeta(bucketName, objectName)
+		if err != nil {
+			return err
+		}
+		if versionID == "" || currentVersionID(meta) == versionID {
+			if err := update(&meta.ObjectMetadata); err != nil {
+				return err
+			}
+			return fs.writeObjectMeta(bucketName, objectName, meta)
+		}
+	}
+	if versionID == "" {
+		return notFound("update", bucketName+"/"+objectName)
+	}
+
+	meta, err := fs.loadArchivedVersion(bucketName, objectName, versionID)
+	if err != nil {
+		return err
+	}
+	if meta.DeleteMarker {
+		return ErrDeleteMarker
+	}
+	if err := update(&meta.ObjectMetadata); err != nil {
+		return err
+	}
+	return writeJSONFile(fs.versionMetaPath(bucketName, objectName, versionID), meta)
+}
+
+// fileMD5 calcule le MD5 hexadécimal du contenu d'un fichier
+func fileMD5(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// statObject construit l'ObjectInfo d'un objet à partir du fichier et de son sidecar
+func (fs *FileStorage) statObject(bucketName, objectName string, fileInfo os.FileInfo) (dto.ObjectInfo, error) {
+	meta, err := fs.loadObjectMeta(bucketName, objectName)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return dto.ObjectInfo{
+		Key:            objectName,
+		Size:           fileInfo.Size(),
+		LastModified:   fileInfo.ModTime(),
+		ETag:           meta.ETag,
+		VersionID:      meta.VersionID,
+		ObjectMetadata: meta.ObjectMetadata,
+	}, nil
+}
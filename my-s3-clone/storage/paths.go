@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Longueur maximale d'une clé d'objet, en octets, comme S3
+const maxKeyLength = 1024
+
+// validBucketName refuse les noms qui sortiraient de la racine du stockage ou désigneraient le
+// répertoire système (les règles de nommage S3 complètes sont vérifiées à la création, voir
+// validNewBucketName : les buckets existants restent accessibles)
+func validBucketName(bucketName string) bool {
+	return bucketName != "" && !strings.HasPrefix(bucketName, ".") && !strings.ContainsAny(bucketName, "/\\\x00")
+}
+
+// Préfixes et suffixes réservés par S3 dans les noms de bucket
+var (
+	reservedBucketPrefixes = []string{"xn--", "sthree-", "amzn-s3-demo-"}
+	reservedBucketSuffixes = []string{"-s3alias", "--ol-s3", ".mrap", "--x-s3", "--table-s3"}
+)
+
+// validNewBucketName applique les règles de nommage S3 aux buckets créés : 3 à 63 caractères
+// parmi les minuscules, chiffres, points et tirets, commençant et finissant par une lettre ou un
+// chiffre, sans points consécutifs, sans forme d'adresse IP ni préfixe ou suffixe réservé
+func validNewBucketName(bucketName string) bool {
+	if len(bucketName) < 3 || len(bucketName) > 63 {
+		return false
+	}
+	for i, c := range bucketName {
+		alphanumeric := (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+		if !alphanumeric && ((c != '.' && c != '-') || i == 0 || i == len(bucketName)-1) {
+			return false
+		}
+	}
+	if strings.Contains(bucketName, "..") || net.ParseIP(bucketName).To4() != nil {
+		return false
+	}
+	for _, prefix := range reservedBucketPrefixes {
+		if strings.HasPrefix(bucketName, prefix) {
+			return false
+		}
+	}
+	for _, suffix := range reservedBucketSuffixes {
+		if strings.HasSuffix(bucketName, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// validObjectKey refuse les clés vides ou trop longues, et celles dont un segment est vide,
+// "." ou ".." : une clé désigne toujours un fichier à l'intérieur du répertoire du bucket
+func validObjectKey(objectName string) bool {
+	if objectName == "" || len(objectName) > maxKeyLength || strings.ContainsRune(objectName, 0) {
+		return false
+	}
+	for _, segment := range strings.Split(objectName, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// checkObjectName vérifie le nom du bucket et la clé avant tout accès au disque
+func checkObjectName(bucketName, objectName string) error {
+	if !validBucketName(bucketName) {
+		return ErrInvalidBucketName
+	}
+	if !validObjectKey(objectName) {
+		return ErrInvalidObjectName
+	}
+	return nil
+}
+
+// bucketDir renvoie le répertoire d'un bucket
+func (fs *FileStorage) bucketDir(bucketName string) string {
+	return filepath.Join(fs.root(), bucketName)
+}
+
+// objectFile renvoie le fichier d'un objet. Les "/" de la clé deviennent des sous-répertoires :
+// "2024/summer/img.jpg" est stocké dans <bucket>/2024/summer/img.jpg.
+func (fs *FileStorage) objectFile(bucketName, objectName string) string {
+	return filepath.Join(fs.root(), bucketName, filepath.FromSlash(objectName))
+}
+
+// makeObjectDir crée les répertoires parents d'un objet. Une clé ne peut pas être à la fois
+// un objet et un "dossier" ("a" et "a/b") : ce conflit est signalé par ErrKeyConflict.
+func makeObjectDir(path string) error {
+	if fileInfo, err := os.Stat(path); err == nil && fileInfo.IsDir() {
+		return ErrKeyConflict
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		if errors.Is(err, syscall.ENOTDIR) || errors.Is(err, syscall.EEXIST) {
+			return ErrKeyConflict
+		}
+		return err
+	}
+	return nil
+}
+
+// removeEmptyParents supprime les répertoires devenus vides entre path et root (exclu),
+// pour qu'un "dossier" disparaisse avec son dernier objet
+func removeEmptyParents(root, path string) {
+	for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
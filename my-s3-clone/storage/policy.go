@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"log"
+
+	"my-s3-clone/dto"
+)
+
+// La politique d'un bucket est conservée dans sa configuration ; elle est évaluée par les
+// handlers avec les ACL du bucket et des objets (voir le package policy).
+
+// Lecture de la politique d'un bucket
+func (fs *FileStorage) GetBucketPolicy(bucketName string) (dto.BucketPolicy, error) {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return dto.BucketPolicy{}, err
+	}
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return dto.BucketPolicy{}, err
+	}
+	if config.Policy == nil {
+		return dto.BucketPolicy{}, ErrNoSuchBucketPolicy
+	}
+	return *config.Policy, nil
+}
+
+// Remplacement de la politique d'un bucket (elle est validée par la couche HTTP)
+func (fs *FileStorage) PutBucketPolicy(bucketName string, policy dto.BucketPolicy) error {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return err
+	}
+	config.Policy = &policy
+	log.Printf("Policy of bucket %s set to %d statement(s)", bucketName, len(policy.Statement))
+	return writeJSONFile(fs.bucketConfigPath(bucketName), config)
+}
+
+// Suppression de la politique d'un bucket
+func (fs *FileStorage) DeleteBucketPolicy(bucketName string) error {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil || config.Policy == nil {
+		return err
+	}
+	config.Policy = nil
+	log.Printf("Policy of bucket %s removed", bucketName)
+	return writeJSONFile(fs.bucketConfigPath(bucketName), config)
+}
+
+// ObjectACL renvoie l'ACL d'une version d'objet sans exiger la clé SSE-C d'un objet chiffré :
+// elle est lue dans ses métadonnées, qui ne sont pas chiffrées
+func ObjectACL(s Storage, bucketName, objectName, versionID string) (string, error) {
+	if es, ok := s.(*EncryptedStorage); ok {
+		s = es.Storage
+	}
+	info, err := s.StatObject(bucketName, objectName, versionID)
+	return info.ACL, err
+}
+
+// ClaimOwnerlessBuckets attribue owner aux buckets qui n'ont pas de propriétaire, créés alors que
+// l'authentification était désactivée : sans propriétaire, personne n'a les droits du propriétaire
+func ClaimOwnerlessBuckets(s Storage, owner string) error {
+	for _, bucketName := range s.ListBuckets() {
+		info, err := s.GetBucketInfo(bucketName)
+		if err != nil {
+			return err
+		}
+		if info.Owner != "" {
+			continue
+		}
+		info.Owner = owner
+		if err := s.PutBucketInfo(bucketName, info); err != nil {
+			return err
+		}
+		log.Printf("Bucket %s without owner assigned to %s", bucketName, owner)
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// Vérification de l'intégrité des contenus sur disque (scrubbing). Chaque version d'objet a dans
+// son sidecar l'empreinte SHA-256 du contenu stocké, enregistrée à l'écriture (voir commit) ; le
+// scrubber relit les contenus, à un débit limité pour ne pas pénaliser les requêtes, et signale
+// ceux dont l'empreinte a changé, ceux qui ont disparu et ceux qui n'ont pas d'empreinte. Les
+// objets chiffrés sont vérifiés tels qu'ils sont stockés, sans clé.
+
+// Scrubber est implémenté par les backends capables de vérifier l'intégrité de leurs contenus
+type Scrubber interface {
+	// Scrub relit tous les contenus, à au plus bytesPerSecond octets par seconde (0 : sans limite),
+	// et enregistre le rapport obtenu. Si ctx est annulé, le rapport partiel est renvoyé avec l'erreur.
+	Scrub(ctx context.Context, bytesPerSecond int64) (dto.IntegrityReport, error)
+	// LastIntegrityReport renvoie le rapport du dernier passage terminé, ou ErrNoIntegrityReport
+	LastIntegrityReport() (dto.IntegrityReport, error)
+}
+
+// AsScrubber renvoie le backend de s qui sait vérifier ses contenus, à travers les couches de
+// chiffrement, de verrouillage et celles qui exposent leur backend par une méthode Unwrap
+func AsScrubber(s Storage) (Scrubber, bool) {
+	switch layer := s.(type) {
+	case Scrubber:
+		return layer, true
+	case *EncryptedStorage:
+		return AsScrubber(layer.Storage)
+	case *ObjectLockStorage:
+		return AsScrubber(layer.Storage)
+	case interface{ Unwrap() Storage }:
+		return AsScrubber(layer.Unwrap())
+	}
+	return nil, false
+}
+
+func (fs *FileStorage) integrityReportPath() string {
+	return filepath.Join(fs.root(), systemDirName, "integrity", "report.json")
+}
+
+// LastIntegrityReport lit le rapport enregistré par le dernier passage du scrubber
+func (fs *FileStorage) LastIntegrityReport() (dto.IntegrityReport, error) {
+	var report dto.IntegrityReport
+	if err := readJSONFile(fs.integrityReportPath(), &report); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return report, ErrNoIntegrityReport
+		}
+		return report, err
+	}
+	return report, nil
+}
+
+// Scrub vérifie les versions courantes et archivées de tous les buckets. Les fichiers sont relus
+// sans fs.mu ; un écart n'est signalé qu'après avoir été confirmé sous fs.mu, pour ne pas
+// prendre pour une corruption un objet remplacé ou supprimé pendant sa lecture.
+func (fs *FileStorage) Scrub(ctx context.Context, bytesPerSecond int64) (dto.IntegrityReport, error) {
+	sc := &scrub{fs: fs, ctx: ctx, throttle: newThrottle(bytesPerSecond)}
+	sc.report.Started = time.Now().UTC()
+
+	entries, err := os.ReadDir(fs.root())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return sc.report, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == systemDirName {
+			continue
+		}
+		if err := sc.bucket(entry.Name()); err != nil {
+			return sc.report, err
+		}
+	}
+
+	finished := time.Now().UTC()
+	sc.report.Finished = &finished
+	if err := writeJSONFile(fs.integrityReportPath(), sc.report); err != nil {
+		return sc.report, fmt.Errorf("failed to save integrity report: %v", err)
+	}
+	return sc.report, nil
+}
+
+// scrub est l'état d'un passage du scrubber
+type scrub struct {
+	fs       *FileStorage
+	ctx      context.Context
+	throttle *throttle
+	report   dto.IntegrityReport
+}
+
+func (sc *scrub) bucket(bucketName string) error {
+	fs := sc.fs
+
+	// Versions courantes : un fichier sans sidecar est un objet écrit avant les sidecars
+	keys, err := fs.bucketKeys(bucketName, "")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := sc.check(bucketName, key, fs.objectFile(bucketName, key), fs.metaPath(bucketName, key), true); err != nil {
+			return err
+		}
+	}
+
+	// Sidecars dont le fichier a disparu
+	metaDir := fs.bucketMetaDir(bucketName)
+	err = walkFiles(metaDir, func(path string) error {
+		rel, err := filepath.Rel(metaDir, path)
+		if err != nil {
+			return err
+		}
+		key, ok := strings.CutSuffix(filepath.ToSlash(rel), ".json")
+		if !ok {
+			return nil
+		}
+		if _, err := os.Stat(fs.objectFile(bucketName, key)); !errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return sc.check(bucketName, key, fs.objectFile(bucketName, key), path, true)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Versions archivées ; les marqueurs de suppression n'ont pas de contenu
+	versionsDir := fs.bucketVersionsDir(bucketName)
+	return walkFiles(versionsDir, func(path string) error {
+		versionID, ok := strings.CutSuffix(filepath.Base(path), ".json")
+		if !ok || !validVersionID(versionID) {
+			return nil
+		}
+		rel, err := filepath.Rel(versionsDir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		return sc.check(bucketName, filepath.ToSlash(rel), filepath.Join(filepath.Dir(path), versionID+".data"), path, false)
+	})
+}
+
+// check vérifie le contenu dataPath d'une version dont le sidecar est metaPath. current indique
+// une version courante, qui peut ne pas avoir de sidecar.
+func (sc *scrub) check(bucketName, key, dataPath, metaPath string, current bool) error {
+	if err := sc.ctx.Err(); err != nil {
+		return err
+	}
+	meta, err := readScrubMeta(metaPath, current)
+	if err != nil {
+		// Version supprimée depuis le début du parcours
+		return nil
+	}
+	if meta.DeleteMarker {
+		return nil
+	}
+	issue := dto.IntegrityIssue{Bucket: bucketName, Key: key, VersionID: meta.VersionID}
+
+	var sum string
+	var size int64
+	if _, err = os.Stat(dataPath); err == nil && meta.SHA256 != "" {
+		sum, size, err = sc.hashFile(dataPath)
+	}
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		sc.confirm(dataPath, metaPath, current, meta, false, func() {
+			sc.report.Missing = append(sc.report.Missing, issue)
+		})
+	case err != nil:
+		return err
+	case meta.SHA256 == "":
+		sc.confirm(dataPath, metaPath, current, meta, true, func() {
+			sc.report.Unverified = append(sc.report.Unverified, issue)
+		})
+	default:
+		sc.report.ObjectsChecked++
+		sc.report.BytesChecked += size
+		if sum != meta.SHA256 {
+			issue.Expected, issue.Actual = meta.SHA256, sum
+			sc.confirm(dataPath, metaPath, current, meta, true, func() {
+				sc.report.Corrupt = append(sc.report.Corrupt, issue)
+			})
+		}
+	}
+	return nil
+}
+
+// confirm appelle report si, sous fs.mu, la version n'a pas changé depuis sa lecture : même
+// sidecar, et fichier présent (exists) ou absent comme lors de la lecture. Une version remplacée
+// ou supprimée entre-temps sera vérifiée au prochain passage.
+func (sc *scrub) confirm(dataPath, metaPath string, current bool, read objectMeta, exists bool, report func()) {
+	sc.fs.mu.Lock()
+	defer sc.fs.mu.Unlock()
+
+	meta, err := readScrubMeta(metaPath, current)
+	if err != nil || meta.SHA256 != read.SHA256 || meta.ETag != read.ETag || meta.VersionID != read.VersionID {
+		return
+	}
+	_, err = os.Stat(dataPath)
+	if exists != (err == nil) {
+		return
+	}
+	if !exists && meta.ETag == "" {
+		// Ni fichier ni sidecar : l'objet a été supprimé
+		return
+	}
+	report()
+}
+
+// readScrubMeta lit un sidecar sans le créer (contrairement à loadObjectMeta). Une version
+// courante sans sidecar a des métadonnées vides, et donc pas d'empreinte.
+func readScrubMeta(metaPath string, current bool) (objectMeta, error) {
+	var meta objectMeta
+	err := readJSONFile(metaPath, &meta)
+	if current && errors.Is(err, os.ErrNotExist) {
+		return meta, nil
+	}
+	return meta, err
+}
+
+// hashFile calcule l'empreinte SHA-256 d'un fichier en respectant le débit du scrubber
+func (sc *scrub) hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, &throttledReader{ctx: sc.ctx, r: file, throttle: sc.throttle})
+	if err != nil {
+		return "", size, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// walkFiles appelle fn pour chaque fichier de l'arborescence root, qui peut ne pas exister. Les
+// fichiers temporaires de writeJSONFile sont ignorés.
+func walkFiles(root string, fn func(path string) error) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return err
+		}
+		return fn(path)
+	})
+}
+
+// throttle limite le débit de lecture cumulé du scrubber
+type throttle struct {
+	bytesPerSecond int64
+	start          time.Time
+	read           int64
+}
+
+func newThrottle(bytesPerSecond int64) *throttle {
+	return &throttle{bytesPerSecond: bytesPerSecond, start: time.Now()}
+}
+
+// wait comptabilise n octets lus et attend que le débit repasse sous la limite
+func (t *throttle) wait(ctx context.Context, n int) error {
+	if t.bytesPerSecond <= 0 {
+		return ctx.Err()
+	}
+	t.read += int64(n)
+	due := t.start.Add(time.Duration(float64(t.read) / float64(t.bytesPerSecond) * float64(time.Second)))
+	delay := time.Until(due)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledReader lit r au débit de throttle, jusqu'à l'annulation de ctx
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	throttle *throttle
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	// Des lectures d'au plus une seconde de débit gardent l'attente régulière
+	if limit := tr.throttle.bytesPerSecond; limit > 0 && int64(len(p)) > limit {
+		p = p[:limit]
+	}
+	n, err := tr.r.Read(p)
+	if werr := tr.throttle.wait(tr.ctx, n); werr != nil {
+		return n, werr
+	}
+	return n, err
+}
@@ -0,0 +1,421 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+)
+
+// Format des objets chiffrés (SSE). Le contenu est une suite de segments : un seul pour un objet
+// écrit d'un bloc, un par partie pour un upload multipart (les parties sont concaténées telles
+// quelles à l'assemblage). Chaque segment a sa propre clé de données, enveloppée par la clé maître
+// (SSE-S3) ou par la clé du client (SSE-C), et se compose :
+//
+//   - de blocs de sealChunkSize octets en clair chiffrés en AES-256-GCM, qui grossissent chacun de
+//     sealTagSize octets. Le dernier bloc, éventuellement vide, est marqué dans son nonce et
+//     authentifie la taille du segment : un segment tronqué est détecté ;
+//   - d'un trailer : clé de données enveloppée, préfixe des nonces, taille en clair, MD5 du
+//     contenu en clair (chiffré) et nombre magique.
+//
+// Le trailer est placé à la fin car la taille n'est connue qu'une fois le flux lu. À la lecture,
+// les segments sont retrouvés en remontant de trailer en trailer depuis la fin de l'objet.
+const (
+	sealChunkSize      = 64 << 10
+	sealTagSize        = 16
+	sealKeySize        = 32
+	sealNonceSize      = 12
+	sealPrefixSize     = 8
+	sealWrappedKeySize = sealNonceSize + sealKeySize + sealTagSize
+	sealSealedMD5Size  = md5.Size + sealTagSize
+	sealMagic          = "S3CE"
+	sealMagicSize      = 4
+	sealTrailerSize    = sealWrappedKeySize + sealPrefixSize + 8 + sealSealedMD5Size + sealMagicSize
+
+	// Le bit de poids fort du compteur marque le dernier bloc ; le compteur maximal est réservé au MD5
+	sealFinalFlag  = 1 << 31
+	sealMD5Counter = 1<<32 - 1
+	sealMaxChunks  = sealFinalFlag - 1
+)
+
+// errSealedCorrupted signale un objet chiffré illisible : contenu altéré, tronqué ou mauvaise clé maître
+var errSealedCorrupted = errors.New("encrypted object is corrupted or was encrypted with another key")
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// wrapKey chiffre une clé de données avec la clé kek (clé maître ou clé du client)
+func wrapKey(kek, dataKey []byte) ([]byte, error) {
+	aead, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, sealNonceSize, sealWrappedKeySize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+// unwrapKey déchiffre une clé de données enveloppée par wrapKey
+func unwrapKey(kek, wrapped []byte) ([]byte, error) {
+	aead, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := aead.Open(nil, wrapped[:sealNonceSize], wrapped[sealNonceSize:], nil)
+	if err != nil {
+		return nil, errSealedCorrupted
+	}
+	return dataKey, nil
+}
+
+func sealNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, sealNonceSize)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[sealPrefixSize:], counter)
+	return nonce
+}
+
+// sealLengthAAD est la donnée authentifiée du dernier bloc : la taille en clair du segment
+func sealLengthAAD(size int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(size))
+}
+
+// sealedSize renvoie la taille chiffrée d'un segment de size octets en clair
+func sealedSize(size int64) int64 {
+	return size + sealTagSize*(size/sealChunkSize+1) + sealTrailerSize
+}
+
+// plainSize est l'inverse de sealedSize : la taille en clair d'un segment chiffré unique
+func plainSize(sealed int64) int64 {
+	chunks := sealed - sealTrailerSize
+	if chunks < sealTagSize {
+		return 0
+	}
+	return chunks - sealTagSize*(chunks/(sealChunkSize+sealTagSize)+1)
+}
+
+// SealedPlainSize renvoie la taille en clair d'un objet chiffré d'un seul segment dont sealed octets
+// ont été lus. Tant que complete est faux, seuls les blocs dont la fin a été lue sont comptés :
+// la valeur ne dépasse jamais la taille en clair finale.
+func SealedPlainSize(sealed int64, complete bool) int64 {
+	if complete {
+		return plainSize(sealed)
+	}
+	// Le dernier bloc, plus court, et le trailer ne remplissent pas ensemble un bloc et un trailer
+	return max(sealed-sealTrailerSize, 0) / (sealChunkSize + sealTagSize) * sealChunkSize
+}
+
+// sealReader chiffre à la volée le flux src en un segment
+type sealReader struct {
+	src    io.Reader
+	kek    []byte
+	aead   cipher.AEAD
+	key    []byte
+	prefix []byte
+
+	chunk   []byte
+	buf     []byte
+	out     []byte
+	counter uint32
+	size    int64
+	hash    hash.Hash
+	done    bool
+	// finished est appelé quand src a été lu entièrement, avant que le dernier bloc soit rendu
+	finished func()
+}
+
+func newSealReader(src io.Reader, kek []byte) (*sealReader, error) {
+	key := make([]byte, sealKeySize+sealPrefixSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key[:sealKeySize])
+	if err != nil {
+		return nil, err
+	}
+	return &sealReader{
+		src:    src,
+		kek:    kek,
+		aead:   aead,
+		key:    key[:sealKeySize],
+		prefix: key[sealKeySize:],
+		chunk:  make([]byte, sealChunkSize),
+		hash:   md5.New(),
+	}, nil
+}
+
+func (sr *sealReader) Read(p []byte) (int, error) {
+	for len(sr.out) == 0 {
+		if sr.done {
+			return 0, io.EOF
+		}
+		if err := sr.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, sr.out)
+	sr.out = sr.out[n:]
+	return n, nil
+}
+
+// fill chiffre le bloc suivant ; le dernier est suivi du trailer
+func (sr *sealReader) fill() error {
+	n, err := io.ReadFull(sr.src, sr.chunk)
+	final := false
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		final = true
+	default:
+		return err
+	}
+	if sr.counter >= sealMaxChunks {
+		return fmt.Errorf("object too large to be encrypted")
+	}
+
+	plain := sr.chunk[:n]
+	sr.hash.Write(plain)
+	sr.size += int64(n)
+
+	counter, aad := sr.counter, []byte(nil)
+	if final {
+		counter |= sealFinalFlag
+		aad = sealLengthAAD(sr.size)
+	}
+	sr.buf = sr.aead.Seal(sr.buf[:0], sealNonce(sr.prefix, counter), plain, aad)
+	sr.counter++
+
+	if final {
+		trailer, err := sr.trailer()
+		if err != nil {
+			return err
+		}
+		sr.buf = append(sr.buf, trailer...)
+		sr.done = true
+		if sr.finished != nil {
+			sr.finished()
+		}
+	}
+	sr.out = sr.buf
+	return nil
+}
+
+func (sr *sealReader) trailer() ([]byte, error) {
+	wrapped, err := wrapKey(sr.kek, sr.key)
+	if err != nil {
+		return nil, err
+	}
+	trailer := make([]byte, 0, sealTrailerSize)
+	trailer = append(trailer, wrapped...)
+	trailer = append(trailer, sr.prefix...)
+	trailer = binary.BigEndian.AppendUint64(trailer, uint64(sr.size))
+	trailer = sr.aead.Seal(trailer, sealNonce(sr.prefix, sealMD5Counter), sr.hash.Sum(nil), nil)
+	return append(trailer, sealMagic...), nil
+}
+
+// sealedSegment localise un segment dans un objet chiffré
+type sealedSegment struct {
+	// Position du segment dans le contenu chiffré et dans le contenu en clair
+	offset      int64
+	plainOffset int64
+	size        int64
+	wrappedKey  []byte
+	prefix      []byte
+	sealedMD5   []byte
+}
+
+// readSealedLayout retrouve les segments d'un objet chiffré de sealed octets, en remontant depuis la fin
+func readSealedLayout(r io.ReadSeeker, sealed int64) ([]sealedSegment, error) {
+	var segments []sealedSegment
+	trailer := make([]byte, sealTrailerSize)
+	for pos := sealed; pos > 0; {
+		if pos < sealTrailerSize {
+			return nil, errSealedCorrupted
+		}
+		if _, err := r.Seek(pos-sealTrailerSize, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, trailer); err != nil {
+			return nil, err
+		}
+		if string(trailer[sealTrailerSize-sealMagicSize:]) != sealMagic {
+			return nil, errSealedCorrupted
+		}
+
+		rest := trailer
+		segment := sealedSegment{
+			wrappedKey: append([]byte(nil), rest[:sealWrappedKeySize]...),
+		}
+		rest = rest[sealWrappedKeySize:]
+		segment.prefix = append([]byte(nil), rest[:sealPrefixSize]...)
+		rest = rest[sealPrefixSize:]
+		size := binary.BigEndian.Uint64(rest)
+		rest = rest[8:]
+		segment.sealedMD5 = append([]byte(nil), rest[:sealSealedMD5Size]...)
+
+		if size > uint64(pos) || sealedSize(int64(size)) > pos {
+			return nil, errSealedCorrupted
+		}
+		segment.size = int64(size)
+		pos -= sealedSize(segment.size)
+		segment.offset = pos
+		segments = append(segments, segment)
+	}
+
+	// Les segments ont été trouvés du dernier au premier
+	var plainOffset int64
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+	for i := range segments {
+		segments[i].plainOffset = plainOffset
+		plainOffset += segments[i].size
+	}
+	return segments, nil
+}
+
+// layoutSize renvoie la taille en clair d'un objet chiffré
+func layoutSize(segments []sealedSegment) int64 {
+	if len(segments) == 0 {
+		return 0
+	}
+	last := segments[len(segments)-1]
+	return last.plainOffset + last.size
+}
+
+// segmentAEAD déchiffre la clé de données d'un segment
+func segmentAEAD(kek []byte, segment sealedSegment) (cipher.AEAD, error) {
+	key, err := unwrapKey(kek, segment.wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return newGCM(key)
+}
+
+// segmentMD5 renvoie le MD5 (hexadécimal) du contenu en clair d'un segment
+func segmentMD5(kek []byte, segment sealedSegment) (string, error) {
+	aead, err := segmentAEAD(kek, segment)
+	if err != nil {
+		return "", err
+	}
+	sum, err := aead.Open(nil, sealNonce(segment.prefix, sealMD5Counter), segment.sealedMD5, nil)
+	if err != nil {
+		return "", errSealedCorrupted
+	}
+	return fmt.Sprintf("%x", sum), nil
+}
+
+// sealedObject déchiffre un objet à la demande. Seul le bloc en cours de lecture est gardé en
+// mémoire, et Seek permet de servir les requêtes Range sans tout déchiffrer.
+type sealedObject struct {
+	r        io.ReadSeekCloser
+	kek      []byte
+	segments []sealedSegment
+	size     int64
+	pos      int64
+
+	// Segment et bloc déchiffrés en dernier
+	segment int
+	aead    cipher.AEAD
+	chunk   int64
+	plain   []byte
+	buf     []byte
+}
+
+func newSealedObject(r io.ReadSeekCloser, kek []byte, segments []sealedSegment) *sealedObject {
+	return &sealedObject{r: r, kek: kek, segments: segments, size: layoutSize(segments), segment: -1, chunk: -1}
+}
+
+func (so *sealedObject) Read(p []byte) (int, error) {
+	if so.pos >= so.size {
+		return 0, io.EOF
+	}
+	i := sort.Search(len(so.segments), func(i int) bool {
+		return so.segments[i].plainOffset+so.segments[i].size > so.pos
+	})
+	segment := so.segments[i]
+	within := so.pos - segment.plainOffset
+	if err := so.load(i, within/sealChunkSize); err != nil {
+		return 0, err
+	}
+
+	n := copy(p, so.plain[within%sealChunkSize:])
+	so.pos += int64(n)
+	return n, nil
+}
+
+// load déchiffre le bloc chunk du segment i
+func (so *sealedObject) load(i int, chunk int64) error {
+	if i == so.segment && chunk == so.chunk {
+		return nil
+	}
+	segment := so.segments[i]
+	if i != so.segment {
+		aead, err := segmentAEAD(so.kek, segment)
+		if err != nil {
+			return err
+		}
+		so.segment, so.aead, so.chunk = i, aead, -1
+	}
+
+	length := segment.size - chunk*sealChunkSize
+	if length > sealChunkSize {
+		length = sealChunkSize
+	}
+	counter, aad := uint32(chunk), []byte(nil)
+	if chunk == segment.size/sealChunkSize {
+		counter |= sealFinalFlag
+		aad = sealLengthAAD(segment.size)
+	}
+
+	if _, err := so.r.Seek(segment.offset+chunk*(sealChunkSize+sealTagSize), io.SeekStart); err != nil {
+		return err
+	}
+	if cap(so.buf) < sealChunkSize+sealTagSize {
+		so.buf = make([]byte, sealChunkSize+sealTagSize)
+	}
+	sealed := so.buf[:length+sealTagSize]
+	if _, err := io.ReadFull(so.r, sealed); err != nil {
+		return err
+	}
+	plain, err := so.aead.Open(so.plain[:0], sealNonce(segment.prefix, counter), sealed, aad)
+	if err != nil {
+		return errSealedCorrupted
+	}
+	so.plain, so.chunk = plain, chunk
+	return nil
+}
+
+func (so *sealedObject) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += so.pos
+	case io.SeekEnd:
+		offset += so.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	so.pos = offset
+	return offset, nil
+}
+
+func (so *sealedObject) Close() error {
+	return so.r.Close()
+}
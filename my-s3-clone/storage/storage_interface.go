@@ -2,23 +2,85 @@ package storage
 
 import (
 	"io"
-	"time"
 	"my-s3-clone/dto"
 
 )
 
 // Storage interface définissant les méthodes de gestion des objets et des buckets
 type Storage interface {
-    AddObject(bucketName, objectName string, data io.Reader, contentSha256 string) error
-    DeleteObject(bucketName, objectName string) error
+    // AddObject enregistre l'objet et ses métadonnées, et renvoie l'objet créé (ETag, version)
+    AddObject(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error)
+    // DeleteObject supprime l'objet, ou la version versionID si elle est renseignée
+    DeleteObject(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error)
     DeleteBucket(bucketName string) error
-    GetObject(bucketName, objectName string) ([]byte, dto.FileInfo, error)
-    CheckObjectExist(bucketName, objectName string) (bool, time.Time, int64, error)
+    // GetObject ouvre l'objet (la version courante si versionID est vide) en lecture ;
+    // l'appelant doit fermer le lecteur renvoyé
+    GetObject(bucketName, objectName, versionID string) (io.ReadSeekCloser, dto.ObjectInfo, error)
+    // StatObject renvoie les métadonnées de l'objet, ou une erreur os.ErrNotExist s'il n'existe pas
+    StatObject(bucketName, objectName, versionID string) (dto.ObjectInfo, error)
     CheckBucketExists(bucketName string) (bool, error)
     ListBuckets() []string
-    ListObjects(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error)
+    // ListObjects liste les objets après marker, regroupés par delimiter en CommonPrefixes
+    ListObjects(bucketName, prefix, delimiter, marker string, maxKeys int) (dto.ListObjectsResponse, error)
+    // CreateBucket crée le bucket et enregistre sa date de création ; le nom doit respecter
+    // les règles de nommage S3
     CreateBucket(bucketName string) error
-    CopyObject(sourceBucket, sourceKey, targetBucket, targetKey string) error
-}
+    // GetBucketInfo renvoie les métadonnées du bucket (date de création, propriétaire, région)
+    GetBucketInfo(bucketName string) (dto.BucketInfo, error)
+    // PutBucketInfo enregistre le propriétaire, la région et l'ACL du bucket ; la date de création est conservée
+    PutBucketInfo(bucketName string, info dto.BucketInfo) error
+    // CopyObject copie un objet (la version sourceVersionID, ou la version courante si elle est vide) ;
+    // metadata remplace ses métadonnées si non nil
+    CopyObject(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error)
+    // MoveObject déplace la version courante d'un objet : la cible est écrite comme par CopyObject, puis
+    // la source est supprimée comme par DeleteObject, sans qu'un échec ne laisse l'objet aux deux endroits
+    MoveObject(sourceBucket, sourceKey, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error)
+    // UpdateObjectMetadata modifie les métadonnées d'une version (la version courante si versionID
+    // est vide) sans réécrire son contenu ; une erreur renvoyée par update annule la modification
+    UpdateObjectMetadata(bucketName, objectName, versionID string, update func(*dto.ObjectMetadata) error) error
+
+    // Upload multipart
+    CreateMultipartUpload(bucketName, objectName string, metadata dto.ObjectMetadata) (string, error)
+    UploadPart(bucketName, objectName, uploadID string, partNumber int, data io.Reader) (string, error)
+    CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error)
+    AbortMultipartUpload(bucketName, objectName, uploadID string) error
+    ListParts(bucketName, objectName, uploadID string, partNumberMarker, maxParts int) (dto.ListPartsResult, error)
+    ListMultipartUploads(bucketName, prefix, keyMarker, uploadIDMarker string, maxUploads int) (dto.ListMultipartUploadsResult, error)
+    // MultipartUploadMetadata renvoie les métadonnées fournies au démarrage de l'upload
+    MultipartUploadMetadata(bucketName, objectName, uploadID string) (dto.ObjectMetadata, error)
+
+    // Versioning
+    GetBucketVersioning(bucketName string) (string, error)
+    PutBucketVersioning(bucketName, status string) error
+    ListObjectVersions(bucketName, prefix, keyMarker, versionIDMarker string, maxKeys int) (dto.ListVersionsResult, error)
+
+    // Cycle de vie : GetBucketLifecycle renvoie ErrNoSuchLifecycle si aucune règle n'est configurée
+    GetBucketLifecycle(bucketName string) (dto.LifecycleConfiguration, error)
+    PutBucketLifecycle(bucketName string, config dto.LifecycleConfiguration) error
+    DeleteBucketLifecycle(bucketName string) error
 
+    // CORS : GetBucketCors renvoie ErrNoSuchCORS si aucune règle n'est configurée
+    GetBucketCors(bucketName string) (dto.CORSConfiguration, error)
+    PutBucketCors(bucketName string, config dto.CORSConfiguration) error
+    DeleteBucketCors(bucketName string) error
 
+    // Chiffrement par défaut : GetBucketEncryption renvoie ErrNoSuchEncryptionConfiguration si aucun
+    // n'est configuré. Il est appliqué par EncryptedStorage, au-dessus des backends.
+    GetBucketEncryption(bucketName string) (dto.ServerSideEncryptionConfiguration, error)
+    PutBucketEncryption(bucketName string, config dto.ServerSideEncryptionConfiguration) error
+    DeleteBucketEncryption(bucketName string) error
+
+    // Politique de bucket : GetBucketPolicy renvoie ErrNoSuchBucketPolicy si aucune politique n'est configurée
+    GetBucketPolicy(bucketName string) (dto.BucketPolicy, error)
+    PutBucketPolicy(bucketName string, policy dto.BucketPolicy) error
+    DeleteBucketPolicy(bucketName string) error
+
+    // Notifications : GetBucketNotification renvoie une configuration vide si aucune n'est configurée
+    GetBucketNotification(bucketName string) (dto.NotificationConfiguration, error)
+    PutBucketNotification(bucketName string, config dto.NotificationConfiguration) error
+
+    // Object Lock : GetObjectLockConfiguration renvoie ErrNoSuchObjectLockConfiguration si le verrouillage
+    // n'est pas activé sur le bucket. Il est appliqué par ObjectLockStorage, au-dessus des backends.
+    GetObjectLockConfiguration(bucketName string) (dto.ObjectLockConfiguration, error)
+    PutObjectLockConfiguration(bucketName string, config dto.ObjectLockConfiguration) error
+}
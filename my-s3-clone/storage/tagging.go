@@ -0,0 +1,11 @@
+package storage
+
+// ObjectTags renvoie les tags d'une version d'objet. Comme sur S3, ils se lisent sans la clé
+// d'un objet SSE-C : le chiffrement est ignoré.
+func ObjectTags(s Storage, bucketName, objectName, versionID string) (map[string]string, error) {
+	if es, ok := s.(*EncryptedStorage); ok {
+		s = es.Storage
+	}
+	info, err := s.StatObject(bucketName, objectName, versionID)
+	return info.Tags, err
+}
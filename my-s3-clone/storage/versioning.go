@@ -0,0 +1,659 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// États du versioning d'un bucket. Un bucket jamais versionné a un état vide ;
+// une fois activé, le versioning ne peut plus qu'être suspendu.
+const (
+	VersioningEnabled   = "Enabled"
+	VersioningSuspended = "Suspended"
+)
+
+// Identifiant de la version "null" : objet écrit sans versioning ou pendant une suspension
+const nullVersionID = "null"
+
+// Organisation des versions sur disque :
+//   - la version courante d'un objet reste à <racine>/<bucket>/<clé>, avec son sidecar ;
+//   - les versions précédentes et les marqueurs de suppression sont archivés sous
+//     .s3clone/versions/<bucket>/<clé>/<versionId>.json (métadonnées) et <versionId>.data (contenu).
+// Le fichier courant existe si et seulement si la dernière version n'est pas un marqueur de suppression.
+
+// bucketConfig regroupe les métadonnées et la configuration d'un bucket, stockées dans
+// .s3clone/buckets/<bucket>.json (voir buckets.go)
+type bucketConfig struct {
+	CreationDate time.Time `json:"creationDate,omitempty"`
+	Owner        string    `json:"owner,omitempty"`
+	Region       string    `json:"region,omitempty"`
+	ACL          string    `json:"acl,omitempty"`
+
+	Versioning string                                 `json:"versioning,omitempty"`
+	Lifecycle  *dto.LifecycleConfiguration            `json:"lifecycle,omitempty"`
+	ObjectLock *dto.ObjectLockConfiguration           `json:"objectLock,omitempty"`
+	CORS       *dto.CORSConfiguration                 `json:"cors,omitempty"`
+	Policy     *dto.BucketPolicy                      `json:"policy,omitempty"`
+	Encryption *dto.ServerSideEncryptionConfiguration `json:"encryption,omitempty"`
+
+	Notification *dto.NotificationConfiguration `json:"notification,omitempty"`
+}
+
+func (fs *FileStorage) bucketConfigPath(bucketName string) string {
+	return filepath.Join(fs.root(), systemDirName, "buckets", bucketName+".json")
+}
+
+func (fs *FileStorage) loadBucketConfig(bucketName string) (bucketConfig, error) {
+	var config bucketConfig
+	err := readJSONFile(fs.bucketConfigPath(bucketName), &config)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	return config, err
+}
+
+func (fs *FileStorage) versionsDir(bucketName, objectName string) string {
+	return filepath.Join(fs.root(), systemDirName, "versions", bucketName, filepath.FromSlash(objectName))
+}
+
+func (fs *FileStorage) bucketVersionsDir(bucketName string) string {
+	return filepath.Join(fs.root(), systemDirName, "versions", bucketName)
+}
+
+func (fs *FileStorage) versionMetaPath(bucketName, objectName, versionID string) string {
+	return filepath.Join(fs.versionsDir(bucketName, objectName), versionID+".json")
+}
+
+func (fs *FileStorage) versionDataPath(bucketName, objectName, versionID string) string {
+	return filepath.Join(fs.versionsDir(bucketName, objectName), versionID+".data")
+}
+
+// newVersionID génère un identifiant de version. Il commence par l'horodatage, ce qui
+// permet de départager deux versions écrites dans la même nanoseconde.
+func newVersionID() (string, error) {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, uint64(time.Now().UnixNano()))
+	if _, err := rand.Read(b[8:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validVersionID vérifie le format de l'identifiant, qui est utilisé comme nom de fichier
+func validVersionID(versionID string) bool {
+	if versionID == nullVersionID {
+		return true
+	}
+	if len(versionID) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(versionID)
+	return err == nil
+}
+
+// currentVersionID renvoie l'identifiant de la version courante décrite par meta
+func currentVersionID(meta objectMeta) string {
+	if meta.VersionID == "" {
+		return nullVersionID
+	}
+	return meta.VersionID
+}
+
+// Lecture de l'état du versioning d'un bucket
+func (fs *FileStorage) GetBucketVersioning(bucketName string) (string, error) {
+	if err := fs.requireBucket(bucketName); err != nil {
+		return "", err
+	}
+	config, err := fs.loadBucketConfig(bucketName)
+	return config.Versioning, err
+}
+
+// Activation ou suspension du versioning d'un bucket
+func (fs *FileStorage) PutBucketVersioning(bucketName, status string) error {
+	if status != VersioningEnabled && status != VersioningSuspended {
+		return ErrInvalidVersioning
+	}
+	if err := fs.requireBucket(bucketName); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return err
+	}
+	config.Versioning = status
+	log.Printf("Versioning of bucket %s set to %s", bucketName, status)
+	return writeJSONFile(fs.bucketConfigPath(bucketName), config)
+}
+
+// requireBucket renvoie ErrNoSuchBucket si le bucket n'existe pas
+func (fs *FileStorage) requireBucket(bucketName string) error {
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoSuchBucket
+	}
+	return nil
+}
+
+// commitObject met en place le fichier tmpPath comme nouvelle version courante de l'objet,
+// en archivant la version précédente si le versioning du bucket le demande.
+func (fs *FileStorage) commitObject(bucketName, objectName, tmpPath string, meta objectMeta) (dto.ObjectInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.commit(bucketName, objectName, tmpPath, meta)
+}
+
+// commit est commitObject pour un appelant qui détient déjà fs.mu
+func (fs *FileStorage) commit(bucketName, objectName, tmpPath string, meta objectMeta) (dto.ObjectInfo, error) {
+	config, err := fs.loadBucketConfig(bucketName)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	switch config.Versioning {
+	case VersioningEnabled:
+		if meta.VersionID, err = newVersionID(); err != nil {
+			return dto.ObjectInfo{}, err
+		}
+		if err := fs.archiveCurrent(bucketName, objectName, true); err != nil {
+			return dto.ObjectInfo{}, err
+		}
+	case VersioningSuspended:
+		// La nouvelle version remplace la version "null" existante, courante ou archivée
+		meta.VersionID = nullVersionID
+		if err := fs.archiveCurrent(bucketName, objectName, false); err != nil {
+			return dto.ObjectInfo{}, err
+		}
+		if err := fs.removeArchivedVersion(bucketName, objectName, nullVersionID); err != nil {
+			return dto.ObjectInfo{}, err
+		}
+	default:
+		meta.VersionID = ""
+	}
+
+	if fs.contentAddressed {
+		if meta.SHA256, err = fs.storeBlob(tmpPath); err != nil {
+			return dto.ObjectInfo{}, err
+		}
+	} else if meta.SHA256 == "" {
+		// Empreinte relue par le scrubber (voir scrub.go) ; les appelants qui lisent déjà le
+		// contenu la calculent au passage
+		if meta.SHA256, err = fileSHA256(tmpPath); err != nil {
+			return dto.ObjectInfo{}, err
+		}
+	}
+
+	objectPath := fs.objectFile(bucketName, objectName)
+	if err := makeObjectDir(objectPath); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	// Le contenu et son sidecar sont remplacés l'un après l'autre : le journal permet de terminer
+	// la mise en place si le serveur s'arrête entre les deux (voir recoverCommit)
+	journalPath, err := fs.writeCommitJournal(bucketName, objectName, tmpPath, meta)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	// Sans versioning, l'ancien contenu est remplacé : son blob est libéré une fois le renommage fait
+	previous := fs.currentBlob(bucketName, objectName)
+	if err := os.Rename(tmpPath, objectPath); err != nil {
+		os.Remove(journalPath)
+		return dto.ObjectInfo{}, fmt.Errorf("failed to move object into place: %v", err)
+	}
+	if err := syncDir(filepath.Dir(objectPath)); err != nil {
+		log.Printf("Failed to sync directory of %s/%s: %v", bucketName, objectName, err)
+	}
+	fs.releaseBlob(previous)
+	if err := fs.writeObjectMeta(bucketName, objectName, meta); err != nil {
+		// Le journal est conservé : le sidecar sera écrit au prochain démarrage
+		return dto.ObjectInfo{}, fmt.Errorf("failed to save object metadata: %v", err)
+	}
+	if err := os.Remove(journalPath); err != nil {
+		log.Printf("Failed to remove commit journal %s: %v", journalPath, err)
+	}
+
+	fileInfo, err := os.Stat(objectPath)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return dto.ObjectInfo{
+		Key:            objectName,
+		Size:           fileInfo.Size(),
+		LastModified:   fileInfo.ModTime(),
+		ETag:           meta.ETag,
+		VersionID:      meta.VersionID,
+		ObjectMetadata: meta.ObjectMetadata,
+	}, nil
+}
+
+// archiveCurrent déplace la version courante de l'objet dans les archives.
+// Si keepNull est faux, une version courante "null" est supprimée au lieu d'être archivée.
+func (fs *FileStorage) archiveCurrent(bucketName, objectName string, keepNull bool) error {
+	objectPath := fs.objectFile(bucketName, objectName)
+	fileInfo, err := os.Stat(objectPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	meta, err := fs.loadObjectMeta(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	versionID := currentVersionID(meta)
+
+	if versionID == nullVersionID && !keepNull {
+		if err := os.Remove(objectPath); err != nil {
+			return err
+		}
+		fs.releaseBlob(meta.SHA256)
+		fs.removeObjectMeta(bucketName, objectName)
+		removeEmptyParents(fs.bucketDir(bucketName), objectPath)
+		return nil
+	}
+
+	meta.VersionID = versionID
+	meta.Size = fileInfo.Size()
+	meta.LastModified = fileInfo.ModTime()
+
+	if err := os.MkdirAll(fs.versionsDir(bucketName, objectName), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(objectPath, fs.versionDataPath(bucketName, objectName, versionID)); err != nil {
+		return fmt.Errorf("failed to archive version %s of %s: %v", versionID, objectName, err)
+	}
+	if err := writeJSONFile(fs.versionMetaPath(bucketName, objectName, versionID), meta); err != nil {
+		return err
+	}
+	fs.removeObjectMeta(bucketName, objectName)
+	removeEmptyParents(fs.bucketDir(bucketName), objectPath)
+
+	log.Printf("Archived version %s of %s/%s", versionID, bucketName, objectName)
+	return nil
+}
+
+// writeDeleteMarker archive un marqueur de suppression, qui devient la dernière version de l'objet
+func (fs *FileStorage) writeDeleteMarker(bucketName, objectName, versionID string) error {
+	return writeJSONFile(fs.versionMetaPath(bucketName, objectName, versionID), objectMeta{
+		VersionID:    versionID,
+		LastModified: time.Now(),
+		DeleteMarker: true,
+	})
+}
+
+// loadArchivedVersion lit les métadonnées d'une version archivée
+func (fs *FileStorage) loadArchivedVersion(bucketName, objectName, versionID string) (objectMeta, error) {
+	var meta objectMeta
+	if !validVersionID(versionID) {
+		return meta, ErrNoSuchVersion
+	}
+	if err := readJSONFile(fs.versionMetaPath(bucketName, objectName, versionID), &meta); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return meta, ErrNoSuchVersion
+		}
+		return meta, err
+	}
+	return meta, nil
+}
+
+// removeArchivedVersion supprime une version archivée, si elle existe
+func (fs *FileStorage) removeArchivedVersion(bucketName, objectName, versionID string) error {
+	var meta objectMeta
+	readJSONFile(fs.versionMetaPath(bucketName, objectName, versionID), &meta)
+
+	for _, path := range []string{fs.versionDataPath(bucketName, objectName, versionID), fs.versionMetaPath(bucketName, objectName, versionID)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	fs.releaseBlob(meta.SHA256)
+	// Les répertoires ne sont supprimés que s'ils sont vides
+	removeEmptyParents(fs.bucketVersionsDir(bucketName), fs.versionMetaPath(bucketName, objectName, versionID))
+	return nil
+}
+
+// archivedVersions liste les versions archivées d'un objet, de la plus récente à la plus ancienne
+func (fs *FileStorage) archivedVersions(bucketName, objectName string) ([]objectMeta, error) {
+	entries, err := os.ReadDir(fs.versionsDir(bucketName, objectName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var versions []objectMeta
+	for _, entry := range entries {
+		versionID, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok || !validVersionID(versionID) {
+			continue
+		}
+		meta, err := fs.loadArchivedVersion(bucketName, objectName, versionID)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, meta)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		if !versions[i].LastModified.Equal(versions[j].LastModified) {
+			return versions[i].LastModified.After(versions[j].LastModified)
+		}
+		return versions[i].VersionID > versions[j].VersionID
+	})
+	return versions, nil
+}
+
+// promoteLatest restaure la version archivée la plus récente comme version courante,
+// lorsque la version courante vient d'être supprimée et que cette version n'est pas un marqueur
+func (fs *FileStorage) promoteLatest(bucketName, objectName string) error {
+	objectPath := fs.objectFile(bucketName, objectName)
+	if _, err := os.Stat(objectPath); err == nil {
+		return nil
+	}
+
+	versions, err := fs.archivedVersions(bucketName, objectName)
+	if err != nil || len(versions) == 0 || versions[0].DeleteMarker {
+		return err
+	}
+
+	latest := versions[0]
+	if err := os.MkdirAll(filepath.Dir(objectPath), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(fs.versionDataPath(bucketName, objectName, latest.VersionID), objectPath); err != nil {
+		return fmt.Errorf("failed to restore version %s of %s: %v", latest.VersionID, objectName, err)
+	}
+
+	latest.Size, latest.LastModified = 0, time.Time{}
+	if err := fs.writeObjectMeta(bucketName, objectName, latest); err != nil {
+		return err
+	}
+	log.Printf("Version %s of %s/%s is now the current version", latest.VersionID, bucketName, objectName)
+	return fs.removeArchivedVersion(bucketName, objectName, latest.VersionID)
+}
+
+// deleteVersion supprime définitivement une version (ou un marqueur de suppression) d'un objet
+func (fs *FileStorage) deleteVersion(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error) {
+	result := dto.DeleteObjectResult{VersionID: versionID}
+	if !validVersionID(versionID) {
+		return result, ErrNoSuchVersion
+	}
+
+	objectPath := fs.objectFile(bucketName, objectName)
+	if _, err := os.Stat(objectPath); err == nil {
+		meta, err := fs.loadObjectMeta(bucketName, objectName)
+		if err != nil {
+			return result, err
+		}
+		if currentVersionID(meta) == versionID {
+			if err := os.Remove(objectPath); err != nil {
+				return result, err
+			}
+			fs.releaseBlob(meta.SHA256)
+			fs.removeObjectMeta(bucketName, objectName)
+			removeEmptyParents(fs.bucketDir(bucketName), objectPath)
+			log.Printf("Deleted current version %s of %s/%s", versionID, bucketName, objectName)
+			return result, fs.promoteLatest(bucketName, objectName)
+		}
+	}
+
+	meta, err := fs.loadArchivedVersion(bucketName, objectName, versionID)
+	if err != nil {
+		return result, err
+	}
+	if err := fs.removeArchivedVersion(bucketName, objectName, versionID); err != nil {
+		return result, err
+	}
+	result.DeleteMarker = meta.DeleteMarker
+	log.Printf("Deleted version %s of %s/%s", versionID, bucketName, objectName)
+
+	// Supprimer le marqueur le plus récent restaure l'objet
+	return result, fs.promoteLatest(bucketName, objectName)
+}
+
+// deleteVersioned supprime un objet d'un bucket versionné en ajoutant un marqueur de suppression
+func (fs *FileStorage) deleteVersioned(bucketName, objectName, status string) (dto.DeleteObjectResult, error) {
+	result := dto.DeleteObjectResult{DeleteMarker: true, VersionID: nullVersionID}
+
+	if status == VersioningEnabled {
+		versionID, err := newVersionID()
+		if err != nil {
+			return result, err
+		}
+		result.VersionID = versionID
+		if err := fs.archiveCurrent(bucketName, objectName, true); err != nil {
+			return result, err
+		}
+	} else {
+		if err := fs.archiveCurrent(bucketName, objectName, false); err != nil {
+			return result, err
+		}
+		if err := fs.removeArchivedVersion(bucketName, objectName, nullVersionID); err != nil {
+			return result, err
+		}
+	}
+
+	if err := fs.writeDeleteMarker(bucketName, objectName, result.VersionID); err != nil {
+		return result, err
+	}
+	log.Printf("Delete marker %s created for %s/%s", result.VersionID, bucketName, objectName)
+	return result, nil
+}
+
+// openVersion ouvre une version précise d'un objet
+func (fs *FileStorage) openVersion(bucketName, objectName, versionID string) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+	objectPath := fs.objectFile(bucketName, objectName)
+	if fileInfo, err := os.Stat(objectPath); err == nil && !fileInfo.IsDir() {
+		meta, err := fs.loadObjectMeta(bucketName, objectName)
+		if err != nil {
+			return nil, dto.ObjectInfo{}, err
+		}
+		if currentVersionID(meta) == versionID {
+			file, err := os.Open(objectPath)
+			if err != nil {
+				return nil, dto.ObjectInfo{}, err
+			}
+			info, err := fs.statObject(bucketName, objectName, fileInfo)
+			if err != nil {
+				file.Close()
+				return nil, dto.ObjectInfo{}, err
+			}
+			info.VersionID = versionID
+			return file, info, nil
+		}
+	}
+
+	meta, err := fs.loadArchivedVersion(bucketName, objectName, versionID)
+	if err != nil {
+		return nil, dto.ObjectInfo{}, err
+	}
+	info := metaObjectInfo(objectName, meta)
+	if meta.DeleteMarker {
+		return nil, info, ErrDeleteMarker
+	}
+
+	file, err := os.Open(fs.versionDataPath(bucketName, objectName, versionID))
+	if err != nil {
+		return nil, dto.ObjectInfo{}, err
+	}
+	return file, info, nil
+}
+
+func metaObjectInfo(objectName string, meta objectMeta) dto.ObjectInfo {
+	return dto.ObjectInfo{
+		Key:            objectName,
+		Size:           meta.Size,
+		LastModified:   meta.LastModified,
+		ETag:           meta.ETag,
+		VersionID:      meta.VersionID,
+		ObjectMetadata: meta.ObjectMetadata,
+	}
+}
+
+// Liste de toutes les versions des objets d'un bucket, triées par clé puis de la plus récente à la plus ancienne
+func (fs *FileStorage) ListObjectVersions(bucketName, prefix, keyMarker, versionIDMarker string, maxKeys int) (dto.ListVersionsResult, error) {
+	result := dto.ListVersionsResult{
+		Xmlns:           dto.S3Namespace,
+		Name:            bucketName,
+		Prefix:          prefix,
+		KeyMarker:       keyMarker,
+		VersionIdMarker: versionIDMarker,
+		MaxKeys:         maxKeys,
+	}
+	if err := fs.requireBucket(bucketName); err != nil {
+		return result, err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	keys, err := fs.versionedKeys(bucketName)
+	if err != nil {
+		return result, err
+	}
+
+	err = paginateVersions(&result, keys, func(key string) ([]objectMeta, error) {
+		return fs.objectVersions(bucketName, key)
+	})
+	return result, err
+}
+
+// paginateVersions remplit une page de listing des versions à partir des clés triées d'un bucket ;
+// elle est commune à tous les backends. versionsOf renvoie les versions d'une clé, la plus récente en premier.
+func paginateVersions(result *dto.ListVersionsResult, keys []string, versionsOf func(key string) ([]objectMeta, error)) error {
+	prefix, keyMarker, versionIDMarker, maxKeys := result.Prefix, result.KeyMarker, result.VersionIdMarker, result.MaxKeys
+
+	count := 0
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) || key < keyMarker || (key == keyMarker && versionIDMarker == "") {
+			continue
+		}
+
+		versions, err := versionsOf(key)
+		if err != nil {
+			return err
+		}
+
+		skipping := key == keyMarker
+		for i, version := range versions {
+			if skipping {
+				if version.VersionID == versionIDMarker {
+					skipping = false
+				}
+				continue
+			}
+
+			if count == maxKeys {
+				result.IsTruncated = true
+				return nil
+			}
+			count++
+			result.NextKeyMarker, result.NextVersionIdMarker = key, version.VersionID
+
+			if version.DeleteMarker {
+				result.DeleteMarkers = append(result.DeleteMarkers, dto.DeleteMarkerEntry{
+					Key:          key,
+					VersionId:    version.VersionID,
+					IsLatest:     i == 0,
+					LastModified: version.LastModified,
+				})
+				continue
+			}
+			result.Versions = append(result.Versions, dto.ObjectVersion{
+				Key:          key,
+				VersionId:    version.VersionID,
+				IsLatest:     i == 0,
+				LastModified: version.LastModified,
+				ETag:         `"` + version.ETag + `"`,
+				Size:         version.Size,
+				StorageClass: "STANDARD",
+			})
+		}
+	}
+
+	// Les marqueurs "Next" ne sont renvoyés que si la liste est tronquée
+	result.NextKeyMarker, result.NextVersionIdMarker = "", ""
+	return nil
+}
+
+// objectVersions renvoie toutes les versions d'un objet, la version courante en premier
+func (fs *FileStorage) objectVersions(bucketName, objectName string) ([]objectMeta, error) {
+	versions, err := fs.archivedVersions(bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := os.Stat(fs.objectFile(bucketName, objectName))
+	if errors.Is(err, os.ErrNotExist) {
+		return versions, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	current, err := fs.loadObjectMeta(bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	current.VersionID = currentVersionID(current)
+	current.Size = fileInfo.Size()
+	current.LastModified = fileInfo.ModTime()
+	return append([]objectMeta{current}, versions...), nil
+}
+
+// versionedKeys liste, triées, les clés ayant une version courante ou des versions archivées
+func (fs *FileStorage) versionedKeys(bucketName string) ([]string, error) {
+	seen := make(map[string]bool)
+
+	current, err := fs.bucketKeys(bucketName, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range current {
+		seen[key] = true
+	}
+
+	archivePath := fs.bucketVersionsDir(bucketName)
+	err = filepath.WalkDir(archivePath, func(path string, d os.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) && path == archivePath {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
+			return err
+		}
+		key, err := filepath.Rel(archivePath, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		seen[filepath.ToSlash(key)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
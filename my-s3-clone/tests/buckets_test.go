@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/config"
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+const (
+	otherAccessKey = "AKIAI44QH8DHBEXAMPLE"
+	otherSecretKey = "je7MtGbClwBF/2Zp9Utk/h3yCo8nvbEXAMPLEKEY"
+)
+
+// Test that bucket metadata is kept on disk, and recorded for buckets created before it existed
+func TestBucketInfoPersistence(t *testing.T) {
+	root := t.TempDir()
+	s := storage.NewFileStorage(root)
+	mustCreateBucket(t, s, "album")
+	if err := s.PutBucketInfo("album", dto.BucketInfo{Owner: testAccessKey, Region: "eu-west-3"}); err != nil {
+		t.Fatalf("PutBucketInfo: %v", err)
+	}
+	info, _ := s.GetBucketInfo("album")
+
+	reopened := storage.NewFileStorage(root)
+	if again, err := reopened.GetBucketInfo("album"); err != nil || again != info {
+		t.Errorf("expected %+v after reopening the storage but got %+v, %v", info, again, err)
+	}
+
+	// A bucket directory without metadata gets a stable creation date
+	if err := os.Mkdir(filepath.Join(root, "legacy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := reopened.GetBucketInfo("legacy")
+	if err != nil || legacy.CreationDate.IsZero() || legacy.Owner != "" {
+		t.Fatalf("unexpected legacy bucket info %+v, %v", legacy, err)
+	}
+	mustPut(t, reopened, "legacy", "photo.jpg", "data")
+	if again, _ := reopened.GetBucketInfo("legacy"); !again.CreationDate.Equal(legacy.CreationDate) {
+		t.Errorf("creation date changed from %v to %v", legacy.CreationDate, again.CreationDate)
+	}
+}
+
+// Test bucket creation, listing and location over HTTP with two users
+func TestBucketOwnership(t *testing.T) {
+	cfg := config.Config{
+		Region:      testRegion,
+		Credentials: map[string]string{testAccessKey: testSecretKey, otherAccessKey: otherSecretKey},
+	}
+	r := router.SetupRouterWithConfig(storage.NewMemoryStorage(), cfg)
+
+	send := func(method, url, body, accessKey, secretKey string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "http://localhost"+url, strings.NewReader(body))
+		auth.SignRequest(req, accessKey, secretKey, testRegion, time.Now())
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	listBuckets := func(accessKey, secretKey string) dto.ListAllMyBucketsResult {
+		t.Helper()
+		rr := send("GET", "/", "", accessKey, secretKey)
+		var result dto.ListAllMyBucketsResult
+		if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("could not decode %q: %v", rr.Body.String(), err)
+		}
+		return result
+	}
+
+	before := time.Now().Add(-time.Second).Truncate(time.Second)
+	if rr := send("PUT", "/album/", "", testAccessKey, testSecretKey); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := send("PUT", "/other-album/", "", otherAccessKey, otherSecretKey); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	result := listBuckets(testAccessKey, testSecretKey)
+	if len(result.Buckets) != 1 || result.Buckets[0].Name != "album" {
+		t.Fatalf("expected only the caller's bucket but got %+v", result.Buckets)
+	}
+	if created := result.Buckets[0].CreationDate; created.Before(before) || created.After(time.Now()) {
+		t.Errorf("unexpected creation date %v", created)
+	}
+	if result.Buckets[0].BucketRegion != testRegion || result.Owner == nil || result.Owner.ID != testAccessKey {
+		t.Errorf("unexpected region or owner: %+v, %+v", result.Buckets[0], result.Owner)
+	}
+	// The creation date does not change between two listings
+	if again := listBuckets(testAccessKey, testSecretKey); !again.Buckets[0].CreationDate.Equal(result.Buckets[0].CreationDate) {
+		t.Errorf("creation date changed from %v to %v", result.Buckets[0].CreationDate, again.Buckets[0].CreationDate)
+	}
+	if other := listBuckets(otherAccessKey, otherSecretKey); len(other.Buckets) != 1 || other.Buckets[0].Name != "other-album" {
+		t.Errorf("expected only other-album but got %+v", other.Buckets)
+	}
+
+	tests := []struct {
+		name         string
+		url          string
+		body         string
+		accessKey    string
+		secretKey    string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"owned by caller", "/album/", "", testAccessKey, testSecretKey, http.StatusConflict, "BucketAlreadyOwnedByYou"},
+		{"owned by another user", "/album/", "", otherAccessKey, otherSecretKey, http.StatusConflict, "BucketAlreadyExists"},
+		{"uppercase", "/Album/", "", testAccessKey, testSecretKey, http.StatusBadRequest, "InvalidBucketName"},
+		{"underscore", "/my_album/", "", testAccessKey, testSecretKey, http.StatusBadRequest, "InvalidBucketName"},
+		{"too short", "/ab/", "", testAccessKey, testSecretKey, http.StatusBadRequest, "InvalidBucketName"},
+		{"ip address", "/10.0.0.1/", "", testAccessKey, testSecretKey, http.StatusBadRequest, "InvalidBucketName"},
+		{"other region", "/paris/", "<CreateBucketConfiguration><LocationConstraint>eu-west-3</LocationConstraint></CreateBucketConfiguration>", testAccessKey, testSecretKey, http.StatusBadRequest, "IllegalLocationConstraintException"},
+		{"malformed configuration", "/paris/", "<CreateBucketConfiguration>", testAccessKey, testSecretKey, http.StatusBadRequest, "MalformedXML"},
+	}
+	for _, tt := range tests {
+		rr := send("PUT", tt.url, tt.body, tt.accessKey, tt.secretKey)
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedCode, rr.Code)
+			continue
+		}
+		if code := errorCode(t, rr); code != tt.expectedErr {
+			t.Errorf("%s: expected error code %s but got %s", tt.name, tt.expectedErr, code)
+		}
+	}
+
+	body := "<CreateBucketConfiguration><LocationConstraint>" + testRegion + "</LocationConstraint></CreateBucketConfiguration>"
+	if rr := send("PUT", "/virginia/", body, testAccessKey, testSecretKey); rr.Code != http.StatusOK {
+		t.Errorf("matching location constraint: expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rr := send("GET", "/album/?location", "", testAccessKey, testSecretKey)
+	if rr.Code != http.StatusOK || rr.Body.String() != "<LocationConstraint>"+testRegion+"</LocationConstraint>" {
+		t.Errorf("unexpected location %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := send("GET", "/missing/?location", "", testAccessKey, testSecretKey); rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchBucket" {
+		t.Errorf("expected NoSuchBucket but got %d: %s", rr.Code, rr.Body.String())
+	}
+}
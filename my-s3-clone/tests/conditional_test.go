@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+)
+
+// Test If-Match, If-None-Match, If-Modified-Since and If-Unmodified-Since on GET and HEAD
+func TestConditionalRequests(t *testing.T) {
+	const etag = "5d41402abc4b2a76b9719d911017c592"
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	info := dto.ObjectInfo{Key: "photo.jpg", Size: 5, LastModified: modTime, ETag: etag}
+
+	mockStorage := &MockStorage{
+		GetObjectFunc: func(bucketName, objectName, versionID string) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+			return newMockObject("hello"), info, nil
+		},
+		StatObjectFunc: func(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+			return info, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	before := modTime.Add(-time.Hour).Format(http.TimeFormat)
+	after := modTime.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		expectedCode int
+	}{
+		{"no condition", nil, http.StatusOK},
+		{"if-match matches", map[string]string{"If-Match": `"` + etag + `"`}, http.StatusOK},
+		{"if-match star", map[string]string{"If-Match": "*"}, http.StatusOK},
+		{"if-match differs", map[string]string{"If-Match": `"other"`}, http.StatusPreconditionFailed},
+		{"if-none-match matches", map[string]string{"If-None-Match": `"other", "` + etag + `"`}, http.StatusNotModified},
+		{"if-none-match differs", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"if-modified-since before", map[string]string{"If-Modified-Since": before}, http.StatusOK},
+		{"if-modified-since after", map[string]string{"If-Modified-Since": after}, http.StatusNotModified},
+		{"if-unmodified-since before", map[string]string{"If-Unmodified-Since": before}, http.StatusPreconditionFailed},
+		{"if-unmodified-since after", map[string]string{"If-Unmodified-Since": after}, http.StatusOK},
+		{"if-match wins over if-unmodified-since", map[string]string{"If-Match": `"` + etag + `"`, "If-Unmodified-Since": before}, http.StatusOK},
+		{"if-none-match wins over if-modified-since", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": after}, http.StatusOK},
+	}
+
+	for _, method := range []string{"GET", "HEAD"} {
+		for _, tt := range tests {
+			req, _ := http.NewRequest(method, "/album/photo.jpg", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("%s %s: expected status %d but got %d", method, tt.name, tt.expectedCode, rr.Code)
+				continue
+			}
+			if got := rr.Header().Get("ETag"); got != `"`+etag+`"` {
+				t.Errorf("%s %s: expected ETag header but got %q", method, tt.name, got)
+			}
+			if method == "GET" && tt.expectedCode == http.StatusPreconditionFailed {
+				if code := errorCode(t, rr); code != "PreconditionFailed" {
+					t.Errorf("%s %s: expected error code PreconditionFailed but got %s", method, tt.name, code)
+				}
+			}
+			if tt.expectedCode == http.StatusNotModified && rr.Body.Len() != 0 {
+				t.Errorf("%s %s: expected empty body for 304", method, tt.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,828 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/storage"
+)
+
+// Conformance suite run against every registered backend: they must all behave the same way
+var conformanceTests = []struct {
+	name string
+	run  func(t *testing.T, s storage.Storage)
+}{
+	{"Buckets", testConformanceBuckets},
+	{"BucketInfo", testConformanceBucketInfo},
+	{"Objects", testConformanceObjects},
+	{"Copy", testConformanceCopy},
+	{"CopyVersion", testConformanceCopyVersion},
+	{"Move", testConformanceMove},
+	{"Tagging", testConformanceTagging},
+	{"Listing", testConformanceListing},
+	{"Versioning", testConformanceVersioning},
+	{"Multipart", testConformanceMultipart},
+	{"Lifecycle", testConformanceLifecycle},
+	{"CORS", testConformanceCORS},
+	{"BucketPolicy", testConformanceBucketPolicy},
+	{"Notification", testConformanceNotification},
+}
+
+func TestBackendConformance(t *testing.T) {
+	for _, backend := range storage.Backends() {
+		for _, tt := range conformanceTests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				s, err := storage.NewBackend(backend, storage.BackendConfig{Root: t.TempDir()})
+				if err != nil {
+					t.Fatalf("could not create backend: %v", err)
+				}
+				tt.run(t, s)
+			})
+		}
+	}
+}
+
+// Test that an unknown backend is rejected
+func TestUnknownBackend(t *testing.T) {
+	if _, err := storage.NewBackend("tape", storage.BackendConfig{}); err == nil {
+		t.Errorf("expected an error for an unknown backend")
+	}
+}
+
+func mustCreateBucket(t *testing.T, s storage.Storage, bucketName string) {
+	t.Helper()
+	if err := s.CreateBucket(bucketName); err != nil {
+		t.Fatalf("CreateBucket(%s): %v", bucketName, err)
+	}
+}
+
+func mustPut(t *testing.T, s storage.Storage, bucketName, objectName, content string) dto.ObjectInfo {
+	t.Helper()
+	info, err := s.AddObject(bucketName, objectName, strings.NewReader(content), dto.ObjectMetadata{})
+	if err != nil {
+		t.Fatalf("AddObject(%s/%s): %v", bucketName, objectName, err)
+	}
+	return info
+}
+
+// expectContent checks the content of an object (or of one of its versions)
+func expectContent(t *testing.T, s storage.Storage, bucketName, objectName, versionID, expected string) {
+	t.Helper()
+	file, _, err := s.GetObject(bucketName, objectName, versionID)
+	if err != nil {
+		t.Fatalf("GetObject(%s/%s, %q): %v", bucketName, objectName, versionID, err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("could not read %s/%s: %v", bucketName, objectName, err)
+	}
+	if string(data) != expected {
+		t.Errorf("%s/%s (%q): expected content %q but got %q", bucketName, objectName, versionID, expected, data)
+	}
+}
+
+func md5Hex(content []byte) string {
+	sum := md5.Sum(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func testConformanceBuckets(t *testing.T, s storage.Storage) {
+	if exists, err := s.CheckBucketExists("album"); err != nil || exists {
+		t.Fatalf("expected no bucket, got %v, %v", exists, err)
+	}
+	mustCreateBucket(t, s, "album")
+	mustCreateBucket(t, s, "backup")
+	if exists, err := s.CheckBucketExists("album"); err != nil || !exists {
+		t.Fatalf("expected bucket to exist, got %v, %v", exists, err)
+	}
+	if buckets := strings.Join(s.ListBuckets(), ","); buckets != "album,backup" {
+		t.Errorf("expected buckets album,backup but got %s", buckets)
+	}
+
+	if err := s.CreateBucket("../etc"); !errors.Is(err, storage.ErrInvalidBucketName) {
+		t.Errorf("expected ErrInvalidBucketName but got %v", err)
+	}
+
+	mustPut(t, s, "backup", "photo.jpg", "data")
+	if err := s.DeleteBucket("backup"); err != nil {
+		t.Fatalf("DeleteBucket: %v", err)
+	}
+	if exists, _ := s.CheckBucketExists("backup"); exists {
+		t.Errorf("bucket should be deleted")
+	}
+	if err := s.DeleteBucket("backup"); !os.IsNotExist(err) {
+		t.Errorf("expected a not-exist error for a missing bucket but got %v", err)
+	}
+}
+
+func testConformanceBucketInfo(t *testing.T, s storage.Storage) {
+	before := time.Now().Add(-time.Second)
+	mustCreateBucket(t, s, "album")
+
+	info, err := s.GetBucketInfo("album")
+	if err != nil {
+		t.Fatalf("GetBucketInfo: %v", err)
+	}
+	if info.Name != "album" || info.CreationDate.Before(before) || info.CreationDate.After(time.Now()) {
+		t.Errorf("unexpected bucket info %+v", info)
+	}
+
+	if err := s.PutBucketInfo("album", dto.BucketInfo{Owner: "alice", Region: "eu-west-3"}); err != nil {
+		t.Fatalf("PutBucketInfo: %v", err)
+	}
+	updated, err := s.GetBucketInfo("album")
+	if err != nil || updated.Owner != "alice" || updated.Region != "eu-west-3" || !updated.CreationDate.Equal(info.CreationDate) {
+		t.Errorf("expected owner and region to be stored with the same creation date, got %+v, %v", updated, err)
+	}
+	// Creating the bucket again does not reset its metadata
+	mustCreateBucket(t, s, "album")
+	if again, _ := s.GetBucketInfo("album"); again != updated {
+		t.Errorf("expected %+v after CreateBucket but got %+v", updated, again)
+	}
+
+	if _, err := s.GetBucketInfo("missing"); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("expected ErrNoSuchBucket but got %v", err)
+	}
+	for _, name := range []string{"ab", strings.Repeat("a", 64), "Album", "my_album", "-album", "album-", "my..album", "192.168.1.10", "xn--album", "album-s3alias"} {
+		if err := s.CreateBucket(name); !errors.Is(err, storage.ErrInvalidBucketName) {
+			t.Errorf("%q: expected ErrInvalidBucketName but got %v", name, err)
+		}
+	}
+	for _, name := range []string{"abc", strings.Repeat("a", 63), "my.album-2024", "192.168.1.album"} {
+		if err := s.CreateBucket(name); err != nil {
+			t.Errorf("%q: expected a valid bucket name but got %v", name, err)
+		}
+	}
+}
+
+func testConformanceObjects(t *testing.T, s storage.Storage) {
+	if _, err := s.AddObject("album", "photo.jpg", strings.NewReader("data"), dto.ObjectMetadata{}); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("expected ErrNoSuchBucket but got %v", err)
+	}
+	mustCreateBucket(t, s, "album")
+
+	metadata := dto.ObjectMetadata{ContentType: "image/jpeg", UserMetadata: map[string]string{"camera": "x100"}}
+	info, err := s.AddObject("album", "2024/summer/photo.jpg", strings.NewReader("hello"), metadata)
+	if err != nil {
+		t.Fatalf("AddObject: %v", err)
+	}
+	if info.ETag != md5Hex([]byte("hello")) || info.Size != 5 || info.VersionID != "" {
+		t.Errorf("unexpected object info %+v", info)
+	}
+
+	expectContent(t, s, "album", "2024/summer/photo.jpg", "", "hello")
+	info, err = s.StatObject("album", "2024/summer/photo.jpg", "")
+	if err != nil {
+		t.Fatalf("StatObject: %v", err)
+	}
+	if info.Size != 5 || info.ContentType != "image/jpeg" || info.UserMetadata["camera"] != "x100" || info.LastModified.IsZero() {
+		t.Errorf("unexpected object info %+v", info)
+	}
+
+	// Overwrite
+	mustPut(t, s, "album", "2024/summer/photo.jpg", "bonjour")
+	expectContent(t, s, "album", "2024/summer/photo.jpg", "", "bonjour")
+
+	// Range requests need a seekable reader
+	file, _, err := s.GetObject("album", "2024/summer/photo.jpg", "")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	if _, err := file.Seek(3, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	rest, _ := io.ReadAll(file)
+	file.Close()
+	if string(rest) != "jour" {
+		t.Errorf("expected %q after seeking but got %q", "jour", rest)
+	}
+
+	for _, key := range []string{"", "a/../b", "a//b", "./a"} {
+		if _, err := s.AddObject("album", key, strings.NewReader("x"), dto.ObjectMetadata{}); !errors.Is(err, storage.ErrInvalidObjectName) {
+			t.Errorf("%q: expected ErrInvalidObjectName but got %v", key, err)
+		}
+	}
+
+	if _, _, err := s.GetObject("album", "missing.jpg", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not-exist error but got %v", err)
+	}
+	if _, err := s.StatObject("album", "missing.jpg", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not-exist error but got %v", err)
+	}
+
+	if _, err := s.DeleteObject("album", "2024/summer/photo.jpg", ""); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if _, err := s.StatObject("album", "2024/summer/photo.jpg", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("object should be deleted, got %v", err)
+	}
+	if _, err := s.DeleteObject("album", "2024/summer/photo.jpg", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not-exist error but got %v", err)
+	}
+}
+
+func testConformanceCopy(t *testing.T, s storage.Storage) {
+	mustCreateBucket(t, s, "album")
+	mustCreateBucket(t, s, "backup")
+	metadata := dto.ObjectMetadata{ContentType: "image/png", UserMetadata: map[string]string{"author": "alice"}}
+	if _, err := s.AddObject("album", "a.png", strings.NewReader("pixels"), metadata); err != nil {
+		t.Fatalf("AddObject: %v", err)
+	}
+
+	info, err := s.CopyObject("album", "a.png", "", "backup", "2024/a.png", nil)
+	if err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	if info.ETag != md5Hex([]byte("pixels")) || info.ContentType != "image/png" || info.UserMetadata["author"] != "alice" {
+		t.Errorf("copy should keep the ETag and metadata, got %+v", info)
+	}
+	expectContent(t, s, "backup", "2024/a.png", "", "pixels")
+
+	// Copy onto itself to replace the metadata
+	replaced := dto.ObjectMetadata{ContentType: "image/webp"}
+	if _, err := s.CopyObject("album", "a.png", "", "album", "a.png", &replaced); err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	expectContent(t, s, "album", "a.png", "", "pixels")
+	info, _ = s.StatObject("album", "a.png", "")
+	if info.ContentType != "image/webp" || len(info.UserMetadata) != 0 {
+		t.Errorf("metadata should be replaced, got %+v", info.ObjectMetadata)
+	}
+
+	if _, err := s.CopyObject("album", "missing.png", "", "backup", "b.png", nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not-exist error but got %v", err)
+	}
+	if _, err := s.CopyObject("album", "a.png", "", "nowhere", "b.png", nil); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("expected ErrNoSuchBucket but got %v", err)
+	}
+}
+
+func testConformanceCopyVersion(t *testing.T, s storage.Storage) {
+	mustCreateBucket(t, s, "album")
+	if err := s.PutBucketVersioning("album", storage.VersioningEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	v1 := mustPut(t, s, "album", "photo.jpg", "first")
+	mustPut(t, s, "album", "photo.jpg", "second")
+
+	// Restoring an old version copies it over the current one
+	info, err := s.CopyObject("album", "photo.jpg", v1.VersionID, "album", "photo.jpg", nil)
+	if err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	if info.ETag != md5Hex([]byte("first")) || info.VersionID == v1.VersionID {
+		t.Errorf("copy should be a new version with the source ETag, got %+v", info)
+	}
+	expectContent(t, s, "album", "photo.jpg", "", "first")
+
+	deleted, err := s.DeleteObject("album", "photo.jpg", "")
+	if err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if _, err := s.CopyObject("album", "photo.jpg", deleted.VersionID, "album", "copy.jpg", nil); !errors.Is(err, storage.ErrDeleteMarker) {
+		t.Errorf("expected ErrDeleteMarker but got %v", err)
+	}
+	if _, err := s.CopyObject("album", "photo.jpg", "unknown", "album", "copy.jpg", nil); !errors.Is(err, storage.ErrNoSuchVersion) {
+		t.Errorf("expected ErrNoSuchVersion but got %v", err)
+	}
+}
+
+func testConformanceMove(t *testing.T, s storage.Storage) {
+	mustCreateBucket(t, s, "album")
+	mustCreateBucket(t, s, "backup")
+	metadata := dto.ObjectMetadata{ContentType: "image/png", UserMetadata: map[string]string{"author": "alice"}}
+	if _, err := s.AddObject("album", "2024/a.png", strings.NewReader("pixels"), metadata); err != nil {
+		t.Fatalf("AddObject: %v", err)
+	}
+
+	info, err := s.MoveObject("album", "2024/a.png", "backup", "2024/a.png", nil)
+	if err != nil {
+		t.Fatalf("MoveObject: %v", err)
+	}
+	if info.ETag != md5Hex([]byte("pixels")) || info.ContentType != "image/png" || info.UserMetadata["author"] != "alice" {
+		t.Errorf("move should keep the ETag and metadata, got %+v", info)
+	}
+	expectContent(t, s, "backup", "2024/a.png", "", "pixels")
+	if _, err := s.StatObject("album", "2024/a.png", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("source should be removed, got %v", err)
+	}
+
+	if _, err := s.MoveObject("album", "2024/a.png", "backup", "b.png", nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not-exist error but got %v", err)
+	}
+	if _, err := s.MoveObject("backup", "2024/a.png", "nowhere", "2024/a.png", nil); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("expected ErrNoSuchBucket but got %v", err)
+	}
+	if _, err := s.MoveObject("backup", "2024/a.png", "backup", "2024/a.png", nil); !errors.Is(err, storage.ErrMoveOntoItself) {
+		t.Errorf("expected ErrMoveOntoItself but got %v", err)
+	}
+	expectContent(t, s, "backup", "2024/a.png", "", "pixels")
+
+	// Moving out of a versioned bucket leaves a delete marker and keeps the history
+	if err := s.PutBucketVersioning("backup", storage.VersioningEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	v1 := mustPut(t, s, "backup", "2024/a.png", "new pixels")
+	if _, err := s.MoveObject("backup", "2024/a.png", "album", "a.png", nil); err != nil {
+		t.Fatalf("MoveObject: %v", err)
+	}
+	expectContent(t, s, "album", "a.png", "", "new pixels")
+	expectContent(t, s, "backup", "2024/a.png", v1.VersionID, "new pixels")
+	if _, err := s.StatObject("backup", "2024/a.png", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("source should be behind a delete marker, got %v", err)
+	}
+}
+
+func testConformanceTagging(t *testing.T, s storage.Storage) {
+	mustCreateBucket(t, s, "album")
+	if err := s.PutBucketVersioning("album", storage.VersioningEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	first, err := s.AddObject("album", "photo.jpg", strings.NewReader("first"), dto.ObjectMetadata{Tags: map[string]string{"kind": "raw"}})
+	if err != nil {
+		t.Fatalf("AddObject: %v", err)
+	}
+	mustPut(t, s, "album", "photo.jpg", "second")
+
+	// Tags belong to a version and are copied with the metadata
+	if err := s.UpdateObjectMetadata("album", "photo.jpg", "", func(metadata *dto.ObjectMetadata) error {
+		metadata.Tags = map[string]string{"kind": "edited", "year": "2024"}
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateObjectMetadata: %v", err)
+	}
+	if info, err := s.StatObject("album", "photo.jpg", first.VersionID); err != nil || len(info.Tags) != 1 || info.Tags["kind"] != "raw" {
+		t.Errorf("unexpected tags of the first version %v, %v", info.Tags, err)
+	}
+	if info, err := s.StatObject("album", "photo.jpg", ""); err != nil || len(info.Tags) != 2 || info.Tags["year"] != "2024" {
+		t.Errorf("unexpected tags of the current version %v, %v", info.Tags, err)
+	}
+	if _, err := s.CopyObject("album", "photo.jpg", first.VersionID, "album", "copy.jpg", nil); err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	if info, err := s.StatObject("album", "copy.jpg", ""); err != nil || info.Tags["kind"] != "raw" {
+		t.Errorf("expected the copy to keep the tags of the source, got %v, %v", info.Tags, err)
+	}
+}
+
+func testConformanceListing(t *testing.T, s storage.Storage) {
+	if _, err := s.ListObjects("album", "", "", "", 10); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("expected ErrNoSuchBucket but got %v", err)
+	}
+	mustCreateBucket(t, s, "album")
+	for _, key := range []string{"cover.jpg", "2023/a.jpg", "2023/b.jpg", "2024/a.jpg", "2024/summer/b.jpg", "2024-notes.txt"} {
+		mustPut(t, s, "album", key, key)
+	}
+
+	var pages [][]string
+	marker := ""
+	for {
+		response, err := s.ListObjects("album", "", "/", marker, 2)
+		if err != nil {
+			t.Fatalf("ListObjects: %v", err)
+		}
+		var page []string
+		for _, prefix := range response.CommonPrefixes {
+			page = append(page, prefix.Prefix)
+		}
+		for _, object := range response.Contents {
+			page = append(page, object.Key)
+		}
+		pages = append(pages, page)
+		if !response.IsTruncated {
+			break
+		}
+		marker = response.NextMarker
+	}
+	expected := "2023/,2024-notes.txt|2024/,cover.jpg"
+	if got := joinPages(pages); got != expected {
+		t.Errorf("expected pages %s but got %s", expected, got)
+	}
+
+	response, err := s.ListObjects("album", "2024/", "", "", 10)
+	if err != nil {
+		t.Fatalf("ListObjects: %v", err)
+	}
+	var keys []string
+	for _, object := range response.Contents {
+		keys = append(keys, object.Key)
+		if object.ETag != `"`+md5Hex([]byte(object.Key))+`"` || object.Size != len(object.Key) {
+			t.Errorf("unexpected listing entry %+v", object)
+		}
+	}
+	if strings.Join(keys, ",") != "2024/a.jpg,2024/summer/b.jpg" || response.IsTruncated {
+		t.Errorf("unexpected listing %v (truncated %v)", keys, response.IsTruncated)
+	}
+}
+
+func joinPages(pages [][]string) string {
+	joined := make([]string, len(pages))
+	for i, page := range pages {
+		joined[i] = strings.Join(page, ",")
+	}
+	return strings.Join(joined, "|")
+}
+
+func testConformanceVersioning(t *testing.T, s storage.Storage) {
+	mustCreateBucket(t, s, "album")
+	mustPut(t, s, "album", "photo.jpg", "v0")
+
+	if status, err := s.GetBucketVersioning("album"); err != nil || status != "" {
+		t.Fatalf("expected no versioning, got %q, %v", status, err)
+	}
+	if err := s.PutBucketVersioning("album", "Maybe"); !errors.Is(err, storage.ErrInvalidVersioning) {
+		t.Errorf("expected ErrInvalidVersioning but got %v", err)
+	}
+	if err := s.PutBucketVersioning("album", storage.VersioningEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+
+	v1 := mustPut(t, s, "album", "photo.jpg", "v1")
+	v2 := mustPut(t, s, "album", "photo.jpg", "v2")
+	if v1.VersionID == "" || v2.VersionID == "" || v1.VersionID == v2.VersionID {
+		t.Fatalf("expected distinct version ids, got %q and %q", v1.VersionID, v2.VersionID)
+	}
+	expectContent(t, s, "album", "photo.jpg", "", "v2")
+	expectContent(t, s, "album", "photo.jpg", v1.VersionID, "v1")
+	expectContent(t, s, "album", "photo.jpg", "null", "v0")
+	if _, err := s.StatObject("album", "photo.jpg", "0123456789abcdef0123456789abcdef"); !errors.Is(err, storage.ErrNoSuchVersion) {
+		t.Errorf("expected ErrNoSuchVersion but got %v", err)
+	}
+
+	// Deleting without a version adds a delete marker
+	result, err := s.DeleteObject("album", "photo.jpg", "")
+	if err != nil || !result.DeleteMarker || result.VersionID == "" {
+		t.Fatalf("expected a delete marker, got %+v, %v", result, err)
+	}
+	if _, err := s.StatObject("album", "photo.jpg", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not-exist error but got %v", err)
+	}
+	if _, err := s.StatObject("album", "photo.jpg", result.VersionID); !errors.Is(err, storage.ErrDeleteMarker) {
+		t.Errorf("expected ErrDeleteMarker but got %v", err)
+	}
+	if response, _ := s.ListObjects("album", "", "", "", 10); len(response.Contents) != 0 {
+		t.Errorf("deleted object should not be listed, got %+v", response.Contents)
+	}
+
+	versions, err := s.ListObjectVersions("album", "", "", "", 10)
+	if err != nil {
+		t.Fatalf("ListObjectVersions: %v", err)
+	}
+	if len(versions.DeleteMarkers) != 1 || !versions.DeleteMarkers[0].IsLatest || len(versions.Versions) != 3 {
+		t.Fatalf("unexpected versions %+v", versions)
+	}
+	var ids []string
+	for _, version := range versions.Versions {
+		ids = append(ids, version.VersionId)
+	}
+	if strings.Join(ids, ",") != strings.Join([]string{v2.VersionID, v1.VersionID, "null"}, ",") {
+		t.Errorf("versions should be listed newest first, got %v", ids)
+	}
+
+	// Paginated version listing
+	page, err := s.ListObjectVersions("album", "", "", "", 2)
+	if err != nil || !page.IsTruncated || page.NextKeyMarker != "photo.jpg" || page.NextVersionIdMarker != v2.VersionID {
+		t.Fatalf("unexpected first page %+v, %v", page, err)
+	}
+	page, err = s.ListObjectVersions("album", "", page.NextKeyMarker, page.NextVersionIdMarker, 2)
+	if err != nil || page.IsTruncated || len(page.Versions) != 2 || page.Versions[0].VersionId != v1.VersionID {
+		t.Fatalf("unexpected second page %+v, %v", page, err)
+	}
+
+	// Removing the delete marker restores the object
+	removed, err := s.DeleteObject("album", "photo.jpg", result.VersionID)
+	if err != nil || !removed.DeleteMarker {
+		t.Fatalf("expected the delete marker to be removed, got %+v, %v", removed, err)
+	}
+	expectContent(t, s, "album", "photo.jpg", "", "v2")
+
+	// Removing the current version makes the previous one current
+	if _, err := s.DeleteObject("album", "photo.jpg", v2.VersionID); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	expectContent(t, s, "album", "photo.jpg", "", "v1")
+
+	// While suspended, new writes replace the "null" version
+	if err := s.PutBucketVersioning("album", storage.VersioningSuspended); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	info := mustPut(t, s, "album", "photo.jpg", "v3")
+	if info.VersionID != "null" {
+		t.Errorf("expected version null but got %q", info.VersionID)
+	}
+	expectContent(t, s, "album", "photo.jpg", "null", "v3")
+	expectContent(t, s, "album", "photo.jpg", v1.VersionID, "v1")
+	versions, _ = s.ListObjectVersions("album", "", "", "", 10)
+	if len(versions.Versions) != 2 {
+		t.Errorf("expected 2 versions but got %+v", versions.Versions)
+	}
+}
+
+func testConformanceMultipart(t *testing.T, s storage.Storage) {
+	if _, err := s.CreateMultipartUpload("album", "video.mp4", dto.ObjectMetadata{}); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("expected ErrNoSuchBucket but got %v", err)
+	}
+	mustCreateBucket(t, s, "album")
+
+	uploadID, err := s.CreateMultipartUpload("album", "video.mp4", dto.ObjectMetadata{ContentType: "video/mp4"})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	if metadata, err := s.MultipartUploadMetadata("album", "video.mp4", uploadID); err != nil || metadata.ContentType != "video/mp4" {
+		t.Errorf("unexpected upload metadata %+v, %v", metadata, err)
+	}
+	if _, err := s.MultipartUploadMetadata("album", "other.mp4", uploadID); !errors.Is(err, storage.ErrNoSuchUpload) {
+		t.Errorf("expected ErrNoSuchUpload but got %v", err)
+	}
+
+	part1 := bytes.Repeat([]byte("a"), 5<<20)
+	part2 := []byte("tail")
+	etag1, err := s.UploadPart("album", "video.mp4", uploadID, 1, bytes.NewReader(part1))
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	etag2, err := s.UploadPart("album", "video.mp4", uploadID, 2, bytes.NewReader(part2))
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	if etag1 != md5Hex(part1) || etag2 != md5Hex(part2) {
+		t.Errorf("part ETags should be the MD5 of their content")
+	}
+	if _, err := s.UploadPart("album", "video.mp4", uploadID, 0, strings.NewReader("x")); !errors.Is(err, storage.ErrInvalidPartNumber) {
+		t.Errorf("expected ErrInvalidPartNumber but got %v", err)
+	}
+	if _, err := s.UploadPart("album", "other.mp4", uploadID, 1, strings.NewReader("x")); !errors.Is(err, storage.ErrNoSuchUpload) {
+		t.Errorf("expected ErrNoSuchUpload but got %v", err)
+	}
+
+	parts, err := s.ListParts("album", "video.mp4", uploadID, 0, 1)
+	if err != nil || len(parts.Parts) != 1 || !parts.IsTruncated || parts.NextPartNumberMarker != 1 {
+		t.Fatalf("unexpected parts %+v, %v", parts, err)
+	}
+	parts, err = s.ListParts("album", "video.mp4", uploadID, 1, 10)
+	if err != nil || len(parts.Parts) != 1 || parts.Parts[0].PartNumber != 2 || parts.Parts[0].Size != 4 {
+		t.Fatalf("unexpected parts %+v, %v", parts, err)
+	}
+
+	uploads, err := s.ListMultipartUploads("album", "", "", "", 10)
+	if err != nil || len(uploads.Uploads) != 1 || uploads.Uploads[0].UploadId != uploadID {
+		t.Fatalf("unexpected uploads %+v, %v", uploads, err)
+	}
+
+	invalid := []struct {
+		parts    []dto.CompletedPart
+		expected error
+	}{
+		{nil, storage.ErrInvalidPart},
+		{[]dto.CompletedPart{{PartNumber: 1, ETag: etag1}, {PartNumber: 1, ETag: etag1}}, storage.ErrInvalidPartOrder},
+		{[]dto.CompletedPart{{PartNumber: 1, ETag: etag2}}, storage.ErrInvalidPart},
+		{[]dto.CompletedPart{{PartNumber: 1, ETag: etag1}, {PartNumber: 3, ETag: etag2}}, storage.ErrInvalidPart},
+		{[]dto.CompletedPart{{PartNumber: 3, ETag: etag2}}, storage.ErrInvalidPart},
+	}
+	for _, tt := range invalid {
+		if _, err := s.CompleteMultipartUpload("album", "video.mp4", uploadID, tt.parts); !errors.Is(err, tt.expected) {
+			t.Errorf("%+v: expected %v but got %v", tt.parts, tt.expected, err)
+		}
+	}
+
+	small, err := s.CreateMultipartUpload("album", "small.bin", dto.ObjectMetadata{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	s.UploadPart("album", "small.bin", small, 1, bytes.NewReader(part2))
+	s.UploadPart("album", "small.bin", small, 2, bytes.NewReader(part2))
+	completed := []dto.CompletedPart{{PartNumber: 1, ETag: etag2}, {PartNumber: 2, ETag: etag2}}
+	if _, err := s.CompleteMultipartUpload("album", "small.bin", small, completed); !errors.Is(err, storage.ErrEntityTooSmall) {
+		t.Errorf("expected ErrEntityTooSmall but got %v", err)
+	}
+	if err := s.AbortMultipartUpload("album", "small.bin", small); err != nil {
+		t.Fatalf("AbortMultipartUpload: %v", err)
+	}
+	if _, err := s.ListParts("album", "small.bin", small, 0, 10); !errors.Is(err, storage.ErrNoSuchUpload) {
+		t.Errorf("expected ErrNoSuchUpload after abort but got %v", err)
+	}
+
+	info, err := s.CompleteMultipartUpload("album", "video.mp4", uploadID, []dto.CompletedPart{{PartNumber: 1, ETag: `"` + etag1 + `"`}, {PartNumber: 2, ETag: etag2}})
+	if err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+	if !strings.HasSuffix(info.ETag, "-2") || info.Size != int64(len(part1)+len(part2)) || info.ContentType != "video/mp4" {
+		t.Errorf("unexpected object info %+v", info)
+	}
+	expectContent(t, s, "album", "video.mp4", "", string(part1)+string(part2))
+	if uploads, _ := s.ListMultipartUploads("album", "", "", "", 10); len(uploads.Uploads) != 0 {
+		t.Errorf("completed upload should not be listed, got %+v", uploads.Uploads)
+	}
+}
+
+func testConformanceLifecycle(t *testing.T, s storage.Storage) {
+	if _, err := s.GetBucketLifecycle("album"); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("expected ErrNoSuchBucket but got %v", err)
+	}
+	mustCreateBucket(t, s, "album")
+	if _, err := s.GetBucketLifecycle("album"); !errors.Is(err, storage.ErrNoSuchLifecycle) {
+		t.Errorf("expected ErrNoSuchLifecycle but got %v", err)
+	}
+
+	prefix := "tmp/"
+	config := dto.LifecycleConfiguration{Rules: []dto.LifecycleRule{{
+		ID:         "scratch",
+		Status:     "Enabled",
+		Filter:     &dto.LifecycleFilter{Prefix: &prefix},
+		Expiration: &dto.LifecycleExpiration{Days: 1},
+	}}}
+	if err := s.PutBucketLifecycle("album", config); err != nil {
+		t.Fatalf("PutBucketLifecycle: %v", err)
+	}
+	stored, err := s.GetBucketLifecycle("album")
+	if err != nil || len(stored.Rules) != 1 || stored.Rules[0].ID != "scratch" || *stored.Rules[0].Filter.Prefix != prefix || stored.Rules[0].Expiration.Days != 1 {
+		t.Fatalf("unexpected lifecycle %+v, %v", stored, err)
+	}
+
+	if err := s.DeleteBucketLifecycle("album"); err != nil {
+		t.Fatalf("DeleteBucketLifecycle: %v", err)
+	}
+	if _, err := s.GetBucketLifecycle("album"); !errors.Is(err, storage.ErrNoSuchLifecycle) {
+		t.Errorf("expected ErrNoSuchLifecycle after delete but got %v", err)
+	}
+}
+
+func testConformanceCORS(t *testing.T, s storage.Storage) {
+	if _, err := s.GetBucketCors("album"); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("expected ErrNoSuchBucket but got %v", err)
+	}
+	mustCreateBucket(t, s, "album")
+	if _, err := s.GetBucketCors("album"); !errors.Is(err, storage.ErrNoSuchCORS) {
+		t.Errorf("expected ErrNoSuchCORS but got %v", err)
+	}
+
+	maxAge := 600
+	config := dto.CORSConfiguration{Rules: []dto.CORSRule{{
+		AllowedOrigins: []string{"https://*.example.com"},
+		AllowedMethods: []string{"GET", "PUT"},
+		MaxAgeSeconds:  &maxAge,
+	}}}
+	if err := s.PutBucketCors("album", config); err != nil {
+		t.Fatalf("PutBucketCors: %v", err)
+	}
+	stored, err := s.GetBucketCors("album")
+	if err != nil || len(stored.Rules) != 1 || stored.Rules[0].AllowedOrigins[0] != "https://*.example.com" || *stored.Rules[0].MaxAgeSeconds != maxAge {
+		t.Fatalf("unexpected CORS configuration %+v, %v", stored, err)
+	}
+
+	if err := s.DeleteBucketCors("album"); err != nil {
+		t.Fatalf("DeleteBucketCors: %v", err)
+	}
+	if _, err := s.GetBucketCors("album"); !errors.Is(err, storage.ErrNoSuchCORS) {
+		t.Errorf("expected ErrNoSuchCORS after delete but got %v", err)
+	}
+}
+
+func testConformanceBucketPolicy(t *testing.T, s storage.Storage) {
+	if _, err := s.GetBucketPolicy("album"); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("expected ErrNoSuchBucket but got %v", err)
+	}
+	mustCreateBucket(t, s, "album")
+	if _, err := s.GetBucketPolicy("album"); !errors.Is(err, storage.ErrNoSuchBucketPolicy) {
+		t.Errorf("expected ErrNoSuchBucketPolicy but got %v", err)
+	}
+
+	bucketPolicy := dto.BucketPolicy{Version: "2012-10-17", Statement: []dto.PolicyStatement{{
+		Effect:    "Allow",
+		Principal: &dto.Principal{AWS: dto.StringList{"*"}},
+		Action:    dto.StringList{"s3:GetObject"},
+		Resource:  dto.StringList{"arn:aws:s3:::album/*"},
+	}}}
+	if err := s.PutBucketPolicy("album", bucketPolicy); err != nil {
+		t.Fatalf("PutBucketPolicy: %v", err)
+	}
+	stored, err := s.GetBucketPolicy("album")
+	if err != nil || len(stored.Statement) != 1 || stored.Statement[0].Resource[0] != "arn:aws:s3:::album/*" || stored.Statement[0].Principal.AWS[0] != "*" {
+		t.Fatalf("unexpected policy %+v, %v", stored, err)
+	}
+
+	// The canned ACL of the bucket is kept with its other metadata
+	if err := s.PutBucketInfo("album", dto.BucketInfo{Owner: "owner", ACL: "public-read"}); err != nil {
+		t.Fatalf("PutBucketInfo: %v", err)
+	}
+	if info, err := s.GetBucketInfo("album"); err != nil || info.ACL != "public-read" {
+		t.Errorf("expected ACL public-read but got %+v, %v", info, err)
+	}
+
+	if err := s.DeleteBucketPolicy("album"); err != nil {
+		t.Fatalf("DeleteBucketPolicy: %v", err)
+	}
+	if _, err := s.GetBucketPolicy("album"); !errors.Is(err, storage.ErrNoSuchBucketPolicy) {
+		t.Errorf("expected ErrNoSuchBucketPolicy after delete but got %v", err)
+	}
+}
+
+func testConformanceNotification(t *testing.T, s storage.Storage) {
+	if _, err := s.GetBucketNotification("album"); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("expected ErrNoSuchBucket but got %v", err)
+	}
+	mustCreateBucket(t, s, "album")
+	if config, err := s.GetBucketNotification("album"); err != nil || len(config.Webhooks) != 0 {
+		t.Errorf("expected an empty configuration but got %+v, %v", config, err)
+	}
+
+	config := dto.NotificationConfiguration{Webhooks: []dto.WebhookConfiguration{{
+		ID:       "gallery",
+		Endpoint: "http://gallery.local/events",
+		Events:   []string{"s3:ObjectCreated:*"},
+		Filter:   &dto.NotificationFilter{Key: dto.KeyFilter{Rules: []dto.FilterRule{{Name: "suffix", Value: ".jpg"}}}},
+	}}}
+	if err := s.PutBucketNotification("album", config); err != nil {
+		t.Fatalf("PutBucketNotification: %v", err)
+	}
+	stored, err := s.GetBucketNotification("album")
+	if err != nil || len(stored.Webhooks) != 1 || stored.Webhooks[0].Endpoint != "http://gallery.local/events" || stored.Webhooks[0].Filter.Key.Rules[0].Value != ".jpg" {
+		t.Fatalf("unexpected notification configuration %+v, %v", stored, err)
+	}
+
+	// An empty configuration disables the notifications
+	if err := s.PutBucketNotification("album", dto.NotificationConfiguration{}); err != nil {
+		t.Fatalf("PutBucketNotification: %v", err)
+	}
+	if config, err := s.GetBucketNotification("album"); err != nil || len(config.Webhooks) != 0 {
+		t.Errorf("expected an empty configuration after reset but got %+v, %v", config, err)
+	}
+}
+
+// Test that the content-addressed backend stores identical photos only once
+func TestContentAddressedDeduplication(t *testing.T) {
+	root := t.TempDir()
+	s, err := storage.NewBackend("cas", storage.BackendConfig{Root: root})
+	if err != nil {
+		t.Fatalf("could not create backend: %v", err)
+	}
+	mustCreateBucket(t, s, "album")
+	mustCreateBucket(t, s, "backup")
+
+	mustPut(t, s, "album", "2024/photo.jpg", "same pixels")
+	mustPut(t, s, "backup", "photo-copy.jpg", "same pixels")
+	mustPut(t, s, "album", "other.jpg", "other pixels")
+
+	first, _ := os.Stat(filepath.Join(root, "album", "2024", "photo.jpg"))
+	second, _ := os.Stat(filepath.Join(root, "backup", "photo-copy.jpg"))
+	other, _ := os.Stat(filepath.Join(root, "album", "other.jpg"))
+	if first == nil || second == nil || other == nil {
+		t.Fatalf("objects should be stored under the configured root")
+	}
+	if !os.SameFile(first, second) {
+		t.Errorf("identical contents should share the same blob")
+	}
+	if os.SameFile(first, other) {
+		t.Errorf("different contents should not share a blob")
+	}
+	if n := countBlobs(t, root); n != 2 {
+		t.Errorf("expected 2 blobs but got %d", n)
+	}
+
+	// A blob is kept while an object still references it
+	if _, err := s.DeleteObject("album", "2024/photo.jpg", ""); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	expectContent(t, s, "backup", "photo-copy.jpg", "", "same pixels")
+	if n := countBlobs(t, root); n != 2 {
+		t.Errorf("expected 2 blobs but got %d", n)
+	}
+
+	// Overwriting and deleting the last references frees the blobs
+	mustPut(t, s, "backup", "photo-copy.jpg", "other pixels")
+	if n := countBlobs(t, root); n != 1 {
+		t.Errorf("expected 1 blob but got %d", n)
+	}
+	if err := s.DeleteBucket("album"); err != nil {
+		t.Fatalf("DeleteBucket: %v", err)
+	}
+	expectContent(t, s, "backup", "photo-copy.jpg", "", "other pixels")
+	if _, err := s.DeleteObject("backup", "photo-copy.jpg", ""); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if n := countBlobs(t, root); n != 0 {
+		t.Errorf("expected no blob left but got %d", n)
+	}
+}
+
+func countBlobs(t *testing.T, root string) int {
+	t.Helper()
+	count := 0
+	filepath.WalkDir(filepath.Join(root, ".s3clone", "blobs"), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			count++
+		}
+		return nil
+	})
+	return count
+}
@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// Test copying a given version of an object and the x-amz-copy-source-if-* conditions
+func TestCopyObjectSourceVersionAndConditions(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	mustCreateBucket(t, s, "family")
+	mustCreateBucket(t, s, "shared")
+	if err := s.PutBucketVersioning("family", storage.VersioningEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	v1 := mustPut(t, s, "family", "photo.jpg", "first")
+	v2 := mustPut(t, s, "family", "photo.jpg", "second")
+	r := router.SetupRouterWithStorage(s)
+
+	copyObject := func(copySource string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PUT", "/shared/photo.jpg", nil)
+		req.Header.Set("X-Amz-Copy-Source", copySource)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := copyObject("/family/photo.jpg?versionId="+v1.VersionID, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("x-amz-copy-source-version-id") != v1.VersionID {
+		t.Errorf("expected x-amz-copy-source-version-id %q but got %q", v1.VersionID, rr.Header().Get("x-amz-copy-source-version-id"))
+	}
+	var result dto.CopyObjectResult
+	if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil || result.ETag != `"`+md5Hex([]byte("first"))+`"` {
+		t.Errorf("unexpected CopyObjectResult %q: %v", rr.Body.String(), err)
+	}
+	expectContent(t, s, "shared", "photo.jpg", "", "first")
+
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	etag := `"` + v2.ETag + `"`
+
+	tests := []struct {
+		name         string
+		copySource   string
+		headers      map[string]string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"if-match", "/family/photo.jpg", map[string]string{"X-Amz-Copy-Source-If-Match": etag}, http.StatusOK, ""},
+		{"if-match fails", "/family/photo.jpg", map[string]string{"X-Amz-Copy-Source-If-Match": `"other"`}, http.StatusPreconditionFailed, "PreconditionFailed"},
+		{"if-match wins over if-unmodified-since", "/family/photo.jpg", map[string]string{"X-Amz-Copy-Source-If-Match": etag, "X-Amz-Copy-Source-If-Unmodified-Since": past}, http.StatusOK, ""},
+		{"if-unmodified-since fails", "/family/photo.jpg", map[string]string{"X-Amz-Copy-Source-If-Unmodified-Since": past}, http.StatusPreconditionFailed, "PreconditionFailed"},
+		{"if-none-match fails", "/family/photo.jpg", map[string]string{"X-Amz-Copy-Source-If-None-Match": etag}, http.StatusPreconditionFailed, "PreconditionFailed"},
+		{"if-none-match on another version", "/family/photo.jpg?versionId=" + v1.VersionID, map[string]string{"X-Amz-Copy-Source-If-None-Match": etag}, http.StatusOK, ""},
+		{"if-modified-since fails", "/family/photo.jpg", map[string]string{"X-Amz-Copy-Source-If-Modified-Since": future}, http.StatusPreconditionFailed, "PreconditionFailed"},
+		{"unknown version", "/family/photo.jpg?versionId=unknown", nil, http.StatusNotFound, "NoSuchVersion"},
+		{"empty version", "/family/photo.jpg?versionId=", nil, http.StatusBadRequest, "InvalidArgument"},
+	}
+	for _, tt := range tests {
+		rr := copyObject(tt.copySource, tt.headers)
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d: %s", tt.name, tt.expectedCode, rr.Code, rr.Body.String())
+			continue
+		}
+		if tt.expectedErr != "" {
+			if code := errorCode(t, rr); code != tt.expectedErr {
+				t.Errorf("%s: expected error code %s but got %s", tt.name, tt.expectedErr, code)
+			}
+		}
+	}
+
+	// A delete marker cannot be copied
+	deleted, err := s.DeleteObject("family", "photo.jpg", "")
+	if err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	rr = copyObject("/family/photo.jpg?versionId="+deleted.VersionID, map[string]string{"X-Amz-Copy-Source-If-Match": etag})
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidRequest" {
+		t.Errorf("expected InvalidRequest for a delete marker but got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = copyObject("/family/photo.jpg", nil)
+	if rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchKey" {
+		t.Errorf("expected NoSuchKey for a deleted object but got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// Test that UploadPartCopy assembles an object from ranges of another one
+func TestUploadPartCopy(t *testing.T) {
+	for _, backend := range storage.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			s, err := storage.NewBackend(backend, storage.BackendConfig{Root: t.TempDir()})
+			if err != nil {
+				t.Fatalf("could not create backend: %v", err)
+			}
+			r := router.SetupRouterWithStorage(s)
+			send := func(method, url string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+				req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+				for name, value := range headers {
+					req.Header.Set(name, value)
+				}
+				rr := httptest.NewRecorder()
+				r.ServeHTTP(rr, req)
+				return rr
+			}
+
+			// The first part must be at least 5 MiB
+			content := testContent(5<<20 + 1000)
+			send("PUT", "/family/", nil, nil)
+			send("PUT", "/shared/", nil, nil)
+			send("PUT", "/family/video.mp4", content, nil)
+
+			rr := send("POST", "/shared/video.mp4?uploads", nil, nil)
+			var initiated dto.InitiateMultipartUploadResult
+			if err := xml.Unmarshal(rr.Body.Bytes(), &initiated); err != nil {
+				t.Fatalf("could not decode CreateMultipartUpload response %q: %v", rr.Body.String(), err)
+			}
+			partURL := func(partNumber int) string {
+				return fmt.Sprintf("/shared/video.mp4?partNumber=%d&uploadId=%s", partNumber, initiated.UploadId)
+			}
+
+			for _, tt := range []struct {
+				name         string
+				copyRange    string
+				expectedCode int
+				expectedErr  string
+			}{
+				{"open range", "bytes=100-", http.StatusBadRequest, "InvalidArgument"},
+				{"reversed range", "bytes=100-10", http.StatusBadRequest, "InvalidArgument"},
+				{"range past the end", fmt.Sprintf("bytes=0-%d", len(content)), http.StatusRequestedRangeNotSatisfiable, "InvalidRange"},
+			} {
+				rr := send("PUT", partURL(1), nil, map[string]string{"X-Amz-Copy-Source": "/family/video.mp4", "X-Amz-Copy-Source-Range": tt.copyRange})
+				if rr.Code != tt.expectedCode || errorCode(t, rr) != tt.expectedErr {
+					t.Errorf("%s: expected %s but got %d: %s", tt.name, tt.expectedErr, rr.Code, rr.Body.String())
+				}
+			}
+
+			var parts []dto.CompletedPart
+			for i, copyRange := range []string{fmt.Sprintf("bytes=0-%d", 5<<20-1), fmt.Sprintf("bytes=%d-%d", 5<<20, len(content)-1)} {
+				rr := send("PUT", partURL(i+1), nil, map[string]string{"X-Amz-Copy-Source": "/family/video.mp4", "X-Amz-Copy-Source-Range": copyRange})
+				if rr.Code != http.StatusOK {
+					t.Fatalf("UploadPartCopy: expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+				}
+				var result dto.CopyPartResult
+				if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+					t.Fatalf("could not decode CopyPartResult %q: %v", rr.Body.String(), err)
+				}
+				parts = append(parts, dto.CompletedPart{PartNumber: i + 1, ETag: result.ETag})
+			}
+
+			body, _ := xml.Marshal(dto.CompleteMultipartUpload{Parts: parts})
+			rr = send("POST", "/shared/video.mp4?uploadId="+initiated.UploadId, body, nil)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("CompleteMultipartUpload: expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			rr = send("GET", "/shared/video.mp4", nil, nil)
+			if !bytes.Equal(rr.Body.Bytes(), content) {
+				t.Errorf("assembled object does not match the source")
+			}
+
+			// The copy source conditions also apply to UploadPartCopy
+			rr = send("PUT", partURL(3), nil, map[string]string{"X-Amz-Copy-Source": "/family/video.mp4", "X-Amz-Copy-Source-If-None-Match": `"` + md5Hex(content) + `"`})
+			if rr.Code != http.StatusPreconditionFailed {
+				t.Errorf("expected status %d but got %d", http.StatusPreconditionFailed, rr.Code)
+			}
+		})
+	}
+}
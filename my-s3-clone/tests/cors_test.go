@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+const corsConfiguration = `<CORSConfiguration>
+  <CORSRule>
+    <AllowedOrigin>https://*.example.com</AllowedOrigin>
+    <AllowedMethod>GET</AllowedMethod>
+    <AllowedMethod>PUT</AllowedMethod>
+    <AllowedHeader>Content-*</AllowedHeader>
+    <AllowedHeader>x-amz-date</AllowedHeader>
+    <ExposeHeader>ETag</ExposeHeader>
+    <MaxAgeSeconds>3000</MaxAgeSeconds>
+  </CORSRule>
+  <CORSRule>
+    <AllowedOrigin>*</AllowedOrigin>
+    <AllowedMethod>GET</AllowedMethod>
+  </CORSRule>
+</CORSConfiguration>`
+
+// Test the CORS configuration routes and their validation
+func TestBucketCorsConfiguration(t *testing.T) {
+	r := router.SetupRouterWithStorage(storage.NewMemoryStorage())
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	send("PUT", "/album/", "")
+
+	if rr := send("GET", "/album/?cors", ""); rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchCORSConfiguration" {
+		t.Errorf("expected NoSuchCORSConfiguration but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		expectedErr string
+	}{
+		{"no rule", "<CORSConfiguration></CORSConfiguration>", "MalformedXML"},
+		{"no origin", "<CORSConfiguration><CORSRule><AllowedMethod>GET</AllowedMethod></CORSRule></CORSConfiguration>", "MalformedXML"},
+		{"unsupported method", "<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>PATCH</AllowedMethod></CORSRule></CORSConfiguration>", "InvalidRequest"},
+		{"two wildcards", "<CORSConfiguration><CORSRule><AllowedOrigin>https://*.*.com</AllowedOrigin><AllowedMethod>GET</AllowedMethod></CORSRule></CORSConfiguration>", "InvalidRequest"},
+		{"not xml", "<CORSConfiguration>", "MalformedXML"},
+	}
+	for _, tt := range tests {
+		rr := send("PUT", "/album/?cors", tt.body)
+		if rr.Code != http.StatusBadRequest || errorCode(t, rr) != tt.expectedErr {
+			t.Errorf("%s: expected %s but got %d: %s", tt.name, tt.expectedErr, rr.Code, rr.Body.String())
+		}
+	}
+
+	if rr := send("PUT", "/album/?cors", corsConfiguration); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rr := send("GET", "/album/?cors", "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "<AllowedOrigin>https://*.example.com</AllowedOrigin>") || !strings.Contains(rr.Body.String(), "<MaxAgeSeconds>3000</MaxAgeSeconds>") {
+		t.Errorf("unexpected CORS configuration %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := send("DELETE", "/album/?cors", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status %d but got %d", http.StatusNoContent, rr.Code)
+	}
+	if rr := send("GET", "/album/?cors", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d after delete but got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+// Test that preflight and actual requests are evaluated against the rules of the target bucket
+func TestCorsRequests(t *testing.T) {
+	r := router.SetupRouterWithStorage(storage.NewMemoryStorage())
+	send := func(method, url, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	send("PUT", "/album/", "", nil)
+	send("PUT", "/private/", "", nil)
+	send("PUT", "/album/?cors", corsConfiguration, nil)
+	send("PUT", "/album/photo.jpg", "photo", nil)
+
+	preflight := func(url, origin, method, headers string) *httptest.ResponseRecorder {
+		return send("OPTIONS", url, "", map[string]string{
+			"Origin":                         origin,
+			"Access-Control-Request-Method":  method,
+			"Access-Control-Request-Headers": headers,
+		})
+	}
+
+	rr := preflight("/album/2024/photo.jpg", "https://app.example.com", "PUT", "content-type, X-Amz-Date")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Methods":     "GET, PUT",
+		"Access-Control-Allow-Headers":     "content-type, X-Amz-Date",
+		"Access-Control-Expose-Headers":    "ETag",
+		"Access-Control-Max-Age":           "3000",
+		"Access-Control-Allow-Credentials": "true",
+	}
+	for name, value := range expected {
+		if got := rr.Header().Get(name); got != value {
+			t.Errorf("%s: expected %q but got %q", name, value, got)
+		}
+	}
+
+	// Any origin may GET, through the second rule
+	rr = preflight("/album/", "https://other.org", "GET", "")
+	if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("expected a wildcard origin but got %d %v", rr.Code, rr.Header())
+	}
+
+	denied := []struct {
+		name, url, origin, method, headers string
+		expectedCode                       int
+		expectedErr                        string
+	}{
+		{"origin not allowed", "/album/photo.jpg", "https://other.org", "PUT", "", http.StatusForbidden, "AccessForbidden"},
+		{"method not allowed", "/album/photo.jpg", "https://app.example.com", "DELETE", "", http.StatusForbidden, "AccessForbidden"},
+		{"header not allowed", "/album/photo.jpg", "https://app.example.com", "PUT", "x-amz-meta-owner", http.StatusForbidden, "AccessForbidden"},
+		{"bucket without CORS", "/private/photo.jpg", "https://app.example.com", "GET", "", http.StatusForbidden, "AccessForbidden"},
+		{"missing bucket", "/missing/photo.jpg", "https://app.example.com", "GET", "", http.StatusNotFound, "NoSuchBucket"},
+		{"missing origin", "/album/photo.jpg", "", "GET", "", http.StatusBadRequest, "BadRequest"},
+	}
+	for _, tt := range denied {
+		rr := preflight(tt.url, tt.origin, tt.method, tt.headers)
+		if rr.Code != tt.expectedCode || errorCode(t, rr) != tt.expectedErr {
+			t.Errorf("%s: expected %d %s but got %d: %s", tt.name, tt.expectedCode, tt.expectedErr, rr.Code, rr.Body.String())
+		}
+		if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("%s: unexpected Access-Control-Allow-Origin %q", tt.name, rr.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+
+	// Actual requests get the headers of the matching rule, and none without a match
+	rr = send("GET", "/album/photo.jpg", "", map[string]string{"Origin": "https://app.example.com"})
+	if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" || rr.Header().Get("Access-Control-Expose-Headers") != "ETag" {
+		t.Errorf("unexpected CORS headers on GET: %d %v", rr.Code, rr.Header())
+	}
+	rr = send("PUT", "/album/photo.jpg", "photo", map[string]string{"Origin": "https://other.org"})
+	if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected no CORS headers for a PUT from another origin: %d %v", rr.Code, rr.Header())
+	}
+	rr = send("GET", "/private/", "", map[string]string{"Origin": "https://app.example.com"})
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected no CORS headers for a bucket without CORS: %v", rr.Header())
+	}
+}
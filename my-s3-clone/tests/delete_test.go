@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// Test the DeleteObjects contract: one entry per key, Quiet mode, missing keys and request validation
+func TestDeleteObjects(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	mustCreateBucket(t, s, "album")
+	if err := s.PutBucketVersioning("album", storage.VersioningEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	r := router.SetupRouterWithStorage(s)
+
+	send := func(bucket, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/"+bucket+"/?delete", strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	deleteObjects := func(body string) dto.DeleteResult {
+		t.Helper()
+		rr := send("album", body, nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var result dto.DeleteResult
+		if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("could not decode DeleteResult %q: %v", rr.Body.String(), err)
+		}
+		return result
+	}
+
+	first := mustPut(t, s, "album", "a.jpg", "first")
+	mustPut(t, s, "album", "a.jpg", "second")
+	mustPut(t, s, "album", "b.jpg", "other")
+
+	// A missing key is reported as deleted, and an unknown version as an error for its key only
+	result := deleteObjects("<Delete><Object><Key>a.jpg</Key><VersionId>" + first.VersionID + "</VersionId></Object><Object><Key>b.jpg</Key></Object><Object><Key>missing.jpg</Key></Object><Object><Key>a.jpg</Key><VersionId>unknown</VersionId></Object></Delete>")
+	if len(result.DeletedResult) != 3 {
+		t.Fatalf("expected 3 deleted keys, got %+v", result.DeletedResult)
+	}
+	if deleted := result.DeletedResult[0]; deleted.Key != "a.jpg" || deleted.VersionId != first.VersionID || deleted.DeleteMarker {
+		t.Errorf("unexpected entry for the deleted version: %+v", deleted)
+	}
+	if deleted := result.DeletedResult[1]; deleted.Key != "b.jpg" || !deleted.DeleteMarker || deleted.DeleteMarkerVersionId == "" {
+		t.Errorf("expected a delete marker for b.jpg, got %+v", deleted)
+	}
+	if result.DeletedResult[2].Key != "missing.jpg" {
+		t.Errorf("expected missing.jpg to be reported as deleted, got %+v", result.DeletedResult[2])
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Key != "a.jpg" || result.Errors[0].VersionId != "unknown" || result.Errors[0].Code != "NoSuchVersion" || result.Errors[0].Message == "" {
+		t.Errorf("expected NoSuchVersion for the unknown version, got %+v", result.Errors)
+	}
+	expectContent(t, s, "album", "a.jpg", "", "second")
+
+	// In Quiet mode only the errors are reported
+	result = deleteObjects("<Delete><Quiet>true</Quiet><Object><Key>a.jpg</Key></Object><Object><Key>a.jpg</Key><VersionId>unknown</VersionId></Object></Delete>")
+	if len(result.DeletedResult) != 0 || len(result.Errors) != 1 {
+		t.Errorf("expected only the error in Quiet mode, got %+v", result)
+	}
+	if _, err := s.StatObject("album", "a.jpg", ""); err == nil {
+		t.Errorf("a.jpg should be deleted in Quiet mode too")
+	}
+
+	body := "<Delete><Object><Key>c.jpg</Key></Object></Delete>"
+	tooMany := "<Delete>" + strings.Repeat("<Object><Key>c.jpg</Key></Object>", 1001) + "</Delete>"
+	for _, tt := range []struct {
+		name         string
+		bucket       string
+		body         string
+		headers      map[string]string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"valid Content-MD5", "album", body, map[string]string{"Content-MD5": base64MD5([]byte(body))}, http.StatusOK, ""},
+		{"wrong Content-MD5", "album", body, map[string]string{"Content-MD5": base64MD5([]byte("other"))}, http.StatusBadRequest, "BadDigest"},
+		{"invalid Content-MD5", "album", body, map[string]string{"Content-MD5": "not-a-digest"}, http.StatusBadRequest, "InvalidDigest"},
+		{"malformed XML", "album", "<Delete><Object>", nil, http.StatusBadRequest, "MalformedXML"},
+		{"no keys", "album", "<Delete></Delete>", nil, http.StatusBadRequest, "MalformedXML"},
+		{"more than 1000 keys", "album", tooMany, nil, http.StatusBadRequest, "MalformedXML"},
+		{"missing bucket", "nowhere", body, nil, http.StatusNotFound, "NoSuchBucket"},
+	} {
+		rr := send(tt.bucket, tt.body, tt.headers)
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d: %s", tt.name, tt.expectedCode, rr.Code, rr.Body.String())
+			continue
+		}
+		if tt.expectedErr != "" {
+			if code := errorCode(t, rr); code != tt.expectedErr {
+				t.Errorf("%s: expected error code %s but got %s", tt.name, tt.expectedErr, code)
+			}
+		}
+	}
+}
@@ -0,0 +1,557 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"my-s3-clone/config"
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+var (
+	testMasterKey    = bytes.Repeat([]byte("m"), 32)
+	testCustomerKey  = bytes.Repeat([]byte("c"), 32)
+	otherCustomerKey = bytes.Repeat([]byte("o"), 32)
+)
+
+// testContent génère un contenu reconnaissable de size octets
+func testContent(size int) []byte {
+	var b bytes.Buffer
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, "private-album-photo-%08d;", i)
+	}
+	return b.Bytes()[:size]
+}
+
+func setCustomerKeyHeaders(req *http.Request, prefix string, key []byte) {
+	sum := md5.Sum(key)
+	req.Header.Set(prefix+"Server-Side-Encryption-Customer-Algorithm", "AES256")
+	req.Header.Set(prefix+"Server-Side-Encryption-Customer-Key", base64.StdEncoding.EncodeToString(key))
+	req.Header.Set(prefix+"Server-Side-Encryption-Customer-Key-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+// Test that encrypted objects of every size round-trip on every backend, support seeking,
+// and that tampering with the stored content is detected
+func TestEncryptedStorageRoundTrip(t *testing.T) {
+	for _, backend := range storage.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			root := t.TempDir()
+			inner, err := storage.NewBackend(backend, storage.BackendConfig{Root: root})
+			if err != nil {
+				t.Fatalf("could not create backend: %v", err)
+			}
+			s := storage.NewEncryptedStorage(inner, testMasterKey)
+			mustCreateBucket(t, s, "private-album-1")
+
+			for _, size := range []int{0, 1, 64 << 10, 64<<10 + 1, 200 << 10} {
+				content := testContent(size)
+				key := fmt.Sprintf("photo-%d.jpg", size)
+				metadata := dto.ObjectMetadata{ContentType: "image/jpeg", Encryption: &dto.Encryption{Algorithm: dto.EncryptionAES256}}
+				info, err := s.AddObject("private-album-1", key, bytes.NewReader(content), metadata)
+				if err != nil {
+					t.Fatalf("AddObject(%d): %v", size, err)
+				}
+				if info.Size != int64(size) || info.ETag != md5Hex(content) {
+					t.Errorf("size %d: unexpected info %+v", size, info)
+				}
+
+				reader, info, err := s.GetObject("private-album-1", key, "")
+				if err != nil {
+					t.Fatalf("GetObject(%d): %v", size, err)
+				}
+				got, err := io.ReadAll(reader)
+				if err != nil || !bytes.Equal(got, content) {
+					t.Errorf("size %d: content does not round-trip (%v)", size, err)
+				}
+				if info.Size != int64(size) || info.ETag != md5Hex(content) || info.Encryption == nil || info.ContentType != "image/jpeg" {
+					t.Errorf("size %d: unexpected info %+v", size, info)
+				}
+
+				if size > 10 {
+					offset := int64(size - 10)
+					if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+						t.Fatalf("Seek: %v", err)
+					}
+					tail, _ := io.ReadAll(reader)
+					if !bytes.Equal(tail, content[offset:]) {
+						t.Errorf("size %d: unexpected content after seeking to %d", size, offset)
+					}
+				}
+				reader.Close()
+
+				// The backend only stores ciphertext
+				raw, _, err := inner.GetObject("private-album-1", key, "")
+				if err != nil {
+					t.Fatalf("inner GetObject: %v", err)
+				}
+				stored, _ := io.ReadAll(raw)
+				raw.Close()
+				if size > 32 && bytes.Contains(stored, content[:32]) {
+					t.Errorf("size %d: content is stored in plain form", size)
+				}
+			}
+
+			response, err := s.ListObjects("private-album-1", "", "", "", 1000)
+			if err != nil {
+				t.Fatalf("ListObjects: %v", err)
+			}
+			for _, object := range response.Contents {
+				size, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(object.Key, "photo-"), ".jpg"))
+				if object.Size != size {
+					t.Errorf("%s: expected listed size %d but got %d", object.Key, size, object.Size)
+				}
+			}
+
+			if backend != "memory" {
+				testTamperedObject(t, s, filepath.Join(root, "private-album-1", "photo-204800.jpg"))
+			}
+		})
+	}
+}
+
+// testTamperedObject corrompt un octet du fichier chiffré et vérifie que la lecture échoue
+func testTamperedObject(t *testing.T, s storage.Storage, path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read stored object: %v", err)
+	}
+	data[100] ^= 0xff
+	// Le backend adressé par contenu partage le fichier avec son blob : il est remplacé, pas modifié
+	os.Remove(path)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("could not write stored object: %v", err)
+	}
+
+	reader, _, err := s.GetObject("private-album-1", "photo-204800.jpg", "")
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	if _, err := io.ReadAll(reader); err == nil {
+		t.Errorf("reading a tampered object should fail")
+	}
+}
+
+// Test SSE-S3 over HTTP: the response headers, the content stored on disk, HEAD, Range and listing
+func TestServerSideEncryptionS3(t *testing.T) {
+	root := t.TempDir()
+	r := router.SetupRouterWithConfig(storage.NewFileStorage(root), config.Config{MasterKey: testMasterKey})
+	content := testContent(150 << 10)
+
+	send := func(method, url string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	send("PUT", "/private-album-1/", nil, nil)
+
+	rr := send("PUT", "/private-album-1/photo.jpg", content, map[string]string{"X-Amz-Server-Side-Encryption": "AES256", "Content-Type": "image/jpeg"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("X-Amz-Server-Side-Encryption") != "AES256" || rr.Header().Get("ETag") != `"`+md5Hex(content)+`"` {
+		t.Errorf("unexpected PUT headers %v", rr.Header())
+	}
+
+	stored, err := os.ReadFile(filepath.Join(root, "private-album-1", "photo.jpg"))
+	if err != nil {
+		t.Fatalf("could not read stored object: %v", err)
+	}
+	if bytes.Contains(stored, []byte("private-album-photo")) {
+		t.Errorf("object is stored in plain form")
+	}
+
+	rr = send("GET", "/private-album-1/photo.jpg", nil, nil)
+	if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), content) {
+		t.Fatalf("GET: unexpected response %d", rr.Code)
+	}
+	if rr.Header().Get("X-Amz-Server-Side-Encryption") != "AES256" || rr.Header().Get("Content-Length") != strconv.Itoa(len(content)) {
+		t.Errorf("unexpected GET headers %v", rr.Header())
+	}
+
+	rr = send("HEAD", "/private-album-1/photo.jpg", nil, nil)
+	if rr.Header().Get("X-Amz-Server-Side-Encryption") != "AES256" || rr.Header().Get("Content-Length") != strconv.Itoa(len(content)) {
+		t.Errorf("unexpected HEAD headers %v", rr.Header())
+	}
+
+	// The range crosses the boundary between two encrypted chunks
+	rr = send("GET", "/private-album-1/photo.jpg", nil, map[string]string{"Range": "bytes=65530-65545"})
+	if rr.Code != http.StatusPartialContent || !bytes.Equal(rr.Body.Bytes(), content[65530:65546]) {
+		t.Errorf("Range: unexpected response %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = send("GET", "/private-album-1/?list-type=2", nil, nil)
+	if !strings.Contains(rr.Body.String(), fmt.Sprintf("<Size>%d</Size>", len(content))) {
+		t.Errorf("listing should report the plain size: %s", rr.Body.String())
+	}
+
+	rr = send("PUT", "/private-album-1/plain.jpg", []byte("plain"), nil)
+	if rr.Header().Get("X-Amz-Server-Side-Encryption") != "" {
+		t.Errorf("unencrypted object should not report encryption")
+	}
+
+	for _, tt := range []struct {
+		name        string
+		headers     map[string]string
+		expectedErr string
+	}{
+		{"kms", map[string]string{"X-Amz-Server-Side-Encryption": "aws:kms"}, "InvalidArgument"},
+		{"invalid customer key", map[string]string{"X-Amz-Server-Side-Encryption-Customer-Algorithm": "AES256", "X-Amz-Server-Side-Encryption-Customer-Key": "c2hvcnQ="}, "InvalidArgument"},
+	} {
+		rr := send("PUT", "/private-album-1/other.jpg", []byte("x"), tt.headers)
+		if rr.Code != http.StatusBadRequest || errorCode(t, rr) != tt.expectedErr {
+			t.Errorf("%s: expected %s but got %d: %s", tt.name, tt.expectedErr, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Without a master key, SSE-S3 is not available
+	r = router.SetupRouterWithStorage(storage.NewFileStorage(root))
+	rr = send("PUT", "/private-album-1/other.jpg", []byte("x"), map[string]string{"X-Amz-Server-Side-Encryption": "AES256"})
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected status %d without master key but got %d", http.StatusNotImplemented, rr.Code)
+	}
+}
+
+// Test that the default encryption of a bucket seals the objects written, copied or moved
+// into it without encryption headers
+func TestBucketDefaultEncryption(t *testing.T) {
+	root := t.TempDir()
+	r := router.SetupRouterWithConfig(storage.NewFileStorage(root), config.Config{MasterKey: testMasterKey})
+	content := testContent(10 << 10)
+
+	send := func(method, url string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	sealed := func(key string) {
+		t.Helper()
+		stored, err := os.ReadFile(filepath.Join(root, "private-album-1", key))
+		if err != nil {
+			t.Fatalf("could not read stored object %s: %v", key, err)
+		}
+		if bytes.Contains(stored, []byte("private-album-photo")) {
+			t.Errorf("%s is stored in plain form", key)
+		}
+		rr := send("GET", "/private-album-1/"+key, nil, nil)
+		if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), content) || rr.Header().Get("X-Amz-Server-Side-Encryption") != "AES256" {
+			t.Errorf("GET %s: unexpected response %d %v", key, rr.Code, rr.Header())
+		}
+	}
+	send("PUT", "/private-album-1/", nil, nil)
+	send("PUT", "/main-album-1/", nil, nil)
+
+	if rr := send("GET", "/private-album-1/?encryption", nil, nil); rr.Code != http.StatusNotFound || errorCode(t, rr) != "ServerSideEncryptionConfigurationNotFoundError" {
+		t.Errorf("expected no default encryption, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := send("PUT", "/private-album-1/?encryption", []byte(`<ServerSideEncryptionConfiguration><Rule><ApplyServerSideEncryptionByDefault><SSEAlgorithm>aws:kms</SSEAlgorithm></ApplyServerSideEncryptionByDefault></Rule></ServerSideEncryptionConfiguration>`), nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected KMS to be refused, got %d", rr.Code)
+	}
+	if rr := send("PUT", "/private-album-1/?encryption", []byte(`<ServerSideEncryptionConfiguration><Rule><ApplyServerSideEncryptionByDefault><SSEAlgorithm>AES256</SSEAlgorithm></ApplyServerSideEncryptionByDefault></Rule></ServerSideEncryptionConfiguration>`), nil); rr.Code != http.StatusOK {
+		t.Fatalf("PUT ?encryption: unexpected response %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := send("GET", "/private-album-1/?encryption", nil, nil); !strings.Contains(rr.Body.String(), "<SSEAlgorithm>AES256</SSEAlgorithm>") {
+		t.Errorf("unexpected default encryption %s", rr.Body.String())
+	}
+
+	rr := send("PUT", "/private-album-1/photo.jpg", content, map[string]string{"Content-Type": "image/jpeg"})
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Amz-Server-Side-Encryption") != "AES256" {
+		t.Fatalf("PUT: unexpected response %d %v", rr.Code, rr.Header())
+	}
+	sealed("photo.jpg")
+
+	send("PUT", "/main-album-1/copied.jpg", content, nil)
+	send("PUT", "/main-album-1/moved.jpg", content, nil)
+	if rr := send("PUT", "/private-album-1/copied.jpg", nil, map[string]string{"X-Amz-Copy-Source": "/main-album-1/copied.jpg"}); rr.Code != http.StatusOK {
+		t.Fatalf("copy: unexpected response %d: %s", rr.Code, rr.Body.String())
+	}
+	sealed("copied.jpg")
+	send("POST", "/main-album-1/?move", []byte("<Move><TargetBucket>private-album-1</TargetBucket><Object><Key>moved.jpg</Key></Object></Move>"), nil)
+	sealed("moved.jpg")
+	if rr := send("HEAD", "/main-album-1/moved.jpg", nil, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected the moved object to leave its source, got %d", rr.Code)
+	}
+
+	// Supprimer la configuration ne déchiffre pas les objets existants
+	if rr := send("DELETE", "/private-album-1/?encryption", nil, nil); rr.Code != http.StatusNoContent {
+		t.Errorf("DELETE ?encryption: unexpected response %d", rr.Code)
+	}
+	sealed("photo.jpg")
+	if rr := send("PUT", "/private-album-1/plain.jpg", []byte("plain"), nil); rr.Header().Get("X-Amz-Server-Side-Encryption") != "" {
+		t.Errorf("objects should not be encrypted once the default encryption is removed")
+	}
+
+	r = router.SetupRouterWithStorage(storage.NewFileStorage(root))
+	if rr := send("PUT", "/private-album-1/?encryption", []byte(`<ServerSideEncryptionConfiguration><Rule><ApplyServerSideEncryptionByDefault><SSEAlgorithm>AES256</SSEAlgorithm></ApplyServerSideEncryptionByDefault></Rule></ServerSideEncryptionConfiguration>`), nil); rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected status %d without master key but got %d", http.StatusNotImplemented, rr.Code)
+	}
+}
+
+// Test SSE-C: the object can only be read with the key it was written with
+func TestServerSideEncryptionCustomerKey(t *testing.T) {
+	r := router.SetupRouterWithStorage(storage.NewMemoryStorage())
+	content := testContent(1000)
+
+	send := func(method, url string, body []byte, key []byte, prepare func(req *http.Request)) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+		if key != nil {
+			setCustomerKeyHeaders(req, "X-Amz-", key)
+		}
+		if prepare != nil {
+			prepare(req)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	send("PUT", "/private-album-2/", nil, nil, nil)
+	send("PUT", "/private-album-2/plain.jpg", []byte("plain"), nil, nil)
+
+	rr := send("PUT", "/private-album-2/photo.jpg", content, testCustomerKey, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "AES256" || rr.Header().Get("X-Amz-Server-Side-Encryption-Customer-Key-MD5") != storage.CustomerKeyMD5(testCustomerKey) {
+		t.Errorf("unexpected PUT headers %v", rr.Header())
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		url          string
+		key          []byte
+		expectedCode int
+		expectedErr  string
+	}{
+		{"get without key", "GET", "/private-album-2/photo.jpg", nil, http.StatusBadRequest, "InvalidRequest"},
+		{"get with another key", "GET", "/private-album-2/photo.jpg", otherCustomerKey, http.StatusForbidden, "AccessDenied"},
+		{"head without key", "HEAD", "/private-album-2/photo.jpg", nil, http.StatusBadRequest, ""},
+		{"key for a plain object", "GET", "/private-album-2/plain.jpg", testCustomerKey, http.StatusBadRequest, "InvalidRequest"},
+		{"get with key", "GET", "/private-album-2/photo.jpg", testCustomerKey, http.StatusOK, ""},
+		{"head with key", "HEAD", "/private-album-2/photo.jpg", testCustomerKey, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		rr := send(tt.method, tt.url, nil, tt.key, nil)
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedCode, rr.Code)
+			continue
+		}
+		if tt.expectedErr != "" {
+			if code := errorCode(t, rr); code != tt.expectedErr {
+				t.Errorf("%s: expected error code %s but got %s", tt.name, tt.expectedErr, code)
+			}
+		}
+		if rr.Code == http.StatusOK && rr.Header().Get("Content-Length") != strconv.Itoa(len(content)) {
+			t.Errorf("%s: unexpected Content-Length %s", tt.name, rr.Header().Get("Content-Length"))
+		}
+		if tt.method == "GET" && rr.Code == http.StatusOK && !bytes.Equal(rr.Body.Bytes(), content) {
+			t.Errorf("%s: unexpected content", tt.name)
+		}
+	}
+
+	// Copy to SSE-S3 is not possible without a master key, copy to another customer key is
+	rr = send("PUT", "/private-album-2/copy.jpg", nil, otherCustomerKey, func(req *http.Request) {
+		req.Header.Set("X-Amz-Copy-Source", "/private-album-2/photo.jpg")
+		setCustomerKeyHeaders(req, "X-Amz-Copy-Source-", testCustomerKey)
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("copy: expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := send("GET", "/private-album-2/copy.jpg", nil, otherCustomerKey, nil); rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), content) {
+		t.Errorf("copy should be readable with the new key, got %d", rr.Code)
+	}
+
+	// Copying without the source key is refused
+	rr = send("PUT", "/private-album-2/copy2.jpg", nil, otherCustomerKey, func(req *http.Request) {
+		req.Header.Set("X-Amz-Copy-Source", "/private-album-2/photo.jpg")
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("copy without source key: expected status %d but got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+// Test an encrypted multipart upload: each part is encrypted on its own and the parts are
+// decrypted in sequence once assembled
+func TestServerSideEncryptionMultipart(t *testing.T) {
+	s := storage.NewEncryptedStorage(storage.NewMemoryStorage(), testMasterKey)
+	mustCreateBucket(t, s, "private-album-3")
+
+	uploadID, err := s.CreateMultipartUpload("private-album-3", "video.mp4", dto.ObjectMetadata{Encryption: &dto.Encryption{Algorithm: dto.EncryptionAES256}})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	part1, part2 := testContent(5<<20), []byte("the end")
+	etag1, err := s.UploadPart("private-album-3", "video.mp4", uploadID, 1, bytes.NewReader(part1))
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	etag2, err := s.UploadPart("private-album-3", "video.mp4", uploadID, 2, bytes.NewReader(part2))
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+
+	parts, err := s.ListParts("private-album-3", "video.mp4", uploadID, 0, 10)
+	if err != nil || len(parts.Parts) != 2 || parts.Parts[0].Size != int64(len(part1)) || parts.Parts[1].Size != int64(len(part2)) {
+		t.Errorf("ListParts should report plain sizes, got %+v, %v", parts.Parts, err)
+	}
+
+	info, err := s.CompleteMultipartUpload("private-album-3", "video.mp4", uploadID, []dto.CompletedPart{{PartNumber: 1, ETag: etag1}, {PartNumber: 2, ETag: etag2}})
+	if err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+	content := append(append([]byte(nil), part1...), part2...)
+	if info.Size != int64(len(content)) || info.Encryption == nil {
+		t.Errorf("unexpected info %+v", info)
+	}
+
+	reader, _, err := s.GetObject("private-album-3", "video.mp4", "")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	defer reader.Close()
+	if got, err := io.ReadAll(reader); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("content does not round-trip (%v)", err)
+	}
+
+	// Read across the boundary between the two parts
+	offset := int64(len(part1) - 3)
+	reader.Seek(offset, io.SeekStart)
+	if got, _ := io.ReadAll(reader); !bytes.Equal(got, content[offset:]) {
+		t.Errorf("unexpected content after seeking to %d: %q", offset, got)
+	}
+
+	// An SSE-C upload needs the key for every part
+	uploadID, err = s.CreateMultipartUpload("private-album-3", "secret.mp4", dto.ObjectMetadata{Encryption: &dto.Encryption{
+		Algorithm: dto.EncryptionAES256, CustomerKey: testCustomerKey, CustomerKeyMD5: storage.CustomerKeyMD5(testCustomerKey),
+	}})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	if _, err := s.UploadPart("private-album-3", "secret.mp4", uploadID, 1, strings.NewReader("x")); !errors.Is(err, storage.ErrCustomerKeyRequired) {
+		t.Errorf("expected ErrCustomerKeyRequired but got %v", err)
+	}
+	if _, err := storage.WithCustomerKey(s, testCustomerKey).UploadPart("private-album-3", "secret.mp4", uploadID, 1, strings.NewReader("x")); err != nil {
+		t.Errorf("UploadPart with the customer key: %v", err)
+	}
+}
+
+// readCountingStorage compte les ouvertures de contenu faites à travers lui
+type readCountingStorage struct {
+	storage.Storage
+	reads int
+}
+
+func (rs *readCountingStorage) GetObject(bucketName, objectName, versionID string) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+	rs.reads++
+	return rs.Storage.GetObject(bucketName, objectName, versionID)
+}
+
+// Test that listings report the plain size and ETag of encrypted objects from their metadata,
+// without opening their content
+func TestEncryptedListingReadsMetadata(t *testing.T) {
+	for _, backend := range storage.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			inner, err := storage.NewBackend(backend, storage.BackendConfig{Root: t.TempDir()})
+			if err != nil {
+				t.Fatalf("could not create backend: %v", err)
+			}
+			counting := &readCountingStorage{Storage: inner}
+			s := storage.NewEncryptedStorage(counting, testMasterKey)
+			mustCreateBucket(t, s, "private-album-1")
+			if err := s.PutBucketVersioning("private-album-1", storage.VersioningEnabled); err != nil {
+				t.Fatalf("could not enable versioning: %v", err)
+			}
+
+			sseS3 := dto.ObjectMetadata{Encryption: &dto.Encryption{Algorithm: dto.EncryptionAES256}}
+			sseC := dto.ObjectMetadata{Encryption: &dto.Encryption{Algorithm: dto.EncryptionAES256, CustomerKey: testCustomerKey, CustomerKeyMD5: storage.CustomerKeyMD5(testCustomerKey)}}
+			photo, secret, empty := testContent(70<<10), testContent(100), []byte{}
+			for _, object := range []struct {
+				key      string
+				content  []byte
+				metadata dto.ObjectMetadata
+			}{
+				{"photo.jpg", testContent(10), sseS3},
+				{"photo.jpg", photo, sseS3},
+				{"secret.jpg", secret, sseC},
+				{"empty.jpg", empty, sseS3},
+			} {
+				if _, err := s.AddObject("private-album-1", object.key, bytes.NewReader(object.content), object.metadata); err != nil {
+					t.Fatalf("AddObject(%s): %v", object.key, err)
+				}
+			}
+
+			uploadID, err := s.CreateMultipartUpload("private-album-1", "video.mp4", sseS3)
+			if err != nil {
+				t.Fatalf("CreateMultipartUpload: %v", err)
+			}
+			var parts []dto.CompletedPart
+			for _, part := range [][]byte{testContent(5 << 20), secret} {
+				etag, err := s.UploadPart("private-album-1", "video.mp4", uploadID, len(parts)+1, bytes.NewReader(part))
+				if err != nil {
+					t.Fatalf("UploadPart: %v", err)
+				}
+				parts = append(parts, dto.CompletedPart{PartNumber: len(parts) + 1, ETag: etag})
+			}
+			video, err := s.CompleteMultipartUpload("private-album-1", "video.mp4", uploadID, parts)
+			if err != nil {
+				t.Fatalf("CompleteMultipartUpload: %v", err)
+			}
+
+			counting.reads = 0
+			response, err := s.ListObjects("private-album-1", "", "", "", 1000)
+			if err != nil {
+				t.Fatalf("ListObjects: %v", err)
+			}
+			expected := map[string]dto.Object{
+				"empty.jpg":  {Size: 0, ETag: `"` + md5Hex(empty) + `"`},
+				"photo.jpg":  {Size: len(photo), ETag: `"` + md5Hex(photo) + `"`},
+				"secret.jpg": {Size: len(secret)},
+				"video.mp4":  {Size: 5<<20 + len(secret), ETag: `"` + video.ETag + `"`},
+			}
+			if len(response.Contents) != len(expected) {
+				t.Fatalf("expected %d objects, got %+v", len(expected), response.Contents)
+			}
+			for _, object := range response.Contents {
+				want := expected[object.Key]
+				if object.Size != want.Size || (want.ETag != "" && object.ETag != want.ETag) {
+					t.Errorf("%s: expected size %d and ETag %s, got %d and %s", object.Key, want.Size, want.ETag, object.Size, object.ETag)
+				}
+				if object.Key == "secret.jpg" && strings.Contains(object.ETag, md5Hex(secret)) {
+					t.Errorf("the ETag of an SSE-C object should not be the MD5 of its content")
+				}
+			}
+
+			versions, err := s.ListObjectVersions("private-album-1", "photo.jpg", "", "", 1000)
+			if err != nil || len(versions.Versions) != 2 {
+				t.Fatalf("ListObjectVersions: %+v, %v", versions.Versions, err)
+			}
+			if versions.Versions[1].Size != 10 || versions.Versions[1].ETag != `"`+md5Hex(testContent(10))+`"` {
+				t.Errorf("unexpected archived version %+v", versions.Versions[1])
+			}
+			if counting.reads != 0 {
+				t.Errorf("expected listings not to open encrypted objects, got %d reads", counting.reads)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// truncatedReader simule une connexion coupée : il renvoie io.ErrUnexpectedEOF après son contenu
+type truncatedReader struct {
+	r io.Reader
+}
+
+func (tr *truncatedReader) Read(p []byte) (int, error) {
+	n, err := tr.r.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func base64MD5(content []byte) string {
+	sum := md5.Sum(content)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func base64CRC32C(content []byte) string {
+	sum := crc32.Checksum(content, crc32.MakeTable(crc32.Castagnoli))
+	return base64.StdEncoding.EncodeToString([]byte{byte(sum >> 24), byte(sum >> 16), byte(sum >> 8), byte(sum)})
+}
+
+func base64SHA256(content []byte) string {
+	sum := sha256.Sum256(content)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Test that PUT verifies the digests announced by the client on every backend, and that a
+// rejected upload never replaces the stored object
+func TestUploadIntegrityChecks(t *testing.T) {
+	content := []byte("wedding-photo-original")
+	other := []byte("wedding-photo-corrupted")
+	sha256Hex := func(b []byte) string {
+		sum := sha256.Sum256(b)
+		return hex.EncodeToString(sum[:])
+	}
+
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"no digest", nil, http.StatusOK, ""},
+		{"matching Content-MD5", map[string]string{"Content-MD5": base64MD5(content)}, http.StatusOK, ""},
+		{"Content-MD5 mismatch", map[string]string{"Content-MD5": base64MD5(other)}, http.StatusBadRequest, "BadDigest"},
+		{"invalid Content-MD5", map[string]string{"Content-MD5": "not-a-digest"}, http.StatusBadRequest, "InvalidDigest"},
+		{"matching x-amz-content-sha256", map[string]string{"X-Amz-Content-Sha256": sha256Hex(content)}, http.StatusOK, ""},
+		{"x-amz-content-sha256 mismatch", map[string]string{"X-Amz-Content-Sha256": sha256Hex(other)}, http.StatusBadRequest, "XAmzContentSHA256Mismatch"},
+		{"matching crc32c", map[string]string{"X-Amz-Checksum-Crc32c": base64CRC32C(content)}, http.StatusOK, ""},
+		{"crc32c mismatch", map[string]string{"X-Amz-Checksum-Crc32c": base64CRC32C(other)}, http.StatusBadRequest, "BadDigest"},
+		{"matching sha256 checksum", map[string]string{"X-Amz-Checksum-Sha256": base64SHA256(content)}, http.StatusOK, ""},
+		{"sha256 checksum mismatch", map[string]string{"X-Amz-Checksum-Sha256": base64SHA256(other)}, http.StatusBadRequest, "BadDigest"},
+		{"invalid checksum", map[string]string{"X-Amz-Checksum-Sha256": "c2hvcnQ="}, http.StatusBadRequest, "InvalidRequest"},
+	}
+
+	for _, backend := range storage.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			s, err := storage.NewBackend(backend, storage.BackendConfig{Root: t.TempDir()})
+			if err != nil {
+				t.Fatalf("could not create backend: %v", err)
+			}
+			mustCreateBucket(t, s, "wedding-album")
+			r := router.SetupRouterWithStorage(s)
+
+			for _, tt := range tests {
+				mustPut(t, s, "wedding-album", "photo.jpg", "previous version")
+
+				req, _ := http.NewRequest("PUT", "/wedding-album/photo.jpg", bytes.NewReader(content))
+				for name, value := range tt.headers {
+					req.Header.Set(name, value)
+				}
+				rr := httptest.NewRecorder()
+				r.ServeHTTP(rr, req)
+
+				if rr.Code != tt.expectedCode {
+					t.Fatalf("%s: expected status %d but got %d: %s", tt.name, tt.expectedCode, rr.Code, rr.Body.String())
+				}
+				if tt.expectedErr != "" {
+					if code := errorCode(t, rr); code != tt.expectedErr {
+						t.Errorf("%s: expected error code %s but got %s", tt.name, tt.expectedErr, code)
+					}
+					expectContent(t, s, "wedding-album", "photo.jpg", "", "previous version")
+					continue
+				}
+				expectContent(t, s, "wedding-album", "photo.jpg", "", string(content))
+				for name, value := range tt.headers {
+					if strings.HasPrefix(name, "X-Amz-Checksum-") && rr.Header().Get(name) != value {
+						t.Errorf("%s: expected %s %q in the response but got %q", tt.name, name, value, rr.Header().Get(name))
+					}
+				}
+			}
+		})
+	}
+}
+
+// Test that an upload interrupted before the end of its body is rejected and leaves nothing behind
+func TestTruncatedUpload(t *testing.T) {
+	for _, backend := range storage.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			root := t.TempDir()
+			s, err := storage.NewBackend(backend, storage.BackendConfig{Root: root})
+			if err != nil {
+				t.Fatalf("could not create backend: %v", err)
+			}
+			mustCreateBucket(t, s, "wedding-album")
+			r := router.SetupRouterWithStorage(s)
+
+			req, _ := http.NewRequest("PUT", "/wedding-album/video.mp4", &truncatedReader{bytes.NewReader(testContent(100 << 10))})
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "IncompleteBody" {
+				t.Fatalf("expected IncompleteBody but got %d: %s", rr.Code, rr.Body.String())
+			}
+			if _, err := s.StatObject("wedding-album", "video.mp4", ""); err == nil {
+				t.Errorf("truncated upload should not be stored")
+			}
+			if _, err := os.Stat(filepath.Join(root, "wedding-album", "video.mp4")); err == nil {
+				t.Errorf("truncated upload left a file at its final path")
+			}
+		})
+	}
+}
+
+// Test that a streaming upload whose decoded content does not have the announced length is rejected
+func TestStreamingUploadDecodedLength(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	mustCreateBucket(t, s, "wedding-album")
+	r := router.SetupRouterWithConfig(s, authTestConfig())
+	chunks := [][]byte{bytes.Repeat([]byte("a"), 8192), []byte("end of file")}
+
+	for _, tt := range []struct {
+		name          string
+		decodedLength int
+		expectedCode  int
+	}{
+		{"announced length", 8192 + len("end of file"), http.StatusOK},
+		{"shorter content", 8192 + len("end of file") + 10, http.StatusBadRequest},
+		{"longer content", 8192, http.StatusBadRequest},
+	} {
+		signedAt := time.Now()
+		req, _ := http.NewRequest("PUT", "http://localhost:9090/wedding-album/video.mp4", nil)
+		req.Header.Set("X-Amz-Content-Sha256", auth.StreamingPayload)
+		req.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprintf("%d", tt.decodedLength))
+		auth.SignRequest(req, testAccessKey, testSecretKey, testRegion, signedAt)
+
+		seedSignature := req.Header.Get("Authorization")
+		seedSignature = seedSignature[strings.LastIndex(seedSignature, "=")+1:]
+		req.Body = io.NopCloser(bytes.NewReader(buildChunkedBody(seedSignature, signedAt, chunks, testSecretKey)))
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d: %s", tt.name, tt.expectedCode, rr.Code, rr.Body.String())
+			continue
+		}
+		if tt.expectedCode != http.StatusOK {
+			if code := errorCode(t, rr); code != "IncompleteBody" {
+				t.Errorf("%s: expected IncompleteBody but got %s", tt.name, code)
+			}
+		}
+	}
+
+	// Signed requests must announce a valid payload hash
+	req, _ := http.NewRequest("PUT", "http://localhost:9090/wedding-album/photo.jpg", strings.NewReader("photo"))
+	req.Header.Set("X-Amz-Content-Sha256", "dummyhash")
+	auth.SignRequest(req, testAccessKey, testSecretKey, testRegion, time.Now())
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidArgument" {
+		t.Errorf("expected InvalidArgument for a malformed payload hash but got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// Test that UploadPart verifies the digests of each part
+func TestUploadPartIntegrityChecks(t *testing.T) {
+	r := router.SetupRouterWithStorage(storage.NewMemoryStorage())
+	send := func(method, url string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	send("PUT", "/wedding-album/", nil, nil)
+
+	rr := send("POST", "/wedding-album/video.mp4?uploads", nil, nil)
+	var initiated dto.InitiateMultipartUploadResult
+	if err := xml.Unmarshal(rr.Body.Bytes(), &initiated); err != nil {
+		t.Fatalf("could not decode CreateMultipartUpload response %q: %v", rr.Body.String(), err)
+	}
+	url := "/wedding-album/video.mp4?partNumber=1&uploadId=" + initiated.UploadId
+	part := testContent(1000)
+
+	rr = send("PUT", url, part, map[string]string{"Content-MD5": base64MD5([]byte("other part"))})
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "BadDigest" {
+		t.Errorf("expected BadDigest but got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = send("PUT", url, part, map[string]string{"X-Amz-Checksum-Crc32c": base64CRC32C([]byte("other part"))})
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "BadDigest" {
+		t.Errorf("expected BadDigest but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = send("PUT", url, part, map[string]string{"Content-MD5": base64MD5(part), "X-Amz-Checksum-Crc32c": base64CRC32C(part)})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("ETag") != `"`+md5Hex(part)+`"` || rr.Header().Get("X-Amz-Checksum-Crc32c") != base64CRC32C(part) {
+		t.Errorf("unexpected UploadPart headers %v", rr.Header())
+	}
+}
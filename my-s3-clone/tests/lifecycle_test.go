@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/lifecycle"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// Test PUT, GET and DELETE ?lifecycle, and the validation of the rules
+func TestBucketLifecycleConfiguration(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	s.CreateBucket("album")
+	r := router.SetupRouterWithStorage(s)
+
+	send := func(method, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/album/?lifecycle", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := send("GET", ""); rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchLifecycleConfiguration" {
+		t.Fatalf("expected NoSuchLifecycleConfiguration but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	config := `<LifecycleConfiguration>
+		<Rule><ID>scratch</ID><Status>Enabled</Status><Filter><Prefix>tmp/</Prefix></Filter><Expiration><Days>7</Days></Expiration></Rule>
+		<Rule><ID>uploads</ID><Status>Enabled</Status><Filter></Filter><AbortIncompleteMultipartUpload><DaysAfterInitiation>2</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule>
+		<Rule><ID>raw</ID><Status>Disabled</Status><Filter><And><Prefix>raw/</Prefix><Tag><Key>kind</Key><Value>raw</Value></Tag></And></Filter><NoncurrentVersionExpiration><NoncurrentDays>30</NoncurrentDays></NoncurrentVersionExpiration></Rule>
+	</LifecycleConfiguration>`
+	if rr := send("PUT", config); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr := send("GET", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+	var stored dto.LifecycleConfiguration
+	if err := xml.Unmarshal(rr.Body.Bytes(), &stored); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(stored.Rules) != 3 || stored.Rules[0].Expiration.Days != 7 || stored.Rules[1].AbortIncompleteMultipartUpload.DaysAfterInitiation != 2 ||
+		stored.Rules[2].Filter.And.Tags[0].Value != "raw" || stored.Rules[2].NoncurrentVersionExpiration.NoncurrentDays != 30 {
+		t.Errorf("unexpected lifecycle configuration %+v", stored)
+	}
+
+	invalid := []struct {
+		name        string
+		rule        string
+		expectedErr string
+	}{
+		{"not xml", `<Rule>`, "MalformedXML"},
+		{"bad status", `<Rule><Status>On</Status><Expiration><Days>1</Days></Expiration></Rule>`, "MalformedXML"},
+		{"days and date", `<Rule><Status>Enabled</Status><Expiration><Days>1</Days><Date>2030-01-01T00:00:00Z</Date></Expiration></Rule>`, "MalformedXML"},
+		{"negative days", `<Rule><Status>Enabled</Status><Expiration><Days>-1</Days></Expiration></Rule>`, "InvalidArgument"},
+		{"date not at midnight", `<Rule><Status>Enabled</Status><Expiration><Date>2030-01-01T10:00:00Z</Date></Expiration></Rule>`, "InvalidArgument"},
+		{"no action", `<Rule><Status>Enabled</Status></Rule>`, "InvalidArgument"},
+		{"duplicate id", `<Rule><ID>a</ID><Status>Enabled</Status><Expiration><Days>1</Days></Expiration></Rule><Rule><ID>a</ID><Status>Enabled</Status><Expiration><Days>2</Days></Expiration></Rule>`, "InvalidArgument"},
+		{"abort with tag", `<Rule><Status>Enabled</Status><Filter><Tag><Key>k</Key><Value>v</Value></Tag></Filter><AbortIncompleteMultipartUpload><DaysAfterInitiation>1</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule>`, "InvalidArgument"},
+	}
+	for _, tt := range invalid {
+		rr := send("PUT", "<LifecycleConfiguration>"+tt.rule+"</LifecycleConfiguration>")
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d but got %d", tt.name, http.StatusBadRequest, rr.Code)
+			continue
+		}
+		if code := errorCode(t, rr); code != tt.expectedErr {
+			t.Errorf("%s: expected error code %s but got %s", tt.name, tt.expectedErr, code)
+		}
+	}
+
+	if rr := send("DELETE", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d", http.StatusNoContent, rr.Code)
+	}
+	if rr := send("GET", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d after delete but got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+// Test that the sweeper enforces the rules when the clock reaches their due date, on every backend
+func TestLifecycleSweeper(t *testing.T) {
+	for _, backend := range storage.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			s, err := storage.NewBackend(backend, storage.BackendConfig{Root: t.TempDir()})
+			if err != nil {
+				t.Fatalf("could not create backend: %v", err)
+			}
+			testLifecycleSweeper(t, s)
+		})
+	}
+}
+
+func testLifecycleSweeper(t *testing.T, s storage.Storage) {
+	const day = 24 * time.Hour
+	clock := lifecycle.NewManualClock(time.Now())
+	sweeper := lifecycle.NewSweeper(s, clock)
+
+	sweep := func(expected lifecycle.Stats) {
+		t.Helper()
+		stats, err := sweeper.Sweep()
+		if err != nil {
+			t.Fatalf("Sweep: %v", err)
+		}
+		if stats != expected {
+			t.Errorf("expected %+v but got %+v", expected, stats)
+		}
+	}
+
+	tmp, raw := "tmp/", "raw/"
+	mustCreateBucket(t, s, "scratch")
+	mustPut(t, s, "scratch", "tmp/a.jpg", "a")
+	mustPut(t, s, "scratch", "keep/b.jpg", "b")
+	mustPut(t, s, "scratch", "raw/c.dng", "c")
+	uploadID, err := s.CreateMultipartUpload("scratch", "videos/clip.mp4", dto.ObjectMetadata{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	err = s.PutBucketLifecycle("scratch", dto.LifecycleConfiguration{Rules: []dto.LifecycleRule{
+		{ID: "tmp", Status: "Enabled", Filter: &dto.LifecycleFilter{Prefix: &tmp}, Expiration: &dto.LifecycleExpiration{Days: 1}},
+		{ID: "uploads", Status: "Enabled", AbortIncompleteMultipartUpload: &dto.AbortIncompleteMultipartUpload{DaysAfterInitiation: 3}},
+		{ID: "disabled", Status: "Disabled", Expiration: &dto.LifecycleExpiration{Days: 1}},
+		{ID: "tagged", Status: "Enabled", Filter: &dto.LifecycleFilter{And: &dto.LifecycleFilterAnd{Prefix: raw, Tags: []dto.Tag{{Key: "kind", Value: "raw"}}}}, Expiration: &dto.LifecycleExpiration{Days: 1}},
+	}})
+	if err != nil {
+		t.Fatalf("PutBucketLifecycle: %v", err)
+	}
+
+	mustCreateBucket(t, s, "album")
+	if err := s.PutBucketVersioning("album", storage.VersioningEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	v1 := mustPut(t, s, "album", "photo.jpg", "v1")
+	mustPut(t, s, "album", "photo.jpg", "v2")
+	err = s.PutBucketLifecycle("album", dto.LifecycleConfiguration{Rules: []dto.LifecycleRule{
+		{ID: "old-versions", Status: "Enabled", NoncurrentVersionExpiration: &dto.NoncurrentVersionExpiration{NoncurrentDays: 7}},
+	}})
+	if err != nil {
+		t.Fatalf("PutBucketLifecycle: %v", err)
+	}
+
+	// Nothing is due yet
+	sweep(lifecycle.Stats{})
+
+	// Expiration is rounded to the next midnight UTC, so two days are always enough for Days=1
+	clock.Advance(2 * day)
+	sweep(lifecycle.Stats{Expired: 1})
+	if _, err := s.StatObject("scratch", "tmp/a.jpg", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("tmp/a.jpg should have expired, got %v", err)
+	}
+	for _, key := range []string{"keep/b.jpg", "raw/c.dng"} {
+		if _, err := s.StatObject("scratch", key, ""); err != nil {
+			t.Errorf("%s should be kept, got %v", key, err)
+		}
+	}
+	if _, err := s.ListParts("scratch", "videos/clip.mp4", uploadID, 0, 10); err != nil {
+		t.Errorf("upload should not be aborted yet, got %v", err)
+	}
+
+	clock.Advance(2 * day)
+	sweep(lifecycle.Stats{AbortedUploads: 1})
+	if _, err := s.ListParts("scratch", "videos/clip.mp4", uploadID, 0, 10); !errors.Is(err, storage.ErrNoSuchUpload) {
+		t.Errorf("upload should be aborted, got %v", err)
+	}
+
+	clock.Advance(5 * day)
+	sweep(lifecycle.Stats{NoncurrentExpired: 1})
+	if _, err := s.StatObject("album", "photo.jpg", v1.VersionID); !errors.Is(err, storage.ErrNoSuchVersion) {
+		t.Errorf("noncurrent version should be deleted, got %v", err)
+	}
+	expectContent(t, s, "album", "photo.jpg", "", "v2")
+
+	// Sweeping again is a no-op
+	sweep(lifecycle.Stats{})
+}
@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+)
+
+// listTestStorage simulates ListObjects over a fixed set of keys
+func listTestStorage(keys []string) *MockStorage {
+	sort.Strings(keys)
+	return &MockStorage{
+		ListObjectsFunc: func(bucketName, prefix, delimiter, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
+			response := dto.ListObjectsResponse{Name: bucketName, Prefix: prefix, Delimiter: delimiter, Marker: marker, MaxKeys: maxKeys}
+			for _, key := range keys {
+				if !strings.HasPrefix(key, prefix) || key <= marker {
+					continue
+				}
+				commonPrefix := ""
+				if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+					commonPrefix = key[:len(prefix)+i+len(delimiter)]
+				}
+				if commonPrefix != "" && (commonPrefix == marker || commonPrefix == response.NextMarker) {
+					continue
+				}
+				if len(response.Contents)+len(response.CommonPrefixes) == maxKeys {
+					response.IsTruncated = true
+					return response, nil
+				}
+				if commonPrefix != "" {
+					response.NextMarker = commonPrefix
+					response.CommonPrefixes = append(response.CommonPrefixes, dto.CommonPrefix{Prefix: commonPrefix})
+					continue
+				}
+				response.NextMarker = key
+				response.Contents = append(response.Contents, dto.Object{Key: key, LastModified: time.Now(), Size: 1})
+			}
+			response.NextMarker = ""
+			return response, nil
+		},
+	}
+}
+
+func listV2(t *testing.T, r http.Handler, query string) dto.ListBucketV2Result {
+	req, _ := http.NewRequest("GET", "/album/?list-type=2&"+query, nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("%s: expected status %d but got %d: %s", query, http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var result dto.ListBucketV2Result
+	if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("%s: could not decode response: %v", query, err)
+	}
+	return result
+}
+
+// Test that continuation tokens page through every key with a delimiter
+func TestListObjectsV2Pagination(t *testing.T) {
+	keys := []string{"cover.jpg", "2023/a.jpg", "2023/b.jpg", "2024/a.jpg", "2024/summer/b.jpg", "readme.txt"}
+	r := router.SetupRouterWithStorage(listTestStorage(keys))
+
+	var pages [][]string
+	query := "delimiter=/&max-keys=2"
+	for {
+		result := listV2(t, r, query)
+		var page []string
+		for _, prefix := range result.CommonPrefixes {
+			page = append(page, prefix.Prefix)
+		}
+		for _, object := range result.Contents {
+			page = append(page, object.Key)
+		}
+		if result.KeyCount != len(page) {
+			t.Errorf("expected KeyCount %d but got %d", len(page), result.KeyCount)
+		}
+		pages = append(pages, page)
+
+		if !result.IsTruncated {
+			if result.NextContinuationToken != "" {
+				t.Errorf("unexpected NextContinuationToken on the last page")
+			}
+			break
+		}
+		if strings.Contains(result.NextContinuationToken, "2024") {
+			t.Errorf("continuation token should be opaque, got %q", result.NextContinuationToken)
+		}
+		query = "delimiter=/&max-keys=2&continuation-token=" + result.NextContinuationToken
+	}
+
+	expected := [][]string{{"2023/", "2024/"}, {"cover.jpg", "readme.txt"}}
+	if len(pages) != len(expected) {
+		t.Fatalf("expected %d pages but got %v", len(expected), pages)
+	}
+	for i := range expected {
+		if strings.Join(pages[i], ",") != strings.Join(expected[i], ",") {
+			t.Errorf("page %d: expected %v but got %v", i, expected[i], pages[i])
+		}
+	}
+}
+
+// Test start-after, fetch-owner and encoding-type=url
+func TestListObjectsV2Options(t *testing.T) {
+	keys := []string{"vacances/plage 1.jpg", "vacances/plage+2.jpg", "vacances/été.jpg"}
+	r := router.SetupRouterWithStorage(listTestStorage(keys))
+
+	result := listV2(t, r, "start-after=vacances/plage 1.jpg&fetch-owner=true&encoding-type=url")
+	if result.EncodingType != "url" || result.StartAfter != "vacances/plage+1.jpg" {
+		t.Errorf("unexpected EncodingType %q or StartAfter %q", result.EncodingType, result.StartAfter)
+	}
+
+	expected := []string{"vacances/plage%2B2.jpg", "vacances/%C3%A9t%C3%A9.jpg"}
+	if len(result.Contents) != len(expected) {
+		t.Fatalf("expected %d objects but got %d", len(expected), len(result.Contents))
+	}
+	for i, object := range result.Contents {
+		if object.Key != expected[i] {
+			t.Errorf("expected key %q but got %q", expected[i], object.Key)
+		}
+		if object.Owner == nil || object.Owner.ID == "" {
+			t.Errorf("expected owner for %q", object.Key)
+		}
+	}
+
+	result = listV2(t, r, "")
+	for _, object := range result.Contents {
+		if object.Owner != nil {
+			t.Errorf("owner should only be returned with fetch-owner=true")
+		}
+	}
+}
+
+// Test invalid ListObjectsV2 parameters
+func TestListObjectsV2InvalidParameters(t *testing.T) {
+	r := router.SetupRouterWithStorage(listTestStorage(nil))
+
+	for _, query := range []string{"continuation-token=%21%21", "continuation-token=", "encoding-type=base64", "max-keys=-1"} {
+		req, _ := http.NewRequest("GET", "/album/?list-type=2&"+query, nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d but got %d", query, http.StatusBadRequest, rr.Code)
+			continue
+		}
+		if code := errorCode(t, rr); code != "InvalidArgument" {
+			t.Errorf("%s: expected error code InvalidArgument but got %s", query, code)
+		}
+	}
+}
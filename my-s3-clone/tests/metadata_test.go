@@ -0,0 +1,267 @@
+package tests
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// Test that metadata sent with PUT is stored and echoed back on GET and HEAD
+func TestObjectMetadataRoundTrip(t *testing.T) {
+	var stored dto.ObjectMetadata
+	mockStorage := &MockStorage{
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error) {
+			stored = metadata
+			return dto.ObjectInfo{ETag: "5d41402abc4b2a76b9719d911017c592"}, nil
+		},
+		GetObjectFunc: func(bucketName, objectName, versionID string) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+			return newMockObject("hello"), dto.ObjectInfo{Key: objectName, Size: 5, LastModified: time.Now(), ObjectMetadata: stored}, nil
+		},
+		StatObjectFunc: func(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+			return dto.ObjectInfo{Key: objectName, Size: 5, LastModified: time.Now(), ObjectMetadata: stored}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	req, _ := http.NewRequest("PUT", "/album/photo.jpg", strings.NewReader("hello"))
+	req.Header.Set("Content-Type", "image/jpeg")
+	req.Header.Set("Content-Disposition", `attachment; filename="vacances.jpg"`)
+	req.Header.Set("Cache-Control", "max-age=3600")
+	req.Header.Set("X-Amz-Meta-Camera", "Pixel 8")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+	if stored.ContentType != "image/jpeg" || stored.UserMetadata["camera"] != "Pixel 8" {
+		t.Fatalf("unexpected metadata passed to storage: %+v", stored)
+	}
+
+	expected := map[string]string{
+		"Content-Type":        "image/jpeg",
+		"Content-Disposition": `attachment; filename="vacances.jpg"`,
+		"Cache-Control":       "max-age=3600",
+		"X-Amz-Meta-Camera":   "Pixel 8",
+	}
+	for _, method := range []string{"GET", "HEAD"} {
+		req, _ := http.NewRequest(method, "/album/photo.jpg", nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d but got %d", method, http.StatusOK, rr.Code)
+		}
+		for name, value := range expected {
+			if got := rr.Header().Get(name); got != value {
+				t.Errorf("%s: expected %s %q but got %q", method, name, value, got)
+			}
+		}
+	}
+}
+
+// Test that oversized user metadata is rejected
+func TestObjectMetadataTooLarge(t *testing.T) {
+	r := router.SetupRouterWithStorage(&MockStorage{})
+
+	req, _ := http.NewRequest("PUT", "/album/photo.jpg", strings.NewReader("hello"))
+	req.Header.Set("X-Amz-Meta-Description", strings.Repeat("a", 3000))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d but got %d", http.StatusBadRequest, rr.Code)
+	}
+	if code := errorCode(t, rr); code != "MetadataTooLarge" {
+		t.Errorf("expected error code MetadataTooLarge but got %s", code)
+	}
+}
+
+// Test the COPY and REPLACE metadata directives of CopyObject
+func TestCopyObjectMetadataDirective(t *testing.T) {
+	var received *dto.ObjectMetadata
+	var source string
+	mockStorage := &MockStorage{
+		CopyObjectFunc: func(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+			if sourceKey == "missing.jpg" {
+				return dto.ObjectInfo{}, os.ErrNotExist
+			}
+			received = metadata
+			source = sourceBucket + "/" + sourceKey
+			return dto.ObjectInfo{Key: targetKey, ETag: "5d41402abc4b2a76b9719d911017c592", LastModified: time.Now()}, nil
+		},
+		// Replacing the metadata reads the tags of the source, which are kept
+		StatObjectFunc: func(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+			if objectName == "missing.jpg" {
+				return dto.ObjectInfo{}, os.ErrNotExist
+			}
+			return dto.ObjectInfo{Key: objectName, ETag: "5d41402abc4b2a76b9719d911017c592", LastModified: time.Now()}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	tests := []struct {
+		name         string
+		url          string
+		copySource   string
+		directive    string
+		expectedCode int
+		expectedErr  string
+		replaced     bool
+	}{
+		{"default directive copies", "/backup/photo.jpg", "/album/photo.jpg", "", http.StatusOK, "", false},
+		{"copy directive", "/backup/photo.jpg", "album/photo.jpg", "COPY", http.StatusOK, "", false},
+		{"replace directive", "/album/photo.jpg", "/album/photo.jpg", "REPLACE", http.StatusOK, "", true},
+		{"copy onto itself", "/album/photo.jpg", "/album/photo.jpg", "COPY", http.StatusBadRequest, "InvalidRequest", false},
+		{"unknown directive", "/backup/photo.jpg", "/album/photo.jpg", "MERGE", http.StatusBadRequest, "InvalidArgument", false},
+		{"malformed source", "/backup/photo.jpg", "album", "", http.StatusBadRequest, "InvalidArgument", false},
+		{"missing source", "/backup/photo.jpg", "/album/missing.jpg", "", http.StatusNotFound, "NoSuchKey", false},
+	}
+
+	for _, tt := range tests {
+		received, source = nil, ""
+
+		req, _ := http.NewRequest("PUT", tt.url, nil)
+		req.Header.Set("X-Amz-Copy-Source", tt.copySource)
+		req.Header.Set("Content-Type", "image/png")
+		if tt.directive != "" {
+			req.Header.Set("X-Amz-Metadata-Directive", tt.directive)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedCode, rr.Code)
+			continue
+		}
+		if tt.expectedErr != "" {
+			if code := errorCode(t, rr); code != tt.expectedErr {
+				t.Errorf("%s: expected error code %s but got %s", tt.name, tt.expectedErr, code)
+			}
+			continue
+		}
+
+		if source != "album/photo.jpg" {
+			t.Errorf("%s: unexpected copy source %q", tt.name, source)
+		}
+		if tt.replaced != (received != nil) {
+			t.Errorf("%s: expected replaced metadata %v but got %+v", tt.name, tt.replaced, received)
+		} else if received != nil && received.ContentType != "image/png" {
+			t.Errorf("%s: expected replaced Content-Type image/png but got %q", tt.name, received.ContentType)
+		}
+
+		var result dto.CopyObjectResult
+		if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%s: could not decode response: %v", tt.name, err)
+		}
+		if result.ETag != `"5d41402abc4b2a76b9719d911017c592"` {
+			t.Errorf("%s: unexpected ETag %q", tt.name, result.ETag)
+		}
+	}
+}
+
+// fileIdentity renvoie l'identité d'un fichier telle que la journalisent les backends fs et cas
+func fileIdentity(t *testing.T, path string) string {
+	t.Helper()
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("could not stat %s: %v", path, err)
+	}
+	stat := fileInfo.Sys().(*syscall.Stat_t)
+	return fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
+}
+
+// writeJournalEntry écrit une entrée du journal, comme l'aurait laissée un arrêt du serveur
+func writeJournalEntry(t *testing.T, root, name string, entry interface{}) {
+	t.Helper()
+	data, _ := json.Marshal(entry)
+	dir := filepath.Join(root, ".s3clone", "journal")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatalf("could not create journal directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		t.Fatalf("could not write journal: %v", err)
+	}
+}
+
+// Test that a write interrupted between the rename of its content and the write of its sidecar
+// is completed when the backend starts, and never pairs content with the metadata of another version
+func TestCommitJournalRecovery(t *testing.T) {
+	for _, backend := range []string{"fs", "cas"} {
+		t.Run(backend, func(t *testing.T) {
+			root := t.TempDir()
+			s, _ := storage.NewBackend(backend, storage.BackendConfig{Root: root})
+			mustCreateBucket(t, s, "album")
+			mustPutMeta(t, s, "album", "renamed.jpg", "old pixels", dto.ObjectMetadata{ContentType: "text/plain"})
+			mustPutMeta(t, s, "album", "staged.jpg", "old pixels", dto.ObjectMetadata{ContentType: "text/plain"})
+			mustPutMeta(t, s, "album", "replaced.jpg", "old pixels", dto.ObjectMetadata{ContentType: "text/plain"})
+			tmpDir := filepath.Join(root, ".s3clone", "tmp")
+			newMeta := map[string]string{"etag": md5Hex([]byte("new pixels")), "contentType": "image/jpeg", "sha256": sha256Hex("new pixels")}
+
+			// Le nouveau contenu a été renommé à sa place, mais son sidecar n'a pas été écrit
+			renamed := filepath.Join(root, "album", "renamed.jpg")
+			if err := os.WriteFile(filepath.Join(tmpDir, "object-renamed"), []byte("new pixels"), 0644); err != nil {
+				t.Fatalf("could not write staged file: %v", err)
+			}
+			if err := os.Rename(filepath.Join(tmpDir, "object-renamed"), renamed); err != nil {
+				t.Fatalf("could not rename staged file: %v", err)
+			}
+			writeJournalEntry(t, root, "commit-renamed.json", map[string]interface{}{
+				"bucket": "album", "key": "renamed.jpg", "staged": filepath.Join(tmpDir, "object-renamed"),
+				"stagedFile": fileIdentity(t, renamed), "meta": newMeta,
+			})
+
+			// Le contenu n'a pas encore été renommé
+			staged := filepath.Join(tmpDir, "object-staged")
+			if err := os.WriteFile(staged, []byte("new pixels"), 0644); err != nil {
+				t.Fatalf("could not write staged file: %v", err)
+			}
+			writeJournalEntry(t, root, "commit-staged.json", map[string]interface{}{
+				"bucket": "album", "key": "staged.jpg", "staged": staged,
+				"stagedFile": fileIdentity(t, staged), "meta": newMeta,
+			})
+
+			// Le journal décrit un fichier qui a été remplacé depuis
+			writeJournalEntry(t, root, "commit-replaced.json", map[string]interface{}{
+				"bucket": "album", "key": "replaced.jpg", "staged": filepath.Join(tmpDir, "object-replaced"),
+				"stagedFile": "0:0", "meta": newMeta,
+			})
+
+			s, err := storage.NewBackend(backend, storage.BackendConfig{Root: root})
+			if err != nil {
+				t.Fatalf("could not create backend: %v", err)
+			}
+			info, err := s.StatObject("album", "renamed.jpg", "")
+			if err != nil || info.ETag != md5Hex([]byte("new pixels")) || info.ContentType != "image/jpeg" {
+				t.Errorf("expected the sidecar of the renamed content to be written, got %+v (%v)", info, err)
+			}
+			expectContent(t, s, "album", "renamed.jpg", "", "new pixels")
+			for _, key := range []string{"staged.jpg", "replaced.jpg"} {
+				info, err := s.StatObject("album", key, "")
+				if err != nil || info.ETag != md5Hex([]byte("old pixels")) || info.ContentType != "text/plain" {
+					t.Errorf("expected %s to keep its version, got %+v (%v)", key, info, err)
+				}
+				expectContent(t, s, "album", key, "", "old pixels")
+			}
+			if _, err := os.Stat(staged); err == nil {
+				t.Errorf("staged file should be removed")
+			}
+			if entries, _ := os.ReadDir(filepath.Join(root, ".s3clone", "journal")); len(entries) != 0 {
+				t.Errorf("journal should be removed, found %d entries", len(entries))
+			}
+		})
+	}
+}
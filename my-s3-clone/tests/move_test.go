@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// Test that a move reports the result of each key and goes on after a failure
+func TestMoveObjectsReportsEachKey(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	mustCreateBucket(t, s, "album")
+	mustCreateBucket(t, s, "archive")
+	mustPut(t, s, "album", "a.jpg", "first")
+	mustPut(t, s, "album", "c.jpg", "third")
+	r := router.SetupRouterWithStorage(s)
+
+	move := func(body string) dto.MoveResult {
+		t.Helper()
+		req, _ := http.NewRequest("POST", "/album/?move", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var result dto.MoveResult
+		if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("could not decode move response %q: %v", rr.Body.String(), err)
+		}
+		return result
+	}
+
+	result := move("<Move><TargetBucket>archive</TargetBucket><Object><Key>a.jpg</Key></Object><Object><Key>b.jpg</Key></Object><Object><Key>c.jpg</Key></Object></Move>")
+	if len(result.Moved) != 2 || result.Moved[0].Key != "a.jpg" || result.Moved[1].Key != "c.jpg" {
+		t.Errorf("expected a.jpg and c.jpg to be moved, got %+v", result.Moved)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Key != "b.jpg" || result.Errors[0].Code != "NoSuchKey" {
+		t.Errorf("expected NoSuchKey for b.jpg, got %+v", result.Errors)
+	}
+	expectContent(t, s, "archive", "a.jpg", "", "first")
+	expectContent(t, s, "archive", "c.jpg", "", "third")
+
+	mustPut(t, s, "album", "d.jpg", "fourth")
+	result = move("<Move><TargetBucket>nowhere</TargetBucket><Object><Key>d.jpg</Key></Object></Move>")
+	if len(result.Moved) != 0 || len(result.Errors) != 1 || result.Errors[0].Code != "NoSuchBucket" {
+		t.Errorf("expected NoSuchBucket for d.jpg, got %+v", result)
+	}
+	result = move("<Move><TargetBucket>album</TargetBucket><Object><Key>d.jpg</Key></Object></Move>")
+	if len(result.Errors) != 1 || result.Errors[0].Code != "InvalidRequest" {
+		t.Errorf("expected InvalidRequest for a move onto itself, got %+v", result)
+	}
+	expectContent(t, s, "album", "d.jpg", "", "fourth")
+
+	// Les erreurs qui concernent toute la requête sont des erreurs S3
+	for _, tt := range []struct {
+		name string
+		body string
+	}{
+		{"malformed XML", "<Move><TargetBucket>archive"},
+		{"no key", "<Move><TargetBucket>archive</TargetBucket></Move>"},
+		{"no target bucket", "<Move><Object><Key>d.jpg</Key></Object></Move>"},
+		{"too many keys", "<Move><TargetBucket>archive</TargetBucket>" + strings.Repeat("<Object><Key>d.jpg</Key></Object>", 1001) + "</Move>"},
+	} {
+		req, _ := http.NewRequest("POST", "/album/?move", strings.NewReader(tt.body))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "MalformedXML" {
+			t.Errorf("%s: expected MalformedXML but got %d: %s", tt.name, rr.Code, rr.Body.String())
+		}
+	}
+	expectContent(t, s, "album", "d.jpg", "", "fourth")
+}
+
+// writeMoveJournal simule un déplacement de album/photo.jpg interrompu par un arrêt du serveur ;
+// sourceFile est l'identité du fichier source au début du déplacement
+func writeMoveJournal(t *testing.T, root, staged, sourceFile string) {
+	t.Helper()
+	writeJournalEntry(t, root, "move-interrupted.json", map[string]interface{}{
+		"sourceBucket": "album",
+		"sourceKey":    "photo.jpg",
+		"targetBucket": "archive",
+		"targetKey":    "photo.jpg",
+		"staged":       staged,
+		"sourceFile":   sourceFile,
+		"meta":         map[string]string{"etag": md5Hex([]byte("pixels"))},
+	})
+}
+
+// expectJournalEmpty vérifie que toutes les entrées du journal ont été reprises
+func expectJournalEmpty(t *testing.T, root string) {
+	t.Helper()
+	if entries, _ := os.ReadDir(filepath.Join(root, ".s3clone", "journal")); len(entries) != 0 {
+		t.Errorf("journal should be removed, found %d entries", len(entries))
+	}
+}
+
+// Test that moves interrupted by a crash are rolled back or forward when the backend starts,
+// depending on whether the staged link was renamed into the target
+func TestMoveJournalRecovery(t *testing.T) {
+	for _, backend := range []string{"fs", "cas"} {
+		t.Run(backend, func(t *testing.T) {
+			// setup crée album/photo.jpg, et archive/photo.jpg si target est renseigné, puis renvoie
+			// un lien vers la source dans le répertoire temporaire, comme au début d'un déplacement
+			setup := func(target string) (string, string) {
+				root := t.TempDir()
+				s, _ := storage.NewBackend(backend, storage.BackendConfig{Root: root})
+				mustCreateBucket(t, s, "album")
+				mustCreateBucket(t, s, "archive")
+				mustPut(t, s, "album", "photo.jpg", "pixels")
+				if target != "" {
+					mustPut(t, s, "archive", "photo.jpg", target)
+				}
+				staged := filepath.Join(root, ".s3clone", "tmp", "object-staged")
+				if err := os.MkdirAll(filepath.Dir(staged), os.ModePerm); err != nil {
+					t.Fatalf("could not create tmp directory: %v", err)
+				}
+				if err := os.Link(filepath.Join(root, "album", "photo.jpg"), staged); err != nil {
+					t.Fatalf("could not stage source: %v", err)
+				}
+				return root, staged
+			}
+			restart := func(root string) storage.Storage {
+				s, err := storage.NewBackend(backend, storage.BackendConfig{Root: root})
+				if err != nil {
+					t.Fatalf("could not create backend: %v", err)
+				}
+				return s
+			}
+
+			// The staged link was not renamed: the move is rolled back, with the commit of its target
+			root, staged := setup("")
+			writeMoveJournal(t, root, staged, fileIdentity(t, filepath.Join(root, "album", "photo.jpg")))
+			writeJournalEntry(t, root, "commit-interrupted.json", map[string]interface{}{
+				"bucket":     "archive",
+				"key":        "photo.jpg",
+				"staged":     staged,
+				"stagedFile": fileIdentity(t, staged),
+				"meta":       map[string]string{"etag": md5Hex([]byte("pixels"))},
+			})
+			s := restart(root)
+			expectContent(t, s, "album", "photo.jpg", "", "pixels")
+			if _, err := s.StatObject("archive", "photo.jpg", ""); err == nil {
+				t.Errorf("rolled back move should not create the target")
+			}
+			if _, err := os.Stat(staged); err == nil {
+				t.Errorf("staged file should be removed")
+			}
+			expectJournalEmpty(t, root)
+
+			// An identical target written by another request does not complete the move
+			root, staged = setup("pixels")
+			writeMoveJournal(t, root, staged, fileIdentity(t, filepath.Join(root, "album", "photo.jpg")))
+			s = restart(root)
+			expectContent(t, s, "album", "photo.jpg", "", "pixels")
+			expectContent(t, s, "archive", "photo.jpg", "", "pixels")
+			expectJournalEmpty(t, root)
+
+			// The staged link was renamed into the target but the source not removed: the move is completed
+			root, staged = setup("")
+			if err := os.Rename(staged, filepath.Join(root, "archive", "photo.jpg")); err != nil {
+				t.Fatalf("could not put target in place: %v", err)
+			}
+			copySidecar(t, root, "album", "archive", "photo.jpg")
+			writeMoveJournal(t, root, staged, fileIdentity(t, filepath.Join(root, "album", "photo.jpg")))
+			s = restart(root)
+			expectContent(t, s, "archive", "photo.jpg", "", "pixels")
+			if _, err := s.StatObject("album", "photo.jpg", ""); err == nil {
+				t.Errorf("completed move should remove the source")
+			}
+			expectJournalEmpty(t, root)
+
+			// The source was replaced after the target was put in place: it is kept
+			root, staged = setup("")
+			os.Remove(staged)
+			writeMoveJournal(t, root, staged, "0:0")
+			s = restart(root)
+			expectContent(t, s, "album", "photo.jpg", "", "pixels")
+			expectJournalEmpty(t, root)
+		})
+	}
+}
+
+// copySidecar copie le sidecar d'un objet d'un bucket à l'autre, comme le fait la mise en place d'un déplacement
+func copySidecar(t *testing.T, root, sourceBucket, targetBucket, key string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, ".s3clone", "meta", sourceBucket, key+".json"))
+	if err != nil {
+		t.Fatalf("could not read sidecar: %v", err)
+	}
+	path := filepath.Join(root, ".s3clone", "meta", targetBucket, key+".json")
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatalf("could not create sidecar directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("could not write sidecar: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// Test for the multipart upload flow: create, upload part, complete
+func TestMultipartUploadRoutes(t *testing.T) {
+	var receivedParts []dto.CompletedPart
+
+	mockStorage := &MockStorage{
+		CreateMultipartUploadFunc: func(bucketName, objectName string, metadata dto.ObjectMetadata) (string, error) {
+			if bucketName != "test-bucket" {
+				return "", storage.ErrNoSuchBucket
+			}
+			return "upload-1", nil
+		},
+		UploadPartFunc: func(bucketName, objectName, uploadID string, partNumber int, data io.Reader) (string, error) {
+			if uploadID != "upload-1" {
+				return "", storage.ErrNoSuchUpload
+			}
+			return "5d41402abc4b2a76b9719d911017c592", nil
+		},
+		CompleteMultipartUploadFunc: func(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+			receivedParts = parts
+			return dto.ObjectInfo{ETag: "3858f62230ac3c915f300c664312c11f-2"}, nil
+		},
+	}
+
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	// Create the upload
+	req, _ := http.NewRequest("POST", "/test-bucket/video.mp4?uploads", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+	var initResult dto.InitiateMultipartUploadResult
+	if err := xml.Unmarshal(rr.Body.Bytes(), &initResult); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if initResult.UploadId != "upload-1" || initResult.Key != "video.mp4" {
+		t.Errorf("unexpected InitiateMultipartUploadResult: %+v", initResult)
+	}
+
+	// Upload a part
+	req, _ = http.NewRequest("PUT", "/test-bucket/video.mp4?partNumber=1&uploadId=upload-1", bytes.NewBufferString("hello"))
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+	if etag := rr.Header().Get("ETag"); etag != `"5d41402abc4b2a76b9719d911017c592"` {
+		t.Errorf("unexpected part ETag %q", etag)
+	}
+
+	// Complete the upload
+	body := `<CompleteMultipartUpload xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` +
+		`<Part><PartNumber>1</PartNumber><ETag>"a"</ETag></Part>` +
+		`<Part><PartNumber>2</PartNumber><ETag>"b"</ETag></Part>` +
+		`</CompleteMultipartUpload>`
+	req, _ = http.NewRequest("POST", "/test-bucket/video.mp4?uploadId=upload-1", bytes.NewBufferString(body))
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+	if len(receivedParts) != 2 || receivedParts[1].PartNumber != 2 {
+		t.Errorf("unexpected parts passed to storage: %+v", receivedParts)
+	}
+	var completeResult dto.CompleteMultipartUploadResult
+	if err := xml.Unmarshal(rr.Body.Bytes(), &completeResult); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if completeResult.ETag != `"3858f62230ac3c915f300c664312c11f-2"` {
+		t.Errorf("unexpected ETag %q", completeResult.ETag)
+	}
+}
+
+// Test that storage errors are returned as S3 XML errors
+func TestMultipartUploadErrors(t *testing.T) {
+	mockStorage := &MockStorage{
+		CreateMultipartUploadFunc: func(bucketName, objectName string, metadata dto.ObjectMetadata) (string, error) {
+			return "", storage.ErrNoSuchBucket
+		},
+		UploadPartFunc: func(bucketName, objectName, uploadID string, partNumber int, data io.Reader) (string, error) {
+			return "", storage.ErrNoSuchUpload
+		},
+		CompleteMultipartUploadFunc: func(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+			return dto.ObjectInfo{}, storage.ErrInvalidPartOrder
+		},
+	}
+
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	tests := []struct {
+		method       string
+		url          string
+		body         string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"POST", "/missing-bucket/video.mp4?uploads", "", http.StatusNotFound, "NoSuchBucket"},
+		{"PUT", "/test-bucket/video.mp4?partNumber=1&uploadId=unknown", "data", http.StatusNotFound, "NoSuchUpload"},
+		{"PUT", "/test-bucket/video.mp4?partNumber=abc&uploadId=upload-1", "data", http.StatusBadRequest, "InvalidArgument"},
+		{"POST", "/test-bucket/video.mp4?uploadId=upload-1", "not xml", http.StatusBadRequest, "MalformedXML"},
+		{"POST", "/test-bucket/video.mp4?uploadId=upload-1", "<CompleteMultipartUpload><Part><PartNumber>2</PartNumber><ETag>a</ETag></Part><Part><PartNumber>1</PartNumber><ETag>b</ETag></Part></CompleteMultipartUpload>", http.StatusBadRequest, "InvalidPartOrder"},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+		if err != nil {
+			t.Fatalf("could not create request: %v", err)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s %s: expected status %d but got %d", tt.method, tt.url, tt.expectedCode, rr.Code)
+		}
+
+		var errResponse dto.ErrorResponse
+		if err := xml.Unmarshal(rr.Body.Bytes(), &errResponse); err != nil {
+			t.Fatalf("%s %s: could not decode error response: %v", tt.method, tt.url, err)
+		}
+		if errResponse.Code != tt.expectedErr {
+			t.Errorf("%s %s: expected error code %s but got %s", tt.method, tt.url, tt.expectedErr, errResponse.Code)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// Test that keys containing slashes and encoded characters reach the storage unchanged
+func TestNestedObjectKeysRouting(t *testing.T) {
+	var received []string
+	record := func(bucketName, objectName string) {
+		received = append(received, bucketName+"|"+objectName)
+	}
+	mockStorage := &MockStorage{
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error) {
+			record(bucketName, objectName)
+			return dto.ObjectInfo{ETag: "5d41402abc4b2a76b9719d911017c592"}, nil
+		},
+		GetObjectFunc: func(bucketName, objectName, versionID string) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+			record(bucketName, objectName)
+			return newMockObject("hello"), dto.ObjectInfo{Key: objectName, Size: 5, LastModified: time.Now()}, nil
+		},
+		StatObjectFunc: func(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+			record(bucketName, objectName)
+			return dto.ObjectInfo{Key: objectName, Size: 5, LastModified: time.Now()}, nil
+		},
+		DeleteObjectFunc: func(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error) {
+			record(bucketName, objectName)
+			return dto.DeleteObjectResult{}, nil
+		},
+		CopyObjectFunc: func(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+			record(sourceBucket, sourceKey)
+			record(targetBucket, targetKey)
+			return dto.ObjectInfo{Key: targetKey, LastModified: time.Now()}, nil
+		},
+		CreateMultipartUploadFunc: func(bucketName, objectName string, metadata dto.ObjectMetadata) (string, error) {
+			record(bucketName, objectName)
+			return "upload-1", nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	tests := []struct {
+		name         string
+		method       string
+		url          string
+		copySource   string
+		expectedCode int
+		expected     []string
+	}{
+		{"put nested", "PUT", "/album/2024/summer/img.jpg", "", http.StatusOK, []string{"album|2024/summer/img.jpg"}},
+		{"put encoded", "PUT", "/album/2024/plage%20%C3%A9t%C3%A9%2B1.jpg", "", http.StatusOK, []string{"album|2024/plage été+1.jpg"}},
+		{"get nested", "GET", "/album/2024/summer/img.jpg", "", http.StatusOK, []string{"album|2024/summer/img.jpg"}},
+		{"head nested", "HEAD", "/album/2024/summer/img.jpg", "", http.StatusOK, []string{"album|2024/summer/img.jpg"}},
+		{"delete nested", "DELETE", "/album/2024/summer/img.jpg", "", http.StatusNoContent, []string{"album|2024/summer/img.jpg"}},
+		{"copy nested", "PUT", "/backup/2024/img.jpg", "/album/2024/summer/img%20one.jpg", http.StatusOK, []string{"album|2024/summer/img one.jpg", "backup|2024/img.jpg"}},
+		{"multipart nested", "POST", "/album/videos/2024/clip.mp4?uploads", "", http.StatusOK, []string{"album|videos/2024/clip.mp4"}},
+	}
+
+	for _, tt := range tests {
+		received = nil
+		req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader("hello"))
+		if tt.copySource != "" {
+			req.Header.Set("X-Amz-Copy-Source", tt.copySource)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedCode, rr.Code)
+			continue
+		}
+		if strings.Join(received, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("%s: expected storage calls %v but got %v", tt.name, tt.expected, received)
+		}
+	}
+}
+
+// Test that invalid keys and bucket names are rejected instead of being cleaned or redirected
+func TestPathTraversalRejected(t *testing.T) {
+	mockStorage := &MockStorage{
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error) {
+			if strings.Contains(objectName, "..") || strings.Contains(objectName, "//") {
+				return dto.ObjectInfo{}, storage.ErrInvalidObjectName
+			}
+			return dto.ObjectInfo{}, nil
+		},
+		CheckBucketExistsFunc: func(bucketName string) (bool, error) {
+			return false, storage.ErrInvalidBucketName
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	tests := []struct {
+		name         string
+		method       string
+		url          string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"dot-dot segment", "PUT", "/album/../etc/passwd", http.StatusBadRequest, "InvalidArgument"},
+		{"encoded dot-dot segment", "PUT", "/album/2024/%2E%2E/%2E%2E/secret", http.StatusBadRequest, "InvalidArgument"},
+		{"empty segment", "PUT", "/album/2024//img.jpg", http.StatusBadRequest, "InvalidArgument"},
+		{"dot-dot bucket", "PUT", "/../", http.StatusBadRequest, "InvalidBucketName"},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "http://localhost"+tt.url, strings.NewReader("hello"))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedCode, rr.Code)
+			continue
+		}
+		if code := errorCode(t, rr); code != tt.expectedErr {
+			t.Errorf("%s: expected error code %s but got %s", tt.name, tt.expectedErr, code)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"my-s3-clone/config"
+	"my-s3-clone/dto"
+	"my-s3-clone/notify"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// webhook is a test endpoint that records the events it receives, or refuses them while failing is set
+type webhook struct {
+	mu      sync.Mutex
+	failing bool
+	events  []string
+	records []notify.Record
+}
+
+func (wh *webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	if wh.failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var payload notify.Payload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, record := range payload.Records {
+		wh.events = append(wh.events, record.EventName+" "+record.S3.Bucket.Name+"/"+record.S3.Object.Key)
+	}
+	wh.records = append(wh.records, payload.Records...)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (wh *webhook) received() string {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	return strings.Join(wh.events, ", ")
+}
+
+// Test that PUT, copy, move and delete are delivered to the webhooks configured with PUT ?notification
+func TestBucketNotifications(t *testing.T) {
+	endpoint := &webhook{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	queue, err := notify.NewQueue(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	s := storage.NewMemoryStorage()
+	mustCreateBucket(t, s, "album")
+	mustCreateBucket(t, s, "archive")
+	r := router.SetupRouterWithStorage(notify.NewStorage(s, queue, "us-east-1"))
+
+	send := func(method, url, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	config := "<NotificationConfiguration><WebhookConfiguration><Id>gallery</Id><Endpoint>" + server.URL + "</Endpoint>" +
+		"<Event>s3:ObjectCreated:*</Event><Event>s3:ObjectRemoved:*</Event>" +
+		"<Filter><S3Key><FilterRule><Name>prefix</Name><Value>photos/</Value></FilterRule></S3Key></Filter></WebhookConfiguration></NotificationConfiguration>"
+	for _, bucket := range []string{"album", "archive"} {
+		if rr := send("PUT", "/"+bucket+"/?notification", config, nil); rr.Code != http.StatusOK {
+			t.Fatalf("PUT ?notification: expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+	}
+	rr := send("GET", "/album/?notification", "", nil)
+	var stored dto.NotificationConfiguration
+	if err := xml.Unmarshal(rr.Body.Bytes(), &stored); err != nil || len(stored.Webhooks) != 1 || stored.Webhooks[0].ID != "gallery" || stored.Webhooks[0].Endpoint != server.URL {
+		t.Fatalf("unexpected notification configuration %s: %v", rr.Body.String(), err)
+	}
+
+	send("PUT", "/album/photos/a b.jpg", "pixels", nil)
+	send("PUT", "/album/notes.txt", "not a photo", nil)
+	send("PUT", "/album/photos/c.jpg", "", map[string]string{"X-Amz-Copy-Source": "/album/photos/a b.jpg"})
+	send("POST", "/album/?move", "<Move><TargetBucket>archive</TargetBucket><Object><Key>photos/c.jpg</Key></Object></Move>", nil)
+	send("DELETE", "/album/photos/a b.jpg", "", nil)
+	send("DELETE", "/album/notes.txt", "", nil)
+
+	if stats := queue.Deliver(time.Now()); stats != (notify.Stats{Delivered: 5}) {
+		t.Errorf("unexpected delivery stats %+v", stats)
+	}
+	expected := "ObjectCreated:Put album/photos/a+b.jpg, ObjectCreated:Copy album/photos/c.jpg, " +
+		"ObjectCreated:Copy archive/photos/c.jpg, ObjectRemoved:Delete album/photos/c.jpg, ObjectRemoved:Delete album/photos/a+b.jpg"
+	if events := endpoint.received(); events != expected {
+		t.Errorf("expected events %q but got %q", expected, events)
+	}
+	if queue.Pending() != 0 {
+		t.Errorf("expected an empty queue, %d delivery(ies) pending", queue.Pending())
+	}
+
+	for _, tt := range []struct {
+		name         string
+		bucket       string
+		body         string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"invalid endpoint", "album", "<NotificationConfiguration><WebhookConfiguration><Endpoint>ftp://gallery</Endpoint><Event>s3:ObjectCreated:*</Event></WebhookConfiguration></NotificationConfiguration>", http.StatusBadRequest, "InvalidArgument"},
+		{"unknown event", "album", "<NotificationConfiguration><WebhookConfiguration><Endpoint>http://gallery</Endpoint><Event>s3:ObjectRestore:*</Event></WebhookConfiguration></NotificationConfiguration>", http.StatusBadRequest, "InvalidArgument"},
+		{"two prefix rules", "album", "<NotificationConfiguration><WebhookConfiguration><Endpoint>http://gallery</Endpoint><Event>s3:ObjectCreated:*</Event><Filter><S3Key><FilterRule><Name>prefix</Name><Value>a</Value></FilterRule><FilterRule><Name>Prefix</Name><Value>b</Value></FilterRule></S3Key></Filter></WebhookConfiguration></NotificationConfiguration>", http.StatusBadRequest, "InvalidArgument"},
+		{"malformed XML", "album", "<NotificationConfiguration>", http.StatusBadRequest, "MalformedXML"},
+		{"missing bucket", "nowhere", "<NotificationConfiguration></NotificationConfiguration>", http.StatusNotFound, "NoSuchBucket"},
+	} {
+		rr := send("PUT", "/"+tt.bucket+"/?notification", tt.body, nil)
+		if rr.Code != tt.expectedCode || errorCode(t, rr) != tt.expectedErr {
+			t.Errorf("%s: expected %s but got %d: %s", tt.name, tt.expectedErr, rr.Code, rr.Body.String())
+		}
+	}
+
+	// An empty configuration disables the notifications
+	send("PUT", "/album/?notification", "<NotificationConfiguration></NotificationConfiguration>", nil)
+	send("PUT", "/album/photos/d.jpg", "pixels", nil)
+	if queue.Pending() != 0 {
+		t.Errorf("expected no event once the notifications are disabled")
+	}
+}
+
+// Test that failed deliveries stay on disk and are retried after a backoff, even after a restart
+func TestNotificationQueueRetry(t *testing.T) {
+	endpoint := &webhook{failing: true}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	dir := t.TempDir()
+	queue, err := notify.NewQueue(dir)
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	s := storage.NewMemoryStorage()
+	mustCreateBucket(t, s, "album")
+	err = s.PutBucketNotification("album", dto.NotificationConfiguration{Webhooks: []dto.WebhookConfiguration{
+		{Endpoint: server.URL, Events: []string{"s3:ObjectCreated:Put"}},
+	}})
+	if err != nil {
+		t.Fatalf("PutBucketNotification: %v", err)
+	}
+	ns := notify.NewStorage(s, queue, "us-east-1")
+	mustPut(t, ns, "album", "a.jpg", "first")
+	mustPut(t, ns, "album", "b.jpg", "second")
+
+	now := time.Now()
+	// The second delivery waits for the first one, to keep the events in order
+	if stats := queue.Deliver(now); stats != (notify.Stats{Failed: 1}) {
+		t.Errorf("unexpected delivery stats %+v", stats)
+	}
+
+	// The queue is read back from disk after a restart
+	endpoint.mu.Lock()
+	endpoint.failing = false
+	endpoint.mu.Unlock()
+	queue, err = notify.NewQueue(dir)
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	if queue.Pending() != 2 {
+		t.Fatalf("expected 2 pending deliveries, got %d", queue.Pending())
+	}
+	if stats := queue.Deliver(now); stats != (notify.Stats{}) {
+		t.Errorf("the failed delivery should not be retried before its backoff, got %+v", stats)
+	}
+	if stats := queue.Deliver(now.Add(2 * time.Second)); stats != (notify.Stats{Delivered: 2}) {
+		t.Errorf("unexpected delivery stats %+v", stats)
+	}
+	if events := endpoint.received(); events != "ObjectCreated:Put album/a.jpg, ObjectCreated:Put album/b.jpg" {
+		t.Errorf("unexpected events %q", events)
+	}
+	if queue.Pending() != 0 {
+		t.Errorf("expected an empty queue, %d delivery(ies) pending", queue.Pending())
+	}
+}
+
+// Test that the events of encrypted objects give the size and ETag of the plaintext, not of the stored content
+func TestEncryptedObjectNotifications(t *testing.T) {
+	endpoint := &webhook{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	queue, err := notify.NewQueue(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	s := storage.NewFileStorage(t.TempDir())
+	mustCreateBucket(t, s, "private-album-1")
+	err = s.PutBucketNotification("private-album-1", dto.NotificationConfiguration{Webhooks: []dto.WebhookConfiguration{
+		{Endpoint: server.URL, Events: []string{"s3:ObjectCreated:*"}},
+	}})
+	if err != nil {
+		t.Fatalf("PutBucketNotification: %v", err)
+	}
+	ns := notify.NewStorage(s, queue, "us-east-1")
+	r := router.SetupRouterWithConfig(ns, config.Config{MasterKey: testMasterKey})
+
+	content := testContent(150 << 10)
+	req, _ := http.NewRequest("PUT", "/private-album-1/photo.jpg", bytes.NewReader(content))
+	req.Header.Set("X-Amz-Server-Side-Encryption", "AES256")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	// La taille d'un upload multipart est lue dans les trailers, avant que la couche de chiffrement ne l'enregistre
+	es := storage.NewEncryptedStorage(ns, testMasterKey)
+	uploadID, err := es.CreateMultipartUpload("private-album-1", "video.mp4", dto.ObjectMetadata{Encryption: &dto.Encryption{Algorithm: dto.EncryptionAES256}})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	etag, err := es.UploadPart("private-album-1", "video.mp4", uploadID, 1, strings.NewReader("a short video"))
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	if _, err := es.CompleteMultipartUpload("private-album-1", "video.mp4", uploadID, []dto.CompletedPart{{PartNumber: 1, ETag: etag}}); err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+
+	if stats := queue.Deliver(time.Now()); stats != (notify.Stats{Delivered: 2}) {
+		t.Fatalf("unexpected delivery stats %+v", stats)
+	}
+	endpoint.mu.Lock()
+	defer endpoint.mu.Unlock()
+	if len(endpoint.records) != 2 {
+		t.Fatalf("expected 2 records, got %+v", endpoint.records)
+	}
+	if object := endpoint.records[0].S3.Object; object.Size != int64(len(content)) || object.ETag != md5Hex(content) {
+		t.Errorf("expected size %d and ETag %s for the PUT, got %+v", len(content), md5Hex(content), object)
+	}
+	if object := endpoint.records[1].S3.Object; object.Size != int64(len("a short video")) {
+		t.Errorf("expected size %d for the multipart upload, got %+v", len("a short video"), object)
+	}
+}
+
+// crashingStorage simulates a server stopped in the middle of an operation: the backend write
+// happens, unless before is set, then the call never returns. With err set, the operation fails
+// without writing anything.
+type crashingStorage struct {
+	storage.Storage
+	before bool
+	err    error
+}
+
+func (cs *crashingStorage) AddObject(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	if cs.err != nil {
+		return dto.ObjectInfo{}, cs.err
+	}
+	if !cs.before {
+		cs.Storage.AddObject(bucketName, objectName, data, metadata)
+	}
+	panic("server stopped")
+}
+
+func (cs *crashingStorage) DeleteObject(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error) {
+	if !cs.before {
+		cs.Storage.DeleteObject(bucketName, objectName, versionID)
+	}
+	panic("server stopped")
+}
+
+// Test that the events of operations interrupted by a stop of the server are published at the next start
+func TestNotificationIntentReplay(t *testing.T) {
+	endpoint := &webhook{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	dir := t.TempDir()
+	queue, err := notify.NewQueue(dir)
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	s := storage.NewMemoryStorage()
+	mustCreateBucket(t, s, "album")
+	err = s.PutBucketNotification("album", dto.NotificationConfiguration{Webhooks: []dto.WebhookConfiguration{
+		{Endpoint: server.URL, Events: []string{"s3:ObjectCreated:*", "s3:ObjectRemoved:*"}},
+	}})
+	if err != nil {
+		t.Fatalf("PutBucketNotification: %v", err)
+	}
+	mustPut(t, s, "album", "old.jpg", "old")
+	mustPut(t, s, "album", "kept.jpg", "kept")
+
+	cs := &crashingStorage{Storage: s}
+	ns := notify.NewStorage(cs, queue, "us-east-1")
+	crash := func(operation func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected the operation to be interrupted")
+			}
+		}()
+		operation()
+	}
+	crash(func() { ns.AddObject("album", "a.jpg", strings.NewReader("pixels"), dto.ObjectMetadata{}) })
+	crash(func() { ns.DeleteObject("album", "old.jpg", "") })
+	// Stopped before the backend write: nothing happened
+	cs.before = true
+	crash(func() { ns.AddObject("album", "b.jpg", strings.NewReader("pixels"), dto.ObjectMetadata{}) })
+	crash(func() { ns.DeleteObject("album", "kept.jpg", "") })
+	// A failed operation drops its intent at once
+	cs.err = errors.New("disk full")
+	if _, err := ns.AddObject("album", "c.jpg", strings.NewReader("pixels"), dto.ObjectMetadata{}); err == nil {
+		t.Fatalf("expected the upload to fail")
+	}
+
+	intents := filepath.Join(dir, "intents")
+	if entries, _ := os.ReadDir(intents); len(entries) != 4 || queue.Pending() != 0 {
+		t.Fatalf("expected 4 intents and no delivery before the restart, got %d and %d", len(entries), queue.Pending())
+	}
+
+	queue, err = notify.NewQueue(dir)
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	notify.NewStorage(s, queue, "us-east-1")
+	if entries, _ := os.ReadDir(intents); len(entries) != 0 {
+		t.Errorf("expected the intents to be dropped once replayed, %d left", len(entries))
+	}
+	if stats := queue.Deliver(time.Now()); stats != (notify.Stats{Delivered: 2}) {
+		t.Errorf("unexpected delivery stats %+v", stats)
+	}
+	if events := endpoint.received(); events != "ObjectCreated:Put album/a.jpg, ObjectRemoved:Delete album/old.jpg" {
+		t.Errorf("unexpected events %q", events)
+	}
+}
@@ -0,0 +1,276 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func mustPutMeta(t *testing.T, s storage.Storage, bucketName, objectName, content string, metadata dto.ObjectMetadata) dto.ObjectInfo {
+	t.Helper()
+	info, err := s.AddObject(bucketName, objectName, strings.NewReader(content), metadata)
+	if err != nil {
+		t.Fatalf("AddObject(%s/%s): %v", bucketName, objectName, err)
+	}
+	return info
+}
+
+func retainedFor(mode string, d time.Duration) *dto.ObjectLock {
+	return &dto.ObjectLock{Mode: mode, RetainUntilDate: time.Now().Add(d).UTC()}
+}
+
+// Test that locked versions cannot be deleted, overwritten or unlocked on every backend
+func TestObjectLockStorage(t *testing.T) {
+	for _, backend := range storage.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			inner, err := storage.NewBackend(backend, storage.BackendConfig{Root: t.TempDir()})
+			if err != nil {
+				t.Fatalf("could not create backend: %v", err)
+			}
+			s := storage.NewObjectLockStorage(inner)
+			mustCreateBucket(t, s, "plain")
+			mustCreateBucket(t, s, "keepsakes")
+
+			if _, err := s.AddObject("plain", "a.jpg", strings.NewReader("a"), dto.ObjectMetadata{Lock: retainedFor(dto.RetentionGovernance, time.Hour)}); !errors.Is(err, storage.ErrObjectLockNotEnabled) {
+				t.Errorf("expected ErrObjectLockNotEnabled on a bucket without object lock but got %v", err)
+			}
+			if _, err := s.GetObjectLockConfiguration("plain"); !errors.Is(err, storage.ErrNoSuchObjectLockConfiguration) {
+				t.Errorf("expected ErrNoSuchObjectLockConfiguration but got %v", err)
+			}
+
+			if err := s.PutObjectLockConfiguration("keepsakes", dto.ObjectLockConfiguration{ObjectLockEnabled: dto.ObjectLockEnabled}); err != nil {
+				t.Fatalf("PutObjectLockConfiguration: %v", err)
+			}
+			mustPutMeta(t, s, "keepsakes", "governance.jpg", "g", dto.ObjectMetadata{Lock: retainedFor(dto.RetentionGovernance, time.Hour)})
+			mustPutMeta(t, s, "keepsakes", "compliance.jpg", "c", dto.ObjectMetadata{Lock: retainedFor(dto.RetentionCompliance, time.Hour)})
+			mustPutMeta(t, s, "keepsakes", "hold.jpg", "h", dto.ObjectMetadata{Lock: &dto.ObjectLock{LegalHold: true}})
+			mustPutMeta(t, s, "keepsakes", "free.jpg", "f", dto.ObjectMetadata{})
+			// A retention that has already ended no longer protects the object
+			mustPutMeta(t, inner, "keepsakes", "expired.jpg", "e", dto.ObjectMetadata{Lock: retainedFor(dto.RetentionCompliance, -time.Hour)})
+
+			for _, key := range []string{"governance.jpg", "compliance.jpg", "hold.jpg"} {
+				if _, err := s.DeleteObject("keepsakes", key, ""); !errors.Is(err, storage.ErrObjectLocked) {
+					t.Errorf("%s: expected ErrObjectLocked on delete but got %v", key, err)
+				}
+				if _, err := s.AddObject("keepsakes", key, strings.NewReader("overwrite"), dto.ObjectMetadata{}); !errors.Is(err, storage.ErrObjectLocked) {
+					t.Errorf("%s: expected ErrObjectLocked on overwrite but got %v", key, err)
+				}
+				if _, err := s.CopyObject("keepsakes", "free.jpg", "", "keepsakes", key, nil); !errors.Is(err, storage.ErrObjectLocked) {
+					t.Errorf("%s: expected ErrObjectLocked on copy but got %v", key, err)
+				}
+				expectContent(t, s, "keepsakes", key, "", key[:1])
+			}
+			if err := s.DeleteBucket("keepsakes"); !errors.Is(err, storage.ErrObjectLocked) {
+				t.Errorf("expected ErrObjectLocked on bucket deletion but got %v", err)
+			}
+			if err := storage.CheckRemovable(s, "keepsakes", "hold.jpg"); !errors.Is(err, storage.ErrObjectLocked) {
+				t.Errorf("expected CheckRemovable to report the legal hold but got %v", err)
+			}
+
+			// The retention can be extended, but not shortened or removed without bypassing GOVERNANCE
+			setRetention := func(s storage.Storage, key string, lock *dto.ObjectLock) error {
+				return s.UpdateObjectMetadata("keepsakes", key, "", func(metadata *dto.ObjectMetadata) error {
+					metadata.Lock = lock
+					return nil
+				})
+			}
+			if err := setRetention(s, "compliance.jpg", retainedFor(dto.RetentionCompliance, 2*time.Hour)); err != nil {
+				t.Errorf("extending a retention: %v", err)
+			}
+			if err := setRetention(s, "compliance.jpg", retainedFor(dto.RetentionGovernance, 3*time.Hour)); !errors.Is(err, storage.ErrObjectLocked) {
+				t.Errorf("expected ErrObjectLocked when switching to GOVERNANCE but got %v", err)
+			}
+			if err := setRetention(storage.WithGovernanceBypass(s), "compliance.jpg", nil); !errors.Is(err, storage.ErrObjectLocked) {
+				t.Errorf("expected ErrObjectLocked when removing a COMPLIANCE retention but got %v", err)
+			}
+			if err := setRetention(s, "governance.jpg", retainedFor(dto.RetentionGovernance, time.Minute)); !errors.Is(err, storage.ErrObjectLocked) {
+				t.Errorf("expected ErrObjectLocked when shortening a retention but got %v", err)
+			}
+			if err := setRetention(storage.WithGovernanceBypass(s), "governance.jpg", retainedFor(dto.RetentionGovernance, time.Minute)); err != nil {
+				t.Errorf("shortening a GOVERNANCE retention with bypass: %v", err)
+			}
+			if err := setRetention(s, "free.jpg", retainedFor(dto.RetentionGovernance, time.Hour)); err != nil {
+				t.Errorf("adding a retention: %v", err)
+			}
+			if info, err := s.StatObject("keepsakes", "free.jpg", ""); err != nil || info.Lock == nil || info.Lock.Mode != dto.RetentionGovernance {
+				t.Errorf("retention should be stored with the object, got %+v, %v", info.Lock, err)
+			}
+
+			// GOVERNANCE can be bypassed, COMPLIANCE and legal holds cannot
+			if _, err := storage.WithGovernanceBypass(s).DeleteObject("keepsakes", "governance.jpg", ""); err != nil {
+				t.Errorf("deleting a GOVERNANCE object with bypass: %v", err)
+			}
+			for _, key := range []string{"compliance.jpg", "hold.jpg"} {
+				if _, err := storage.WithGovernanceBypass(s).DeleteObject("keepsakes", key, ""); !errors.Is(err, storage.ErrObjectLocked) {
+					t.Errorf("%s: expected ErrObjectLocked with bypass but got %v", key, err)
+				}
+			}
+			if _, err := s.DeleteObject("keepsakes", "expired.jpg", ""); err != nil {
+				t.Errorf("deleting an object whose retention has ended: %v", err)
+			}
+
+			// Removing the legal hold releases the object
+			if err := setRetention(s, "hold.jpg", nil); err != nil {
+				t.Fatalf("removing a legal hold: %v", err)
+			}
+			if _, err := s.DeleteObject("keepsakes", "hold.jpg", ""); err != nil {
+				t.Errorf("deleting an object after removing its legal hold: %v", err)
+			}
+		})
+	}
+}
+
+// Test that in a versioned bucket locked versions are kept while delete markers and new versions are allowed
+func TestObjectLockVersioned(t *testing.T) {
+	for _, backend := range storage.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			inner, err := storage.NewBackend(backend, storage.BackendConfig{Root: t.TempDir()})
+			if err != nil {
+				t.Fatalf("could not create backend: %v", err)
+			}
+			s := storage.NewObjectLockStorage(inner)
+			mustCreateBucket(t, s, "keepsakes")
+			if err := s.PutBucketVersioning("keepsakes", storage.VersioningEnabled); err != nil {
+				t.Fatalf("PutBucketVersioning: %v", err)
+			}
+			err = s.PutObjectLockConfiguration("keepsakes", dto.ObjectLockConfiguration{
+				ObjectLockEnabled: dto.ObjectLockEnabled,
+				Rule:              &dto.ObjectLockRule{DefaultRetention: dto.DefaultRetention{Mode: dto.RetentionCompliance, Days: 1}},
+			})
+			if err != nil {
+				t.Fatalf("PutObjectLockConfiguration: %v", err)
+			}
+
+			// The default retention applies to new versions, including copies
+			first := mustPutMeta(t, s, "keepsakes", "photo.jpg", "v1", dto.ObjectMetadata{})
+			if !first.Lock.Retained(time.Now().Add(23*time.Hour)) || first.Lock.Mode != dto.RetentionCompliance {
+				t.Errorf("expected the default retention but got %+v", first.Lock)
+			}
+			copied, err := s.CopyObject("keepsakes", "photo.jpg", "", "keepsakes", "copy.jpg", nil)
+			if err != nil || copied.Lock == nil || copied.Lock.Mode != dto.RetentionCompliance {
+				t.Errorf("expected the default retention on the copy but got %+v, %v", copied.Lock, err)
+			}
+
+			second := mustPutMeta(t, s, "keepsakes", "photo.jpg", "v2", dto.ObjectMetadata{})
+			result, err := s.DeleteObject("keepsakes", "photo.jpg", "")
+			if err != nil || !result.DeleteMarker {
+				t.Fatalf("expected a delete marker but got %+v, %v", result, err)
+			}
+			for _, versionID := range []string{first.VersionID, second.VersionID} {
+				if _, err := s.DeleteObject("keepsakes", "photo.jpg", versionID); !errors.Is(err, storage.ErrObjectLocked) {
+					t.Errorf("version %s: expected ErrObjectLocked but got %v", versionID, err)
+				}
+			}
+			// The delete marker itself is not locked
+			if _, err := s.DeleteObject("keepsakes", "photo.jpg", result.VersionID); err != nil {
+				t.Errorf("deleting the delete marker: %v", err)
+			}
+			expectContent(t, s, "keepsakes", "photo.jpg", first.VersionID, "v1")
+		})
+	}
+}
+
+// Test object lock over HTTP: bucket configuration, lock headers, retention and legal hold
+// sub-resources and the AccessDenied errors
+func TestObjectLockRoutes(t *testing.T) {
+	r := router.SetupRouterWithStorage(storage.NewMemoryStorage())
+	until := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	send := func(method, url, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	send("PUT", "/plain/", "", nil)
+	send("PUT", "/keepsakes/", "", map[string]string{"X-Amz-Bucket-Object-Lock-Enabled": "true"})
+
+	rr := send("GET", "/keepsakes/?object-lock", "", nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "<ObjectLockEnabled>Enabled</ObjectLockEnabled>") {
+		t.Errorf("unexpected object lock configuration %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = send("GET", "/plain/?object-lock", "", nil)
+	if rr.Code != http.StatusNotFound || errorCode(t, rr) != "ObjectLockConfigurationNotFoundError" {
+		t.Errorf("expected ObjectLockConfigurationNotFoundError but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	lockHeaders := map[string]string{"X-Amz-Object-Lock-Mode": "GOVERNANCE", "X-Amz-Object-Lock-Retain-Until-Date": until}
+	rr = send("PUT", "/plain/photo.jpg", "photo", lockHeaders)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidRequest" {
+		t.Errorf("expected InvalidRequest on a bucket without object lock but got %d", rr.Code)
+	}
+	for name, headers := range map[string]map[string]string{
+		"mode without date": {"X-Amz-Object-Lock-Mode": "GOVERNANCE"},
+		"unknown mode":      {"X-Amz-Object-Lock-Mode": "FOREVER", "X-Amz-Object-Lock-Retain-Until-Date": until},
+		"past date":         {"X-Amz-Object-Lock-Mode": "GOVERNANCE", "X-Amz-Object-Lock-Retain-Until-Date": "2020-01-01T00:00:00Z"},
+		"legal hold":        {"X-Amz-Object-Lock-Legal-Hold": "MAYBE"},
+	} {
+		if rr := send("PUT", "/keepsakes/photo.jpg", "photo", headers); rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidArgument" {
+			t.Errorf("%s: expected InvalidArgument but got %d", name, rr.Code)
+		}
+	}
+
+	if rr := send("PUT", "/keepsakes/photo.jpg", "photo", lockHeaders); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rr = send("HEAD", "/keepsakes/photo.jpg", "", nil)
+	if rr.Header().Get("X-Amz-Object-Lock-Mode") != "GOVERNANCE" || rr.Header().Get("X-Amz-Object-Lock-Retain-Until-Date") != until || rr.Header().Get("X-Amz-Object-Lock-Legal-Hold") != "OFF" {
+		t.Errorf("unexpected HEAD headers %v", rr.Header())
+	}
+	rr = send("GET", "/keepsakes/photo.jpg?retention", "", nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "<Mode>GOVERNANCE</Mode>") || !strings.Contains(rr.Body.String(), "<RetainUntilDate>"+until+"</RetainUntilDate>") {
+		t.Errorf("unexpected retention %d: %s", rr.Code, rr.Body.String())
+	}
+
+	expectDenied := func(name string, rr *httptest.ResponseRecorder) {
+		t.Helper()
+		if rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessDenied" {
+			t.Errorf("%s: expected AccessDenied but got %d: %s", name, rr.Code, rr.Body.String())
+		}
+	}
+	expectDenied("delete", send("DELETE", "/keepsakes/photo.jpg", "", nil))
+	expectDenied("overwrite", send("PUT", "/keepsakes/photo.jpg", "other", nil))
+	// Batch deletes and moves report the refusal for the key
+	if rr := send("POST", "/keepsakes/?delete", "<Delete><Object><Key>photo.jpg</Key></Object></Delete>", nil); !strings.Contains(rr.Body.String(), "<Error><Key>photo.jpg</Key><Code>AccessDenied</Code>") {
+		t.Errorf("batch delete: expected AccessDenied for the key but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := send("POST", "/keepsakes/?move", "<Move><Object><Key>photo.jpg</Key></Object><TargetBucket>plain</TargetBucket></Move>", nil); !strings.Contains(rr.Body.String(), "<Error><Key>photo.jpg</Key><Code>AccessDenied</Code>") {
+		t.Errorf("move: expected AccessDenied for the key but got %d: %s", rr.Code, rr.Body.String())
+	}
+	expectDenied("delete bucket", send("DELETE", "/keepsakes/", "", nil))
+	if rr := send("HEAD", "/plain/photo.jpg", "", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("a refused move should not copy the object, got %d", rr.Code)
+	}
+
+	shorter := "<Retention><Mode>GOVERNANCE</Mode><RetainUntilDate>" + time.Now().Add(time.Minute).UTC().Format(time.RFC3339) + "</RetainUntilDate></Retention>"
+	expectDenied("shorten retention", send("PUT", "/keepsakes/photo.jpg?retention", shorter, nil))
+	if rr := send("PUT", "/keepsakes/photo.jpg?retention", shorter, map[string]string{"X-Amz-Bypass-Governance-Retention": "true"}); rr.Code != http.StatusOK {
+		t.Errorf("shortening with bypass: expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if rr := send("PUT", "/keepsakes/photo.jpg?legal-hold", "<LegalHold><Status>ON</Status></LegalHold>", nil); rr.Code != http.StatusOK {
+		t.Fatalf("legal hold: expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := send("GET", "/keepsakes/photo.jpg?legal-hold", "", nil); !strings.Contains(rr.Body.String(), "<Status>ON</Status>") {
+		t.Errorf("unexpected legal hold: %s", rr.Body.String())
+	}
+	expectDenied("delete under legal hold", send("DELETE", "/keepsakes/photo.jpg", "", map[string]string{"X-Amz-Bypass-Governance-Retention": "true"}))
+
+	send("PUT", "/keepsakes/photo.jpg?legal-hold", "<LegalHold><Status>OFF</Status></LegalHold>", nil)
+	if rr := send("DELETE", "/keepsakes/photo.jpg", "", map[string]string{"X-Amz-Bypass-Governance-Retention": "true"}); rr.Code != http.StatusNoContent {
+		t.Errorf("delete with bypass: expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := send("DELETE", "/keepsakes/", "", nil); rr.Code != http.StatusNoContent {
+		t.Errorf("deleting a bucket without locked objects: expected status %d but got %d", http.StatusNoContent, rr.Code)
+	}
+}
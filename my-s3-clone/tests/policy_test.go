@@ -0,0 +1,374 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/config"
+	"my-s3-clone/dto"
+	"my-s3-clone/policy"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// Policy publishing the photos of the album bucket, as in the S3 documentation
+const publicAlbumPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [{
+    "Sid": "PublicRead",
+    "Effect": "Allow",
+    "Principal": "*",
+    "Action": ["s3:GetObject", "s3:GetObjectVersion"],
+    "Resource": "arn:aws:s3:::album/*"
+  }, {
+    "Sid": "FriendsList",
+    "Effect": "Allow",
+    "Principal": {"AWS": "` + otherAccessKey + `"},
+    "Action": "s3:ListBucket",
+    "Resource": "arn:aws:s3:::album"
+  }]
+}`
+
+// Test the validation of bucket policies
+func TestBucketPolicyValidation(t *testing.T) {
+	statement := func(fields string) string {
+		return `{"Version": "2012-10-17", "Statement": [{` + fields + `}]}`
+	}
+
+	tests := []struct {
+		name   string
+		policy string
+		valid  bool
+	}{
+		{"public read", publicAlbumPolicy, true},
+		{"all actions", statement(`"Effect": "Deny", "Principal": {"AWS": ["a", "b"]}, "Action": "s3:*", "Resource": ["arn:aws:s3:::album", "arn:aws:s3:::album/*"]`), true},
+		{"not json", `{"Statement": [`, false},
+		{"no statement", `{"Version": "2012-10-17", "Statement": []}`, false},
+		{"unknown version", `{"Version": "2020-01-01", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::album/*"}]}`, false},
+		{"unknown effect", statement(`"Effect": "Maybe", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::album/*"`), false},
+		{"no principal", statement(`"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::album/*"`), false},
+		{"service principal", statement(`"Effect": "Allow", "Principal": {"Service": "lambda.amazonaws.com"}, "Action": "s3:GetObject", "Resource": "arn:aws:s3:::album/*"`), false},
+		{"other service action", statement(`"Effect": "Allow", "Principal": "*", "Action": "iam:PassRole", "Resource": "arn:aws:s3:::album/*"`), false},
+		{"other bucket", statement(`"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::private/*"`), false},
+		{"not an arn", statement(`"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "album/*"`), false},
+		{"condition", statement(`"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::album/*", "Condition": {"IpAddress": {"aws:SourceIp": "10.0.0.0/8"}}`), false},
+		{"too large", statement(`"Sid": "` + strings.Repeat("x", policy.MaxSize) + `", "Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::album/*"`), false},
+	}
+	for _, tt := range tests {
+		_, err := policy.Parse("album", []byte(tt.policy))
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid=%v but got %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+// Test the evaluation of policy statements
+func TestPolicyEvaluate(t *testing.T) {
+	bucketPolicy, err := policy.Parse("album", []byte(`{"Statement": [
+		{"Effect": "Allow", "Principal": "*", "Action": "s3:Get*", "Resource": "arn:aws:s3:::album/*"},
+		{"Effect": "Deny", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::album/private/*"},
+		{"Effect": "Allow", "Principal": {"AWS": "`+otherAccessKey+`"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::album/2024/??/*"}
+	]}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		principal string
+		action    string
+		key       string
+		expected  policy.Decision
+	}{
+		{"anonymous read", "", policy.GetObject, "2024/photo.jpg", policy.Allowed},
+		{"action case", "", "S3:GETOBJECT", "2024/photo.jpg", policy.Allowed},
+		{"deny wins", otherAccessKey, policy.GetObject, "private/photo.jpg", policy.Denied},
+		{"bucket resource", "", policy.GetObject, "", policy.NotApplicable},
+		{"other action", "", policy.DeleteObject, "2024/photo.jpg", policy.NotApplicable},
+		{"named principal", otherAccessKey, policy.PutObject, "2024/06/photo.jpg", policy.Allowed},
+		{"single character wildcard", otherAccessKey, policy.PutObject, "2024/6/photo.jpg", policy.NotApplicable},
+		{"other principal", testAccessKey, policy.PutObject, "2024/06/photo.jpg", policy.NotApplicable},
+		{"anonymous is not a named principal", "", policy.PutObject, "2024/06/photo.jpg", policy.NotApplicable},
+	}
+	for _, tt := range tests {
+		if decision := policy.Evaluate(&bucketPolicy, tt.principal, tt.action, policy.Resource("album", tt.key)); decision != tt.expected {
+			t.Errorf("%s: expected decision %d but got %d", tt.name, tt.expected, decision)
+		}
+	}
+	if decision := policy.Evaluate(nil, "", policy.GetObject, policy.Resource("album", "photo.jpg")); decision != policy.NotApplicable {
+		t.Errorf("expected no decision without policy but got %d", decision)
+	}
+}
+
+// policyTestRouter returns a router with two users, and a function sending requests signed
+// with the given access key ("" for an anonymous request)
+func policyTestRouter() func(method, url, body, accessKey string, headers map[string]string) *httptest.ResponseRecorder {
+	cfg := config.Config{
+		Region:      testRegion,
+		Credentials: map[string]string{testAccessKey: testSecretKey, otherAccessKey: otherSecretKey},
+	}
+	r := router.SetupRouterWithConfig(storage.NewMemoryStorage(), cfg)
+
+	return func(method, url, body, accessKey string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "http://localhost"+url, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		if accessKey != "" {
+			auth.SignRequest(req, accessKey, cfg.Credentials[accessKey], testRegion, time.Now())
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+}
+
+// Test a shared album published read-only by its bucket policy while the other buckets stay private
+func TestBucketPolicyRoutes(t *testing.T) {
+	send := policyTestRouter()
+	for _, url := range []string{"/album/", "/private/"} {
+		if rr := send("PUT", url, "", testAccessKey, nil); rr.Code != http.StatusOK {
+			t.Fatalf("create %s: expected status %d but got %d: %s", url, http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if rr := send("PUT", url+"photo.jpg", "pixels", testAccessKey, nil); rr.Code != http.StatusOK {
+			t.Fatalf("put %s: expected status %d but got %d: %s", url, http.StatusOK, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Without policy, only the owner has access
+	if rr := send("GET", "/album/?policy", "", testAccessKey, nil); rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchBucketPolicy" {
+		t.Errorf("expected NoSuchBucketPolicy but got %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, accessKey := range []string{"", otherAccessKey} {
+		if rr := send("GET", "/album/photo.jpg", "", accessKey, nil); rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessDenied" {
+			t.Errorf("%q: expected AccessDenied before the policy but got %d: %s", accessKey, rr.Code, rr.Body.String())
+		}
+	}
+
+	if rr := send("PUT", "/album/?policy", `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::private/*"}]}`, testAccessKey, nil); rr.Code != http.StatusBadRequest || errorCode(t, rr) != "MalformedPolicy" {
+		t.Errorf("expected MalformedPolicy but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := send("PUT", "/album/?policy", publicAlbumPolicy, otherAccessKey, nil); rr.Code != http.StatusForbidden {
+		t.Errorf("expected a user to be denied the policy of another user's bucket but got %d", rr.Code)
+	}
+	if rr := send("PUT", "/album/?policy", publicAlbumPolicy, testAccessKey, nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	rr := send("GET", "/album/?policy", "", testAccessKey, nil)
+	var stored dto.BucketPolicy
+	if err := json.Unmarshal(rr.Body.Bytes(), &stored); err != nil || len(stored.Statement) != 2 || stored.Statement[1].Principal.AWS[0] != otherAccessKey {
+		t.Errorf("unexpected policy %d: %s (%v)", rr.Code, rr.Body.String(), err)
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		url          string
+		accessKey    string
+		expectedCode int
+	}{
+		{"anonymous read", "GET", "/album/photo.jpg", "", http.StatusOK},
+		{"anonymous head", "HEAD", "/album/photo.jpg", "", http.StatusOK},
+		{"other user read", "GET", "/album/photo.jpg", otherAccessKey, http.StatusOK},
+		{"anonymous read of a missing photo", "GET", "/album/missing.jpg", "", http.StatusNotFound},
+		{"anonymous list", "GET", "/album/", "", http.StatusForbidden},
+		{"other user list", "GET", "/album/", otherAccessKey, http.StatusOK},
+		{"anonymous write", "PUT", "/album/graffiti.jpg", "", http.StatusForbidden},
+		{"other user write", "PUT", "/album/graffiti.jpg", otherAccessKey, http.StatusForbidden},
+		{"other user delete", "DELETE", "/album/photo.jpg", otherAccessKey, http.StatusForbidden},
+		{"other user policy", "GET", "/album/?policy", otherAccessKey, http.StatusForbidden},
+		{"anonymous read of a private bucket", "GET", "/private/photo.jpg", "", http.StatusForbidden},
+		{"other user read of a private bucket", "GET", "/private/photo.jpg", otherAccessKey, http.StatusForbidden},
+		{"anonymous read of a missing bucket", "GET", "/missing/photo.jpg", "", http.StatusForbidden},
+		{"anonymous bucket listing", "GET", "/", "", http.StatusForbidden},
+		{"anonymous bucket creation", "PUT", "/anonymous/", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rr := send(tt.method, tt.url, "", tt.accessKey, nil); rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d: %s", tt.name, tt.expectedCode, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Copying and moving need the right to read the source
+	copySource := map[string]string{"X-Amz-Copy-Source": "/private/photo.jpg"}
+	if rr := send("PUT", "/album/copy.jpg", "", testAccessKey, copySource); rr.Code != http.StatusOK {
+		t.Errorf("expected the owner to copy between their buckets but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := send("PUT", "/other-album/", "", otherAccessKey, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := send("PUT", "/other-album/copy.jpg", "", otherAccessKey, copySource); rr.Code != http.StatusForbidden {
+		t.Errorf("expected the copy of a private photo to be denied but got %d", rr.Code)
+	}
+	if rr := send("PUT", "/other-album/copy.jpg", "", otherAccessKey, map[string]string{"X-Amz-Copy-Source": "/album/photo.jpg"}); rr.Code != http.StatusOK {
+		t.Errorf("expected the copy of a public photo to succeed but got %d: %s", rr.Code, rr.Body.String())
+	}
+	move := "<Move><TargetBucket>other-album</TargetBucket><Object><Key>photo.jpg</Key></Object></Move>"
+	if rr := send("POST", "/album/?move", move, otherAccessKey, nil); !strings.Contains(rr.Body.String(), "<Error><Key>photo.jpg</Key><Code>AccessDenied</Code>") {
+		t.Errorf("expected the move of a read-only photo to be denied but got %d: %s", rr.Code, rr.Body.String())
+	}
+	remove := "<Delete><Object><Key>photo.jpg</Key></Object></Delete>"
+	if rr := send("POST", "/album/?delete", remove, otherAccessKey, nil); !strings.Contains(rr.Body.String(), "<Error><Key>photo.jpg</Key><Code>AccessDenied</Code>") {
+		t.Errorf("expected the batch delete of a read-only photo to be denied but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := send("GET", "/album/photo.jpg", "", testAccessKey, nil); rr.Code != http.StatusOK {
+		t.Errorf("expected the photo to be kept but got %d", rr.Code)
+	}
+
+	// An explicit deny applies to the owner too, except on the policy itself
+	denyAll := `{"Statement": [{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": ["arn:aws:s3:::album", "arn:aws:s3:::album/*"]}]}`
+	if rr := send("PUT", "/album/?policy", denyAll, testAccessKey, nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := send("GET", "/album/photo.jpg", "", testAccessKey, nil); rr.Code != http.StatusForbidden {
+		t.Errorf("expected the owner to be denied but got %d", rr.Code)
+	}
+	if rr := send("DELETE", "/album/?policy", "", testAccessKey, nil); rr.Code != http.StatusNoContent {
+		t.Errorf("expected the owner to remove the policy but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := send("GET", "/album/photo.jpg", "", "", nil); rr.Code != http.StatusForbidden {
+		t.Errorf("expected anonymous reads to be denied once the policy is removed but got %d", rr.Code)
+	}
+}
+
+// Test canned ACLs on buckets and objects
+func TestCannedACL(t *testing.T) {
+	send := policyTestRouter()
+	if rr := send("PUT", "/album/", "", testAccessKey, map[string]string{"x-amz-acl": "public-read"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := send("PUT", "/album/public.jpg", "pixels", testAccessKey, map[string]string{"x-amz-acl": "public-read"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := send("PUT", "/album/private.jpg", "pixels", testAccessKey, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		url          string
+		accessKey    string
+		expectedCode int
+	}{
+		{"anonymous list of a public-read bucket", "GET", "/album/", "", http.StatusOK},
+		{"anonymous read of a public-read object", "GET", "/album/public.jpg", "", http.StatusOK},
+		{"anonymous read of a private object", "GET", "/album/private.jpg", "", http.StatusForbidden},
+		{"other user read of a private object", "GET", "/album/private.jpg", otherAccessKey, http.StatusForbidden},
+		{"anonymous write to a public-read bucket", "PUT", "/album/graffiti.jpg", "", http.StatusForbidden},
+		{"anonymous bucket ACL", "GET", "/album/?acl", "", http.StatusForbidden},
+		{"other user object ACL change", "PUT", "/album/private.jpg?acl", otherAccessKey, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rr := send(tt.method, tt.url, "", tt.accessKey, nil); rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d: %s", tt.name, tt.expectedCode, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := send("GET", "/album/public.jpg?acl", "", testAccessKey, nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "<URI>http://acs.amazonaws.com/groups/global/AllUsers</URI>") || !strings.Contains(rr.Body.String(), `xsi:type="Group"`) {
+		t.Errorf("unexpected object ACL %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = send("GET", "/album/private.jpg?acl", "", testAccessKey, nil)
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "AllUsers") || !strings.Contains(rr.Body.String(), "<ID>"+testAccessKey+"</ID>") {
+		t.Errorf("unexpected object ACL %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// The ACL of an object and of a bucket can be changed afterwards
+	if rr := send("PUT", "/album/private.jpg?acl", "", testAccessKey, map[string]string{"x-amz-acl": "public-read"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := send("GET", "/album/private.jpg", "", "", nil); rr.Code != http.StatusOK {
+		t.Errorf("expected the photo to be public but got %d", rr.Code)
+	}
+	if rr := send("PUT", "/album/?acl", "", testAccessKey, map[string]string{"x-amz-acl": "private"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := send("GET", "/album/", "", "", nil); rr.Code != http.StatusForbidden {
+		t.Errorf("expected the bucket to be private but got %d", rr.Code)
+	}
+
+	// A copy does not inherit the ACL of its source
+	if rr := send("PUT", "/album/copy.jpg", "", testAccessKey, map[string]string{"X-Amz-Copy-Source": "/album/public.jpg"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := send("GET", "/album/copy.jpg", "", "", nil); rr.Code != http.StatusForbidden {
+		t.Errorf("expected the copy to be private but got %d", rr.Code)
+	}
+
+	invalid := []struct {
+		name        string
+		method      string
+		url         string
+		headers     map[string]string
+		expectedErr string
+	}{
+		{"unknown object ACL", "PUT", "/album/photo.jpg", map[string]string{"x-amz-acl": "everyone"}, "InvalidArgument"},
+		{"unknown bucket ACL", "PUT", "/album/?acl", map[string]string{"x-amz-acl": "everyone"}, "InvalidArgument"},
+		{"grant list", "PUT", "/album/?acl", nil, "NotImplemented"},
+	}
+	for _, tt := range invalid {
+		if rr := send(tt.method, tt.url, "", testAccessKey, tt.headers); errorCode(t, rr) != tt.expectedErr {
+			t.Errorf("%s: expected %s but got %d: %s", tt.name, tt.expectedErr, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Without credentials, every request is allowed whatever the ACL
+	r := router.SetupRouterWithStorage(storage.NewMemoryStorage())
+	for _, step := range []struct{ method, url, body string }{{"PUT", "/open/", ""}, {"PUT", "/open/photo.jpg", "pixels"}, {"GET", "/open/photo.jpg", ""}} {
+		req, _ := http.NewRequest(step.method, step.url, strings.NewReader(step.body))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s %s: expected status %d without credentials but got %d", step.method, step.url, http.StatusOK, rr.Code)
+		}
+	}
+}
+
+// Test that a bucket created while authentication was disabled gives owner rights to nobody,
+// until it is assigned to the configured bucket owner
+func TestOwnerlessBucket(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	mustCreateBucket(t, s, "legacy")
+	mustPut(t, s, "legacy", "photo.jpg", "pixels")
+
+	cfg := config.Config{
+		Region:      testRegion,
+		Credentials: map[string]string{testAccessKey: testSecretKey, otherAccessKey: otherSecretKey},
+	}
+	var r http.Handler
+	send := func(method, url, body, accessKey string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "http://localhost"+url, strings.NewReader(body))
+		auth.SignRequest(req, accessKey, cfg.Credentials[accessKey], testRegion, time.Now())
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	r = router.SetupRouterWithConfig(s, cfg)
+	for _, accessKey := range []string{testAccessKey, otherAccessKey} {
+		if rr := send("GET", "/legacy/photo.jpg", "", accessKey); rr.Code != http.StatusForbidden {
+			t.Errorf("%s: expected the read of an ownerless bucket to be denied, got %d", accessKey, rr.Code)
+		}
+		if rr := send("PUT", "/legacy/?policy", publicAlbumPolicy, accessKey); rr.Code != http.StatusForbidden {
+			t.Errorf("%s: expected the policy of an ownerless bucket to be denied, got %d", accessKey, rr.Code)
+		}
+	}
+
+	cfg.BucketOwner = testAccessKey
+	r = router.SetupRouterWithConfig(s, cfg)
+	if info, err := s.GetBucketInfo("legacy"); err != nil || info.Owner != testAccessKey {
+		t.Errorf("expected the bucket to be assigned to %s, got %+v (%v)", testAccessKey, info, err)
+	}
+	if rr := send("GET", "/legacy/photo.jpg", "", testAccessKey); rr.Code != http.StatusOK {
+		t.Errorf("expected the new owner to read the bucket, got %d", rr.Code)
+	}
+	if rr := send("GET", "/legacy/photo.jpg", "", otherAccessKey); rr.Code != http.StatusForbidden {
+		t.Errorf("expected another user to be denied, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// signedPostFields signs a POST policy made of the given conditions and returns the form fields
+// carrying it; the conditions on the signature fields are added to the policy
+func signedPostFields(secretKey string, expiration time.Time, conditions ...string) []string {
+	now := time.Now()
+	credential := testAccessKey + "/" + now.UTC().Format("20060102") + "/" + testRegion + "/s3/aws4_request"
+	date := now.UTC().Format("20060102T150405Z")
+	conditions = append(conditions,
+		`{"x-amz-algorithm": "AWS4-HMAC-SHA256"}`,
+		fmt.Sprintf(`{"x-amz-credential": %q}`, credential),
+		fmt.Sprintf(`{"x-amz-date": %q}`, date))
+	document := fmt.Sprintf(`{"expiration": %q, "conditions": [%s]}`, expiration.UTC().Format(time.RFC3339), strings.Join(conditions, ", "))
+	policy := base64.StdEncoding.EncodeToString([]byte(document))
+
+	signing := auth.SignPostPolicy(policy, testAccessKey, secretKey, testRegion, now)
+	return []string{
+		"policy", policy,
+		"x-amz-algorithm", signing["x-amz-algorithm"],
+		"x-amz-credential", signing["x-amz-credential"],
+		"x-amz-date", signing["x-amz-date"],
+		"x-amz-signature", signing["x-amz-signature"],
+	}
+}
+
+// postForm sends a multipart/form-data upload with the given fields, followed by the file unless it is empty
+func postForm(r http.Handler, url string, fields []string, filename, content string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for i := 0; i+1 < len(fields); i += 2 {
+		writer.WriteField(fields[i], fields[i+1])
+	}
+	if filename != "" {
+		part, _ := writer.CreateFormFile("file", filename)
+		part.Write([]byte(content))
+	}
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", url, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+// Test browser uploads with a signed POST policy: conditions, content-length-range, expiration and responses
+func TestPostObjectUpload(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	mustCreateBucket(t, s, "album")
+	r := router.SetupRouterWithConfig(s, authTestConfig())
+
+	expiration := time.Now().Add(time.Hour)
+	conditions := []string{
+		`{"bucket": "album"}`,
+		`["starts-with", "$key", "uploads/"]`,
+		`["starts-with", "$Content-Type", "image/"]`,
+		`["starts-with", "$x-amz-meta-camera", ""]`,
+		`["content-length-range", 1, 10]`,
+		`{"success_action_status": "201"}`,
+	}
+	signed := signedPostFields(testSecretKey, expiration, conditions...)
+	fields := func(key, contentType string, extra ...string) []string {
+		form := []string{"key", key, "Content-Type", contentType, "x-amz-meta-camera", "x100", "success_action_status", "201"}
+		return append(append(form, extra...), signed...)
+	}
+
+	rr := postForm(r, "/album", fields("uploads/${filename}", "image/jpeg"), "photo.jpg", "pixels")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var response dto.PostResponse
+	if err := xml.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("could not decode PostResponse %q: %v", rr.Body.String(), err)
+	}
+	if response.Bucket != "album" || response.Key != "uploads/photo.jpg" || response.ETag != `"`+md5Hex([]byte("pixels"))+`"` || !strings.HasSuffix(response.Location, "/album/uploads/photo.jpg") {
+		t.Errorf("unexpected PostResponse %+v", response)
+	}
+	expectContent(t, s, "album", "uploads/photo.jpg", "", "pixels")
+	if info, err := s.StatObject("album", "uploads/photo.jpg", ""); err != nil || info.ContentType != "image/jpeg" || info.UserMetadata["camera"] != "x100" {
+		t.Errorf("expected the metadata of the form to be stored, got %+v, %v", info, err)
+	}
+
+	for _, tt := range []struct {
+		name         string
+		fields       []string
+		content      string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"key outside the prefix", fields("other/photo.jpg", "image/jpeg"), "pixels", http.StatusForbidden, "AccessDenied"},
+		{"wrong content type", fields("uploads/notes.txt", "text/plain"), "pixels", http.StatusForbidden, "AccessDenied"},
+		{"field not in the policy", fields("uploads/photo.jpg", "image/jpeg", "x-amz-meta-author", "alice"), "pixels", http.StatusForbidden, "AccessDenied"},
+		{"policy without bucket", append([]string{"key", "uploads/any.jpg"}, signedPostFields(testSecretKey, expiration, `["starts-with", "$key", "uploads/"]`)...), "pixels", http.StatusForbidden, "AccessDenied"},
+		{"policy of another bucket", append([]string{"key", "uploads/other.jpg"}, signedPostFields(testSecretKey, expiration, `{"bucket": "archive"}`, `["starts-with", "$key", "uploads/"]`)...), "pixels", http.StatusForbidden, "AccessDenied"},
+		{"file too large", fields("uploads/large.jpg", "image/jpeg"), "0123456789a", http.StatusBadRequest, "EntityTooLarge"},
+		{"empty file", fields("uploads/empty.jpg", "image/jpeg"), "", http.StatusBadRequest, "EntityTooSmall"},
+		{"expired policy", append([]string{"key", "uploads/late.jpg"}, signedPostFields(testSecretKey, time.Now().Add(-time.Minute), `{"bucket": "album"}`, `["starts-with", "$key", "uploads/"]`)...), "pixels", http.StatusForbidden, "AccessDenied"},
+		{"wrong signature", append([]string{"key", "uploads/forged.jpg"}, signedPostFields("not-the-secret", expiration, `{"bucket": "album"}`, `["starts-with", "$key", "uploads/"]`)...), "pixels", http.StatusForbidden, "SignatureDoesNotMatch"},
+		{"invalid policy", []string{"key", "uploads/a.jpg", "policy", "not base64!", "x-amz-signature", "00"}, "pixels", http.StatusBadRequest, "InvalidPolicyDocument"},
+		{"anonymous upload", []string{"key", "uploads/anonymous.jpg"}, "pixels", http.StatusForbidden, "AccessDenied"},
+		{"missing key", signed, "pixels", http.StatusBadRequest, "InvalidArgument"},
+	} {
+		rr := postForm(r, "/album/", tt.fields, "photo.jpg", tt.content)
+		if rr.Code != tt.expectedCode || errorCode(t, rr) != tt.expectedErr {
+			t.Errorf("%s: expected %s but got %d: %s", tt.name, tt.expectedErr, rr.Code, rr.Body.String())
+		}
+	}
+	for _, key := range []string{"other/photo.jpg", "uploads/large.jpg", "uploads/empty.jpg", "uploads/forged.jpg", "uploads/anonymous.jpg", "uploads/any.jpg", "uploads/other.jpg"} {
+		if _, err := s.StatObject("album", key, ""); err == nil {
+			t.Errorf("%s should not be stored", key)
+		}
+	}
+	if rr := postForm(r, "/album", fields("uploads/nofile.jpg", "image/jpeg"), "", ""); rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidArgument" {
+		t.Errorf("missing file: expected InvalidArgument but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// The browser is redirected to success_action_redirect, or gets 204 by default
+	redirectFields := append([]string{"key", "uploads/b.jpg", "success_action_redirect", "https://gallery.example.com/done?album=1"},
+		signedPostFields(testSecretKey, expiration, `{"bucket": "album"}`, `["starts-with", "$key", "uploads/"]`, `["starts-with", "$success_action_redirect", "https://gallery.example.com/"]`)...)
+	rr = postForm(r, "/album", redirectFields, "b.jpg", "pixels")
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusSeeOther, rr.Code, rr.Body.String())
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil || location.Host != "gallery.example.com" || location.Query().Get("album") != "1" || location.Query().Get("key") != "uploads/b.jpg" ||
+		location.Query().Get("bucket") != "album" || location.Query().Get("etag") != `"`+md5Hex([]byte("pixels"))+`"` {
+		t.Errorf("unexpected redirect %q", rr.Header().Get("Location"))
+	}
+
+	rr = postForm(r, "/album", append([]string{"key", "uploads/c.jpg"}, signedPostFields(testSecretKey, expiration, `{"bucket": "album"}`, `{"key": "uploads/c.jpg"}`)...), "c.jpg", "pixels")
+	if rr.Code != http.StatusNoContent || rr.Header().Get("ETag") == "" {
+		t.Errorf("expected status %d with an ETag but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	expectContent(t, s, "album", "uploads/c.jpg", "", "pixels")
+}
@@ -0,0 +1,277 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/config"
+	"my-s3-clone/dto"
+	"my-s3-clone/quota"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func bucketUsage(report dto.UsageReport, bucketName string) dto.BucketUsage {
+	for _, bucket := range report.Buckets {
+		if bucket.Name == bucketName {
+			return bucket
+		}
+	}
+	return dto.BucketUsage{}
+}
+
+// setBucketOwner attribue un bucket à un propriétaire, comme s'il avait été créé par une requête signée
+func setBucketOwner(t *testing.T, s storage.Storage, bucketName, owner string) {
+	t.Helper()
+	info, err := s.GetBucketInfo(bucketName)
+	if err != nil {
+		t.Fatalf("could not read bucket %s: %v", bucketName, err)
+	}
+	info.Owner = owner
+	if err := s.PutBucketInfo(bucketName, info); err != nil {
+		t.Fatalf("could not set owner of bucket %s: %v", bucketName, err)
+	}
+}
+
+// Test that uploads, copies and multipart completions are refused past the hard quota of a bucket
+func TestBucketQuota(t *testing.T) {
+	for _, backend := range []string{"memory", "fs"} {
+		t.Run(backend, func(t *testing.T) {
+			s, err := storage.NewBackend(backend, storage.BackendConfig{Root: t.TempDir()})
+			if err != nil {
+				t.Fatalf("could not create backend: %v", err)
+			}
+			mustCreateBucket(t, s, "album")
+			qs := quota.NewStorage(s, dto.QuotaConfiguration{Bucket: dto.QuotaLimits{SoftBytes: 10, HardBytes: 20}})
+			r := router.SetupRouterWithConfig(qs, config.Config{})
+
+			do := func(method, url, body string, headers map[string]string) *httptest.ResponseRecorder {
+				req, _ := http.NewRequest(method, url, strings.NewReader(body))
+				for name, value := range headers {
+					req.Header.Set(name, value)
+				}
+				rr := httptest.NewRecorder()
+				r.ServeHTTP(rr, req)
+				return rr
+			}
+			expect := func(rr *httptest.ResponseRecorder, status int, code string) {
+				t.Helper()
+				if rr.Code != status {
+					t.Fatalf("expected status %d but got %d: %s", status, rr.Code, rr.Body.String())
+				}
+				if code != "" && errorCode(t, rr) != code {
+					t.Errorf("expected error %s, got %s", code, rr.Body.String())
+				}
+			}
+
+			expect(do("PUT", "/album/a.jpg", "0123456789", nil), http.StatusOK, "")
+			expect(do("PUT", "/album/b.jpg", "0123456789", nil), http.StatusOK, "")
+			expect(do("PUT", "/album/c.jpg", "x", nil), http.StatusForbidden, "QuotaExceeded")
+			if rr := do("HEAD", "/album/c.jpg", "", nil); rr.Code != http.StatusNotFound {
+				t.Errorf("expected the refused upload not to be stored, got %d", rr.Code)
+			}
+
+			// Remplacer un objet ne compte que la différence de taille
+			expect(do("PUT", "/album/a.jpg", "01234", nil), http.StatusOK, "")
+			expect(do("PUT", "/album/c.jpg", "56789", nil), http.StatusOK, "")
+			expect(do("PUT", "/album/d.jpg", "", map[string]string{"X-Amz-Copy-Source": "/album/c.jpg"}), http.StatusForbidden, "QuotaExceeded")
+			expect(do("DELETE", "/album/a.jpg", "", nil), http.StatusNoContent, "")
+			expect(do("PUT", "/album/d.jpg", "", map[string]string{"X-Amz-Copy-Source": "/album/c.jpg"}), http.StatusOK, "")
+
+			uploadID, err := qs.CreateMultipartUpload("album", "video.mp4", dto.ObjectMetadata{})
+			if err != nil {
+				t.Fatalf("could not create upload: %v", err)
+			}
+			etag, err := qs.UploadPart("album", "video.mp4", uploadID, 1, strings.NewReader("abcdef"))
+			if err != nil {
+				t.Fatalf("could not upload part: %v", err)
+			}
+			if _, err := qs.CompleteMultipartUpload("album", "video.mp4", uploadID, []dto.CompletedPart{{PartNumber: 1, ETag: etag}}); !errors.Is(err, storage.ErrQuotaExceeded) {
+				t.Errorf("expected ErrQuotaExceeded when completing the upload, got %v", err)
+			}
+			if _, err := qs.ListParts("album", "video.mp4", uploadID, 0, 1000); err != nil {
+				t.Errorf("expected the refused upload to be kept, got %v", err)
+			}
+
+			rr := do("GET", "/?usage", "", nil)
+			expect(rr, http.StatusOK, "")
+			var report dto.UsageReport
+			if err := xml.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+				t.Fatalf("could not decode usage report %q: %v", rr.Body.String(), err)
+			}
+			usage := bucketUsage(report, "album")
+			if usage.Bytes != 20 || usage.Objects != 3 {
+				t.Errorf("expected 20 bytes in 3 objects, got %+v", usage.Usage)
+			}
+			if !usage.SoftQuotaExceeded || usage.HardQuotaExceeded || usage.Quota == nil || usage.Quota.HardBytes != 20 {
+				t.Errorf("expected only the soft quota to be exceeded, got %+v", usage)
+			}
+			if report.LastRecount == nil {
+				t.Errorf("expected the report to give the date of the last recount")
+			}
+		})
+	}
+}
+
+// Test that the hard quota of an owner applies to all of its buckets together
+func TestOwnerQuota(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	for _, bucketName := range []string{"main-album-1", "main-album-2", "shared"} {
+		mustCreateBucket(t, s, bucketName)
+	}
+	setBucketOwner(t, s, "main-album-1", "alice")
+	setBucketOwner(t, s, "main-album-2", "alice")
+	qs := quota.NewStorage(s, dto.QuotaConfiguration{Owners: map[string]dto.QuotaLimits{"alice": {HardObjects: 2}}})
+
+	mustPut(t, qs, "main-album-1", "a.jpg", "a")
+	mustPut(t, qs, "main-album-2", "b.jpg", "b")
+	if _, err := qs.AddObject("main-album-2", "c.jpg", strings.NewReader("c"), dto.ObjectMetadata{}); !errors.Is(err, storage.ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded for the third object of alice, got %v", err)
+	}
+	if _, err := qs.CopyObject("main-album-1", "a.jpg", "", "main-album-2", "a.jpg", nil); !errors.Is(err, storage.ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded when copying into a bucket of alice, got %v", err)
+	}
+	// Le bucket sans propriétaire n'est pas concerné, et un objet déplacé hors des buckets d'alice lui libère de la place
+	if _, err := qs.MoveObject("main-album-2", "b.jpg", "shared", "b.jpg", nil); err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	mustPut(t, qs, "main-album-2", "c.jpg", "c")
+
+	report := qs.Usage()
+	if len(report.Owners) != 1 || report.Owners[0].ID != "alice" || report.Owners[0].Objects != 2 || report.Owners[0].Quota == nil {
+		t.Errorf("expected alice to have 2 objects, got %+v", report.Owners)
+	}
+	if usage := bucketUsage(report, "shared"); usage.Objects != 1 || usage.Owner != "" {
+		t.Errorf("expected the moved object to be counted in shared, got %+v", usage)
+	}
+}
+
+// Test that a recount reconciles the counters with the content of the backend
+func TestQuotaRecount(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	mustCreateBucket(t, s, "album")
+	mustPut(t, s, "album", "before.jpg", "12345")
+	qs := quota.NewStorage(s, dto.QuotaConfiguration{})
+
+	// Le premier accès aux compteurs compte les objets déjà stockés
+	if usage := bucketUsage(qs.Usage(), "album"); usage.Bytes != 5 || usage.Objects != 1 {
+		t.Errorf("expected the initial count to find 5 bytes in 1 object, got %+v", usage.Usage)
+	}
+
+	// Les versions archivées occupent de la place
+	if err := qs.PutBucketVersioning("album", storage.VersioningEnabled); err != nil {
+		t.Fatalf("could not enable versioning: %v", err)
+	}
+	mustPut(t, qs, "album", "before.jpg", "123")
+	if _, err := qs.DeleteObject("album", "before.jpg", ""); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if usage := bucketUsage(qs.Usage(), "album"); usage.Bytes != 8 || usage.Objects != 2 {
+		t.Errorf("expected 8 bytes in 2 versions, got %+v", usage.Usage)
+	}
+
+	// Écritures qui ne passent pas par les compteurs
+	mustPut(t, s, "album", "direct.jpg", "1234567")
+	if err := qs.Recount(); err != nil {
+		t.Fatalf("recount failed: %v", err)
+	}
+	if usage := bucketUsage(qs.Usage(), "album"); usage.Bytes != 15 || usage.Objects != 3 {
+		t.Errorf("expected the recount to find 15 bytes in 3 versions, got %+v", usage.Usage)
+	}
+
+	r := router.SetupRouterWithStorage(storage.NewMemoryStorage())
+	req, _ := http.NewRequest("GET", "/?usage", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected status %d without usage tracking, got %d", http.StatusNotImplemented, rr.Code)
+	}
+}
+
+// Test that only the administrator can read the usage report, which lists the buckets of every owner
+func TestUsageEndpointAccess(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	mustCreateBucket(t, s, "album")
+	cfg := authTestConfig()
+	cfg.Credentials[otherAccessKey] = otherSecretKey
+	r := router.SetupRouterWithConfig(quota.NewStorage(s, dto.QuotaConfiguration{}), cfg)
+
+	for _, tt := range []struct {
+		name         string
+		accessKey    string
+		secretKey    string
+		expectedCode int
+	}{
+		{"administrator", testAccessKey, testSecretKey, http.StatusOK},
+		{"other user", otherAccessKey, otherSecretKey, http.StatusForbidden},
+		{"anonymous", "", "", http.StatusForbidden},
+	} {
+		req, _ := http.NewRequest("GET", "/?usage", nil)
+		if tt.accessKey != "" {
+			auth.SignRequest(req, tt.accessKey, tt.secretKey, testRegion, time.Now())
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d: %s", tt.name, tt.expectedCode, rr.Code, rr.Body.String())
+		}
+		if tt.expectedCode == http.StatusForbidden && errorCode(t, rr) != "AccessDenied" {
+			t.Errorf("%s: expected AccessDenied, got %s", tt.name, rr.Body.String())
+		}
+	}
+}
+
+// Test that encrypted objects count for their plaintext size, not for their larger size on disk
+func TestEncryptedObjectQuota(t *testing.T) {
+	s := storage.NewFileStorage(t.TempDir())
+	mustCreateBucket(t, s, "private-album-1")
+	qs := quota.NewStorage(s, dto.QuotaConfiguration{Bucket: dto.QuotaLimits{HardBytes: 70 << 10}})
+	r := router.SetupRouterWithConfig(qs, config.Config{MasterKey: testMasterKey})
+
+	put := func(key string, content []byte, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PUT", "/private-album-1/"+key, bytes.NewReader(content))
+		req.Header.Set("X-Amz-Server-Side-Encryption", "AES256")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Plus d'un bloc chiffré, jusqu'à la limite exacte
+	if rr := put("photo.jpg", testContent(70<<10), nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected an upload up to the limit to be accepted, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := put("more.jpg", []byte("x"), nil); rr.Code != http.StatusForbidden || errorCode(t, rr) != "QuotaExceeded" {
+		t.Errorf("expected QuotaExceeded past the limit, got %d: %s", rr.Code, rr.Body.String())
+	}
+	// Remplacer l'objet par un plus petit compte la différence des tailles en clair
+	if rr := put("photo.jpg", testContent(60<<10), nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected the replacement to be accepted, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := put("copy.jpg", nil, map[string]string{"X-Amz-Copy-Source": "/private-album-1/photo.jpg"}); rr.Code != http.StatusForbidden {
+		t.Errorf("expected the copy to exceed the quota, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := put("small.jpg", testContent(10<<10), nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected the remaining space to be usable, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	expected := int64(70 << 10)
+	if usage := bucketUsage(qs.Usage(), "private-album-1"); usage.Bytes != expected || usage.Objects != 2 {
+		t.Errorf("expected %d bytes in 2 objects, got %+v", expected, usage.Usage)
+	}
+	if err := qs.Recount(); err != nil {
+		t.Fatalf("recount failed: %v", err)
+	}
+	if usage := bucketUsage(qs.Usage(), "private-album-1"); usage.Bytes != expected || usage.Objects != 2 {
+		t.Errorf("expected the recount to find %d bytes in 2 objects, got %+v", expected, usage.Usage)
+	}
+}
@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+const (
+	rangeTestContent = "0123456789abcdefghij"
+	rangeTestETag    = "e1faffb3e614e6c2fba74296962386b7"
+)
+
+func rangeTestRouter(modTime time.Time) http.Handler {
+	mockStorage := &MockStorage{
+		GetObjectFunc: func(bucketName, objectName, versionID string) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+			return newMockObject(rangeTestContent), dto.ObjectInfo{Key: objectName, Size: int64(len(rangeTestContent)), LastModified: modTime, ETag: rangeTestETag}, nil
+		},
+	}
+	return router.SetupRouterWithStorage(mockStorage)
+}
+
+// Test single byte ranges, suffix ranges and unsatisfiable ranges
+func TestDownloadObjectRange(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := rangeTestRouter(modTime)
+
+	tests := []struct {
+		name          string
+		rangeHeader   string
+		ifRange       string
+		expectedCode  int
+		expectedRange string
+		expectedBody  string
+	}{
+		{"no range", "", "", http.StatusOK, "", rangeTestContent},
+		{"first bytes", "bytes=0-4", "", http.StatusPartialContent, "bytes 0-4/20", "01234"},
+		{"open ended", "bytes=15-", "", http.StatusPartialContent, "bytes 15-19/20", "fghij"},
+		{"suffix", "bytes=-3", "", http.StatusPartialContent, "bytes 17-19/20", "hij"},
+		{"end past size", "bytes=18-100", "", http.StatusPartialContent, "bytes 18-19/20", "ij"},
+		{"malformed range ignored", "bytes=abc", "", http.StatusOK, "", rangeTestContent},
+		{"unsatisfiable", "bytes=50-60", "", http.StatusRequestedRangeNotSatisfiable, "bytes */20", ""},
+		{"if-range date matches", "bytes=0-1", modTime.Format(http.TimeFormat), http.StatusPartialContent, "bytes 0-1/20", "01"},
+		{"if-range date changed", "bytes=0-1", modTime.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK, "", rangeTestContent},
+		{"if-range etag matches", "bytes=0-1", `"` + rangeTestETag + `"`, http.StatusPartialContent, "bytes 0-1/20", "01"},
+		{"if-range unknown etag", "bytes=0-1", `"d41d8cd98f00b204e9800998ecf8427e"`, http.StatusOK, "", rangeTestContent},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/videos/clip.mp4", nil)
+		if tt.rangeHeader != "" {
+			req.Header.Set("Range", tt.rangeHeader)
+		}
+		if tt.ifRange != "" {
+			req.Header.Set("If-Range", tt.ifRange)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedCode, rr.Code)
+			continue
+		}
+		if got := rr.Header().Get("Content-Range"); got != tt.expectedRange {
+			t.Errorf("%s: expected Content-Range %q but got %q", tt.name, tt.expectedRange, got)
+		}
+		if tt.expectedCode == http.StatusRequestedRangeNotSatisfiable {
+			if code := errorCode(t, rr); code != "InvalidRange" {
+				t.Errorf("%s: expected error code InvalidRange but got %s", tt.name, code)
+			}
+			continue
+		}
+		if rr.Body.String() != tt.expectedBody {
+			t.Errorf("%s: expected body %q but got %q", tt.name, tt.expectedBody, rr.Body.String())
+		}
+	}
+}
+
+// Test that several ranges are returned as multipart/byteranges
+func TestDownloadObjectMultiRange(t *testing.T) {
+	r := rangeTestRouter(time.Now())
+
+	req, _ := http.NewRequest("GET", "/videos/clip.mp4", nil)
+	req.Header.Set("Range", "bytes=0-1, 10-12, -2")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusPartialContent {
+		t.Fatalf("expected status %d but got %d", http.StatusPartialContent, rr.Code)
+	}
+	mediaType, params, err := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("unexpected Content-Type %q", rr.Header().Get("Content-Type"))
+	}
+
+	expected := []struct{ contentRange, body string }{
+		{"bytes 0-1/20", "01"},
+		{"bytes 10-12/20", "abc"},
+		{"bytes 18-19/20", "ij"},
+	}
+
+	mr := multipart.NewReader(rr.Body, params["boundary"])
+	for i, want := range expected {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		body, _ := io.ReadAll(part)
+		if got := part.Header.Get("Content-Range"); got != want.contentRange {
+			t.Errorf("part %d: expected Content-Range %q but got %q", i, want.contentRange, got)
+		}
+		if string(body) != want.body {
+			t.Errorf("part %d: expected body %q but got %q", i, want.body, body)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("expected exactly %d parts", len(expected))
+	}
+}
+
+// discardResponseWriter counts the bytes of a response without keeping them, unlike httptest.ResponseRecorder
+type discardResponseWriter struct {
+	header http.Header
+	status int
+	n      int64
+}
+
+func (w *discardResponseWriter) Header() http.Header    { return w.header }
+func (w *discardResponseWriter) WriteHeader(status int) { w.status = status }
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.n += int64(len(b))
+	return len(b), nil
+}
+
+// Test that downloading a large object through the full middleware stack does not hold it in memory
+func TestDownloadLargeObjectMemory(t *testing.T) {
+	const size = 64 << 20
+	root := t.TempDir()
+	t.Setenv("S3_STORAGE_BACKEND", "fs")
+	t.Setenv("S3_STORAGE_ROOT", root)
+	t.Setenv("S3_ACCESS_KEY", "")
+	t.Setenv("S3_CREDENTIALS_FILE", "")
+	t.Setenv("S3_LIFECYCLE_INTERVAL", "0")
+	t.Setenv("S3_SCRUB_INTERVAL", "0")
+	t.Setenv("S3_QUOTA_RECOUNT_INTERVAL", "0")
+	// Un répertoire de file impossible à créer désactive les notifications et leur goroutine de livraison
+	blocker := filepath.Join(root, "not-a-directory")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatalf("could not create file: %v", err)
+	}
+	t.Setenv("S3_NOTIFICATION_QUEUE_DIR", filepath.Join(blocker, "queue"))
+
+	s := storage.NewFileStorage(root)
+	mustCreateBucket(t, s, "videos")
+	content := io.LimitReader(neverEndingReader('v'), size)
+	if _, err := s.AddObject("videos", "movie.mp4", content, dto.ObjectMetadata{}); err != nil {
+		t.Fatalf("could not store the video: %v", err)
+	}
+	r := router.SetupRouter()
+
+	for _, rangeHeader := range []string{"", "bytes=1048576-"} {
+		req, _ := http.NewRequest("GET", "/videos/movie.mp4", nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		w := &discardResponseWriter{header: http.Header{}}
+
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		r.ServeHTTP(w, req)
+		runtime.ReadMemStats(&after)
+
+		expected := int64(size)
+		if rangeHeader != "" {
+			expected -= 1 << 20
+		}
+		if w.n != expected {
+			t.Fatalf("range %q: expected %d bytes, got %d (status %d)", rangeHeader, expected, w.n, w.status)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/16 {
+			t.Errorf("range %q: serving a %d byte object allocated %d bytes", rangeHeader, size, allocated)
+		}
+	}
+}
+
+// neverEndingReader produit indéfiniment le même octet
+type neverEndingReader byte
+
+func (b neverEndingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+	return len(p), nil
+}
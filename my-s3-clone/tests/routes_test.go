@@ -8,54 +8,92 @@ import (
 	"os"
 	"testing"
 	"github.com/gorilla/mux"
+	"my-s3-clone/config"
 	"my-s3-clone/handlers"
 	"my-s3-clone/router"
 	"my-s3-clone/dto"
+	"my-s3-clone/storage"
 	"io"
 	"time"
 	"fmt"
 )
 
-// Mock implementation of FileInfo 
-type MockFileInfo struct {
-	name    string
-	size    int64
-	modTime time.Time
+// mockObject wraps in-memory content as the reader returned by GetObject
+type mockObject struct {
+	*bytes.Reader
 }
 
-func (m MockFileInfo) Name() string       { return m.name }
-func (m MockFileInfo) Size() int64        { return m.size }
-func (m MockFileInfo) Mode() os.FileMode  { return 0644 }
-func (m MockFileInfo) ModTime() time.Time { return m.modTime }
-func (m MockFileInfo) IsDir() bool        { return false }
-func (m MockFileInfo) Sys() interface{}   { return nil }
+func (mockObject) Close() error { return nil }
+
+func newMockObject(content string) io.ReadSeekCloser {
+	return mockObject{bytes.NewReader([]byte(content))}
+}
 
 // MockStorage is a mock implementation of the Storage interface
 type MockStorage struct {
-	AddObjectFunc         func(bucketName, objectName string, data io.Reader, contentSha256 string) error
-	DeleteObjectFunc      func(bucketName, objectName string) error
+	AddObjectFunc         func(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error)
+	DeleteObjectFunc      func(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error)
 	CheckBucketExistsFunc func(bucketName string) (bool, error)
-	CheckObjectExistFunc  func(bucketName, objectName string) (bool, time.Time, int64, error)
+	StatObjectFunc        func(bucketName, objectName, versionID string) (dto.ObjectInfo, error)
 	DeleteBucketFunc      func(bucketName string) error
-	GetObjectFunc         func(bucketName, objectName string) ([]byte, dto.FileInfo, error)
+	GetObjectFunc         func(bucketName, objectName, versionID string) (io.ReadSeekCloser, dto.ObjectInfo, error)
 	ListBucketsFunc       func() []string
-	ListObjectsFunc       func(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error)
+	ListObjectsFunc       func(bucketName, prefix, delimiter, marker string, maxKeys int) (dto.ListObjectsResponse, error)
 	CreateBucketFunc      func(bucketName string) error
+	GetBucketInfoFunc     func(bucketName string) (dto.BucketInfo, error)
+	PutBucketInfoFunc     func(bucketName string, info dto.BucketInfo) error
+	CopyObjectFunc        func(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error)
+	MoveObjectFunc        func(sourceBucket, sourceKey, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error)
+	UpdateObjectMetadataFunc func(bucketName, objectName, versionID string, update func(*dto.ObjectMetadata) error) error
+
+	CreateMultipartUploadFunc   func(bucketName, objectName string, metadata dto.ObjectMetadata) (string, error)
+	UploadPartFunc              func(bucketName, objectName, uploadID string, partNumber int, data io.Reader) (string, error)
+	CompleteMultipartUploadFunc func(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error)
+	AbortMultipartUploadFunc    func(bucketName, objectName, uploadID string) error
+	ListPartsFunc               func(bucketName, objectName, uploadID string, partNumberMarker, maxParts int) (dto.ListPartsResult, error)
+	ListMultipartUploadsFunc    func(bucketName, prefix, keyMarker, uploadIDMarker string, maxUploads int) (dto.ListMultipartUploadsResult, error)
+	MultipartUploadMetadataFunc func(bucketName, objectName, uploadID string) (dto.ObjectMetadata, error)
+
+	GetBucketVersioningFunc func(bucketName string) (string, error)
+	PutBucketVersioningFunc func(bucketName, status string) error
+	ListObjectVersionsFunc  func(bucketName, prefix, keyMarker, versionIDMarker string, maxKeys int) (dto.ListVersionsResult, error)
+
+	GetBucketLifecycleFunc    func(bucketName string) (dto.LifecycleConfiguration, error)
+	PutBucketLifecycleFunc    func(bucketName string, config dto.LifecycleConfiguration) error
+	DeleteBucketLifecycleFunc func(bucketName string) error
+
+	GetBucketCorsFunc    func(bucketName string) (dto.CORSConfiguration, error)
+	PutBucketCorsFunc    func(bucketName string, config dto.CORSConfiguration) error
+	DeleteBucketCorsFunc func(bucketName string) error
+
+	GetBucketEncryptionFunc    func(bucketName string) (dto.ServerSideEncryptionConfiguration, error)
+	PutBucketEncryptionFunc    func(bucketName string, config dto.ServerSideEncryptionConfiguration) error
+	DeleteBucketEncryptionFunc func(bucketName string) error
+
+	GetBucketPolicyFunc    func(bucketName string) (dto.BucketPolicy, error)
+	PutBucketPolicyFunc    func(bucketName string, policy dto.BucketPolicy) error
+	DeleteBucketPolicyFunc func(bucketName string) error
+
+	GetBucketNotificationFunc func(bucketName string) (dto.NotificationConfiguration, error)
+	PutBucketNotificationFunc func(bucketName string, config dto.NotificationConfiguration) error
+
+	GetObjectLockConfigurationFunc func(bucketName string) (dto.ObjectLockConfiguration, error)
+	PutObjectLockConfigurationFunc func(bucketName string, config dto.ObjectLockConfiguration) error
 }
 
 // Implementations of the Storage interface using the mock functions
-func (m *MockStorage) AddObject(bucketName, objectName string, data io.Reader, contentSha256 string) error {
+func (m *MockStorage) AddObject(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error) {
 	if m.AddObjectFunc != nil {
-		return m.AddObjectFunc(bucketName, objectName, data, contentSha256)
+		return m.AddObjectFunc(bucketName, objectName, data, metadata)
 	}
-	return nil
+	return dto.ObjectInfo{}, nil
 }
 
-func (m *MockStorage) DeleteObject(bucketName, objectName string) error {
+func (m *MockStorage) DeleteObject(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error) {
 	if m.DeleteObjectFunc != nil {
-		return m.DeleteObjectFunc(bucketName, objectName)
+		return m.DeleteObjectFunc(bucketName, objectName, versionID)
 	}
-	return nil
+	return dto.DeleteObjectResult{}, nil
 }
 
 func (m *MockStorage) CheckBucketExists(bucketName string) (bool, error) {
@@ -65,11 +103,11 @@ func (m *MockStorage) CheckBucketExists(bucketName string) (bool, error) {
 	return false, nil
 }
 
-func (m *MockStorage) CheckObjectExist(bucketName, objectName string) (bool, time.Time, int64, error) {
-	if m.CheckObjectExistFunc != nil {
-		return m.CheckObjectExistFunc(bucketName, objectName)
+func (m *MockStorage) StatObject(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+	if m.StatObjectFunc != nil {
+		return m.StatObjectFunc(bucketName, objectName, versionID)
 	}
-	return false, time.Time{}, 0, nil
+	return dto.ObjectInfo{}, os.ErrNotExist
 }
 
 func (m *MockStorage) DeleteBucket(bucketName string) error {
@@ -79,11 +117,11 @@ func (m *MockStorage) DeleteBucket(bucketName string) error {
 	return nil
 }
 
-func (m *MockStorage) GetObject(bucketName, objectName string) ([]byte, dto.FileInfo, error) {
+func (m *MockStorage) GetObject(bucketName, objectName, versionID string) (io.ReadSeekCloser, dto.ObjectInfo, error) {
 	if m.GetObjectFunc != nil {
-		return m.GetObjectFunc(bucketName, objectName)
+		return m.GetObjectFunc(bucketName, objectName, versionID)
 	}
-	return nil, nil, os.ErrNotExist
+	return nil, dto.ObjectInfo{}, os.ErrNotExist
 }
 
 func (m *MockStorage) ListBuckets() []string {
@@ -93,9 +131,9 @@ func (m *MockStorage) ListBuckets() []string {
 	return []string{}
 }
 
-func (m *MockStorage) ListObjects(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
+func (m *MockStorage) ListObjects(bucketName, prefix, delimiter, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
 	if m.ListObjectsFunc != nil {
-		return m.ListObjectsFunc(bucketName, prefix, marker, maxKeys)
+		return m.ListObjectsFunc(bucketName, prefix, delimiter, marker, maxKeys)
 	}
 	return dto.ListObjectsResponse{}, nil
 }
@@ -108,9 +146,226 @@ func (m *MockStorage) CreateBucket(bucketName string) error {
     return nil
 }
 
+func (m *MockStorage) GetBucketInfo(bucketName string) (dto.BucketInfo, error) {
+	if m.GetBucketInfoFunc != nil {
+		return m.GetBucketInfoFunc(bucketName)
+	}
+	return dto.BucketInfo{Name: bucketName}, nil
+}
+
+func (m *MockStorage) PutBucketInfo(bucketName string, info dto.BucketInfo) error {
+	if m.PutBucketInfoFunc != nil {
+		return m.PutBucketInfoFunc(bucketName, info)
+	}
+	return nil
+}
+
+func (m *MockStorage) CopyObject(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	if m.CopyObjectFunc != nil {
+		return m.CopyObjectFunc(sourceBucket, sourceKey, sourceVersionID, targetBucket, targetKey, metadata)
+	}
+	return dto.ObjectInfo{}, nil
+}
+
+func (m *MockStorage) MoveObject(sourceBucket, sourceKey, targetBucket, targetKey string, metadata *dto.ObjectMetadata) (dto.ObjectInfo, error) {
+	if m.MoveObjectFunc != nil {
+		return m.MoveObjectFunc(sourceBucket, sourceKey, targetBucket, targetKey, metadata)
+	}
+	return dto.ObjectInfo{}, nil
+}
+
+func (m *MockStorage) UpdateObjectMetadata(bucketName, objectName, versionID string, update func(*dto.ObjectMetadata) error) error {
+	if m.UpdateObjectMetadataFunc != nil {
+		return m.UpdateObjectMetadataFunc(bucketName, objectName, versionID, update)
+	}
+	return nil
+}
+
+func (m *MockStorage) CreateMultipartUpload(bucketName, objectName string, metadata dto.ObjectMetadata) (string, error) {
+	if m.CreateMultipartUploadFunc != nil {
+		return m.CreateMultipartUploadFunc(bucketName, objectName, metadata)
+	}
+	return "", nil
+}
+
+func (m *MockStorage) UploadPart(bucketName, objectName, uploadID string, partNumber int, data io.Reader) (string, error) {
+	if m.UploadPartFunc != nil {
+		return m.UploadPartFunc(bucketName, objectName, uploadID, partNumber, data)
+	}
+	return "", nil
+}
+
+func (m *MockStorage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+	if m.CompleteMultipartUploadFunc != nil {
+		return m.CompleteMultipartUploadFunc(bucketName, objectName, uploadID, parts)
+	}
+	return dto.ObjectInfo{}, nil
+}
+
+func (m *MockStorage) AbortMultipartUpload(bucketName, objectName, uploadID string) error {
+	if m.AbortMultipartUploadFunc != nil {
+		return m.AbortMultipartUploadFunc(bucketName, objectName, uploadID)
+	}
+	return nil
+}
+
+func (m *MockStorage) ListParts(bucketName, objectName, uploadID string, partNumberMarker, maxParts int) (dto.ListPartsResult, error) {
+	if m.ListPartsFunc != nil {
+		return m.ListPartsFunc(bucketName, objectName, uploadID, partNumberMarker, maxParts)
+	}
+	return dto.ListPartsResult{}, nil
+}
+
+func (m *MockStorage) ListMultipartUploads(bucketName, prefix, keyMarker, uploadIDMarker string, maxUploads int) (dto.ListMultipartUploadsResult, error) {
+	if m.ListMultipartUploadsFunc != nil {
+		return m.ListMultipartUploadsFunc(bucketName, prefix, keyMarker, uploadIDMarker, maxUploads)
+	}
+	return dto.ListMultipartUploadsResult{}, nil
+}
+
+func (m *MockStorage) MultipartUploadMetadata(bucketName, objectName, uploadID string) (dto.ObjectMetadata, error) {
+	if m.MultipartUploadMetadataFunc != nil {
+		return m.MultipartUploadMetadataFunc(bucketName, objectName, uploadID)
+	}
+	return dto.ObjectMetadata{}, nil
+}
+
+func (m *MockStorage) GetBucketVersioning(bucketName string) (string, error) {
+	if m.GetBucketVersioningFunc != nil {
+		return m.GetBucketVersioningFunc(bucketName)
+	}
+	return "", nil
+}
+
+func (m *MockStorage) PutBucketVersioning(bucketName, status string) error {
+	if m.PutBucketVersioningFunc != nil {
+		return m.PutBucketVersioningFunc(bucketName, status)
+	}
+	return nil
+}
+
+func (m *MockStorage) ListObjectVersions(bucketName, prefix, keyMarker, versionIDMarker string, maxKeys int) (dto.ListVersionsResult, error) {
+	if m.ListObjectVersionsFunc != nil {
+		return m.ListObjectVersionsFunc(bucketName, prefix, keyMarker, versionIDMarker, maxKeys)
+	}
+	return dto.ListVersionsResult{}, nil
+}
+
+func (m *MockStorage) GetBucketLifecycle(bucketName string) (dto.LifecycleConfiguration, error) {
+	if m.GetBucketLifecycleFunc != nil {
+		return m.GetBucketLifecycleFunc(bucketName)
+	}
+	return dto.LifecycleConfiguration{}, nil
+}
+
+func (m *MockStorage) PutBucketLifecycle(bucketName string, config dto.LifecycleConfiguration) error {
+	if m.PutBucketLifecycleFunc != nil {
+		return m.PutBucketLifecycleFunc(bucketName, config)
+	}
+	return nil
+}
+
+func (m *MockStorage) DeleteBucketLifecycle(bucketName string) error {
+	if m.DeleteBucketLifecycleFunc != nil {
+		return m.DeleteBucketLifecycleFunc(bucketName)
+	}
+	return nil
+}
+
+func (m *MockStorage) GetBucketCors(bucketName string) (dto.CORSConfiguration, error) {
+	if m.GetBucketCorsFunc != nil {
+		return m.GetBucketCorsFunc(bucketName)
+	}
+	return dto.CORSConfiguration{}, storage.ErrNoSuchCORS
+}
+
+func (m *MockStorage) PutBucketCors(bucketName string, config dto.CORSConfiguration) error {
+	if m.PutBucketCorsFunc != nil {
+		return m.PutBucketCorsFunc(bucketName, config)
+	}
+	return nil
+}
+
+func (m *MockStorage) DeleteBucketCors(bucketName string) error {
+	if m.DeleteBucketCorsFunc != nil {
+		return m.DeleteBucketCorsFunc(bucketName)
+	}
+	return nil
+}
+
+func (m *MockStorage) GetBucketEncryption(bucketName string) (dto.ServerSideEncryptionConfiguration, error) {
+	if m.GetBucketEncryptionFunc != nil {
+		return m.GetBucketEncryptionFunc(bucketName)
+	}
+	return dto.ServerSideEncryptionConfiguration{}, storage.ErrNoSuchEncryptionConfiguration
+}
+
+func (m *MockStorage) PutBucketEncryption(bucketName string, config dto.ServerSideEncryptionConfiguration) error {
+	if m.PutBucketEncryptionFunc != nil {
+		return m.PutBucketEncryptionFunc(bucketName, config)
+	}
+	return nil
+}
+
+func (m *MockStorage) DeleteBucketEncryption(bucketName string) error {
+	if m.DeleteBucketEncryptionFunc != nil {
+		return m.DeleteBucketEncryptionFunc(bucketName)
+	}
+	return nil
+}
+
+func (m *MockStorage) GetBucketPolicy(bucketName string) (dto.BucketPolicy, error) {
+	if m.GetBucketPolicyFunc != nil {
+		return m.GetBucketPolicyFunc(bucketName)
+	}
+	return dto.BucketPolicy{}, storage.ErrNoSuchBucketPolicy
+}
+
+func (m *MockStorage) PutBucketPolicy(bucketName string, policy dto.BucketPolicy) error {
+	if m.PutBucketPolicyFunc != nil {
+		return m.PutBucketPolicyFunc(bucketName, policy)
+	}
+	return nil
+}
+
+func (m *MockStorage) DeleteBucketPolicy(bucketName string) error {
+	if m.DeleteBucketPolicyFunc != nil {
+		return m.DeleteBucketPolicyFunc(bucketName)
+	}
+	return nil
+}
+
+func (m *MockStorage) GetBucketNotification(bucketName string) (dto.NotificationConfiguration, error) {
+	if m.GetBucketNotificationFunc != nil {
+		return m.GetBucketNotificationFunc(bucketName)
+	}
+	return dto.NotificationConfiguration{}, nil
+}
+
+func (m *MockStorage) PutBucketNotification(bucketName string, config dto.NotificationConfiguration) error {
+	if m.PutBucketNotificationFunc != nil {
+		return m.PutBucketNotificationFunc(bucketName, config)
+	}
+	return nil
+}
+
+func (m *MockStorage) GetObjectLockConfiguration(bucketName string) (dto.ObjectLockConfiguration, error) {
+	if m.GetObjectLockConfigurationFunc != nil {
+		return m.GetObjectLockConfigurationFunc(bucketName)
+	}
+	return dto.ObjectLockConfiguration{}, storage.ErrNoSuchObjectLockConfiguration
+}
+
+func (m *MockStorage) PutObjectLockConfiguration(bucketName string, config dto.ObjectLockConfiguration) error {
+	if m.PutObjectLockConfigurationFunc != nil {
+		return m.PutObjectLockConfigurationFunc(bucketName, config)
+	}
+	return nil
+}
+
 // Test for the /probe-bsign{suffix:.*} route
 func TestProbeBSignRoute(t *testing.T) {
-	r := router.SetupRouter()
+	r := router.SetupRouterWithConfig(storage.NewFileStorage(t.TempDir()), config.Config{})
 
 	tests := []struct {
 		method       string
@@ -205,19 +460,19 @@ func TestHandleDeleteObject(t *testing.T) {
 func TestHandleAddObject(t *testing.T) {
 	// Create a new instance of the mock storage
 	mockStorage := &MockStorage{
-		AddObjectFunc: func(bucketName, objectName string, data io.Reader, contentSha256 string) error {
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error) {
 			if bucketName == "test-bucket" && objectName == "test-object" {
 				// Simulate successful upload, reading the content from the reader
 				buf := new(bytes.Buffer)
 				if _, err := buf.ReadFrom(data); err != nil {
-					return err
+					return dto.ObjectInfo{}, err
 				}
 				if buf.String() != "file content" {
-					return fmt.Errorf("unexpected file content: %s", buf.String())
+					return dto.ObjectInfo{}, fmt.Errorf("unexpected file content: %s", buf.String())
 				}
-				return nil
+				return dto.ObjectInfo{ETag: "d3b07384d113edec49eaa6238ad5ff00"}, nil
 			}
-			return os.ErrNotExist // Simulate failure
+			return dto.ObjectInfo{}, os.ErrNotExist // Simulate failure
 		},
 		CheckBucketExistsFunc: func(bucketName string) (bool, error) {
 			if bucketName == "test-bucket" {
@@ -225,11 +480,11 @@ func TestHandleAddObject(t *testing.T) {
 			}
 			return false, nil
 		},
-		CheckObjectExistFunc: func(bucketName, objectName string) (bool, time.Time, int64, error) {
+		StatObjectFunc: func(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
 			if bucketName == "test-bucket" && objectName == "test-object" {
-				return true, time.Now(), 1234, nil
+				return dto.ObjectInfo{Key: objectName, Size: 1234, LastModified: time.Now()}, nil
 			}
-			return false, time.Time{}, 0, os.ErrNotExist
+			return dto.ObjectInfo{}, os.ErrNotExist
 		},
 	}
 
@@ -270,8 +525,8 @@ func TestHandleAddObject(t *testing.T) {
 	}
 
 	// Validate the response headers
-	if rr.Header().Get("ETag") == "" {
-		t.Errorf("expected ETag header to be set")
+	if rr.Header().Get("ETag") != `"d3b07384d113edec49eaa6238ad5ff00"` {
+		t.Errorf("expected the ETag returned by the storage but got %q", rr.Header().Get("ETag"))
 	}
 	if rr.Header().Get("x-amz-id-2") == "" {
 		t.Errorf("expected x-amz-id-2 header to be set")
@@ -284,13 +539,13 @@ func TestHandleAddObject(t *testing.T) {
 func TestHandleCheckObjectExist(t *testing.T) {
 	// Create a new instance of the mock storage
 	mockStorage := &MockStorage{
-		CheckObjectExistFunc: func(bucketName, objectName string) (bool, time.Time, int64, error) {
+		StatObjectFunc: func(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
 			// Simulate that the object exists
 			if bucketName == "test-bucket" && objectName == "test-object" {
-				return true, time.Now(), 1234, nil
+				return dto.ObjectInfo{Key: objectName, Size: 1234, LastModified: time.Now()}, nil
 			}
 			// Simulate that the object does not exist
-			return false, time.Time{}, 0, nil
+			return dto.ObjectInfo{}, os.ErrNotExist
 		},
 	}
 
@@ -342,7 +597,7 @@ func TestHandleCheckObjectExist(t *testing.T) {
 func TestHandleListObjects(t *testing.T) {
     // Create a new instance of the mock storage
     mockStorage := &MockStorage{
-        ListObjectsFunc: func(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
+        ListObjectsFunc: func(bucketName, prefix, delimiter, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
             // Simulate a response with some objects
             if marker == "object1.txt" {
                 // Simulate paginated response
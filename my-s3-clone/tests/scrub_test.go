@@ -0,0 +1,252 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func issueKeys(issues []dto.IntegrityIssue) string {
+	var keys []string
+	for _, issue := range issues {
+		keys = append(keys, issue.Bucket+"/"+issue.Key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// stripChecksum retire l'empreinte du sidecar d'un objet, comme pour un objet écrit avant son enregistrement
+func stripChecksum(t *testing.T, root, bucketName, objectName string) {
+	t.Helper()
+	path := filepath.Join(root, ".s3clone", "meta", bucketName, objectName+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read sidecar of %s: %v", objectName, err)
+	}
+	var meta map[string]interface{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatalf("could not decode sidecar of %s: %v", objectName, err)
+	}
+	delete(meta, "sha256")
+	data, _ = json.Marshal(meta)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("could not rewrite sidecar of %s: %v", objectName, err)
+	}
+}
+
+// Test that the scrubber reports corrupt, missing and unchecked versions on the disk backends
+func TestIntegrityScrub(t *testing.T) {
+	for _, backend := range []string{"fs", "cas"} {
+		t.Run(backend, func(t *testing.T) {
+			root := t.TempDir()
+			s, err := storage.NewBackend(backend, storage.BackendConfig{Root: root})
+			if err != nil {
+				t.Fatalf("could not create backend: %v", err)
+			}
+			mustCreateBucket(t, s, "album")
+			mustCreateBucket(t, s, "history")
+
+			mustPut(t, s, "album", "good.jpg", "intact content")
+			mustPut(t, s, "album", "rotten.jpg", "original content")
+			mustPut(t, s, "album", "gone.jpg", "vanished content")
+			mustPut(t, s, "album", "legacy.jpg", "legacy content")
+			if _, err := s.CopyObject("album", "good.jpg", "", "album", "copy.jpg", nil); err != nil {
+				t.Fatalf("copy failed: %v", err)
+			}
+			mustPut(t, s, "album", "moving.jpg", "moved content")
+			if _, err := s.MoveObject("album", "moving.jpg", "album", "moved/photo.jpg", nil); err != nil {
+				t.Fatalf("move failed: %v", err)
+			}
+			if err := s.PutBucketVersioning("history", storage.VersioningEnabled); err != nil {
+				t.Fatalf("could not enable versioning: %v", err)
+			}
+			first := mustPut(t, s, "history", "doc.txt", "first version")
+			mustPut(t, s, "history", "doc.txt", "second version")
+
+			// Dégradations du disque
+			if err := os.WriteFile(filepath.Join(root, "album", "rotten.jpg"), []byte("origina1 content"), 0644); err != nil {
+				t.Fatalf("could not corrupt object: %v", err)
+			}
+			if err := os.Remove(filepath.Join(root, "album", "gone.jpg")); err != nil {
+				t.Fatalf("could not remove object: %v", err)
+			}
+			stripChecksum(t, root, "album", "legacy.jpg")
+			if err := os.WriteFile(filepath.Join(root, "album", "raw.jpg"), []byte("no sidecar"), 0644); err != nil {
+				t.Fatalf("could not write object without sidecar: %v", err)
+			}
+			archived := filepath.Join(root, ".s3clone", "versions", "history", "doc.txt", first.VersionID+".data")
+			if err := os.WriteFile(archived, []byte("first versioN"), 0644); err != nil {
+				t.Fatalf("could not corrupt archived version: %v", err)
+			}
+
+			if _, err := s.(storage.Scrubber).LastIntegrityReport(); err != storage.ErrNoIntegrityReport {
+				t.Errorf("expected ErrNoIntegrityReport before the first scrub, got %v", err)
+			}
+			scrubber, ok := storage.AsScrubber(storage.NewEncryptedStorage(storage.NewObjectLockStorage(s), nil))
+			if !ok {
+				t.Fatalf("expected the %s backend to be scrubbable through its decorators", backend)
+			}
+			report, err := scrubber.Scrub(context.Background(), 0)
+			if err != nil {
+				t.Fatalf("scrub failed: %v", err)
+			}
+
+			if keys := issueKeys(report.Corrupt); keys != "album/rotten.jpg,history/doc.txt" {
+				t.Errorf("expected rotten.jpg and the first version of doc.txt to be corrupt, got %s", keys)
+			}
+			for _, issue := range report.Corrupt {
+				if issue.Key == "rotten.jpg" && (issue.Expected != sha256Hex("original content") || issue.Actual != sha256Hex("origina1 content")) {
+					t.Errorf("unexpected checksums for rotten.jpg: %+v", issue)
+				}
+				if issue.Key == "doc.txt" && issue.VersionID != first.VersionID {
+					t.Errorf("expected the corrupt version of doc.txt to be %s, got %q", first.VersionID, issue.VersionID)
+				}
+			}
+			if keys := issueKeys(report.Missing); keys != "album/gone.jpg" {
+				t.Errorf("expected gone.jpg to be missing, got %s", keys)
+			}
+			if keys := issueKeys(report.Unverified); keys != "album/legacy.jpg,album/raw.jpg" {
+				t.Errorf("expected legacy.jpg and raw.jpg to have no checksum, got %s", keys)
+			}
+			// good, rotten, copy, moved et les deux versions de doc.txt
+			if report.ObjectsChecked != 6 {
+				t.Errorf("expected 6 objects checked, got %d", report.ObjectsChecked)
+			}
+			if report.Finished == nil {
+				t.Errorf("expected a finished report")
+			}
+
+			saved, err := scrubber.LastIntegrityReport()
+			if err != nil {
+				t.Fatalf("could not read the saved report: %v", err)
+			}
+			if saved.ObjectsChecked != report.ObjectsChecked || issueKeys(saved.Corrupt) != issueKeys(report.Corrupt) {
+				t.Errorf("saved report %+v differs from %+v", saved, report)
+			}
+		})
+	}
+
+	if _, ok := storage.AsScrubber(storage.NewMemoryStorage()); ok {
+		t.Errorf("expected the memory backend not to be scrubbable")
+	}
+}
+
+// Test that a scrub honours its read budget and stops when cancelled
+func TestIntegrityScrubThrottle(t *testing.T) {
+	s := storage.NewFileStorage(t.TempDir())
+	mustCreateBucket(t, s, "album")
+	mustPut(t, s, "album", "photo.jpg", strings.Repeat("x", 3000))
+
+	start := time.Now()
+	report, err := s.Scrub(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("scrub failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Errorf("expected reading 3000 bytes at 1000 bytes/s to take about 3s, took %v", elapsed)
+	}
+	if report.BytesChecked != 3000 {
+		t.Errorf("expected 3000 bytes checked, got %d", report.BytesChecked)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := s.Scrub(ctx, 1000); err != context.DeadlineExceeded {
+		t.Errorf("expected the scrub to stop with its context, got %v", err)
+	}
+}
+
+// Test the integrity admin endpoint: report, on-demand scrub and access control
+func TestIntegrityEndpoint(t *testing.T) {
+	root := t.TempDir()
+	s := storage.NewFileStorage(root)
+	mustCreateBucket(t, s, "album")
+	mustPut(t, s, "album", "photo.jpg", "original content")
+	if err := os.WriteFile(filepath.Join(root, "album", "photo.jpg"), []byte("damaged content!"), 0644); err != nil {
+		t.Fatalf("could not corrupt object: %v", err)
+	}
+	cfg := authTestConfig()
+	cfg.Credentials[otherAccessKey] = otherSecretKey
+	r := router.SetupRouterWithConfig(s, cfg)
+
+	// Seul l'administrateur (testAccessKey) peut lire le rapport et lancer une vérification
+	send := func(method, accessKey, secretKey string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/?integrity", nil)
+		if accessKey != "" {
+			auth.SignRequest(req, accessKey, secretKey, testRegion, time.Now())
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	do := func(method string, sign bool) *httptest.ResponseRecorder {
+		if sign {
+			return send(method, testAccessKey, testSecretKey)
+		}
+		return send(method, "", "")
+	}
+
+	if rr := do("GET", false); rr.Code != http.StatusForbidden {
+		t.Errorf("expected anonymous requests to be denied, got %d", rr.Code)
+	}
+	for _, method := range []string{"GET", "POST"} {
+		if rr := send(method, otherAccessKey, otherSecretKey); rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessDenied" {
+			t.Errorf("%s: expected a user other than the administrator to be denied, got %d: %s", method, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := do("GET", true); rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchIntegrityReport" {
+		t.Errorf("expected NoSuchIntegrityReport before the first scrub, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("POST", true); rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+
+	var report dto.IntegrityReport
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rr := do("GET", true)
+		if rr.Code == http.StatusOK {
+			if err := xml.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+				t.Fatalf("could not decode report %q: %v", rr.Body.String(), err)
+			}
+			if !report.InProgress && report.Finished != nil {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("scrub did not finish: %d %s", rr.Code, rr.Body.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(report.Corrupt) != 1 || report.Corrupt[0].Key != "photo.jpg" || report.Corrupt[0].Expected != sha256Hex("original content") {
+		t.Errorf("expected photo.jpg to be reported corrupt, got %+v", report.Corrupt)
+	}
+
+	memory := router.SetupRouterWithStorage(storage.NewMemoryStorage())
+	req, _ := http.NewRequest("POST", "/?integrity", nil)
+	rr := httptest.NewRecorder()
+	memory.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected status %d for the memory backend, got %d", http.StatusNotImplemented, rr.Code)
+	}
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/lifecycle"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// Test PUT/GET/DELETE ?tagging, x-amz-tagging on upload and copy, and x-amz-tagging-count
+func TestObjectTagging(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	mustCreateBucket(t, s, "album")
+	r := router.SetupRouterWithStorage(s)
+
+	send := func(method, url, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	getTags := func(url string) string {
+		t.Helper()
+		rr := send("GET", url+"?tagging", "", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET ?tagging: expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var tagging dto.Tagging
+		if err := xml.Unmarshal(rr.Body.Bytes(), &tagging); err != nil {
+			t.Fatalf("could not decode Tagging %q: %v", rr.Body.String(), err)
+		}
+		var pairs []string
+		for _, tag := range tagging.TagSet.Tags {
+			pairs = append(pairs, tag.Key+"="+tag.Value)
+		}
+		return strings.Join(pairs, "&")
+	}
+
+	if rr := send("PUT", "/album/photo.jpg", "pixels", map[string]string{"X-Amz-Tagging": "year=2024&kind=raw%20photo"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := send("HEAD", "/album/photo.jpg", "", nil); rr.Header().Get("X-Amz-Tagging-Count") != "2" {
+		t.Errorf("expected x-amz-tagging-count 2 on HEAD, got %q", rr.Header().Get("X-Amz-Tagging-Count"))
+	}
+	if rr := send("GET", "/album/photo.jpg", "", nil); rr.Header().Get("X-Amz-Tagging-Count") != "2" {
+		t.Errorf("expected x-amz-tagging-count 2 on GET, got %q", rr.Header().Get("X-Amz-Tagging-Count"))
+	}
+	if tags := getTags("/album/photo.jpg"); tags != "kind=raw photo&year=2024" {
+		t.Errorf("unexpected tags %q", tags)
+	}
+
+	if rr := send("PUT", "/album/photo.jpg?tagging", "<Tagging><TagSet><Tag><Key>kind</Key><Value>edited</Value></Tag></TagSet></Tagging>", nil); rr.Code != http.StatusOK {
+		t.Fatalf("PUT ?tagging: expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if tags := getTags("/album/photo.jpg"); tags != "kind=edited" {
+		t.Errorf("expected the tags to be replaced, got %q", tags)
+	}
+
+	// Copies keep the tags of the source unless the tagging directive replaces them
+	send("PUT", "/album/copy.jpg", "", map[string]string{"X-Amz-Copy-Source": "/album/photo.jpg", "X-Amz-Metadata-Directive": "REPLACE"})
+	if tags := getTags("/album/copy.jpg"); tags != "kind=edited" {
+		t.Errorf("expected the copy to keep the tags, got %q", tags)
+	}
+	send("PUT", "/album/copy.jpg", "", map[string]string{"X-Amz-Copy-Source": "/album/photo.jpg", "X-Amz-Tagging-Directive": "REPLACE", "X-Amz-Tagging": "kind=copy"})
+	if tags := getTags("/album/copy.jpg"); tags != "kind=copy" {
+		t.Errorf("expected the copy to have the tags of the request, got %q", tags)
+	}
+
+	var tooMany strings.Builder
+	for i := 0; i < 11; i++ {
+		fmt.Fprintf(&tooMany, "<Tag><Key>k%d</Key><Value>v</Value></Tag>", i)
+	}
+	for _, tt := range []struct {
+		name         string
+		method       string
+		url          string
+		body         string
+		headers      map[string]string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"more than 10 tags", "PUT", "/album/photo.jpg?tagging", "<Tagging><TagSet>" + tooMany.String() + "</TagSet></Tagging>", nil, http.StatusBadRequest, "BadRequest"},
+		{"duplicate keys", "PUT", "/album/photo.jpg?tagging", "<Tagging><TagSet><Tag><Key>k</Key><Value>1</Value></Tag><Tag><Key>k</Key><Value>2</Value></Tag></TagSet></Tagging>", nil, http.StatusBadRequest, "InvalidTag"},
+		{"key too long", "PUT", "/album/photo.jpg?tagging", "<Tagging><TagSet><Tag><Key>" + strings.Repeat("k", 129) + "</Key><Value>v</Value></Tag></TagSet></Tagging>", nil, http.StatusBadRequest, "InvalidTag"},
+		{"malformed XML", "PUT", "/album/photo.jpg?tagging", "<Tagging>", nil, http.StatusBadRequest, "MalformedXML"},
+		{"duplicate keys in header", "PUT", "/album/other.jpg", "pixels", map[string]string{"X-Amz-Tagging": "k=1&k=2"}, http.StatusBadRequest, "InvalidTag"},
+		{"unknown tagging directive", "PUT", "/album/copy.jpg", "", map[string]string{"X-Amz-Copy-Source": "/album/photo.jpg", "X-Amz-Tagging-Directive": "MERGE"}, http.StatusBadRequest, "InvalidArgument"},
+		{"missing object", "GET", "/album/missing.jpg?tagging", "", nil, http.StatusNotFound, "NoSuchKey"},
+	} {
+		rr := send(tt.method, tt.url, tt.body, tt.headers)
+		if rr.Code != tt.expectedCode || errorCode(t, rr) != tt.expectedErr {
+			t.Errorf("%s: expected %s but got %d: %s", tt.name, tt.expectedErr, rr.Code, rr.Body.String())
+		}
+	}
+	if tags := getTags("/album/photo.jpg"); tags != "kind=edited" {
+		t.Errorf("refused tags should not change the object, got %q", tags)
+	}
+
+	if rr := send("DELETE", "/album/photo.jpg?tagging", "", nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("DELETE ?tagging: expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := send("GET", "/album/photo.jpg?tagging", "", nil); !strings.Contains(rr.Body.String(), "<TagSet></TagSet>") {
+		t.Errorf("expected an empty TagSet, got %s", rr.Body.String())
+	}
+	if rr := send("HEAD", "/album/photo.jpg", "", nil); rr.Header().Get("X-Amz-Tagging-Count") != "" {
+		t.Errorf("expected no x-amz-tagging-count without tags, got %q", rr.Header().Get("X-Amz-Tagging-Count"))
+	}
+	expectContent(t, s, "album", "photo.jpg", "", "pixels")
+}
+
+// Test that lifecycle rules filtered by tag only apply to the objects carrying the tags
+func TestLifecycleTagFilter(t *testing.T) {
+	for _, backend := range storage.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			s, err := storage.NewBackend(backend, storage.BackendConfig{Root: t.TempDir()})
+			if err != nil {
+				t.Fatalf("could not create backend: %v", err)
+			}
+			clock := lifecycle.NewManualClock(time.Now())
+			sweeper := lifecycle.NewSweeper(s, clock)
+
+			mustCreateBucket(t, s, "scratch")
+			if err := s.PutBucketVersioning("scratch", storage.VersioningEnabled); err != nil {
+				t.Fatalf("PutBucketVersioning: %v", err)
+			}
+			tagged := dto.ObjectMetadata{Tags: map[string]string{"kind": "raw", "year": "2024"}}
+			for _, key := range []string{"raw/a.dng", "other/b.dng"} {
+				if _, err := s.AddObject("scratch", key, strings.NewReader("raw"), tagged); err != nil {
+					t.Fatalf("AddObject: %v", err)
+				}
+			}
+			mustPut(t, s, "scratch", "raw/c.dng", "untagged")
+			// The noncurrent version of raw/d.dng is tagged, the current one is not
+			old, err := s.AddObject("scratch", "raw/d.dng", strings.NewReader("old"), tagged)
+			if err != nil {
+				t.Fatalf("AddObject: %v", err)
+			}
+			mustPut(t, s, "scratch", "raw/d.dng", "new")
+
+			filter := &dto.LifecycleFilter{And: &dto.LifecycleFilterAnd{Prefix: "raw/", Tags: []dto.Tag{{Key: "kind", Value: "raw"}}}}
+			err = s.PutBucketLifecycle("scratch", dto.LifecycleConfiguration{Rules: []dto.LifecycleRule{
+				{ID: "raw", Status: "Enabled", Filter: filter, Expiration: &dto.LifecycleExpiration{Days: 1}, NoncurrentVersionExpiration: &dto.NoncurrentVersionExpiration{NoncurrentDays: 1}},
+			}})
+			if err != nil {
+				t.Fatalf("PutBucketLifecycle: %v", err)
+			}
+
+			clock.Advance(2 * 24 * time.Hour)
+			stats, err := sweeper.Sweep()
+			if err != nil {
+				t.Fatalf("Sweep: %v", err)
+			}
+			// raw/a.dng expires, then its tagged version, now noncurrent, is deleted with the one of raw/d.dng
+			if stats != (lifecycle.Stats{Expired: 1, NoncurrentExpired: 2}) {
+				t.Errorf("unexpected stats %+v", stats)
+			}
+			if _, err := s.StatObject("scratch", "raw/a.dng", ""); err == nil {
+				t.Errorf("raw/a.dng should have expired")
+			}
+			for _, key := range []string{"other/b.dng", "raw/c.dng", "raw/d.dng"} {
+				if _, err := s.StatObject("scratch", key, ""); err != nil {
+					t.Errorf("%s should be kept, got %v", key, err)
+				}
+			}
+			if _, err := s.StatObject("scratch", "raw/d.dng", old.VersionID); err == nil {
+				t.Errorf("the tagged noncurrent version of raw/d.dng should be deleted")
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// Test enabling, suspending and reading the versioning state of a bucket
+func TestBucketVersioningConfiguration(t *testing.T) {
+	status := ""
+	mockStorage := &MockStorage{
+		PutBucketVersioningFunc: func(bucketName, newStatus string) error {
+			if newStatus != storage.VersioningEnabled && newStatus != storage.VersioningSuspended {
+				return storage.ErrInvalidVersioning
+			}
+			status = newStatus
+			return nil
+		},
+		GetBucketVersioningFunc: func(bucketName string) (string, error) {
+			return status, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"enable", `<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`, http.StatusOK, ""},
+		{"suspend", `<VersioningConfiguration><Status>Suspended</Status></VersioningConfiguration>`, http.StatusOK, ""},
+		{"unknown status", `<VersioningConfiguration><Status>Disabled</Status></VersioningConfiguration>`, http.StatusBadRequest, "MalformedXML"},
+		{"malformed body", `<VersioningConfiguration>`, http.StatusBadRequest, "MalformedXML"},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("PUT", "/photos/?versioning", strings.NewReader(tt.body))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedCode, rr.Code)
+			continue
+		}
+		if tt.expectedErr != "" {
+			if code := errorCode(t, rr); code != tt.expectedErr {
+				t.Errorf("%s: expected error code %s but got %s", tt.name, tt.expectedErr, code)
+			}
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/photos/?versioning", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var config dto.VersioningConfiguration
+	if err := xml.Unmarshal(rr.Body.Bytes(), &config); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if config.Status != storage.VersioningSuspended {
+		t.Errorf("expected status Suspended but got %q", config.Status)
+	}
+}
+
+// Test that versionId is passed to storage and version headers are returned
+func TestObjectVersionHeaders(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var requested string
+	mockStorage := &MockStorage{
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, metadata dto.ObjectMetadata) (dto.ObjectInfo, error) {
+			return dto.ObjectInfo{ETag: "5d41402abc4b2a76b9719d911017c592", VersionID: "v2"}, nil
+		},
+		GetObjectFunc: func(bucketName, objectName, versionID string) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+			requested = versionID
+			switch versionID {
+			case "", "v2":
+				return newMockObject("hello"), dto.ObjectInfo{Key: objectName, Size: 5, LastModified: modTime, VersionID: "v2"}, nil
+			case "v3":
+				return nil, dto.ObjectInfo{Key: objectName, LastModified: modTime, VersionID: "v3"}, storage.ErrDeleteMarker
+			}
+			return nil, dto.ObjectInfo{}, storage.ErrNoSuchVersion
+		},
+		DeleteObjectFunc: func(bucketName, objectName, versionID string) (dto.DeleteObjectResult, error) {
+			if versionID == "" {
+				return dto.DeleteObjectResult{VersionID: "v3", DeleteMarker: true}, nil
+			}
+			return dto.DeleteObjectResult{VersionID: versionID}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	req, _ := http.NewRequest("PUT", "/photos/cat.jpg", strings.NewReader("hello"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if got := rr.Header().Get("x-amz-version-id"); got != "v2" {
+		t.Errorf("PUT: expected version id v2 but got %q", got)
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		expectedCode  int
+		expectedErr   string
+		versionHeader string
+		deleteMarker  string
+	}{
+		{"get current", "GET", "/photos/cat.jpg", http.StatusOK, "", "v2", ""},
+		{"get version", "GET", "/photos/cat.jpg?versionId=v2", http.StatusOK, "", "v2", ""},
+		{"get delete marker", "GET", "/photos/cat.jpg?versionId=v3", http.StatusMethodNotAllowed, "MethodNotAllowed", "v3", "true"},
+		{"get unknown version", "GET", "/photos/cat.jpg?versionId=v9", http.StatusNotFound, "NoSuchVersion", "", ""},
+		{"delete creates marker", "DELETE", "/photos/cat.jpg", http.StatusNoContent, "", "v3", "true"},
+		{"delete version", "DELETE", "/photos/cat.jpg?versionId=v2", http.StatusNoContent, "", "v2", ""},
+	}
+
+	for _, tt := range tests {
+		requested = ""
+		req, _ := http.NewRequest(tt.method, tt.url, nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedCode, rr.Code)
+			continue
+		}
+		if tt.expectedErr != "" {
+			if code := errorCode(t, rr); code != tt.expectedErr {
+				t.Errorf("%s: expected error code %s but got %s", tt.name, tt.expectedErr, code)
+			}
+		}
+		if tt.method == "GET" && requested != req.URL.Query().Get("versionId") {
+			t.Errorf("%s: storage received version %q", tt.name, requested)
+		}
+		if got := rr.Header().Get("x-amz-version-id"); got != tt.versionHeader {
+			t.Errorf("%s: expected version id %q but got %q", tt.name, tt.versionHeader, got)
+		}
+		if got := rr.Header().Get("x-amz-delete-marker"); got != tt.deleteMarker {
+			t.Errorf("%s: expected delete marker %q but got %q", tt.name, tt.deleteMarker, got)
+		}
+	}
+}
+
+// Test the ListObjectVersions query parameters and response
+func TestListObjectVersions(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mockStorage := &MockStorage{
+		ListObjectVersionsFunc: func(bucketName, prefix, keyMarker, versionIDMarker string, maxKeys int) (dto.ListVersionsResult, error) {
+			if prefix != "cats/" || keyMarker != "cats/a.jpg" || versionIDMarker != "v1" || maxKeys != 2 {
+				t.Errorf("unexpected parameters: %q %q %q %d", prefix, keyMarker, versionIDMarker, maxKeys)
+			}
+			return dto.ListVersionsResult{
+				Xmlns:   dto.S3Namespace,
+				Name:    bucketName,
+				Prefix:  prefix,
+				MaxKeys: maxKeys,
+				Versions: []dto.ObjectVersion{
+					{Key: "cats/b.jpg", VersionId: "v2", LastModified: modTime, ETag: `"5d41402abc4b2a76b9719d911017c592"`, Size: 5, StorageClass: "STANDARD"},
+				},
+				DeleteMarkers: []dto.DeleteMarkerEntry{
+					{Key: "cats/b.jpg", VersionId: "v3", IsLatest: true, LastModified: modTime},
+				},
+			}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	req, _ := http.NewRequest("GET", "/photos/?versions&prefix=cats/&key-marker=cats/a.jpg&version-id-marker=v1&max-keys=2", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	var result dto.ListVersionsResult
+	if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(result.Versions) != 1 || result.Versions[0].VersionId != "v2" {
+		t.Errorf("unexpected versions: %+v", result.Versions)
+	}
+	if len(result.DeleteMarkers) != 1 || !result.DeleteMarkers[0].IsLatest {
+		t.Errorf("unexpected delete markers: %+v", result.DeleteMarkers)
+	}
+}